@@ -3,19 +3,16 @@ package libbtc
 import (
 	"bytes"
 	"context"
-	"crypto/ecdsa"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"time"
 
-	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
-	"github.com/sirupsen/logrus"
+	"github.com/renproject/libbtc-go/clients"
 )
 
 // The TxExecutionSpeed indicates the tier of speed that the transaction falls
@@ -31,9 +28,25 @@ const (
 )
 
 type account struct {
-	PrivKey *btcec.PrivateKey
-	Logger  logrus.FieldLogger
+	Signer        Signer
+	Logger        Logger
+	DustPolicy    DustPolicy
+	SigHashType   txscript.SigHashType
+	Change        ChangeAddresser
+	MaxFeePercent float64
+	reservations  *reservations
 	Client
+
+	// arrange, if set, reorders the outputs of the transactions of the
+	// account once they are funded and their fee is paid, before they are
+	// signed.
+	arrange func(*wire.MsgTx)
+
+	// dustSend is set for the sends that only pay dust, or nothing, to their
+	// recipients, such as Omni sends and anchors. Their fee is checked
+	// against the value of every output, including the change, as it would
+	// otherwise always be above the maximum percentage.
+	dustSend bool
 }
 
 // Account is an Bitcoin external account that can sign and submit transactions
@@ -44,8 +57,51 @@ type Account interface {
 	BTCClient() Client
 	Address() (btcutil.Address, error)
 	SerializedPublicKey() ([]byte, error)
+	SetDustPolicy(policy DustPolicy)
+	SetSigHashType(hashType txscript.SigHashType)
+	SetChangeAddresser(addresser ChangeAddresser)
+	SetMaxFeePercent(percent float64)
 	Transfer(ctx context.Context, to string, value int64, speed TxExecutionSpeed, sendAll bool) (string, int64, error)
+	TransferAmount(ctx context.Context, to string, value btcutil.Amount, speed TxExecutionSpeed, sendAll bool) (string, btcutil.Amount, error)
 	BuildTransfer(ctx context.Context, to string, value int64, speed TxExecutionSpeed, sendAll bool) (string, []byte, error)
+	EstimateTransfer(ctx context.Context, to string, value int64, speed TxExecutionSpeed) (TransferQuote, error)
+	TransferTemplate(ctx context.Context, template TxTemplate, speed TxExecutionSpeed) (string, int64, error)
+	SweepScript(ctx context.Context, redeemScript []byte, to string, template [][]byte, speed TxExecutionSpeed) (string, int64, error)
+
+	// SpendContract spends the outputs of the redeem script to the given
+	// address, satisfying it with the stack returned by the witness template
+	// for the signature and public key of the account.
+	SpendContract(ctx context.Context, redeemScript []byte, witnessTemplate func(sig, pubKey []byte) wire.TxWitness, to string, speed TxExecutionSpeed) (string, int64, error)
+
+	// MigrateSlave moves the funds of the slave addresses of the nonce in
+	// other formats to the slave address of the new version.
+	MigrateSlave(ctx context.Context, oldNonce []byte, newVersion SlaveVersion) ([]string, error)
+
+	// SweepSlaves spends the funds of the slave addresses of the nonces
+	// holding at least the threshold to the address of the account, batching
+	// their inputs.
+	SweepSlaves(ctx context.Context, nonces [][]byte, threshold int64, speed TxExecutionSpeed) ([]string, error)
+
+	// Anchor commits the hash in an OP_RETURN output and returns the hash of
+	// the transaction with a proof of its inclusion, once it is confirmed.
+	Anchor(ctx context.Context, data [32]byte, speed TxExecutionSpeed) (string, MerkleProof, error)
+	BuildTransferTemplate(ctx context.Context, template TxTemplate, speed TxExecutionSpeed) (string, []byte, error)
+
+	// TransferOmni sends the amount, in willets, of the Omni property to the
+	// address in a simple send, with the bitcoin change back to the account.
+	TransferOmni(ctx context.Context, to string, propertyID uint32, amount int64, speed TxExecutionSpeed, opts ...OmniOption) (string, int64, error)
+
+	// BuildOmni builds and signs the transaction of TransferOmni without
+	// publishing it.
+	BuildOmni(ctx context.Context, to string, propertyID uint32, amount int64, speed TxExecutionSpeed, opts ...OmniOption) (string, []byte, error)
+
+	// TransferUSDT sends the amount of USDT, in willets, to the address in an
+	// Omni simple send, with the bitcoin change back to the account.
+	TransferUSDT(ctx context.Context, to string, amount int64, speed TxExecutionSpeed, opts ...OmniOption) (string, int64, error)
+
+	// BuildTransferUSDT builds and signs the transaction of TransferUSDT
+	// without publishing it.
+	BuildTransferUSDT(ctx context.Context, to string, amount int64, speed TxExecutionSpeed, opts ...OmniOption) (string, []byte, error)
 	SendTransaction(
 		ctx context.Context,
 		script []byte,
@@ -68,26 +124,28 @@ type Account interface {
 	) (string, []byte, error)
 }
 
-// NewAccount returns a user account for the provided private key which is
-// connected to a Bitcoin client.
-func NewAccount(client Client, privateKey *ecdsa.PrivateKey, logger logrus.FieldLogger) Account {
+// NewAccount returns a user account for the provided signer which is connected
+// to a Bitcoin client. Use NewPrivateKeySigner for a private key held in
+// memory.
+func NewAccount(client Client, signer Signer, logger Logger) Account {
 	if logger == nil {
-		nullLogger := logrus.New()
-		logFile, err := os.OpenFile(os.DevNull, os.O_APPEND|os.O_WRONLY, 0666)
-		if err != nil {
-			panic(err)
-		}
-		nullLogger.SetOutput(logFile)
-		logger = nullLogger
+		logger = clients.NopLogger()
 	}
 	return &account{
-		(*btcec.PrivateKey)(privateKey),
+		signer,
 		logger,
+		DustToFee,
+		txscript.SigHashAll,
+		nil,
+		DefaultMaxFeePercent,
+		newReservations(),
 		client,
+		nil,
+		false,
 	}
 }
 
-// Address returns the address of the given private key
+// Address returns the address of the public key of the signer
 func (account *account) Address() (btcutil.Address, error) {
 	pubKeyBytes, err := account.SerializedPublicKey()
 	if err != nil {
@@ -180,6 +238,11 @@ func (account *account) BuildTransfer(ctx context.Context, to string, value int6
 // to be used with non empty contracts, to modify the signature script. preCond
 // is executed in the starting of the process, if it returns false
 // SendTransaction returns ErrPreConditionCheckFailed and stops the process.
+// SendTransaction is safe for concurrent use: concurrent transactions never
+// select the same unspent outputs, and a transaction whose outputs turn out to
+// be spent is sent again, with fresh outputs, up to MaxConflictRetries times.
+// Contracts other than the slave script are more easily spent with
+// SpendContract.
 func (account *account) SendTransaction(
 	ctx context.Context,
 	contract []byte,
@@ -189,7 +252,32 @@ func (account *account) SendTransaction(
 	f func(*txscript.ScriptBuilder),
 	postCond func(*wire.MsgTx) bool,
 	sendAll bool,
-) (string, int64, error) {
+) (txHash string, txFee int64, err error) {
+	ctx, span := startSpan(ctx, "libbtc.Account.SendTransaction", nil)
+	defer func() { span.End(err) }()
+
+	// The outputs selected by concurrent transactions of the account are
+	// reserved, but they can still be spent by transactions sent by other
+	// means, in which case the transaction is sent again with fresh outputs.
+	for attempt := 1; ; attempt++ {
+		txHash, txFee, err = account.sendTransaction(ctx, contract, speed, updateTxIn, preCond, f, postCond, sendAll)
+		if err == nil || !isMempoolConflict(err) || attempt > MaxConflictRetries {
+			return txHash, txFee, err
+		}
+		account.Logger.Infof("inputs of the tx already spent, retrying with fresh utxos")
+	}
+}
+
+func (account *account) sendTransaction(
+	ctx context.Context,
+	contract []byte,
+	speed TxExecutionSpeed,
+	updateTxIn func(*wire.TxIn),
+	preCond func(*wire.MsgTx) bool,
+	f func(*txscript.ScriptBuilder),
+	postCond func(*wire.MsgTx) bool,
+	sendAll bool,
+) (txHash string, txFee int64, err error) {
 	// Current Bitcoin Transaction Version (2).
 	tx := account.newTx(ctx, wire.NewMsgTx(2))
 	if preCond != nil && !preCond(tx.msgTx) {
@@ -197,7 +285,6 @@ func (account *account) SendTransaction(
 	}
 
 	var address btcutil.Address
-	var err error
 	if contract == nil {
 		address, err = account.Address()
 		if err != nil {
@@ -211,61 +298,94 @@ func (account *account) SendTransaction(
 	}
 
 	account.Logger.Infof("funding %s, with fee %d SAT/byte", address.EncodeAddress(), speed)
-	if sendAll {
-		if err := tx.fundAll(address); err != nil {
-			return "", 0, err
-		}
-	} else {
-		if err := tx.fund(address); err != nil {
-			return "", 0, err
+	account.reservations.lock()
+	err = traceStep(tx, "libbtc.Account.fund", func() error {
+		if sendAll {
+			return tx.fundAll(address)
 		}
+		return tx.fund(address)
+	})
+	if err == nil {
+		account.reservations.reserve(tx.msgTx)
 	}
-	account.Logger.Info("successfully funded the transaction")
-
-	account.Logger.Info("estimating stx size")
-	size, err := tx.estimateSTXSize(f, updateTxIn, contract)
+	account.reservations.unlock()
 	if err != nil {
 		return "", 0, err
 	}
-	account.Logger.Info("successfully estimated stx size")
+	account.Logger.Infof("successfully funded the transaction")
+
+	// The outputs stay reserved once the transaction is submitted, and when
+	// they turn out to be spent already.
+	submitted := false
+	defer func() {
+		if err != nil && !submitted && !isMempoolConflict(err) {
+			account.reservations.release(tx.msgTx)
+		}
+	}()
+
+	account.Logger.Infof("estimating stx size")
+	var size int
+	if err := traceStep(tx, "libbtc.Account.estimate", func() error {
+		var err error
+		size, err = tx.estimateSTXVSize(f, contract)
+		return err
+	}); err != nil {
+		return "", 0, err
+	}
+	account.Logger.Infof("successfully estimated stx size")
 
-	rate, err := SuggestedTxRate(speed)
+	rate, err := account.TxRate(ctx, speed)
 	if err != nil {
-		rate = 30
+		return "", 0, err
 	}
 
-	txFee := int64(size) * rate
-	if txFee > MaxBitcoinFee-BitcoinDust {
-		txFee = MaxBitcoinFee
+	txFee = int64(size) * rate
+	if maxFee := NetworkMaxFee(account.NetworkParams()); txFee > maxFee-NetworkDust(account.NetworkParams()) {
+		txFee = maxFee
 	}
 	tx.msgTx.TxOut[len(tx.msgTx.TxOut)-1].Value -= txFee
+	value := tx.transferredValue(sendAll || account.dustSend)
+	if !sendAll {
+		if err := account.applyDustPolicy(tx.msgTx); err != nil {
+			return "", 0, err
+		}
+	}
+	if err := checkFee(tx.fee(), value, account.MaxFeePercent); err != nil {
+		return "", 0, err
+	}
+	if account.arrange != nil {
+		account.arrange(tx.msgTx)
+	}
 
-	account.Logger.Info("signing the tx")
-	if err := tx.sign(f, updateTxIn, contract); err != nil {
+	account.Logger.Infof("signing the tx")
+	if err := traceStep(tx, "libbtc.Account.sign", func() error {
+		return tx.sign(f, updateTxIn, contract)
+	}); err != nil {
 		return "", 0, err
 	}
-	account.Logger.Info("successfully signined the tx")
+	account.Logger.Infof("successfully signined the tx")
 
-	account.Logger.Info("verifying the tx")
-	if err := tx.verify(); err != nil {
+	account.Logger.Infof("verifying the tx")
+	if err := traceStep(tx, "libbtc.Account.verify", tx.verify); err != nil {
 		return "", 0, err
 	}
-	account.Logger.Info("successfully verified the tx")
+	account.Logger.Infof("successfully verified the tx")
 
 	for {
-		account.Logger.Info("trying to submit the tx")
+		account.Logger.Infof("trying to submit the tx")
 		select {
 		case <-ctx.Done():
-			account.Logger.Info("submitting failed due to failed post condition")
+			account.Logger.Infof("submitting failed due to failed post condition")
 			return "", 0, ErrPostConditionCheckFailed
 		default:
-			if err := tx.submit(); err != nil {
+			if err := traceStep(tx, "libbtc.Account.submit", tx.submit); err != nil {
 				account.Logger.Infof("submitting failed due to %s", err)
 				return "", 0, err
 			}
+			submitted = true
 			for i := 0; i < 60; i++ {
 				if postCond == nil || postCond(tx.msgTx) {
-					account.Logger.Infof("successfully submitted the tx", err)
+					account.Logger.Infof("successfully submitted the tx")
 					return tx.msgTx.TxHash().String(), txFee, nil
 				}
 				time.Sleep(5 * time.Second)
@@ -283,7 +403,10 @@ func (account *account) BuildTransaction(
 	f func(*txscript.ScriptBuilder),
 	postCond func(*wire.MsgTx) bool,
 	sendAll bool,
-) (string, []byte, error) {
+) (txHash string, stx []byte, err error) {
+	ctx, span := startSpan(ctx, "libbtc.Account.BuildTransaction", nil)
+	defer func() { span.End(err) }()
+
 	// Current Bitcoin Transaction Version (2).
 	tx := account.newTx(ctx, wire.NewMsgTx(2))
 	if preCond != nil && !preCond(tx.msgTx) {
@@ -291,7 +414,6 @@ func (account *account) BuildTransaction(
 	}
 
 	var address btcutil.Address
-	var err error
 	if contract == nil {
 		address, err = account.Address()
 		if err != nil {
@@ -305,46 +427,63 @@ func (account *account) BuildTransaction(
 	}
 
 	account.Logger.Infof("funding %s, with fee %d SAT/byte", address.EncodeAddress(), speed)
-	if sendAll {
-		if err := tx.fundAll(address); err != nil {
-			return "", nil, err
-		}
-	} else {
-		if err := tx.fund(address); err != nil {
-			return "", nil, err
+	if err := traceStep(tx, "libbtc.Account.fund", func() error {
+		if sendAll {
+			return tx.fundAll(address)
 		}
+		return tx.fund(address)
+	}); err != nil {
+		return "", nil, err
 	}
-	account.Logger.Info("successfully funded the transaction")
-
-	account.Logger.Info("estimating stx size")
-	size, err := tx.estimateSTXSize(f, updateTxIn, contract)
-	if err != nil {
+	account.Logger.Infof("successfully funded the transaction")
+
+	account.Logger.Infof("estimating stx size")
+	var size int
+	if err := traceStep(tx, "libbtc.Account.estimate", func() error {
+		var err error
+		size, err = tx.estimateSTXVSize(f, contract)
+		return err
+	}); err != nil {
 		return "", nil, err
 	}
-	account.Logger.Info("successfully estimated stx size")
+	account.Logger.Infof("successfully estimated stx size")
 
-	rate, err := SuggestedTxRate(speed)
+	rate, err := account.TxRate(ctx, speed)
 	if err != nil {
-		rate = 30
+		return "", nil, err
 	}
 
 	txFee := int64(size) * rate
-	if txFee > MaxBitcoinFee-BitcoinDust {
-		txFee = MaxBitcoinFee
+	if maxFee := NetworkMaxFee(account.NetworkParams()); txFee > maxFee-NetworkDust(account.NetworkParams()) {
+		txFee = maxFee
 	}
 	tx.msgTx.TxOut[len(tx.msgTx.TxOut)-1].Value -= txFee
+	value := tx.transferredValue(sendAll || account.dustSend)
+	if !sendAll {
+		if err := account.applyDustPolicy(tx.msgTx); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := checkFee(tx.fee(), value, account.MaxFeePercent); err != nil {
+		return "", nil, err
+	}
+	if account.arrange != nil {
+		account.arrange(tx.msgTx)
+	}
 
-	account.Logger.Info("signing the tx")
-	if err := tx.sign(f, updateTxIn, contract); err != nil {
+	account.Logger.Infof("signing the tx")
+	if err := traceStep(tx, "libbtc.Account.sign", func() error {
+		return tx.sign(f, updateTxIn, contract)
+	}); err != nil {
 		return "", nil, err
 	}
-	account.Logger.Info("successfully signined the tx")
+	account.Logger.Infof("successfully signined the tx")
 
-	account.Logger.Info("verifying the tx")
-	if err := tx.verify(); err != nil {
+	account.Logger.Infof("verifying the tx")
+	if err := traceStep(tx, "libbtc.Account.verify", tx.verify); err != nil {
 		return "", nil, err
 	}
-	account.Logger.Info("successfully verified the tx")
+	account.Logger.Infof("successfully verified the tx")
 
 	var stxBuffer bytes.Buffer
 	stxBuffer.Grow(tx.msgTx.SerializeSize())
@@ -356,7 +495,48 @@ func (account *account) BuildTransaction(
 }
 
 func (account *account) SerializedPublicKey() ([]byte, error) {
-	return account.SerializePublicKey(account.PrivKey.PubKey())
+	return account.SerializePublicKey(account.Signer.PublicKey())
+}
+
+// SetDustPolicy sets what happens to the change output of the transactions
+// sent by the account when, once fees are paid, its value falls below dust.
+func (account *account) SetDustPolicy(policy DustPolicy) {
+	account.DustPolicy = policy
+}
+
+// SetSigHashType sets the sighash type with which the account signs every
+// input of the transactions it sends, which is SigHashAll by default. Types
+// with the SigHashForkID flag sign the digest of BCH-style fork networks, and
+// transactions are refused if any input is not signed with it.
+func (account *account) SetSigHashType(hashType txscript.SigHashType) {
+	account.SigHashType = hashType
+}
+
+// SetChangeAddresser sets where the change of the transactions sent by the
+// account goes. By default, change goes back to the address being spent from.
+func (account *account) SetChangeAddresser(addresser ChangeAddresser) {
+	account.Change = addresser
+}
+
+// SetMaxFeePercent sets the percentage of the value transferred by the
+// transactions sent by the account that their fee can reach, which is
+// DefaultMaxFeePercent by default. Zero disables the check, so that any fee is
+// paid.
+func (account *account) SetMaxFeePercent(percent float64) {
+	account.MaxFeePercent = percent
+}
+
+// applyDustPolicy applies the dust policy of the account to the change output,
+// which is the last output added when funding the transaction. The payment is
+// assumed to be the first output.
+func (account *account) applyDustPolicy(msgTx *wire.MsgTx) error {
+	// Null data outputs, like Omni payloads, are never paid to.
+	paymentIndex := -1
+	if len(msgTx.TxOut) > 1 && txscript.GetScriptClass(msgTx.TxOut[0].PkScript) != txscript.NullDataTy {
+		paymentIndex = 0
+	}
+	_, err := applyDustPolicy(msgTx, len(msgTx.TxOut)-1, paymentIndex, NetworkDust(account.NetworkParams()), account.DustPolicy)
+	return err
 }
 
 func (account *account) BTCClient() Client {
@@ -366,24 +546,31 @@ func (account *account) BTCClient() Client {
 // SuggestedTxRate returns the gas price that bitcoinfees.earn.com recommends for
 // transactions to be mined on Bitcoin blockchain based on the speed provided.
 func SuggestedTxRate(txSpeed TxExecutionSpeed) (int64, error) {
+	return SuggestedTxRateWithClient(&http.Client{Timeout: clients.DefaultHTTPTimeout}, txSpeed)
+}
+
+// SuggestedTxRateWithClient returns the rate suggested by bitcoinfees.earn.com,
+// like SuggestedTxRate, requesting it with the http.Client.
+func SuggestedTxRateWithClient(httpClient *http.Client, txSpeed TxExecutionSpeed) (int64, error) {
 	request, err := http.NewRequest("GET", "https://bitcoinfees.earn.com/api/v1/fees/recommended", nil)
 	if err != nil {
 		return 0, fmt.Errorf("cannot build request to bitcoinfees.earn.com = %v", err)
 	}
 	request.Header.Set("Content-Type", "application/json")
 
-	res, err := (&http.Client{}).Do(request)
+	res, err := httpClient.Do(request)
 	if err != nil {
 		return 0, fmt.Errorf("cannot connect to bitcoinfees.earn.com = %v", err)
 	}
+	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
 		return 0, fmt.Errorf("unexpected status code %v from bitcoinfees.earn.com", res.StatusCode)
 	}
 
 	data := struct {
-		Slow     int64 `json:"fastestFee"`
+		Slow     int64 `json:"hourFee"`
 		Standard int64 `json:"halfHourFee"`
-		Fast     int64 `json:"hourFee"`
+		Fast     int64 `json:"fastestFee"`
 	}{}
 	if err = json.NewDecoder(res.Body).Decode(&data); err != nil {
 		resp, err := ioutil.ReadAll(res.Body)
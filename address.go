@@ -1,6 +1,8 @@
 package libbtc
 
 import (
+	"crypto/sha256"
+
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcutil"
 )
@@ -25,7 +27,24 @@ func (client *client) SlaveScript(mpkh, nonce []byte) ([]byte, error) {
 	return b.Script()
 }
 
+func (client *client) SlaveAddressSegwit(mpkh, nonce []byte) (btcutil.Address, error) {
+	script, err := client.SlaveScriptSegwit(mpkh, nonce)
+	if err != nil {
+		return nil, err
+	}
+	scriptHash := sha256.Sum256(script)
+	return btcutil.NewAddressWitnessScriptHash(scriptHash[:], client.NetworkParams())
+}
+
+// SlaveScriptSegwit returns the witness script of the P2WSH slave address,
+// which is the same script as the P2SH one. The master public key hash must be
+// the hash of a compressed public key, as segwit inputs with uncompressed
+// public keys are not standard.
+func (client *client) SlaveScriptSegwit(mpkh, nonce []byte) ([]byte, error) {
+	return client.SlaveScript(mpkh, nonce)
+}
+
 func (client *client) Validate(address string) error {
-	_, err := btcutil.DecodeAddress(address, client.NetworkParams())
+	_, err := client.InspectAddress(address)
 	return err
 }
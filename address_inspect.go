@@ -0,0 +1,298 @@
+package libbtc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// AddressType is the kind of script an address pays to.
+type AddressType uint8
+
+// AddressType values.
+const (
+	AddressUnknown = AddressType(iota)
+	AddressP2PKH
+	AddressP2SH
+	AddressP2WPKH
+	AddressP2WSH
+	AddressP2TR
+	// AddressWitnessUnknown is a segwit address of a witness version or
+	// program size without defined semantics yet.
+	AddressWitnessUnknown
+)
+
+func (addressType AddressType) String() string {
+	switch addressType {
+	case AddressP2PKH:
+		return "p2pkh"
+	case AddressP2SH:
+		return "p2sh"
+	case AddressP2WPKH:
+		return "p2wpkh"
+	case AddressP2WSH:
+		return "p2wsh"
+	case AddressP2TR:
+		return "p2tr"
+	case AddressWitnessUnknown:
+		return "witness_unknown"
+	default:
+		return "unknown"
+	}
+}
+
+// AddressInfo describes a decoded address.
+type AddressInfo struct {
+	Type         AddressType
+	Network      *chaincfg.Params
+	ScriptPubKey []byte
+
+	// WitnessVersion and WitnessProgram are only set for segwit addresses.
+	WitnessVersion int
+	WitnessProgram []byte
+}
+
+// inspectNetworks are the networks an address is matched against. Testnet and
+// regtest share their base58 prefixes, so the network of the client is tried
+// first.
+var inspectNetworks = []*chaincfg.Params{
+	&chaincfg.MainNetParams,
+	&chaincfg.TestNet3Params,
+	&chaincfg.RegressionNetParams,
+	&chaincfg.SimNetParams,
+}
+
+// InspectAddress decodes the address, validating its checksum, and returns its
+// type, network and public key script. If the address is valid for another
+// network than the one of the client, the information is returned along with
+// a mismatched networks error.
+func (client *client) InspectAddress(address string) (AddressInfo, error) {
+	networks := append([]*chaincfg.Params{client.NetworkParams()}, inspectNetworks...)
+
+	var info AddressInfo
+	var err error
+	if hrp, ok := bech32HRP(address, networks); ok {
+		info, err = inspectSegwitAddress(address, hrp, networks)
+	} else {
+		info, err = inspectBase58Address(address, networks)
+	}
+	if err != nil {
+		return AddressInfo{}, err
+	}
+	if info.Network.Name != client.NetworkParams().Name {
+		return info, errors.NewErrMismatchedNetworks(client.NetworkParams().Name, info.Network.Name)
+	}
+	return info, nil
+}
+
+func inspectBase58Address(address string, networks []*chaincfg.Params) (AddressInfo, error) {
+	payload, version, err := base58.CheckDecode(address)
+	if err != nil {
+		return AddressInfo{}, fmt.Errorf("invalid address %s: %v", address, err)
+	}
+	if len(payload) != 20 {
+		return AddressInfo{}, fmt.Errorf("invalid address %s: hash of %d bytes", address, len(payload))
+	}
+
+	for _, network := range networks {
+		switch version {
+		case network.PubKeyHashAddrID:
+			script, err := txscript.NewScriptBuilder().
+				AddOp(txscript.OP_DUP).AddOp(txscript.OP_HASH160).AddData(payload).
+				AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG).
+				Script()
+			if err != nil {
+				return AddressInfo{}, err
+			}
+			return AddressInfo{Type: AddressP2PKH, Network: network, ScriptPubKey: script}, nil
+		case network.ScriptHashAddrID:
+			script, err := txscript.NewScriptBuilder().
+				AddOp(txscript.OP_HASH160).AddData(payload).AddOp(txscript.OP_EQUAL).
+				Script()
+			if err != nil {
+				return AddressInfo{}, err
+			}
+			return AddressInfo{Type: AddressP2SH, Network: network, ScriptPubKey: script}, nil
+		}
+	}
+	return AddressInfo{}, fmt.Errorf("invalid address %s: unknown version byte %d", address, version)
+}
+
+func inspectSegwitAddress(address, hrp string, networks []*chaincfg.Params) (AddressInfo, error) {
+	var network *chaincfg.Params
+	for _, params := range networks {
+		if params.Bech32HRPSegwit == hrp {
+			network = params
+			break
+		}
+	}
+
+	_, data, encoding, err := bech32Decode(address)
+	if err != nil {
+		return AddressInfo{}, fmt.Errorf("invalid address %s: %v", address, err)
+	}
+	if len(data) < 1 || data[0] > 16 {
+		return AddressInfo{}, fmt.Errorf("invalid address %s: invalid witness version", address)
+	}
+	version := int(data[0])
+	program, err := convertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return AddressInfo{}, fmt.Errorf("invalid address %s: %v", address, err)
+	}
+	if len(program) < 2 || len(program) > 40 {
+		return AddressInfo{}, fmt.Errorf("invalid address %s: witness program of %d bytes", address, len(program))
+	}
+
+	// BIP350: version 0 programs use bech32, later versions use bech32m.
+	if version == 0 && encoding != bech32Const || version != 0 && encoding != bech32mConst {
+		return AddressInfo{}, fmt.Errorf("invalid address %s: wrong checksum variant for witness version %d", address, version)
+	}
+
+	addressType := AddressWitnessUnknown
+	switch {
+	case version == 0 && len(program) == 20:
+		addressType = AddressP2WPKH
+	case version == 0 && len(program) == 32:
+		addressType = AddressP2WSH
+	case version == 0:
+		return AddressInfo{}, fmt.Errorf("invalid address %s: witness v0 program of %d bytes", address, len(program))
+	case version == 1 && len(program) == 32:
+		addressType = AddressP2TR
+	}
+
+	versionOp := byte(txscript.OP_0)
+	if version > 0 {
+		versionOp = byte(txscript.OP_1 + version - 1)
+	}
+	script, err := txscript.NewScriptBuilder().AddOp(versionOp).AddData(program).Script()
+	if err != nil {
+		return AddressInfo{}, err
+	}
+	return AddressInfo{
+		Type:           addressType,
+		Network:        network,
+		ScriptPubKey:   script,
+		WitnessVersion: version,
+		WitnessProgram: program,
+	}, nil
+}
+
+// Checksum constants of bech32 (BIP173) and bech32m (BIP350).
+const (
+	bech32Const  = uint32(1)
+	bech32mConst = uint32(0x2bc830a3)
+)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// bech32HRP returns the human-readable part of the address if it is the
+// segwit human-readable part of one of the networks.
+func bech32HRP(address string, networks []*chaincfg.Params) (string, bool) {
+	sep := strings.LastIndexByte(address, '1')
+	if sep < 1 {
+		return "", false
+	}
+	hrp := strings.ToLower(address[:sep])
+	for _, params := range networks {
+		if hrp == params.Bech32HRPSegwit {
+			return hrp, true
+		}
+	}
+	return "", false
+}
+
+// bech32Decode decodes a bech32 or bech32m string, returning its
+// human-readable part, its data without the checksum, and the checksum
+// constant it was encoded with.
+func bech32Decode(s string) (string, []byte, uint32, error) {
+	if len(s) > 90 {
+		return "", nil, 0, fmt.Errorf("bech32 string of %d characters is too long", len(s))
+	}
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, 0, fmt.Errorf("bech32 string has mixed case")
+	}
+	s = strings.ToLower(s)
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || sep+7 > len(s) {
+		return "", nil, 0, fmt.Errorf("invalid bech32 separator position")
+	}
+	hrp := s[:sep]
+	for _, c := range []byte(hrp) {
+		if c < 33 || c > 126 {
+			return "", nil, 0, fmt.Errorf("invalid bech32 human-readable part")
+		}
+	}
+	data := make([]byte, 0, len(s)-sep-1)
+	for _, c := range []byte(s[sep+1:]) {
+		value := strings.IndexByte(bech32Charset, c)
+		if value < 0 {
+			return "", nil, 0, fmt.Errorf("invalid bech32 character %q", c)
+		}
+		data = append(data, byte(value))
+	}
+
+	checksum := bech32Polymod(append(bech32HRPExpand(hrp), data...))
+	if checksum != bech32Const && checksum != bech32mConst {
+		return "", nil, 0, fmt.Errorf("invalid bech32 checksum")
+	}
+	return hrp, data[:len(data)-6], checksum, nil
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := []uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, value := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(value)
+		for i := uint(0); i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	var buf bytes.Buffer
+	for _, c := range []byte(hrp) {
+		buf.WriteByte(c >> 5)
+	}
+	buf.WriteByte(0)
+	for _, c := range []byte(hrp) {
+		buf.WriteByte(c & 31)
+	}
+	return buf.Bytes()
+}
+
+// convertBits regroups the bits of data from groups of fromBits to groups of
+// toBits.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	acc, bits := uint32(0), uint(0)
+	maxValue := uint32(1)<<toBits - 1
+	converted := []byte{}
+	for _, value := range data {
+		if uint32(value)>>fromBits != 0 {
+			return nil, fmt.Errorf("invalid data range")
+		}
+		acc = acc<<fromBits | uint32(value)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			converted = append(converted, byte(acc>>bits&maxValue))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			converted = append(converted, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	return converted, nil
+}
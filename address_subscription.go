@@ -0,0 +1,109 @@
+package libbtc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/renproject/libbtc-go/clients"
+)
+
+// addressPollInterval is the interval at which subscribed addresses are
+// polled.
+const addressPollInterval = 15 * time.Second
+
+// AddressEventType is the type of an AddressEvent.
+type AddressEventType uint8
+
+// AddressEventType values.
+const (
+	// AddressReceived is emitted when an output paying to the address
+	// appears.
+	AddressReceived = AddressEventType(iota)
+	// AddressSpent is emitted when an output paying to the address is spent.
+	AddressSpent
+)
+
+// AddressEvent is an output of an address that was received or spent.
+type AddressEvent struct {
+	Type    AddressEventType
+	Address string
+	TxHash  string
+	Vout    uint32
+	Amount  int64
+}
+
+func (client *client) SubscribeAddress(ctx context.Context, address string) (<-chan AddressEvent, error) {
+	if err := client.Validate(address); err != nil {
+		return nil, err
+	}
+	utxos, err := client.GetUTXOs(ctx, address, 999999, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan AddressEvent, len(utxos))
+	known := map[string]clients.UTXO{}
+	for _, utxo := range utxos {
+		known[utxoKey(utxo)] = utxo
+		events <- addressEvent(AddressReceived, address, utxo)
+	}
+
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(addressPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			utxos, err := client.GetUTXOs(ctx, address, 999999, 0)
+			if err != nil {
+				continue
+			}
+			latest := map[string]clients.UTXO{}
+			for _, utxo := range utxos {
+				latest[utxoKey(utxo)] = utxo
+			}
+
+			diff := []AddressEvent{}
+			for key, utxo := range latest {
+				if _, ok := known[key]; !ok {
+					diff = append(diff, addressEvent(AddressReceived, address, utxo))
+				}
+			}
+			for key, utxo := range known {
+				if _, ok := latest[key]; !ok {
+					diff = append(diff, addressEvent(AddressSpent, address, utxo))
+				}
+			}
+			known = latest
+
+			for _, event := range diff {
+				select {
+				case <-ctx.Done():
+					return
+				case events <- event:
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+func addressEvent(eventType AddressEventType, address string, utxo clients.UTXO) AddressEvent {
+	return AddressEvent{
+		Type:    eventType,
+		Address: address,
+		TxHash:  utxo.TxHash,
+		Vout:    utxo.Vout,
+		Amount:  utxo.Amount,
+	}
+}
+
+func utxoKey(utxo clients.UTXO) string {
+	return fmt.Sprintf("%s:%d", utxo.TxHash, utxo.Vout)
+}
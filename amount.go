@@ -0,0 +1,32 @@
+package libbtc
+
+import (
+	"context"
+
+	"github.com/btcsuite/btcutil"
+)
+
+// The methods taking and returning btcutil.Amount are typed variants of the
+// methods using int64 satoshis, which are kept for compatibility. Amounts in
+// BTC are converted with btcutil.NewAmount and Amount.ToBTC, or with
+// clients.BTCToSatoshi and clients.SatoshiToBTC.
+
+// BalanceAmount returns the balance of the address, like Balance.
+func (client *client) BalanceAmount(ctx context.Context, address string, confirmations int64) (btcutil.Amount, error) {
+	balance, err := client.Balance(ctx, address, confirmations)
+	return btcutil.Amount(balance), err
+}
+
+// ScriptFundedAmount checks whether the script received at least the value,
+// like ScriptFunded.
+func (client *client) ScriptFundedAmount(ctx context.Context, address string, value btcutil.Amount) (bool, btcutil.Amount, error) {
+	funded, received, err := client.ScriptFunded(ctx, address, int64(value))
+	return funded, btcutil.Amount(received), err
+}
+
+// TransferAmount transfers the value to the address, like Transfer, and
+// returns the hash of the transaction and its fee.
+func (account *account) TransferAmount(ctx context.Context, to string, value btcutil.Amount, speed TxExecutionSpeed, sendAll bool) (string, btcutil.Amount, error) {
+	txHash, fee, err := account.Transfer(ctx, to, int64(value), speed, sendAll)
+	return txHash, btcutil.Amount(fee), err
+}
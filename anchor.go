@@ -0,0 +1,36 @@
+package libbtc
+
+import (
+	"context"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Anchor commits the hash on the chain in the OP_RETURN output of a
+// transaction from the account, and waits for the transaction to be confirmed
+// to return its hash with a proof of its inclusion in a block. If waiting
+// fails, for example because the context is done, the hash of the published
+// transaction is returned with the error, and the proof can be fetched later
+// with GetTxMerkleProof.
+func (account *account) Anchor(ctx context.Context, data [32]byte, speed TxExecutionSpeed) (string, MerkleProof, error) {
+	script, err := txscript.NullDataScript(data[:])
+	if err != nil {
+		return "", MerkleProof{}, err
+	}
+	// The copy shares the reservations of the account.
+	anchoring := *account
+	anchoring.dustSend = true
+	txHash, _, err := anchoring.SendTransaction(ctx, nil, speed, nil, addTxOuts([]*wire.TxOut{wire.NewTxOut(0, script)}), nil, nil, false)
+	if err != nil {
+		return "", MerkleProof{}, err
+	}
+	if err := account.WaitForConfirmations(ctx, txHash, 1); err != nil {
+		return txHash, MerkleProof{}, err
+	}
+	proof, err := account.GetTxMerkleProof(ctx, txHash)
+	if err != nil {
+		return txHash, MerkleProof{}, err
+	}
+	return txHash, proof, nil
+}
@@ -0,0 +1,63 @@
+package libbtc_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/renproject/libbtc-go/clients/mock"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+var _ = Describe("Anchor", func() {
+	data := [32]byte{1, 2, 3}
+
+	// newFundedAccount returns an account funded on a new mock chain.
+	newFundedAccount := func() (*mock.Chain, Account) {
+		chain, account := newMockAccount()
+		address, err := account.Address()
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = chain.Fund(address.EncodeAddress(), 100000)
+		Expect(err).ShouldNot(HaveOccurred())
+		chain.Mine(1)
+		return chain, account
+	}
+
+	It("should commit the hash in a confirmed transaction with a proof of its inclusion", func() {
+		chain, account := newFundedAccount()
+		go func() {
+			defer GinkgoRecover()
+			for len(chain.Mempool()) == 0 {
+				time.Sleep(10 * time.Millisecond)
+			}
+			chain.Mine(1)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		txHash, proof, err := account.Anchor(ctx, data, Fast)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(proof.TxHash.String()).Should(Equal(txHash))
+		header, err := chain.GetBlockHeader(ctx, proof.BlockHash.String())
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(proof.Verify(header)).Should(Succeed())
+
+		tx, err := chain.RawTransaction(ctx, txHash)
+		Expect(err).ShouldNot(HaveOccurred())
+		script, err := txscript.NullDataScript(data[:])
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(tx.TxOut[0].PkScript).Should(Equal(script))
+	})
+
+	It("should check the fee against the value of every output", func() {
+		chain, account := newFundedAccount()
+		account.SetMaxFeePercent(0.1)
+		_, _, err := account.Anchor(context.Background(), data, Fast)
+		Expect(errors.Is(err, errors.ErrAbsurdFee)).Should(BeTrue())
+		Expect(chain.Published()).Should(BeEmpty())
+	})
+})
@@ -0,0 +1,73 @@
+package libbtc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// balanceRefreshTimeout bounds how long a background balance refresh can take.
+const balanceRefreshTimeout = time.Minute
+
+type cachedBalance struct {
+	balance    int64
+	updatedAt  time.Time
+	refreshing bool
+}
+
+type balanceCache struct {
+	mu       *sync.Mutex
+	balances map[string]*cachedBalance
+}
+
+func newBalanceCache() *balanceCache {
+	return &balanceCache{
+		mu:       new(sync.Mutex),
+		balances: map[string]*cachedBalance{},
+	}
+}
+
+func (client *client) BalanceCached(ctx context.Context, address string) (int64, time.Duration, error) {
+	cache := client.balances
+	cache.mu.Lock()
+	cached, ok := cache.balances[address]
+	if !ok {
+		cache.mu.Unlock()
+		balance, err := client.Balance(ctx, address, 0)
+		if err != nil {
+			return 0, 0, err
+		}
+		cache.mu.Lock()
+		if _, ok := cache.balances[address]; !ok {
+			cache.balances[address] = &cachedBalance{balance: balance, updatedAt: time.Now()}
+		}
+		cache.mu.Unlock()
+		return balance, 0, nil
+	}
+	defer cache.mu.Unlock()
+
+	if !cached.refreshing {
+		cached.refreshing = true
+		go client.refreshBalance(address)
+	}
+	return cached.balance, time.Since(cached.updatedAt), nil
+}
+
+func (client *client) refreshBalance(address string) {
+	// The refresh outlives the call that triggered it, so it cannot use the
+	// caller's context.
+	ctx, cancel := context.WithTimeout(context.Background(), balanceRefreshTimeout)
+	defer cancel()
+	balance, err := client.Balance(ctx, address, 0)
+
+	cache := client.balances
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cached := cache.balances[address]
+	cached.refreshing = false
+	if err != nil {
+		return
+	}
+	cached.balance = balance
+	cached.updatedAt = time.Now()
+}
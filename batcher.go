@@ -0,0 +1,320 @@
+package libbtc
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// PayoutStatus is the state of a payout queued in a Batcher.
+type PayoutStatus uint8
+
+// PayoutStatus values.
+const (
+	PayoutQueued = PayoutStatus(iota)
+	PayoutSent
+	// PayoutUnknown is the state of payouts whose batch was signed but could
+	// not be published, and may or may not have reached the backend. The same
+	// transaction is published again with the next batch, and the payouts
+	// are only queued again once it conflicts with another transaction, so
+	// that they are never paid twice.
+	PayoutUnknown
+)
+
+// Payout is a payment queued in a Batcher, along with its state.
+type Payout struct {
+	ID      uint64
+	Address string
+	Value   int64
+	Status  PayoutStatus
+
+	// TxHash is the hash of the transaction including the payout, once it
+	// is signed.
+	TxHash string
+	// Err is the error of the last attempt to send the payout, which is
+	// attempted again with the next batch.
+	Err error
+}
+
+// Batcher queues payouts and pays them from an account in batches, with a
+// single transaction with one output per payout, which costs a lot less fees
+// than a transaction per payout.
+type Batcher interface {
+	// Add queues a payout of the value to the address, and returns its ID.
+	Add(address string, value int64) (uint64, error)
+
+	// Payout returns the state of the payout with the given ID. Sent payouts
+	// are forgotten once their transaction is confirmed.
+	Payout(id uint64) (Payout, bool)
+
+	// Remove forgets the payout with the given ID, which is never sent if it
+	// is still queued, unless a batch including it is being sent.
+	Remove(id uint64)
+
+	// Flush settles the batches sent so far, sends the queued payouts now,
+	// and returns the hash of the transaction. It returns an empty hash if
+	// no payout is queued.
+	Flush(ctx context.Context) (string, error)
+}
+
+type batcher struct {
+	account Account
+	speed   TxExecutionSpeed
+	maxSize int
+	full    chan struct{}
+
+	// sendMu is held while a batch is sent, so that batches never include
+	// the same payouts.
+	sendMu *sync.Mutex
+
+	mu      *sync.Mutex
+	nextID  uint64
+	payouts map[uint64]*Payout
+	// txs are the hex encoded signed transactions of the batches, by hash,
+	// until their payouts are confirmed or queued again.
+	txs map[string]string
+}
+
+// NewBatcher returns a Batcher paying the queued payouts from the account at
+// the given speed, every interval or as soon as maxSize payouts are queued,
+// until the context is done. A maxSize of zero only sends batches on the
+// interval.
+func NewBatcher(ctx context.Context, account Account, interval time.Duration, maxSize int, speed TxExecutionSpeed) Batcher {
+	batcher := &batcher{
+		account: account,
+		speed:   speed,
+		maxSize: maxSize,
+		full:    make(chan struct{}, 1),
+		sendMu:  new(sync.Mutex),
+		mu:      new(sync.Mutex),
+		payouts: map[uint64]*Payout{},
+		txs:     map[string]string{},
+	}
+	go batcher.run(ctx, interval)
+	return batcher
+}
+
+func (batcher *batcher) Add(address string, value int64) (uint64, error) {
+	// Invalid payouts are rejected now, rather than failing their batch.
+	if _, err := NewTxTemplate(TemplateOutput{address, value}).TxOuts(batcher.account.NetworkParams()); err != nil {
+		return 0, err
+	}
+
+	batcher.mu.Lock()
+	defer batcher.mu.Unlock()
+	batcher.nextID++
+	batcher.payouts[batcher.nextID] = &Payout{
+		ID:      batcher.nextID,
+		Address: address,
+		Value:   value,
+		Status:  PayoutQueued,
+	}
+	if batcher.maxSize > 0 && len(batcher.queued()) >= batcher.maxSize {
+		select {
+		case batcher.full <- struct{}{}:
+		default:
+		}
+	}
+	return batcher.nextID, nil
+}
+
+func (batcher *batcher) Payout(id uint64) (Payout, bool) {
+	batcher.mu.Lock()
+	defer batcher.mu.Unlock()
+	payout, ok := batcher.payouts[id]
+	if !ok {
+		return Payout{}, false
+	}
+	return *payout, true
+}
+
+func (batcher *batcher) Remove(id uint64) {
+	batcher.mu.Lock()
+	defer batcher.mu.Unlock()
+	delete(batcher.payouts, id)
+}
+
+func (batcher *batcher) Flush(ctx context.Context) (string, error) {
+	batcher.sendMu.Lock()
+	defer batcher.sendMu.Unlock()
+
+	batcher.settle(ctx)
+
+	batcher.mu.Lock()
+	batch := batcher.queued()
+	outputs := make([]TemplateOutput, len(batch))
+	for i, payout := range batch {
+		outputs[i] = TemplateOutput{payout.Address, payout.Value}
+	}
+	batcher.mu.Unlock()
+	if len(batch) == 0 {
+		return "", nil
+	}
+
+	txHash, stx, err := batcher.account.BuildTransferTemplate(ctx, NewTxTemplate(outputs...), batcher.speed)
+	if err != nil {
+		batcher.mu.Lock()
+		for _, payout := range batch {
+			payout.Err = err
+		}
+		batcher.mu.Unlock()
+		return "", fmt.Errorf("cannot build batch of %d payouts: %v", len(batch), err)
+	}
+
+	// Once signed, the payouts are bound to the transaction until it is known
+	// to be published or to conflict with another transaction.
+	batcher.mu.Lock()
+	batcher.txs[txHash] = hex.EncodeToString(stx)
+	for _, payout := range batch {
+		payout.Status = PayoutUnknown
+		payout.TxHash = txHash
+		payout.Err = nil
+	}
+	batcher.mu.Unlock()
+	if err := batcher.publish(ctx, txHash); err != nil {
+		return "", fmt.Errorf("cannot send batch of %d payouts: %v", len(batch), err)
+	}
+	return txHash, nil
+}
+
+// settle updates the payouts of the batches signed so far with the state of
+// their transactions. Payouts are forgotten once their transaction is
+// confirmed, and queued again if it conflicts with another transaction.
+// Transactions unknown to the backend are published again.
+func (batcher *batcher) settle(ctx context.Context) {
+	batcher.mu.Lock()
+	txHashes := make([]string, 0, len(batcher.txs))
+	for txHash := range batcher.txs {
+		txHashes = append(txHashes, txHash)
+	}
+	batcher.mu.Unlock()
+
+	for _, txHash := range txHashes {
+		status, err := batcher.account.TxStatus(ctx, txHash)
+		if err != nil {
+			batcher.setErr(txHash, err)
+			continue
+		}
+		switch status.State {
+		case TxConfirmed:
+			batcher.prune(txHash)
+		case TxMempool:
+			batcher.setStatus(txHash, PayoutSent)
+		case TxConflicted:
+			batcher.setStatus(txHash, PayoutQueued)
+		default:
+			batcher.publish(ctx, txHash)
+		}
+	}
+}
+
+// publish publishes the transaction of a batch. When publishing fails, the
+// transaction may still have reached the backend, so its payouts are only
+// queued again if it cannot be confirmed because its inputs are spent.
+func (batcher *batcher) publish(ctx context.Context, txHash string) error {
+	batcher.mu.Lock()
+	txHex := batcher.txs[txHash]
+	batcher.mu.Unlock()
+
+	err := batcher.account.PublishRawTransaction(ctx, txHex)
+	if err == nil || errors.Is(err, errors.ErrTxAlreadyInMempool) {
+		batcher.setStatus(txHash, PayoutSent)
+		return nil
+	}
+	batcher.setErr(txHash, err)
+	status, statusErr := batcher.account.TxStatus(ctx, txHash)
+	switch {
+	case statusErr != nil:
+		batcher.setStatus(txHash, PayoutUnknown)
+	case status.State == TxMempool || status.State == TxConfirmed:
+		batcher.setStatus(txHash, PayoutSent)
+		return nil
+	case status.State == TxConflicted || errors.Is(err, errors.ErrMempoolConflict):
+		batcher.setStatus(txHash, PayoutQueued)
+	default:
+		batcher.setStatus(txHash, PayoutUnknown)
+	}
+	return err
+}
+
+// setStatus sets the status of the payouts of the transaction. Payouts queued
+// again are detached from the transaction, which is forgotten.
+func (batcher *batcher) setStatus(txHash string, status PayoutStatus) {
+	batcher.mu.Lock()
+	defer batcher.mu.Unlock()
+	for _, payout := range batcher.payouts {
+		if payout.TxHash != txHash {
+			continue
+		}
+		payout.Status = status
+		if status == PayoutSent {
+			payout.Err = nil
+		}
+		if status == PayoutQueued {
+			payout.TxHash = ""
+		}
+	}
+	if status == PayoutQueued {
+		delete(batcher.txs, txHash)
+	}
+}
+
+// setErr records the error of the last attempt to settle the payouts of the
+// transaction.
+func (batcher *batcher) setErr(txHash string, err error) {
+	batcher.mu.Lock()
+	defer batcher.mu.Unlock()
+	for _, payout := range batcher.payouts {
+		if payout.TxHash == txHash {
+			payout.Err = err
+		}
+	}
+}
+
+// prune forgets the transaction and its payouts, once it is confirmed.
+func (batcher *batcher) prune(txHash string) {
+	batcher.mu.Lock()
+	defer batcher.mu.Unlock()
+	for id, payout := range batcher.payouts {
+		if payout.TxHash == txHash {
+			delete(batcher.payouts, id)
+		}
+	}
+	delete(batcher.txs, txHash)
+}
+
+// queued returns the queued payouts, in the order they were added. It must be
+// called with the lock held.
+func (batcher *batcher) queued() []*Payout {
+	queued := []*Payout{}
+	for _, payout := range batcher.payouts {
+		if payout.Status == PayoutQueued {
+			queued = append(queued, payout)
+		}
+	}
+	sort.Slice(queued, func(i, j int) bool {
+		return queued[i].ID < queued[j].ID
+	})
+	return queued
+}
+
+func (batcher *batcher) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-batcher.full:
+		}
+		// Failed batches are recorded in their payouts, and settled with the
+		// next batch.
+		batcher.Flush(ctx)
+	}
+}
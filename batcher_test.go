@@ -0,0 +1,189 @@
+package libbtc_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/clients/mock"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// flakyChain is a mock chain whose publications fail while failing is set,
+// after the transaction reached the chain if accept is set too, like a
+// request timing out after the backend received it.
+type flakyChain struct {
+	*mock.Chain
+
+	mu      sync.Mutex
+	failing bool
+	accept  bool
+}
+
+func (chain *flakyChain) PublishTransaction(ctx context.Context, stx *wire.MsgTx) error {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	if !chain.failing {
+		return chain.Chain.PublishTransaction(ctx, stx)
+	}
+	if chain.accept {
+		if err := chain.Chain.PublishTransaction(ctx, stx); err != nil {
+			return err
+		}
+	}
+	return errors.NewErrBackendUnavailable("mock", fmt.Errorf("request timed out"))
+}
+
+func (chain *flakyChain) fail(failing, accept bool) {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	chain.failing, chain.accept = failing, accept
+}
+
+var _ = Describe("Batcher", func() {
+	// newBatcher returns a batcher only sending batches when flushed, from a
+	// funded account, along with two addresses to pay.
+	newBatcher := func(ctx context.Context, chain *flakyChain) (Batcher, Account, []string) {
+		account := newAccount(chain)
+		address, err := account.Address()
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = chain.Fund(address.EncodeAddress(), 10000000)
+		Expect(err).ShouldNot(HaveOccurred())
+		chain.Mine(1)
+
+		to := make([]string, 2)
+		for i := range to {
+			key, err := btcec.NewPrivateKey(btcec.S256())
+			Expect(err).ShouldNot(HaveOccurred())
+			address, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(key.PubKey().SerializeCompressed()), account.NetworkParams())
+			Expect(err).ShouldNot(HaveOccurred())
+			to[i] = address.EncodeAddress()
+		}
+		return NewBatcher(ctx, account, time.Hour, 0, Fast), account, to
+	}
+
+	expectStatus := func(batcher Batcher, ids []uint64, status PayoutStatus) []Payout {
+		payouts := make([]Payout, len(ids))
+		for i, id := range ids {
+			payout, ok := batcher.Payout(id)
+			Expect(ok).Should(BeTrue())
+			Expect(payout.Status).Should(Equal(status))
+			payouts[i] = payout
+		}
+		return payouts
+	}
+
+	addPayouts := func(batcher Batcher, to []string) []uint64 {
+		ids := make([]uint64, len(to))
+		for i, address := range to {
+			id, err := batcher.Add(address, 100000)
+			Expect(err).ShouldNot(HaveOccurred())
+			ids[i] = id
+		}
+		return ids
+	}
+
+	It("should send queued payouts in a single transaction and forget them once confirmed", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		chain := &flakyChain{Chain: mock.NewChain(&chaincfg.RegressionNetParams)}
+		batcher, _, to := newBatcher(ctx, chain)
+		ids := addPayouts(batcher, to)
+
+		txHash, err := batcher.Flush(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(chain.Mempool()).Should(ConsistOf(txHash))
+		for _, payout := range expectStatus(batcher, ids, PayoutSent) {
+			Expect(payout.TxHash).Should(Equal(txHash))
+		}
+
+		chain.Mine(1)
+		txHash, err = batcher.Flush(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(txHash).Should(BeEmpty())
+		for _, id := range ids {
+			_, ok := batcher.Payout(id)
+			Expect(ok).Should(BeFalse())
+		}
+	})
+
+	It("should report batches the backend received as sent even if publishing fails", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		chain := &flakyChain{Chain: mock.NewChain(&chaincfg.RegressionNetParams)}
+		batcher, _, to := newBatcher(ctx, chain)
+		ids := addPayouts(batcher, to)
+
+		chain.fail(true, true)
+		txHash, err := batcher.Flush(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		expectStatus(batcher, ids, PayoutSent)
+
+		txHash2, err := batcher.Flush(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(txHash2).Should(BeEmpty())
+		Expect(chain.Published()).Should(HaveLen(1))
+		Expect(chain.Mempool()).Should(ConsistOf(txHash))
+	})
+
+	It("should publish the same transaction again when publishing failed", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		chain := &flakyChain{Chain: mock.NewChain(&chaincfg.RegressionNetParams)}
+		batcher, _, to := newBatcher(ctx, chain)
+		ids := addPayouts(batcher, to)
+
+		chain.fail(true, false)
+		_, err := batcher.Flush(ctx)
+		Expect(err).Should(HaveOccurred())
+		payouts := expectStatus(batcher, ids, PayoutUnknown)
+		Expect(payouts[0].TxHash).ShouldNot(BeEmpty())
+		Expect(payouts[0].Err).Should(HaveOccurred())
+		Expect(chain.Mempool()).Should(BeEmpty())
+
+		chain.fail(false, false)
+		txHash, err := batcher.Flush(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(txHash).Should(BeEmpty())
+		expectStatus(batcher, ids, PayoutSent)
+		Expect(chain.Mempool()).Should(ConsistOf(payouts[0].TxHash))
+	})
+
+	It("should queue payouts again once their transaction conflicts with another one", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		chain := &flakyChain{Chain: mock.NewChain(&chaincfg.RegressionNetParams)}
+		batcher, account, to := newBatcher(ctx, chain)
+		ids := addPayouts(batcher, to)
+
+		chain.fail(true, false)
+		_, err := batcher.Flush(ctx)
+		Expect(err).Should(HaveOccurred())
+		unknown := expectStatus(batcher, ids, PayoutUnknown)
+		chain.fail(false, false)
+
+		// The account spends the inputs of the batch before it is published
+		// again, so the batch can never be confirmed.
+		address, err := account.Address()
+		Expect(err).ShouldNot(HaveOccurred())
+		_, _, err = account.Transfer(ctx, address.EncodeAddress(), 0, Fast, true)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		txHash, err := batcher.Flush(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(txHash).ShouldNot(BeEmpty())
+		Expect(txHash).ShouldNot(Equal(unknown[0].TxHash))
+		for _, payout := range expectStatus(batcher, ids, PayoutSent) {
+			Expect(payout.TxHash).Should(Equal(txHash))
+		}
+	})
+})
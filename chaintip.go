@@ -0,0 +1,142 @@
+package libbtc
+
+import (
+	"context"
+	"time"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/renproject/libbtc-go/clients"
+)
+
+func (client *client) SubscribeChainTip(ctx context.Context, interval time.Duration) (<-chan clients.ChainTip, error) {
+	tip, err := client.ChainTip(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tips := make(chan clients.ChainTip, 1)
+	tips <- tip
+	go func() {
+		defer close(tips)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				latest, err := client.ChainTip(ctx)
+				if err != nil || latest.Hash == tip.Hash {
+					continue
+				}
+				tip = latest
+				select {
+				case <-ctx.Done():
+					return
+				case tips <- tip:
+				}
+			}
+		}
+	}()
+	return tips, nil
+}
+
+// BlockPollInterval is the interval at which the chain tip is polled by
+// SubscribeBlocks, when the backend is not notified of new blocks.
+const BlockPollInterval = 15 * time.Second
+
+func (client *client) SubscribeBlocks(ctx context.Context) (<-chan clients.BlockEvent, error) {
+	tip, err := client.ChainTip(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var notifications <-chan clients.BlockEvent
+	if subscriber, ok := client.ClientCore.(clients.BlockSubscriber); ok {
+		if notifications, err = subscriber.SubscribeBlocks(ctx); err != nil {
+			notifications = nil
+		}
+	}
+
+	events := make(chan clients.BlockEvent, 1)
+	events <- client.blockEvent(ctx, tip)
+	go func() {
+		defer close(events)
+
+		// The chain tip is polled until the backend notifies of blocks, or
+		// once its subscription fails.
+		var ticker *time.Ticker
+		var ticks <-chan time.Time
+		poll := func() {
+			ticker = time.NewTicker(BlockPollInterval)
+			ticks = ticker.C
+		}
+		defer func() {
+			if ticker != nil {
+				ticker.Stop()
+			}
+		}()
+		if notifications == nil {
+			poll()
+		}
+
+		hash := tip.Hash
+		for {
+			var event clients.BlockEvent
+			select {
+			case <-ctx.Done():
+				return
+			case notification, ok := <-notifications:
+				if !ok {
+					notifications = nil
+					poll()
+					continue
+				}
+				event = notification
+			case <-ticks:
+				latest, err := client.ChainTip(ctx)
+				if err != nil || latest.Hash == hash {
+					continue
+				}
+				event = client.blockEvent(ctx, latest)
+			}
+			if event.Hash == hash {
+				continue
+			}
+			hash = event.Hash
+			select {
+			case <-ctx.Done():
+				return
+			case events <- event:
+			}
+		}
+	}()
+	return events, nil
+}
+
+// blockEvent returns the event of the chain tip. The hash of its parent is
+// only known when the backend returns the hash of a block at a height.
+func (client *client) blockEvent(ctx context.Context, tip clients.ChainTip) clients.BlockEvent {
+	event := clients.BlockEvent{
+		Height: tip.Height,
+		Hash:   tip.Hash,
+		Time:   tip.Time,
+	}
+	if tip.Height > 0 {
+		if prevHash, err := client.BlockHash(ctx, tip.Height-1); err == nil {
+			event.PrevHash = prevHash
+		}
+	}
+	return event
+}
+
+// IsLockTimeMature returns whether a transaction with the given nLockTime can
+// be included in the block following the chain tip. Height based lock times
+// are compared to the height of the next block, and time based lock times to
+// the median time past of the tip as required by BIP113.
+func IsLockTimeMature(tip clients.ChainTip, lockTime uint32) bool {
+	if lockTime < txscript.LockTimeThreshold {
+		return int64(lockTime) <= tip.Height
+	}
+	return int64(lockTime) < tip.MedianTime
+}
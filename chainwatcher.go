@@ -0,0 +1,249 @@
+package libbtc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// ReorgEvent is emitted by a ChainWatcher when previously seen blocks are
+// orphaned.
+type ReorgEvent struct {
+	// ForkHeight is the height of the last block shared by the old and the
+	// new chain.
+	ForkHeight int64
+	// Depth is the number of blocks of the old chain that were orphaned.
+	Depth int64
+	// OrphanedHashes are the hashes of the orphaned blocks that the watcher
+	// had seen.
+	OrphanedHashes []string
+	// AffectedTxHashes are the tracked transactions that were mined in an
+	// orphaned block.
+	AffectedTxHashes []string
+}
+
+// ChainWatcher polls the chain tip and emits an event whenever a block it has
+// seen is orphaned, along with the tracked transactions it affects.
+type ChainWatcher interface {
+	// Track starts watching whether the transaction is affected by reorgs.
+	Track(txHash string)
+
+	// Untrack stops watching the transaction.
+	Untrack(txHash string)
+
+	// Reorgs returns the channel of reorg events. It is closed once the
+	// context of the watcher is done.
+	Reorgs() <-chan ReorgEvent
+}
+
+type chainWatcher struct {
+	client Client
+	window int64
+	reorgs chan ReorgEvent
+
+	mu      *sync.Mutex
+	tracked map[string]int64
+
+	hashes    map[int64]string
+	tipHeight int64
+}
+
+// NewChainWatcher returns a ChainWatcher polling the client at the given
+// interval, remembering the blocks of the last window heights. Reorgs are
+// detected precisely when the client can return the hash of a block at a
+// height, otherwise they are only detected when a tip at an already seen height
+// changes or the confirmations of a tracked transaction go backwards.
+func NewChainWatcher(ctx context.Context, client Client, interval time.Duration, window int64) ChainWatcher {
+	watcher := &chainWatcher{
+		client:  client,
+		window:  window,
+		reorgs:  make(chan ReorgEvent, 16),
+		mu:      new(sync.Mutex),
+		tracked: map[string]int64{},
+		hashes:  map[int64]string{},
+	}
+	go watcher.run(ctx, interval)
+	return watcher
+}
+
+func (watcher *chainWatcher) Track(txHash string) {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	if _, ok := watcher.tracked[txHash]; !ok {
+		watcher.tracked[txHash] = 0
+	}
+}
+
+func (watcher *chainWatcher) Untrack(txHash string) {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	delete(watcher.tracked, txHash)
+}
+
+func (watcher *chainWatcher) Reorgs() <-chan ReorgEvent {
+	return watcher.reorgs
+}
+
+func (watcher *chainWatcher) run(ctx context.Context, interval time.Duration) {
+	defer close(watcher.reorgs)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// New blocks are checked as soon as they are seen, on top of the regular
+	// polling.
+	blocks, err := watcher.client.SubscribeBlocks(ctx)
+	if err != nil {
+		blocks = nil
+	}
+
+	for {
+		if event, ok := watcher.poll(ctx); ok {
+			select {
+			case <-ctx.Done():
+				return
+			case watcher.reorgs <- event:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case _, ok := <-blocks:
+			if !ok {
+				blocks = nil
+			}
+		}
+	}
+}
+
+// poll fetches the chain tip and the confirmations of the tracked
+// transactions, and returns a reorg event if seen blocks were orphaned. It is
+// the only writer of the seen blocks, so they are read without the lock while
+// querying the client.
+func (watcher *chainWatcher) poll(ctx context.Context) (ReorgEvent, bool) {
+	tip, err := watcher.client.ChainTip(ctx)
+	if err != nil {
+		return ReorgEvent{}, false
+	}
+	included := watcher.inclusionHeights(ctx, tip.Height)
+
+	// The fork height is lowered by every sign of a reorg: a changed block
+	// hash, or a tracked transaction that moved out of its block.
+	forkHeight := tip.Height
+	if watcher.tipHeight < forkHeight {
+		forkHeight = watcher.tipHeight
+	}
+	reorged := false
+	if hash, ok := watcher.hashes[forkHeight]; ok && !watcher.sameBlock(ctx, forkHeight, hash, tip) {
+		reorged = true
+		forkHeight--
+		for height := forkHeight; height > tip.Height-watcher.window; height-- {
+			hash, ok := watcher.hashes[height]
+			if !ok || watcher.sameBlock(ctx, height, hash, tip) {
+				break
+			}
+			forkHeight--
+		}
+	}
+
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	for txHash, height := range watcher.tracked {
+		if newHeight, ok := included[txHash]; ok && height > 0 && newHeight != height {
+			reorged = true
+			if height-1 < forkHeight {
+				forkHeight = height - 1
+			}
+		}
+	}
+
+	event := ReorgEvent{
+		ForkHeight:       forkHeight,
+		Depth:            watcher.tipHeight - forkHeight,
+		OrphanedHashes:   []string{},
+		AffectedTxHashes: []string{},
+	}
+	if reorged {
+		for height := forkHeight + 1; height <= watcher.tipHeight; height++ {
+			if hash, ok := watcher.hashes[height]; ok {
+				event.OrphanedHashes = append(event.OrphanedHashes, hash)
+			}
+			delete(watcher.hashes, height)
+		}
+		for txHash, height := range watcher.tracked {
+			if height > forkHeight {
+				event.AffectedTxHashes = append(event.AffectedTxHashes, txHash)
+			}
+		}
+	}
+
+	for txHash, height := range included {
+		if _, ok := watcher.tracked[txHash]; ok {
+			watcher.tracked[txHash] = height
+		}
+	}
+	watcher.hashes[tip.Height] = tip.Hash
+	watcher.tipHeight = tip.Height
+	for height := range watcher.hashes {
+		if height <= tip.Height-watcher.window {
+			delete(watcher.hashes, height)
+		}
+	}
+	return event, reorged
+}
+
+// sameBlock returns whether the block seen at the given height is still part
+// of the chain.
+func (watcher *chainWatcher) sameBlock(ctx context.Context, height int64, hash string, tip clients.ChainTip) bool {
+	if height == tip.Height {
+		return hash == tip.Hash
+	}
+	current, err := watcher.client.BlockHash(ctx, height)
+	if err != nil {
+		// Without the hash of the block, the block is assumed to be part of
+		// the chain.
+		return true
+	}
+	return current == hash
+}
+
+// inclusionHeights returns the height of the block including each tracked
+// transaction, or 0 if it is unconfirmed. Transactions whose confirmations
+// cannot be fetched are omitted.
+func (watcher *chainWatcher) inclusionHeights(ctx context.Context, tipHeight int64) map[string]int64 {
+	watcher.mu.Lock()
+	txHashes := make([]string, 0, len(watcher.tracked))
+	for txHash := range watcher.tracked {
+		txHashes = append(txHashes, txHash)
+	}
+	watcher.mu.Unlock()
+
+	included := map[string]int64{}
+	for _, txHash := range txHashes {
+		confCtx, cancel := context.WithTimeout(ctx, knownTxTimeout)
+		conf, err := watcher.client.Confirmations(confCtx, txHash)
+		cancel()
+		if err != nil {
+			continue
+		}
+		if conf > 0 {
+			included[txHash] = tipHeight - conf + 1
+		} else {
+			included[txHash] = 0
+		}
+	}
+	return included
+}
+
+func (client *client) BlockHash(ctx context.Context, height int64) (string, error) {
+	hasher, ok := client.ClientCore.(clients.BlockHasher)
+	if !ok {
+		return "", errors.NewErrUnsupportedOperation("BlockHash", "current")
+	}
+	return hasher.BlockHash(ctx, height)
+}
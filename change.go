@@ -0,0 +1,38 @@
+package libbtc
+
+import (
+	"context"
+
+	"github.com/btcsuite/btcutil"
+)
+
+// ChangeAddresser decides the address the change of a transaction is sent
+// to. Sending change back to the sender links the change to the sender, so
+// wallets deriving fresh addresses, like HD accounts, use a new address for
+// every transaction.
+type ChangeAddresser interface {
+	ChangeAddress(ctx context.Context) (btcutil.Address, error)
+}
+
+type staticChange struct {
+	address btcutil.Address
+}
+
+// StaticChangeAddresser returns a ChangeAddresser always sending change to the
+// address, which is what accounts do by default with their own address.
+func StaticChangeAddresser(address btcutil.Address) ChangeAddresser {
+	return staticChange{address}
+}
+
+func (change staticChange) ChangeAddress(ctx context.Context) (btcutil.Address, error) {
+	return change.address, nil
+}
+
+// changeAddress returns the change address of the addresser, or the default
+// address if the addresser is nil.
+func changeAddress(ctx context.Context, addresser ChangeAddresser, defaultAddress btcutil.Address) (btcutil.Address, error) {
+	if addresser == nil {
+		return defaultAddress, nil
+	}
+	return addresser.ChangeAddress(ctx)
+}
@@ -0,0 +1,147 @@
+package libbtc
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/clients"
+)
+
+// Channel is a 2-of-2 multisig P2WSH output funded by one of its parties, the
+// base of payment channels and escrows. Its funds are spent by transactions
+// both parties sign with Tx.InjectSigsFor, starting with a refund returning the
+// funds to the funder after a delay, which the other party signs before the
+// funding transaction is published so that the funds are never locked.
+type Channel struct {
+	// LocalPubKey is the serialized public key of the funder, and
+	// RemotePubKey the one of the other party.
+	LocalPubKey  []byte
+	RemotePubKey []byte
+
+	// RedeemScript is OP_2 <pubKey> <pubKey> OP_2 OP_CHECKMULTISIG, with the
+	// public keys in the BIP67 order.
+	RedeemScript []byte
+	Capacity     int64
+
+	// Funding pays the capacity to the P2WSH output of the redeem script, in
+	// its first output, from the UTXOs of the funder.
+	Funding Tx
+}
+
+// BuildChannelFunding builds the funding transaction of a channel of the given
+// capacity between the public key and the remote serialized public key, from
+// the UTXOs of the public key.
+func (builder *txBuilder) BuildChannelFunding(ctx context.Context, pubKey ecdsa.PublicKey, remotePubKey []byte, capacity int64, utxos []clients.UTXO, opts ...BuildOption) (*Channel, error) {
+	if _, err := btcec.ParsePubKey(remotePubKey, btcec.S256()); err != nil {
+		return nil, fmt.Errorf("invalid remote public key: %v", err)
+	}
+	if len(remotePubKey) != btcec.PubKeyBytesLenCompressed {
+		return nil, fmt.Errorf("invalid remote public key: segwit requires compressed public keys")
+	}
+	localPubKey := (*btcec.PublicKey)(&pubKey).SerializeCompressed()
+	if bytes.Equal(localPubKey, remotePubKey) {
+		return nil, fmt.Errorf("channel parties have the same public key")
+	}
+
+	pubKeys := [][]byte{localPubKey, remotePubKey}
+	if bytes.Compare(pubKeys[0], pubKeys[1]) > 0 {
+		pubKeys[0], pubKeys[1] = pubKeys[1], pubKeys[0]
+	}
+	redeemScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_2).
+		AddData(pubKeys[0]).
+		AddData(pubKeys[1]).
+		AddOp(txscript.OP_2).
+		AddOp(txscript.OP_CHECKMULTISIG).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+	fundingScript, err := p2wshScript(redeemScript, builder.client.NetworkParams())
+	if err != nil {
+		return nil, err
+	}
+
+	funding, err := builder.BuildOutputs(ctx, pubKey, []wire.TxOut{*wire.NewTxOut(capacity, fundingScript)}, utxos, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Channel{
+		LocalPubKey:  localPubKey,
+		RemotePubKey: remotePubKey,
+		RedeemScript: redeemScript,
+		Capacity:     capacity,
+		Funding:      funding,
+	}, nil
+}
+
+// BuildChannelRefund builds the transaction spending the funding output of the
+// channel back to the address of the funder, which can only be mined delay
+// blocks after the funding transaction. Unless the inputs of the funding
+// transaction are segwit, its hash changes when it is signed, so the refund is
+// built once the funder has signed it. Both parties then sign the refund, and
+// the funding transaction is only published once they have.
+//
+// The delay is a property of the refund rather than of the channel: it is set
+// in the sequence number of its input, which BIP68 enforces for transactions
+// of version 2, and not in the redeem script. The parties can always spend
+// the funding output together with another transaction, without any delay,
+// which is how the channel is closed cooperatively.
+func (builder *txBuilder) BuildChannelRefund(ctx context.Context, channel *Channel, delay uint16, opts ...BuildOption) (Tx, error) {
+	if builder.version < 2 {
+		return nil, fmt.Errorf("channel refunds require transactions of version 2 to enforce their delay, got version %d", builder.version)
+	}
+	pubKey, err := btcec.ParsePubKey(channel.LocalPubKey, btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+	to, err := builder.client.PublicKeyToAddress(channel.LocalPubKey)
+	if err != nil {
+		return nil, err
+	}
+	utxo, err := channel.FundingUTXO(builder.client.NetworkParams())
+	if err != nil {
+		return nil, err
+	}
+	// The lock time is enforced through the sequence number of the input, as
+	// defined in BIP68, which the signature of the other party commits to.
+	opts = append([]BuildOption{WithSequence(0, RelativeLockTimeBlocks(delay))}, opts...)
+	return builder.BuildContracts(ctx, *pubKey.ToECDSA(), to.EncodeAddress(), channel.Capacity, nil, []ContractSpend{{
+		Contract: channel.RedeemScript,
+		UTXOs:    []clients.UTXO{utxo},
+	}}, opts...)
+}
+
+// FundingUTXO returns the funding output of the channel, to be spent by the
+// transactions of the channel with TxBuilder.BuildContracts.
+func (channel *Channel) FundingUTXO(params *chaincfg.Params) (clients.UTXO, error) {
+	fundingScript, err := p2wshScript(channel.RedeemScript, params)
+	if err != nil {
+		return clients.UTXO{}, err
+	}
+	return clients.UTXO{
+		TxHash:       channel.Funding.TxHash().String(),
+		Amount:       channel.Capacity,
+		ScriptPubKey: hex.EncodeToString(fundingScript),
+		Vout:         0,
+	}, nil
+}
+
+// p2wshScript returns the P2WSH script paying to the witness script.
+func p2wshScript(witnessScript []byte, params *chaincfg.Params) ([]byte, error) {
+	scriptHash := sha256.Sum256(witnessScript)
+	address, err := btcutil.NewAddressWitnessScriptHash(scriptHash[:], params)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.PayToAddrScript(address)
+}
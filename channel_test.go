@@ -0,0 +1,86 @@
+package libbtc_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/renproject/libbtc-go/clients/mock"
+)
+
+var _ = Describe("Channel", func() {
+	const capacity = 100000
+
+	// sign returns the signatures of the hashes by the key.
+	sign := func(key *btcec.PrivateKey, hashes [][]byte) []*btcec.Signature {
+		sigs := make([]*btcec.Signature, len(hashes))
+		for i, hash := range hashes {
+			sig, err := key.Sign(hash)
+			Expect(err).ShouldNot(HaveOccurred())
+			sigs[i] = sig
+		}
+		return sigs
+	}
+
+	// openChannel returns a channel funded by a new key, with its funding
+	// transaction signed and the refund after delay blocks signed by both
+	// parties.
+	openChannel := func(chain *mock.Chain, builder TxBuilder, delay uint16) (*Channel, Tx) {
+		client := NewClientFromCore(chain)
+		localKey, err := btcec.NewPrivateKey(btcec.S256())
+		Expect(err).ShouldNot(HaveOccurred())
+		remoteKey, err := btcec.NewPrivateKey(btcec.S256())
+		Expect(err).ShouldNot(HaveOccurred())
+		address, err := client.PublicKeyToAddress(localKey.PubKey().SerializeCompressed())
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = chain.Fund(address.EncodeAddress(), 2*capacity)
+		Expect(err).ShouldNot(HaveOccurred())
+		chain.Mine(1)
+		utxos, err := client.GetUTXOs(context.Background(), address.EncodeAddress(), 999999, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		channel, err := builder.BuildChannelFunding(context.Background(), *localKey.PubKey().ToECDSA(), remoteKey.PubKey().SerializeCompressed(), capacity, utxos)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(channel.Funding.InjectSigs(sign(localKey, channel.Funding.Hashes()))).Should(Succeed())
+
+		refund, err := builder.BuildChannelRefund(context.Background(), channel, delay)
+		Expect(err).ShouldNot(HaveOccurred())
+		hash := refund.Hashes()[0]
+		Expect(refund.InjectSigsFor(0, []InputSig{
+			{Signature: sign(localKey, [][]byte{hash})[0]},
+			{PubKey: channel.RemotePubKey, Signature: sign(remoteKey, [][]byte{hash})[0]},
+		})).Should(Succeed())
+		return channel, refund
+	}
+
+	It("should only confirm the refund delay blocks after the funding", func() {
+		const delay = 3
+		chain := mock.NewChain(&chaincfg.RegressionNetParams)
+		channel, refund := openChannel(chain, NewTxBuilder(NewClientFromCore(chain)), delay)
+		_, err := channel.Funding.Submit(context.Background())
+		Expect(err).ShouldNot(HaveOccurred())
+		chain.Mine(1)
+
+		for i := 1; i < delay; i++ {
+			_, err := refund.Submit(context.Background())
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(ContainSubstring("non-BIP68-final"))
+			chain.Mine(1)
+		}
+		_, err = refund.Submit(context.Background())
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(chain.Mempool()).Should(ConsistOf(refund.TxHash().String()))
+	})
+
+	It("should reject refunds of version 1, which do not enforce the delay", func() {
+		chain := mock.NewChain(&chaincfg.RegressionNetParams)
+		client := NewClientFromCore(chain)
+		channel, _ := openChannel(chain, NewTxBuilder(client), 1)
+		_, err := NewTxBuilder(client, WithVersion(1)).BuildChannelRefund(context.Background(), channel, 1)
+		Expect(err).Should(HaveOccurred())
+	})
+})
@@ -3,20 +3,118 @@ package libbtc
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/renproject/libbtc-go/clients"
-	"github.com/renproject/libbtc-go/errors"
 )
 
 type Client interface {
 	clients.ClientCore
 
+	// ScriptRedemption returns the transactions that redeemed a script along
+	// with the redeemed amount and their confirmations.
+	ScriptRedemption(ctx context.Context, address string, value int64) (clients.Redemption, error)
+
+	// ScriptSpendDetails returns the input spending from a script, and false
+	// if the script is unspent.
+	ScriptSpendDetails(ctx context.Context, script, spender string) (clients.ScriptSpend, bool, error)
+
+	// ChainTip returns the latest block of the chain along with its median
+	// time past.
+	ChainTip(ctx context.Context) (clients.ChainTip, error)
+
+	// RawTransaction returns the transaction with the given hash.
+	RawTransaction(ctx context.Context, txHash string) (*wire.MsgTx, error)
+
+	// GetBlockHeader returns the header of the block with the given hash, or
+	// at the given height of the active chain.
+	GetBlockHeader(ctx context.Context, hashOrHeight string) (*wire.BlockHeader, error)
+
+	// GetBlock returns the block with the given hash, or at the given height
+	// of the active chain.
+	GetBlock(ctx context.Context, hashOrHeight string) (*wire.MsgBlock, error)
+
 	// Balance of the given address on Bitcoin blockchain.
 	Balance(ctx context.Context, address string, confirmations int64) (int64, error)
 
+	// BalanceMulti returns the balance of every given address, fetching their
+	// outputs in a single round trip when the backend supports it.
+	BalanceMulti(ctx context.Context, addresses []string, confirmations int64) (map[string]int64, error)
+
+	// GetUTXOsMulti returns the outputs of every given address, in a single
+	// round trip when the backend supports it. Otherwise, they are fetched
+	// concurrently.
+	GetUTXOsMulti(ctx context.Context, addresses []string, confirmations int64) (map[string][]clients.UTXO, error)
+
+	// BalanceAmount returns the balance of the given address, like Balance.
+	BalanceAmount(ctx context.Context, address string, confirmations int64) (btcutil.Amount, error)
+
+	// ScriptFundedAmount checks whether a script is funded, like
+	// ScriptFunded.
+	ScriptFundedAmount(ctx context.Context, address string, value btcutil.Amount) (bool, btcutil.Amount, error)
+
+	// BalanceCached returns the last known balance of the given address along
+	// with its age, and refreshes it in the background. Only the first call
+	// for an address waits for the balance to be fetched.
+	BalanceCached(ctx context.Context, address string) (int64, time.Duration, error)
+
+	// BlockHash returns the hash of the block at the given height of the
+	// active chain, if the backend supports it.
+	BlockHash(ctx context.Context, height int64) (string, error)
+
+	// ScriptStatuses returns a digest of the history of each of the
+	// addresses, which changes whenever a transaction funds or spends from
+	// it, if the backend supports it.
+	ScriptStatuses(ctx context.Context, addresses []string) (map[string]string, error)
+
+	// ScanBlocks walks the blocks from fromHeight to toHeight, or to the chain
+	// tip if toHeight is zero, and returns the outputs paying to the scripts,
+	// including those of addresses no backend indexes. The progress is called
+	// after every block if it is not nil. The outputs found before an error
+	// are returned along with it, so a scan can be resumed.
+	ScanBlocks(ctx context.Context, fromHeight, toHeight int64, scripts [][]byte, progress ScanProgress) ([]ScannedOutput, error)
+
+	// ImportAddressRescan imports the address into the wallet of a node and
+	// rescans the chain for its transactions in the background, sending the
+	// progress of the rescan to the returned channel, if the backend supports
+	// it. The rescan is aborted once the context is done.
+	ImportAddressRescan(ctx context.Context, address string) (<-chan clients.RescanProgress, error)
+
+	// ImportDescriptors imports the output descriptors into the descriptor
+	// wallet of a node, which then tracks their outputs and transactions, if
+	// the backend supports it.
+	ImportDescriptors(ctx context.Context, descriptors []clients.WalletDescriptor) error
+
+	// DecodeRawTransaction decodes the serialized transaction, which may be
+	// hex encoded, and resolves the outputs spent by its inputs to compute its
+	// fee.
+	DecodeRawTransaction(ctx context.Context, rawTx []byte) (DecodedTx, error)
+
+	// GetTxMerkleProof returns the proof that the confirmed transaction is
+	// included in its block, which can be checked against the header of the
+	// block with MerkleProof.Verify.
+	GetTxMerkleProof(ctx context.Context, txHash string) (MerkleProof, error)
+
+	// GetTransaction returns the transaction with the given hash, along with
+	// the outputs spent by its inputs, its fee and its block height.
+	GetTransaction(ctx context.Context, txHash string) (Transaction, error)
+
+	// PublishRawTransaction publishes the hex serialized transaction, without
+	// the caller deserializing it first. Use a multi-backend client to publish
+	// it through several backends, see clients.BroadcastAll.
+	PublishRawTransaction(ctx context.Context, txHex string) error
+
+	// TxStatus returns whether the transaction is unknown, unconfirmed,
+	// confirmed or conflicting with another transaction, along with the fee
+	// it pays. Transactions are only reported unknown when the backend says
+	// so, errors looking them up are returned.
+	TxStatus(ctx context.Context, txHash string) (TxStatus, error)
+
 	// FormatTransactionView formats the message and txhash into a user friendly
 	// message.
 	FormatTransactionView(msg, txhash string) string
@@ -35,15 +133,144 @@ type Client interface {
 	// the private key correspndong to the given master public key hash
 	SlaveScript(mpkh, nonce []byte) ([]byte, error)
 
+	// SlaveAddressSegwit returns the P2WSH version of the slave address,
+	// whose outputs are spent with about a quarter of the fee.
+	SlaveAddressSegwit(mpkh, nonce []byte) (btcutil.Address, error)
+
+	// SlaveScriptSegwit returns the witness script of the P2WSH slave
+	// address, to be given to a TxBuilder as the contract spent.
+	SlaveScriptSegwit(mpkh, nonce []byte) ([]byte, error)
+
+	// SlaveAddressVersion returns the P2SH slave address in the format of the
+	// given version.
+	SlaveAddressVersion(mpkh, nonce []byte, version SlaveVersion) (btcutil.Address, error)
+
+	// SlaveScriptVersion returns the slave script in the format of the given
+	// version.
+	SlaveScriptVersion(mpkh, nonce []byte, version SlaveVersion) ([]byte, error)
+
+	// PropagationStatus reports which of the backends of the client know about
+	// the given transaction. Backends that do not know about it keep retrying
+	// until the context is done, so the context should have a deadline.
+	PropagationStatus(ctx context.Context, txHash string) (PropagationStatus, error)
+
+	// ScriptHistory returns the transactions funding and spending from the
+	// script address, oldest first, with unconfirmed transactions last. It
+	// returns an unsupported operation error if the backend cannot list them.
+	ScriptHistory(ctx context.Context, address string) ([]clients.ScriptTx, error)
+
+	// OutPointSpent returns whether the output is spent, including by an
+	// unconfirmed transaction, along with the hash of the spending
+	// transaction. The hash is empty when the backend can only tell that the
+	// output is spent.
+	OutPointSpent(ctx context.Context, txHash string, vout uint32) (bool, string, error)
+
+	// ResolveInputs fetches the outputs spent by every input of the given
+	// transaction.
+	ResolveInputs(ctx context.Context, msgTx *wire.MsgTx) (ResolvedInputs, error)
+
+	// GetUTXOsPaged sends the unspent outputs of the address one page of at
+	// most pageSize outputs at a time, so that addresses with more outputs
+	// than the backend returns at once can be enumerated. The channel is
+	// closed after the last page, or once the context is done.
+	GetUTXOsPaged(ctx context.Context, address string, pageSize int64) (<-chan UTXOPage, error)
+
+	// SubscribeAddress polls the unspent outputs of the address and sends an
+	// event whenever one is received or spent. The outputs that are unspent
+	// when subscribing are sent first. The channel is closed once the context
+	// is done.
+	SubscribeAddress(ctx context.Context, address string) (<-chan AddressEvent, error)
+
+	// SubscribeChainTip polls the chain tip at the given interval and sends it
+	// whenever it changes. The channel is closed once the context is done.
+	SubscribeChainTip(ctx context.Context, interval time.Duration) (<-chan clients.ChainTip, error)
+
+	// SubscribeBlocks sends an event for every new chain tip, starting with
+	// the current one. Blocks are subscribed to natively when the backend is
+	// notified of them, and the chain tip is polled every BlockPollInterval
+	// otherwise. The channel is closed once the context is done.
+	SubscribeBlocks(ctx context.Context) (<-chan clients.BlockEvent, error)
+
+	// WatchDoubleSpends polls the outputs spent by the given transaction at the
+	// given interval, and reports the inputs whose output is spent by a
+	// conflicting transaction. The channel is closed once the transaction is
+	// mined or the context is done.
+	WatchDoubleSpends(ctx context.Context, msgTx *wire.MsgTx, interval time.Duration) (<-chan DoubleSpend, error)
+
+	// WaitForConfirmations blocks until the transaction has at least n
+	// confirmations, polling with an increasing interval. It returns
+	// ErrTimedOut if the context is done first.
+	WaitForConfirmations(ctx context.Context, txHash string, n int64) error
+
+	// RelativeLockTimeReached returns whether an input with the given
+	// sequence number can spend an output of the given transaction in the next
+	// block, as checked by OP_CHECKSEQUENCEVERIFY.
+	RelativeLockTimeReached(ctx context.Context, txHash string, sequence uint32) (bool, error)
+
 	// UTXOCount returns the number of utxos that can be spent.
 	UTXOCount(ctx context.Context, address string, confirmations int64) (int, error)
 
-	// Validate returns whether an address is valid or not
+	// InspectAddress decodes the address and returns its type, network and
+	// public key script. Addresses of another network are returned along
+	// with a mismatched networks error.
+	InspectAddress(address string) (AddressInfo, error)
+
+	// Validate returns whether an address is a valid address of the network
+	// of the client
 	Validate(address string) error
+
+	// SetLogger sets the logger of the backend of the client, if it logs.
+	// Backends log nothing until a logger is set.
+	SetLogger(logger Logger)
+
+	// SetRetryPolicy sets how the backend of the client retries failed
+	// requests, if it retries them. Backends use clients.DefaultRetryPolicy
+	// until a policy is set.
+	SetRetryPolicy(policy clients.RetryPolicy)
+
+	// SetHTTPClient sets the http.Client used by the backend of the client,
+	// if it calls an HTTP API, to configure timeouts and proxies. Backends use
+	// a client timing out after clients.DefaultHTTPTimeout until one is set.
+	SetHTTPClient(httpClient *http.Client)
+
+	// TestMempoolAccept checks whether the mempool of the backend accepts the
+	// transaction, without publishing it, if the backend can test it.
+	// Rejections are returned as errors.SubmitTxError, whose reasons tell
+	// apart fees below the minimum relay fee (ErrInsufficientFee) and inputs
+	// already spent (ErrMempoolConflict).
+	TestMempoolAccept(ctx context.Context, stx *wire.MsgTx) error
+
+	// EstimateFeeRate returns the fee rate, in satoshis per byte, estimated by
+	// the backend for a transaction to be mined within the given number of
+	// blocks, if the backend estimates fees.
+	EstimateFeeRate(ctx context.Context, blocks int64) (int64, error)
+
+	// TxRate returns the fee rate, in satoshis per byte, of transactions sent
+	// at the given speed. Rates come from the fee source of the client, or
+	// from NetworkTxRate until one is set, falling back to DefaultTxRate.
+	TxRate(ctx context.Context, speed TxExecutionSpeed) (int64, error)
+
+	// SetFeeSource sets where the client gets the fee rates of transactions,
+	// for example one returned by NewFeeOracle.
+	SetFeeSource(source FeeSource)
+
+	// SetZMQAddress sets the address on which the node backing the client
+	// publishes raw blocks through ZMQ, if it is a full node, so that
+	// SubscribeBlocks does not poll.
+	SetZMQAddress(addr string)
 }
 
 type client struct {
 	clients.ClientCore
+	balances *balanceCache
+	fees     FeeSource
+}
+
+func newClient(core clients.ClientCore) *client {
+	return &client{
+		ClientCore: core,
+		balances:   newBalanceCache(),
+	}
 }
 
 func (client *client) Balance(ctx context.Context, address string, confirmations int64) (int64, error) {
@@ -58,6 +285,31 @@ func (client *client) Balance(ctx context.Context, address string, confirmations
 	return balance, nil
 }
 
+func (client *client) BalanceMulti(ctx context.Context, addresses []string, confirmations int64) (map[string]int64, error) {
+	utxos, err := client.GetUTXOsMulti(ctx, addresses, confirmations)
+	if err != nil {
+		return nil, err
+	}
+	balances := make(map[string]int64, len(utxos))
+	for address, addressUTXOs := range utxos {
+		var balance int64
+		for _, utxo := range addressUTXOs {
+			balance = balance + utxo.Amount
+		}
+		balances[address] = balance
+	}
+	return balances, nil
+}
+
+func (client *client) GetUTXOsMulti(ctx context.Context, addresses []string, confirmations int64) (map[string][]clients.UTXO, error) {
+	for _, address := range addresses {
+		if err := client.Validate(address); err != nil {
+			return nil, err
+		}
+	}
+	return clients.GetUTXOsMulti(ctx, client.ClientCore, addresses, confirmations)
+}
+
 func (client *client) UTXOCount(ctx context.Context, address string, confirmations int64) (int, error) {
 	utxos, err := client.GetUTXOs(ctx, address, 999999, confirmations)
 	if err != nil {
@@ -77,16 +329,14 @@ func (client *client) FormatTransactionView(msg, txhash string) string {
 	}
 }
 
+// SerializePublicKey serializes public keys uncompressed on testnet3, where
+// the existing accounts use uncompressed addresses, and compressed on every
+// other network, including custom ones.
 func (client *client) SerializePublicKey(pubKey *btcec.PublicKey) ([]byte, error) {
-	net := client.NetworkParams()
-	switch net {
-	case &chaincfg.MainNetParams:
-		return pubKey.SerializeCompressed(), nil
-	case &chaincfg.TestNet3Params:
+	if client.NetworkParams() == &chaincfg.TestNet3Params {
 		return pubKey.SerializeUncompressed(), nil
-	default:
-		return nil, errors.NewErrUnsupportedNetwork(net.Name)
 	}
+	return pubKey.SerializeCompressed(), nil
 }
 
 func (client *client) PublicKeyToAddress(pubKeyBytes []byte) (btcutil.Address, error) {
@@ -95,8 +345,21 @@ func (client *client) PublicKeyToAddress(pubKeyBytes []byte) (btcutil.Address, e
 	if err != nil {
 		return nil, err
 	}
-	addrString := pubKey.EncodeAddress()
-	return btcutil.DecodeAddress(addrString, net)
+	return pubKey.AddressPubKeyHash(), nil
+}
+
+// registerParams registers the parameters of a custom network, which btcutil
+// needs to decode the addresses of the network. Networks that are already
+// registered, including the default ones, are left as they are.
+func registerParams(params *chaincfg.Params) {
+	chaincfg.Register(params)
+}
+
+// NewClientFromCore returns a Client backed by the ClientCore, for example a
+// custom backend, or the in-memory chain of the mock package.
+func NewClientFromCore(core clients.ClientCore) Client {
+	registerParams(core.NetworkParams())
+	return newClient(core)
 }
 
 func NewBlockchainInfoClient(network string) (Client, error) {
@@ -104,7 +367,15 @@ func NewBlockchainInfoClient(network string) (Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &client{core}, nil
+	return newClient(core), nil
+}
+
+func NewBlockCypherClient(network, token string) (Client, error) {
+	core, err := clients.NewBlockCypherClientCore(network, token)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(core), nil
 }
 
 func NewBitcoinFNClient(host, user, password string) (Client, error) {
@@ -112,7 +383,111 @@ func NewBitcoinFNClient(host, user, password string) (Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &client{core}, nil
+	return newClient(core), nil
+}
+
+func NewBitcoinFNClientWithParams(host, user, password string, params *chaincfg.Params) (Client, error) {
+	registerParams(params)
+	core, err := clients.NewBitcoinFNClientCoreWithParams(host, user, password, params)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(core), nil
+}
+
+func NewBtcWalletClient(host, user, password string, cert []byte) (Client, error) {
+	core, err := clients.NewBtcWalletClientCore(host, user, password, cert)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(core), nil
+}
+
+func NewBtcWalletClientWithParams(host, user, password string, cert []byte, params *chaincfg.Params) (Client, error) {
+	registerParams(params)
+	core, err := clients.NewBtcWalletClientCoreWithParams(host, user, password, cert, params)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(core), nil
+}
+
+func NewElectrumClient(server string, useTLS bool) (Client, error) {
+	core, err := clients.NewElectrumClientCore(server, useTLS)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(core), nil
+}
+
+// NewElectrumClientWithProxy returns a Client of the Electrum server,
+// connected through the SOCKS5 proxy.
+func NewElectrumClientWithProxy(server string, useTLS bool, proxy string) (Client, error) {
+	core, err := clients.NewElectrumClientCoreWithProxy(server, useTLS, proxy)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(core), nil
+}
+
+func NewElectrumClientWithParams(server string, useTLS bool, params *chaincfg.Params) (Client, error) {
+	registerParams(params)
+	core, err := clients.NewElectrumClientCoreWithParams(server, useTLS, params)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(core), nil
+}
+
+func NewEsploraClient(network string) (Client, error) {
+	core, err := clients.NewEsploraClientCore(network)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(core), nil
+}
+
+func NewEsploraClientWithURL(url string, params *chaincfg.Params) Client {
+	registerParams(params)
+	return newClient(clients.NewEsploraClientCoreWithURL(url, params))
+}
+
+// NewEsploraClientWithProxy returns a Client of the Esplora API at the given
+// URL, sending its requests through the SOCKS5 proxy, so that .onion
+// instances are reachable through Tor.
+func NewEsploraClientWithProxy(url string, params *chaincfg.Params, proxy string) (Client, error) {
+	httpClient, err := clients.NewProxyHTTPClient(proxy, clients.DefaultHTTPTimeout)
+	if err != nil {
+		return nil, err
+	}
+	client := NewEsploraClientWithURL(url, params)
+	client.SetHTTPClient(httpClient)
+	return client, nil
+}
+
+func NewInsightClient(url string, params *chaincfg.Params) Client {
+	registerParams(params)
+	return newClient(clients.NewInsightClientCore(url, params))
+}
+
+func NewNeutrinoClient(peerAddress string, params *chaincfg.Params, birthday int64) (Client, error) {
+	registerParams(params)
+	core, err := clients.NewNeutrinoClientCore(peerAddress, params, birthday)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(core), nil
+}
+
+// NewNeutrinoClientWithProxy returns a Client of the peer, connected through
+// the SOCKS5 proxy.
+func NewNeutrinoClientWithProxy(peerAddress string, params *chaincfg.Params, birthday int64, proxy string) (Client, error) {
+	registerParams(params)
+	core, err := clients.NewNeutrinoClientCoreWithProxy(peerAddress, params, birthday, proxy)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(core), nil
 }
 
 func NewMercuryClient(network string) (Client, error) {
@@ -120,5 +495,42 @@ func NewMercuryClient(network string) (Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &client{core}, nil
+	return newClient(core), nil
+}
+
+func NewMercuryClientWithURL(url string, params *chaincfg.Params) Client {
+	registerParams(params)
+	return newClient(clients.NewMercuryClientCoreWithURL(url, params))
+}
+
+func NewMultiClient(mode clients.SelectionMode, backends ...clients.Backend) (Client, error) {
+	core, err := clients.NewMultiClientCore(mode, backends...)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(core), nil
+}
+
+// SetRetryPolicy sets the retry policy of the backend of the client, if it
+// retries failed requests.
+func (client *client) SetRetryPolicy(policy clients.RetryPolicy) {
+	if setter, ok := client.ClientCore.(clients.RetryPolicySetter); ok {
+		setter.SetRetryPolicy(policy)
+	}
+}
+
+// SetHTTPClient sets the http.Client of the backend of the client, if it calls
+// an HTTP API.
+func (client *client) SetHTTPClient(httpClient *http.Client) {
+	if setter, ok := client.ClientCore.(clients.HTTPClientSetter); ok {
+		setter.SetHTTPClient(httpClient)
+	}
+}
+
+// SetZMQAddress sets the ZMQ address of the backend of the client, if it is a
+// full node.
+func (client *client) SetZMQAddress(addr string) {
+	if setter, ok := client.ClientCore.(clients.ZMQAddressSetter); ok {
+		setter.SetZMQAddress(addr)
+	}
 }
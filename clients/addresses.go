@@ -0,0 +1,92 @@
+package clients
+
+import (
+	"context"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// maxConcurrentAddresses is the maximum number of addresses whose outputs are
+// fetched concurrently by backends that cannot fetch them in a single round
+// trip, so that APIs do not rate limit the requests.
+const maxConcurrentAddresses = 8
+
+// GetUTXOsMulti returns the outputs of the addresses, in a single round trip
+// when the backend implements AddressBatcher. Otherwise, the outputs of the
+// addresses are fetched concurrently.
+func GetUTXOsMulti(ctx context.Context, core ClientCore, addresses []string, confirmations int64) (map[string][]UTXO, error) {
+	if batcher, ok := core.(AddressBatcher); ok {
+		return batcher.GetUTXOsMulti(ctx, addresses, confirmations)
+	}
+	return getUTXOsConcurrently(ctx, core, addresses, confirmations)
+}
+
+func getUTXOsConcurrently(ctx context.Context, core ClientCore, addresses []string, confirmations int64) (map[string][]UTXO, error) {
+	mu := new(sync.Mutex)
+	utxos := make(map[string][]UTXO, len(addresses))
+	var firstErr error
+
+	sem := make(chan struct{}, maxConcurrentAddresses)
+	var wg sync.WaitGroup
+	for _, address := range addresses {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(address string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			addressUTXOs, err := core.GetUTXOs(ctx, address, 999999, confirmations)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			utxos[address] = addressUTXOs
+		}(address)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return utxos, nil
+}
+
+// utxosByAddress groups the outputs listed by the listunspent RPC by address,
+// converting their amounts in BTC to satoshis.
+func utxosByAddress(addresses []string, unspents []btcjson.ListUnspentResult, tipHeight int64, toSatoshi func(float64) (int64, error)) (map[string][]UTXO, error) {
+	utxos := make(map[string][]UTXO, len(addresses))
+	for _, address := range addresses {
+		utxos[address] = []UTXO{}
+	}
+	for _, unspent := range unspents {
+		utxo, err := listUnspentUTXO(unspent, tipHeight, toSatoshi)
+		if err != nil {
+			return nil, err
+		}
+		utxos[unspent.Address] = append(utxos[unspent.Address], utxo)
+	}
+	return utxos, nil
+}
+
+// listUnspentUTXO returns the output listed by the listunspent RPC, along with
+// the redeem script the wallet knows for it, when the chain tip is at the
+// given height.
+func listUnspentUTXO(unspent btcjson.ListUnspentResult, tipHeight int64, toSatoshi func(float64) (int64, error)) (UTXO, error) {
+	amount, err := toSatoshi(unspent.Amount)
+	if err != nil {
+		return UTXO{}, err
+	}
+	return UTXO{
+		TxHash:        unspent.TxID,
+		Amount:        amount,
+		ScriptPubKey:  unspent.ScriptPubKey,
+		Vout:          unspent.Vout,
+		Confirmations: unspent.Confirmations,
+		BlockHeight:   utxoBlockHeight(tipHeight, unspent.Confirmations),
+		Address:       unspent.Address,
+		RedeemScript:  unspent.RedeemScript,
+	}, nil
+}
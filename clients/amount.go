@@ -0,0 +1,26 @@
+package clients
+
+import (
+	"github.com/btcsuite/btcutil"
+)
+
+// Value returns the amount of the output.
+func (utxo UTXO) Value() btcutil.Amount {
+	return btcutil.Amount(utxo.Amount)
+}
+
+// BTCToSatoshi converts an amount in BTC, as returned by the JSON-RPC API of
+// bitcoind, to satoshis. The amount is rounded to the nearest satoshi, so
+// that floating point errors do not lose a satoshi.
+func BTCToSatoshi(btc float64) (int64, error) {
+	amount, err := btcutil.NewAmount(btc)
+	if err != nil {
+		return 0, err
+	}
+	return int64(amount), nil
+}
+
+// SatoshiToBTC converts an amount in satoshis to BTC.
+func SatoshiToBTC(satoshi int64) float64 {
+	return btcutil.Amount(satoshi).ToBTC()
+}
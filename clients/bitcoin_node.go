@@ -4,25 +4,123 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"math"
-	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/errors"
+	"github.com/renproject/libbtc-go/networks"
 )
 
 type bitcoinFNClient struct {
 	client  *rpcclient.Client
 	client2 RPCCLient
 	params  *chaincfg.Params
+	zmqAddr string
+
+	// scanMu serialises the calls to scantxoutset, as nodes run a single
+	// scan at a time.
+	scanMu *sync.Mutex
+
+	// walletMu guards descriptorWallet, which is whether the wallet of the
+	// node is a descriptor wallet, and nil until it is known, and watched,
+	// which maps the addresses the wallet is known to watch to whether their
+	// past transactions are known to the wallet.
+	walletMu         *sync.Mutex
+	descriptorWallet *bool
+	watched          map[string]bool
 }
 
+// rescanPollInterval is the interval at which the progress of a wallet rescan
+// is polled.
+const rescanPollInterval = 5 * time.Second
+
+// scanTxOutSetResult is the result of the scantxoutset RPC.
+type scanTxOutSetResult struct {
+	Success  bool  `json:"success"`
+	Height   int64 `json:"height"`
+	Unspents []struct {
+		TxID         string  `json:"txid"`
+		Vout         uint32  `json:"vout"`
+		ScriptPubKey string  `json:"scriptPubKey"`
+		Amount       float64 `json:"amount"`
+		Height       int64   `json:"height"`
+	} `json:"unspents"`
+}
+
+// NewBitcoinFNClientCore returns a ClientCore connected to the JSON-RPC server
+// of a full node. Nodes of the networks registered in the networks package,
+// such as litecoind and dogecoind, are recognised by their genesis hash.
 func NewBitcoinFNClientCore(host, user, password string) (ClientCore, error) {
-	client, err := rpcclient.New(
+	client, err := newBitcoinFNRPCClient(host, user, password)
+	if err != nil {
+		return nil, err
+	}
+
+	genesis, err := client.GetBlockHash(0)
+	if err != nil {
+		return nil, err
+	}
+	var params *chaincfg.Params
+	if network, ok := networks.LookupGenesis(genesis.String()); ok {
+		params = network.Params
+	} else {
+		bcInfo, err := client.GetBlockChainInfo()
+		if err != nil {
+			return nil, err
+		}
+		switch bcInfo.Chain {
+		case "main":
+			params = &chaincfg.MainNetParams
+		case "test":
+			params = &chaincfg.TestNet3Params
+		case "regtest":
+			params = &chaincfg.RegressionNetParams
+		default:
+			return nil, fmt.Errorf("unsupported bitcoin network: %s", bcInfo.Chain)
+		}
+	}
+
+	return &bitcoinFNClient{
+		client:   client,
+		client2:  NewRPCClient(host, user, password),
+		params:   params,
+		scanMu:   new(sync.Mutex),
+		walletMu: new(sync.Mutex),
+		watched:  map[string]bool{},
+	}, nil
+}
+
+// NewBitcoinFNClientCoreWithParams returns a ClientCore connected to a node
+// of the network described by the given parameters, for example a private
+// chain or a fork, instead of detecting the network from the node.
+func NewBitcoinFNClientCoreWithParams(host, user, password string, params *chaincfg.Params) (ClientCore, error) {
+	client, err := newBitcoinFNRPCClient(host, user, password)
+	if err != nil {
+		return nil, err
+	}
+	return &bitcoinFNClient{
+		client:   client,
+		client2:  NewRPCClient(host, user, password),
+		params:   params,
+		scanMu:   new(sync.Mutex),
+		walletMu: new(sync.Mutex),
+		watched:  map[string]bool{},
+	}, nil
+}
+
+func newBitcoinFNRPCClient(host, user, password string) (*rpcclient.Client, error) {
+	return rpcclient.New(
 		&rpcclient.ConnConfig{
 			Host:         host,
 			User:         user,
@@ -32,67 +130,393 @@ func NewBitcoinFNClientCore(host, user, password string) (ClientCore, error) {
 		},
 		nil,
 	)
+}
+
+func (client *bitcoinFNClient) GetUTXOs(ctx context.Context, address string, limit, confitmations int64) ([]UTXO, error) {
+	utxos, err := client.GetUTXOsMulti(ctx, []string{address}, confitmations)
 	if err != nil {
-		return nil, err
+		return []UTXO{}, err
 	}
+	return utxos[address], nil
+}
 
-	bcInfo, err := client.GetBlockChainInfo()
+// GetUTXOsMulti lists the outputs of every address from the wallet of the node
+// when it watches all of them, as listunspent returns unconfirmed outputs and
+// leaves out the outputs spent in the mempool. Otherwise, the outputs are
+// listed with a single call to scantxoutset, which reads the UTXO set of the
+// node rather than its wallet, so that it works with pruned nodes and nodes
+// without a wallet, and never needs the addresses to be imported. The UTXO set
+// only has confirmed outputs, so those spent in the mempool are left out with
+// gettxout, and unconfirmed ones are added from the wallet of the node if it
+// watches some of the addresses. Nodes too old to support scantxoutset list
+// the outputs from their wallet.
+func (client *bitcoinFNClient) GetUTXOsMulti(ctx context.Context, addresses []string, confirmations int64) (map[string][]UTXO, error) {
+	addrs := make([]btcutil.Address, len(addresses))
+	descriptors := make([]map[string]string, len(addresses))
+	scripts := make(map[string]string, len(addresses))
+	for i, address := range addresses {
+		addr, err := btcutil.DecodeAddress(address, client.NetworkParams())
+		if err != nil {
+			return nil, err
+		}
+		script, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+		addrs[i] = addr
+		descriptors[i] = map[string]string{"desc": fmt.Sprintf("addr(%s)", address)}
+		scripts[hex.EncodeToString(script)] = address
+	}
+
+	if client.walletWatches(addresses) {
+		return client.listUnspent(addresses, addrs, confirmations)
+	}
+	result, err := client.scanTxOutSet(descriptors)
+	if rpcErr, ok := err.(*btcjson.RPCError); ok && rpcErr.Code == btcjson.ErrRPCMethodNotFound.Code {
+		return client.listUnspent(addresses, addrs, confirmations)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	var params *chaincfg.Params
-	switch bcInfo.Chain {
-	case "main":
-		params = &chaincfg.MainNetParams
-	case "test":
-		params = &chaincfg.TestNet3Params
-	case "regtest":
-		params = &chaincfg.RegressionNetParams
-	default:
-		return nil, fmt.Errorf("unsupported bitcoin network: %s", bcInfo.Chain)
+	utxos := make(map[string][]UTXO, len(addresses))
+	for _, address := range addresses {
+		utxos[address] = []UTXO{}
+	}
+	// The outputs spent by transactions of the mempool are still in the UTXO
+	// set, but not in the results of gettxout including the mempool, which
+	// are requested concurrently.
+	txOuts := make([]rpcclient.FutureGetTxOutResult, len(result.Unspents))
+	for i, unspent := range result.Unspents {
+		hash, err := chainhash.NewHashFromStr(unspent.TxID)
+		if err != nil {
+			return nil, err
+		}
+		txOuts[i] = client.client.GetTxOutAsync(hash, unspent.Vout, true)
+	}
+	for i, unspent := range result.Unspents {
+		txOut, err := txOuts[i].Receive()
+		if err != nil {
+			return nil, err
+		}
+		if txOut == nil {
+			continue
+		}
+		address, ok := scripts[unspent.ScriptPubKey]
+		if !ok {
+			continue
+		}
+		utxoConfirmations := result.Height - unspent.Height + 1
+		if utxoConfirmations < confirmations {
+			continue
+		}
+		amount, err := BTCToSatoshi(unspent.Amount)
+		if err != nil {
+			return nil, err
+		}
+		utxos[address] = append(utxos[address], UTXO{
+			TxHash:        unspent.TxID,
+			Amount:        amount,
+			ScriptPubKey:  unspent.ScriptPubKey,
+			Vout:          unspent.Vout,
+			Confirmations: utxoConfirmations,
+			BlockHeight:   unspent.Height,
+			Address:       address,
+		})
+	}
+	if confirmations > 0 {
+		return utxos, nil
 	}
 
-	return &bitcoinFNClient{
-		client:  client,
-		client2: NewRPCClient(host, user, password),
-		params:  params,
-	}, nil
+	// Nodes without a wallet, or whose wallet does not watch the addresses,
+	// only return the confirmed outputs.
+	unspents, err := client.client.ListUnspentMinMaxAddresses(0, 0, addrs)
+	if err != nil {
+		return utxos, nil
+	}
+	for _, unspent := range unspents {
+		utxo, err := listUnspentUTXO(unspent, result.Height, BTCToSatoshi)
+		if err != nil {
+			return nil, err
+		}
+		utxos[unspent.Address] = append(utxos[unspent.Address], utxo)
+	}
+	return utxos, nil
 }
 
-func (client *bitcoinFNClient) GetUTXOs(ctx context.Context, address string, limit, confitmations int64) ([]UTXO, error) {
-	net := client.NetworkParams()
-	addr, err := btcutil.DecodeAddress(address, net)
+// listUnspent lists the outputs of the addresses from the wallet of the node.
+func (client *bitcoinFNClient) listUnspent(addresses []string, addrs []btcutil.Address, confirmations int64) (map[string][]UTXO, error) {
+	tipHeight, err := client.client.GetBlockCount()
 	if err != nil {
-		return []UTXO{}, err
+		return nil, err
+	}
+	unspents, err := client.client.ListUnspentMinMaxAddresses(int(confirmations), 999999, addrs)
+	if err != nil {
+		return nil, err
 	}
+	return utxosByAddress(addresses, unspents, tipHeight, BTCToSatoshi)
+}
+
+// walletWatches returns whether the wallet of the node watches every address
+// along with its past transactions, as reported by getaddressinfo unless the
+// address was imported by the client. Addresses imported without rescanning
+// the chain are not, and the answer is false for nodes without a wallet.
+func (client *bitcoinFNClient) walletWatches(addresses []string) bool {
+	client.walletMu.Lock()
+	defer client.walletMu.Unlock()
+	for _, address := range addresses {
+		if scanned, ok := client.watched[address]; ok {
+			if !scanned {
+				return false
+			}
+			continue
+		}
+		addressJSON, err := json.Marshal(address)
+		if err != nil {
+			return false
+		}
+		resp, err := client.client.RawRequest("getaddressinfo", []json.RawMessage{addressJSON})
+		if err != nil {
+			return false
+		}
+		info := struct {
+			IsMine      bool `json:"ismine"`
+			IsWatchOnly bool `json:"iswatchonly"`
+		}{}
+		if err := json.Unmarshal(resp, &info); err != nil || !(info.IsMine || info.IsWatchOnly) {
+			return false
+		}
+		client.watched[address] = true
+	}
+	return true
+}
+
+// scanTxOutSet scans the UTXO set of the node for the outputs matching the
+// descriptors.
+func (client *bitcoinFNClient) scanTxOutSet(descriptors []map[string]string) (scanTxOutSetResult, error) {
+	client.scanMu.Lock()
+	defer client.scanMu.Unlock()
 
-	unspents, err := client.client.ListUnspentMinMaxAddresses(0, 999999, []btcutil.Address{addr})
+	descriptorsJSON, err := json.Marshal(descriptors)
 	if err != nil {
-		return []UTXO{}, err
+		return scanTxOutSetResult{}, err
 	}
+	resp, err := client.client.RawRequest("scantxoutset", []json.RawMessage{json.RawMessage(`"start"`), descriptorsJSON})
+	if err != nil {
+		return scanTxOutSetResult{}, err
+	}
+	result := scanTxOutSetResult{}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return scanTxOutSetResult{}, err
+	}
+	if !result.Success {
+		return scanTxOutSetResult{}, fmt.Errorf("scantxoutset did not complete")
+	}
+	return result, nil
+}
 
-	if len(unspents) == 0 {
-		if err := client.client.ImportAddressRescan(address, "", false); err != nil {
-			return []UTXO{}, err
+// ImportAddressRescan imports the address into the wallet of the node and
+// rescans the chain for its transactions, sending the progress reported by
+// getwalletinfo while the rescan runs.
+func (client *bitcoinFNClient) ImportAddressRescan(ctx context.Context, address string) (<-chan RescanProgress, error) {
+	if _, err := btcutil.DecodeAddress(address, client.NetworkParams()); err != nil {
+		return nil, err
+	}
+
+	progress := make(chan RescanProgress, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.watchAddress(ctx, address, "", true)
+	}()
+	go func() {
+		defer close(progress)
+		ticker := time.NewTicker(rescanPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				// The import keeps running on the node until the rescan is
+				// aborted.
+				client.client.RawRequest("abortrescan", nil)
+				return
+			case err := <-done:
+				select {
+				case <-ctx.Done():
+				case progress <- RescanProgress{Progress: 1, Done: true, Err: err}:
+				}
+				return
+			case <-ticker.C:
+			}
+
+			status, err := client.rescanProgress()
+			if err != nil || !status.Scanning {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+			case progress <- status:
+			}
 		}
+	}()
+	return progress, nil
+}
 
-		unspents, err = client.client.ListUnspentMinMaxAddresses(0, 999999, []btcutil.Address{addr})
+// rescanProgress returns the progress of the rescan of the wallet of the node,
+// from the scanning field of getwalletinfo. The field is false when no rescan
+// is running, and missing on nodes too old to report it.
+func (client *bitcoinFNClient) rescanProgress() (RescanProgress, error) {
+	resp, err := client.client.RawRequest("getwalletinfo", nil)
+	if err != nil {
+		return RescanProgress{}, err
+	}
+	info := struct {
+		Scanning json.RawMessage `json:"scanning"`
+	}{}
+	if err := json.Unmarshal(resp, &info); err != nil {
+		return RescanProgress{}, err
+	}
+	if len(info.Scanning) == 0 || string(info.Scanning) == "false" {
+		return RescanProgress{}, nil
+	}
+	scanning := struct {
+		Duration int64   `json:"duration"`
+		Progress float64 `json:"progress"`
+	}{}
+	if err := json.Unmarshal(info.Scanning, &scanning); err != nil {
+		return RescanProgress{}, err
+	}
+	return RescanProgress{
+		Scanning: true,
+		Progress: scanning.Progress,
+		Duration: time.Duration(scanning.Duration) * time.Second,
+	}, nil
+}
+
+// ImportDescriptors imports the descriptors into the descriptor wallet of the
+// node with importdescriptors, once their checksum is computed by the node.
+func (client *bitcoinFNClient) ImportDescriptors(ctx context.Context, descriptors []WalletDescriptor) error {
+	requests := make([]map[string]interface{}, len(descriptors))
+	for i, descriptor := range descriptors {
+		desc := strings.SplitN(descriptor.Desc, "#", 2)[0]
+		descJSON, err := json.Marshal(desc)
+		if err != nil {
+			return err
+		}
+		resp, err := client.client.RawRequest("getdescriptorinfo", []json.RawMessage{descJSON})
 		if err != nil {
-			return []UTXO{}, err
+			return fmt.Errorf("invalid descriptor %s: %v", desc, err)
+		}
+		info := struct {
+			Checksum string `json:"checksum"`
+		}{}
+		if err := json.Unmarshal(resp, &info); err != nil {
+			return err
+		}
+
+		request := map[string]interface{}{
+			"desc":      desc + "#" + info.Checksum,
+			"timestamp": "now",
+		}
+		if descriptor.Rescan {
+			request["timestamp"] = 0
 		}
+		if strings.Contains(desc, "*") {
+			request["range"] = []uint32{0, descriptor.RangeEnd}
+		}
+		if descriptor.Internal {
+			request["internal"] = true
+		}
+		if descriptor.Label != "" {
+			request["label"] = descriptor.Label
+		}
+		requests[i] = request
 	}
 
-	utxos := []UTXO{}
-	for _, unspent := range unspents {
-		utxos = append(utxos, UTXO{
-			TxHash:       unspent.TxID,
-			Amount:       int64(unspent.Amount * math.Pow(10, 8)),
-			ScriptPubKey: unspent.ScriptPubKey,
-			Vout:         unspent.Vout,
-		})
+	requestsJSON, err := json.Marshal(requests)
+	if err != nil {
+		return err
 	}
-	return utxos, nil
+	resp, err := client.client.RawRequest("importdescriptors", []json.RawMessage{requestsJSON})
+	if err != nil {
+		return err
+	}
+	results := []struct {
+		Success bool `json:"success"`
+		Error   *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}{}
+	if err := json.Unmarshal(resp, &results); err != nil {
+		return err
+	}
+	for i, result := range results {
+		if result.Success || i >= len(descriptors) {
+			continue
+		}
+		if result.Error != nil {
+			return fmt.Errorf("cannot import descriptor %s: (%d) %s", descriptors[i].Desc, result.Error.Code, result.Error.Message)
+		}
+		return fmt.Errorf("cannot import descriptor %s", descriptors[i].Desc)
+	}
+	return nil
+}
+
+// watchAddress makes the wallet of the node watch the address, with
+// importdescriptors for descriptor wallets and importaddress for legacy
+// wallets, rescanning the chain for its past transactions if rescan is true.
+// Addresses are only imported once, unless they are rescanned.
+func (client *bitcoinFNClient) watchAddress(ctx context.Context, address, label string, rescan bool) error {
+	client.walletMu.Lock()
+	_, watched := client.watched[address]
+	client.walletMu.Unlock()
+	if watched && !rescan {
+		return nil
+	}
+
+	var err error
+	if client.usesDescriptors() {
+		err = client.ImportDescriptors(ctx, []WalletDescriptor{{
+			Desc:   fmt.Sprintf("addr(%s)", address),
+			Label:  label,
+			Rescan: rescan,
+		}})
+	} else {
+		err = client.client.ImportAddressRescan(address, label, rescan)
+	}
+	if err != nil {
+		return err
+	}
+
+	client.walletMu.Lock()
+	defer client.walletMu.Unlock()
+	client.watched[address] = client.watched[address] || rescan
+	return nil
+}
+
+// usesDescriptors returns whether the wallet of the node is a descriptor
+// wallet, which modern nodes create by default and which does not support
+// importaddress. Nodes too old to report it have legacy wallets. The answer
+// is only remembered once the node has given it.
+func (client *bitcoinFNClient) usesDescriptors() bool {
+	client.walletMu.Lock()
+	defer client.walletMu.Unlock()
+	if client.descriptorWallet != nil {
+		return *client.descriptorWallet
+	}
+
+	resp, err := client.client.RawRequest("getwalletinfo", nil)
+	if err != nil {
+		return false
+	}
+	info := struct {
+		Descriptors bool `json:"descriptors"`
+	}{}
+	if err := json.Unmarshal(resp, &info); err != nil {
+		return false
+	}
+	client.descriptorWallet = &info.Descriptors
+	return info.Descriptors
 }
 
 func (client *bitcoinFNClient) Confirmations(ctx context.Context, txHashStr string) (int64, error) {
@@ -108,7 +532,7 @@ func (client *bitcoinFNClient) Confirmations(ctx context.Context, txHashStr stri
 }
 
 func (client *bitcoinFNClient) ScriptFunded(ctx context.Context, address string, value int64) (bool, int64, error) {
-	if err := client.client.ImportAddressRescan(address, "scripts", false); err != nil {
+	if err := client.watchAddress(ctx, address, "scripts", false); err != nil {
 		return false, value, err
 	}
 	net := client.NetworkParams()
@@ -124,7 +548,7 @@ func (client *bitcoinFNClient) ScriptFunded(ctx context.Context, address string,
 }
 
 func (client *bitcoinFNClient) ScriptRedeemed(ctx context.Context, address string, value int64) (bool, int64, error) {
-	if err := client.client.ImportAddressRescan(address, "scripts", false); err != nil {
+	if err := client.watchAddress(ctx, address, "scripts", false); err != nil {
 		return false, value, err
 	}
 	net := client.NetworkParams()
@@ -147,6 +571,97 @@ func (client *bitcoinFNClient) ScriptRedeemed(ctx context.Context, address strin
 	return int64(amount.ToUnit(btcutil.AmountSatoshi)) >= value && balance == 0, balance, nil
 }
 
+func (client *bitcoinFNClient) ScriptRedemption(ctx context.Context, address string, value int64) (Redemption, error) {
+	if err := client.watchAddress(ctx, address, "scripts", false); err != nil {
+		return Redemption{}, err
+	}
+	net := client.NetworkParams()
+	addr, err := btcutil.DecodeAddress(address, net)
+	if err != nil {
+		return Redemption{}, err
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return Redemption{}, err
+	}
+
+	received, err := client.client2.ListReceivedByAddress(address)
+	if err != nil {
+		return Redemption{}, err
+	}
+	receivedTxIDs := []string{}
+	for _, obj := range received {
+		receivedTxIDs = append(receivedTxIDs, obj.TxIDs...)
+	}
+	receivedTxs, err := client.rawTransactions(receivedTxIDs)
+	if err != nil {
+		return Redemption{}, err
+	}
+	funded := map[wire.OutPoint]int64{}
+	var receivedAmount int64
+	for _, tx := range receivedTxs {
+		for vout, txOut := range tx.TxOut {
+			if bytes.Equal(txOut.PkScript, script) {
+				funded[wire.OutPoint{Hash: tx.TxHash(), Index: uint32(vout)}] = txOut.Value
+				receivedAmount += txOut.Value
+			}
+		}
+	}
+
+	// Spends of watch-only outputs are listed by the wallet, so the redeeming
+	// transactions are found by looking for inputs spending the funded
+	// outputs.
+	txs, err := client.client2.ListTransansactions()
+	if err != nil {
+		return Redemption{}, err
+	}
+	txIDs := []string{}
+	seen := map[string]bool{}
+	for _, obj := range txs {
+		if !seen[obj.TxID] {
+			seen[obj.TxID] = true
+			txIDs = append(txIDs, obj.TxID)
+		}
+	}
+	listedTxs, err := client.rawTransactions(txIDs)
+	if err != nil {
+		return Redemption{}, err
+	}
+
+	// The transactions are visited in the order listed by the wallet, so
+	// that the redemption does not depend on the iteration order of the map.
+	redemption := Redemption{TxHashes: []string{}}
+	for _, txID := range txIDs {
+		hash, err := chainhash.NewHashFromStr(txID)
+		if err != nil {
+			return Redemption{}, err
+		}
+		tx, ok := listedTxs[*hash]
+		if !ok {
+			return Redemption{}, fmt.Errorf("node did not return transaction %s", txID)
+		}
+		var amount int64
+		for _, txIn := range tx.TxIn {
+			amount += funded[txIn.PreviousOutPoint]
+		}
+		if amount == 0 {
+			continue
+		}
+
+		conf, err := client.Confirmations(ctx, txID)
+		if err != nil {
+			return Redemption{}, err
+		}
+		if len(redemption.TxHashes) == 0 || conf < redemption.Confirmations {
+			redemption.Confirmations = conf
+		}
+		redemption.TxHashes = append(redemption.TxHashes, txID)
+		redemption.Amount += amount
+	}
+	redemption.Redeemed = receivedAmount >= value && receivedAmount == redemption.Amount
+	return redemption, nil
+}
+
 func (client *bitcoinFNClient) GetUTXO(ctx context.Context, txHash string, vout uint32) (UTXO, error) {
 	hash, err := chainhash.NewHashFromStr(txHash)
 	if err != nil {
@@ -166,26 +681,97 @@ func (client *bitcoinFNClient) GetUTXO(ctx context.Context, txHash string, vout
 		return UTXO{}, err
 	}
 
+	amount, err := BTCToSatoshi(tx.Vout[vout].Value)
+	if err != nil {
+		return UTXO{}, err
+	}
+	confirmations, err := client.Confirmations(ctx, txHash)
+	if err != nil {
+		return UTXO{}, err
+	}
+	tipHeight, err := client.client.GetBlockCount()
+	if err != nil {
+		return UTXO{}, err
+	}
 	return UTXO{
-		TxHash:       txHash,
-		Vout:         vout,
-		Amount:       floatToInt(tx.Vout[vout].Value),
-		ScriptPubKey: tx.Vout[vout].ScriptPubKey.Hex,
+		TxHash:        txHash,
+		Vout:          vout,
+		Amount:        amount,
+		ScriptPubKey:  tx.Vout[vout].ScriptPubKey.Hex,
+		Confirmations: confirmations,
+		BlockHeight:   utxoBlockHeight(tipHeight, confirmations),
+		Address:       scriptAddress(tx.Vout[vout].ScriptPubKey.Hex, client.NetworkParams()),
 	}, nil
 }
 
-func (client *bitcoinFNClient) ScriptSpent(ctx context.Context, scriptAddress, spenderAddress string) (bool, string, error) {
-	if err := client.client.ImportAddressRescan(scriptAddress, "", false); err != nil {
-		return false, "", err
+// GetUTXOBatch returns the outputs at the given outpoints, fetching every
+// transaction with a single batched request.
+func (client *bitcoinFNClient) GetUTXOBatch(ctx context.Context, outPoints []wire.OutPoint) ([]UTXO, error) {
+	txIDs := []string{}
+	seen := map[chainhash.Hash]bool{}
+	for _, outPoint := range outPoints {
+		if !seen[outPoint.Hash] {
+			seen[outPoint.Hash] = true
+			txIDs = append(txIDs, outPoint.Hash.String())
+		}
+	}
+	txs, err := client.rawTransactions(txIDs)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := client.client.ImportAddressRescan(spenderAddress, "", false); err != nil {
-		return false, "", err
+	utxos := make([]UTXO, len(outPoints))
+	for i, outPoint := range outPoints {
+		tx, ok := txs[outPoint.Hash]
+		if !ok {
+			return nil, fmt.Errorf("node did not return transaction %s", outPoint.Hash)
+		}
+		if int(outPoint.Index) >= len(tx.TxOut) {
+			return nil, fmt.Errorf("transaction %s does not have an output at index %d", outPoint.Hash, outPoint.Index)
+		}
+		scriptPubKey := hex.EncodeToString(tx.TxOut[outPoint.Index].PkScript)
+		utxos[i] = UTXO{
+			TxHash:       outPoint.Hash.String(),
+			Amount:       tx.TxOut[outPoint.Index].Value,
+			ScriptPubKey: scriptPubKey,
+			Vout:         outPoint.Index,
+			Address:      scriptAddress(scriptPubKey, client.NetworkParams()),
+		}
+	}
+	return utxos, nil
+}
+
+// OutputUnspent returns whether the output is unspent with gettxout, which
+// takes the mempool into account.
+func (client *bitcoinFNClient) OutputUnspent(ctx context.Context, txHash string, vout uint32) (bool, error) {
+	hash, err := chainhash.NewHashFromStr(txHash)
+	if err != nil {
+		return false, err
+	}
+	txOut, err := client.client.GetTxOut(hash, vout, true)
+	if err != nil {
+		return false, err
+	}
+	return txOut != nil, nil
+}
+
+func (client *bitcoinFNClient) ScriptSpent(ctx context.Context, script, spender string) (bool, string, error) {
+	spend, spent, err := client.ScriptSpendDetails(ctx, script, spender)
+	return spent, spend.SigScript, err
+}
+
+func (client *bitcoinFNClient) ScriptSpendDetails(ctx context.Context, scriptAddress, spenderAddress string) (ScriptSpend, bool, error) {
+	if err := client.watchAddress(ctx, scriptAddress, "", false); err != nil {
+		return ScriptSpend{}, false, err
+	}
+
+	if err := client.watchAddress(ctx, spenderAddress, "", false); err != nil {
+		return ScriptSpend{}, false, err
 	}
 
 	txs, err := client.client2.ListTransansactions()
 	if err != nil {
-		return false, "", err
+		return ScriptSpend{}, false, err
 	}
 
 	var hash string
@@ -197,40 +783,214 @@ func (client *bitcoinFNClient) ScriptSpent(ctx context.Context, scriptAddress, s
 
 	txList, err := client.client2.ListReceivedByAddress(spenderAddress)
 	if err != nil {
-		return false, "", err
+		return ScriptSpend{}, false, err
 	}
 
 	for _, txID := range txList[0].TxIDs {
 		txHash, err := chainhash.NewHashFromStr(txID)
 		if err != nil {
-			return false, "", err
+			return ScriptSpend{}, false, err
 		}
 
 		tx, err := client.client.GetRawTransaction(txHash)
 		if err != nil {
-			return false, "", err
+			return ScriptSpend{}, false, err
 		}
 
-		for _, txIn := range tx.MsgTx().TxIn {
+		for i, txIn := range tx.MsgTx().TxIn {
 			if txIn.PreviousOutPoint.Hash.String() == hash {
-				return true, hex.EncodeToString(txIn.SignatureScript), nil
+				return newScriptSpend(tx.MsgTx(), i), true, nil
 			}
 		}
 	}
 
-	return false, "", fmt.Errorf("could not find the transaction")
+	return ScriptSpend{}, false, fmt.Errorf("could not find the transaction")
+}
+
+func (client *bitcoinFNClient) ChainTip(ctx context.Context) (ChainTip, error) {
+	bcInfo, err := client.client.GetBlockChainInfo()
+	if err != nil {
+		return ChainTip{}, err
+	}
+	hash, err := chainhash.NewHashFromStr(bcInfo.BestBlockHash)
+	if err != nil {
+		return ChainTip{}, err
+	}
+	header, err := client.client.GetBlockHeader(hash)
+	if err != nil {
+		return ChainTip{}, err
+	}
+	return ChainTip{
+		Height:     int64(bcInfo.Blocks),
+		Hash:       bcInfo.BestBlockHash,
+		Time:       header.Timestamp.Unix(),
+		MedianTime: bcInfo.MedianTime,
+	}, nil
+}
+
+// BlockHash returns the hash of the block at the given height.
+func (client *bitcoinFNClient) BlockHash(ctx context.Context, height int64) (string, error) {
+	hash, err := client.client.GetBlockHash(height)
+	if err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+// EstimateFeeRate returns the rate estimated by the estimatesmartfee call of
+// the node, which fails until the node has seen enough blocks.
+func (client *bitcoinFNClient) EstimateFeeRate(ctx context.Context, blocks int64) (int64, error) {
+	resp, err := client.client.RawRequest("estimatesmartfee", []json.RawMessage{json.RawMessage(strconv.FormatInt(blocks, 10))})
+	if err != nil {
+		return 0, err
+	}
+	estimate := struct {
+		FeeRate *float64 `json:"feerate"`
+		Errors  []string `json:"errors"`
+	}{}
+	if err := json.Unmarshal(resp, &estimate); err != nil {
+		return 0, err
+	}
+	if estimate.FeeRate == nil {
+		return 0, fmt.Errorf("cannot estimate fee rate: %v", estimate.Errors)
+	}
+	// The rate is in BTC per kilobyte.
+	ratePerKB, err := BTCToSatoshi(*estimate.FeeRate)
+	if err != nil {
+		return 0, err
+	}
+	return (ratePerKB + 999) / 1000, nil
+}
+
+// SetZMQAddress sets the address of the ZMQ publisher of the node, on which
+// it publishes raw blocks (-zmqpubrawblock), so that blocks are subscribed to
+// instead of polled.
+func (client *bitcoinFNClient) SetZMQAddress(addr string) {
+	client.zmqAddr = addr
+}
+
+// SubscribeBlocks subscribes to the blocks published by the ZMQ interface of
+// the node, once its address is set.
+func (client *bitcoinFNClient) SubscribeBlocks(ctx context.Context) (<-chan BlockEvent, error) {
+	if client.zmqAddr == "" {
+		return nil, errors.NewErrUnsupportedOperation("SubscribeBlocks", "bitcoin node without zmq")
+	}
+	return subscribeZMQBlocks(ctx, client.zmqAddr, func(hash *chainhash.Hash) (int64, error) {
+		header, err := client.client.GetBlockHeaderVerbose(hash)
+		if err != nil {
+			return 0, err
+		}
+		return int64(header.Height), nil
+	})
+}
+
+func (client *bitcoinFNClient) GetBlockHeader(ctx context.Context, hashOrHeight string) (*wire.BlockHeader, error) {
+	hash, err := client.blockHash(hashOrHeight)
+	if err != nil {
+		return nil, err
+	}
+	return client.client.GetBlockHeader(hash)
+}
+
+func (client *bitcoinFNClient) GetBlock(ctx context.Context, hashOrHeight string) (*wire.MsgBlock, error) {
+	hash, err := client.blockHash(hashOrHeight)
+	if err != nil {
+		return nil, err
+	}
+	return client.client.GetBlock(hash)
+}
+
+func (client *bitcoinFNClient) RawTransaction(ctx context.Context, txHash string) (*wire.MsgTx, error) {
+	hash, err := chainhash.NewHashFromStr(txHash)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := client.client.GetRawTransaction(hash)
+	if err != nil {
+		return nil, err
+	}
+	return tx.MsgTx(), nil
+}
+
+// TestMempoolAccept tests the transaction with the testmempoolaccept call of
+// the node, which nodes older than 0.17 do not support.
+func (client *bitcoinFNClient) TestMempoolAccept(ctx context.Context, stx *wire.MsgTx) error {
+	buf := new(bytes.Buffer)
+	if err := stx.Serialize(buf); err != nil {
+		return err
+	}
+	rawTxs, err := json.Marshal([]string{hex.EncodeToString(buf.Bytes())})
+	if err != nil {
+		return err
+	}
+	resp, err := client.client.RawRequest("testmempoolaccept", []json.RawMessage{rawTxs})
+	if err != nil {
+		return err
+	}
+	results := []struct {
+		Allowed      bool   `json:"allowed"`
+		RejectReason string `json:"reject-reason"`
+	}{}
+	if err := json.Unmarshal(resp, &results); err != nil {
+		return err
+	}
+	if len(results) != 1 {
+		return fmt.Errorf("unexpected testmempoolaccept response: %s", resp)
+	}
+	if !results[0].Allowed {
+		return errors.NewErrBitcoinSubmitTx(results[0].RejectReason)
+	}
+	return nil
 }
 
 func (client *bitcoinFNClient) PublishTransaction(ctx context.Context, stx *wire.MsgTx) error {
-	_, err := client.client.SendRawTransaction(stx, false)
-	return err
+	if _, err := client.client.SendRawTransaction(stx, false); err != nil {
+		return publishRPCError("bitcoind", err)
+	}
+	return nil
 }
 
 func (client *bitcoinFNClient) NetworkParams() *chaincfg.Params {
 	return client.params
 }
 
-func floatToInt(val float64) int64 {
-	value, _ := new(big.Float).Mul(new(big.Float).SetFloat64(val), big.NewFloat(10e8)).Int64()
-	return value
+// rawTransactions returns the transactions with the given ids, fetched with a
+// single batched request.
+func (client *bitcoinFNClient) rawTransactions(txIDs []string) (map[chainhash.Hash]*wire.MsgTx, error) {
+	rawTxs, err := client.client2.GetRawTransactions(txIDs)
+	if err != nil {
+		return nil, err
+	}
+	txs := map[chainhash.Hash]*wire.MsgTx{}
+	for _, rawTx := range rawTxs {
+		txBytes, err := hex.DecodeString(rawTx)
+		if err != nil {
+			return nil, err
+		}
+		tx := new(wire.MsgTx)
+		if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+			return nil, err
+		}
+		txs[tx.TxHash()] = tx
+	}
+	return txs, nil
+}
+
+// blockHash returns the hash of the block with the given hash or height.
+func (client *bitcoinFNClient) blockHash(hashOrHeight string) (*chainhash.Hash, error) {
+	hash, height, err := parseBlockID(hashOrHeight)
+	if err != nil || hash != nil {
+		return hash, err
+	}
+	return client.client.GetBlockHash(height)
+}
+
+// publishRPCError maps the error of a JSON-RPC server publishing a transaction
+// to the errors of the errors package. Errors returned by the server are
+// rejections of the transaction, and other errors mean that it is unavailable.
+func publishRPCError(backend string, err error) error {
+	if rpcErr, ok := err.(*btcjson.RPCError); ok {
+		return errors.NewErrBitcoinSubmitTx(rpcErr.Message)
+	}
+	return errors.NewErrBackendUnavailable(backend, err)
 }
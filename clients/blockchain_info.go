@@ -7,13 +7,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
 	"github.com/renproject/libbtc-go/errors"
 )
 
@@ -34,6 +34,8 @@ type Output struct {
 	Value           uint64 `json:"value"`
 	TransactionHash string `json:"hash"`
 	Script          string `json:"script"`
+	Address         string `json:"addr"`
+	Spent           bool   `json:"spent"`
 }
 
 type Transaction struct {
@@ -100,6 +102,7 @@ type UnspentOutput struct {
 	TransactionOutputNumber uint32 `json:"tx_output_n"`
 	ScriptPubKey            string `json:"script"`
 	Amount                  int64  `json:"value"`
+	Confirmations           int64  `json:"confirmations"`
 }
 
 type Unspent struct {
@@ -116,6 +119,9 @@ type LatestBlock struct {
 type blockchainInfoClient struct {
 	URL    string
 	Params *chaincfg.Params
+	logging
+	retrying
+	requesting
 }
 
 func NewBlockchainInfoClientCore(network string) (ClientCore, error) {
@@ -136,24 +142,149 @@ func NewBlockchainInfoClientCore(network string) (ClientCore, error) {
 	}
 }
 
+// blockchainInfoMaxUnspent is the maximum number of outputs returned by a
+// single request to the unspent endpoint of blockchain.info, whose default is
+// 250.
+const blockchainInfoMaxUnspent = 1000
+
+// GetUTXOs returns up to limit outputs of the address, 250 if limit is zero,
+// fetching them in pages of up to 1000 outputs when there are more than
+// blockchain.info returns at once. The offset of the pages is not a documented
+// parameter of the unspent endpoint, so paging stops at the first page that
+// has no new outputs, in case it is ignored.
 func (client *blockchainInfoClient) GetUTXOs(ctx context.Context, address string, limit, confitmations int64) ([]UTXO, error) {
-	unspent, err := client.GetUnspentOutputs(ctx, address, limit, confitmations)
+	if limit == 0 {
+		limit = 250
+	}
+	utxos := []UTXO{}
+	seen := map[string]bool{}
+	for offset := int64(0); offset < limit; offset += blockchainInfoMaxUnspent {
+		pageSize := limit - offset
+		if pageSize > blockchainInfoMaxUnspent {
+			pageSize = blockchainInfoMaxUnspent
+		}
+		page, err := client.GetUTXOPage(ctx, address, offset, pageSize, confitmations)
+		if err != nil {
+			return nil, err
+		}
+		var added int
+		utxos, added = appendNewUTXOs(utxos, seen, page)
+		if int64(len(page)) < pageSize || added == 0 {
+			break
+		}
+	}
+	return utxos, nil
+}
+
+// appendNewUTXOs appends the outputs of the page that are not in seen, and
+// returns how many were appended.
+func appendNewUTXOs(utxos []UTXO, seen map[string]bool, page []UTXO) ([]UTXO, int) {
+	added := 0
+	for _, utxo := range page {
+		outPoint := fmt.Sprintf("%s:%d", utxo.TxHash, utxo.Vout)
+		if seen[outPoint] {
+			continue
+		}
+		seen[outPoint] = true
+		utxos = append(utxos, utxo)
+		added++
+	}
+	return utxos, added
+}
+
+func (client *blockchainInfoClient) GetUTXOPage(ctx context.Context, address string, offset, limit, confitmations int64) ([]UTXO, error) {
+	if limit > blockchainInfoMaxUnspent {
+		limit = blockchainInfoMaxUnspent
+	}
+	unspent, err := client.unspentOutputs(ctx, address, offset, limit, confitmations)
 	if err != nil {
 		return nil, err
 	}
 
+	// The unspent endpoint reports the confirmations of outputs but not
+	// their block height, which is derived from the latest block.
+	var tipHeight int64
+	for _, output := range unspent.Outputs {
+		if output.Confirmations > 0 {
+			latest, err := client.LatestBlock(ctx)
+			if err != nil {
+				return nil, err
+			}
+			tipHeight = latest.Height
+			break
+		}
+	}
+
 	utxos := []UTXO{}
 	for _, output := range unspent.Outputs {
+		if output.Confirmations < confitmations {
+			continue
+		}
 		utxos = append(utxos, UTXO{
-			TxHash:       output.TransactionHash,
-			Amount:       output.Amount,
-			ScriptPubKey: output.ScriptPubKey,
-			Vout:         output.TransactionOutputNumber,
+			TxHash:        output.TransactionHash,
+			Amount:        output.Amount,
+			ScriptPubKey:  output.ScriptPubKey,
+			Vout:          output.TransactionOutputNumber,
+			Confirmations: output.Confirmations,
+			BlockHeight:   utxoBlockHeight(tipHeight, output.Confirmations),
+			Address:       scriptAddress(output.ScriptPubKey, client.Params),
 		})
 	}
 	return utxos, nil
 }
 
+// blockchainInfoMaxAddresses is the number of addresses whose outputs are
+// fetched by a single request to the unspent endpoint of blockchain.info.
+const blockchainInfoMaxAddresses = 100
+
+// GetUTXOsMulti fetches the outputs of up to blockchainInfoMaxAddresses
+// addresses per request. The unspent endpoint does not return the address of
+// outputs, so they are matched to the addresses by their script.
+func (client *blockchainInfoClient) GetUTXOsMulti(ctx context.Context, addresses []string, confirmations int64) (map[string][]UTXO, error) {
+	utxos := make(map[string][]UTXO, len(addresses))
+	scripts := map[string]string{}
+	for _, address := range addresses {
+		addr, err := btcutil.DecodeAddress(address, client.Params)
+		if err != nil {
+			return nil, err
+		}
+		script, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+		scripts[hex.EncodeToString(script)] = address
+		utxos[address] = []UTXO{}
+	}
+
+	for start := 0; start < len(addresses); start += blockchainInfoMaxAddresses {
+		end := start + blockchainInfoMaxAddresses
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+		active := strings.Join(addresses[start:end], "|")
+		// Paging stops at the first page without new outputs, in case the
+		// offset is ignored, as in GetUTXOs.
+		found := []UTXO{}
+		seen := map[string]bool{}
+		for offset := int64(0); ; offset += blockchainInfoMaxUnspent {
+			page, err := client.GetUTXOPage(ctx, active, offset, blockchainInfoMaxUnspent, confirmations)
+			if err != nil {
+				return nil, err
+			}
+			var added int
+			found, added = appendNewUTXOs(found, seen, page)
+			if len(page) < blockchainInfoMaxUnspent || added == 0 {
+				break
+			}
+		}
+		for _, utxo := range found {
+			address := scripts[utxo.ScriptPubKey]
+			utxos[address] = append(utxos[address], utxo)
+		}
+	}
+	return utxos, nil
+}
+
 func (client *blockchainInfoClient) balance(ctx context.Context, address string, confirmations int64) (int64, error) {
 	utxos, err := client.GetUTXOs(ctx, address, 999999, confirmations)
 	if err != nil {
@@ -170,21 +301,21 @@ func (client *blockchainInfoClient) GetUnspentOutputs(ctx context.Context, addre
 	if limit == 0 {
 		limit = 250
 	}
-	utxos := Unspent{}
-	err := backoff(ctx, func() error {
-		resp, err := http.Get(fmt.Sprintf("%s/unspent?active=%s&confirmations=%d&limit=%d", client.URL, address, confitmations, limit))
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
+	return client.unspentOutputs(ctx, address, 0, limit, confitmations)
+}
 
-		respBytes, err := ioutil.ReadAll(resp.Body)
+func (client *blockchainInfoClient) unspentOutputs(ctx context.Context, address string, offset, limit, confitmations int64) (Unspent, error) {
+	utxos := Unspent{}
+	err := backoff(ctx, client.retryPolicy(), client.log(), func() error {
+		respBytes, err := client.get(ctx, fmt.Sprintf("%s/unspent?active=%s&confirmations=%d&limit=%d&offset=%d", client.URL, address, confitmations, limit, offset))
 		if err != nil {
+			// Addresses without outputs are answered with an error.
+			var httpErr HTTPError
+			if errors.As(err, &httpErr) && httpErr.Body == "No free outputs to spend" {
+				return nil
+			}
 			return err
 		}
-		if string(respBytes) == "No free outputs to spend" {
-			return nil
-		}
 		return json.Unmarshal(respBytes, &utxos)
 	})
 	return utxos, err
@@ -192,15 +323,7 @@ func (client *blockchainInfoClient) GetUnspentOutputs(ctx context.Context, addre
 
 func (client *blockchainInfoClient) GetRawTransaction(ctx context.Context, txhash string) (Transaction, error) {
 	transaction := Transaction{}
-	err := backoff(ctx, func() error {
-		resp, err := http.Get(fmt.Sprintf("%s/rawtx/%s", client.URL, txhash))
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-		txBytes, err := ioutil.ReadAll(resp.Body)
-		return json.Unmarshal(txBytes, &transaction)
-	})
+	err := client.getJSON(ctx, fmt.Sprintf("%s/rawtx/%s", client.URL, txhash), &transaction)
 	return transaction, err
 }
 
@@ -221,30 +344,108 @@ func (client *blockchainInfoClient) Confirmations(ctx context.Context, txhash st
 
 func (client *blockchainInfoClient) GetRawAddressInformation(ctx context.Context, addr string) (SingleAddress, error) {
 	addressInfo := SingleAddress{}
-	err := backoff(ctx, func() error {
-		resp, err := http.Get(fmt.Sprintf("%s/rawaddr/%s", client.URL, addr))
+	err := client.getJSON(ctx, fmt.Sprintf("%s/rawaddr/%s", client.URL, addr), &addressInfo)
+	return addressInfo, err
+}
+
+func (client *blockchainInfoClient) LatestBlock(ctx context.Context) (LatestBlock, error) {
+	latestBlock := LatestBlock{}
+	err := client.getJSON(ctx, fmt.Sprintf("%s/latestblock", client.URL), &latestBlock)
+	return latestBlock, err
+}
+
+func (client *blockchainInfoClient) GetRawBlock(ctx context.Context, hash string) (Block, error) {
+	block := Block{}
+	err := client.getJSON(ctx, fmt.Sprintf("%s/rawblock/%s", client.URL, hash), &block)
+	return block, err
+}
+
+func (client *blockchainInfoClient) ChainTip(ctx context.Context) (ChainTip, error) {
+	latest, err := client.LatestBlock(ctx)
+	if err != nil {
+		return ChainTip{}, err
+	}
+
+	// blockchain.info does not expose the median time past, so it is computed
+	// from the timestamps of the latest blocks.
+	timestamps := []int64{}
+	hash := latest.Hash
+	for len(timestamps) < medianTimeBlocks && hash != "" {
+		block, err := client.GetRawBlock(ctx, hash)
+		if err != nil {
+			return ChainTip{}, err
+		}
+		timestamps = append(timestamps, block.Time)
+		hash = block.PreviousBlockHash
+	}
+	medianTime, err := medianTimePast(timestamps)
+	if err != nil {
+		return ChainTip{}, err
+	}
+	return ChainTip{
+		Height:     latest.Height,
+		Hash:       latest.Hash,
+		Time:       latest.Time,
+		MedianTime: medianTime,
+	}, nil
+}
+
+func (client *blockchainInfoClient) GetBlockHeader(ctx context.Context, hashOrHeight string) (*wire.BlockHeader, error) {
+	block, err := client.GetBlock(ctx, hashOrHeight)
+	if err != nil {
+		return nil, err
+	}
+	return &block.Header, nil
+}
+
+func (client *blockchainInfoClient) GetBlock(ctx context.Context, hashOrHeight string) (*wire.MsgBlock, error) {
+	hash, height, err := parseBlockID(hashOrHeight)
+	if err != nil {
+		return nil, err
+	}
+	blockHash := ""
+	if hash != nil {
+		blockHash = hash.String()
+	} else {
+		// Several blocks may have been mined at the height, only one of which
+		// is in the main chain.
+		blocks := Blocks{}
+		if err := client.getJSON(ctx, fmt.Sprintf("%s/block-height/%d?format=json", client.URL, height), &blocks); err != nil {
+			return nil, err
+		}
+		for _, block := range blocks.Blocks {
+			if block.MainChain {
+				blockHash = block.BlockHash
+			}
+		}
+		if blockHash == "" {
+			return nil, fmt.Errorf("no block at height %d", height)
+		}
+	}
+
+	var block *wire.MsgBlock
+	err = backoff(ctx, client.retryPolicy(), client.log(), func() error {
+		blockBytes, err := client.get(ctx, fmt.Sprintf("%s/rawblock/%s?format=hex", client.URL, blockHash))
 		if err != nil {
 			return err
 		}
-		defer resp.Body.Close()
-		addrBytes, err := ioutil.ReadAll(resp.Body)
-		return json.Unmarshal(addrBytes, &addressInfo)
+		block, err = deserializeBlock(string(blockBytes))
+		return err
 	})
-	return addressInfo, err
+	return block, err
 }
 
-func (client *blockchainInfoClient) LatestBlock(ctx context.Context) (LatestBlock, error) {
-	latestBlock := LatestBlock{}
-	err := backoff(ctx, func() error {
-		resp, err := http.Get(fmt.Sprintf("%s/latestblock", client.URL))
+func (client *blockchainInfoClient) RawTransaction(ctx context.Context, txHash string) (*wire.MsgTx, error) {
+	var tx *wire.MsgTx
+	err := backoff(ctx, client.retryPolicy(), client.log(), func() error {
+		txBytes, err := client.get(ctx, fmt.Sprintf("%s/rawtx/%s?format=hex", client.URL, txHash))
 		if err != nil {
 			return err
 		}
-		defer resp.Body.Close()
-		latestBlockBytes, err := ioutil.ReadAll(resp.Body)
-		return json.Unmarshal(latestBlockBytes, &latestBlock)
+		tx, err = deserializeTx(string(txBytes))
+		return err
 	})
-	return latestBlock, err
+	return tx, err
 }
 
 func (client *blockchainInfoClient) PublishTransaction(ctx context.Context, stx *wire.MsgTx) error {
@@ -255,14 +456,8 @@ func (client *blockchainInfoClient) PublishTransaction(ctx context.Context, stx
 	}
 	data := url.Values{}
 	data.Set("tx", hex.EncodeToString(stxBuffer.Bytes()))
-	err := backoff(ctx, func() error {
-		httpClient := &http.Client{}
-		r, err := http.NewRequest("POST", fmt.Sprintf("%s/pushtx", client.URL), strings.NewReader(data.Encode())) // URL-encoded payload
-		if err != nil {
-			return err
-		}
-		r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-		resp, err := httpClient.Do(r)
+	err := backoff(ctx, client.retryPolicy(), client.log(), func() error {
+		resp, err := client.httpPostForm(ctx, fmt.Sprintf("%s/pushtx", client.URL), data)
 		if err != nil {
 			return err
 		}
@@ -272,30 +467,70 @@ func (client *blockchainInfoClient) PublishTransaction(ctx context.Context, stx
 			return err
 		}
 		stxResult := string(stxResultBytes)
-		if strings.Contains(stxResult, "Transaction already exists") || strings.Contains(stxResult, "txn-mempool-conflict") {
+		if strings.Contains(stxResult, "Transaction Submitted") || errors.SubmitTxReason(stxResult) == errors.ErrTxAlreadyInMempool {
 			return nil
 		}
-		if !strings.Contains(stxResult, "Transaction Submitted") {
-			return errors.NewErrBitcoinSubmitTx(stxResult)
-		}
-		return nil
+		return errors.NewErrBitcoinSubmitTx(stxResult)
 	})
 	return err
 }
 
 func (client *blockchainInfoClient) ScriptSpent(ctx context.Context, script, spender string) (bool, string, error) {
+	spend, spent, err := client.ScriptSpendDetails(ctx, script, spender)
+	return spent, spend.SigScript, err
+}
+
+// ScriptSpendDetails finds the input spending from the script, and fetches its
+// transaction to decode its witness.
+func (client *blockchainInfoClient) ScriptSpendDetails(ctx context.Context, script, spender string) (ScriptSpend, bool, error) {
 	addrInfo, err := client.GetRawAddressInformation(ctx, script)
 	if err != nil || addrInfo.Sent == 0 {
-		return false, "", err
+		return ScriptSpend{}, false, err
 	}
 	for _, tx := range addrInfo.Transactions {
 		for i := range tx.Inputs {
 			if tx.Inputs[i].PrevOut.Address == addrInfo.Address {
-				return true, tx.Inputs[i].Script, nil
+				spend, err := rawScriptSpend(ctx, client, tx.TransactionHash, i)
+				return spend, true, err
+			}
+		}
+	}
+	return ScriptSpend{}, true, fmt.Errorf("could not find a spending transaction")
+}
+
+// SpentBy returns the transaction spending the output, and false if the
+// output is unspent. Blockchain.info only tells whether an output is spent,
+// so the spending transaction is looked up in the latest transactions of the
+// address of the output.
+func (client *blockchainInfoClient) SpentBy(ctx context.Context, txHash string, vout uint32) (Spend, bool, error) {
+	tx, err := client.GetRawTransaction(ctx, txHash)
+	if err != nil {
+		return Spend{}, false, err
+	}
+	if int(vout) >= len(tx.Outputs) {
+		return Spend{}, false, fmt.Errorf("transaction %s does not have an output at index %d", txHash, vout)
+	}
+	if !tx.Outputs[vout].Spent {
+		return Spend{}, false, nil
+	}
+
+	addrInfo, err := client.GetRawAddressInformation(ctx, tx.Outputs[vout].Address)
+	if err != nil {
+		return Spend{}, false, err
+	}
+	for _, spendingTx := range addrInfo.Transactions {
+		for _, input := range spendingTx.Inputs {
+			if input.PrevOut.TransactionIndex != tx.TransactionIndex || uint32(input.PrevOut.VoutNumber) != vout {
+				continue
 			}
+			confirmations, err := client.Confirmations(ctx, spendingTx.TransactionHash)
+			if err != nil {
+				return Spend{}, false, err
+			}
+			return Spend{TxHash: spendingTx.TransactionHash, Confirmations: confirmations}, true, nil
 		}
 	}
-	return true, "", fmt.Errorf("could not find a spending transaction")
+	return Spend{}, false, fmt.Errorf("could not find the transaction spending %s:%d", txHash, vout)
 }
 
 func (client *blockchainInfoClient) ScriptFunded(ctx context.Context, address string, value int64) (bool, int64, error) {
@@ -314,28 +549,128 @@ func (client *blockchainInfoClient) ScriptRedeemed(ctx context.Context, address
 	return rawAddress.Received >= value && rawAddress.Balance == 0, rawAddress.Balance, nil
 }
 
+func (client *blockchainInfoClient) ScriptRedemption(ctx context.Context, address string, value int64) (Redemption, error) {
+	rawAddress, err := client.GetRawAddressInformation(ctx, address)
+	if err != nil {
+		return Redemption{}, err
+	}
+	latest, err := client.LatestBlock(ctx)
+	if err != nil {
+		return Redemption{}, err
+	}
+
+	redemption := Redemption{
+		Redeemed: rawAddress.Received >= value && rawAddress.Balance == 0,
+		TxHashes: []string{},
+	}
+	for _, tx := range rawAddress.Transactions {
+		var amount int64
+		for _, input := range tx.Inputs {
+			if input.PrevOut.Address == address {
+				amount += int64(input.PrevOut.Value)
+			}
+		}
+		if amount == 0 {
+			continue
+		}
+
+		var conf int64
+		if tx.BlockHeight != 0 {
+			conf = 1 + (latest.Height - tx.BlockHeight)
+		}
+		if len(redemption.TxHashes) == 0 || conf < redemption.Confirmations {
+			redemption.Confirmations = conf
+		}
+		redemption.TxHashes = append(redemption.TxHashes, tx.TransactionHash)
+		redemption.Amount += amount
+	}
+	return redemption, nil
+}
+
+// ScriptHistory returns the latest transactions of the address, as
+// blockchain.info only returns the latest 50 transactions of addresses.
+func (client *blockchainInfoClient) ScriptHistory(ctx context.Context, address string) ([]ScriptTx, error) {
+	rawAddress, err := client.GetRawAddressInformation(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := client.LatestBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]ScriptTx, 0, len(rawAddress.Transactions))
+	for _, tx := range rawAddress.Transactions {
+		scriptTx := ScriptTx{TxHash: tx.TransactionHash}
+		if tx.BlockHeight != 0 {
+			scriptTx.Confirmations = 1 + (latest.Height - tx.BlockHeight)
+		}
+		for _, input := range tx.Inputs {
+			if input.PrevOut.Address == address {
+				scriptTx.Spent += int64(input.PrevOut.Value)
+			}
+		}
+		for _, output := range tx.Outputs {
+			if output.Address == address {
+				scriptTx.Received += int64(output.Value)
+			}
+		}
+		history = append(history, scriptTx)
+	}
+	return history, nil
+}
+
 func (client *blockchainInfoClient) NetworkParams() *chaincfg.Params {
 	return client.Params
 }
 
 func (client *blockchainInfoClient) GetUTXO(ctx context.Context, txhash string, vout uint32) (UTXO, error) {
-	panic("unimplemented")
-}
-
-func backoff(ctx context.Context, f func() error) error {
-	duration := time.Duration(1000)
-	for {
-		select {
-		case <-ctx.Done():
-			return errors.ErrTimedOut
-		default:
-			err := f()
-			if err == nil {
-				return nil
-			}
-			fmt.Printf("Error: %v, will try again in %d sec\n", err, duration)
-			time.Sleep(duration * time.Millisecond)
-			duration = time.Duration(float64(duration) * 1.6)
+	tx, err := client.GetRawTransaction(ctx, txhash)
+	if err != nil {
+		return UTXO{}, err
+	}
+	if int(vout) >= len(tx.Outputs) {
+		return UTXO{}, fmt.Errorf("transaction %s does not have an output at index %d", txhash, vout)
+	}
+	confirmations, err := client.Confirmations(ctx, txhash)
+	if err != nil {
+		return UTXO{}, err
+	}
+	return UTXO{
+		TxHash:        txhash,
+		Amount:        int64(tx.Outputs[vout].Value),
+		ScriptPubKey:  tx.Outputs[vout].Script,
+		Vout:          vout,
+		Confirmations: confirmations,
+		BlockHeight:   tx.BlockHeight,
+		Address:       tx.Outputs[vout].Address,
+	}, nil
+}
+
+func (client *blockchainInfoClient) getJSON(ctx context.Context, url string, response interface{}) error {
+	return backoff(ctx, client.retryPolicy(), client.log(), func() error {
+		respBytes, err := client.get(ctx, url)
+		if err != nil {
+			return err
 		}
+		return json.Unmarshal(respBytes, response)
+	})
+}
+
+// get returns the body of the response to the GET request, or an HTTPError
+// if its status is not a success, so that client errors are not retried.
+func (client *blockchainInfoClient) get(ctx context.Context, url string) ([]byte, error) {
+	resp, err := client.httpGet(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, HTTPError{resp.StatusCode, strings.TrimSpace(string(respBytes))}
 	}
+	return respBytes, nil
 }
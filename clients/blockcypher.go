@@ -0,0 +1,500 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+type BlockCypherTxRef struct {
+	TxHash        string `json:"tx_hash"`
+	BlockHeight   int64  `json:"block_height"`
+	TxInputN      int64  `json:"tx_input_n"`
+	TxOutputN     int64  `json:"tx_output_n"`
+	Value         int64  `json:"value"`
+	Confirmations int64  `json:"confirmations"`
+	Script        string `json:"script"`
+	Spent         bool   `json:"spent"`
+}
+
+type BlockCypherAddress struct {
+	Address            string             `json:"address"`
+	TotalReceived      int64              `json:"total_received"`
+	TotalSent          int64              `json:"total_sent"`
+	Balance            int64              `json:"balance"`
+	UnconfirmedBalance int64              `json:"unconfirmed_balance"`
+	FinalBalance       int64              `json:"final_balance"`
+	TxRefs             []BlockCypherTxRef `json:"txrefs"`
+	UnconfirmedTxRefs  []BlockCypherTxRef `json:"unconfirmed_txrefs"`
+	Txs                []BlockCypherTx    `json:"txs"`
+	HasMore            bool               `json:"hasMore"`
+}
+
+type BlockCypherInput struct {
+	PrevHash    string   `json:"prev_hash"`
+	OutputIndex uint32   `json:"output_index"`
+	OutputValue int64    `json:"output_value"`
+	Script      string   `json:"script"`
+	Addresses   []string `json:"addresses"`
+	Sequence    uint32   `json:"sequence"`
+	Witness     []string `json:"witness"`
+}
+
+type BlockCypherOutput struct {
+	Value     int64    `json:"value"`
+	Script    string   `json:"script"`
+	Addresses []string `json:"addresses"`
+	SpentBy   string   `json:"spent_by"`
+}
+
+type BlockCypherTx struct {
+	Hash          string              `json:"hash"`
+	BlockHeight   int64               `json:"block_height"`
+	Confirmations int64               `json:"confirmations"`
+	Fees          int64               `json:"fees"`
+	Inputs        []BlockCypherInput  `json:"inputs"`
+	Outputs       []BlockCypherOutput `json:"outputs"`
+	Hex           string              `json:"hex"`
+}
+
+type blockCypherError struct {
+	Error string `json:"error"`
+}
+
+type blockCypherClient struct {
+	URL    string
+	Token  string
+	Params *chaincfg.Params
+	logging
+	retrying
+	requesting
+}
+
+// NewBlockCypherClientCore returns a ClientCore backed by the BlockCypher API.
+// The token is optional, requests without a token are subject to lower rate
+// limits.
+func NewBlockCypherClientCore(network, token string) (ClientCore, error) {
+	network = strings.ToLower(network)
+	switch network {
+	case "mainnet":
+		return &blockCypherClient{
+			URL:    "https://api.blockcypher.com/v1/btc/main",
+			Token:  token,
+			Params: &chaincfg.MainNetParams,
+		}, nil
+	case "testnet", "testnet3", "":
+		return &blockCypherClient{
+			URL:    "https://api.blockcypher.com/v1/btc/test3",
+			Token:  token,
+			Params: &chaincfg.TestNet3Params,
+		}, nil
+	default:
+		return nil, errors.NewErrUnsupportedNetwork(network)
+	}
+}
+
+func (client *blockCypherClient) NetworkParams() *chaincfg.Params {
+	return client.Params
+}
+
+func (client *blockCypherClient) GetUTXOs(ctx context.Context, address string, limit, confitmations int64) ([]UTXO, error) {
+	if limit == 0 || limit > 2000 {
+		limit = 2000
+	}
+	addressInfo := BlockCypherAddress{}
+	query := url.Values{}
+	query.Set("unspentOnly", "true")
+	query.Set("includeScript", "true")
+	query.Set("limit", strconv.FormatInt(limit, 10))
+	if err := client.get(ctx, fmt.Sprintf("/addrs/%s", address), query, &addressInfo); err != nil {
+		return nil, err
+	}
+
+	utxos := []UTXO{}
+	for _, txRef := range append(addressInfo.TxRefs, addressInfo.UnconfirmedTxRefs...) {
+		if txRef.Confirmations < confitmations || int64(len(utxos)) >= limit {
+			continue
+		}
+		utxos = append(utxos, UTXO{
+			TxHash:        txRef.TxHash,
+			Amount:        txRef.Value,
+			ScriptPubKey:  txRef.Script,
+			Vout:          uint32(txRef.TxOutputN),
+			Confirmations: txRef.Confirmations,
+			BlockHeight:   confirmedHeight(txRef.BlockHeight),
+			Address:       address,
+		})
+	}
+	return utxos, nil
+}
+
+func (client *blockCypherClient) GetUTXO(ctx context.Context, txHash string, vout uint32) (UTXO, error) {
+	tx, err := client.GetTransaction(ctx, txHash)
+	if err != nil {
+		return UTXO{}, err
+	}
+	if int(vout) >= len(tx.Outputs) {
+		return UTXO{}, fmt.Errorf("transaction %s does not have an output at index %d", txHash, vout)
+	}
+	confirmations, err := client.Confirmations(ctx, txHash)
+	if err != nil {
+		return UTXO{}, err
+	}
+	return UTXO{
+		TxHash:        txHash,
+		Amount:        tx.Outputs[vout].Value,
+		ScriptPubKey:  tx.Outputs[vout].Script,
+		Vout:          vout,
+		Confirmations: confirmations,
+		BlockHeight:   confirmedHeight(tx.BlockHeight),
+		Address:       scriptAddress(tx.Outputs[vout].Script, client.Params),
+	}, nil
+}
+
+func (client *blockCypherClient) Confirmations(ctx context.Context, txHash string) (int64, error) {
+	tx, err := client.GetTransaction(ctx, txHash)
+	if err != nil {
+		return 0, err
+	}
+	return tx.Confirmations, nil
+}
+
+func (client *blockCypherClient) ScriptFunded(ctx context.Context, address string, value int64) (bool, int64, error) {
+	addressInfo, err := client.GetAddressBalance(ctx, address)
+	if err != nil {
+		return false, 0, err
+	}
+	return addressInfo.TotalReceived >= value, addressInfo.TotalReceived, nil
+}
+
+func (client *blockCypherClient) ScriptRedeemed(ctx context.Context, address string, value int64) (bool, int64, error) {
+	addressInfo, err := client.GetAddressBalance(ctx, address)
+	if err != nil {
+		return false, 0, err
+	}
+	return addressInfo.TotalReceived >= value && addressInfo.FinalBalance == 0, addressInfo.FinalBalance, nil
+}
+
+func (client *blockCypherClient) ScriptRedemption(ctx context.Context, address string, value int64) (Redemption, error) {
+	addressInfo, err := client.GetAddressTransactions(ctx, address)
+	if err != nil {
+		return Redemption{}, err
+	}
+
+	redemption := Redemption{
+		Redeemed: addressInfo.TotalReceived >= value && addressInfo.FinalBalance == 0,
+		TxHashes: []string{},
+	}
+	for _, tx := range addressInfo.Txs {
+		var amount int64
+		for _, input := range tx.Inputs {
+			if containsAddress(input.Addresses, address) {
+				amount += input.OutputValue
+			}
+		}
+		if amount == 0 {
+			continue
+		}
+		if len(redemption.TxHashes) == 0 || tx.Confirmations < redemption.Confirmations {
+			redemption.Confirmations = tx.Confirmations
+		}
+		redemption.TxHashes = append(redemption.TxHashes, tx.Hash)
+		redemption.Amount += amount
+	}
+	return redemption, nil
+}
+
+func (client *blockCypherClient) ScriptHistory(ctx context.Context, address string) ([]ScriptTx, error) {
+	addressInfo, err := client.GetAddressTransactions(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]ScriptTx, 0, len(addressInfo.Txs))
+	for _, tx := range addressInfo.Txs {
+		scriptTx := ScriptTx{
+			TxHash:        tx.Hash,
+			Confirmations: tx.Confirmations,
+		}
+		for _, input := range tx.Inputs {
+			if containsAddress(input.Addresses, address) {
+				scriptTx.Spent += input.OutputValue
+			}
+		}
+		for _, output := range tx.Outputs {
+			if containsAddress(output.Addresses, address) {
+				scriptTx.Received += output.Value
+			}
+		}
+		history = append(history, scriptTx)
+	}
+	return history, nil
+}
+
+func (client *blockCypherClient) ScriptSpent(ctx context.Context, script, spender string) (bool, string, error) {
+	spend, spent, err := client.ScriptSpendDetails(ctx, script, spender)
+	return spent, spend.SigScript, err
+}
+
+func (client *blockCypherClient) ScriptSpendDetails(ctx context.Context, script, spender string) (ScriptSpend, bool, error) {
+	addressInfo, err := client.GetAddressTransactions(ctx, script)
+	if err != nil {
+		return ScriptSpend{}, false, err
+	}
+	for _, tx := range addressInfo.Txs {
+		for i, input := range tx.Inputs {
+			if containsAddress(input.Addresses, script) {
+				return ScriptSpend{
+					TxHash:     tx.Hash,
+					InputIndex: uint32(i),
+					SigScript:  input.Script,
+					Witness:    input.Witness,
+				}, true, nil
+			}
+		}
+	}
+	return ScriptSpend{}, false, nil
+}
+
+// SpentBy returns the transaction spending the output, and false if the
+// output is unspent.
+func (client *blockCypherClient) SpentBy(ctx context.Context, txHash string, vout uint32) (Spend, bool, error) {
+	tx, err := client.GetTransaction(ctx, txHash)
+	if err != nil {
+		return Spend{}, false, err
+	}
+	if int(vout) >= len(tx.Outputs) {
+		return Spend{}, false, fmt.Errorf("transaction %s does not have an output at index %d", txHash, vout)
+	}
+	spentBy := tx.Outputs[vout].SpentBy
+	if spentBy == "" {
+		return Spend{}, false, nil
+	}
+	confirmations, err := client.Confirmations(ctx, spentBy)
+	if err != nil {
+		return Spend{}, false, err
+	}
+	return Spend{TxHash: spentBy, Confirmations: confirmations}, true, nil
+}
+
+// GetBlockHeader returns the header of the block, rebuilt from its fields and
+// checked against its hash.
+func (client *blockCypherClient) GetBlockHeader(ctx context.Context, hashOrHeight string) (*wire.BlockHeader, error) {
+	if _, _, err := parseBlockID(hashOrHeight); err != nil {
+		return nil, err
+	}
+	block := struct {
+		Hash       string    `json:"hash"`
+		Version    int32     `json:"ver"`
+		PrevBlock  string    `json:"prev_block"`
+		MerkleRoot string    `json:"mrkl_root"`
+		Time       time.Time `json:"time"`
+		Bits       uint32    `json:"bits"`
+		Nonce      uint32    `json:"nonce"`
+	}{}
+	query := url.Values{}
+	query.Set("limit", "1")
+	if err := client.get(ctx, fmt.Sprintf("/blocks/%s", hashOrHeight), query, &block); err != nil {
+		return nil, err
+	}
+
+	prevBlock, err := chainhash.NewHashFromStr(block.PrevBlock)
+	if err != nil {
+		return nil, err
+	}
+	merkleRoot, err := chainhash.NewHashFromStr(block.MerkleRoot)
+	if err != nil {
+		return nil, err
+	}
+	header := wire.NewBlockHeader(block.Version, prevBlock, merkleRoot, block.Bits, block.Nonce)
+	header.Timestamp = block.Time
+	if hash := header.BlockHash(); hash.String() != block.Hash {
+		return nil, fmt.Errorf("header of block %s hashes to %s", block.Hash, hash)
+	}
+	return header, nil
+}
+
+func (client *blockCypherClient) RawTransaction(ctx context.Context, txHash string) (*wire.MsgTx, error) {
+	tx := BlockCypherTx{}
+	query := url.Values{}
+	query.Set("includeHex", "true")
+	if err := client.get(ctx, fmt.Sprintf("/txs/%s", txHash), query, &tx); err != nil {
+		return nil, err
+	}
+	return deserializeTx(tx.Hex)
+}
+
+func (client *blockCypherClient) PublishTransaction(ctx context.Context, stx *wire.MsgTx) error {
+	var stxBuffer bytes.Buffer
+	stxBuffer.Grow(stx.SerializeSize())
+	if err := stx.Serialize(&stxBuffer); err != nil {
+		return err
+	}
+	reqBytes, err := json.Marshal(struct {
+		Tx string `json:"tx"`
+	}{hex.EncodeToString(stxBuffer.Bytes())})
+	if err != nil {
+		return err
+	}
+
+	return backoff(ctx, client.retryPolicy(), client.log(), func() error {
+		resp, err := client.httpPost(ctx, client.url("/txs/push", nil), "application/json", bytes.NewReader(reqBytes))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		respBytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return blockCypherRateLimited(resp)
+		}
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+			respErr := blockCypherError{}
+			if err := json.Unmarshal(respBytes, &respErr); err != nil || respErr.Error == "" {
+				return errors.NewErrBitcoinSubmitTx(string(respBytes))
+			}
+			if errors.SubmitTxReason(respErr.Error) == errors.ErrTxAlreadyInMempool {
+				return nil
+			}
+			return errors.NewErrBitcoinSubmitTx(respErr.Error)
+		}
+		return nil
+	})
+}
+
+func (client *blockCypherClient) ChainTip(ctx context.Context) (ChainTip, error) {
+	chain := struct {
+		Height int64  `json:"height"`
+		Hash   string `json:"hash"`
+	}{}
+	if err := client.get(ctx, "", nil, &chain); err != nil {
+		return ChainTip{}, err
+	}
+
+	// BlockCypher does not expose the median time past, so it is computed
+	// from the timestamps of the latest blocks.
+	var tipTime int64
+	timestamps := []int64{}
+	hash := chain.Hash
+	for len(timestamps) < medianTimeBlocks && hash != "" {
+		block := struct {
+			Time      time.Time `json:"time"`
+			PrevBlock string    `json:"prev_block"`
+		}{}
+		query := url.Values{}
+		query.Set("limit", "1")
+		if err := client.get(ctx, fmt.Sprintf("/blocks/%s", hash), query, &block); err != nil {
+			return ChainTip{}, err
+		}
+		if len(timestamps) == 0 {
+			tipTime = block.Time.Unix()
+		}
+		timestamps = append(timestamps, block.Time.Unix())
+		hash = block.PrevBlock
+	}
+	medianTime, err := medianTimePast(timestamps)
+	if err != nil {
+		return ChainTip{}, err
+	}
+	return ChainTip{
+		Height:     chain.Height,
+		Hash:       chain.Hash,
+		Time:       tipTime,
+		MedianTime: medianTime,
+	}, nil
+}
+
+// GetTransaction returns the transaction with the given hash.
+func (client *blockCypherClient) GetTransaction(ctx context.Context, txHash string) (BlockCypherTx, error) {
+	tx := BlockCypherTx{}
+	query := url.Values{}
+	query.Set("includeHex", "false")
+	err := client.get(ctx, fmt.Sprintf("/txs/%s", txHash), query, &tx)
+	return tx, err
+}
+
+// GetAddressBalance returns the received, sent and final balance of an
+// address.
+func (client *blockCypherClient) GetAddressBalance(ctx context.Context, address string) (BlockCypherAddress, error) {
+	addressInfo := BlockCypherAddress{}
+	err := client.get(ctx, fmt.Sprintf("/addrs/%s/balance", address), nil, &addressInfo)
+	return addressInfo, err
+}
+
+// GetAddressTransactions returns the address balance along with the most
+// recent transactions funding or spending from it.
+func (client *blockCypherClient) GetAddressTransactions(ctx context.Context, address string) (BlockCypherAddress, error) {
+	addressInfo := BlockCypherAddress{}
+	query := url.Values{}
+	query.Set("limit", "50")
+	err := client.get(ctx, fmt.Sprintf("/addrs/%s/full", address), query, &addressInfo)
+	return addressInfo, err
+}
+
+func (client *blockCypherClient) get(ctx context.Context, path string, query url.Values, response interface{}) error {
+	return backoff(ctx, client.retryPolicy(), client.log(), func() error {
+		resp, err := client.httpGet(ctx, client.url(path, query))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return blockCypherRateLimited(resp)
+		}
+		respBytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return HTTPError{resp.StatusCode, string(respBytes)}
+		}
+		return json.Unmarshal(respBytes, response)
+	})
+}
+
+func (client *blockCypherClient) url(path string, query url.Values) string {
+	if query == nil {
+		query = url.Values{}
+	}
+	if client.Token != "" {
+		query.Set("token", client.Token)
+	}
+	if len(query) == 0 {
+		return client.URL + path
+	}
+	return fmt.Sprintf("%s%s?%s", client.URL, path, query.Encode())
+}
+
+// blockCypherRateLimited waits for the duration requested by the Retry-After
+// header of a rate limited response, and returns an error so that the request
+// is retried.
+func blockCypherRateLimited(resp *http.Response) error {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		time.Sleep(time.Duration(seconds) * time.Second)
+	}
+	return fmt.Errorf("rate limited by blockcypher")
+}
+
+func containsAddress(addresses []string, address string) bool {
+	for _, addr := range addresses {
+		if addr == address {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package clients_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go/clients"
+)
+
+const (
+	blockCypherAddress = "tb1q63gxjpemqpj4sq9gchdtf2ggc5zr0j98649d0s"
+	blockCypherScript  = "0014d45069073b00655800a8c5dab4a908c50437c8a7"
+	blockCypherTxHash  = "9c2ac5a7e5b4bf2c7ef7f0a6ce1d2a1c4a6c7a0b2e3f40516273849506a7b8c9"
+)
+
+// blockCypherUnspentFixture is the response of BlockCypher listing the
+// unspent outputs of blockCypherAddress, a confirmed one and an unconfirmed
+// one.
+const blockCypherUnspentFixture = `{
+	"address": "` + blockCypherAddress + `",
+	"total_received": 70000,
+	"total_sent": 0,
+	"balance": 50000,
+	"unconfirmed_balance": 20000,
+	"final_balance": 70000,
+	"txrefs": [
+		{"tx_hash": "` + blockCypherTxHash + `", "block_height": 1900001, "tx_input_n": -1, "tx_output_n": 1, "value": 50000, "confirmations": 10, "script": "` + blockCypherScript + `", "spent": false}
+	],
+	"unconfirmed_txrefs": [
+		{"tx_hash": "1d2e3f405162738495a6b7c8d9eafb0c1d2e3f405162738495a6b7c8d9eafb0c", "block_height": -1, "tx_input_n": -1, "tx_output_n": 0, "value": 20000, "confirmations": 0, "script": "` + blockCypherScript + `", "spent": false}
+	]
+}`
+
+// blockCypherTxFixture is the transaction of the confirmed output of
+// blockCypherUnspentFixture.
+const blockCypherTxFixture = `{
+	"hash": "` + blockCypherTxHash + `",
+	"block_height": 1900001,
+	"confirmations": 10,
+	"fees": 1000,
+	"inputs": [],
+	"outputs": [
+		{"value": 10000, "script": "76a914d45069073b00655800a8c5dab4a908c50437c8a788ac", "addresses": ["mzsZsj2HvcPZ8cqWoYmLBcHz6rHjS8LKk5"]},
+		{"value": 50000, "script": "` + blockCypherScript + `", "addresses": ["` + blockCypherAddress + `"]}
+	]
+}`
+
+var _ = Describe("BlockCypher client", func() {
+	newClient := func(server *fixtureServer) ClientCore {
+		client, err := NewBlockCypherClientCore("testnet", "secret")
+		Expect(err).ShouldNot(HaveOccurred())
+		client.(HTTPClientSetter).SetHTTPClient(server.redirectingClient())
+		return client
+	}
+
+	It("should map the unspent outputs and their confirmations", func() {
+		server := newFixtureServer(map[string]string{
+			"/v1/btc/test3/addrs/" + blockCypherAddress: blockCypherUnspentFixture,
+		})
+		defer server.Close()
+		client := newClient(server)
+
+		utxos, err := client.GetUTXOs(context.Background(), blockCypherAddress, 0, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(utxos).Should(Equal([]UTXO{
+			{TxHash: blockCypherTxHash, Amount: 50000, ScriptPubKey: blockCypherScript, Vout: 1, Confirmations: 10, BlockHeight: 1900001, Address: blockCypherAddress},
+			{TxHash: "1d2e3f405162738495a6b7c8d9eafb0c1d2e3f405162738495a6b7c8d9eafb0c", Amount: 20000, ScriptPubKey: blockCypherScript, Vout: 0, Address: blockCypherAddress},
+		}))
+		query := server.queries()[0]
+		Expect(query.Get("token")).Should(Equal("secret"))
+		Expect(query.Get("unspentOnly")).Should(Equal("true"))
+		Expect(query.Get("includeScript")).Should(Equal("true"))
+		Expect(query.Get("limit")).Should(Equal("2000"))
+
+		confirmed, err := client.GetUTXOs(context.Background(), blockCypherAddress, 0, 1)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(confirmed).Should(Equal(utxos[:1]))
+		limited, err := client.GetUTXOs(context.Background(), blockCypherAddress, 1, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(limited).Should(Equal(utxos[:1]))
+		Expect(server.queries()[2].Get("limit")).Should(Equal("1"))
+	})
+
+	It("should map an output of a transaction and its confirmations", func() {
+		server := newFixtureServer(map[string]string{
+			"/v1/btc/test3/txs/" + blockCypherTxHash: blockCypherTxFixture,
+		})
+		defer server.Close()
+		client := newClient(server)
+
+		confirmations, err := client.Confirmations(context.Background(), blockCypherTxHash)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(confirmations).Should(Equal(int64(10)))
+
+		utxo, err := client.GetUTXO(context.Background(), blockCypherTxHash, 1)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(utxo).Should(Equal(UTXO{TxHash: blockCypherTxHash, Amount: 50000, ScriptPubKey: blockCypherScript, Vout: 1, Confirmations: 10, BlockHeight: 1900001, Address: blockCypherAddress}))
+		_, err = client.GetUTXO(context.Background(), blockCypherTxHash, 2)
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should fail without retrying when the transaction is not found", func() {
+		server := newFixtureServer(map[string]string{})
+		defer server.Close()
+		client := newClient(server)
+
+		_, err := client.Confirmations(context.Background(), blockCypherTxHash)
+		Expect(err).Should(HaveOccurred())
+		Expect(server.requested()).Should(HaveLen(1))
+	})
+})
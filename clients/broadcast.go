@@ -0,0 +1,39 @@
+package clients
+
+import (
+	"context"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// BroadcastAll publishes the transaction through every given backend
+// concurrently, so that it propagates even if some of them are down. It
+// succeeds if at least one of the backends accepts the transaction or already
+// knows about it, otherwise it returns the last error.
+func BroadcastAll(ctx context.Context, stx *wire.MsgTx, cores ...ClientCore) error {
+	if len(cores) == 0 {
+		return errors.ErrNoBackends
+	}
+	errs := make(chan error, len(cores))
+	for _, core := range cores {
+		go func(core ClientCore) {
+			errs <- core.PublishTransaction(ctx, stx)
+		}(core)
+	}
+
+	var err error
+	for range cores {
+		publishErr := <-errs
+		if publishErr == nil || isAlreadyInMempool(publishErr) {
+			return nil
+		}
+		err = publishErr
+	}
+	return err
+}
+
+func isAlreadyInMempool(err error) bool {
+	submitErr, ok := err.(*errors.SubmitTxError)
+	return ok && submitErr.Reason == errors.ErrTxAlreadyInMempool
+}
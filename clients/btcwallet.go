@@ -0,0 +1,323 @@
+package clients
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// BtcWalletClientCore is a ClientCore backed by a btcwallet instance, which
+// can also sign transactions spending the outputs owned by the wallet.
+type BtcWalletClientCore interface {
+	ClientCore
+
+	// SignTransaction signs every input of the transaction that spends an
+	// output owned by the wallet. The wallet must be unlocked.
+	SignTransaction(ctx context.Context, msgTx *wire.MsgTx) (*wire.MsgTx, error)
+}
+
+type btcWalletClient struct {
+	client *rpcclient.Client
+	params *chaincfg.Params
+}
+
+// NewBtcWalletClientCore returns a client connected to the JSON-RPC server of
+// btcwallet at the given host, using the PEM-encoded certificate of the
+// server. Chain queries are forwarded by btcwallet to its btcd backend, which
+// must have the transaction index enabled. btcwallet does not watch arbitrary
+// scripts, so address queries are only answered for addresses of the wallet.
+func NewBtcWalletClientCore(host, user, password string, cert []byte) (BtcWalletClientCore, error) {
+	client, err := newBtcWalletRPCClient(host, user, password, cert)
+	if err != nil {
+		return nil, err
+	}
+
+	net, err := client.GetCurrentNet()
+	if err != nil {
+		return nil, err
+	}
+	var params *chaincfg.Params
+	switch net {
+	case wire.MainNet:
+		params = &chaincfg.MainNetParams
+	case wire.TestNet3:
+		params = &chaincfg.TestNet3Params
+	case wire.TestNet:
+		params = &chaincfg.RegressionNetParams
+	case wire.SimNet:
+		params = &chaincfg.SimNetParams
+	default:
+		return nil, fmt.Errorf("unsupported bitcoin network: %s", net)
+	}
+
+	return &btcWalletClient{
+		client: client,
+		params: params,
+	}, nil
+}
+
+// NewBtcWalletClientCoreWithParams is like NewBtcWalletClientCore, for a
+// btcwallet of the network described by the given parameters instead of one
+// of the networks known to btcd.
+func NewBtcWalletClientCoreWithParams(host, user, password string, cert []byte, params *chaincfg.Params) (BtcWalletClientCore, error) {
+	client, err := newBtcWalletRPCClient(host, user, password, cert)
+	if err != nil {
+		return nil, err
+	}
+	return &btcWalletClient{
+		client: client,
+		params: params,
+	}, nil
+}
+
+func newBtcWalletRPCClient(host, user, password string, cert []byte) (*rpcclient.Client, error) {
+	return rpcclient.New(
+		&rpcclient.ConnConfig{
+			Host:         host,
+			User:         user,
+			Pass:         password,
+			HTTPPostMode: true,
+			Certificates: cert,
+			DisableTLS:   len(cert) == 0,
+		},
+		nil,
+	)
+}
+
+func (client *btcWalletClient) NetworkParams() *chaincfg.Params {
+	return client.params
+}
+
+func (client *btcWalletClient) GetUTXOs(ctx context.Context, address string, limit, confitmations int64) ([]UTXO, error) {
+	addr, err := btcutil.DecodeAddress(address, client.params)
+	if err != nil {
+		return nil, err
+	}
+	unspents, err := client.client.ListUnspentMinMaxAddresses(int(confitmations), 9999999, []btcutil.Address{addr})
+	if err != nil {
+		return nil, err
+	}
+
+	tipHeight, err := client.client.GetBlockCount()
+	if err != nil {
+		return nil, err
+	}
+
+	utxos := []UTXO{}
+	for _, unspent := range unspents {
+		if limit > 0 && int64(len(utxos)) >= limit {
+			break
+		}
+		utxo, err := listUnspentUTXO(unspent, tipHeight, BTCToSatoshi)
+		if err != nil {
+			return nil, err
+		}
+		utxos = append(utxos, utxo)
+	}
+	return utxos, nil
+}
+
+// GetUTXOsMulti lists the outputs of every address with a single call to
+// listunspent.
+func (client *btcWalletClient) GetUTXOsMulti(ctx context.Context, addresses []string, confirmations int64) (map[string][]UTXO, error) {
+	addrs := make([]btcutil.Address, len(addresses))
+	for i, address := range addresses {
+		addr, err := btcutil.DecodeAddress(address, client.params)
+		if err != nil {
+			return nil, err
+		}
+		addrs[i] = addr
+	}
+	unspents, err := client.client.ListUnspentMinMaxAddresses(int(confirmations), 9999999, addrs)
+	if err != nil {
+		return nil, err
+	}
+	tipHeight, err := client.client.GetBlockCount()
+	if err != nil {
+		return nil, err
+	}
+	return utxosByAddress(addresses, unspents, tipHeight, BTCToSatoshi)
+}
+
+func (client *btcWalletClient) GetUTXO(ctx context.Context, txHash string, vout uint32) (UTXO, error) {
+	hash, err := chainhash.NewHashFromStr(txHash)
+	if err != nil {
+		return UTXO{}, err
+	}
+	tx, err := client.client.GetRawTransaction(hash)
+	if err != nil {
+		return UTXO{}, err
+	}
+	txOuts := tx.MsgTx().TxOut
+	if int(vout) >= len(txOuts) {
+		return UTXO{}, fmt.Errorf("transaction %s does not have an output at index %d", txHash, vout)
+	}
+	confirmations, err := client.Confirmations(ctx, txHash)
+	if err != nil {
+		return UTXO{}, err
+	}
+	tipHeight, err := client.client.GetBlockCount()
+	if err != nil {
+		return UTXO{}, err
+	}
+	scriptPubKey := hex.EncodeToString(txOuts[vout].PkScript)
+	return UTXO{
+		TxHash:        txHash,
+		Amount:        txOuts[vout].Value,
+		ScriptPubKey:  scriptPubKey,
+		Vout:          vout,
+		Confirmations: confirmations,
+		BlockHeight:   utxoBlockHeight(tipHeight, confirmations),
+		Address:       scriptAddress(scriptPubKey, client.params),
+	}, nil
+}
+
+func (client *btcWalletClient) Confirmations(ctx context.Context, txHash string) (int64, error) {
+	hash, err := chainhash.NewHashFromStr(txHash)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := client.client.GetRawTransactionVerbose(hash)
+	if err != nil {
+		return 0, err
+	}
+	return int64(tx.Confirmations), nil
+}
+
+func (client *btcWalletClient) ScriptFunded(ctx context.Context, address string, value int64) (bool, int64, error) {
+	received, err := client.received(address)
+	if err != nil {
+		return false, 0, err
+	}
+	return received >= value, received, nil
+}
+
+func (client *btcWalletClient) ScriptRedeemed(ctx context.Context, address string, value int64) (bool, int64, error) {
+	received, err := client.received(address)
+	if err != nil {
+		return false, 0, err
+	}
+	utxos, err := client.GetUTXOs(ctx, address, 0, 0)
+	if err != nil {
+		return false, 0, err
+	}
+	var balance int64
+	for _, utxo := range utxos {
+		balance += utxo.Amount
+	}
+	return received >= value && balance == 0, balance, nil
+}
+
+func (client *btcWalletClient) ScriptSpent(ctx context.Context, script, spender string) (bool, string, error) {
+	return false, "", errors.NewErrUnsupportedOperation("ScriptSpent", "btcwallet")
+}
+
+func (client *btcWalletClient) ChainTip(ctx context.Context) (ChainTip, error) {
+	hash, height, err := client.client.GetBestBlock()
+	if err != nil {
+		return ChainTip{}, err
+	}
+
+	// btcwallet does not expose the median time past, so it is computed from
+	// the timestamps of the latest blocks.
+	var tip *wire.BlockHeader
+	timestamps := []int64{}
+	for len(timestamps) < medianTimeBlocks {
+		header, err := client.client.GetBlockHeader(hash)
+		if err != nil {
+			return ChainTip{}, err
+		}
+		if tip == nil {
+			tip = header
+		}
+		timestamps = append(timestamps, header.Timestamp.Unix())
+		if header.PrevBlock == (chainhash.Hash{}) {
+			break
+		}
+		hash = &header.PrevBlock
+	}
+	medianTime, err := medianTimePast(timestamps)
+	if err != nil {
+		return ChainTip{}, err
+	}
+	return ChainTip{
+		Height:     int64(height),
+		Hash:       tip.BlockHash().String(),
+		Time:       tip.Timestamp.Unix(),
+		MedianTime: medianTime,
+	}, nil
+}
+
+func (client *btcWalletClient) GetBlockHeader(ctx context.Context, hashOrHeight string) (*wire.BlockHeader, error) {
+	hash, err := client.blockHash(hashOrHeight)
+	if err != nil {
+		return nil, err
+	}
+	return client.client.GetBlockHeader(hash)
+}
+
+func (client *btcWalletClient) GetBlock(ctx context.Context, hashOrHeight string) (*wire.MsgBlock, error) {
+	hash, err := client.blockHash(hashOrHeight)
+	if err != nil {
+		return nil, err
+	}
+	return client.client.GetBlock(hash)
+}
+
+func (client *btcWalletClient) RawTransaction(ctx context.Context, txHash string) (*wire.MsgTx, error) {
+	hash, err := chainhash.NewHashFromStr(txHash)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := client.client.GetRawTransaction(hash)
+	if err != nil {
+		return nil, err
+	}
+	return tx.MsgTx(), nil
+}
+
+func (client *btcWalletClient) PublishTransaction(ctx context.Context, stx *wire.MsgTx) error {
+	if _, err := client.client.SendRawTransaction(stx, false); err != nil {
+		return publishRPCError("btcwallet", err)
+	}
+	return nil
+}
+
+func (client *btcWalletClient) SignTransaction(ctx context.Context, msgTx *wire.MsgTx) (*wire.MsgTx, error) {
+	signed, complete, err := client.client.SignRawTransaction(msgTx)
+	if err != nil {
+		return nil, err
+	}
+	if !complete {
+		return nil, fmt.Errorf("btcwallet could not sign every input of transaction %s", msgTx.TxHash())
+	}
+	return signed, nil
+}
+
+func (client *btcWalletClient) received(address string) (int64, error) {
+	addr, err := btcutil.DecodeAddress(address, client.params)
+	if err != nil {
+		return 0, err
+	}
+	amount, err := client.client.GetReceivedByAddressMinConf(addr, 0)
+	if err != nil {
+		return 0, err
+	}
+	return int64(amount), nil
+}
+
+// blockHash returns the hash of the block with the given hash or height.
+func (client *btcWalletClient) blockHash(hashOrHeight string) (*chainhash.Hash, error) {
+	hash, height, err := parseBlockID(hashOrHeight)
+	if err != nil || hash != nil {
+		return hash, err
+	}
+	return client.client.GetBlockHash(height)
+}
@@ -1,9 +1,18 @@
 package clients
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 )
 
@@ -12,7 +21,62 @@ type UTXO struct {
 	Amount       int64  `json:"amount"`
 	ScriptPubKey string `json:"scriptPubKey"`
 	Vout         uint32 `json:"vout"`
+
+	// Confirmations is the number of confirmations of the transaction of the
+	// output when it was fetched, zero while it is unconfirmed.
+	Confirmations int64 `json:"confirmations"`
+
+	// BlockHeight is the height of the block of the transaction of the
+	// output, zero while it is unconfirmed.
+	BlockHeight int64 `json:"blockHeight"`
+
+	// Address is the address the output pays to, empty for scripts without
+	// an address.
+	Address string `json:"address"`
+
+	// RedeemScript is the hex encoded redeem script, or witness script, of
+	// P2SH and P2WSH outputs, when the backend knows it. Only wallets
+	// holding the script know it.
+	RedeemScript string `json:"redeemScript,omitempty"`
+}
+
+// Redemption is the on-chain evidence that a script has been redeemed.
+type Redemption struct {
+	// Redeemed is true when the script received at least the expected value
+	// and has no balance left.
+	Redeemed bool `json:"redeemed"`
+
+	// TxHashes are the hashes of the transactions spending from the script.
+	TxHashes []string `json:"txHashes"`
+
+	// Amount is the total value spent from the script by these transactions.
+	Amount int64 `json:"amount"`
+
+	// Confirmations is the lowest number of confirmations of the redeeming
+	// transactions.
+	Confirmations int64 `json:"confirmations"`
+}
+
+// ScriptSpend is an input spending from a script. SigScript and the items of
+// Witness are hex encoded, secrets revealed by the spender can be extracted
+// from them.
+type ScriptSpend struct {
+	TxHash     string   `json:"txHash"`
+	InputIndex uint32   `json:"inputIndex"`
+	SigScript  string   `json:"sigScript"`
+	Witness    []string `json:"witness"`
 }
+
+// ChainTip is the latest block of the chain. MedianTime is the median time of
+// the last 11 blocks, which is used by consensus to evaluate time based lock
+// times.
+type ChainTip struct {
+	Height     int64  `json:"height"`
+	Hash       string `json:"hash"`
+	Time       int64  `json:"time"`
+	MedianTime int64  `json:"medianTime"`
+}
+
 type ClientCore interface {
 	// NetworkParams should return the network parameters of the underlying
 	// Bitcoin blockchain.
@@ -37,3 +101,337 @@ type ClientCore interface {
 	// blockchain.
 	PublishTransaction(ctx context.Context, signedTransaction *wire.MsgTx) error
 }
+
+// RedemptionFinder is implemented by backends that can return the
+// transactions that redeemed a script along with the redeemed amount and their
+// confirmations.
+type RedemptionFinder interface {
+	ScriptRedemption(ctx context.Context, address string, value int64) (Redemption, error)
+}
+
+// ScriptSpendFinder is implemented by backends that can return the input
+// spending from a script, and false if the script is unspent.
+type ScriptSpendFinder interface {
+	ScriptSpendDetails(ctx context.Context, script, spender string) (ScriptSpend, bool, error)
+}
+
+// ChainTipFetcher is implemented by backends that can return the latest block
+// of the chain along with its median time past.
+type ChainTipFetcher interface {
+	ChainTip(ctx context.Context) (ChainTip, error)
+}
+
+// TxFetcher is implemented by backends that can return the transaction with
+// the given hash.
+type TxFetcher interface {
+	RawTransaction(ctx context.Context, txHash string) (*wire.MsgTx, error)
+}
+
+// HeaderFetcher is implemented by backends that can return the header of the
+// block with the given hash, or at the given height of the active chain.
+type HeaderFetcher interface {
+	GetBlockHeader(ctx context.Context, hashOrHeight string) (*wire.BlockHeader, error)
+}
+
+// BlockFetcher is implemented by backends that can return the block with the
+// given hash, or at the given height of the active chain.
+type BlockFetcher interface {
+	GetBlock(ctx context.Context, hashOrHeight string) (*wire.MsgBlock, error)
+}
+
+// UTXOBatcher is implemented by backends that can fetch several outputs in a
+// single round trip. The UTXOs are returned in the order of the outpoints.
+type UTXOBatcher interface {
+	GetUTXOBatch(ctx context.Context, outPoints []wire.OutPoint) ([]UTXO, error)
+}
+
+// UTXOPager is implemented by backends that cap the number of outputs they
+// return at once, and can return the outputs of an address one page at a time.
+type UTXOPager interface {
+	// GetUTXOPage returns at most limit outputs of the address, skipping the
+	// first offset ones. Backends may return fewer outputs than asked for,
+	// the outputs are exhausted once an empty page is returned.
+	GetUTXOPage(ctx context.Context, address string, offset, limit, confirmations int64) ([]UTXO, error)
+}
+
+// AddressBatcher is implemented by backends that can fetch the outputs of
+// several addresses in a single round trip. The outputs are returned by
+// address, and every address is in the map even if it has no outputs.
+type AddressBatcher interface {
+	GetUTXOsMulti(ctx context.Context, addresses []string, confirmations int64) (map[string][]UTXO, error)
+}
+
+// ScriptStatuser is implemented by backends that can return, in a single round
+// trip, a digest of the history of several addresses which changes whenever a
+// transaction funds or spends from them, such as the status of Electrum script
+// hash subscriptions. The digest is empty when an address has no history, and
+// every address is in the map.
+type ScriptStatuser interface {
+	ScriptStatuses(ctx context.Context, addresses []string) (map[string]string, error)
+}
+
+// Spend is a transaction spending an output.
+type Spend struct {
+	TxHash        string `json:"txHash"`
+	Confirmations int64  `json:"confirmations"`
+}
+
+// OutputSpender is implemented by backends that can find the transaction
+// spending an output, including unconfirmed transactions.
+type OutputSpender interface {
+	// SpentBy returns the transaction spending the output, and false if the
+	// output is unspent.
+	SpentBy(ctx context.Context, txHash string, vout uint32) (Spend, bool, error)
+}
+
+// OutputChecker is implemented by backends that can tell whether an output is
+// unspent, including by unconfirmed transactions, but cannot find the
+// transaction spending it.
+type OutputChecker interface {
+	OutputUnspent(ctx context.Context, txHash string, vout uint32) (bool, error)
+}
+
+// ScriptTx is a transaction funding or spending from a script. Received is the
+// value paid to the script by the transaction, and Spent is the value of the
+// outputs of the script spent by it.
+type ScriptTx struct {
+	TxHash        string `json:"txHash"`
+	Received      int64  `json:"received"`
+	Spent         int64  `json:"spent"`
+	Confirmations int64  `json:"confirmations"`
+}
+
+// ScriptHistorian is implemented by backends that can list the transactions
+// funding and spending from a script, including unconfirmed transactions.
+type ScriptHistorian interface {
+	ScriptHistory(ctx context.Context, address string) ([]ScriptTx, error)
+}
+
+// BlockHasher is implemented by backends that can return the hash of the
+// block at a height of the active chain.
+type BlockHasher interface {
+	BlockHash(ctx context.Context, height int64) (string, error)
+}
+
+// RescanProgress is the state of a wallet rescan of a node. Progress is the
+// fraction of the blocks rescanned, and Duration the time spent rescanning.
+// The last progress of a rescan is Done, with the error that stopped it if
+// any.
+type RescanProgress struct {
+	Scanning bool          `json:"scanning"`
+	Progress float64       `json:"progress"`
+	Duration time.Duration `json:"duration"`
+	Done     bool          `json:"done"`
+	Err      error         `json:"-"`
+}
+
+// AddressRescanner is implemented by backends that import addresses into the
+// wallet of a node and rescan the chain for their transactions, which can take
+// hours. The progress of the rescan is sent to the channel, which is closed
+// once the rescan is done or the context is done, in which case the rescan is
+// aborted.
+type AddressRescanner interface {
+	ImportAddressRescan(ctx context.Context, address string) (<-chan RescanProgress, error)
+}
+
+// WalletDescriptor is an output descriptor imported into the wallet of a node
+// to be watched, such as addr(<address>) or wpkh(<xpub>/0/*). Ranged
+// descriptors are imported from index 0 to RangeEnd. The chain is rescanned
+// for the transactions of the descriptor if Rescan is true, otherwise only
+// new transactions are tracked.
+type WalletDescriptor struct {
+	Desc     string `json:"desc"`
+	Label    string `json:"label"`
+	RangeEnd uint32 `json:"rangeEnd"`
+	Internal bool   `json:"internal"`
+	Rescan   bool   `json:"rescan"`
+}
+
+// DescriptorImporter is implemented by backends that can import output
+// descriptors into the descriptor wallet of a node, which replaces the
+// importaddress of legacy wallets.
+type DescriptorImporter interface {
+	ImportDescriptors(ctx context.Context, descriptors []WalletDescriptor) error
+}
+
+// BlockEvent is a block that became the tip of the chain. PrevHash is the hash
+// of its parent, it is empty when the backend does not report it.
+type BlockEvent struct {
+	Height   int64  `json:"height"`
+	Hash     string `json:"hash"`
+	PrevHash string `json:"prevHash"`
+	Time     int64  `json:"time"`
+}
+
+// BlockSubscriber is implemented by backends that are notified of new blocks,
+// instead of having to poll the chain tip. The channel is closed once the
+// context is done or the subscription fails.
+type BlockSubscriber interface {
+	SubscribeBlocks(ctx context.Context) (<-chan BlockEvent, error)
+}
+
+// MempoolAcceptTester is implemented by backends that can test whether their
+// mempool accepts a transaction without publishing it. Rejections are returned
+// as errors.SubmitTxError.
+type MempoolAcceptTester interface {
+	TestMempoolAccept(ctx context.Context, stx *wire.MsgTx) error
+}
+
+// FeeEstimator is implemented by backends that estimate the fee rate, in
+// satoshis per virtual byte, for a transaction to be mined within the given
+// number of blocks.
+type FeeEstimator interface {
+	EstimateFeeRate(ctx context.Context, blocks int64) (int64, error)
+}
+
+// MerkleBranch links a transaction to the merkle root of its block. Hashes are
+// the siblings of the transaction and of its ancestors in the merkle tree,
+// from the bottom up, and Index is the position of the transaction in the
+// block.
+type MerkleBranch struct {
+	BlockHeight int64            `json:"blockHeight"`
+	Index       uint32           `json:"index"`
+	Hashes      []chainhash.Hash `json:"hashes"`
+}
+
+// MerkleProver is implemented by backends that can return the merkle branch
+// of a confirmed transaction. Some backends need the height of the block of
+// the transaction.
+type MerkleProver interface {
+	MerkleBranch(ctx context.Context, txHash string, blockHeight int64) (MerkleBranch, error)
+}
+
+// merkleBranch parses a merkle branch returned by Electrum servers and
+// Esplora, in which hashes are hex encoded in reverse byte order.
+func merkleBranch(blockHeight int64, pos uint32, merkle []string) (MerkleBranch, error) {
+	branch := MerkleBranch{
+		BlockHeight: blockHeight,
+		Index:       pos,
+		Hashes:      make([]chainhash.Hash, len(merkle)),
+	}
+	for i, hashStr := range merkle {
+		hash, err := chainhash.NewHashFromStr(hashStr)
+		if err != nil {
+			return MerkleBranch{}, err
+		}
+		branch.Hashes[i] = *hash
+	}
+	return branch, nil
+}
+
+// newScriptSpend returns the input of the transaction at the index.
+func newScriptSpend(tx *wire.MsgTx, index int) ScriptSpend {
+	txIn := tx.TxIn[index]
+	witness := make([]string, len(txIn.Witness))
+	for i, item := range txIn.Witness {
+		witness[i] = hex.EncodeToString(item)
+	}
+	return ScriptSpend{
+		TxHash:     tx.TxHash().String(),
+		InputIndex: uint32(index),
+		SigScript:  hex.EncodeToString(txIn.SignatureScript),
+		Witness:    witness,
+	}
+}
+
+// rawScriptSpend fetches the transaction to return its input at the index, for
+// backends whose APIs do not return the witnesses of inputs.
+func rawScriptSpend(ctx context.Context, fetcher TxFetcher, txHash string, index int) (ScriptSpend, error) {
+	tx, err := fetcher.RawTransaction(ctx, txHash)
+	if err != nil {
+		return ScriptSpend{}, err
+	}
+	if index >= len(tx.TxIn) {
+		return ScriptSpend{}, fmt.Errorf("transaction %s has no input %d", txHash, index)
+	}
+	return newScriptSpend(tx, index), nil
+}
+
+// scriptAddress returns the address the hex encoded public key script pays
+// to, or an empty string if it does not pay to a single address.
+func scriptAddress(scriptPubKey string, params *chaincfg.Params) string {
+	script, err := hex.DecodeString(scriptPubKey)
+	if err != nil {
+		return ""
+	}
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(script, params)
+	if err != nil || len(addrs) != 1 {
+		return ""
+	}
+	return addrs[0].EncodeAddress()
+}
+
+// confirmedHeight returns the block height reported by a backend, which some
+// report as -1 while the transaction is unconfirmed, as zero in that case.
+func confirmedHeight(height int64) int64 {
+	if height < 0 {
+		return 0
+	}
+	return height
+}
+
+// utxoBlockHeight returns the height of the block of an output with the
+// confirmations, when the chain tip is at the given height, and zero while it
+// is unconfirmed.
+func utxoBlockHeight(tipHeight, confirmations int64) int64 {
+	if confirmations <= 0 {
+		return 0
+	}
+	return tipHeight - confirmations + 1
+}
+
+// deserializeTx decodes a hex serialized transaction.
+func deserializeTx(txHex string) (*wire.MsgTx, error) {
+	txBytes, err := hex.DecodeString(strings.TrimSpace(txHex))
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// parseBlockID parses a block hash, or a block height, in which case the
+// returned hash is nil.
+func parseBlockID(hashOrHeight string) (*chainhash.Hash, int64, error) {
+	if len(hashOrHeight) == 2*chainhash.HashSize {
+		hash, err := chainhash.NewHashFromStr(hashOrHeight)
+		return hash, 0, err
+	}
+	height, err := strconv.ParseInt(hashOrHeight, 10, 64)
+	if err != nil || height < 0 {
+		return nil, 0, fmt.Errorf("invalid block hash or height %q", hashOrHeight)
+	}
+	return nil, height, nil
+}
+
+// deserializeBlock decodes a hex serialized block.
+func deserializeBlock(blockHex string) (*wire.MsgBlock, error) {
+	blockBytes, err := hex.DecodeString(strings.TrimSpace(blockHex))
+	if err != nil {
+		return nil, err
+	}
+	block := new(wire.MsgBlock)
+	if err := block.Deserialize(bytes.NewReader(blockBytes)); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// medianTimePast returns the median of the given block timestamps, as defined
+// by BIP113. It fails if there are no timestamps.
+func medianTimePast(timestamps []int64) (int64, error) {
+	if len(timestamps) == 0 {
+		return 0, fmt.Errorf("cannot compute the median time past of no blocks")
+	}
+	sorted := make([]int64, len(timestamps))
+	copy(sorted, timestamps)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2], nil
+}
+
+// medianTimeBlocks is the number of blocks used to compute the median time
+// past.
+const medianTimeBlocks = 11
@@ -0,0 +1,88 @@
+package clients_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestClients(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Clients Suite")
+}
+
+// fixtureServer is an HTTP server answering requests with the fixture of their
+// path and query, or of their path alone, and recording the URLs requested.
+// Paths without a fixture are not found.
+type fixtureServer struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	urls []url.URL
+}
+
+func newFixtureServer(fixtures map[string]string) *fixtureServer {
+	server := &fixtureServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		server.urls = append(server.urls, *r.URL)
+		server.mu.Unlock()
+		fixture, ok := fixtures[r.URL.RequestURI()]
+		if !ok {
+			fixture, ok = fixtures[r.URL.Path]
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(fixture))
+	}))
+	return server
+}
+
+// requested returns the paths requested so far, in order.
+func (server *fixtureServer) requested() []string {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	paths := make([]string, len(server.urls))
+	for i, u := range server.urls {
+		paths[i] = u.Path
+	}
+	return paths
+}
+
+// queries returns the query parameters of the requests made so far, in order.
+func (server *fixtureServer) queries() []url.Values {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	queries := make([]url.Values, len(server.urls))
+	for i, u := range server.urls {
+		queries[i] = u.Query()
+	}
+	return queries
+}
+
+// redirectingClient returns an http.Client sending every request to the
+// server, for backends whose URL cannot be set.
+func (server *fixtureServer) redirectingClient() *http.Client {
+	target, err := url.Parse(server.URL)
+	Expect(err).ShouldNot(HaveOccurred())
+	return &http.Client{Transport: redirectTransport{target}}
+}
+
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (transport redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = transport.target.Scheme
+	req.URL.Host = transport.target.Host
+	req.Host = transport.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
@@ -0,0 +1,936 @@
+package clients
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/errors"
+	"github.com/renproject/libbtc-go/networks"
+)
+
+// maxElectrumBatch is the maximum number of requests sent to an Electrum server
+// in a single JSON-RPC batch, which servers limit the size of.
+const maxElectrumBatch = 100
+
+// ElectrumUTXO is an unspent output as returned by an Electrum server.
+type ElectrumUTXO struct {
+	TxHash string `json:"tx_hash"`
+	TxPos  uint32 `json:"tx_pos"`
+	Height int64  `json:"height"`
+	Value  int64  `json:"value"`
+}
+
+// ElectrumHistoryItem is a transaction in the history of a script hash as
+// returned by an Electrum server. Height is 0 or negative for unconfirmed
+// transactions.
+type ElectrumHistoryItem struct {
+	TxHash string `json:"tx_hash"`
+	Height int64  `json:"height"`
+}
+
+// ElectrumBalance is the balance of a script hash as returned by an Electrum
+// server.
+type ElectrumBalance struct {
+	Confirmed   int64 `json:"confirmed"`
+	Unconfirmed int64 `json:"unconfirmed"`
+}
+
+// ElectrumHeader is the chain tip as returned by an Electrum server.
+type ElectrumHeader struct {
+	Height int64  `json:"height"`
+	Hex    string `json:"hex"`
+}
+
+type electrumFeatures struct {
+	GenesisHash string `json:"genesis_hash"`
+}
+
+type electrumRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type electrumResponse struct {
+	ID     *uint64         `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type electrumClient struct {
+	server string
+	useTLS bool
+	params *chaincfg.Params
+	dial   dialFunc
+
+	mu     *sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	nextID uint64
+}
+
+// NewElectrumClientCore returns a ClientCore connected to the Electrum server
+// at the given host:port. The network is detected using the genesis hash
+// reported by the server.
+func NewElectrumClientCore(server string, useTLS bool) (ClientCore, error) {
+	return newElectrumClientCore(server, useTLS, directDial)
+}
+
+// NewElectrumClientCoreWithProxy returns a ClientCore connected to the
+// Electrum server through the SOCKS5 proxy, given as in NewProxyHTTPClient.
+// The server can be a .onion address when the proxy is Tor.
+func NewElectrumClientCoreWithProxy(server string, useTLS bool, proxy string) (ClientCore, error) {
+	dial, err := proxyDial(proxy)
+	if err != nil {
+		return nil, err
+	}
+	return newElectrumClientCore(server, useTLS, dial)
+}
+
+func newElectrumClientCore(server string, useTLS bool, dial dialFunc) (ClientCore, error) {
+	client := &electrumClient{
+		server: server,
+		useTLS: useTLS,
+		dial:   dial,
+		mu:     new(sync.Mutex),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	features := electrumFeatures{}
+	if err := client.call(ctx, "server.features", &features); err != nil {
+		return nil, err
+	}
+	for _, params := range []*chaincfg.Params{&chaincfg.MainNetParams, &chaincfg.TestNet3Params, &chaincfg.RegressionNetParams, &chaincfg.SimNetParams} {
+		if params.GenesisHash.String() == features.GenesisHash {
+			client.params = params
+			return client, nil
+		}
+	}
+	if network, ok := networks.LookupGenesis(features.GenesisHash); ok {
+		client.params = network.Params
+		return client, nil
+	}
+	return nil, errors.NewErrUnsupportedNetwork(fmt.Sprintf("genesis hash %s", features.GenesisHash))
+}
+
+// NewElectrumClientCoreWithParams returns a ClientCore connected to the
+// Electrum server of the network described by the given parameters, for
+// example a private chain or a fork. The genesis hash reported by the server
+// must match the one of the parameters.
+func NewElectrumClientCoreWithParams(server string, useTLS bool, params *chaincfg.Params) (ClientCore, error) {
+	client := &electrumClient{
+		server: server,
+		useTLS: useTLS,
+		params: params,
+		dial:   directDial,
+		mu:     new(sync.Mutex),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	features := electrumFeatures{}
+	if err := client.call(ctx, "server.features", &features); err != nil {
+		return nil, err
+	}
+	if params.GenesisHash.String() != features.GenesisHash {
+		return nil, errors.NewErrMismatchedNetworks(params.Name, fmt.Sprintf("genesis hash %s", features.GenesisHash))
+	}
+	return client, nil
+}
+
+func (client *electrumClient) NetworkParams() *chaincfg.Params {
+	return client.params
+}
+
+func (client *electrumClient) GetUTXOs(ctx context.Context, address string, limit, confitmations int64) ([]UTXO, error) {
+	script, err := client.addressScript(address)
+	if err != nil {
+		return nil, err
+	}
+	unspents, err := client.ListUnspent(ctx, script)
+	if err != nil {
+		return nil, err
+	}
+	tip, err := client.LatestHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return electrumUTXOs(address, script, unspents, tip.Height, limit, confitmations), nil
+}
+
+// GetUTXOsMulti returns the outputs of the addresses, listing the unspent
+// outputs of their scripts with batched requests.
+func (client *electrumClient) GetUTXOsMulti(ctx context.Context, addresses []string, confirmations int64) (map[string][]UTXO, error) {
+	scripts, params, err := client.scriptHashParams(addresses)
+	if err != nil {
+		return nil, err
+	}
+	results, err := client.callBatch(ctx, "blockchain.scripthash.listunspent", params)
+	if err != nil {
+		return nil, err
+	}
+	tip, err := client.LatestHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	utxos := make(map[string][]UTXO, len(addresses))
+	for i, address := range addresses {
+		unspents := []ElectrumUTXO{}
+		if err := json.Unmarshal(results[i], &unspents); err != nil {
+			return nil, err
+		}
+		utxos[address] = electrumUTXOs(address, scripts[i], unspents, tip.Height, 0, confirmations)
+	}
+	return utxos, nil
+}
+
+// ScriptStatuses returns the status of the script hash subscriptions of the
+// addresses, subscribed to with batched requests. The notifications of the
+// subscriptions are ignored, so the statuses are polled.
+func (client *electrumClient) ScriptStatuses(ctx context.Context, addresses []string) (map[string]string, error) {
+	_, params, err := client.scriptHashParams(addresses)
+	if err != nil {
+		return nil, err
+	}
+	results, err := client.callBatch(ctx, "blockchain.scripthash.subscribe", params)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[string]string, len(addresses))
+	for i, address := range addresses {
+		// The status is null when the script has no history.
+		var status *string
+		if err := json.Unmarshal(results[i], &status); err != nil {
+			return nil, err
+		}
+		statuses[address] = ""
+		if status != nil {
+			statuses[address] = *status
+		}
+	}
+	return statuses, nil
+}
+
+func (client *electrumClient) GetUTXO(ctx context.Context, txHash string, vout uint32) (UTXO, error) {
+	tx, err := client.GetTransaction(ctx, txHash)
+	if err != nil {
+		return UTXO{}, err
+	}
+	if int(vout) >= len(tx.TxOut) {
+		return UTXO{}, fmt.Errorf("transaction %s does not have an output at index %d", txHash, vout)
+	}
+	confirmations, err := client.Confirmations(ctx, txHash)
+	if err != nil {
+		return UTXO{}, err
+	}
+	tip, err := client.LatestHeader(ctx)
+	if err != nil {
+		return UTXO{}, err
+	}
+	scriptPubKey := hex.EncodeToString(tx.TxOut[vout].PkScript)
+	return UTXO{
+		TxHash:        txHash,
+		Amount:        tx.TxOut[vout].Value,
+		ScriptPubKey:  scriptPubKey,
+		Vout:          vout,
+		Confirmations: confirmations,
+		BlockHeight:   utxoBlockHeight(tip.Height, confirmations),
+		Address:       scriptAddress(scriptPubKey, client.params),
+	}, nil
+}
+
+func (client *electrumClient) Confirmations(ctx context.Context, txHash string) (int64, error) {
+	tx, err := client.GetTransaction(ctx, txHash)
+	if err != nil {
+		return 0, err
+	}
+
+	// Electrum servers index transactions by the scripts they touch, so the
+	// height of the transaction is looked up in the history of its outputs.
+	for _, txOut := range tx.TxOut {
+		history, err := client.History(ctx, txOut.PkScript)
+		if err != nil {
+			return 0, err
+		}
+		for _, item := range history {
+			if item.TxHash != txHash {
+				continue
+			}
+			tip, err := client.LatestHeader(ctx)
+			if err != nil {
+				return 0, err
+			}
+			return electrumConfirmations(tip.Height, item.Height), nil
+		}
+	}
+	return 0, fmt.Errorf("could not find transaction %s in the history of its outputs", txHash)
+}
+
+func (client *electrumClient) ScriptFunded(ctx context.Context, address string, value int64) (bool, int64, error) {
+	script, err := client.addressScript(address)
+	if err != nil {
+		return false, 0, err
+	}
+	received, err := client.received(ctx, script)
+	if err != nil {
+		return false, 0, err
+	}
+	return received >= value, received, nil
+}
+
+func (client *electrumClient) ScriptRedeemed(ctx context.Context, address string, value int64) (bool, int64, error) {
+	script, err := client.addressScript(address)
+	if err != nil {
+		return false, 0, err
+	}
+	received, err := client.received(ctx, script)
+	if err != nil {
+		return false, 0, err
+	}
+	balance, err := client.Balance(ctx, script)
+	if err != nil {
+		return false, 0, err
+	}
+	total := balance.Confirmed + balance.Unconfirmed
+	return received >= value && total == 0, total, nil
+}
+
+func (client *electrumClient) ScriptRedemption(ctx context.Context, address string, value int64) (Redemption, error) {
+	script, err := client.addressScript(address)
+	if err != nil {
+		return Redemption{}, err
+	}
+	history, err := client.History(ctx, script)
+	if err != nil {
+		return Redemption{}, err
+	}
+	tip, err := client.LatestHeader(ctx)
+	if err != nil {
+		return Redemption{}, err
+	}
+
+	txs := make([]*wire.MsgTx, len(history))
+	funded := map[wire.OutPoint]int64{}
+	var received int64
+	for i, item := range history {
+		txs[i], err = client.GetTransaction(ctx, item.TxHash)
+		if err != nil {
+			return Redemption{}, err
+		}
+		for vout, txOut := range txs[i].TxOut {
+			if bytes.Equal(txOut.PkScript, script) {
+				funded[wire.OutPoint{Hash: txs[i].TxHash(), Index: uint32(vout)}] = txOut.Value
+				received += txOut.Value
+			}
+		}
+	}
+
+	redemption := Redemption{TxHashes: []string{}}
+	for i, tx := range txs {
+		var amount int64
+		for _, txIn := range tx.TxIn {
+			amount += funded[txIn.PreviousOutPoint]
+		}
+		if amount == 0 {
+			continue
+		}
+
+		conf := electrumConfirmations(tip.Height, history[i].Height)
+		if len(redemption.TxHashes) == 0 || conf < redemption.Confirmations {
+			redemption.Confirmations = conf
+		}
+		redemption.TxHashes = append(redemption.TxHashes, history[i].TxHash)
+		redemption.Amount += amount
+	}
+	redemption.Redeemed = received >= value && received == redemption.Amount
+	return redemption, nil
+}
+
+func (client *electrumClient) ScriptHistory(ctx context.Context, address string) ([]ScriptTx, error) {
+	script, err := client.addressScript(address)
+	if err != nil {
+		return nil, err
+	}
+	history, err := client.History(ctx, script)
+	if err != nil {
+		return nil, err
+	}
+	tip, err := client.LatestHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	txs := make([]*wire.MsgTx, len(history))
+	scriptTxs := make([]ScriptTx, len(history))
+	funded := map[wire.OutPoint]int64{}
+	for i, item := range history {
+		txs[i], err = client.GetTransaction(ctx, item.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		scriptTxs[i] = ScriptTx{
+			TxHash:        item.TxHash,
+			Confirmations: electrumConfirmations(tip.Height, item.Height),
+		}
+		for vout, txOut := range txs[i].TxOut {
+			if bytes.Equal(txOut.PkScript, script) {
+				funded[wire.OutPoint{Hash: txs[i].TxHash(), Index: uint32(vout)}] = txOut.Value
+				scriptTxs[i].Received += txOut.Value
+			}
+		}
+	}
+	for i, tx := range txs {
+		for _, txIn := range tx.TxIn {
+			scriptTxs[i].Spent += funded[txIn.PreviousOutPoint]
+		}
+	}
+	return scriptTxs, nil
+}
+
+func (client *electrumClient) ScriptSpent(ctx context.Context, script, spender string) (bool, string, error) {
+	spend, spent, err := client.ScriptSpendDetails(ctx, script, spender)
+	return spent, spend.SigScript, err
+}
+
+func (client *electrumClient) ScriptSpendDetails(ctx context.Context, script, spender string) (ScriptSpend, bool, error) {
+	pkScript, err := client.addressScript(script)
+	if err != nil {
+		return ScriptSpend{}, false, err
+	}
+	history, err := client.History(ctx, pkScript)
+	if err != nil {
+		return ScriptSpend{}, false, err
+	}
+
+	txs := make([]*wire.MsgTx, len(history))
+	funded := map[wire.OutPoint]bool{}
+	for i, item := range history {
+		txs[i], err = client.GetTransaction(ctx, item.TxHash)
+		if err != nil {
+			return ScriptSpend{}, false, err
+		}
+		for vout, txOut := range txs[i].TxOut {
+			if bytes.Equal(txOut.PkScript, pkScript) {
+				funded[wire.OutPoint{Hash: txs[i].TxHash(), Index: uint32(vout)}] = true
+			}
+		}
+	}
+
+	for _, tx := range txs {
+		for i, txIn := range tx.TxIn {
+			if funded[txIn.PreviousOutPoint] {
+				return newScriptSpend(tx, i), true, nil
+			}
+		}
+	}
+	return ScriptSpend{}, false, nil
+}
+
+// SpentBy returns the transaction spending the output, which is looked up in
+// the history of the script of the output, and false if the output is
+// unspent.
+func (client *electrumClient) SpentBy(ctx context.Context, txHash string, vout uint32) (Spend, bool, error) {
+	tx, err := client.GetTransaction(ctx, txHash)
+	if err != nil {
+		return Spend{}, false, err
+	}
+	if int(vout) >= len(tx.TxOut) {
+		return Spend{}, false, fmt.Errorf("transaction %s does not have an output at index %d", txHash, vout)
+	}
+	history, err := client.History(ctx, tx.TxOut[vout].PkScript)
+	if err != nil {
+		return Spend{}, false, err
+	}
+
+	outPoint := wire.OutPoint{Hash: tx.TxHash(), Index: vout}
+	for _, item := range history {
+		if item.TxHash == txHash {
+			continue
+		}
+		spendingTx, err := client.GetTransaction(ctx, item.TxHash)
+		if err != nil {
+			return Spend{}, false, err
+		}
+		for _, txIn := range spendingTx.TxIn {
+			if txIn.PreviousOutPoint != outPoint {
+				continue
+			}
+			tip, err := client.LatestHeader(ctx)
+			if err != nil {
+				return Spend{}, false, err
+			}
+			return Spend{
+				TxHash:        item.TxHash,
+				Confirmations: electrumConfirmations(tip.Height, item.Height),
+			}, true, nil
+		}
+	}
+	return Spend{}, false, nil
+}
+
+// GetBlockHeader returns the header of the block at the given height, Electrum
+// servers do not look up blocks by hash.
+func (client *electrumClient) GetBlockHeader(ctx context.Context, hashOrHeight string) (*wire.BlockHeader, error) {
+	hash, height, err := parseBlockID(hashOrHeight)
+	if err != nil {
+		return nil, err
+	}
+	if hash != nil {
+		return nil, errors.NewErrUnsupportedOperation("GetBlockHeader by hash", "electrum")
+	}
+	headers, err := client.Headers(ctx, height, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(headers) == 0 {
+		return nil, fmt.Errorf("no block at height %d", height)
+	}
+	return &headers[0], nil
+}
+
+func (client *electrumClient) RawTransaction(ctx context.Context, txHash string) (*wire.MsgTx, error) {
+	return client.GetTransaction(ctx, txHash)
+}
+
+func (client *electrumClient) PublishTransaction(ctx context.Context, stx *wire.MsgTx) error {
+	var stxBuffer bytes.Buffer
+	stxBuffer.Grow(stx.SerializeSize())
+	if err := stx.Serialize(&stxBuffer); err != nil {
+		return err
+	}
+	var txHash string
+	if err := client.call(ctx, "blockchain.transaction.broadcast", &txHash, hex.EncodeToString(stxBuffer.Bytes())); err != nil {
+		if _, ok := err.(net.Error); ok {
+			return errors.NewErrBackendUnavailable("electrum", err)
+		}
+		return errors.NewErrBitcoinSubmitTx(err.Error())
+	}
+	return nil
+}
+
+func (client *electrumClient) ChainTip(ctx context.Context) (ChainTip, error) {
+	tip, err := client.LatestHeader(ctx)
+	if err != nil {
+		return ChainTip{}, err
+	}
+	start := tip.Height - medianTimeBlocks + 1
+	if start < 0 {
+		start = 0
+	}
+	headers, err := client.Headers(ctx, start, tip.Height-start+1)
+	if err != nil {
+		return ChainTip{}, err
+	}
+	if len(headers) == 0 {
+		return ChainTip{}, fmt.Errorf("electrum server returned no headers from height %d", start)
+	}
+
+	timestamps := make([]int64, len(headers))
+	for i, header := range headers {
+		timestamps[i] = header.Timestamp.Unix()
+	}
+	latest := headers[len(headers)-1]
+	medianTime, err := medianTimePast(timestamps)
+	if err != nil {
+		return ChainTip{}, err
+	}
+	return ChainTip{
+		Height:     tip.Height,
+		Hash:       latest.BlockHash().String(),
+		Time:       latest.Timestamp.Unix(),
+		MedianTime: medianTime,
+	}, nil
+}
+
+// ListUnspent returns the unspent outputs of the given script.
+func (client *electrumClient) ListUnspent(ctx context.Context, script []byte) ([]ElectrumUTXO, error) {
+	unspents := []ElectrumUTXO{}
+	err := client.call(ctx, "blockchain.scripthash.listunspent", &unspents, electrumScriptHash(script))
+	return unspents, err
+}
+
+// History returns the confirmed and unconfirmed transactions that fund or
+// spend the given script.
+func (client *electrumClient) History(ctx context.Context, script []byte) ([]ElectrumHistoryItem, error) {
+	history := []ElectrumHistoryItem{}
+	err := client.call(ctx, "blockchain.scripthash.get_history", &history, electrumScriptHash(script))
+	return history, err
+}
+
+// Balance returns the confirmed and unconfirmed balance of the given script.
+func (client *electrumClient) Balance(ctx context.Context, script []byte) (ElectrumBalance, error) {
+	balance := ElectrumBalance{}
+	err := client.call(ctx, "blockchain.scripthash.get_balance", &balance, electrumScriptHash(script))
+	return balance, err
+}
+
+// GetTransaction returns the decoded transaction with the given hash.
+func (client *electrumClient) GetTransaction(ctx context.Context, txHash string) (*wire.MsgTx, error) {
+	var txHex string
+	if err := client.call(ctx, "blockchain.transaction.get", &txHex, txHash); err != nil {
+		return nil, err
+	}
+	txBytes, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// MerkleBranch returns the merkle branch of the transaction, which must be in
+// the block at the given height.
+func (client *electrumClient) MerkleBranch(ctx context.Context, txHash string, blockHeight int64) (MerkleBranch, error) {
+	resp := struct {
+		BlockHeight int64    `json:"block_height"`
+		Merkle      []string `json:"merkle"`
+		Pos         uint32   `json:"pos"`
+	}{}
+	if err := client.call(ctx, "blockchain.transaction.get_merkle", &resp, txHash, blockHeight); err != nil {
+		return MerkleBranch{}, err
+	}
+	return merkleBranch(resp.BlockHeight, resp.Pos, resp.Merkle)
+}
+
+// LatestHeader returns the current chain tip of the server.
+func (client *electrumClient) LatestHeader(ctx context.Context) (ElectrumHeader, error) {
+	header := ElectrumHeader{}
+	err := client.call(ctx, "blockchain.headers.subscribe", &header)
+	return header, err
+}
+
+// Headers returns count block headers starting at the given height.
+func (client *electrumClient) Headers(ctx context.Context, start, count int64) ([]wire.BlockHeader, error) {
+	resp := struct {
+		Count int64  `json:"count"`
+		Hex   string `json:"hex"`
+	}{}
+	if err := client.call(ctx, "blockchain.block.headers", &resp, start, count); err != nil {
+		return nil, err
+	}
+	headerBytes, err := hex.DecodeString(resp.Hex)
+	if err != nil {
+		return nil, err
+	}
+	reader := bytes.NewReader(headerBytes)
+	headers := make([]wire.BlockHeader, resp.Count)
+	for i := range headers {
+		if err := headers[i].Deserialize(reader); err != nil {
+			return nil, err
+		}
+	}
+	return headers, nil
+}
+
+func (client *electrumClient) received(ctx context.Context, script []byte) (int64, error) {
+	history, err := client.History(ctx, script)
+	if err != nil {
+		return 0, err
+	}
+	var received int64
+	for _, item := range history {
+		tx, err := client.GetTransaction(ctx, item.TxHash)
+		if err != nil {
+			return 0, err
+		}
+		for _, txOut := range tx.TxOut {
+			if bytes.Equal(txOut.PkScript, script) {
+				received += txOut.Value
+			}
+		}
+	}
+	return received, nil
+}
+
+func (client *electrumClient) addressScript(address string) ([]byte, error) {
+	addr, err := btcutil.DecodeAddress(address, client.params)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.PayToAddrScript(addr)
+}
+
+// scriptHashParams returns the scripts of the addresses, and the parameters of
+// the requests about their script hashes.
+func (client *electrumClient) scriptHashParams(addresses []string) ([][]byte, [][]interface{}, error) {
+	scripts := make([][]byte, len(addresses))
+	params := make([][]interface{}, len(addresses))
+	for i, address := range addresses {
+		script, err := client.addressScript(address)
+		if err != nil {
+			return nil, nil, err
+		}
+		scripts[i] = script
+		params[i] = []interface{}{electrumScriptHash(script)}
+	}
+	return scripts, params, nil
+}
+
+// call sends a request to the server and decodes the result into the given
+// response. The connection is re-established on the next call if the request
+// fails.
+func (client *electrumClient) call(ctx context.Context, method string, response interface{}, params ...interface{}) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if err := client.connect(ctx); err != nil {
+		return err
+	}
+	result, err := client.roundTrip(ctx, method, params)
+	if err != nil {
+		client.conn.Close()
+		client.conn = nil
+		return err
+	}
+	return json.Unmarshal(result, response)
+}
+
+// callBatch sends a request of the method for each of the params, in JSON-RPC
+// batches of at most maxElectrumBatch requests, and returns their results in
+// the same order. The connection is re-established on the next call if a batch
+// fails.
+func (client *electrumClient) callBatch(ctx context.Context, method string, params [][]interface{}) ([]json.RawMessage, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if err := client.connect(ctx); err != nil {
+		return nil, err
+	}
+	results := make([]json.RawMessage, 0, len(params))
+	for start := 0; start < len(params); start += maxElectrumBatch {
+		end := start + maxElectrumBatch
+		if end > len(params) {
+			end = len(params)
+		}
+		batch, err := client.batchRoundTrip(ctx, method, params[start:end])
+		if err != nil {
+			client.conn.Close()
+			client.conn = nil
+			return nil, err
+		}
+		results = append(results, batch...)
+	}
+	return results, nil
+}
+
+func (client *electrumClient) connect(ctx context.Context) error {
+	if client.conn != nil {
+		return nil
+	}
+
+	conn, err := client.dial(ctx, "tcp", client.server)
+	if err != nil {
+		return err
+	}
+	if client.useTLS {
+		host, _, err := net.SplitHostPort(client.server)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		tlsConn.SetDeadline(time.Now().Add(30 * time.Second))
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return err
+		}
+		conn = tlsConn
+	}
+	client.conn = conn
+	client.reader = bufio.NewReader(conn)
+
+	// The protocol version has to be negotiated before any other request is
+	// made.
+	if _, err := client.roundTrip(ctx, "server.version", []interface{}{"libbtc-go", "1.4"}); err != nil {
+		client.conn.Close()
+		client.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (client *electrumClient) roundTrip(ctx context.Context, method string, params []interface{}) (json.RawMessage, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Minute)
+	}
+	if err := client.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if params == nil {
+		params = []interface{}{}
+	}
+	client.nextID++
+	id := client.nextID
+	req, err := json.Marshal(electrumRequest{
+		JSONRPC: "2.0",
+		ID:      id,
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.conn.Write(append(req, '\n')); err != nil {
+		return nil, err
+	}
+
+	for {
+		line, err := client.reader.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		resp := electrumResponse{}
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return nil, err
+		}
+		// Skip subscription notifications and responses to other requests.
+		if resp.ID == nil || *resp.ID != id {
+			continue
+		}
+		if resp.Error != nil {
+			return nil, fmt.Errorf("electrum request %s failed with (%d): %s", method, resp.Error.Code, resp.Error.Message)
+		}
+		return resp.Result, nil
+	}
+}
+
+func (client *electrumClient) batchRoundTrip(ctx context.Context, method string, params [][]interface{}) ([]json.RawMessage, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Minute)
+	}
+	if err := client.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	reqs := make([]electrumRequest, len(params))
+	indices := make(map[uint64]int, len(params))
+	for i, param := range params {
+		if param == nil {
+			param = []interface{}{}
+		}
+		client.nextID++
+		reqs[i] = electrumRequest{
+			JSONRPC: "2.0",
+			ID:      client.nextID,
+			Method:  method,
+			Params:  param,
+		}
+		indices[client.nextID] = i
+	}
+	req, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.conn.Write(append(req, '\n')); err != nil {
+		return nil, err
+	}
+
+	results := make([]json.RawMessage, len(params))
+	for len(indices) > 0 {
+		line, err := client.reader.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		// The responses to a batch are sent in an array, while subscription
+		// notifications are single objects.
+		resps := []electrumResponse{}
+		if line = bytes.TrimSpace(line); len(line) > 0 && line[0] == '[' {
+			if err := json.Unmarshal(line, &resps); err != nil {
+				return nil, err
+			}
+		} else {
+			resp := electrumResponse{}
+			if err := json.Unmarshal(line, &resp); err != nil {
+				return nil, err
+			}
+			resps = append(resps, resp)
+		}
+
+		for _, resp := range resps {
+			if resp.ID == nil {
+				continue
+			}
+			i, ok := indices[*resp.ID]
+			if !ok {
+				continue
+			}
+			if resp.Error != nil {
+				return nil, fmt.Errorf("electrum request %s failed with (%d): %s", method, resp.Error.Code, resp.Error.Message)
+			}
+			results[i] = resp.Result
+			delete(indices, *resp.ID)
+		}
+	}
+	return results, nil
+}
+
+// electrumUTXOs returns at most limit of the unspent outputs of the script of
+// the address with the given confirmations, when the chain tip is at the given
+// height. A limit of zero returns all of them.
+func electrumUTXOs(address string, script []byte, unspents []ElectrumUTXO, tipHeight, limit, minConfirmations int64) []UTXO {
+	utxos := []UTXO{}
+	for _, unspent := range unspents {
+		if limit > 0 && int64(len(utxos)) >= limit {
+			break
+		}
+		confirmations := electrumConfirmations(tipHeight, unspent.Height)
+		if confirmations < minConfirmations {
+			continue
+		}
+		utxos = append(utxos, UTXO{
+			TxHash:        unspent.TxHash,
+			Amount:        unspent.Value,
+			ScriptPubKey:  hex.EncodeToString(script),
+			Vout:          unspent.TxPos,
+			Confirmations: confirmations,
+			BlockHeight:   confirmedHeight(unspent.Height),
+			Address:       address,
+		})
+	}
+	return utxos
+}
+
+// electrumScriptHash returns the script hash used by Electrum servers to index
+// the given script, which is the reversed sha256 of the script in hex.
+func electrumScriptHash(script []byte) string {
+	hash := sha256.Sum256(script)
+	for i, j := 0, len(hash)-1; i < j; i, j = i+1, j-1 {
+		hash[i], hash[j] = hash[j], hash[i]
+	}
+	return hex.EncodeToString(hash[:])
+}
+
+func electrumConfirmations(tip, height int64) int64 {
+	if height <= 0 {
+		return 0
+	}
+	return tip - height + 1
+}
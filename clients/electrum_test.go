@@ -0,0 +1,255 @@
+package clients_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go/clients"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// electrumUnspentFixture is the response of an Electrum server listing the
+// unspent outputs of a script: a confirmed output, a mempool output and an
+// output of a transaction with unconfirmed parents.
+const electrumUnspentFixture = `[
+	{"tx_hash": "9c2ac5a7e5b4bf2c7ef7f0a6ce1d2a1c4a6c7a0b2e3f40516273849506a7b8c9", "tx_pos": 1, "height": 101, "value": 50000},
+	{"tx_hash": "1d2e3f405162738495a6b7c8d9eafb0c1d2e3f405162738495a6b7c8d9eafb0c", "tx_pos": 0, "height": 0, "value": 20000},
+	{"tx_hash": "aa0b1c2d3e4f5061728394a5b6c7d8e9fa0b1c2d3e4f5061728394a5b6c7d8e9", "tx_pos": 2, "height": -1, "value": 10000}
+]`
+
+// electrumRequest is a JSON-RPC request received by the electrumServer.
+type electrumRequest struct {
+	ID     uint64        `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// electrumServer is an Electrum server answering requests with the results
+// returned by handle, and recording the size of the batches it receives. The
+// responses to a batch are sent in the reverse order of its requests, which
+// servers are free to do.
+type electrumServer struct {
+	listener net.Listener
+	handle   func(method string, params []interface{}) interface{}
+
+	mu      sync.Mutex
+	batches []int
+}
+
+func newElectrumServer(params *chaincfg.Params, handle func(method string, params []interface{}) interface{}) *electrumServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ShouldNot(HaveOccurred())
+	server := &electrumServer{
+		listener: listener,
+		handle: func(method string, args []interface{}) interface{} {
+			switch method {
+			case "server.version":
+				return []string{"ElectrumX 1.16.0", "1.4"}
+			case "server.features":
+				return map[string]string{"genesis_hash": params.GenesisHash.String()}
+			case "blockchain.headers.subscribe":
+				return map[string]interface{}{"height": 110, "hex": ""}
+			}
+			return handle(method, args)
+		},
+	}
+	go server.serve()
+	return server
+}
+
+func (server *electrumServer) serve() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+		go server.serveConn(conn)
+	}
+}
+
+func (server *electrumServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var resp interface{}
+		if line = bytes.TrimSpace(line); line[0] == '[' {
+			reqs := []electrumRequest{}
+			if err := json.Unmarshal(line, &reqs); err != nil {
+				return
+			}
+			server.mu.Lock()
+			server.batches = append(server.batches, len(reqs))
+			server.mu.Unlock()
+			resps := make([]interface{}, len(reqs))
+			for i, req := range reqs {
+				resps[len(reqs)-1-i] = server.respond(req)
+			}
+			resp = resps
+		} else {
+			req := electrumRequest{}
+			if err := json.Unmarshal(line, &req); err != nil {
+				return
+			}
+			resp = server.respond(req)
+		}
+		respBytes, err := json.Marshal(resp)
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write(append(respBytes, '\n')); err != nil {
+			return
+		}
+	}
+}
+
+func (server *electrumServer) respond(req electrumRequest) interface{} {
+	return map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      req.ID,
+		"result":  server.handle(req.Method, req.Params),
+	}
+}
+
+func (server *electrumServer) batchSizes() []int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return append([]int{}, server.batches...)
+}
+
+var _ = Describe("Electrum client", func() {
+	params := &chaincfg.RegressionNetParams
+
+	// newAddresses returns n distinct addresses.
+	newAddresses := func(n int) []string {
+		addresses := make([]string, n)
+		for i := range addresses {
+			hash := sha256.Sum256([]byte{byte(i), byte(i >> 8)})
+			address, err := btcutil.NewAddressPubKeyHash(hash[:20], params)
+			Expect(err).ShouldNot(HaveOccurred())
+			addresses[i] = address.EncodeAddress()
+		}
+		return addresses
+	}
+
+	// scriptHash returns the Electrum script hash of the hex encoded script.
+	scriptHash := func(scriptPubKey string) string {
+		script, err := hex.DecodeString(scriptPubKey)
+		Expect(err).ShouldNot(HaveOccurred())
+		hash := sha256.Sum256(script)
+		for i, j := 0, len(hash)-1; i < j; i, j = i+1, j-1 {
+			hash[i], hash[j] = hash[j], hash[i]
+		}
+		return hex.EncodeToString(hash[:])
+	}
+
+	newClient := func(server *electrumServer) ClientCore {
+		client, err := NewElectrumClientCoreWithParams(server.listener.Addr().String(), false, params)
+		Expect(err).ShouldNot(HaveOccurred())
+		return client
+	}
+
+	It("should map the unspent outputs and their confirmations", func() {
+		server := newElectrumServer(params, func(method string, args []interface{}) interface{} {
+			if method != "blockchain.scripthash.listunspent" {
+				return nil
+			}
+			return json.RawMessage(electrumUnspentFixture)
+		})
+		defer server.listener.Close()
+		client := newClient(server)
+		address := newAddresses(1)[0]
+
+		utxos, err := client.GetUTXOs(context.Background(), address, 0, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(utxos).Should(HaveLen(3))
+		Expect(utxos[0].TxHash).Should(Equal("9c2ac5a7e5b4bf2c7ef7f0a6ce1d2a1c4a6c7a0b2e3f40516273849506a7b8c9"))
+		Expect(utxos[0].Vout).Should(Equal(uint32(1)))
+		Expect(utxos[0].Amount).Should(Equal(int64(50000)))
+		Expect(utxos[0].Address).Should(Equal(address))
+		Expect(utxos[0].Confirmations).Should(Equal(int64(10)))
+		Expect(utxos[0].BlockHeight).Should(Equal(int64(101)))
+		for _, utxo := range utxos[1:] {
+			Expect(utxo.Confirmations).Should(BeZero())
+			Expect(utxo.BlockHeight).Should(BeZero())
+		}
+		for _, utxo := range utxos {
+			Expect(utxo.ScriptPubKey).Should(Equal(utxos[0].ScriptPubKey))
+		}
+
+		confirmed, err := client.GetUTXOs(context.Background(), address, 0, 1)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(confirmed).Should(Equal(utxos[:1]))
+		limited, err := client.GetUTXOs(context.Background(), address, 2, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(limited).Should(Equal(utxos[:2]))
+	})
+
+	It("should count the confirmations of a transaction from the history of its outputs", func() {
+		address, err := btcutil.DecodeAddress(newAddresses(1)[0], params)
+		Expect(err).ShouldNot(HaveOccurred())
+		script, err := txscript.PayToAddrScript(address)
+		Expect(err).ShouldNot(HaveOccurred())
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil, nil))
+		tx.AddTxOut(wire.NewTxOut(10000, script))
+		buf := new(bytes.Buffer)
+		Expect(tx.Serialize(buf)).Should(Succeed())
+		txHash := tx.TxHash().String()
+
+		server := newElectrumServer(params, func(method string, args []interface{}) interface{} {
+			switch method {
+			case "blockchain.transaction.get":
+				return hex.EncodeToString(buf.Bytes())
+			case "blockchain.scripthash.get_history":
+				return []map[string]interface{}{{"tx_hash": txHash, "height": 105}}
+			}
+			return nil
+		})
+		defer server.listener.Close()
+
+		confirmations, err := newClient(server).Confirmations(context.Background(), txHash)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(confirmations).Should(Equal(int64(6)))
+	})
+
+	It("should list the outputs of many addresses in batches", func() {
+		// Every script hash has a single output, whose hash is the script
+		// hash itself.
+		server := newElectrumServer(params, func(method string, args []interface{}) interface{} {
+			if method != "blockchain.scripthash.listunspent" {
+				return nil
+			}
+			return []map[string]interface{}{{"tx_hash": args[0], "tx_pos": 0, "height": 101, "value": 1000}}
+		})
+		defer server.listener.Close()
+		client := newClient(server)
+		addresses := newAddresses(150)
+
+		utxos, err := client.(AddressBatcher).GetUTXOsMulti(context.Background(), addresses, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(server.batchSizes()).Should(Equal([]int{100, 50}))
+		Expect(utxos).Should(HaveLen(len(addresses)))
+		for _, address := range addresses {
+			Expect(utxos[address]).Should(HaveLen(1))
+			Expect(utxos[address][0].Address).Should(Equal(address))
+			Expect(utxos[address][0].TxHash).Should(Equal(scriptHash(utxos[address][0].ScriptPubKey)))
+			Expect(utxos[address][0].Confirmations).Should(Equal(int64(10)))
+		}
+	})
+})
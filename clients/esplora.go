@@ -0,0 +1,618 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+type EsploraStatus struct {
+	Confirmed   bool   `json:"confirmed"`
+	BlockHeight int64  `json:"block_height"`
+	BlockHash   string `json:"block_hash"`
+	BlockTime   int64  `json:"block_time"`
+}
+
+type EsploraUTXO struct {
+	TxID   string        `json:"txid"`
+	Vout   uint32        `json:"vout"`
+	Value  int64         `json:"value"`
+	Status EsploraStatus `json:"status"`
+}
+
+type EsploraOutput struct {
+	ScriptPubKey        string `json:"scriptpubkey"`
+	ScriptPubKeyAddress string `json:"scriptpubkey_address"`
+	ScriptPubKeyType    string `json:"scriptpubkey_type"`
+	Value               int64  `json:"value"`
+}
+
+type EsploraInput struct {
+	TxID       string         `json:"txid"`
+	Vout       uint32         `json:"vout"`
+	PrevOut    *EsploraOutput `json:"prevout"`
+	ScriptSig  string         `json:"scriptsig"`
+	Witness    []string       `json:"witness"`
+	IsCoinbase bool           `json:"is_coinbase"`
+	Sequence   uint32         `json:"sequence"`
+}
+
+type EsploraTransaction struct {
+	TxID     string          `json:"txid"`
+	Version  int32           `json:"version"`
+	LockTime uint32          `json:"locktime"`
+	Inputs   []EsploraInput  `json:"vin"`
+	Outputs  []EsploraOutput `json:"vout"`
+	Size     int64           `json:"size"`
+	Weight   int64           `json:"weight"`
+	Fee      int64           `json:"fee"`
+	Status   EsploraStatus   `json:"status"`
+}
+
+type EsploraStats struct {
+	FundedTxoCount int64 `json:"funded_txo_count"`
+	FundedTxoSum   int64 `json:"funded_txo_sum"`
+	SpentTxoCount  int64 `json:"spent_txo_count"`
+	SpentTxoSum    int64 `json:"spent_txo_sum"`
+	TxCount        int64 `json:"tx_count"`
+}
+
+type EsploraAddress struct {
+	Address      string       `json:"address"`
+	ChainStats   EsploraStats `json:"chain_stats"`
+	MempoolStats EsploraStats `json:"mempool_stats"`
+}
+
+// Received returns the total value received by the address, including
+// unconfirmed transactions.
+func (address EsploraAddress) Received() int64 {
+	return address.ChainStats.FundedTxoSum + address.MempoolStats.FundedTxoSum
+}
+
+// Balance returns the balance of the address, including unconfirmed
+// transactions.
+func (address EsploraAddress) Balance() int64 {
+	return address.Received() - address.ChainStats.SpentTxoSum - address.MempoolStats.SpentTxoSum
+}
+
+type EsploraBlock struct {
+	ID                string `json:"id"`
+	Height            int64  `json:"height"`
+	Version           int32  `json:"version"`
+	Timestamp         int64  `json:"timestamp"`
+	MedianTime        int64  `json:"mediantime"`
+	TxCount           int64  `json:"tx_count"`
+	Size              int64  `json:"size"`
+	Weight            int64  `json:"weight"`
+	MerkleRoot        string `json:"merkle_root"`
+	PreviousBlockHash string `json:"previousblockhash"`
+	Nonce             uint32 `json:"nonce"`
+	Bits              uint32 `json:"bits"`
+}
+
+type esploraClient struct {
+	URL    string
+	Params *chaincfg.Params
+	logging
+	retrying
+	requesting
+}
+
+// EsploraRegtestURL is the default address of the HTTP API of electrs when it
+// runs against a local regtest node.
+const EsploraRegtestURL = "http://127.0.0.1:3002"
+
+// NewEsploraClientCore returns a ClientCore connected to the public Esplora
+// instance at blockstream.info for the given network, or to a local electrs
+// at EsploraRegtestURL for regtest.
+func NewEsploraClientCore(network string) (ClientCore, error) {
+	network = strings.ToLower(network)
+	switch network {
+	case "mainnet":
+		return NewEsploraClientCoreWithURL("https://blockstream.info/api", &chaincfg.MainNetParams), nil
+	case "testnet", "testnet3", "":
+		return NewEsploraClientCoreWithURL("https://blockstream.info/testnet/api", &chaincfg.TestNet3Params), nil
+	case "regtest":
+		return NewEsploraClientCoreWithURL(EsploraRegtestURL, &chaincfg.RegressionNetParams), nil
+	default:
+		return nil, errors.NewErrUnsupportedNetwork(network)
+	}
+}
+
+// NewEsploraClientCoreWithURL returns a ClientCore connected to a self-hosted
+// Esplora instance, for example a local electrs running against regtest.
+func NewEsploraClientCoreWithURL(url string, params *chaincfg.Params) ClientCore {
+	return &esploraClient{
+		URL:    strings.TrimSuffix(url, "/"),
+		Params: params,
+	}
+}
+
+func (client *esploraClient) NetworkParams() *chaincfg.Params {
+	return client.Params
+}
+
+func (client *esploraClient) GetUTXOs(ctx context.Context, address string, limit, confitmations int64) ([]UTXO, error) {
+	unspents := []EsploraUTXO{}
+	if err := client.get(ctx, fmt.Sprintf("/address/%s/utxo", address), &unspents); err != nil {
+		return nil, err
+	}
+	height, err := client.TipHeight(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// All outputs of an address share the same script, so it is only fetched
+	// once.
+	var scriptPubKey string
+	utxos := []UTXO{}
+	for _, unspent := range unspents {
+		if limit > 0 && int64(len(utxos)) >= limit {
+			break
+		}
+		confirmations := esploraConfirmations(height, unspent.Status)
+		if confirmations < confitmations {
+			continue
+		}
+		if scriptPubKey == "" {
+			tx, err := client.GetRawTransaction(ctx, unspent.TxID)
+			if err != nil {
+				return nil, err
+			}
+			if int(unspent.Vout) >= len(tx.Outputs) {
+				return nil, fmt.Errorf("transaction %s does not have an output at index %d", unspent.TxID, unspent.Vout)
+			}
+			scriptPubKey = tx.Outputs[unspent.Vout].ScriptPubKey
+		}
+		utxos = append(utxos, UTXO{
+			TxHash:        unspent.TxID,
+			Amount:        unspent.Value,
+			ScriptPubKey:  scriptPubKey,
+			Vout:          unspent.Vout,
+			Confirmations: confirmations,
+			BlockHeight:   unspent.Status.BlockHeight,
+			Address:       address,
+		})
+	}
+	return utxos, nil
+}
+
+// GetUTXOsMulti fetches the outputs of the addresses concurrently, as Esplora
+// has no endpoint returning the outputs of several addresses.
+func (client *esploraClient) GetUTXOsMulti(ctx context.Context, addresses []string, confirmations int64) (map[string][]UTXO, error) {
+	return getUTXOsConcurrently(ctx, client, addresses, confirmations)
+}
+
+func (client *esploraClient) GetUTXO(ctx context.Context, txHash string, vout uint32) (UTXO, error) {
+	tx, err := client.GetRawTransaction(ctx, txHash)
+	if err != nil {
+		return UTXO{}, err
+	}
+	if int(vout) >= len(tx.Outputs) {
+		return UTXO{}, fmt.Errorf("transaction %s does not have an output at index %d", txHash, vout)
+	}
+	confirmations, err := client.Confirmations(ctx, txHash)
+	if err != nil {
+		return UTXO{}, err
+	}
+	return UTXO{
+		TxHash:        txHash,
+		Amount:        tx.Outputs[vout].Value,
+		ScriptPubKey:  tx.Outputs[vout].ScriptPubKey,
+		Vout:          vout,
+		Confirmations: confirmations,
+		BlockHeight:   tx.Status.BlockHeight,
+		Address:       tx.Outputs[vout].ScriptPubKeyAddress,
+	}, nil
+}
+
+func (client *esploraClient) Confirmations(ctx context.Context, txHash string) (int64, error) {
+	status := EsploraStatus{}
+	if err := client.get(ctx, fmt.Sprintf("/tx/%s/status", txHash), &status); err != nil {
+		return 0, err
+	}
+	height, err := client.TipHeight(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return esploraConfirmations(height, status), nil
+}
+
+func (client *esploraClient) ScriptFunded(ctx context.Context, address string, value int64) (bool, int64, error) {
+	addressInfo, err := client.GetAddress(ctx, address)
+	if err != nil {
+		return false, 0, err
+	}
+	return addressInfo.Received() >= value, addressInfo.Received(), nil
+}
+
+func (client *esploraClient) ScriptRedeemed(ctx context.Context, address string, value int64) (bool, int64, error) {
+	addressInfo, err := client.GetAddress(ctx, address)
+	if err != nil {
+		return false, 0, err
+	}
+	return addressInfo.Received() >= value && addressInfo.Balance() == 0, addressInfo.Balance(), nil
+}
+
+func (client *esploraClient) ScriptRedemption(ctx context.Context, address string, value int64) (Redemption, error) {
+	addressInfo, err := client.GetAddress(ctx, address)
+	if err != nil {
+		return Redemption{}, err
+	}
+	txs, err := client.GetAddressTransactions(ctx, address)
+	if err != nil {
+		return Redemption{}, err
+	}
+	height, err := client.TipHeight(ctx)
+	if err != nil {
+		return Redemption{}, err
+	}
+
+	redemption := Redemption{
+		Redeemed: addressInfo.Received() >= value && addressInfo.Balance() == 0,
+		TxHashes: []string{},
+	}
+	for _, tx := range txs {
+		var amount int64
+		for _, input := range tx.Inputs {
+			if input.PrevOut != nil && input.PrevOut.ScriptPubKeyAddress == address {
+				amount += input.PrevOut.Value
+			}
+		}
+		if amount == 0 {
+			continue
+		}
+
+		conf := esploraConfirmations(height, tx.Status)
+		if len(redemption.TxHashes) == 0 || conf < redemption.Confirmations {
+			redemption.Confirmations = conf
+		}
+		redemption.TxHashes = append(redemption.TxHashes, tx.TxID)
+		redemption.Amount += amount
+	}
+	return redemption, nil
+}
+
+func (client *esploraClient) ScriptHistory(ctx context.Context, address string) ([]ScriptTx, error) {
+	txs, err := client.GetAddressTransactions(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	height, err := client.TipHeight(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]ScriptTx, 0, len(txs))
+	for _, tx := range txs {
+		scriptTx := ScriptTx{
+			TxHash:        tx.TxID,
+			Confirmations: esploraConfirmations(height, tx.Status),
+		}
+		for _, input := range tx.Inputs {
+			if input.PrevOut != nil && input.PrevOut.ScriptPubKeyAddress == address {
+				scriptTx.Spent += input.PrevOut.Value
+			}
+		}
+		for _, output := range tx.Outputs {
+			if output.ScriptPubKeyAddress == address {
+				scriptTx.Received += output.Value
+			}
+		}
+		history = append(history, scriptTx)
+	}
+	return history, nil
+}
+
+func (client *esploraClient) ScriptSpent(ctx context.Context, script, spender string) (bool, string, error) {
+	spend, spent, err := client.ScriptSpendDetails(ctx, script, spender)
+	return spent, spend.SigScript, err
+}
+
+func (client *esploraClient) ScriptSpendDetails(ctx context.Context, script, spender string) (ScriptSpend, bool, error) {
+	txs, err := client.GetAddressTransactions(ctx, script)
+	if err != nil {
+		return ScriptSpend{}, false, err
+	}
+	for _, tx := range txs {
+		for i, input := range tx.Inputs {
+			if input.PrevOut != nil && input.PrevOut.ScriptPubKeyAddress == script {
+				return ScriptSpend{
+					TxHash:     tx.TxID,
+					InputIndex: uint32(i),
+					SigScript:  input.ScriptSig,
+					Witness:    input.Witness,
+				}, true, nil
+			}
+		}
+	}
+	return ScriptSpend{}, false, nil
+}
+
+func (client *esploraClient) GetBlockHeader(ctx context.Context, hashOrHeight string) (*wire.BlockHeader, error) {
+	hash, err := client.blockHash(ctx, hashOrHeight)
+	if err != nil {
+		return nil, err
+	}
+	header := new(wire.BlockHeader)
+	err = backoff(ctx, client.retryPolicy(), client.log(), func() error {
+		respBytes, err := client.fetch(ctx, fmt.Sprintf("/block/%s/header", hash))
+		if err != nil {
+			return err
+		}
+		headerBytes, err := hex.DecodeString(strings.TrimSpace(string(respBytes)))
+		if err != nil {
+			return err
+		}
+		return header.Deserialize(bytes.NewReader(headerBytes))
+	})
+	return header, err
+}
+
+func (client *esploraClient) GetBlock(ctx context.Context, hashOrHeight string) (*wire.MsgBlock, error) {
+	hash, err := client.blockHash(ctx, hashOrHeight)
+	if err != nil {
+		return nil, err
+	}
+	block := new(wire.MsgBlock)
+	err = backoff(ctx, client.retryPolicy(), client.log(), func() error {
+		respBytes, err := client.fetch(ctx, fmt.Sprintf("/block/%s/raw", hash))
+		if err != nil {
+			return err
+		}
+		return block.Deserialize(bytes.NewReader(respBytes))
+	})
+	return block, err
+}
+
+func (client *esploraClient) RawTransaction(ctx context.Context, txHash string) (*wire.MsgTx, error) {
+	var tx *wire.MsgTx
+	err := backoff(ctx, client.retryPolicy(), client.log(), func() error {
+		respBytes, err := client.fetch(ctx, fmt.Sprintf("/tx/%s/hex", txHash))
+		if err != nil {
+			return err
+		}
+		tx, err = deserializeTx(string(respBytes))
+		return err
+	})
+	return tx, err
+}
+
+func (client *esploraClient) PublishTransaction(ctx context.Context, stx *wire.MsgTx) error {
+	var stxBuffer bytes.Buffer
+	stxBuffer.Grow(stx.SerializeSize())
+	if err := stx.Serialize(&stxBuffer); err != nil {
+		return err
+	}
+
+	resp, err := client.httpPost(ctx, fmt.Sprintf("%s/tx", client.URL), "text/plain", strings.NewReader(hex.EncodeToString(stxBuffer.Bytes())))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return HTTPError{resp.StatusCode, string(respBytes)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.NewErrBitcoinSubmitTx(string(respBytes))
+	}
+	return nil
+}
+
+func (client *esploraClient) ChainTip(ctx context.Context) (ChainTip, error) {
+	var hash string
+	err := backoff(ctx, client.retryPolicy(), client.log(), func() error {
+		respBytes, err := client.fetch(ctx, "/blocks/tip/hash")
+		if err != nil {
+			return err
+		}
+		hash = strings.TrimSpace(string(respBytes))
+		return nil
+	})
+	if err != nil {
+		return ChainTip{}, err
+	}
+	block := EsploraBlock{}
+	if err := client.get(ctx, fmt.Sprintf("/block/%s", hash), &block); err != nil {
+		return ChainTip{}, err
+	}
+	return ChainTip{
+		Height:     block.Height,
+		Hash:       block.ID,
+		Time:       block.Timestamp,
+		MedianTime: block.MedianTime,
+	}, nil
+}
+
+// MerkleBranch returns the merkle branch of the transaction, the block height
+// is not needed.
+func (client *esploraClient) MerkleBranch(ctx context.Context, txHash string, blockHeight int64) (MerkleBranch, error) {
+	resp := struct {
+		BlockHeight int64    `json:"block_height"`
+		Merkle      []string `json:"merkle"`
+		Pos         uint32   `json:"pos"`
+	}{}
+	if err := client.get(ctx, fmt.Sprintf("/tx/%s/merkle-proof", txHash), &resp); err != nil {
+		return MerkleBranch{}, err
+	}
+	return merkleBranch(resp.BlockHeight, resp.Pos, resp.Merkle)
+}
+
+// SpentBy returns the transaction spending the output, and false if the
+// output is unspent.
+func (client *esploraClient) SpentBy(ctx context.Context, txHash string, vout uint32) (Spend, bool, error) {
+	outSpend := struct {
+		Spent  bool          `json:"spent"`
+		TxID   string        `json:"txid"`
+		Status EsploraStatus `json:"status"`
+	}{}
+	if err := client.get(ctx, fmt.Sprintf("/tx/%s/outspend/%d", txHash, vout), &outSpend); err != nil {
+		return Spend{}, false, err
+	}
+	if !outSpend.Spent {
+		return Spend{}, false, nil
+	}
+	height, err := client.TipHeight(ctx)
+	if err != nil {
+		return Spend{}, false, err
+	}
+	return Spend{
+		TxHash:        outSpend.TxID,
+		Confirmations: esploraConfirmations(height, outSpend.Status),
+	}, true, nil
+}
+
+// GetAddress returns the funding and spending statistics of an address.
+func (client *esploraClient) GetAddress(ctx context.Context, address string) (EsploraAddress, error) {
+	addressInfo := EsploraAddress{}
+	err := client.get(ctx, fmt.Sprintf("/address/%s", address), &addressInfo)
+	return addressInfo, err
+}
+
+// GetAddressTransactions returns every transaction funding or spending from an
+// address, unconfirmed transactions first.
+func (client *esploraClient) GetAddressTransactions(ctx context.Context, address string) ([]EsploraTransaction, error) {
+	txs := []EsploraTransaction{}
+	if err := client.get(ctx, fmt.Sprintf("/address/%s/txs", address), &txs); err != nil {
+		return nil, err
+	}
+
+	// The first page contains up to 50 mempool transactions and 25 confirmed
+	// transactions, further confirmed transactions are paged 25 at a time.
+	page := txs
+	for {
+		confirmed := 0
+		for _, tx := range page {
+			if tx.Status.Confirmed {
+				confirmed++
+			}
+		}
+		if confirmed < 25 {
+			return txs, nil
+		}
+
+		page = []EsploraTransaction{}
+		lastSeen := txs[len(txs)-1].TxID
+		if err := client.get(ctx, fmt.Sprintf("/address/%s/txs/chain/%s", address, lastSeen), &page); err != nil {
+			return nil, err
+		}
+		txs = append(txs, page...)
+	}
+}
+
+// GetRawTransaction returns the transaction with the given hash.
+func (client *esploraClient) GetRawTransaction(ctx context.Context, txHash string) (EsploraTransaction, error) {
+	tx := EsploraTransaction{}
+	err := client.get(ctx, fmt.Sprintf("/tx/%s", txHash), &tx)
+	return tx, err
+}
+
+// TipHeight returns the height of the latest block.
+func (client *esploraClient) TipHeight(ctx context.Context) (int64, error) {
+	var height int64
+	err := backoff(ctx, client.retryPolicy(), client.log(), func() error {
+		respBytes, err := client.fetch(ctx, "/blocks/tip/height")
+		if err != nil {
+			return err
+		}
+		height, err = strconv.ParseInt(strings.TrimSpace(string(respBytes)), 10, 64)
+		return err
+	})
+	return height, err
+}
+
+// BlockHash returns the hash of the block at the given height.
+func (client *esploraClient) BlockHash(ctx context.Context, height int64) (string, error) {
+	var hash string
+	err := backoff(ctx, client.retryPolicy(), client.log(), func() error {
+		respBytes, err := client.fetch(ctx, fmt.Sprintf("/block-height/%d", height))
+		if err != nil {
+			return err
+		}
+		hash = strings.TrimSpace(string(respBytes))
+		return nil
+	})
+	return hash, err
+}
+
+// blockHash returns the hash of the block with the given hash or height.
+func (client *esploraClient) blockHash(ctx context.Context, hashOrHeight string) (string, error) {
+	hash, height, err := parseBlockID(hashOrHeight)
+	if err != nil {
+		return "", err
+	}
+	if hash != nil {
+		return hash.String(), nil
+	}
+	return client.BlockHash(ctx, height)
+}
+
+// EstimateFeeRate returns the rate estimated for the largest confirmation
+// target of the API that is within the given number of blocks.
+func (client *esploraClient) EstimateFeeRate(ctx context.Context, blocks int64) (int64, error) {
+	estimates := map[string]float64{}
+	if err := client.get(ctx, "/fee-estimates", &estimates); err != nil {
+		return 0, err
+	}
+	var target int64
+	var rate float64
+	for key, estimate := range estimates {
+		estimateTarget, err := strconv.ParseInt(key, 10, 64)
+		if err != nil || estimateTarget > blocks || estimateTarget <= target {
+			continue
+		}
+		target, rate = estimateTarget, estimate
+	}
+	if target == 0 {
+		return 0, fmt.Errorf("no fee estimate within %d blocks", blocks)
+	}
+	return int64(math.Ceil(rate)), nil
+}
+
+func (client *esploraClient) get(ctx context.Context, path string, response interface{}) error {
+	return backoff(ctx, client.retryPolicy(), client.log(), func() error {
+		respBytes, err := client.fetch(ctx, path)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(respBytes, response)
+	})
+}
+
+func (client *esploraClient) fetch(ctx context.Context, path string) ([]byte, error) {
+	resp, err := client.httpGet(ctx, client.URL+path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, HTTPError{resp.StatusCode, string(respBytes)}
+	}
+	return respBytes, nil
+}
+
+func esploraConfirmations(height int64, status EsploraStatus) int64 {
+	if !status.Confirmed {
+		return 0
+	}
+	return 1 + height - status.BlockHeight
+}
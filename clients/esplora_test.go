@@ -0,0 +1,143 @@
+package clients_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go/clients"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+const (
+	esploraAddress = "bcrt1q63gxjpemqpj4sq9gchdtf2ggc5zr0j98cuuqce"
+	esploraScript  = "0014d45069073b00655800a8c5dab4a908c50437c8a7"
+	esploraTxID    = "9c2ac5a7e5b4bf2c7ef7f0a6ce1d2a1c4a6c7a0b2e3f40516273849506a7b8c9"
+)
+
+// esploraUnspentFixture is the response of an Esplora instance listing the
+// unspent outputs of esploraAddress, a confirmed one and an unconfirmed one.
+const esploraUnspentFixture = `[
+	{"txid": "` + esploraTxID + `", "vout": 1, "value": 50000, "status": {"confirmed": true, "block_height": 101, "block_hash": "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206", "block_time": 1600000000}},
+	{"txid": "1d2e3f405162738495a6b7c8d9eafb0c1d2e3f405162738495a6b7c8d9eafb0c", "vout": 0, "value": 20000, "status": {"confirmed": false}}
+]`
+
+// esploraTxFixture is the transaction of the confirmed output of
+// esploraUnspentFixture.
+const esploraTxFixture = `{
+	"txid": "` + esploraTxID + `",
+	"version": 2,
+	"locktime": 0,
+	"vin": [],
+	"vout": [
+		{"scriptpubkey": "76a914d45069073b00655800a8c5dab4a908c50437c8a788ac", "scriptpubkey_address": "mzsZsj2HvcPZ8cqWoYmLBcHz6rHjS8LKk5", "scriptpubkey_type": "p2pkh", "value": 10000},
+		{"scriptpubkey": "` + esploraScript + `", "scriptpubkey_address": "` + esploraAddress + `", "scriptpubkey_type": "v0_p2wpkh", "value": 50000}
+	],
+	"status": {"confirmed": true, "block_height": 101}
+}`
+
+var _ = Describe("Esplora client", func() {
+	params := &chaincfg.RegressionNetParams
+
+	// addressTxs returns the transactions paying to esploraAddress numbered
+	// from start to end, as returned by Esplora, confirmed unless they are in
+	// the mempool.
+	addressTxs := func(start, end int, confirmed bool) []map[string]interface{} {
+		txs := []map[string]interface{}{}
+		for i := start; i < end; i++ {
+			status := map[string]interface{}{"confirmed": false}
+			if confirmed {
+				status = map[string]interface{}{"confirmed": true, "block_height": 100 - i}
+			}
+			txs = append(txs, map[string]interface{}{
+				"txid": fmt.Sprintf("%064x", i),
+				"vin":  []interface{}{},
+				"vout": []map[string]interface{}{{
+					"scriptpubkey":         esploraScript,
+					"scriptpubkey_address": esploraAddress,
+					"value":                1000,
+				}},
+				"status": status,
+			})
+		}
+		return txs
+	}
+
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		Expect(err).ShouldNot(HaveOccurred())
+		return string(data)
+	}
+
+	It("should map the unspent outputs and their confirmations", func() {
+		server := newFixtureServer(map[string]string{
+			"/address/" + esploraAddress + "/utxo": esploraUnspentFixture,
+			"/blocks/tip/height":                   "110",
+			"/tx/" + esploraTxID:                   esploraTxFixture,
+		})
+		defer server.Close()
+		client := NewEsploraClientCoreWithURL(server.URL, params)
+
+		utxos, err := client.GetUTXOs(context.Background(), esploraAddress, 0, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(utxos).Should(Equal([]UTXO{
+			{TxHash: esploraTxID, Amount: 50000, ScriptPubKey: esploraScript, Vout: 1, Confirmations: 10, BlockHeight: 101, Address: esploraAddress},
+			{TxHash: "1d2e3f405162738495a6b7c8d9eafb0c1d2e3f405162738495a6b7c8d9eafb0c", Amount: 20000, ScriptPubKey: esploraScript, Vout: 0, Address: esploraAddress},
+		}))
+
+		confirmed, err := client.GetUTXOs(context.Background(), esploraAddress, 0, 1)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(confirmed).Should(Equal(utxos[:1]))
+	})
+
+	It("should count the confirmations of transactions from the chain tip", func() {
+		server := newFixtureServer(map[string]string{
+			"/tx/" + esploraTxID + "/status":             `{"confirmed": true, "block_height": 105}`,
+			"/tx/" + fmt.Sprintf("%064x", 0) + "/status": `{"confirmed": false}`,
+			"/blocks/tip/height":                         "110",
+		})
+		defer server.Close()
+		client := NewEsploraClientCoreWithURL(server.URL, params)
+
+		confirmations, err := client.Confirmations(context.Background(), esploraTxID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(confirmations).Should(Equal(int64(6)))
+		confirmations, err = client.Confirmations(context.Background(), fmt.Sprintf("%064x", 0))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(confirmations).Should(BeZero())
+	})
+
+	It("should page through the confirmed transactions of an address", func() {
+		// The first page has the mempool transactions and the first 25
+		// confirmed ones, further pages start after the last one seen.
+		firstPage := append(addressTxs(0, 2, false), addressTxs(2, 27, true)...)
+		server := newFixtureServer(map[string]string{
+			"/address/" + esploraAddress + "/txs":                                   encode(firstPage),
+			"/address/" + esploraAddress + "/txs/chain/" + fmt.Sprintf("%064x", 26): encode(addressTxs(27, 52, true)),
+			"/address/" + esploraAddress + "/txs/chain/" + fmt.Sprintf("%064x", 51): encode(addressTxs(52, 55, true)),
+			"/blocks/tip/height": "110",
+		})
+		defer server.Close()
+		client := NewEsploraClientCoreWithURL(server.URL, params)
+
+		history, err := client.(ScriptHistorian).ScriptHistory(context.Background(), esploraAddress)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(history).Should(HaveLen(55))
+		for i, tx := range history {
+			Expect(tx.TxHash).Should(Equal(fmt.Sprintf("%064x", i)))
+			Expect(tx.Received).Should(Equal(int64(1000)))
+		}
+		Expect(history[0].Confirmations).Should(BeZero())
+		Expect(history[2].Confirmations).Should(Equal(int64(13)))
+		Expect(history[54].Confirmations).Should(Equal(int64(65)))
+		Expect(server.requested()).Should(Equal([]string{
+			"/address/" + esploraAddress + "/txs",
+			"/address/" + esploraAddress + "/txs/chain/" + fmt.Sprintf("%064x", 26),
+			"/address/" + esploraAddress + "/txs/chain/" + fmt.Sprintf("%064x", 51),
+			"/blocks/tip/height",
+		}))
+	})
+})
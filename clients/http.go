@@ -0,0 +1,88 @@
+package clients
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// DefaultHTTPTimeout is the timeout of the requests of clients calling HTTP
+// APIs until another http.Client is set.
+const DefaultHTTPTimeout = time.Minute
+
+var defaultHTTPClient = &http.Client{Timeout: DefaultHTTPTimeout}
+
+// HTTPClientSetter is implemented by the clients calling HTTP APIs, so that
+// the timeouts, proxies and transports of their requests can be configured.
+type HTTPClientSetter interface {
+	SetHTTPClient(httpClient *http.Client)
+}
+
+// NewProxyHTTPClient returns an http.Client sending requests through the
+// proxy, with the timeout. SOCKS5 proxies are supported, so requests are
+// routed through Tor with "socks5://127.0.0.1:9050".
+func NewProxyHTTPClient(proxy string, timeout time.Duration) (*http.Client, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+		Timeout:   timeout,
+	}, nil
+}
+
+// requesting is embedded by the clients calling HTTP APIs, and implements
+// HTTPClientSetter.
+type requesting struct {
+	doer *http.Client
+}
+
+func (r *requesting) SetHTTPClient(httpClient *http.Client) {
+	r.doer = httpClient
+}
+
+func (r *requesting) httpClient() *http.Client {
+	if r.doer == nil {
+		return defaultHTTPClient
+	}
+	return r.doer
+}
+
+// The requests of clients are bound to the context of the call, so that they
+// are cancelled when it is done instead of hanging until they time out. Other
+// failures to send them mean that the API is unavailable.
+
+func (r *requesting) httpGet(ctx context.Context, rawURL string) (*http.Response, error) {
+	request, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.do(ctx, request)
+}
+
+func (r *requesting) httpPost(ctx context.Context, rawURL, contentType string, body io.Reader) (*http.Response, error) {
+	request, err := http.NewRequest("POST", rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", contentType)
+	return r.do(ctx, request)
+}
+
+func (r *requesting) httpPostForm(ctx context.Context, rawURL string, form url.Values) (*http.Response, error) {
+	return r.httpPost(ctx, rawURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+}
+
+func (r *requesting) do(ctx context.Context, request *http.Request) (*http.Response, error) {
+	resp, err := r.httpClient().Do(request.WithContext(ctx))
+	if err != nil && ctx.Err() == nil {
+		return nil, errors.NewErrBackendUnavailable(request.URL.Host, err)
+	}
+	return resp, err
+}
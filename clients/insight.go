@@ -0,0 +1,464 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+type InsightUTXO struct {
+	Address       string `json:"address"`
+	TxID          string `json:"txid"`
+	Vout          uint32 `json:"vout"`
+	ScriptPubKey  string `json:"scriptPubKey"`
+	Satoshis      int64  `json:"satoshis"`
+	Height        int64  `json:"height"`
+	Confirmations int64  `json:"confirmations"`
+}
+
+type InsightAddress struct {
+	Address               string `json:"addrStr"`
+	TotalReceivedSat      int64  `json:"totalReceivedSat"`
+	TotalSentSat          int64  `json:"totalSentSat"`
+	BalanceSat            int64  `json:"balanceSat"`
+	UnconfirmedBalanceSat int64  `json:"unconfirmedBalanceSat"`
+	TxApperances          int64  `json:"txApperances"`
+}
+
+type InsightInput struct {
+	TxID      string `json:"txid"`
+	Vout      uint32 `json:"vout"`
+	Sequence  uint32 `json:"sequence"`
+	Address   string `json:"addr"`
+	ValueSat  int64  `json:"valueSat"`
+	ScriptSig struct {
+		Hex string `json:"hex"`
+	} `json:"scriptSig"`
+}
+
+type InsightOutput struct {
+	Value        string `json:"value"`
+	N            uint32 `json:"n"`
+	ScriptPubKey struct {
+		Hex       string   `json:"hex"`
+		Addresses []string `json:"addresses"`
+	} `json:"scriptPubKey"`
+	SpentTxID string `json:"spentTxId"`
+}
+
+type InsightTransaction struct {
+	TxID          string          `json:"txid"`
+	Version       int32           `json:"version"`
+	LockTime      uint32          `json:"locktime"`
+	Inputs        []InsightInput  `json:"vin"`
+	Outputs       []InsightOutput `json:"vout"`
+	BlockHash     string          `json:"blockhash"`
+	BlockHeight   int64           `json:"blockheight"`
+	Confirmations int64           `json:"confirmations"`
+	Time          int64           `json:"time"`
+	Fees          float64         `json:"fees"`
+}
+
+type InsightBlock struct {
+	Hash              string `json:"hash"`
+	Height            int64  `json:"height"`
+	Time              int64  `json:"time"`
+	PreviousBlockHash string `json:"previousblockhash"`
+}
+
+type insightClient struct {
+	URL    string
+	Params *chaincfg.Params
+	logging
+	retrying
+	requesting
+}
+
+// NewInsightClientCore returns a ClientCore connected to the Insight API of a
+// Bitcore node, for example "http://localhost:3001/insight-api".
+func NewInsightClientCore(url string, params *chaincfg.Params) ClientCore {
+	return &insightClient{
+		URL:    strings.TrimSuffix(url, "/"),
+		Params: params,
+	}
+}
+
+func (client *insightClient) NetworkParams() *chaincfg.Params {
+	return client.Params
+}
+
+func (client *insightClient) GetUTXOs(ctx context.Context, address string, limit, confitmations int64) ([]UTXO, error) {
+	unspents := []InsightUTXO{}
+	if err := client.get(ctx, fmt.Sprintf("/addr/%s/utxo", address), &unspents); err != nil {
+		return nil, err
+	}
+
+	utxos := []UTXO{}
+	for _, unspent := range unspents {
+		if limit > 0 && int64(len(utxos)) >= limit {
+			break
+		}
+		if unspent.Confirmations < confitmations {
+			continue
+		}
+		utxos = append(utxos, UTXO{
+			TxHash:        unspent.TxID,
+			Amount:        unspent.Satoshis,
+			ScriptPubKey:  unspent.ScriptPubKey,
+			Vout:          unspent.Vout,
+			Confirmations: unspent.Confirmations,
+			BlockHeight:   confirmedHeight(unspent.Height),
+			Address:       address,
+		})
+	}
+	return utxos, nil
+}
+
+func (client *insightClient) GetUTXO(ctx context.Context, txHash string, vout uint32) (UTXO, error) {
+	tx, err := client.GetRawTransaction(ctx, txHash)
+	if err != nil {
+		return UTXO{}, err
+	}
+	if int(vout) >= len(tx.Outputs) {
+		return UTXO{}, fmt.Errorf("transaction %s does not have an output at index %d", txHash, vout)
+	}
+	amount, err := insightValue(tx.Outputs[vout].Value)
+	if err != nil {
+		return UTXO{}, err
+	}
+	confirmations, err := client.Confirmations(ctx, txHash)
+	if err != nil {
+		return UTXO{}, err
+	}
+	return UTXO{
+		TxHash:        txHash,
+		Amount:        amount,
+		ScriptPubKey:  tx.Outputs[vout].ScriptPubKey.Hex,
+		Vout:          vout,
+		Confirmations: confirmations,
+		BlockHeight:   confirmedHeight(tx.BlockHeight),
+		Address:       scriptAddress(tx.Outputs[vout].ScriptPubKey.Hex, client.Params),
+	}, nil
+}
+
+func (client *insightClient) Confirmations(ctx context.Context, txHash string) (int64, error) {
+	tx, err := client.GetRawTransaction(ctx, txHash)
+	if err != nil {
+		return 0, err
+	}
+	return tx.Confirmations, nil
+}
+
+func (client *insightClient) ScriptFunded(ctx context.Context, address string, value int64) (bool, int64, error) {
+	addressInfo, err := client.GetAddress(ctx, address)
+	if err != nil {
+		return false, 0, err
+	}
+	return addressInfo.TotalReceivedSat >= value, addressInfo.TotalReceivedSat, nil
+}
+
+func (client *insightClient) ScriptRedeemed(ctx context.Context, address string, value int64) (bool, int64, error) {
+	addressInfo, err := client.GetAddress(ctx, address)
+	if err != nil {
+		return false, 0, err
+	}
+	balance := addressInfo.BalanceSat + addressInfo.UnconfirmedBalanceSat
+	return addressInfo.TotalReceivedSat >= value && balance == 0, balance, nil
+}
+
+func (client *insightClient) ScriptRedemption(ctx context.Context, address string, value int64) (Redemption, error) {
+	addressInfo, err := client.GetAddress(ctx, address)
+	if err != nil {
+		return Redemption{}, err
+	}
+	txs, err := client.GetAddressTransactions(ctx, address)
+	if err != nil {
+		return Redemption{}, err
+	}
+
+	balance := addressInfo.BalanceSat + addressInfo.UnconfirmedBalanceSat
+	redemption := Redemption{
+		Redeemed: addressInfo.TotalReceivedSat >= value && balance == 0,
+		TxHashes: []string{},
+	}
+	for _, tx := range txs {
+		var amount int64
+		for _, input := range tx.Inputs {
+			if input.Address == address {
+				amount += input.ValueSat
+			}
+		}
+		if amount == 0 {
+			continue
+		}
+		if len(redemption.TxHashes) == 0 || tx.Confirmations < redemption.Confirmations {
+			redemption.Confirmations = tx.Confirmations
+		}
+		redemption.TxHashes = append(redemption.TxHashes, tx.TxID)
+		redemption.Amount += amount
+	}
+	return redemption, nil
+}
+
+func (client *insightClient) ScriptHistory(ctx context.Context, address string) ([]ScriptTx, error) {
+	txs, err := client.GetAddressTransactions(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]ScriptTx, 0, len(txs))
+	for _, tx := range txs {
+		scriptTx := ScriptTx{
+			TxHash:        tx.TxID,
+			Confirmations: tx.Confirmations,
+		}
+		for _, input := range tx.Inputs {
+			if input.Address == address {
+				scriptTx.Spent += input.ValueSat
+			}
+		}
+		for _, output := range tx.Outputs {
+			if !containsAddress(output.ScriptPubKey.Addresses, address) {
+				continue
+			}
+			value, err := insightValue(output.Value)
+			if err != nil {
+				return nil, err
+			}
+			scriptTx.Received += value
+		}
+		history = append(history, scriptTx)
+	}
+	return history, nil
+}
+
+func (client *insightClient) ScriptSpent(ctx context.Context, script, spender string) (bool, string, error) {
+	spend, spent, err := client.ScriptSpendDetails(ctx, script, spender)
+	return spent, spend.SigScript, err
+}
+
+// ScriptSpendDetails finds the input spending from the script, and fetches its
+// transaction as Insight does not return the witnesses of inputs.
+func (client *insightClient) ScriptSpendDetails(ctx context.Context, script, spender string) (ScriptSpend, bool, error) {
+	txs, err := client.GetAddressTransactions(ctx, script)
+	if err != nil {
+		return ScriptSpend{}, false, err
+	}
+	for _, tx := range txs {
+		for i, input := range tx.Inputs {
+			if input.Address == script {
+				spend, err := rawScriptSpend(ctx, client, tx.TxID, i)
+				return spend, err == nil, err
+			}
+		}
+	}
+	return ScriptSpend{}, false, nil
+}
+
+// SpentBy returns the transaction spending the output, and false if the
+// output is unspent.
+func (client *insightClient) SpentBy(ctx context.Context, txHash string, vout uint32) (Spend, bool, error) {
+	tx, err := client.GetRawTransaction(ctx, txHash)
+	if err != nil {
+		return Spend{}, false, err
+	}
+	if int(vout) >= len(tx.Outputs) {
+		return Spend{}, false, fmt.Errorf("transaction %s does not have an output at index %d", txHash, vout)
+	}
+	spentTxID := tx.Outputs[vout].SpentTxID
+	if spentTxID == "" {
+		return Spend{}, false, nil
+	}
+	confirmations, err := client.Confirmations(ctx, spentTxID)
+	if err != nil {
+		return Spend{}, false, err
+	}
+	return Spend{TxHash: spentTxID, Confirmations: confirmations}, true, nil
+}
+
+func (client *insightClient) ChainTip(ctx context.Context) (ChainTip, error) {
+	status := struct {
+		LastBlockHash string `json:"lastblockhash"`
+	}{}
+	if err := client.get(ctx, "/status?q=getLastBlockHash", &status); err != nil {
+		return ChainTip{}, err
+	}
+
+	// Insight does not expose the median time past, so it is computed from the
+	// timestamps of the latest blocks.
+	var tip InsightBlock
+	timestamps := []int64{}
+	hash := status.LastBlockHash
+	for len(timestamps) < medianTimeBlocks && hash != "" {
+		block := InsightBlock{}
+		if err := client.get(ctx, fmt.Sprintf("/block/%s", hash), &block); err != nil {
+			return ChainTip{}, err
+		}
+		if len(timestamps) == 0 {
+			tip = block
+		}
+		timestamps = append(timestamps, block.Time)
+		hash = block.PreviousBlockHash
+	}
+	medianTime, err := medianTimePast(timestamps)
+	if err != nil {
+		return ChainTip{}, err
+	}
+	return ChainTip{
+		Height:     tip.Height,
+		Hash:       tip.Hash,
+		Time:       tip.Time,
+		MedianTime: medianTime,
+	}, nil
+}
+
+func (client *insightClient) GetBlockHeader(ctx context.Context, hashOrHeight string) (*wire.BlockHeader, error) {
+	block, err := client.GetBlock(ctx, hashOrHeight)
+	if err != nil {
+		return nil, err
+	}
+	return &block.Header, nil
+}
+
+func (client *insightClient) GetBlock(ctx context.Context, hashOrHeight string) (*wire.MsgBlock, error) {
+	hash, height, err := parseBlockID(hashOrHeight)
+	if err != nil {
+		return nil, err
+	}
+	blockHash := ""
+	if hash != nil {
+		blockHash = hash.String()
+	} else {
+		index := struct {
+			BlockHash string `json:"blockHash"`
+		}{}
+		if err := client.get(ctx, fmt.Sprintf("/block-index/%d", height), &index); err != nil {
+			return nil, err
+		}
+		blockHash = index.BlockHash
+	}
+
+	rawBlock := struct {
+		RawBlock string `json:"rawblock"`
+	}{}
+	if err := client.get(ctx, fmt.Sprintf("/rawblock/%s", blockHash), &rawBlock); err != nil {
+		return nil, err
+	}
+	return deserializeBlock(rawBlock.RawBlock)
+}
+
+func (client *insightClient) RawTransaction(ctx context.Context, txHash string) (*wire.MsgTx, error) {
+	rawTx := struct {
+		RawTx string `json:"rawtx"`
+	}{}
+	if err := client.get(ctx, fmt.Sprintf("/rawtx/%s", txHash), &rawTx); err != nil {
+		return nil, err
+	}
+	return deserializeTx(rawTx.RawTx)
+}
+
+func (client *insightClient) PublishTransaction(ctx context.Context, stx *wire.MsgTx) error {
+	var stxBuffer bytes.Buffer
+	stxBuffer.Grow(stx.SerializeSize())
+	if err := stx.Serialize(&stxBuffer); err != nil {
+		return err
+	}
+	reqBytes, err := json.Marshal(struct {
+		RawTx string `json:"rawtx"`
+	}{hex.EncodeToString(stxBuffer.Bytes())})
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.httpPost(ctx, fmt.Sprintf("%s/tx/send", client.URL), "application/json", bytes.NewReader(reqBytes))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return HTTPError{resp.StatusCode, string(respBytes)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.NewErrBitcoinSubmitTx(string(respBytes))
+	}
+	return nil
+}
+
+// GetAddress returns the received, sent and balance of an address.
+func (client *insightClient) GetAddress(ctx context.Context, address string) (InsightAddress, error) {
+	addressInfo := InsightAddress{}
+	err := client.get(ctx, fmt.Sprintf("/addr/%s?noTxList=1", address), &addressInfo)
+	return addressInfo, err
+}
+
+// GetAddressTransactions returns every transaction funding or spending from an
+// address.
+func (client *insightClient) GetAddressTransactions(ctx context.Context, address string) ([]InsightTransaction, error) {
+	txs := []InsightTransaction{}
+	for page := 0; ; page++ {
+		resp := struct {
+			PagesTotal int                  `json:"pagesTotal"`
+			Txs        []InsightTransaction `json:"txs"`
+		}{}
+		if err := client.get(ctx, fmt.Sprintf("/txs?address=%s&pageNum=%d", address, page), &resp); err != nil {
+			return nil, err
+		}
+		txs = append(txs, resp.Txs...)
+		if page+1 >= resp.PagesTotal {
+			return txs, nil
+		}
+	}
+}
+
+// GetRawTransaction returns the transaction with the given hash.
+func (client *insightClient) GetRawTransaction(ctx context.Context, txHash string) (InsightTransaction, error) {
+	tx := InsightTransaction{}
+	err := client.get(ctx, fmt.Sprintf("/tx/%s", txHash), &tx)
+	return tx, err
+}
+
+func (client *insightClient) get(ctx context.Context, path string, response interface{}) error {
+	return backoff(ctx, client.retryPolicy(), client.log(), func() error {
+		resp, err := client.httpGet(ctx, client.URL+path)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		respBytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return HTTPError{resp.StatusCode, string(respBytes)}
+		}
+		return json.Unmarshal(respBytes, response)
+	})
+}
+
+// insightValue converts a BTC denominated value string to satoshis.
+func insightValue(value string) (int64, error) {
+	btc, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	amount, err := btcutil.NewAmount(btc)
+	if err != nil {
+		return 0, err
+	}
+	return int64(amount), nil
+}
@@ -0,0 +1,122 @@
+package clients_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go/clients"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+const (
+	insightAddress = "mzsZsj2HvcPZ8cqWoYmLBcHz6rHjS8LKk5"
+	insightScript  = "76a914d45069073b00655800a8c5dab4a908c50437c8a788ac"
+	insightTxID    = "9c2ac5a7e5b4bf2c7ef7f0a6ce1d2a1c4a6c7a0b2e3f40516273849506a7b8c9"
+)
+
+// insightUnspentFixture is the response of an Insight API listing the unspent
+// outputs of insightAddress, a confirmed one and an unconfirmed one.
+const insightUnspentFixture = `[
+	{"address": "` + insightAddress + `", "txid": "` + insightTxID + `", "vout": 1, "scriptPubKey": "` + insightScript + `", "amount": 0.0005, "satoshis": 50000, "height": 101, "confirmations": 10},
+	{"address": "` + insightAddress + `", "txid": "1d2e3f405162738495a6b7c8d9eafb0c1d2e3f405162738495a6b7c8d9eafb0c", "vout": 0, "scriptPubKey": "` + insightScript + `", "amount": 0.0002, "satoshis": 20000, "confirmations": 0}
+]`
+
+// insightTxFixture is the transaction of the confirmed output of
+// insightUnspentFixture, whose values are denominated in BTC.
+const insightTxFixture = `{
+	"txid": "` + insightTxID + `",
+	"version": 2,
+	"locktime": 0,
+	"vin": [],
+	"vout": [
+		{"value": "0.00010000", "n": 0, "scriptPubKey": {"hex": "0014d45069073b00655800a8c5dab4a908c50437c8a7", "addresses": ["bcrt1q63gxjpemqpj4sq9gchdtf2ggc5zr0j98cuuqce"]}},
+		{"value": "0.00050000", "n": 1, "scriptPubKey": {"hex": "` + insightScript + `", "addresses": ["` + insightAddress + `"]}}
+	],
+	"blockhash": "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206",
+	"blockheight": 101,
+	"confirmations": 10,
+	"time": 1600000000
+}`
+
+var _ = Describe("Insight client", func() {
+	params := &chaincfg.RegressionNetParams
+
+	newClient := func(server *fixtureServer) ClientCore {
+		return NewInsightClientCore(server.URL+"/insight-api/", params)
+	}
+
+	It("should map the unspent outputs and their confirmations", func() {
+		server := newFixtureServer(map[string]string{
+			"/insight-api/addr/" + insightAddress + "/utxo": insightUnspentFixture,
+		})
+		defer server.Close()
+		client := newClient(server)
+
+		utxos, err := client.GetUTXOs(context.Background(), insightAddress, 0, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(utxos).Should(Equal([]UTXO{
+			{TxHash: insightTxID, Amount: 50000, ScriptPubKey: insightScript, Vout: 1, Confirmations: 10, BlockHeight: 101, Address: insightAddress},
+			{TxHash: "1d2e3f405162738495a6b7c8d9eafb0c1d2e3f405162738495a6b7c8d9eafb0c", Amount: 20000, ScriptPubKey: insightScript, Vout: 0, Address: insightAddress},
+		}))
+
+		confirmed, err := client.GetUTXOs(context.Background(), insightAddress, 0, 1)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(confirmed).Should(Equal(utxos[:1]))
+		limited, err := client.GetUTXOs(context.Background(), insightAddress, 1, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(limited).Should(Equal(utxos[:1]))
+	})
+
+	It("should convert the values of transaction outputs to satoshis", func() {
+		server := newFixtureServer(map[string]string{
+			"/insight-api/tx/" + insightTxID: insightTxFixture,
+		})
+		defer server.Close()
+		client := newClient(server)
+
+		utxo, err := client.GetUTXO(context.Background(), insightTxID, 1)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(utxo).Should(Equal(UTXO{TxHash: insightTxID, Amount: 50000, ScriptPubKey: insightScript, Vout: 1, Confirmations: 10, BlockHeight: 101, Address: insightAddress}))
+		confirmations, err := client.Confirmations(context.Background(), insightTxID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(confirmations).Should(Equal(int64(10)))
+	})
+
+	It("should page through the transactions of an address", func() {
+		// page returns a page, out of pagesTotal, of the transactions of
+		// insightAddress with the given numbers. Each of them receives 0.001
+		// BTC and has as many confirmations as its number.
+		page := func(pagesTotal int, numbers ...int) string {
+			txs := []map[string]interface{}{}
+			for _, i := range numbers {
+				txs = append(txs, map[string]interface{}{
+					"txid":          fmt.Sprintf("%064x", i),
+					"vin":           []interface{}{},
+					"vout":          []map[string]interface{}{{"value": "0.00100000", "n": 0, "scriptPubKey": map[string]interface{}{"hex": insightScript, "addresses": []string{insightAddress}}}},
+					"confirmations": i,
+				})
+			}
+			data, err := json.Marshal(map[string]interface{}{"pagesTotal": pagesTotal, "txs": txs})
+			Expect(err).ShouldNot(HaveOccurred())
+			return string(data)
+		}
+		server := newFixtureServer(map[string]string{
+			"/insight-api/txs?address=" + insightAddress + "&pageNum=0": page(2, 0, 1),
+			"/insight-api/txs?address=" + insightAddress + "&pageNum=1": page(2, 2),
+		})
+		defer server.Close()
+		client := newClient(server)
+
+		history, err := client.(ScriptHistorian).ScriptHistory(context.Background(), insightAddress)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(history).Should(HaveLen(3))
+		for i, tx := range history {
+			Expect(tx).Should(Equal(ScriptTx{TxHash: fmt.Sprintf("%064x", i), Received: 100000, Confirmations: int64(i)}))
+		}
+		Expect(server.requested()).Should(HaveLen(2))
+	})
+})
@@ -0,0 +1,57 @@
+package clients
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// Logger logs the progress of clients, such as the retries of failed requests.
+// A logrus.FieldLogger is a Logger, see NewLogrusLogger.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// LoggerSetter is implemented by the clients that log. Clients log nothing
+// until a logger is set.
+type LoggerSetter interface {
+	SetLogger(logger Logger)
+}
+
+// NewLogrusLogger returns a Logger writing to the logrus logger, or to the
+// standard logrus logger if it is nil.
+func NewLogrusLogger(logger logrus.FieldLogger) Logger {
+	if logger == nil {
+		return logrus.StandardLogger()
+	}
+	return logger
+}
+
+// NopLogger returns a Logger discarding everything.
+func NopLogger() Logger {
+	return nopLogger{}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+func (nopLogger) Infof(format string, args ...interface{})  {}
+func (nopLogger) Warnf(format string, args ...interface{})  {}
+func (nopLogger) Errorf(format string, args ...interface{}) {}
+
+// logging is embedded by the clients that log, and implements LoggerSetter.
+type logging struct {
+	logger Logger
+}
+
+func (l *logging) SetLogger(logger Logger) {
+	l.logger = logger
+}
+
+func (l *logging) log() Logger {
+	if l.logger == nil {
+		return nopLogger{}
+	}
+	return l.logger
+}
@@ -18,6 +18,8 @@ import (
 type mercuryClient struct {
 	URL    string
 	Params *chaincfg.Params
+	logging
+	requesting
 }
 
 func NewMercuryClientCore(network string) (ClientCore, error) {
@@ -38,13 +40,22 @@ func NewMercuryClientCore(network string) (ClientCore, error) {
 	}
 }
 
+// NewMercuryClientCoreWithURL returns a ClientCore connected to a self-hosted
+// Mercury instance, for example one running against a regtest node.
+func NewMercuryClientCoreWithURL(url string, params *chaincfg.Params) ClientCore {
+	return &mercuryClient{
+		URL:    strings.TrimSuffix(url, "/"),
+		Params: params,
+	}
+}
+
 func (client *mercuryClient) NetworkParams() *chaincfg.Params {
 	return client.Params
 }
 
 func (client *mercuryClient) GetUTXOs(ctx context.Context, address string, limit, confitmations int64) ([]UTXO, error) {
 	utxos := []UTXO{}
-	resp, err := http.Get(fmt.Sprintf("%s/utxo/%s?limit=%d&confirmations=%d", client.URL, address, limit, confitmations))
+	resp, err := client.httpGet(ctx, fmt.Sprintf("%s/utxo/%s?limit=%d&confirmations=%d", client.URL, address, limit, confitmations))
 	if err != nil || resp.StatusCode != http.StatusOK {
 		if err != nil {
 			return utxos, err
@@ -59,12 +70,33 @@ func (client *mercuryClient) GetUTXOs(ctx context.Context, address string, limit
 	if err := json.NewDecoder(resp.Body).Decode(&utxos); err != nil {
 		return utxos, err
 	}
-	return utxos, nil
+
+	// Mercury does not report the confirmations of the outputs, so they are
+	// fetched once per transaction and the outputs filtered here. Nor does it
+	// report their block height, which is left as zero.
+	confirmations := map[string]int64{}
+	filtered := []UTXO{}
+	for _, utxo := range utxos {
+		conf, ok := confirmations[utxo.TxHash]
+		if !ok {
+			if conf, err = client.Confirmations(ctx, utxo.TxHash); err != nil {
+				return nil, err
+			}
+			confirmations[utxo.TxHash] = conf
+		}
+		if conf < confitmations {
+			continue
+		}
+		utxo.Confirmations = conf
+		utxo.Address = address
+		filtered = append(filtered, utxo)
+	}
+	return filtered, nil
 }
 
 func (client *mercuryClient) GetUTXO(ctx context.Context, txhash string, vout uint32) (UTXO, error) {
 	utxo := UTXO{}
-	resp, err := http.Get(fmt.Sprintf("%s/unspent/%s?vout=%d", client.URL, txhash, vout))
+	resp, err := client.httpGet(ctx, fmt.Sprintf("%s/unspent/%s?vout=%d", client.URL, txhash, vout))
 	if err != nil || resp.StatusCode != http.StatusOK {
 		if err != nil {
 			return utxo, err
@@ -79,12 +111,16 @@ func (client *mercuryClient) GetUTXO(ctx context.Context, txhash string, vout ui
 	if err := json.NewDecoder(resp.Body).Decode(&utxo); err != nil {
 		return utxo, err
 	}
+	if utxo.Confirmations, err = client.Confirmations(ctx, txhash); err != nil {
+		return UTXO{}, err
+	}
+	utxo.Address = scriptAddress(utxo.ScriptPubKey, client.Params)
 	return utxo, nil
 }
 
 func (client *mercuryClient) Confirmations(ctx context.Context, txHash string) (int64, error) {
 	var conf btc.GetConfirmationsResponse
-	resp, err := http.Get(fmt.Sprintf("%s/confirmations/%s", client.URL, txHash))
+	resp, err := client.httpGet(ctx, fmt.Sprintf("%s/confirmations/%s", client.URL, txHash))
 	if err != nil || resp.StatusCode != http.StatusOK {
 		if err != nil {
 			return 0, err
@@ -103,7 +139,7 @@ func (client *mercuryClient) Confirmations(ctx context.Context, txHash string) (
 
 func (client *mercuryClient) ScriptSpent(ctx context.Context, script, spender string) (bool, string, error) {
 	var scriptResp btc.GetScriptResponse
-	resp, err := http.Get(fmt.Sprintf("%s/script/spent/%s?spender=%s", client.URL, script, spender))
+	resp, err := client.httpGet(ctx, fmt.Sprintf("%s/script/spent/%s?spender=%s", client.URL, script, spender))
 	if err != nil || resp.StatusCode != http.StatusOK {
 		if err != nil {
 			return false, "", err
@@ -120,11 +156,13 @@ func (client *mercuryClient) ScriptSpent(ctx context.Context, script, spender st
 	return scriptResp.Status, scriptResp.Script, nil
 }
 
+// ScriptSpendDetails is not supported, as Mercury only returns the signature
+// script of the spending input.
 func (client *mercuryClient) ScriptFunded(ctx context.Context, address string, value int64) (bool, int64, error) {
-	fmt.Println(fmt.Sprintf("%s/script/funded/%s?value=%d", client.URL, address, value))
+	client.log().Debugf("GET %s/script/funded/%s?value=%d", client.URL, address, value)
 
 	var scriptResp btc.GetScriptResponse
-	resp, err := http.Get(fmt.Sprintf("%s/script/funded/%s?value=%d", client.URL, address, value))
+	resp, err := client.httpGet(ctx, fmt.Sprintf("%s/script/funded/%s?value=%d", client.URL, address, value))
 	if err != nil || resp.StatusCode != http.StatusOK {
 		if err != nil {
 			return false, 0, err
@@ -143,7 +181,7 @@ func (client *mercuryClient) ScriptFunded(ctx context.Context, address string, v
 
 func (client *mercuryClient) ScriptRedeemed(ctx context.Context, address string, value int64) (bool, int64, error) {
 	var scriptResp btc.GetScriptResponse
-	resp, err := http.Get(fmt.Sprintf("%s/script/redeemed/%s?value=%d", client.URL, address, value))
+	resp, err := client.httpGet(ctx, fmt.Sprintf("%s/script/redeemed/%s?value=%d", client.URL, address, value))
 	if err != nil || resp.StatusCode != http.StatusOK {
 		if err != nil {
 			return false, 0, err
@@ -176,7 +214,7 @@ func (client *mercuryClient) PublishTransaction(ctx context.Context, stx *wire.M
 		return err
 	}
 
-	if resp, err := http.Post(fmt.Sprintf("%s/tx", client.URL), "application/json", buf); err != nil || resp.StatusCode != http.StatusCreated {
+	if resp, err := client.httpPost(ctx, fmt.Sprintf("%s/tx", client.URL), "application/json", buf); err != nil || resp.StatusCode != http.StatusCreated {
 		if err != nil {
 			return err
 		}
@@ -184,7 +222,10 @@ func (client *mercuryClient) PublishTransaction(ctx context.Context, stx *wire.M
 		if err := json.NewDecoder(resp.Body).Decode(&respErr); err != nil {
 			return err
 		}
-		return fmt.Errorf("request failed with (%d): %s", resp.StatusCode, respErr.Error)
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return HTTPError{resp.StatusCode, respErr.Error}
+		}
+		return errors.NewErrBitcoinSubmitTx(respErr.Error)
 	}
 	return nil
 }
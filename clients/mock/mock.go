@@ -0,0 +1,749 @@
+// Package mock implements clients.ClientCore on an in-memory chain, so that
+// code using a client can be tested without a node, testnet funds or public
+// services. Addresses are funded out of thin air, published transactions are
+// checked like a node would check them, and blocks are mined on demand.
+package mock
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// BlockInterval is the time between the timestamps of mined blocks.
+const BlockInterval = 10 * time.Minute
+
+// medianTimeBlocks is the number of blocks used to compute the median time
+// past.
+const medianTimeBlocks = 11
+
+type entry struct {
+	tx *wire.MsgTx
+	// height is the height of the block of the transaction, -1 while it is
+	// in the mempool.
+	height int64
+}
+
+// Chain is an in-memory chain implementing clients.ClientCore, along with
+// clients.BlockHasher, clients.OutputSpender and clients.ScriptHistorian. It
+// starts with the genesis block of the network, and is safe for concurrent
+// use.
+type Chain struct {
+	params *chaincfg.Params
+
+	mu        *sync.RWMutex
+	blocks    []*wire.MsgBlock
+	heights   map[chainhash.Hash]int64
+	txs       map[chainhash.Hash]*entry
+	spends    map[wire.OutPoint]chainhash.Hash
+	mempool   []chainhash.Hash
+	published []*wire.MsgTx
+	nonce     int64
+}
+
+// NewChain returns a chain of the network holding its genesis block.
+func NewChain(params *chaincfg.Params) *Chain {
+	genesis := copyBlock(params.GenesisBlock)
+	return &Chain{
+		params:  params,
+		mu:      new(sync.RWMutex),
+		blocks:  []*wire.MsgBlock{genesis},
+		heights: map[chainhash.Hash]int64{genesis.BlockHash(): 0},
+		txs:     map[chainhash.Hash]*entry{},
+		spends:  map[wire.OutPoint]chainhash.Hash{},
+	}
+}
+
+// Fund adds a transaction paying the value to the address to the mempool, and
+// returns its hash. The transaction has no inputs, like a coinbase, and is
+// confirmed by Mine or Confirm.
+func (chain *Chain) Fund(address string, value int64) (string, error) {
+	script, err := chain.script(address)
+	if err != nil {
+		return "", err
+	}
+
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	chain.nonce++
+	tx, err := coinbase(chain.nonce, wire.NewTxOut(value, script))
+	if err != nil {
+		return "", err
+	}
+	chain.addToMempool(tx)
+	return tx.TxHash().String(), nil
+}
+
+// Mine mines n blocks, the first of which confirms every transaction of the
+// mempool, and returns their hashes.
+func (chain *Chain) Mine(n int) []string {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+
+	hashes := make([]string, n)
+	for i := range hashes {
+		hashes[i] = chain.mine(chain.mempool).String()
+	}
+	return hashes
+}
+
+// Confirm mines a block confirming the transaction along with its unconfirmed
+// ancestors, leaving the other transactions in the mempool, and returns the
+// hash of the block.
+func (chain *Chain) Confirm(txHash string) (string, error) {
+	hash, err := chainhash.NewHashFromStr(txHash)
+	if err != nil {
+		return "", err
+	}
+
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	entry, ok := chain.txs[*hash]
+	if !ok {
+		return "", fmt.Errorf("transaction %s not found", txHash)
+	}
+	if entry.height >= 0 {
+		return "", fmt.Errorf("transaction %s is already confirmed", txHash)
+	}
+
+	ancestors := map[chainhash.Hash]bool{}
+	chain.collectAncestors(*hash, ancestors)
+	confirmed := []chainhash.Hash{}
+	for _, hash := range chain.mempool {
+		if ancestors[hash] {
+			confirmed = append(confirmed, hash)
+		}
+	}
+	return chain.mine(confirmed).String(), nil
+}
+
+// Published returns the transactions published through the chain, in the
+// order they were published.
+func (chain *Chain) Published() []*wire.MsgTx {
+	chain.mu.RLock()
+	defer chain.mu.RUnlock()
+	published := make([]*wire.MsgTx, len(chain.published))
+	for i, tx := range chain.published {
+		published[i] = tx.Copy()
+	}
+	return published
+}
+
+// Mempool returns the hashes of the unconfirmed transactions.
+func (chain *Chain) Mempool() []string {
+	chain.mu.RLock()
+	defer chain.mu.RUnlock()
+	mempool := make([]string, len(chain.mempool))
+	for i, hash := range chain.mempool {
+		mempool[i] = hash.String()
+	}
+	return mempool
+}
+
+func (chain *Chain) NetworkParams() *chaincfg.Params {
+	return chain.params
+}
+
+func (chain *Chain) GetUTXOs(ctx context.Context, address string, limit, confitmations int64) ([]clients.UTXO, error) {
+	script, err := chain.script(address)
+	if err != nil {
+		return nil, err
+	}
+
+	chain.mu.RLock()
+	defer chain.mu.RUnlock()
+	utxos := []clients.UTXO{}
+	for _, hash := range chain.hashes() {
+		entry := chain.txs[hash]
+		for i, txOut := range entry.tx.TxOut {
+			if int64(len(utxos)) >= limit {
+				return utxos, nil
+			}
+			if _, spent := chain.spends[*wire.NewOutPoint(&hash, uint32(i))]; spent || !matches(txOut, script) {
+				continue
+			}
+			utxo := chain.utxo(hash, uint32(i))
+			if utxo.Confirmations >= confitmations {
+				utxos = append(utxos, utxo)
+			}
+		}
+	}
+	return utxos, nil
+}
+
+func (chain *Chain) GetUTXO(ctx context.Context, txHash string, vout uint32) (clients.UTXO, error) {
+	hash, err := chainhash.NewHashFromStr(txHash)
+	if err != nil {
+		return clients.UTXO{}, err
+	}
+
+	chain.mu.RLock()
+	defer chain.mu.RUnlock()
+	entry, ok := chain.txs[*hash]
+	if !ok {
+		return clients.UTXO{}, fmt.Errorf("transaction %s not found", txHash)
+	}
+	if vout >= uint32(len(entry.tx.TxOut)) {
+		return clients.UTXO{}, fmt.Errorf("transaction %s has no output %d", txHash, vout)
+	}
+	return chain.utxo(*hash, vout), nil
+}
+
+func (chain *Chain) Confirmations(ctx context.Context, txHash string) (int64, error) {
+	hash, err := chainhash.NewHashFromStr(txHash)
+	if err != nil {
+		return 0, err
+	}
+
+	chain.mu.RLock()
+	defer chain.mu.RUnlock()
+	entry, ok := chain.txs[*hash]
+	if !ok {
+		return 0, fmt.Errorf("transaction %s not found", txHash)
+	}
+	return chain.confirmations(entry), nil
+}
+
+func (chain *Chain) ScriptFunded(ctx context.Context, address string, value int64) (bool, int64, error) {
+	received, _, err := chain.received(address)
+	if err != nil {
+		return false, 0, err
+	}
+	return received >= value, received, nil
+}
+
+func (chain *Chain) ScriptRedeemed(ctx context.Context, address string, value int64) (bool, int64, error) {
+	received, balance, err := chain.received(address)
+	if err != nil {
+		return false, 0, err
+	}
+	return received >= value && balance == 0, balance, nil
+}
+
+func (chain *Chain) ScriptRedemption(ctx context.Context, address string, value int64) (clients.Redemption, error) {
+	received, balance, err := chain.received(address)
+	if err != nil {
+		return clients.Redemption{}, err
+	}
+	script, err := chain.script(address)
+	if err != nil {
+		return clients.Redemption{}, err
+	}
+
+	chain.mu.RLock()
+	defer chain.mu.RUnlock()
+	redemption := clients.Redemption{
+		Redeemed: received >= value && balance == 0,
+		TxHashes: []string{},
+	}
+	for _, hash := range chain.hashes() {
+		entry := chain.txs[hash]
+		var amount int64
+		for _, txIn := range entry.tx.TxIn {
+			if prevOut, ok := chain.prevOut(txIn.PreviousOutPoint); ok && matches(prevOut, script) {
+				amount += prevOut.Value
+			}
+		}
+		if amount == 0 {
+			continue
+		}
+
+		conf := chain.confirmations(entry)
+		if len(redemption.TxHashes) == 0 || conf < redemption.Confirmations {
+			redemption.Confirmations = conf
+		}
+		redemption.TxHashes = append(redemption.TxHashes, hash.String())
+		redemption.Amount += amount
+	}
+	return redemption, nil
+}
+
+func (chain *Chain) ScriptHistory(ctx context.Context, address string) ([]clients.ScriptTx, error) {
+	script, err := chain.script(address)
+	if err != nil {
+		return nil, err
+	}
+
+	chain.mu.RLock()
+	defer chain.mu.RUnlock()
+	history := []clients.ScriptTx{}
+	for _, hash := range chain.hashes() {
+		entry := chain.txs[hash]
+		scriptTx := clients.ScriptTx{
+			TxHash:        hash.String(),
+			Confirmations: chain.confirmations(entry),
+		}
+		for _, txIn := range entry.tx.TxIn {
+			if prevOut, ok := chain.prevOut(txIn.PreviousOutPoint); ok && matches(prevOut, script) {
+				scriptTx.Spent += prevOut.Value
+			}
+		}
+		for _, txOut := range entry.tx.TxOut {
+			if matches(txOut, script) {
+				scriptTx.Received += txOut.Value
+			}
+		}
+		if scriptTx.Received > 0 || scriptTx.Spent > 0 {
+			history = append(history, scriptTx)
+		}
+	}
+	return history, nil
+}
+
+// ScriptStatuses returns a digest of the history of each address, computed
+// like the status of Electrum script hash subscriptions from the hashes of its
+// transactions and whether they are confirmed.
+func (chain *Chain) ScriptStatuses(ctx context.Context, addresses []string) (map[string]string, error) {
+	statuses := make(map[string]string, len(addresses))
+	for _, address := range addresses {
+		history, err := chain.ScriptHistory(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		if len(history) == 0 {
+			statuses[address] = ""
+			continue
+		}
+		digest := sha256.New()
+		for _, scriptTx := range history {
+			fmt.Fprintf(digest, "%s:%t:", scriptTx.TxHash, scriptTx.Confirmations > 0)
+		}
+		statuses[address] = hex.EncodeToString(digest.Sum(nil))
+	}
+	return statuses, nil
+}
+
+func (chain *Chain) ScriptSpent(ctx context.Context, script, spender string) (bool, string, error) {
+	spend, spent, err := chain.ScriptSpendDetails(ctx, script, spender)
+	return spent, spend.SigScript, err
+}
+
+func (chain *Chain) ScriptSpendDetails(ctx context.Context, script, spender string) (clients.ScriptSpend, bool, error) {
+	pkScript, err := chain.script(script)
+	if err != nil {
+		return clients.ScriptSpend{}, false, err
+	}
+
+	chain.mu.RLock()
+	defer chain.mu.RUnlock()
+	for _, hash := range chain.hashes() {
+		tx := chain.txs[hash].tx
+		for i, txIn := range tx.TxIn {
+			if prevOut, ok := chain.prevOut(txIn.PreviousOutPoint); ok && matches(prevOut, pkScript) {
+				witness := make([]string, len(txIn.Witness))
+				for j, item := range txIn.Witness {
+					witness[j] = hex.EncodeToString(item)
+				}
+				return clients.ScriptSpend{
+					TxHash:     hash.String(),
+					InputIndex: uint32(i),
+					SigScript:  hex.EncodeToString(txIn.SignatureScript),
+					Witness:    witness,
+				}, true, nil
+			}
+		}
+	}
+	return clients.ScriptSpend{}, false, nil
+}
+
+func (chain *Chain) ChainTip(ctx context.Context) (clients.ChainTip, error) {
+	chain.mu.RLock()
+	defer chain.mu.RUnlock()
+
+	height := int64(len(chain.blocks) - 1)
+	tip := chain.blocks[height]
+	return clients.ChainTip{
+		Height:     height,
+		Hash:       tip.BlockHash().String(),
+		Time:       tip.Header.Timestamp.Unix(),
+		MedianTime: chain.medianTimePast(height),
+	}, nil
+}
+
+func (chain *Chain) RawTransaction(ctx context.Context, txHash string) (*wire.MsgTx, error) {
+	hash, err := chainhash.NewHashFromStr(txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	chain.mu.RLock()
+	defer chain.mu.RUnlock()
+	entry, ok := chain.txs[*hash]
+	if !ok {
+		return nil, fmt.Errorf("transaction %s: %w", txHash, errors.ErrTxNotFound)
+	}
+	return entry.tx.Copy(), nil
+}
+
+func (chain *Chain) GetBlockHeader(ctx context.Context, hashOrHeight string) (*wire.BlockHeader, error) {
+	block, err := chain.GetBlock(ctx, hashOrHeight)
+	if err != nil {
+		return nil, err
+	}
+	return &block.Header, nil
+}
+
+func (chain *Chain) GetBlock(ctx context.Context, hashOrHeight string) (*wire.MsgBlock, error) {
+	chain.mu.RLock()
+	defer chain.mu.RUnlock()
+
+	height, err := strconv.ParseInt(hashOrHeight, 10, 64)
+	if len(hashOrHeight) == 2*chainhash.HashSize {
+		hash, err := chainhash.NewHashFromStr(hashOrHeight)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if height, ok = chain.heights[*hash]; !ok {
+			return nil, fmt.Errorf("block %s not found", hashOrHeight)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("invalid block hash or height %q", hashOrHeight)
+	}
+	if height < 0 || height >= int64(len(chain.blocks)) {
+		return nil, fmt.Errorf("no block at height %d", height)
+	}
+	return copyBlock(chain.blocks[height]), nil
+}
+
+// PublishTransaction adds the transaction to the mempool. Transactions are
+// rejected like bitcoind rejects them, with the errors of the errors package,
+// if they are already known, spend missing or spent outputs, spend more than
+// their inputs, or fail the verification of their scripts.
+func (chain *Chain) PublishTransaction(ctx context.Context, stx *wire.MsgTx) error {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+
+	if err := chain.accept(stx); err != nil {
+		return err
+	}
+	tx := stx.Copy()
+	chain.addToMempool(tx)
+	chain.published = append(chain.published, tx)
+	return nil
+}
+
+// TestMempoolAccept returns the error with which PublishTransaction would
+// reject the transaction, without publishing it.
+func (chain *Chain) TestMempoolAccept(ctx context.Context, stx *wire.MsgTx) error {
+	chain.mu.RLock()
+	defer chain.mu.RUnlock()
+	return chain.accept(stx)
+}
+
+// accept returns the error with which the transaction is rejected, if any.
+func (chain *Chain) accept(stx *wire.MsgTx) error {
+	hash := stx.TxHash()
+	if entry, ok := chain.txs[hash]; ok {
+		if entry.height >= 0 {
+			return errors.NewErrBitcoinSubmitTx("transaction already in block chain")
+		}
+		return errors.NewErrBitcoinSubmitTx("txn-already-in-mempool")
+	}
+
+	var value int64
+	for i, txIn := range stx.TxIn {
+		prevOut, ok := chain.prevOut(txIn.PreviousOutPoint)
+		if !ok {
+			return errors.NewErrBitcoinSubmitTx("bad-txns-inputs-missingorspent")
+		}
+		if _, spent := chain.spends[txIn.PreviousOutPoint]; spent {
+			return errors.NewErrBitcoinSubmitTx("txn-mempool-conflict")
+		}
+		if chain.sequenceLocked(stx, txIn) {
+			return errors.NewErrBitcoinSubmitTx("non-BIP68-final")
+		}
+		engine, err := txscript.NewEngine(prevOut.PkScript, stx, i,
+			txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(stx), prevOut.Value)
+		if err != nil {
+			return errors.NewErrBitcoinSubmitTx(fmt.Sprintf("mandatory-script-verify-flag-failed (%v)", err))
+		}
+		if err := engine.Execute(); err != nil {
+			return errors.NewErrBitcoinSubmitTx(fmt.Sprintf("mandatory-script-verify-flag-failed (%v)", err))
+		}
+		value += prevOut.Value
+	}
+	for _, txOut := range stx.TxOut {
+		value -= txOut.Value
+	}
+	if value < 0 {
+		return errors.NewErrBitcoinSubmitTx("bad-txns-in-belowout")
+	}
+	return nil
+}
+
+// sequenceLocked returns whether the input is still locked by its relative
+// lock time, as defined in BIP68, for the transaction to be mined in the next
+// block. Only transactions of version 2 and above are subject to it.
+func (chain *Chain) sequenceLocked(stx *wire.MsgTx, txIn *wire.TxIn) bool {
+	if stx.Version < 2 || txIn.Sequence&wire.SequenceLockTimeDisabled != 0 {
+		return false
+	}
+	next := int64(len(chain.blocks))
+	// Outputs of unconfirmed transactions are treated as if they were mined
+	// in the next block.
+	prevHeight := next
+	if entry := chain.txs[txIn.PreviousOutPoint.Hash]; entry.height >= 0 {
+		prevHeight = entry.height
+	}
+	lock := int64(txIn.Sequence & wire.SequenceLockTimeMask)
+	if txIn.Sequence&wire.SequenceLockTimeIsSeconds != 0 {
+		minTime := chain.medianTimePast(prevHeight-1) + lock<<wire.SequenceLockTimeGranularity - 1
+		return minTime >= chain.medianTimePast(next-1)
+	}
+	return prevHeight+lock-1 >= next
+}
+
+// medianTimePast returns the median of the timestamps of the block at the
+// height and of the blocks before it, as defined in BIP113.
+func (chain *Chain) medianTimePast(height int64) int64 {
+	if height < 0 {
+		height = 0
+	}
+	first := height + 1 - medianTimeBlocks
+	if first < 0 {
+		first = 0
+	}
+	timestamps := []int64{}
+	for _, block := range chain.blocks[first : height+1] {
+		timestamps = append(timestamps, block.Header.Timestamp.Unix())
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps[len(timestamps)/2]
+}
+
+func (chain *Chain) BlockHash(ctx context.Context, height int64) (string, error) {
+	chain.mu.RLock()
+	defer chain.mu.RUnlock()
+	if height < 0 || height >= int64(len(chain.blocks)) {
+		return "", fmt.Errorf("no block at height %d", height)
+	}
+	return chain.blocks[height].BlockHash().String(), nil
+}
+
+func (chain *Chain) SpentBy(ctx context.Context, txHash string, vout uint32) (clients.Spend, bool, error) {
+	hash, err := chainhash.NewHashFromStr(txHash)
+	if err != nil {
+		return clients.Spend{}, false, err
+	}
+
+	chain.mu.RLock()
+	defer chain.mu.RUnlock()
+	spender, ok := chain.spends[*wire.NewOutPoint(hash, vout)]
+	if !ok {
+		return clients.Spend{}, false, nil
+	}
+	return clients.Spend{
+		TxHash:        spender.String(),
+		Confirmations: chain.confirmations(chain.txs[spender]),
+	}, true, nil
+}
+
+// script returns the public key script of the address.
+func (chain *Chain) script(address string) ([]byte, error) {
+	addr, err := btcutil.DecodeAddress(address, chain.params)
+	if err != nil {
+		return nil, err
+	}
+	if !addr.IsForNet(chain.params) {
+		return nil, fmt.Errorf("address %s is not an address of %s", address, chain.params.Name)
+	}
+	return txscript.PayToAddrScript(addr)
+}
+
+// received returns the total value received by the address and its balance,
+// including unconfirmed transactions.
+func (chain *Chain) received(address string) (int64, int64, error) {
+	script, err := chain.script(address)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	chain.mu.RLock()
+	defer chain.mu.RUnlock()
+	var received, balance int64
+	for hash, entry := range chain.txs {
+		for i, txOut := range entry.tx.TxOut {
+			if !matches(txOut, script) {
+				continue
+			}
+			received += txOut.Value
+			if _, spent := chain.spends[*wire.NewOutPoint(&hash, uint32(i))]; !spent {
+				balance += txOut.Value
+			}
+		}
+	}
+	return received, balance, nil
+}
+
+// hashes returns the hashes of the transactions, confirmed ones first in the
+// order of the chain, followed by the mempool.
+func (chain *Chain) hashes() []chainhash.Hash {
+	hashes := []chainhash.Hash{}
+	for _, block := range chain.blocks[1:] {
+		for _, tx := range block.Transactions {
+			hashes = append(hashes, tx.TxHash())
+		}
+	}
+	return append(hashes, chain.mempool...)
+}
+
+func (chain *Chain) utxo(hash chainhash.Hash, vout uint32) clients.UTXO {
+	entry := chain.txs[hash]
+	utxo := clients.UTXO{
+		TxHash:        hash.String(),
+		Amount:        entry.tx.TxOut[vout].Value,
+		ScriptPubKey:  hex.EncodeToString(entry.tx.TxOut[vout].PkScript),
+		Vout:          vout,
+		Confirmations: chain.confirmations(entry),
+	}
+	if entry.height > 0 {
+		utxo.BlockHeight = entry.height
+	}
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(entry.tx.TxOut[vout].PkScript, chain.params)
+	if err == nil && len(addrs) == 1 {
+		utxo.Address = addrs[0].EncodeAddress()
+	}
+	return utxo
+}
+
+func (chain *Chain) prevOut(outPoint wire.OutPoint) (*wire.TxOut, bool) {
+	entry, ok := chain.txs[outPoint.Hash]
+	if !ok || outPoint.Index >= uint32(len(entry.tx.TxOut)) {
+		return nil, false
+	}
+	return entry.tx.TxOut[outPoint.Index], true
+}
+
+func (chain *Chain) confirmations(entry *entry) int64 {
+	if entry.height < 0 {
+		return 0
+	}
+	return int64(len(chain.blocks)) - entry.height
+}
+
+func (chain *Chain) addToMempool(tx *wire.MsgTx) {
+	hash := tx.TxHash()
+	chain.txs[hash] = &entry{tx: tx, height: -1}
+	chain.mempool = append(chain.mempool, hash)
+	for _, txIn := range tx.TxIn {
+		if _, ok := chain.txs[txIn.PreviousOutPoint.Hash]; ok {
+			chain.spends[txIn.PreviousOutPoint] = hash
+		}
+	}
+}
+
+// collectAncestors adds the transaction and its unconfirmed ancestors to the
+// set.
+func (chain *Chain) collectAncestors(hash chainhash.Hash, ancestors map[chainhash.Hash]bool) {
+	entry, ok := chain.txs[hash]
+	if !ok || entry.height >= 0 || ancestors[hash] {
+		return
+	}
+	ancestors[hash] = true
+	for _, txIn := range entry.tx.TxIn {
+		chain.collectAncestors(txIn.PreviousOutPoint.Hash, ancestors)
+	}
+}
+
+// mine mines a block confirming the transactions of the mempool, which are
+// given in the order of the mempool, and returns its hash.
+func (chain *Chain) mine(confirmed []chainhash.Hash) chainhash.Hash {
+	height := int64(len(chain.blocks))
+	prev := chain.blocks[height-1]
+
+	cb, _ := coinbase(height)
+	txs := []*wire.MsgTx{cb}
+	chain.txs[cb.TxHash()] = &entry{tx: cb, height: height}
+	included := map[chainhash.Hash]bool{}
+	for _, hash := range confirmed {
+		entry := chain.txs[hash]
+		entry.height = height
+		txs = append(txs, entry.tx)
+		included[hash] = true
+	}
+	mempool := []chainhash.Hash{}
+	for _, hash := range chain.mempool {
+		if !included[hash] {
+			mempool = append(mempool, hash)
+		}
+	}
+	chain.mempool = mempool
+
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:    4,
+			PrevBlock:  prev.BlockHash(),
+			MerkleRoot: merkleRoot(txs),
+			Timestamp:  prev.Header.Timestamp.Add(BlockInterval),
+			Bits:       chain.params.PowLimitBits,
+		},
+		Transactions: txs,
+	}
+	hash := block.BlockHash()
+	chain.blocks = append(chain.blocks, block)
+	chain.heights[hash] = height
+	return hash
+}
+
+// coinbase returns a transaction without inputs paying the outputs. The nonce
+// is pushed in its signature script to make it unique.
+func coinbase(nonce int64, txOuts ...*wire.TxOut) (*wire.MsgTx, error) {
+	sigScript, err := txscript.NewScriptBuilder().AddInt64(nonce).Script()
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex),
+		SignatureScript:  sigScript,
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	for _, txOut := range txOuts {
+		tx.AddTxOut(txOut)
+	}
+	return tx, nil
+}
+
+func copyBlock(block *wire.MsgBlock) *wire.MsgBlock {
+	blockCopy := &wire.MsgBlock{Header: block.Header}
+	for _, tx := range block.Transactions {
+		blockCopy.AddTransaction(tx.Copy())
+	}
+	return blockCopy
+}
+
+func merkleRoot(txs []*wire.MsgTx) chainhash.Hash {
+	hashes := make([]chainhash.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.TxHash()
+	}
+	for len(hashes) > 1 {
+		if len(hashes)%2 == 1 {
+			hashes = append(hashes, hashes[len(hashes)-1])
+		}
+		next := make([]chainhash.Hash, len(hashes)/2)
+		for i := range next {
+			next[i] = chainhash.DoubleHashH(append(hashes[2*i][:], hashes[2*i+1][:]...))
+		}
+		hashes = next
+	}
+	return hashes[0]
+}
+
+func matches(txOut *wire.TxOut, script []byte) bool {
+	return string(txOut.PkScript) == string(script)
+}
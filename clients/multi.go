@@ -0,0 +1,563 @@
+package clients
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// SelectionMode decides which backend of a multi-backend client serves a read
+// call. Writes are always broadcast to every backend.
+type SelectionMode uint8
+
+// SelectionMode values.
+const (
+	// RoundRobin cycles through the backends in the order they were given.
+	RoundRobin = SelectionMode(iota)
+	// Weighted picks a random backend, proportional to its weight.
+	Weighted
+	// LowestLatency picks the backend with the lowest observed latency.
+	LowestLatency
+)
+
+// Backend is a ClientCore used by the multi-backend client. Weight is only
+// used by the Weighted selection mode, non-positive weights are treated as 1.
+// Name is optional and only used to identify the backend in reports.
+type Backend struct {
+	ClientCore
+	Weight int
+	Name   string
+}
+
+type multiClient struct {
+	mode     SelectionMode
+	backends []Backend
+	params   *chaincfg.Params
+
+	mu        *sync.Mutex
+	next      int
+	latencies []time.Duration
+	rand      *rand.Rand
+}
+
+// NewMultiClientCore returns a ClientCore that spreads read calls across the
+// given backends using the selection mode, falling back to the remaining
+// backends when the selected one fails. Transactions are published through
+// every backend. All backends must be connected to the same network.
+func NewMultiClientCore(mode SelectionMode, backends ...Backend) (ClientCore, error) {
+	if len(backends) == 0 {
+		return nil, errors.ErrNoBackends
+	}
+	params := backends[0].NetworkParams()
+	for _, backend := range backends[1:] {
+		if backend.NetworkParams().Name != params.Name {
+			return nil, errors.NewErrMismatchedNetworks(params.Name, backend.NetworkParams().Name)
+		}
+	}
+	return &multiClient{
+		mode:      mode,
+		backends:  backends,
+		params:    params,
+		mu:        new(sync.Mutex),
+		latencies: make([]time.Duration, len(backends)),
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// Backends returns the backends of the client, in the order they were given.
+func (client *multiClient) Backends() []Backend {
+	return client.backends
+}
+
+// SetLogger sets the logger of the backends that log.
+func (client *multiClient) SetLogger(logger Logger) {
+	for _, backend := range client.backends {
+		if setter, ok := backend.ClientCore.(LoggerSetter); ok {
+			setter.SetLogger(logger)
+		}
+	}
+}
+
+// SetRetryPolicy sets the retry policy of the backends that retry failed
+// requests.
+func (client *multiClient) SetRetryPolicy(policy RetryPolicy) {
+	for _, backend := range client.backends {
+		if setter, ok := backend.ClientCore.(RetryPolicySetter); ok {
+			setter.SetRetryPolicy(policy)
+		}
+	}
+}
+
+// SetHTTPClient sets the http.Client of the backends calling HTTP APIs.
+func (client *multiClient) SetHTTPClient(httpClient *http.Client) {
+	for _, backend := range client.backends {
+		if setter, ok := backend.ClientCore.(HTTPClientSetter); ok {
+			setter.SetHTTPClient(httpClient)
+		}
+	}
+}
+
+func (client *multiClient) NetworkParams() *chaincfg.Params {
+	return client.params
+}
+
+func (client *multiClient) GetUTXOs(ctx context.Context, address string, limit, confitmations int64) ([]UTXO, error) {
+	var utxos []UTXO
+	err := client.read(ctx, func(core ClientCore) error {
+		var err error
+		utxos, err = core.GetUTXOs(ctx, address, limit, confitmations)
+		return err
+	})
+	return utxos, err
+}
+
+func (client *multiClient) GetUTXO(ctx context.Context, txHash string, vout uint32) (UTXO, error) {
+	var utxo UTXO
+	err := client.read(ctx, func(core ClientCore) error {
+		var err error
+		utxo, err = core.GetUTXO(ctx, txHash, vout)
+		return err
+	})
+	return utxo, err
+}
+
+func (client *multiClient) Confirmations(ctx context.Context, txHash string) (int64, error) {
+	var conf int64
+	err := client.read(ctx, func(core ClientCore) error {
+		var err error
+		conf, err = core.Confirmations(ctx, txHash)
+		return err
+	})
+	return conf, err
+}
+
+func (client *multiClient) ScriptFunded(ctx context.Context, address string, value int64) (bool, int64, error) {
+	var funded bool
+	var amount int64
+	err := client.read(ctx, func(core ClientCore) error {
+		var err error
+		funded, amount, err = core.ScriptFunded(ctx, address, value)
+		return err
+	})
+	return funded, amount, err
+}
+
+func (client *multiClient) ScriptRedeemed(ctx context.Context, address string, value int64) (bool, int64, error) {
+	var redeemed bool
+	var amount int64
+	err := client.read(ctx, func(core ClientCore) error {
+		var err error
+		redeemed, amount, err = core.ScriptRedeemed(ctx, address, value)
+		return err
+	})
+	return redeemed, amount, err
+}
+
+// ScriptRedemption returns the transactions that redeemed the script, using
+// the backends that implement RedemptionFinder in turn until one of them
+// succeeds.
+func (client *multiClient) ScriptRedemption(ctx context.Context, address string, value int64) (Redemption, error) {
+	err := errors.NewErrUnsupportedOperation("ScriptRedemption", "multi")
+	for _, backend := range client.backends {
+		finder, ok := backend.ClientCore.(RedemptionFinder)
+		if !ok {
+			continue
+		}
+		var redemption Redemption
+		if redemption, err = finder.ScriptRedemption(ctx, address, value); err == nil {
+			return redemption, nil
+		}
+	}
+	return Redemption{}, err
+}
+
+func (client *multiClient) ScriptSpent(ctx context.Context, script, spender string) (bool, string, error) {
+	var spent bool
+	var sigScript string
+	err := client.read(ctx, func(core ClientCore) error {
+		var err error
+		spent, sigScript, err = core.ScriptSpent(ctx, script, spender)
+		return err
+	})
+	return spent, sigScript, err
+}
+
+// ScriptSpendDetails returns the input spending from the script, using the
+// backends that implement ScriptSpendFinder in turn until one of them
+// succeeds.
+func (client *multiClient) ScriptSpendDetails(ctx context.Context, script, spender string) (ScriptSpend, bool, error) {
+	err := errors.NewErrUnsupportedOperation("ScriptSpendDetails", "multi")
+	for _, backend := range client.backends {
+		finder, ok := backend.ClientCore.(ScriptSpendFinder)
+		if !ok {
+			continue
+		}
+		var spend ScriptSpend
+		var spent bool
+		if spend, spent, err = finder.ScriptSpendDetails(ctx, script, spender); err == nil {
+			return spend, spent, nil
+		}
+	}
+	return ScriptSpend{}, false, err
+}
+
+// ChainTip returns the latest block of the chain, using the backends that
+// implement ChainTipFetcher in turn until one of them succeeds.
+func (client *multiClient) ChainTip(ctx context.Context) (ChainTip, error) {
+	err := errors.NewErrUnsupportedOperation("ChainTip", "multi")
+	for _, backend := range client.backends {
+		fetcher, ok := backend.ClientCore.(ChainTipFetcher)
+		if !ok {
+			continue
+		}
+		var tip ChainTip
+		if tip, err = fetcher.ChainTip(ctx); err == nil {
+			return tip, nil
+		}
+	}
+	return ChainTip{}, err
+}
+
+// GetBlockHeader returns the header of the block with the given hash or at the given height, using
+// the backends that implement HeaderFetcher in turn until one of them succeeds.
+func (client *multiClient) GetBlockHeader(ctx context.Context, hashOrHeight string) (*wire.BlockHeader, error) {
+	err := errors.NewErrUnsupportedOperation("GetBlockHeader", "multi")
+	for _, backend := range client.backends {
+		fetcher, ok := backend.ClientCore.(HeaderFetcher)
+		if !ok {
+			continue
+		}
+		var header *wire.BlockHeader
+		if header, err = fetcher.GetBlockHeader(ctx, hashOrHeight); err == nil {
+			return header, nil
+		}
+	}
+	return nil, err
+}
+
+// GetBlock returns the block with the given hash or at the given height, using
+// the backends that implement BlockFetcher in turn until one of them succeeds.
+func (client *multiClient) GetBlock(ctx context.Context, hashOrHeight string) (*wire.MsgBlock, error) {
+	err := errors.NewErrUnsupportedOperation("GetBlock", "multi")
+	for _, backend := range client.backends {
+		fetcher, ok := backend.ClientCore.(BlockFetcher)
+		if !ok {
+			continue
+		}
+		var block *wire.MsgBlock
+		if block, err = fetcher.GetBlock(ctx, hashOrHeight); err == nil {
+			return block, nil
+		}
+	}
+	return nil, err
+}
+
+// RawTransaction returns the transaction with the given hash, using the
+// backends that implement TxFetcher in turn until one of them succeeds.
+func (client *multiClient) RawTransaction(ctx context.Context, txHash string) (*wire.MsgTx, error) {
+	err := errors.NewErrUnsupportedOperation("RawTransaction", "multi")
+	for _, backend := range client.backends {
+		fetcher, ok := backend.ClientCore.(TxFetcher)
+		if !ok {
+			continue
+		}
+		var tx *wire.MsgTx
+		if tx, err = fetcher.RawTransaction(ctx, txHash); err == nil {
+			return tx, nil
+		}
+	}
+	return nil, err
+}
+
+// GetUTXOsMulti returns the outputs of the addresses, using the backends that
+// implement AddressBatcher in turn until one of them succeeds. When none of
+// them does, the outputs of the addresses are fetched concurrently.
+func (client *multiClient) GetUTXOsMulti(ctx context.Context, addresses []string, confirmations int64) (map[string][]UTXO, error) {
+	for _, backend := range client.backends {
+		batcher, ok := backend.ClientCore.(AddressBatcher)
+		if !ok {
+			continue
+		}
+		if utxos, err := batcher.GetUTXOsMulti(ctx, addresses, confirmations); err == nil {
+			return utxos, nil
+		}
+	}
+	return getUTXOsConcurrently(ctx, client, addresses, confirmations)
+}
+
+// GetUTXOPage returns a page of the outputs of the address, using the
+// backends that implement UTXOPager in turn until one of them succeeds. When
+// none of them does, the page is cut from every output returned by GetUTXOs,
+// sorted by transaction hash and index so that successive pages follow the
+// same order whichever backend serves them. A non-positive limit returns
+// every output after the offset.
+func (client *multiClient) GetUTXOPage(ctx context.Context, address string, offset, limit, confirmations int64) ([]UTXO, error) {
+	for _, backend := range client.backends {
+		pager, ok := backend.ClientCore.(UTXOPager)
+		if !ok {
+			continue
+		}
+		if utxos, err := pager.GetUTXOPage(ctx, address, offset, limit, confirmations); err == nil {
+			return utxos, nil
+		}
+	}
+	utxos, err := client.GetUTXOs(ctx, address, 999999, confirmations)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(utxos, func(i, j int) bool {
+		if utxos[i].TxHash != utxos[j].TxHash {
+			return utxos[i].TxHash < utxos[j].TxHash
+		}
+		return utxos[i].Vout < utxos[j].Vout
+	})
+	if int64(len(utxos)) <= offset {
+		return []UTXO{}, nil
+	}
+	utxos = utxos[offset:]
+	if limit > 0 && int64(len(utxos)) > limit {
+		utxos = utxos[:limit]
+	}
+	return utxos, nil
+}
+
+// SpentBy returns the transaction spending the output, using the backends
+// that implement OutputSpender in turn until one of them succeeds.
+func (client *multiClient) SpentBy(ctx context.Context, txHash string, vout uint32) (Spend, bool, error) {
+	err := errors.NewErrUnsupportedOperation("SpentBy", "multi")
+	for _, backend := range client.backends {
+		spender, ok := backend.ClientCore.(OutputSpender)
+		if !ok {
+			continue
+		}
+		var spend Spend
+		var spent bool
+		if spend, spent, err = spender.SpentBy(ctx, txHash, vout); err == nil {
+			return spend, spent, nil
+		}
+	}
+	return Spend{}, false, err
+}
+
+// ScriptHistory returns the transactions funding and spending from the
+// script, using the backends that implement ScriptHistorian in turn until one
+// of them succeeds.
+func (client *multiClient) ScriptHistory(ctx context.Context, address string) ([]ScriptTx, error) {
+	err := errors.NewErrUnsupportedOperation("ScriptHistory", "multi")
+	for _, backend := range client.backends {
+		historian, ok := backend.ClientCore.(ScriptHistorian)
+		if !ok {
+			continue
+		}
+		var history []ScriptTx
+		if history, err = historian.ScriptHistory(ctx, address); err == nil {
+			return history, nil
+		}
+	}
+	return nil, err
+}
+
+// ScriptStatuses returns the statuses of the addresses, using the backends
+// that implement ScriptStatuser in turn until one of them succeeds. Statuses
+// are only comparable when returned by the same kind of backend.
+func (client *multiClient) ScriptStatuses(ctx context.Context, addresses []string) (map[string]string, error) {
+	err := errors.NewErrUnsupportedOperation("ScriptStatuses", "multi")
+	for _, backend := range client.backends {
+		statuser, ok := backend.ClientCore.(ScriptStatuser)
+		if !ok {
+			continue
+		}
+		var statuses map[string]string
+		if statuses, err = statuser.ScriptStatuses(ctx, addresses); err == nil {
+			return statuses, nil
+		}
+	}
+	return nil, err
+}
+
+// OutputUnspent returns whether the output is unspent, using the backends that
+// implement OutputChecker in turn until one of them succeeds.
+func (client *multiClient) OutputUnspent(ctx context.Context, txHash string, vout uint32) (bool, error) {
+	err := errors.NewErrUnsupportedOperation("OutputUnspent", "multi")
+	for _, backend := range client.backends {
+		checker, ok := backend.ClientCore.(OutputChecker)
+		if !ok {
+			continue
+		}
+		var unspent bool
+		if unspent, err = checker.OutputUnspent(ctx, txHash, vout); err == nil {
+			return unspent, nil
+		}
+	}
+	return false, err
+}
+
+// BlockHash returns the hash of the block at the given height, using the
+// backends that implement BlockHasher in turn until one of them succeeds.
+func (client *multiClient) BlockHash(ctx context.Context, height int64) (string, error) {
+	err := errors.NewErrUnsupportedOperation("BlockHash", "multi")
+	for _, backend := range client.backends {
+		hasher, ok := backend.ClientCore.(BlockHasher)
+		if !ok {
+			continue
+		}
+		var hash string
+		if hash, err = hasher.BlockHash(ctx, height); err == nil {
+			return hash, nil
+		}
+	}
+	return "", err
+}
+
+// TestMempoolAccept tests the transaction against the mempool of the first
+// backend that can test it.
+func (client *multiClient) TestMempoolAccept(ctx context.Context, stx *wire.MsgTx) error {
+	err := errors.NewErrUnsupportedOperation("TestMempoolAccept", "multi")
+	for _, backend := range client.backends {
+		tester, ok := backend.ClientCore.(MempoolAcceptTester)
+		if !ok {
+			continue
+		}
+		err = tester.TestMempoolAccept(ctx, stx)
+		if _, rejected := err.(*errors.SubmitTxError); err == nil || rejected {
+			return err
+		}
+	}
+	return err
+}
+
+// EstimateFeeRate returns the rate estimated by the first backend that
+// estimates fees successfully.
+func (client *multiClient) EstimateFeeRate(ctx context.Context, blocks int64) (int64, error) {
+	err := errors.NewErrUnsupportedOperation("EstimateFeeRate", "multi")
+	for _, backend := range client.backends {
+		estimator, ok := backend.ClientCore.(FeeEstimator)
+		if !ok {
+			continue
+		}
+		var rate int64
+		if rate, err = estimator.EstimateFeeRate(ctx, blocks); err == nil {
+			return rate, nil
+		}
+	}
+	return 0, err
+}
+
+// SubscribeBlocks subscribes to the blocks of the first backend that is
+// notified of them.
+func (client *multiClient) SubscribeBlocks(ctx context.Context) (<-chan BlockEvent, error) {
+	err := errors.NewErrUnsupportedOperation("SubscribeBlocks", "multi")
+	for _, backend := range client.backends {
+		subscriber, ok := backend.ClientCore.(BlockSubscriber)
+		if !ok {
+			continue
+		}
+		var events <-chan BlockEvent
+		if events, err = subscriber.SubscribeBlocks(ctx); err == nil {
+			return events, nil
+		}
+	}
+	return nil, err
+}
+
+// PublishTransaction publishes the transaction through every backend
+// concurrently, it succeeds if at least one of the backends accepts it. See
+// BroadcastAll.
+func (client *multiClient) PublishTransaction(ctx context.Context, stx *wire.MsgTx) error {
+	cores := make([]ClientCore, len(client.backends))
+	for i, backend := range client.backends {
+		cores[i] = backend.ClientCore
+	}
+	return BroadcastAll(ctx, stx, cores...)
+}
+
+// read calls f with the selected backend, and with every other backend in
+// turn until one of them succeeds or the context is done. Calls cut short by
+// the context say nothing of the backend, so their latency is not observed.
+func (client *multiClient) read(ctx context.Context, f func(ClientCore) error) error {
+	first := client.pick()
+	var err error
+	for i := range client.backends {
+		index := (first + i) % len(client.backends)
+		start := time.Now()
+		err = f(client.backends[index].ClientCore)
+		if ctx.Err() != nil {
+			return err
+		}
+		client.observe(index, time.Since(start), err)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (client *multiClient) pick() int {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	switch client.mode {
+	case Weighted:
+		total := 0
+		for _, backend := range client.backends {
+			total += weight(backend)
+		}
+		n := client.rand.Intn(total)
+		for i, backend := range client.backends {
+			if n < weight(backend) {
+				return i
+			}
+			n -= weight(backend)
+		}
+		return 0
+	case LowestLatency:
+		best := 0
+		for i, latency := range client.latencies {
+			// Backends that have not been used yet are preferred, so that
+			// every backend gets measured.
+			if latency == 0 {
+				return i
+			}
+			if latency < client.latencies[best] {
+				best = i
+			}
+		}
+		return best
+	default:
+		index := client.next
+		client.next = (client.next + 1) % len(client.backends)
+		return index
+	}
+}
+
+// observe records the latency of a call to the backend at the given index as
+// an exponentially weighted moving average. Failed calls are penalised so that
+// the LowestLatency mode moves away from unhealthy backends.
+func (client *multiClient) observe(index int, latency time.Duration, err error) {
+	if err != nil {
+		latency = 2*latency + time.Second
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.latencies[index] == 0 {
+		client.latencies[index] = latency
+		return
+	}
+	client.latencies[index] = (4*client.latencies[index] + latency) / 5
+}
+
+func weight(backend Backend) int {
+	if backend.Weight <= 0 {
+		return 1
+	}
+	return backend.Weight
+}
@@ -0,0 +1,206 @@
+package clients_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go/clients"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/clients/mock"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// countingBackend is a mock chain counting the calls to Confirmations, which
+// take delay and fail with err.
+type countingBackend struct {
+	*mock.Chain
+	calls int
+	delay time.Duration
+	err   error
+}
+
+func (backend *countingBackend) Confirmations(ctx context.Context, txHash string) (int64, error) {
+	backend.calls++
+	time.Sleep(backend.delay)
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return 0, backend.err
+}
+
+// coreOnly is a backend only implementing the methods of ClientCore, none of
+// the optional interfaces.
+type coreOnly struct {
+	ClientCore
+}
+
+var _ = Describe("Multi-backend client", func() {
+	params := &chaincfg.RegressionNetParams
+	const txHash = "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"
+
+	// newMultiClient returns a client of n counting backends.
+	newMultiClient := func(mode SelectionMode, n int) (ClientCore, []*countingBackend) {
+		counting := make([]*countingBackend, n)
+		backends := make([]Backend, n)
+		for i := range backends {
+			counting[i] = &countingBackend{Chain: mock.NewChain(params)}
+			backends[i] = Backend{ClientCore: counting[i], Weight: 1}
+		}
+		client, err := NewMultiClientCore(mode, backends...)
+		Expect(err).ShouldNot(HaveOccurred())
+		return client, counting
+	}
+
+	// newAddress returns the address of a new key.
+	newAddress := func() string {
+		key, err := btcec.NewPrivateKey(btcec.S256())
+		Expect(err).ShouldNot(HaveOccurred())
+		address, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(key.PubKey().SerializeCompressed()), params)
+		Expect(err).ShouldNot(HaveOccurred())
+		return address.EncodeAddress()
+	}
+
+	// calls returns the number of calls to every backend.
+	calls := func(backends []*countingBackend) []int {
+		n := make([]int, len(backends))
+		for i, backend := range backends {
+			n[i] = backend.calls
+		}
+		return n
+	}
+
+	Context("when selecting backends", func() {
+		It("should cycle through the backends in RoundRobin mode", func() {
+			client, backends := newMultiClient(RoundRobin, 3)
+			for i := 0; i < 6; i++ {
+				_, err := client.Confirmations(context.Background(), txHash)
+				Expect(err).ShouldNot(HaveOccurred())
+			}
+			Expect(calls(backends)).Should(Equal([]int{2, 2, 2}))
+		})
+
+		It("should pick backends in proportion to their weights in Weighted mode", func() {
+			light := &countingBackend{Chain: mock.NewChain(params)}
+			heavy := &countingBackend{Chain: mock.NewChain(params)}
+			client, err := NewMultiClientCore(Weighted, Backend{ClientCore: light, Weight: 1}, Backend{ClientCore: heavy, Weight: 9})
+			Expect(err).ShouldNot(HaveOccurred())
+			for i := 0; i < 1000; i++ {
+				_, err := client.Confirmations(context.Background(), txHash)
+				Expect(err).ShouldNot(HaveOccurred())
+			}
+			Expect(heavy.calls).Should(BeNumerically(">", 800))
+			Expect(light.calls).Should(BeNumerically(">", 0))
+		})
+
+		It("should prefer the fastest backend once each is measured in LowestLatency mode", func() {
+			client, backends := newMultiClient(LowestLatency, 2)
+			backends[0].delay = 20 * time.Millisecond
+			for i := 0; i < 5; i++ {
+				_, err := client.Confirmations(context.Background(), txHash)
+				Expect(err).ShouldNot(HaveOccurred())
+			}
+			Expect(calls(backends)).Should(Equal([]int{1, 4}))
+		})
+
+		It("should move away from failing backends in LowestLatency mode", func() {
+			client, backends := newMultiClient(LowestLatency, 2)
+			backends[1].err = fmt.Errorf("unavailable")
+			for i := 0; i < 5; i++ {
+				_, err := client.Confirmations(context.Background(), txHash)
+				Expect(err).ShouldNot(HaveOccurred())
+			}
+			Expect(calls(backends)).Should(Equal([]int{5, 1}))
+		})
+	})
+
+	Context("when a backend fails", func() {
+		It("should fall back to the other backends", func() {
+			client, backends := newMultiClient(RoundRobin, 3)
+			backends[0].err = fmt.Errorf("unavailable")
+			_, err := client.Confirmations(context.Background(), txHash)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(calls(backends)).Should(Equal([]int{1, 1, 0}))
+		})
+
+		It("should return the last error when every backend fails", func() {
+			client, backends := newMultiClient(RoundRobin, 2)
+			backends[0].err = fmt.Errorf("unavailable")
+			backends[1].err = fmt.Errorf("also unavailable")
+			_, err := client.Confirmations(context.Background(), txHash)
+			Expect(err).Should(MatchError("also unavailable"))
+		})
+
+		It("should not try the other backends once the context is done", func() {
+			client, backends := newMultiClient(RoundRobin, 3)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := client.Confirmations(ctx, txHash)
+			Expect(err).Should(Equal(context.Canceled))
+			Expect(calls(backends)).Should(Equal([]int{1, 0, 0}))
+		})
+	})
+
+	Context("when backends do not implement an optional interface", func() {
+		It("should only use the backends implementing it", func() {
+			chain := mock.NewChain(params)
+			client, err := NewMultiClientCore(RoundRobin, Backend{ClientCore: coreOnly{chain}}, Backend{ClientCore: chain})
+			Expect(err).ShouldNot(HaveOccurred())
+			address := newAddress()
+			_, err = chain.Fund(address, 10000)
+			Expect(err).ShouldNot(HaveOccurred())
+			redemption, err := client.(RedemptionFinder).ScriptRedemption(context.Background(), address, 10000)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(redemption.Redeemed).Should(BeFalse())
+		})
+
+		It("should fail when none of the backends implement it", func() {
+			client, err := NewMultiClientCore(RoundRobin, Backend{ClientCore: coreOnly{mock.NewChain(params)}})
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = client.(RedemptionFinder).ScriptRedemption(context.Background(), newAddress(), 0)
+			Expect(err).Should(MatchError(errors.NewErrUnsupportedOperation("ScriptRedemption", "multi")))
+		})
+	})
+
+	Context("when paging outputs without a pager", func() {
+		It("should cut the pages from the outputs sorted by transaction and index", func() {
+			chain := mock.NewChain(params)
+			address := newAddress()
+			for i := 0; i < 5; i++ {
+				_, err := chain.Fund(address, int64(10000*(i+1)))
+				Expect(err).ShouldNot(HaveOccurred())
+			}
+			client, err := NewMultiClientCore(RoundRobin, Backend{ClientCore: chain})
+			Expect(err).ShouldNot(HaveOccurred())
+			pager := client.(UTXOPager)
+
+			all, err := pager.GetUTXOPage(context.Background(), address, 0, 0, 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(all).Should(HaveLen(5))
+			for i := 1; i < len(all); i++ {
+				Expect(all[i-1].TxHash < all[i].TxHash).Should(BeTrue())
+			}
+
+			pages := []UTXO{}
+			for offset := int64(0); ; offset += 2 {
+				page, err := pager.GetUTXOPage(context.Background(), address, offset, 2, 0)
+				Expect(err).ShouldNot(HaveOccurred())
+				if len(page) == 0 {
+					break
+				}
+				Expect(len(page)).Should(BeNumerically("<=", 2))
+				pages = append(pages, page...)
+			}
+			Expect(pages).Should(Equal(all))
+
+			rest, err := pager.GetUTXOPage(context.Background(), address, 1, 0, 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(rest).Should(Equal(all[1:]))
+		})
+	})
+})
@@ -0,0 +1,931 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/gcs"
+	"github.com/btcsuite/btcutil/gcs/builder"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+const (
+	// neutrinoMaxCFilters is the maximum number of filters that can be
+	// requested with a single getcfilters message.
+	neutrinoMaxCFilters = 1000
+
+	// neutrinoRequestTimeout is the time to wait for a response from the
+	// peer before giving up on a request.
+	neutrinoRequestTimeout = time.Minute
+)
+
+type neutrinoOutput struct {
+	value  int64
+	height int64
+}
+
+type neutrinoSpend struct {
+	txHash     chainhash.Hash
+	height     int64
+	inputIndex uint32
+	sigScript  []byte
+	witness    wire.TxWitness
+}
+
+// neutrinoScript is the state of a script that has been scanned for, it is
+// kept so that later queries only scan the blocks mined since.
+type neutrinoScript struct {
+	script    []byte
+	scannedTo int64
+	funding   map[wire.OutPoint]neutrinoOutput
+	spends    map[wire.OutPoint]neutrinoSpend
+}
+
+type neutrinoTx struct {
+	tx     *wire.MsgTx
+	height int64
+}
+
+type neutrinoClient struct {
+	params   *chaincfg.Params
+	birthday int64
+	peer     *peer.Peer
+
+	// reqMu serialises requests to the peer, so that responses can be
+	// matched to requests.
+	reqMu     *sync.Mutex
+	verAcks   chan struct{}
+	headersCh chan *wire.MsgHeaders
+	filtersCh chan *wire.MsgCFilter
+	blocksCh  chan *wire.MsgBlock
+
+	mu      *sync.RWMutex
+	headers []wire.BlockHeader
+	heights map[chainhash.Hash]int64
+	scripts map[string]*neutrinoScript
+	txs     map[chainhash.Hash]neutrinoTx
+}
+
+// NewNeutrinoClientCore returns a ClientCore that discovers UTXOs using BIP157
+// compact block filters served by the full node at the given address, without
+// trusting a third-party indexer. Block headers are synchronised from genesis
+// and checked for proof of work and difficulty retargeting, blocks are checked
+// against the merkle root of their header, so the peer cannot fake funding or
+// confirmations. Filters are not checked against filter headers from other
+// peers, so the peer can still hide transactions by serving filters that do
+// not match them: it must be trusted to be honest about what it serves.
+// Scripts are only scanned for from the birthday height onwards, which should
+// be lower than the height of the first transaction of interest.
+func NewNeutrinoClientCore(peerAddress string, params *chaincfg.Params, birthday int64) (ClientCore, error) {
+	return newNeutrinoClientCore(peerAddress, params, birthday, directDial)
+}
+
+// NewNeutrinoClientCoreWithProxy returns a ClientCore connected to the peer
+// through the SOCKS5 proxy, given as in NewProxyHTTPClient. The peer can be a
+// .onion address when the proxy is Tor.
+func NewNeutrinoClientCoreWithProxy(peerAddress string, params *chaincfg.Params, birthday int64, proxy string) (ClientCore, error) {
+	dial, err := proxyDial(proxy)
+	if err != nil {
+		return nil, err
+	}
+	return newNeutrinoClientCore(peerAddress, params, birthday, dial)
+}
+
+func newNeutrinoClientCore(peerAddress string, params *chaincfg.Params, birthday int64, dial dialFunc) (ClientCore, error) {
+	client := &neutrinoClient{
+		params:    params,
+		birthday:  birthday,
+		reqMu:     new(sync.Mutex),
+		verAcks:   make(chan struct{}, 1),
+		headersCh: make(chan *wire.MsgHeaders, 1),
+		filtersCh: make(chan *wire.MsgCFilter, neutrinoMaxCFilters),
+		blocksCh:  make(chan *wire.MsgBlock, 1),
+		mu:        new(sync.RWMutex),
+		headers:   []wire.BlockHeader{params.GenesisBlock.Header},
+		heights:   map[chainhash.Hash]int64{*params.GenesisHash: 0},
+		scripts:   map[string]*neutrinoScript{},
+		txs:       map[chainhash.Hash]neutrinoTx{},
+	}
+
+	p, err := peer.NewOutboundPeer(&peer.Config{
+		UserAgentName:    "libbtc-go",
+		UserAgentVersion: "0.1.0",
+		ChainParams:      params,
+		DisableRelayTx:   true,
+		Listeners: peer.MessageListeners{
+			// Messages are dropped rather than blocking the peer when
+			// nobody is waiting for them.
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				select {
+				case client.verAcks <- struct{}{}:
+				default:
+				}
+			},
+			OnHeaders: func(p *peer.Peer, msg *wire.MsgHeaders) {
+				select {
+				case client.headersCh <- msg:
+				default:
+				}
+			},
+			OnCFilter: func(p *peer.Peer, msg *wire.MsgCFilter) {
+				select {
+				case client.filtersCh <- msg:
+				default:
+				}
+			},
+			OnBlock: func(p *peer.Peer, msg *wire.MsgBlock, buf []byte) {
+				select {
+				case client.blocksCh <- msg:
+				default:
+				}
+			},
+		},
+	}, peerAddress)
+	if err != nil {
+		return nil, err
+	}
+	dialCtx, dialCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer dialCancel()
+	conn, err := dial(dialCtx, "tcp", peerAddress)
+	if err != nil {
+		return nil, err
+	}
+	p.AssociateConnection(conn)
+
+	select {
+	case <-client.verAcks:
+	case <-time.After(neutrinoRequestTimeout):
+		p.Disconnect()
+		return nil, fmt.Errorf("timed out waiting for the handshake with %s", peerAddress)
+	}
+	if p.Services()&wire.SFNodeCF != wire.SFNodeCF {
+		p.Disconnect()
+		return nil, fmt.Errorf("peer %s does not serve compact block filters", peerAddress)
+	}
+	client.peer = p
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := client.syncHeaders(ctx); err != nil {
+		p.Disconnect()
+		return nil, err
+	}
+	return client, nil
+}
+
+func (client *neutrinoClient) NetworkParams() *chaincfg.Params {
+	return client.params
+}
+
+func (client *neutrinoClient) GetUTXOs(ctx context.Context, address string, limit, confitmations int64) ([]UTXO, error) {
+	script, err := client.addressScript(address)
+	if err != nil {
+		return nil, err
+	}
+	state, err := client.scan(ctx, script)
+	if err != nil {
+		return nil, err
+	}
+
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	tip := int64(len(client.headers) - 1)
+	utxos := []UTXO{}
+	for outPoint, output := range state.funding {
+		if limit > 0 && int64(len(utxos)) >= limit {
+			break
+		}
+		if _, ok := state.spends[outPoint]; ok {
+			continue
+		}
+		confirmations := neutrinoConfirmations(tip, output.height)
+		if confirmations < confitmations {
+			continue
+		}
+		utxos = append(utxos, UTXO{
+			TxHash:        outPoint.Hash.String(),
+			Amount:        output.value,
+			ScriptPubKey:  hex.EncodeToString(script),
+			Vout:          outPoint.Index,
+			Confirmations: confirmations,
+			BlockHeight:   confirmedHeight(output.height),
+			Address:       address,
+		})
+	}
+	return utxos, nil
+}
+
+func (client *neutrinoClient) GetUTXO(ctx context.Context, txHash string, vout uint32) (UTXO, error) {
+	tx, height, err := client.transaction(txHash)
+	if err != nil {
+		return UTXO{}, err
+	}
+	if int(vout) >= len(tx.TxOut) {
+		return UTXO{}, fmt.Errorf("transaction %s does not have an output at index %d", txHash, vout)
+	}
+	confirmations, err := client.Confirmations(ctx, txHash)
+	if err != nil {
+		return UTXO{}, err
+	}
+	scriptPubKey := hex.EncodeToString(tx.TxOut[vout].PkScript)
+	return UTXO{
+		TxHash:        txHash,
+		Amount:        tx.TxOut[vout].Value,
+		ScriptPubKey:  scriptPubKey,
+		Vout:          vout,
+		Confirmations: confirmations,
+		BlockHeight:   confirmedHeight(height),
+		Address:       scriptAddress(scriptPubKey, client.params),
+	}, nil
+}
+
+// Confirmations returns the confirmations of transactions that were published
+// through this client, or that touch a script that has been scanned for.
+func (client *neutrinoClient) Confirmations(ctx context.Context, txHash string) (int64, error) {
+	if err := client.syncHeaders(ctx); err != nil {
+		return 0, err
+	}
+	_, height, err := client.transaction(txHash)
+	if err != nil {
+		return 0, err
+	}
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	return neutrinoConfirmations(int64(len(client.headers)-1), height), nil
+}
+
+func (client *neutrinoClient) ScriptFunded(ctx context.Context, address string, value int64) (bool, int64, error) {
+	received, _, err := client.scriptTotals(ctx, address)
+	if err != nil {
+		return false, 0, err
+	}
+	return received >= value, received, nil
+}
+
+func (client *neutrinoClient) ScriptRedeemed(ctx context.Context, address string, value int64) (bool, int64, error) {
+	received, balance, err := client.scriptTotals(ctx, address)
+	if err != nil {
+		return false, 0, err
+	}
+	return received >= value && balance == 0, balance, nil
+}
+
+func (client *neutrinoClient) ScriptRedemption(ctx context.Context, address string, value int64) (Redemption, error) {
+	script, err := client.addressScript(address)
+	if err != nil {
+		return Redemption{}, err
+	}
+	state, err := client.scan(ctx, script)
+	if err != nil {
+		return Redemption{}, err
+	}
+
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	tip := int64(len(client.headers) - 1)
+	var received, balance int64
+	amounts := map[chainhash.Hash]int64{}
+	heights := map[chainhash.Hash]int64{}
+	for outPoint, output := range state.funding {
+		received += output.value
+		spend, ok := state.spends[outPoint]
+		if !ok {
+			balance += output.value
+			continue
+		}
+		amounts[spend.txHash] += output.value
+		heights[spend.txHash] = spend.height
+	}
+
+	redemption := Redemption{
+		Redeemed: received >= value && balance == 0,
+		TxHashes: []string{},
+	}
+	for txHash, amount := range amounts {
+		conf := neutrinoConfirmations(tip, heights[txHash])
+		if len(redemption.TxHashes) == 0 || conf < redemption.Confirmations {
+			redemption.Confirmations = conf
+		}
+		redemption.TxHashes = append(redemption.TxHashes, txHash.String())
+		redemption.Amount += amount
+	}
+	return redemption, nil
+}
+
+func (client *neutrinoClient) ScriptSpent(ctx context.Context, script, spender string) (bool, string, error) {
+	spend, spent, err := client.ScriptSpendDetails(ctx, script, spender)
+	return spent, spend.SigScript, err
+}
+
+func (client *neutrinoClient) ScriptSpendDetails(ctx context.Context, script, spender string) (ScriptSpend, bool, error) {
+	pkScript, err := client.addressScript(script)
+	if err != nil {
+		return ScriptSpend{}, false, err
+	}
+	state, err := client.scan(ctx, pkScript)
+	if err != nil {
+		return ScriptSpend{}, false, err
+	}
+
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	for _, spend := range state.spends {
+		witness := make([]string, len(spend.witness))
+		for i, item := range spend.witness {
+			witness[i] = hex.EncodeToString(item)
+		}
+		return ScriptSpend{
+			TxHash:     spend.txHash.String(),
+			InputIndex: spend.inputIndex,
+			SigScript:  hex.EncodeToString(spend.sigScript),
+			Witness:    witness,
+		}, true, nil
+	}
+	return ScriptSpend{}, false, nil
+}
+
+func (client *neutrinoClient) ChainTip(ctx context.Context) (ChainTip, error) {
+	if err := client.syncHeaders(ctx); err != nil {
+		return ChainTip{}, err
+	}
+
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	tip := client.headers[len(client.headers)-1]
+	timestamps := []int64{}
+	for i := len(client.headers) - 1; i >= 0 && len(timestamps) < medianTimeBlocks; i-- {
+		timestamps = append(timestamps, client.headers[i].Timestamp.Unix())
+	}
+	medianTime, err := medianTimePast(timestamps)
+	if err != nil {
+		return ChainTip{}, err
+	}
+	return ChainTip{
+		Height:     int64(len(client.headers) - 1),
+		Hash:       tip.BlockHash().String(),
+		Time:       tip.Timestamp.Unix(),
+		MedianTime: medianTime,
+	}, nil
+}
+
+// BlockHash returns the hash of the block at the given height.
+func (client *neutrinoClient) BlockHash(ctx context.Context, height int64) (string, error) {
+	if err := client.syncHeaders(ctx); err != nil {
+		return "", err
+	}
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	if height < 0 || height >= int64(len(client.headers)) {
+		return "", fmt.Errorf("no block at height %d", height)
+	}
+	return client.headers[height].BlockHash().String(), nil
+}
+
+func (client *neutrinoClient) GetBlockHeader(ctx context.Context, hashOrHeight string) (*wire.BlockHeader, error) {
+	height, err := client.blockHeight(ctx, hashOrHeight)
+	if err != nil {
+		return nil, err
+	}
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	header := client.headers[height]
+	return &header, nil
+}
+
+// GetBlock downloads the block from the peer, and checks it against the
+// merkle root of its header.
+func (client *neutrinoClient) GetBlock(ctx context.Context, hashOrHeight string) (*wire.MsgBlock, error) {
+	height, err := client.blockHeight(ctx, hashOrHeight)
+	if err != nil {
+		return nil, err
+	}
+	return client.getBlock(ctx, height)
+}
+
+// blockHeight returns the height of the block with the given hash or height,
+// in the synchronised headers.
+func (client *neutrinoClient) blockHeight(ctx context.Context, hashOrHeight string) (int64, error) {
+	hash, height, err := parseBlockID(hashOrHeight)
+	if err != nil {
+		return 0, err
+	}
+	if err := client.syncHeaders(ctx); err != nil {
+		return 0, err
+	}
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	if hash != nil {
+		var ok bool
+		if height, ok = client.heights[*hash]; !ok {
+			return 0, fmt.Errorf("block %s is not in the active chain", hash)
+		}
+	}
+	if height >= int64(len(client.headers)) {
+		return 0, fmt.Errorf("no block at height %d", height)
+	}
+	return height, nil
+}
+
+// RawTransaction returns transactions that were published through this
+// client, or that touch a script that has been scanned for.
+func (client *neutrinoClient) RawTransaction(ctx context.Context, txHash string) (*wire.MsgTx, error) {
+	tx, _, err := client.transaction(txHash)
+	return tx, err
+}
+
+// PublishTransaction relays the transaction to the peer. The transaction is
+// tracked as unconfirmed until it is found in a block while scanning.
+func (client *neutrinoClient) PublishTransaction(ctx context.Context, stx *wire.MsgTx) error {
+	done := make(chan struct{}, 1)
+	client.peer.QueueMessage(stx, done)
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return errors.ErrTimedOut
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.txs[stx.TxHash()] = neutrinoTx{tx: stx}
+	for _, state := range client.scripts {
+		client.processTx(state, stx, 0)
+	}
+	return nil
+}
+
+func (client *neutrinoClient) scriptTotals(ctx context.Context, address string) (int64, int64, error) {
+	script, err := client.addressScript(address)
+	if err != nil {
+		return 0, 0, err
+	}
+	state, err := client.scan(ctx, script)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	var received, balance int64
+	for outPoint, output := range state.funding {
+		received += output.value
+		if _, ok := state.spends[outPoint]; !ok {
+			balance += output.value
+		}
+	}
+	return received, balance, nil
+}
+
+func (client *neutrinoClient) transaction(txHash string) (*wire.MsgTx, int64, error) {
+	hash, err := chainhash.NewHashFromStr(txHash)
+	if err != nil {
+		return nil, 0, err
+	}
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	tx, ok := client.txs[*hash]
+	if !ok {
+		return nil, 0, fmt.Errorf("transaction %s: %w: only transactions touching scanned scripts are tracked", txHash, errors.ErrTxNotFound)
+	}
+	return tx.tx, tx.height, nil
+}
+
+// scan brings the state of the script up to date with the chain tip, by
+// matching the script against the compact filters of every block that has not
+// been scanned yet and processing the blocks that match.
+func (client *neutrinoClient) scan(ctx context.Context, script []byte) (*neutrinoScript, error) {
+	if err := client.syncHeaders(ctx); err != nil {
+		return nil, err
+	}
+
+	client.mu.Lock()
+	state, ok := client.scripts[string(script)]
+	if !ok {
+		state = &neutrinoScript{
+			script:    script,
+			scannedTo: client.birthday - 1,
+			funding:   map[wire.OutPoint]neutrinoOutput{},
+			spends:    map[wire.OutPoint]neutrinoSpend{},
+		}
+		client.scripts[string(script)] = state
+		// Published transactions are not in any block yet.
+		for _, tx := range client.txs {
+			if tx.height == 0 {
+				client.processTx(state, tx.tx, 0)
+			}
+		}
+	}
+	from := state.scannedTo + 1
+	tip := int64(len(client.headers) - 1)
+	client.mu.Unlock()
+
+	for start := from; start <= tip; start += neutrinoMaxCFilters {
+		stop := start + neutrinoMaxCFilters - 1
+		if stop > tip {
+			stop = tip
+		}
+		matches, err := client.matchFilters(ctx, start, stop, script)
+		if err != nil {
+			return nil, err
+		}
+		for _, height := range matches {
+			block, err := client.getBlock(ctx, height)
+			if err != nil {
+				return nil, err
+			}
+			client.mu.Lock()
+			for _, tx := range block.Transactions {
+				client.processTx(state, tx, height)
+			}
+			client.mu.Unlock()
+		}
+		client.mu.Lock()
+		// A reorg while scanning resets the state, in which case the scan
+		// has to start again from the birthday.
+		if state.scannedTo == start-1 {
+			state.scannedTo = stop
+		}
+		client.mu.Unlock()
+	}
+	return state, nil
+}
+
+// processTx records the outputs of the transaction that pay to the script, and
+// the inputs that spend them. It must be called with the lock held.
+func (client *neutrinoClient) processTx(state *neutrinoScript, tx *wire.MsgTx, height int64) {
+	txHash := tx.TxHash()
+	relevant := false
+	for i, txIn := range tx.TxIn {
+		if _, ok := state.funding[txIn.PreviousOutPoint]; ok {
+			state.spends[txIn.PreviousOutPoint] = neutrinoSpend{
+				txHash:     txHash,
+				height:     height,
+				inputIndex: uint32(i),
+				sigScript:  txIn.SignatureScript,
+				witness:    txIn.Witness,
+			}
+			relevant = true
+		}
+	}
+	for vout, txOut := range tx.TxOut {
+		if bytes.Equal(txOut.PkScript, state.script) {
+			state.funding[wire.OutPoint{Hash: txHash, Index: uint32(vout)}] = neutrinoOutput{
+				value:  txOut.Value,
+				height: height,
+			}
+			relevant = true
+		}
+	}
+	if relevant {
+		client.txs[txHash] = neutrinoTx{tx: tx, height: height}
+	}
+}
+
+// matchFilters returns the heights of the blocks in the given range whose
+// compact filters match the script.
+func (client *neutrinoClient) matchFilters(ctx context.Context, start, stop int64, script []byte) ([]int64, error) {
+	client.mu.RLock()
+	stopHash := client.headers[stop].BlockHash()
+	client.mu.RUnlock()
+
+	client.reqMu.Lock()
+	defer client.reqMu.Unlock()
+
+	client.peer.QueueMessage(wire.NewMsgGetCFilters(wire.GCSFilterRegular, uint32(start), &stopHash), nil)
+	matches := []int64{}
+	for received := start; received <= stop; {
+		select {
+		case msg := <-client.filtersCh:
+			client.mu.RLock()
+			height, ok := client.heights[msg.BlockHash]
+			client.mu.RUnlock()
+			if !ok || height != received {
+				continue
+			}
+			received++
+
+			filter, err := gcs.FromNBytes(builder.DefaultP, builder.DefaultM, msg.Data)
+			if err != nil {
+				return nil, err
+			}
+			if filter.N() == 0 {
+				continue
+			}
+			matched, err := filter.Match(builder.DeriveKey(&msg.BlockHash), script)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				matches = append(matches, height)
+			}
+		case <-ctx.Done():
+			return nil, errors.ErrTimedOut
+		case <-time.After(neutrinoRequestTimeout):
+			return nil, fmt.Errorf("timed out waiting for compact filters from height %d", received)
+		}
+	}
+	return matches, nil
+}
+
+// getBlock downloads the block at the given height and checks it against the
+// merkle root of its header.
+func (client *neutrinoClient) getBlock(ctx context.Context, height int64) (*wire.MsgBlock, error) {
+	client.mu.RLock()
+	header := client.headers[height]
+	client.mu.RUnlock()
+	hash := header.BlockHash()
+
+	client.reqMu.Lock()
+	defer client.reqMu.Unlock()
+
+	getData := wire.NewMsgGetData()
+	if err := getData.AddInvVect(wire.NewInvVect(wire.InvTypeWitnessBlock, &hash)); err != nil {
+		return nil, err
+	}
+	client.peer.QueueMessage(getData, nil)
+	for {
+		select {
+		case block := <-client.blocksCh:
+			if block.BlockHash() != hash {
+				continue
+			}
+			if merkleRoot(block.Transactions) != header.MerkleRoot {
+				return nil, fmt.Errorf("block %s does not match the merkle root of its header", hash)
+			}
+			return block, nil
+		case <-ctx.Done():
+			return nil, errors.ErrTimedOut
+		case <-time.After(neutrinoRequestTimeout):
+			return nil, fmt.Errorf("timed out waiting for block %s", hash)
+		}
+	}
+}
+
+// syncHeaders downloads the block headers mined since the last sync, handling
+// reorgs by discarding the headers and scanned state above the fork point.
+func (client *neutrinoClient) syncHeaders(ctx context.Context) error {
+	client.reqMu.Lock()
+	defer client.reqMu.Unlock()
+
+	for {
+		getHeaders := wire.NewMsgGetHeaders()
+		for _, hash := range client.locator() {
+			if err := getHeaders.AddBlockLocatorHash(hash); err != nil {
+				return err
+			}
+		}
+		client.peer.QueueMessage(getHeaders, nil)
+
+		var msg *wire.MsgHeaders
+		select {
+		case msg = <-client.headersCh:
+		case <-ctx.Done():
+			return errors.ErrTimedOut
+		case <-time.After(neutrinoRequestTimeout):
+			return fmt.Errorf("timed out waiting for block headers")
+		}
+		if len(msg.Headers) == 0 {
+			return nil
+		}
+		if err := client.connectHeaders(msg.Headers); err != nil {
+			return err
+		}
+		if len(msg.Headers) < wire.MaxBlockHeadersPerMsg {
+			return nil
+		}
+	}
+}
+
+func (client *neutrinoClient) connectHeaders(headers []*wire.BlockHeader) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	forkHeight, ok := client.heights[headers[0].PrevBlock]
+	if !ok {
+		return fmt.Errorf("received headers that do not connect to the chain")
+	}
+	if forkHeight < int64(len(client.headers)-1) {
+		for _, header := range client.headers[forkHeight+1:] {
+			delete(client.heights, header.BlockHash())
+		}
+		client.headers = client.headers[:forkHeight+1]
+		for _, state := range client.scripts {
+			if state.scannedTo > forkHeight {
+				// Rescan the script from scratch, as the orphaned blocks
+				// may have funded or spent from it.
+				state.scannedTo = client.birthday - 1
+				state.funding = map[wire.OutPoint]neutrinoOutput{}
+				state.spends = map[wire.OutPoint]neutrinoSpend{}
+			}
+		}
+	}
+
+	checkpoints := map[int64]*chainhash.Hash{}
+	for _, checkpoint := range client.params.Checkpoints {
+		checkpoints[int64(checkpoint.Height)] = checkpoint.Hash
+	}
+	for _, header := range headers {
+		prevHash := client.headers[len(client.headers)-1].BlockHash()
+		if header.PrevBlock != prevHash {
+			return fmt.Errorf("received headers that do not connect to each other")
+		}
+		hash := header.BlockHash()
+		height := int64(len(client.headers))
+		if bits := client.requiredBits(header); header.Bits != bits {
+			return fmt.Errorf("block %s at height %d has difficulty bits %08x, expected %08x", hash, height, header.Bits, bits)
+		}
+		if err := checkProofOfWork(header, client.params.PowLimit); err != nil {
+			return err
+		}
+		if checkpoint, ok := checkpoints[height]; ok && *checkpoint != hash {
+			return fmt.Errorf("block %s at height %d does not match the checkpoint %s", hash, height, checkpoint)
+		}
+		client.headers = append(client.headers, *header)
+		client.heights[hash] = height
+	}
+	return nil
+}
+
+// requiredBits returns the difficulty bits the header must have to extend the
+// chain, following the difficulty retarget rules of the network. It must be
+// called with the lock held.
+func (client *neutrinoClient) requiredBits(header *wire.BlockHeader) uint32 {
+	params := client.params
+	last := client.headers[len(client.headers)-1]
+	height := int64(len(client.headers))
+	blocksPerRetarget := int64(params.TargetTimespan / params.TargetTimePerBlock)
+
+	// Regtest never retargets, every block is mined at the proof of work
+	// limit.
+	if params.Net == wire.TestNet {
+		return params.PowLimitBits
+	}
+
+	if height%blocksPerRetarget != 0 {
+		if !params.ReduceMinDifficulty {
+			return last.Bits
+		}
+		// Testnet allows a minimum difficulty block when no block has been
+		// mined for a while, otherwise the difficulty is the one of the last
+		// block that was not mined at the minimum difficulty.
+		if header.Timestamp.Unix() > last.Timestamp.Unix()+int64(params.MinDiffReductionTime/time.Second) {
+			return params.PowLimitBits
+		}
+		prev := height - 1
+		for prev > 0 && prev%blocksPerRetarget != 0 && client.headers[prev].Bits == params.PowLimitBits {
+			prev--
+		}
+		return client.headers[prev].Bits
+	}
+
+	first := client.headers[height-blocksPerRetarget]
+	targetTimespan := int64(params.TargetTimespan / time.Second)
+	timespan := last.Timestamp.Unix() - first.Timestamp.Unix()
+	if min := targetTimespan / params.RetargetAdjustmentFactor; timespan < min {
+		timespan = min
+	}
+	if max := targetTimespan * params.RetargetAdjustmentFactor; timespan > max {
+		timespan = max
+	}
+	target := compactToBig(last.Bits)
+	target.Mul(target, big.NewInt(timespan))
+	target.Div(target, big.NewInt(targetTimespan))
+	if target.Cmp(params.PowLimit) > 0 {
+		target.Set(params.PowLimit)
+	}
+	return bigToCompact(target)
+}
+
+// locator returns the block locator of the current chain, with dense hashes
+// near the tip and exponentially sparser hashes towards genesis.
+func (client *neutrinoClient) locator() []*chainhash.Hash {
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+
+	locator := []*chainhash.Hash{}
+	step := int64(1)
+	for height := int64(len(client.headers) - 1); height > 0; height -= step {
+		hash := client.headers[height].BlockHash()
+		locator = append(locator, &hash)
+		if len(locator) > 10 {
+			step *= 2
+		}
+		if len(locator) == wire.MaxBlockLocatorsPerMsg-1 {
+			break
+		}
+	}
+	return append(locator, client.params.GenesisHash)
+}
+
+func (client *neutrinoClient) addressScript(address string) ([]byte, error) {
+	addr, err := btcutil.DecodeAddress(address, client.params)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.PayToAddrScript(addr)
+}
+
+// checkProofOfWork checks that the hash of the header is below the target
+// encoded in the header, and that the target is below the proof of work limit.
+func checkProofOfWork(header *wire.BlockHeader, powLimit *big.Int) error {
+	target := compactToBig(header.Bits)
+	if target.Sign() <= 0 || target.Cmp(powLimit) > 0 {
+		return fmt.Errorf("block %s has an invalid target", header.BlockHash())
+	}
+	hash := header.BlockHash()
+	for i, j := 0, len(hash)-1; i < j; i, j = i+1, j-1 {
+		hash[i], hash[j] = hash[j], hash[i]
+	}
+	if new(big.Int).SetBytes(hash[:]).Cmp(target) > 0 {
+		return fmt.Errorf("block %s does not meet its proof of work target", header.BlockHash())
+	}
+	return nil
+}
+
+// compactToBig converts the compact representation of a target used in block
+// headers to a big integer.
+func compactToBig(compact uint32) *big.Int {
+	mantissa := compact & 0x007fffff
+	isNegative := compact&0x00800000 != 0
+	exponent := uint(compact >> 24)
+
+	var bn *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		bn = big.NewInt(int64(mantissa))
+	} else {
+		bn = big.NewInt(int64(mantissa))
+		bn.Lsh(bn, 8*(exponent-3))
+	}
+	if isNegative {
+		bn = bn.Neg(bn)
+	}
+	return bn
+}
+
+// bigToCompact converts a target to the compact representation used in block
+// headers.
+func bigToCompact(n *big.Int) uint32 {
+	if n.Sign() == 0 {
+		return 0
+	}
+	var mantissa uint32
+	exponent := uint(len(n.Bytes()))
+	if exponent <= 3 {
+		mantissa = uint32(n.Bits()[0])
+		mantissa <<= 8 * (3 - exponent)
+	} else {
+		tn := new(big.Int).Abs(n)
+		mantissa = uint32(tn.Rsh(tn, 8*(exponent-3)).Bits()[0])
+	}
+	// The sign bit is part of the mantissa, so a mantissa with the bit set is
+	// shifted into the next exponent.
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+	compact := uint32(exponent<<24) | mantissa
+	if n.Sign() < 0 {
+		compact |= 0x00800000
+	}
+	return compact
+}
+
+// merkleRoot returns the merkle root of the transactions of a block.
+func merkleRoot(txs []*wire.MsgTx) chainhash.Hash {
+	if len(txs) == 0 {
+		return chainhash.Hash{}
+	}
+	level := make([]chainhash.Hash, len(txs))
+	for i, tx := range txs {
+		level[i] = tx.TxHash()
+	}
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		next := make([]chainhash.Hash, len(level)/2)
+		for i := range next {
+			next[i] = chainhash.DoubleHashH(append(level[2*i][:], level[2*i+1][:]...))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+func neutrinoConfirmations(tip, height int64) int64 {
+	if height <= 0 {
+		return 0
+	}
+	return tip - height + 1
+}
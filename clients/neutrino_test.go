@@ -0,0 +1,267 @@
+package clients_test
+
+import (
+	"context"
+	"encoding/hex"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go/clients"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/gcs/builder"
+)
+
+// neutrinoPeer is a full node serving the headers, compact filters and blocks
+// of a chain built on top of the genesis block of its network.
+type neutrinoPeer struct {
+	listener net.Listener
+	params   *chaincfg.Params
+
+	mu      sync.Mutex
+	blocks  []*wire.MsgBlock
+	filters [][]byte
+	heights map[chainhash.Hash]int
+}
+
+func newNeutrinoPeer(params *chaincfg.Params) *neutrinoPeer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ShouldNot(HaveOccurred())
+	node := &neutrinoPeer{
+		listener: listener,
+		params:   params,
+		blocks:   []*wire.MsgBlock{params.GenesisBlock},
+		heights:  map[chainhash.Hash]int{*params.GenesisHash: 0},
+	}
+	filter, err := builder.BuildBasicFilter(params.GenesisBlock, nil)
+	Expect(err).ShouldNot(HaveOccurred())
+	filterBytes, err := filter.NBytes()
+	Expect(err).ShouldNot(HaveOccurred())
+	node.filters = [][]byte{filterBytes}
+	go node.serve()
+	return node
+}
+
+// mine adds a block with the transactions to the chain, after a coinbase
+// paying to the script. The scripts of the outputs spent by the transactions
+// are given to build the filter of the block.
+func (node *neutrinoPeer) mine(script []byte, prevOutScripts [][]byte, txs ...*wire.MsgTx) *wire.MsgBlock {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+
+	height := len(node.blocks)
+	heightScript, err := txscript.NewScriptBuilder().AddInt64(int64(height)).Script()
+	Expect(err).ShouldNot(HaveOccurred())
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, math.MaxUint32), heightScript, nil))
+	coinbase.AddTxOut(wire.NewTxOut(5000000000, script))
+
+	prev := node.blocks[height-1]
+	block := wire.NewMsgBlock(wire.NewBlockHeader(4, new(chainhash.Hash), new(chainhash.Hash), node.params.PowLimitBits, 0))
+	block.Header.PrevBlock = prev.BlockHash()
+	block.Header.Timestamp = prev.Header.Timestamp.Add(10 * time.Minute)
+	Expect(block.AddTransaction(coinbase)).Should(Succeed())
+	for _, tx := range txs {
+		Expect(block.AddTransaction(tx)).Should(Succeed())
+	}
+	blockTxs := make([]*btcutil.Tx, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		blockTxs[i] = btcutil.NewTx(tx)
+	}
+	merkles := blockchain.BuildMerkleTreeStore(blockTxs, false)
+	block.Header.MerkleRoot = *merkles[len(merkles)-1]
+	target := blockchain.CompactToBig(block.Header.Bits)
+	for {
+		hash := block.Header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+			break
+		}
+		block.Header.Nonce++
+	}
+
+	filter, err := builder.BuildBasicFilter(block, prevOutScripts)
+	Expect(err).ShouldNot(HaveOccurred())
+	filterBytes, err := filter.NBytes()
+	Expect(err).ShouldNot(HaveOccurred())
+	node.blocks = append(node.blocks, block)
+	node.filters = append(node.filters, filterBytes)
+	node.heights[block.BlockHash()] = height
+	return block
+}
+
+func (node *neutrinoPeer) serve() {
+	for {
+		conn, err := node.listener.Accept()
+		if err != nil {
+			return
+		}
+		go node.serveConn(conn)
+	}
+}
+
+// serveConn answers the messages of a connection. The peer package of btcd is
+// not used as it refuses connections between two of its peers in the same
+// process.
+func (node *neutrinoPeer) serveConn(conn net.Conn) {
+	defer conn.Close()
+	for {
+		msg, _, err := wire.ReadMessage(conn, wire.ProtocolVersion, node.params.Net)
+		if err != nil {
+			return
+		}
+		var resps []wire.Message
+		switch msg := msg.(type) {
+		case *wire.MsgVersion:
+			me := wire.NewNetAddress(conn.LocalAddr().(*net.TCPAddr), wire.SFNodeNetwork)
+			you := wire.NewNetAddress(conn.RemoteAddr().(*net.TCPAddr), msg.Services)
+			version := wire.NewMsgVersion(me, you, msg.Nonce+1, int32(len(node.blocks)-1))
+			version.Services = wire.SFNodeNetwork | wire.SFNodeWitness | wire.SFNodeCF
+			resps = []wire.Message{version, wire.NewMsgVerAck()}
+		case *wire.MsgPing:
+			resps = []wire.Message{wire.NewMsgPong(msg.Nonce)}
+		case *wire.MsgGetHeaders:
+			resps = node.headers(msg)
+		case *wire.MsgGetCFilters:
+			resps = node.cfilters(msg)
+		case *wire.MsgGetData:
+			resps = node.data(msg)
+		}
+		for _, resp := range resps {
+			if err := wire.WriteMessage(conn, resp, wire.ProtocolVersion, node.params.Net); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// headers returns the headers following the first hash of the locator that is
+// in the chain.
+func (node *neutrinoPeer) headers(msg *wire.MsgGetHeaders) []wire.Message {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	start := 0
+	for _, hash := range msg.BlockLocatorHashes {
+		if height, ok := node.heights[*hash]; ok {
+			start = height + 1
+			break
+		}
+	}
+	headers := wire.NewMsgHeaders()
+	for _, block := range node.blocks[start:] {
+		header := block.Header
+		if err := headers.AddBlockHeader(&header); err != nil {
+			break
+		}
+	}
+	return []wire.Message{headers}
+}
+
+func (node *neutrinoPeer) cfilters(msg *wire.MsgGetCFilters) []wire.Message {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	stop, ok := node.heights[msg.StopHash]
+	if !ok {
+		return nil
+	}
+	filters := []wire.Message{}
+	for height := int(msg.StartHeight); height <= stop; height++ {
+		hash := node.blocks[height].BlockHash()
+		filters = append(filters, wire.NewMsgCFilter(wire.GCSFilterRegular, &hash, node.filters[height]))
+	}
+	return filters
+}
+
+func (node *neutrinoPeer) data(msg *wire.MsgGetData) []wire.Message {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	blocks := []wire.Message{}
+	for _, inv := range msg.InvList {
+		if height, ok := node.heights[inv.Hash]; ok {
+			blocks = append(blocks, node.blocks[height])
+		}
+	}
+	return blocks
+}
+
+var _ = Describe("Neutrino client", func() {
+	params := &chaincfg.RegressionNetParams
+	const address = "bcrt1q63gxjpemqpj4sq9gchdtf2ggc5zr0j98cuuqce"
+	const scriptPubKey = "0014d45069073b00655800a8c5dab4a908c50437c8a7"
+	script, _ := hex.DecodeString(scriptPubKey)
+	otherScript := []byte{txscript.OP_TRUE}
+
+	var node *neutrinoPeer
+	var funding, spending *wire.MsgTx
+
+	// The first block pays to the script, the second one spends it and
+	// pays it change, and the third one does not touch it.
+	BeforeEach(func() {
+		node = newNeutrinoPeer(params)
+		funding = node.mine(script, nil).Transactions[0]
+		spending = wire.NewMsgTx(wire.TxVersion)
+		spending.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: funding.TxHash(), Index: 0}, nil, nil))
+		spending.AddTxOut(wire.NewTxOut(1000000000, script))
+		spending.AddTxOut(wire.NewTxOut(3900000000, otherScript))
+		node.mine(otherScript, [][]byte{script}, spending)
+		node.mine(otherScript, nil)
+	})
+
+	AfterEach(func() {
+		node.listener.Close()
+	})
+
+	newClient := func() ClientCore {
+		client, err := NewNeutrinoClientCore(node.listener.Addr().String(), params, 1)
+		Expect(err).ShouldNot(HaveOccurred())
+		return client
+	}
+
+	It("should map the unspent outputs of a script and their confirmations", func() {
+		client := newClient()
+
+		utxos, err := client.GetUTXOs(context.Background(), address, 0, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(utxos).Should(Equal([]UTXO{
+			{TxHash: spending.TxHash().String(), Amount: 1000000000, ScriptPubKey: scriptPubKey, Vout: 0, Confirmations: 2, BlockHeight: 2, Address: address},
+		}))
+		confirmed, err := client.GetUTXOs(context.Background(), address, 0, 3)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(confirmed).Should(BeEmpty())
+
+		confirmations, err := client.Confirmations(context.Background(), funding.TxHash().String())
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(confirmations).Should(Equal(int64(3)))
+		spend, spent, err := client.(ScriptSpendFinder).ScriptSpendDetails(context.Background(), address, "")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(spent).Should(BeTrue())
+		Expect(spend.TxHash).Should(Equal(spending.TxHash().String()))
+	})
+
+	It("should scan the blocks mined since the last query", func() {
+		client := newClient()
+		_, err := client.GetUTXOs(context.Background(), address, 0, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		coinbase := node.mine(script, nil).Transactions[0]
+		utxos, err := client.GetUTXOs(context.Background(), address, 0, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(utxos).Should(ConsistOf(
+			UTXO{TxHash: spending.TxHash().String(), Amount: 1000000000, ScriptPubKey: scriptPubKey, Vout: 0, Confirmations: 3, BlockHeight: 2, Address: address},
+			UTXO{TxHash: coinbase.TxHash().String(), Amount: 5000000000, ScriptPubKey: scriptPubKey, Vout: 0, Confirmations: 1, BlockHeight: 4, Address: address},
+		))
+
+		tip, err := client.(ChainTipFetcher).ChainTip(context.Background())
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(tip.Height).Should(Equal(int64(4)))
+		Expect(tip.Hash).Should(Equal(node.blocks[4].BlockHash().String()))
+	})
+})
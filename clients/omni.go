@@ -0,0 +1,228 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// OmniClient reads the state of Omni Layer tokens. Amounts are in the smallest
+// unit of the property, willets for divisible properties.
+type OmniClient interface {
+	// OmniBalance returns the available balance of the address for the
+	// property.
+	OmniBalance(ctx context.Context, address string, propertyID int64) (int64, error)
+
+	// OmniTransactions returns the Omni transactions sent or received by the
+	// address, most recent first.
+	OmniTransactions(ctx context.Context, address string) ([]OmniTransaction, error)
+}
+
+// OmniTransaction is an Omni Layer transaction. Valid is false for
+// transactions that are in the chain but were rejected by the Omni protocol,
+// for example because the sender did not have enough tokens.
+type OmniTransaction struct {
+	TxHash        string
+	Type          string
+	TypeInt       int
+	Sender        string
+	Reference     string
+	PropertyID    int64
+	Amount        int64
+	Divisible     bool
+	Valid         bool
+	Block         int64
+	Confirmations int64
+}
+
+type omniTransaction struct {
+	TxID          string `json:"txid"`
+	Type          string `json:"type"`
+	TypeInt       int    `json:"type_int"`
+	Sender        string `json:"sendingaddress"`
+	Reference     string `json:"referenceaddress"`
+	PropertyID    int64  `json:"propertyid"`
+	Amount        string `json:"amount"`
+	Divisible     bool   `json:"divisible"`
+	Valid         bool   `json:"valid"`
+	Block         int64  `json:"block"`
+	Confirmations int64  `json:"confirmations"`
+}
+
+func (tx omniTransaction) transaction() (OmniTransaction, error) {
+	amount, err := omniAmount(tx.Amount)
+	if err != nil {
+		return OmniTransaction{}, err
+	}
+	return OmniTransaction{
+		TxHash:        tx.TxID,
+		Type:          tx.Type,
+		TypeInt:       tx.TypeInt,
+		Sender:        tx.Sender,
+		Reference:     tx.Reference,
+		PropertyID:    tx.PropertyID,
+		Amount:        amount,
+		Divisible:     tx.Divisible,
+		Valid:         tx.Valid,
+		Block:         tx.Block,
+		Confirmations: tx.Confirmations,
+	}, nil
+}
+
+type omniCoreClient struct {
+	rpc *rpcClient
+}
+
+// NewOmniCoreClient returns an OmniClient connected to the JSON-RPC server of
+// omnicored. Transactions are only listed for addresses of the wallet of the
+// node, which can be imported as watch-only addresses.
+func NewOmniCoreClient(host, user, password string) OmniClient {
+	return &omniCoreClient{
+		rpc: &rpcClient{host, user, password},
+	}
+}
+
+func (client *omniCoreClient) OmniBalance(ctx context.Context, address string, propertyID int64) (int64, error) {
+	balance := struct {
+		Balance string `json:"balance"`
+	}{}
+	if err := client.rpc.call("omni_getbalance", []interface{}{address, propertyID}, &balance); err != nil {
+		return 0, err
+	}
+	return omniAmount(balance.Balance)
+}
+
+func (client *omniCoreClient) OmniTransactions(ctx context.Context, address string) ([]OmniTransaction, error) {
+	txs := []omniTransaction{}
+	if err := client.rpc.call("omni_listtransactions", []interface{}{address, 999999, 0}, &txs); err != nil {
+		return nil, err
+	}
+
+	// omnicored lists the oldest transactions first.
+	transactions := make([]OmniTransaction, len(txs))
+	for i, tx := range txs {
+		transaction, err := tx.transaction()
+		if err != nil {
+			return nil, err
+		}
+		transactions[len(txs)-1-i] = transaction
+	}
+	return transactions, nil
+}
+
+type omniExplorerClient struct {
+	URL string
+	logging
+	retrying
+	requesting
+}
+
+// NewOmniExplorerClient returns an OmniClient backed by the API of
+// omniexplorer.info, which only indexes the main network.
+func NewOmniExplorerClient(network string) (OmniClient, error) {
+	network = strings.ToLower(network)
+	switch network {
+	case "mainnet":
+		return &omniExplorerClient{
+			URL: "https://api.omniexplorer.info/v1",
+		}, nil
+	default:
+		return nil, errors.NewErrUnsupportedNetwork(network)
+	}
+}
+
+func (client *omniExplorerClient) OmniBalance(ctx context.Context, address string, propertyID int64) (int64, error) {
+	resp := struct {
+		Balance []struct {
+			ID    string `json:"id"`
+			Value string `json:"value"`
+		} `json:"balance"`
+	}{}
+	if err := client.post(ctx, "/address/addr/", url.Values{"addr": {address}}, &resp); err != nil {
+		return 0, err
+	}
+	for _, balance := range resp.Balance {
+		if balance.ID == strconv.FormatInt(propertyID, 10) {
+			// Balances are reported in the smallest unit of the property.
+			return strconv.ParseInt(balance.Value, 10, 64)
+		}
+	}
+	return 0, nil
+}
+
+func (client *omniExplorerClient) OmniTransactions(ctx context.Context, address string) ([]OmniTransaction, error) {
+	transactions := []OmniTransaction{}
+	for page := 1; ; page++ {
+		resp := struct {
+			Pages        int               `json:"pages"`
+			Transactions []omniTransaction `json:"transactions"`
+		}{}
+		form := url.Values{"addr": {address}, "page": {strconv.Itoa(page)}}
+		if err := client.post(ctx, "/transaction/address", form, &resp); err != nil {
+			return nil, err
+		}
+		for _, tx := range resp.Transactions {
+			transaction, err := tx.transaction()
+			if err != nil {
+				return nil, err
+			}
+			transactions = append(transactions, transaction)
+		}
+		if page >= resp.Pages || len(resp.Transactions) == 0 {
+			return transactions, nil
+		}
+	}
+}
+
+func (client *omniExplorerClient) post(ctx context.Context, path string, form url.Values, response interface{}) error {
+	return backoff(ctx, client.retryPolicy(), client.log(), func() error {
+		resp, err := client.httpPostForm(ctx, client.URL+path, form)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		respBytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return HTTPError{resp.StatusCode, string(respBytes)}
+		}
+		return json.Unmarshal(respBytes, response)
+	})
+}
+
+// omniAmount converts an amount formatted by Omni, with eight decimals for
+// divisible properties and none for indivisible ones, to the smallest unit of
+// the property.
+func omniAmount(amount string) (int64, error) {
+	if amount == "" {
+		return 0, nil
+	}
+	parts := strings.Split(amount, ".")
+	if len(parts) == 1 {
+		return strconv.ParseInt(amount, 10, 64)
+	}
+	if len(parts) != 2 || len(parts[1]) > 8 {
+		return 0, fmt.Errorf("invalid omni amount %s", amount)
+	}
+	whole, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid omni amount %s: %v", amount, err)
+	}
+	fraction, err := strconv.ParseInt(parts[1]+strings.Repeat("0", 8-len(parts[1])), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid omni amount %s: %v", amount, err)
+	}
+	if strings.HasPrefix(parts[0], "-") {
+		return whole*1e8 - fraction, nil
+	}
+	return whole*1e8 + fraction, nil
+}
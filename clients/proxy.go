@@ -0,0 +1,64 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/btcsuite/go-socks/socks"
+)
+
+// dialFunc opens the connections of the clients that talk to their backend
+// over TCP rather than HTTP.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func directDial(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// proxyDial returns a dialFunc connecting through the SOCKS5 proxy, given as
+// in NewProxyHTTPClient. Host names are resolved by the proxy, so that .onion
+// addresses are reachable through Tor and no DNS requests leak.
+func proxyDial(proxy string) (dialFunc, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Scheme != "socks5" && proxyURL.Scheme != "socks5h" {
+		return nil, fmt.Errorf("unsupported proxy scheme %s", proxyURL.Scheme)
+	}
+	socksProxy := &socks.Proxy{Addr: proxyURL.Host}
+	if proxyURL.User != nil {
+		socksProxy.Username = proxyURL.User.Username()
+		socksProxy.Password, _ = proxyURL.User.Password()
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		type result struct {
+			conn net.Conn
+			err  error
+		}
+		results := make(chan result, 1)
+		go func() {
+			conn, err := socksProxy.Dial(network, addr)
+			results <- result{conn, err}
+		}()
+
+		select {
+		case <-ctx.Done():
+			// The connection is closed if it is opened after the context is
+			// done.
+			go func() {
+				if result := <-results; result.conn != nil {
+					result.conn.Close()
+				}
+			}()
+			return nil, ctx.Err()
+		case result := <-results:
+			return result.conn, result.err
+		}
+	}, nil
+}
@@ -0,0 +1,152 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// RetryPolicy decides how the clients calling HTTP APIs retry failed
+// requests. The interval between attempts starts at InitialInterval and is
+// multiplied by Multiplier after every failure.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first
+	// one. Zero means unlimited.
+	MaxAttempts int
+
+	// MaxElapsed is the maximum time spent retrying, after which the last
+	// error is returned. Zero means unlimited.
+	MaxElapsed time.Duration
+
+	InitialInterval time.Duration
+	Multiplier      float64
+
+	// Jitter randomizes every interval by up to this fraction of it, so that
+	// clients failing together do not retry together. It is between 0 and 1.
+	Jitter float64
+
+	// Retryable returns whether a request failing with the error should be
+	// retried. Nil retries every error but the ones for which IsPermanent
+	// returns true.
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy returns the policy used by clients until another one is
+// set. Requests are retried until the context is done, unless they fail
+// permanently.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		InitialInterval: time.Second,
+		Multiplier:      1.6,
+		Jitter:          0.2,
+	}
+}
+
+// RetryPolicySetter is implemented by the clients that retry failed requests.
+type RetryPolicySetter interface {
+	SetRetryPolicy(policy RetryPolicy)
+}
+
+// HTTPError is returned by requests to HTTP APIs that fail with an unexpected
+// status code.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (err HTTPError) Error() string {
+	return fmt.Sprintf("request failed with (%d): %s", err.StatusCode, err.Body)
+}
+
+// Unwrap returns errors.ErrBackendUnavailable for server errors, timeouts and
+// rate limiting, and nil for other statuses.
+func (err HTTPError) Unwrap() error {
+	switch {
+	case err.StatusCode >= 500, err.StatusCode == http.StatusRequestTimeout, err.StatusCode == http.StatusTooManyRequests:
+		return errors.ErrBackendUnavailable
+	}
+	return nil
+}
+
+// IsPermanent returns whether retrying will not fix the error, which is the
+// case of HTTPErrors for client errors other than timeouts and rate limiting,
+// and of transactions rejected for a known reason, even when they are wrapped.
+func IsPermanent(err error) bool {
+	var submitErr *errors.SubmitTxError
+	if errors.As(err, &submitErr) {
+		return submitErr.Reason != nil
+	}
+	var httpErr HTTPError
+	if !errors.As(err, &httpErr) {
+		return false
+	}
+	switch httpErr.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return httpErr.StatusCode >= 400 && httpErr.StatusCode < 500
+}
+
+// retrying is embedded by the clients that retry failed requests, and
+// implements RetryPolicySetter.
+type retrying struct {
+	policy *RetryPolicy
+}
+
+func (r *retrying) SetRetryPolicy(policy RetryPolicy) {
+	r.policy = &policy
+}
+
+func (r *retrying) retryPolicy() RetryPolicy {
+	if r.policy == nil {
+		return DefaultRetryPolicy()
+	}
+	return *r.policy
+}
+
+// backoff calls f until it succeeds, or until the policy gives up on it, in
+// which case the last error is returned. Failures are logged as warnings.
+func backoff(ctx context.Context, policy RetryPolicy, logger Logger, f func() error) error {
+	start := time.Now()
+	interval := policy.InitialInterval
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			return errors.ErrTimedOut
+		default:
+		}
+
+		err := f()
+		if err == nil {
+			return nil
+		}
+		if policy.Retryable != nil && !policy.Retryable(err) || policy.Retryable == nil && IsPermanent(err) {
+			return err
+		}
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			return err
+		}
+
+		wait := interval
+		if policy.Jitter > 0 {
+			wait += time.Duration((rand.Float64()*2 - 1) * policy.Jitter * float64(interval))
+		}
+		if policy.MaxElapsed > 0 && time.Since(start)+wait > policy.MaxElapsed {
+			return err
+		}
+		logger.Warnf("request failed: %v, retrying in %v", err, wait)
+
+		select {
+		case <-ctx.Done():
+			return errors.ErrTimedOut
+		case <-time.After(wait):
+		}
+		if policy.Multiplier > 0 {
+			interval = time.Duration(float64(interval) * policy.Multiplier)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package clients_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go/clients"
+
+	"github.com/renproject/libbtc-go/errors"
+)
+
+var _ = Describe("Retries", func() {
+	Context("when classifying errors", func() {
+		It("should not retry client errors", func() {
+			Expect(IsPermanent(HTTPError{StatusCode: http.StatusNotFound})).Should(BeTrue())
+			Expect(IsPermanent(HTTPError{StatusCode: http.StatusBadRequest})).Should(BeTrue())
+		})
+
+		It("should retry server errors, timeouts and rate limiting", func() {
+			for _, status := range []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusRequestTimeout, http.StatusTooManyRequests} {
+				Expect(IsPermanent(HTTPError{StatusCode: status})).Should(BeFalse())
+				Expect(errors.Is(HTTPError{StatusCode: status}, errors.ErrBackendUnavailable)).Should(BeTrue())
+			}
+		})
+
+		It("should classify wrapped errors", func() {
+			Expect(IsPermanent(fmt.Errorf("cannot get the block: %w", HTTPError{StatusCode: http.StatusNotFound}))).Should(BeTrue())
+			Expect(IsPermanent(fmt.Errorf("cannot get the block: %w", HTTPError{StatusCode: http.StatusServiceUnavailable}))).Should(BeFalse())
+			Expect(IsPermanent(fmt.Errorf("cannot submit: %w", errors.NewErrBitcoinSubmitTx("txn-mempool-conflict")))).Should(BeTrue())
+		})
+
+		It("should retry other errors", func() {
+			Expect(IsPermanent(fmt.Errorf("connection reset"))).Should(BeFalse())
+			Expect(IsPermanent(errors.NewErrBackendUnavailable("test", fmt.Errorf("connection reset")))).Should(BeFalse())
+		})
+	})
+})
@@ -28,9 +28,19 @@ type ListTransansactionsObj struct {
 	TxID     string  `json:"txid"`
 }
 
+type BatchResponse struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
 type RPCCLient interface {
 	ListTransansactions() (ListTransansactionsResponse, error)
 	ListReceivedByAddress(address string) (ListReceivedByAddressResponse, error)
+	GetRawTransactions(txIDs []string) ([]string, error)
 }
 
 type rpcClient struct {
@@ -63,7 +73,89 @@ func (client *rpcClient) ListReceivedByAddress(address string) (ListReceivedByAd
 	return resp, nil
 }
 
+// GetRawTransactions returns the hex encoded transactions with the given ids,
+// fetched with a single batched request.
+func (client *rpcClient) GetRawTransactions(txIDs []string) ([]string, error) {
+	params := make([][]interface{}, len(txIDs))
+	for i, txID := range txIDs {
+		params[i] = []interface{}{txID}
+	}
+	txs := make([]string, len(txIDs))
+	responses := make([]interface{}, len(txIDs))
+	for i := range txs {
+		responses[i] = &txs[i]
+	}
+	if err := client.sendBatchRequest("getrawtransaction", params, responses); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// sendBatchRequest calls the method once for every set of params in a single
+// JSON-RPC batch, and decodes the results into the responses in order.
+func (client *rpcClient) sendBatchRequest(method string, params [][]interface{}, responses []interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	reqs := make([]map[string]interface{}, len(params))
+	for i := range params {
+		reqs[i] = map[string]interface{}{
+			"jsonrpc": "1.0",
+			"id":      i,
+			"method":  method,
+			"params":  params[i],
+		}
+	}
+	data, err := json.Marshal(reqs)
+	if err != nil {
+		return err
+	}
+
+	results := []BatchResponse{}
+	if err := client.send(data, &results); err != nil {
+		return err
+	}
+	if len(results) != len(params) {
+		return fmt.Errorf("expected %d responses to batch request, got %d", len(params), len(results))
+	}
+	for _, result := range results {
+		if result.ID < 0 || result.ID >= len(responses) {
+			return fmt.Errorf("unexpected response id %d in batch request", result.ID)
+		}
+		if result.Error != nil {
+			return fmt.Errorf("%s failed with (%d): %s", method, result.Error.Code, result.Error.Message)
+		}
+		if err := json.Unmarshal(result.Result, responses[result.ID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// call calls the method with the params, and decodes its result into the
+// response.
+func (client *rpcClient) call(method string, params []interface{}, response interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "1.0",
+		"id":      method,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	return client.sendRequest(data, response)
+}
+
 func (client *rpcClient) sendRequest(data []byte, response interface{}) error {
+	result := Response{}
+	if err := client.send(data, &result); err != nil {
+		return err
+	}
+	return json.Unmarshal(result.Result, response)
+}
+
+func (client *rpcClient) send(data []byte, response interface{}) error {
 	request, err := http.NewRequest("POST", fmt.Sprintf("http://%s", client.host), bytes.NewBuffer(data))
 	if err != nil {
 		return err
@@ -83,10 +175,5 @@ func (client *rpcClient) sendRequest(data []byte, response interface{}) error {
 		return errors.New(string(msg))
 	}
 
-	result := Response{}
-	if err := json.Unmarshal(msg, &result); err != nil {
-		return err
-	}
-
-	return json.Unmarshal(result.Result, response)
+	return json.Unmarshal(msg, response)
 }
@@ -0,0 +1,324 @@
+package clients
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ZMQ topics published by bitcoind.
+const (
+	zmqTopicRawBlock = "rawblock"
+	zmqTopicRawTx    = "rawtx"
+)
+
+// ZMTP frame flags.
+const (
+	zmtpFlagMore    = 0x01
+	zmtpFlagLong    = 0x02
+	zmtpFlagCommand = 0x04
+)
+
+// ZMQListener receives the blocks and transactions published by the ZMQ
+// interface of bitcoind (-zmqpubrawblock and -zmqpubrawtx).
+type ZMQListener interface {
+	// Blocks returns a channel of the blocks connected to the chain of the
+	// node. It is closed when the listener stops.
+	Blocks() <-chan *wire.MsgBlock
+
+	// Transactions returns a channel of the transactions accepted in the
+	// mempool of the node, and of the transactions in connected blocks. It is
+	// closed when the listener stops.
+	Transactions() <-chan *wire.MsgTx
+
+	// Err returns the error that stopped the listener, once the channels are
+	// closed.
+	Err() error
+
+	// Close stops the listener.
+	Close() error
+}
+
+// ZMQAddressSetter is implemented by the clients of nodes that publish blocks
+// through ZMQ, so that they subscribe to blocks instead of polling for them.
+type ZMQAddressSetter interface {
+	SetZMQAddress(addr string)
+}
+
+type zmqListener struct {
+	conn   net.Conn
+	blocks chan *wire.MsgBlock
+	txs    chan *wire.MsgTx
+	done   chan struct{}
+	once   *sync.Once
+
+	mu  *sync.Mutex
+	err error
+}
+
+// NewZMQListener connects to the ZMQ publisher of bitcoind at the given
+// address (for example "127.0.0.1:28332" or "tcp://127.0.0.1:28332") and
+// subscribes to the rawblock and rawtx topics. If bitcoind publishes them on
+// different addresses, a listener is needed for each one.
+func NewZMQListener(addr string) (ZMQListener, error) {
+	conn, err := net.DialTimeout("tcp", strings.TrimPrefix(addr, "tcp://"), 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	listener := &zmqListener{
+		conn:   conn,
+		blocks: make(chan *wire.MsgBlock, 16),
+		txs:    make(chan *wire.MsgTx, 1024),
+		done:   make(chan struct{}),
+		once:   new(sync.Once),
+		mu:     new(sync.Mutex),
+	}
+
+	reader := bufio.NewReader(conn)
+	if err := listener.handshake(reader); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	for _, topic := range []string{zmqTopicRawBlock, zmqTopicRawTx} {
+		if err := zmtpWriteFrame(conn, 0, append([]byte{0x01}, topic...)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	go listener.run(reader)
+	return listener, nil
+}
+
+func (listener *zmqListener) Blocks() <-chan *wire.MsgBlock {
+	return listener.blocks
+}
+
+func (listener *zmqListener) Transactions() <-chan *wire.MsgTx {
+	return listener.txs
+}
+
+func (listener *zmqListener) Err() error {
+	listener.mu.Lock()
+	defer listener.mu.Unlock()
+	return listener.err
+}
+
+func (listener *zmqListener) Close() error {
+	listener.once.Do(func() { close(listener.done) })
+	return listener.conn.Close()
+}
+
+// subscribeZMQBlocks connects a listener to the ZMQ publisher at the given
+// address and turns the blocks it receives into events, looking their height
+// up with the given function. The listener is closed once the context is done.
+func subscribeZMQBlocks(ctx context.Context, addr string, height func(hash *chainhash.Hash) (int64, error)) (<-chan BlockEvent, error) {
+	listener, err := NewZMQListener(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Transactions are not needed, but have to be drained so that the
+	// listener does not stop receiving blocks.
+	go func() {
+		for range listener.Transactions() {
+		}
+	}()
+
+	events := make(chan BlockEvent, 16)
+	go func() {
+		defer close(events)
+		defer listener.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case block, ok := <-listener.Blocks():
+				if !ok {
+					return
+				}
+				hash := block.BlockHash()
+				blockHeight, err := height(&hash)
+				if err != nil {
+					continue
+				}
+				event := BlockEvent{
+					Height:   blockHeight,
+					Hash:     hash.String(),
+					PrevHash: block.Header.PrevBlock.String(),
+					Time:     block.Header.Timestamp.Unix(),
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case events <- event:
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// handshake performs the ZMTP 3.0 handshake of a SUB socket, using the NULL
+// security mechanism.
+func (listener *zmqListener) handshake(reader *bufio.Reader) error {
+	listener.conn.SetDeadline(time.Now().Add(30 * time.Second))
+	defer listener.conn.SetDeadline(time.Time{})
+
+	greeting := make([]byte, 64)
+	greeting[0] = 0xFF
+	greeting[9] = 0x7F
+	greeting[10] = 3
+	greeting[11] = 0
+	copy(greeting[12:32], "NULL")
+	if _, err := listener.conn.Write(greeting); err != nil {
+		return err
+	}
+
+	peerGreeting := make([]byte, 64)
+	if _, err := io.ReadFull(reader, peerGreeting); err != nil {
+		return err
+	}
+	if peerGreeting[0] != 0xFF || peerGreeting[9] != 0x7F || peerGreeting[10] < 3 {
+		return fmt.Errorf("zmq peer does not speak ZMTP 3")
+	}
+	if mechanism := string(bytes.TrimRight(peerGreeting[12:32], "\x00")); mechanism != "NULL" {
+		return fmt.Errorf("unsupported zmq security mechanism %s", mechanism)
+	}
+
+	ready := []byte{5}
+	ready = append(ready, "READY"...)
+	ready = append(ready, zmtpProperty("Socket-Type", "SUB")...)
+	if err := zmtpWriteFrame(listener.conn, zmtpFlagCommand, ready); err != nil {
+		return err
+	}
+
+	flags, body, err := zmtpReadFrame(reader)
+	if err != nil {
+		return err
+	}
+	if flags&zmtpFlagCommand == 0 || len(body) < 6 || string(body[1:6]) != "READY" {
+		return fmt.Errorf("unexpected zmq handshake command")
+	}
+	return nil
+}
+
+// run reads the messages published by bitcoind until the connection fails or
+// is closed. Messages are made of the topic, the body and a sequence number.
+func (listener *zmqListener) run(reader *bufio.Reader) {
+	defer close(listener.blocks)
+	defer close(listener.txs)
+
+	for {
+		parts, err := zmtpReadMessage(reader)
+		if err != nil {
+			listener.mu.Lock()
+			listener.err = err
+			listener.mu.Unlock()
+			return
+		}
+		if len(parts) < 2 {
+			continue
+		}
+
+		switch string(parts[0]) {
+		case zmqTopicRawBlock:
+			block := new(wire.MsgBlock)
+			if err := block.Deserialize(bytes.NewReader(parts[1])); err != nil {
+				continue
+			}
+			select {
+			case listener.blocks <- block:
+			case <-listener.done:
+				return
+			}
+		case zmqTopicRawTx:
+			tx := new(wire.MsgTx)
+			if err := tx.Deserialize(bytes.NewReader(parts[1])); err != nil {
+				continue
+			}
+			select {
+			case listener.txs <- tx:
+			case <-listener.done:
+				return
+			}
+		}
+	}
+}
+
+// zmtpReadMessage reads the frames of the next message, skipping commands.
+func zmtpReadMessage(reader *bufio.Reader) ([][]byte, error) {
+	parts := [][]byte{}
+	for {
+		flags, body, err := zmtpReadFrame(reader)
+		if err != nil {
+			return nil, err
+		}
+		if flags&zmtpFlagCommand != 0 {
+			continue
+		}
+		parts = append(parts, body)
+		if flags&zmtpFlagMore == 0 {
+			return parts, nil
+		}
+	}
+}
+
+func zmtpReadFrame(reader *bufio.Reader) (byte, []byte, error) {
+	flags, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var size uint64
+	if flags&zmtpFlagLong != 0 {
+		if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+			return 0, nil, err
+		}
+	} else {
+		short, err := reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(short)
+	}
+	if size > wire.MaxMessagePayload {
+		return 0, nil, fmt.Errorf("zmq frame of %d bytes is too large", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
+
+func zmtpWriteFrame(w io.Writer, flags byte, body []byte) error {
+	var frame []byte
+	if len(body) > 255 {
+		frame = make([]byte, 9, 9+len(body))
+		frame[0] = flags | zmtpFlagLong
+		binary.BigEndian.PutUint64(frame[1:], uint64(len(body)))
+	} else {
+		frame = []byte{flags, byte(len(body))}
+	}
+	_, err := w.Write(append(frame, body...))
+	return err
+}
+
+func zmtpProperty(name, value string) []byte {
+	property := []byte{byte(len(name))}
+	property = append(property, name...)
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(value)))
+	property = append(property, size...)
+	return append(property, value...)
+}
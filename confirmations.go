@@ -0,0 +1,32 @@
+package libbtc
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	minConfirmationsInterval = time.Second
+	maxConfirmationsInterval = time.Minute
+)
+
+func (client *client) WaitForConfirmations(ctx context.Context, txHash string, n int64) error {
+	interval := minConfirmationsInterval
+	for {
+		// Errors are retried, as the transaction may not have reached the
+		// backend yet.
+		conf, err := client.Confirmations(ctx, txHash)
+		if err == nil && conf >= n {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ErrTimedOut
+		case <-time.After(interval):
+		}
+		if interval *= 2; interval > maxConfirmationsInterval {
+			interval = maxConfirmationsInterval
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package libbtc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"strings"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// DecodedTx is a structured view of a raw transaction.
+type DecodedTx struct {
+	TxHash   string
+	Version  int32
+	LockTime uint32
+	VSize    int
+
+	// RBF is whether the transaction signals replaceability, as defined in
+	// BIP125.
+	RBF bool
+
+	Inputs  []DecodedInput
+	Outputs []DecodedOutput
+
+	// Fee is the fee paid by the transaction, computed from the outputs
+	// spent by its inputs.
+	Fee int64
+}
+
+// DecodedInput is an input of a decoded transaction, along with the output it
+// spends.
+type DecodedInput struct {
+	ResolvedInput
+	Sequence uint32
+}
+
+// DecodedOutput is an output of a decoded transaction.
+type DecodedOutput struct {
+	Index        int
+	Value        int64
+	ScriptPubKey []byte
+	ScriptClass  txscript.ScriptClass
+
+	// Address is the address the output pays to, it is nil for non-standard
+	// scripts and scripts with several addresses.
+	Address btcutil.Address
+}
+
+// DecodeRawTransaction decodes the serialized transaction, which may be hex
+// encoded, and resolves the outputs spent by its inputs to compute its fee.
+func (client *client) DecodeRawTransaction(ctx context.Context, rawTx []byte) (DecodedTx, error) {
+	// Serialized transactions start with their version, which is not a hex
+	// character.
+	if decoded, err := hex.DecodeString(string(bytes.TrimSpace(rawTx))); err == nil {
+		rawTx = decoded
+	}
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	if err := msgTx.Deserialize(bytes.NewReader(rawTx)); err != nil {
+		return DecodedTx{}, err
+	}
+	return client.decode(ctx, msgTx)
+}
+
+func (client *client) PublishRawTransaction(ctx context.Context, txHex string) error {
+	txBytes, err := hex.DecodeString(strings.TrimSpace(txHex))
+	if err != nil {
+		return err
+	}
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	if err := msgTx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return err
+	}
+	return client.PublishTransaction(ctx, msgTx)
+}
+
+// Transaction is a transaction along with its position in the chain.
+type Transaction struct {
+	DecodedTx
+
+	// BlockHeight is the height of the block of the transaction, zero if it
+	// is unconfirmed.
+	BlockHeight   int64
+	Confirmations int64
+}
+
+func (client *client) GetTransaction(ctx context.Context, txHash string) (Transaction, error) {
+	msgTx, err := client.RawTransaction(ctx, txHash)
+	if err != nil {
+		return Transaction{}, err
+	}
+	decoded, err := client.decode(ctx, msgTx)
+	if err != nil {
+		return Transaction{}, err
+	}
+	conf, err := client.Confirmations(ctx, txHash)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	tx := Transaction{
+		DecodedTx:     decoded,
+		Confirmations: conf,
+	}
+	if conf > 0 {
+		tip, err := client.ChainTip(ctx)
+		if err != nil {
+			return Transaction{}, err
+		}
+		tx.BlockHeight = tip.Height - conf + 1
+	}
+	return tx, nil
+}
+
+// decode returns the structured view of the transaction, resolving the
+// outputs spent by its inputs.
+func (client *client) decode(ctx context.Context, msgTx *wire.MsgTx) (DecodedTx, error) {
+	inputs, err := client.ResolveInputs(ctx, msgTx)
+	if err != nil {
+		return DecodedTx{}, err
+	}
+
+	decoded := DecodedTx{
+		TxHash:   msgTx.TxHash().String(),
+		Version:  msgTx.Version,
+		LockTime: msgTx.LockTime,
+		VSize:    txVSize(msgTx),
+		Inputs:   make([]DecodedInput, len(msgTx.TxIn)),
+		Outputs:  make([]DecodedOutput, len(msgTx.TxOut)),
+		Fee:      inputs.Fee(msgTx),
+	}
+	for i, txIn := range msgTx.TxIn {
+		decoded.Inputs[i] = DecodedInput{
+			ResolvedInput: inputs[i],
+			Sequence:      txIn.Sequence,
+		}
+		if txIn.Sequence < wire.MaxTxInSequenceNum-1 {
+			decoded.RBF = true
+		}
+	}
+	for i, txOut := range msgTx.TxOut {
+		decoded.Outputs[i] = DecodedOutput{
+			Index:        i,
+			Value:        txOut.Value,
+			ScriptPubKey: txOut.PkScript,
+			ScriptClass:  txscript.NonStandardTy,
+		}
+		class, addrs, _, err := txscript.ExtractPkScriptAddrs(txOut.PkScript, client.NetworkParams())
+		if err != nil {
+			continue
+		}
+		decoded.Outputs[i].ScriptClass = class
+		if len(addrs) == 1 {
+			decoded.Outputs[i].Address = addrs[0]
+		}
+	}
+	return decoded, nil
+}
@@ -0,0 +1,86 @@
+package libbtc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tyler-smith/go-bip32"
+)
+
+// ChildIndex is a component of a BIP32 derivation path. Hardened indexes are
+// offset by bip32.FirstHardenedChild, and built with Hardened rather than by
+// hand.
+type ChildIndex uint32
+
+// Hardened returns the hardened child index i', for i below 2^31.
+func Hardened(i uint32) ChildIndex {
+	return ChildIndex(bip32.FirstHardenedChild + i)
+}
+
+// IsHardened returns whether the index is hardened.
+func (index ChildIndex) IsHardened() bool {
+	return uint32(index) >= bip32.FirstHardenedChild
+}
+
+// String returns the index in the notation of BIP32 paths, such as 84'.
+func (index ChildIndex) String() string {
+	if index.IsHardened() {
+		return strconv.FormatUint(uint64(uint32(index)-bip32.FirstHardenedChild), 10) + "'"
+	}
+	return strconv.FormatUint(uint64(index), 10)
+}
+
+// DerivationPath is a BIP32 derivation path from the master key.
+type DerivationPath []ChildIndex
+
+// AccountPath returns the path m/purpose'/coinType'/account' of a BIP44
+// account-level key, for the purposes of BIP44, BIP49 and BIP84.
+func AccountPath(purpose, coinType, account uint32) DerivationPath {
+	return DerivationPath{Hardened(purpose), Hardened(coinType), Hardened(account)}
+}
+
+// ParseDerivationPath parses a path such as m/84'/0'/0', where hardened
+// indexes are marked with either ' or h.
+func ParseDerivationPath(path string) (DerivationPath, error) {
+	components := strings.Split(strings.TrimSpace(path), "/")
+	if components[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path %q: expected a path from m", path)
+	}
+	parsed := DerivationPath{}
+	for _, component := range components[1:] {
+		hardened := strings.HasSuffix(component, "'") || strings.HasSuffix(component, "h")
+		if hardened {
+			component = component[:len(component)-1]
+		}
+		i, err := strconv.ParseUint(component, 10, 32)
+		if err != nil || i >= uint64(bip32.FirstHardenedChild) {
+			return nil, fmt.Errorf("invalid derivation path %q: invalid index %q", path, component)
+		}
+		if hardened {
+			parsed = append(parsed, Hardened(uint32(i)))
+		} else {
+			parsed = append(parsed, ChildIndex(i))
+		}
+	}
+	return parsed, nil
+}
+
+// String returns the path in the notation of BIP32, such as m/84'/0'/0'.
+func (path DerivationPath) String() string {
+	components := []string{"m"}
+	for _, index := range path {
+		components = append(components, index.String())
+	}
+	return strings.Join(components, "/")
+}
+
+// Uint32s returns the indexes of the path, as expected by the functions taking
+// raw derivation paths.
+func (path DerivationPath) Uint32s() []uint32 {
+	indexes := make([]uint32, len(path))
+	for i, index := range path {
+		indexes[i] = uint32(index)
+	}
+	return indexes
+}
@@ -0,0 +1,238 @@
+package libbtc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/storage"
+	"github.com/tyler-smith/go-bip32"
+)
+
+// BIP43 purposes of the derivation schemes walked by Wallet.Discover.
+const (
+	PurposeP2PKH      = uint32(44)
+	PurposeP2SHP2WPKH = uint32(49)
+	PurposeP2WPKH     = uint32(84)
+)
+
+// DefaultGapLimit is the gap limit recommended by BIP44.
+const DefaultGapLimit = uint32(20)
+
+// discoveryMaxAccounts bounds the number of accounts scanned per purpose.
+const discoveryMaxAccounts = uint32(100)
+
+// DiscoveredAddress is an address of the wallet that has received funds.
+type DiscoveredAddress struct {
+	Purpose uint32
+	Account uint32
+	Chain   uint32
+	Index   uint32
+	Address btcutil.Address
+	Balance int64
+	UTXOs   []clients.UTXO
+}
+
+// Discovery is the result of a gap-limit scan of the wallet.
+type Discovery struct {
+	// Addresses are the used addresses, ordered by purpose, account, chain
+	// and index.
+	Addresses []DiscoveredAddress
+	// Balance is the aggregate balance of the used addresses.
+	Balance int64
+	// Next is the number of derived addresses on each chain of each account
+	// up to the last used one, indexed by purpose, account and chain. It can
+	// be given to NewHDAccount to keep spending from the discovered addresses.
+	Next map[uint32]map[uint32][2]uint32
+}
+
+// Discover walks the external and internal chains of the BIP44, BIP49 and
+// BIP84 accounts of the wallet, and returns the addresses that have a history
+// on the chain. A chain is scanned until gapLimit consecutive addresses are
+// unused, and accounts are scanned until one has no used address, as
+// described in BIP44. If the wallet has a store, addresses known to be used
+// are not queried for their history again, and the discovered addresses and
+// their UTXOs are cached.
+func (wallet *wallet) Discover(ctx context.Context, client Client, gapLimit uint32, password string) (Discovery, error) {
+	if gapLimit == 0 {
+		gapLimit = DefaultGapLimit
+	}
+	params := client.NetworkParams()
+
+	known := map[string]bool{}
+	// heights are the heights at which the known addresses were first seen
+	// used, which are kept when they are stored again.
+	heights := map[string]int64{}
+	var walletID string
+	var tipHeight int64
+	if wallet.store != nil {
+		var err error
+		if walletID, err = wallet.id(password); err != nil {
+			return Discovery{}, err
+		}
+		addresses, err := wallet.store.Addresses(walletID)
+		if err != nil {
+			return Discovery{}, err
+		}
+		for _, meta := range addresses {
+			known[meta.Address] = true
+			heights[meta.Address] = meta.Height
+		}
+		tip, err := client.ChainTip(ctx)
+		if err != nil {
+			return Discovery{}, err
+		}
+		tipHeight = tip.Height
+	}
+	discovery := Discovery{
+		Addresses: []DiscoveredAddress{},
+		Next:      map[uint32]map[uint32][2]uint32{},
+	}
+
+	for _, purpose := range []uint32{PurposeP2PKH, PurposeP2SHP2WPKH, PurposeP2WPKH} {
+		discovery.Next[purpose] = map[uint32][2]uint32{}
+		for account := uint32(0); account < discoveryMaxAccounts; account++ {
+			key, err := wallet.deriveKey([]uint32{
+				bip32.FirstHardenedChild + purpose,
+				bip32.FirstHardenedChild + params.HDCoinType,
+				bip32.FirstHardenedChild + account,
+			}, password)
+			if err != nil {
+				return Discovery{}, err
+			}
+
+			var next [2]uint32
+			for _, chain := range []uint32{ExternalChain, InternalChain} {
+				used, err := discoverChain(ctx, client, key, purpose, chain, gapLimit, known)
+				if err != nil {
+					return Discovery{}, err
+				}
+				for _, address := range used {
+					address.Account = account
+					if wallet.store != nil {
+						height, ok := heights[address.Address.EncodeAddress()]
+						if !ok {
+							height = tipHeight
+						}
+						if err := wallet.storeAddress(walletID, address, height, tipHeight); err != nil {
+							return Discovery{}, err
+						}
+					}
+					discovery.Addresses = append(discovery.Addresses, address)
+					discovery.Balance += address.Balance
+					next[chain] = address.Index + 1
+				}
+			}
+			if next[ExternalChain] == 0 && next[InternalChain] == 0 {
+				break
+			}
+			discovery.Next[purpose][account] = next
+		}
+	}
+	return discovery, nil
+}
+
+// storeAddress caches a discovered address, with the height at which it was
+// first seen used, and its UTXOs at the tip height.
+func (wallet *wallet) storeAddress(walletID string, address DiscoveredAddress, height, tipHeight int64) error {
+	encoded := address.Address.EncodeAddress()
+	meta := storage.AddressMeta{
+		Address: encoded,
+		Purpose: address.Purpose,
+		Account: address.Account,
+		Chain:   address.Chain,
+		Index:   address.Index,
+		Height:  height,
+	}
+	if err := wallet.store.PutAddress(walletID, meta); err != nil {
+		return err
+	}
+	return wallet.store.PutUTXOs(walletID, encoded, storage.UTXOSet{
+		UTXOs:  address.UTXOs,
+		Height: tipHeight,
+	})
+}
+
+// discoverChain returns the used addresses of a chain of the account-level
+// key, stopping after gapLimit consecutive unused addresses. Addresses in
+// known are used without querying their history.
+func discoverChain(ctx context.Context, client Client, accountKey *bip32.Key, purpose, chain, gapLimit uint32, known map[string]bool) ([]DiscoveredAddress, error) {
+	chainKey, err := accountKey.NewChildKey(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	used := []DiscoveredAddress{}
+	for index, gap := uint32(0), uint32(0); gap < gapLimit; index++ {
+		key, err := chainKey.NewChildKey(index)
+		if err != nil {
+			return nil, err
+		}
+		address, err := purposeAddress(purpose, key.PublicKey().Key, client.NetworkParams())
+		if err != nil {
+			return nil, err
+		}
+
+		// Any funds ever received by the address mean it has a history.
+		if !known[address.EncodeAddress()] {
+			funded, _, err := client.ScriptFunded(ctx, address.EncodeAddress(), 1)
+			if err != nil {
+				return nil, err
+			}
+			if !funded {
+				gap++
+				continue
+			}
+		}
+		gap = 0
+		utxos, err := client.GetUTXOs(ctx, address.EncodeAddress(), 999999, 0)
+		if err != nil {
+			return nil, err
+		}
+		var balance int64
+		for _, utxo := range utxos {
+			balance += utxo.Amount
+		}
+		used = append(used, DiscoveredAddress{
+			Purpose: purpose,
+			Chain:   chain,
+			Index:   index,
+			Address: address,
+			Balance: balance,
+			UTXOs:   utxos,
+		})
+	}
+	return used, nil
+}
+
+// purposeAddress returns the address of the compressed public key for the
+// derivation scheme of the purpose.
+func purposeAddress(purpose uint32, pubKey []byte, params *chaincfg.Params) (btcutil.Address, error) {
+	switch purpose {
+	case PurposeP2PKH:
+		return btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey), params)
+	case PurposeP2SHP2WPKH:
+		witnessScript, err := p2wpkhScript(pubKey, params)
+		if err != nil {
+			return nil, err
+		}
+		return btcutil.NewAddressScriptHash(witnessScript, params)
+	case PurposeP2WPKH:
+		return btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey), params)
+	default:
+		return nil, fmt.Errorf("unsupported derivation purpose %d", purpose)
+	}
+}
+
+// p2wpkhScript returns the P2WPKH witness program of the compressed public
+// key, which is the redeem script of its P2SH-P2WPKH address.
+func p2wpkhScript(pubKey []byte, params *chaincfg.Params) ([]byte, error) {
+	witnessAddr, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey), params)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.PayToAddrScript(witnessAddr)
+}
@@ -0,0 +1,117 @@
+package libbtc
+
+import (
+	"context"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/libbtc-go/clients"
+)
+
+// DoubleSpend is an input of a watched transaction whose output has been spent
+// by a conflicting transaction.
+type DoubleSpend struct {
+	Index    int
+	OutPoint wire.OutPoint
+
+	// ConflictTxHash is the hash of the conflicting transaction, it is empty
+	// if the backend cannot tell which transaction spent the output.
+	ConflictTxHash string
+
+	// Mined is true if the conflicting transaction has been mined.
+	Mined bool
+}
+
+// knownTxTimeout bounds the time spent checking whether the watched
+// transaction is known, as backends retry until the context is done.
+const knownTxTimeout = 10 * time.Second
+
+func (client *client) WatchDoubleSpends(ctx context.Context, msgTx *wire.MsgTx, interval time.Duration) (<-chan DoubleSpend, error) {
+	inputs, err := client.ResolveInputs(ctx, msgTx)
+	if err != nil {
+		return nil, err
+	}
+
+	txHash := msgTx.TxHash().String()
+	doubleSpends := make(chan DoubleSpend, len(inputs))
+	go func() {
+		defer close(doubleSpends)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		reported := map[int]bool{}
+		for {
+			if conf, err := client.confirmations(ctx, txHash); err == nil && conf > 0 {
+				return
+			}
+			for _, input := range inputs {
+				if reported[input.Index] || isCoinbaseOutPoint(input.PreviousOutPoint) {
+					continue
+				}
+				doubleSpend, ok := client.checkDoubleSpend(ctx, txHash, input)
+				if !ok {
+					continue
+				}
+				reported[input.Index] = true
+				select {
+				case <-ctx.Done():
+					return
+				case doubleSpends <- doubleSpend:
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return doubleSpends, nil
+}
+
+// checkDoubleSpend returns whether the output spent by the input has been
+// spent by another transaction. Backends that cannot find the spending
+// transaction are asked whether the output is still unspent instead, in which
+// case a spent output is only reported if the watched transaction is unknown.
+func (client *client) checkDoubleSpend(ctx context.Context, txHash string, input ResolvedInput) (DoubleSpend, bool) {
+	doubleSpend := DoubleSpend{
+		Index:    input.Index,
+		OutPoint: input.PreviousOutPoint,
+	}
+
+	if spender, ok := client.ClientCore.(clients.OutputSpender); ok {
+		spend, spent, err := spender.SpentBy(ctx, input.PreviousOutPoint.Hash.String(), input.PreviousOutPoint.Index)
+		if err == nil {
+			if !spent || spend.TxHash == txHash {
+				return DoubleSpend{}, false
+			}
+			doubleSpend.ConflictTxHash = spend.TxHash
+			doubleSpend.Mined = spend.Confirmations > 0
+			return doubleSpend, true
+		}
+	}
+
+	if input.Address == nil {
+		return DoubleSpend{}, false
+	}
+	utxos, err := client.GetUTXOs(ctx, input.Address.EncodeAddress(), 999999, 0)
+	if err != nil {
+		return DoubleSpend{}, false
+	}
+	for _, utxo := range utxos {
+		if utxo.TxHash == input.PreviousOutPoint.Hash.String() && utxo.Vout == input.PreviousOutPoint.Index {
+			return DoubleSpend{}, false
+		}
+	}
+	if _, err := client.confirmations(ctx, txHash); err == nil {
+		return DoubleSpend{}, false
+	}
+	return doubleSpend, true
+}
+
+func (client *client) confirmations(ctx context.Context, txHash string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, knownTxTimeout)
+	defer cancel()
+	return client.Confirmations(ctx, txHash)
+}
@@ -0,0 +1,51 @@
+package libbtc
+
+import (
+	"github.com/btcsuite/btcd/wire"
+)
+
+// DustPolicy decides what happens to a change output whose value, once fees
+// are paid, falls below dust. Such outputs are non-standard and would get the
+// transaction rejected.
+type DustPolicy uint8
+
+// DustPolicy values.
+const (
+	// DustToFee drops the change output and adds its value to the fee.
+	DustToFee = DustPolicy(iota)
+	// DustToPayment drops the change output and adds its value to the
+	// payment output. It falls back to DustToFee if there is no payment
+	// output.
+	DustToPayment
+	// DustFail fails to build the transaction.
+	DustFail
+)
+
+// applyDustPolicy applies the policy to the change output at changeIndex if
+// its value is below dust. paymentIndex is the index of the payment output,
+// or a negative number if there is none. It returns the value that was added
+// to the payment output.
+func applyDustPolicy(msgTx *wire.MsgTx, changeIndex, paymentIndex int, dust int64, policy DustPolicy) (int64, error) {
+	change := msgTx.TxOut[changeIndex].Value
+	if change >= dust {
+		return 0, nil
+	}
+
+	switch policy {
+	case DustFail:
+		return 0, NewErrDustChange(change, dust)
+	case DustToPayment:
+		msgTx.TxOut = append(msgTx.TxOut[:changeIndex], msgTx.TxOut[changeIndex+1:]...)
+		if paymentIndex < 0 || change <= 0 {
+			return 0, nil
+		}
+		if paymentIndex > changeIndex {
+			paymentIndex--
+		}
+		msgTx.TxOut[paymentIndex].Value += change
+		return change, nil
+	default:
+		msgTx.TxOut = append(msgTx.TxOut[:changeIndex], msgTx.TxOut[changeIndex+1:]...)
+		return 0, nil
+	}
+}
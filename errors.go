@@ -1,32 +1,55 @@
 package libbtc
 
 import (
-	"errors"
 	"fmt"
+
+	"github.com/renproject/libbtc-go/errors"
 )
 
 // ErrPreConditionCheckFailed indicates that the pre-condition for executing
 // a transaction failed.
-var ErrPreConditionCheckFailed = errors.New("pre-condition check failed")
+var ErrPreConditionCheckFailed = errors.ErrPreConditionCheckFailed
 
 // ErrPostConditionCheckFailed indicates that the post-condition for executing
 // a transaction failed.
-var ErrPostConditionCheckFailed = errors.New("post-condition check failed")
+var ErrPostConditionCheckFailed = errors.ErrPostConditionCheckFailed
+
+var ErrTimedOut = errors.ErrTimedOut
 
-var ErrTimedOut = errors.New("timed out")
+// ErrTxNotFound indicates that the backend does not know the transaction.
+var ErrTxNotFound = errors.ErrTxNotFound
 
-var ErrNoSpendingTransactions = fmt.Errorf("No spending transactions")
+var ErrNoSpendingTransactions = errors.ErrNoSpendingTransactions
 
-var ErrMismatchedPubKeys = fmt.Errorf("failed to fund the transaction mismatched script public keys")
+var ErrMismatchedPubKeys = errors.ErrMismatchedPubKeys
+
+// The reasons for which backends reject transactions, see the errors package.
+var (
+	ErrTxAlreadyInMempool = errors.ErrTxAlreadyInMempool
+	ErrMempoolConflict    = errors.ErrMempoolConflict
+	ErrInsufficientFee    = errors.ErrInsufficientFee
+	ErrDustOutput         = errors.ErrDustOutput
+	ErrBackendUnavailable = errors.ErrBackendUnavailable
+	ErrNonStandard        = errors.ErrNonStandard
+)
+
+// ErrAbsurdFee is unwrapped from the errors returned when the fee of a
+// transaction exceeds the maximum percentage of the value it transfers, see
+// errors.AbsurdFeeError.
+var ErrAbsurdFee = errors.ErrAbsurdFee
 
 func NewErrUnsupportedNetwork(network string) error {
 	return fmt.Errorf("unsupported network %s", network)
 }
 
 func NewErrBitcoinSubmitTx(msg string) error {
-	return fmt.Errorf("error while submitting Bitcoin transaction: %s", msg)
+	return errors.NewErrBitcoinSubmitTx(msg)
 }
 func NewErrInsufficientBalance(address string, required, current int64) error {
 	return fmt.Errorf("insufficient balance in %s "+
 		"required:%d current:%d", address, required, current)
 }
+
+func NewErrDustChange(change, dust int64) error {
+	return fmt.Errorf("change output value (%d) is less than bitcoin's minimum value (%d)", change, dust)
+}
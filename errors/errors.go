@@ -3,6 +3,7 @@ package errors
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 // ErrPreConditionCheckFailed indicates that the pre-condition for executing
@@ -15,17 +16,160 @@ var ErrPostConditionCheckFailed = errors.New("post-condition check failed")
 
 var ErrTimedOut = errors.New("timed out")
 
+// Is reports whether any error in the chain of err matches target, as the
+// standard errors.Is, so that callers importing this package need not import
+// both.
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
+
+// As finds the first error in the chain of err that matches target, as the
+// standard errors.As.
+func As(err error, target interface{}) bool {
+	return errors.As(err, target)
+}
+
+// ErrTxNotFound indicates that the backend does not know the transaction,
+// which was never published or was dropped from its mempool.
+var ErrTxNotFound = errors.New("transaction not found")
+
 var ErrNoSpendingTransactions = fmt.Errorf("No spending transactions")
 
 var ErrMismatchedPubKeys = fmt.Errorf("failed to fund the transaction mismatched script public keys")
 
+// ErrNoBackends indicates that a multi-backend client was created without any
+// backends.
+var ErrNoBackends = errors.New("no backends provided")
+
+func NewErrUnsupportedOperation(operation, backend string) error {
+	return fmt.Errorf("%s is not supported by the %s backend", operation, backend)
+}
+
+func NewErrMismatchedNetworks(expected, got string) error {
+	return fmt.Errorf("mismatched networks: expected %s got %s", expected, got)
+}
+
 func NewErrUnsupportedNetwork(network string) error {
 	return fmt.Errorf("unsupported network %s", network)
 }
 
+// The reasons for which backends reject transactions. Backends report them
+// with backend specific messages, which are mapped to these errors so that
+// callers can check them with errors.Is instead of matching the messages.
+var (
+	// ErrTxAlreadyInMempool indicates that the transaction is already known
+	// by the backend, in its mempool or in the chain.
+	ErrTxAlreadyInMempool = errors.New("transaction already in mempool")
+
+	// ErrMempoolConflict indicates that an input of the transaction is spent
+	// by another transaction, or does not exist.
+	ErrMempoolConflict = errors.New("transaction conflicts with the mempool")
+
+	// ErrInsufficientFee indicates that the fee of the transaction is below
+	// the minimum fee accepted by the backend, or below the fee of the
+	// transaction it replaces.
+	ErrInsufficientFee = errors.New("insufficient fee")
+
+	// ErrDustOutput indicates that an output of the transaction is dust.
+	ErrDustOutput = errors.New("dust output")
+
+	// ErrBackendUnavailable indicates that the backend could not be reached,
+	// or failed to serve the request.
+	ErrBackendUnavailable = errors.New("backend unavailable")
+
+	// ErrNonStandard indicates that the transaction breaks a standardness
+	// rule of the relay policy, other than the dust and fee ones.
+	ErrNonStandard = errors.New("non-standard transaction")
+)
+
+// submitTxReasons maps the messages of backends rejecting transactions to the
+// reasons, in the order they are checked.
+var submitTxReasons = []struct {
+	reason   error
+	messages []string
+}{
+	{ErrTxAlreadyInMempool, []string{"already in mempool", "already-in-mempool", "already known", "already-known", "already in block chain", "already exists"}},
+	{ErrMempoolConflict, []string{"mempool-conflict", "mempool conflict", "missingorspent", "missing inputs", "inputs-spent", "double spend"}},
+	{ErrInsufficientFee, []string{"min relay fee not met", "mempool min fee not met", "insufficient fee", "insufficient priority", "fee is too low"}},
+	{ErrDustOutput, []string{"dust"}},
+	{ErrNonStandard, []string{"non-standard", "tx-size", "scriptsig-size", "scriptsig-not-pushonly", "scriptpubkey", "bare-multisig", "multi-op-return"}},
+}
+
+// SubmitTxReason returns the reason for which a backend rejected a
+// transaction with the message, or nil if the message is not recognised.
+func SubmitTxReason(msg string) error {
+	msg = strings.ToLower(msg)
+	for _, reason := range submitTxReasons {
+		for _, message := range reason.messages {
+			if strings.Contains(msg, message) {
+				return reason.reason
+			}
+		}
+	}
+	return nil
+}
+
+// SubmitTxError is returned when a backend rejects a transaction. It unwraps
+// to the reason of the rejection, if the message of the backend is recognised.
+type SubmitTxError struct {
+	Reason error
+	Msg    string
+}
+
+func (err *SubmitTxError) Error() string {
+	return fmt.Sprintf("error while submitting Bitcoin transaction: %s", err.Msg)
+}
+
+// Unwrap returns the reason of the rejection, which may be nil.
+func (err *SubmitTxError) Unwrap() error {
+	return err.Reason
+}
+
 func NewErrBitcoinSubmitTx(msg string) error {
-	return fmt.Errorf("error while submitting Bitcoin transaction: %s", msg)
+	return &SubmitTxError{SubmitTxReason(msg), msg}
 }
+
+// ErrAbsurdFee indicates that the fee of a transaction exceeds the maximum
+// percentage of the value it transfers.
+var ErrAbsurdFee = errors.New("absurd fee")
+
+// AbsurdFeeError is returned when building a transaction whose fee exceeds
+// MaxFeePercent percent of the value it transfers. It unwraps to ErrAbsurdFee.
+type AbsurdFeeError struct {
+	Fee           int64
+	Value         int64
+	MaxFeePercent float64
+}
+
+func (err *AbsurdFeeError) Error() string {
+	return fmt.Sprintf("fee of %d is more than %g%% of the transferred value of %d", err.Fee, err.MaxFeePercent, err.Value)
+}
+
+// Unwrap returns ErrAbsurdFee.
+func (err *AbsurdFeeError) Unwrap() error {
+	return ErrAbsurdFee
+}
+
+// UnavailableError is returned when a backend cannot be reached. It unwraps to
+// ErrBackendUnavailable.
+type UnavailableError struct {
+	Backend string
+	Err     error
+}
+
+func (err *UnavailableError) Error() string {
+	return fmt.Sprintf("%s backend unavailable: %v", err.Backend, err.Err)
+}
+
+// Unwrap returns ErrBackendUnavailable.
+func (err *UnavailableError) Unwrap() error {
+	return ErrBackendUnavailable
+}
+
+func NewErrBackendUnavailable(backend string, err error) error {
+	return &UnavailableError{backend, err}
+}
+
 func NewErrInsufficientBalance(address string, required, current int64) error {
 	return fmt.Errorf("insufficient balance in %s "+
 		"required:%d current:%d", address, required, current)
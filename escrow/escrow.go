@@ -0,0 +1,135 @@
+package escrow
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go"
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/multisig"
+)
+
+// Escrow is a 2-of-3 multisig of a buyer, a seller and an arbiter. The buyer
+// funds it, and its funds are released to the seller or refunded to the buyer
+// with the signatures of both, or of the arbiter and either of them when they
+// disagree.
+//
+// The spends are libbtc.Txs: two of the parties sign their Hashes, and inject
+// their signatures with InjectSigsFor along with their public key.
+type Escrow struct {
+	Buyer   []byte
+	Seller  []byte
+	Arbiter []byte
+	Script  *multisig.Script
+
+	client libbtc.Client
+}
+
+// New returns the Escrow of the serialized public keys of the parties, which
+// are sorted in its script as defined in BIP67.
+func New(client libbtc.Client, buyer, seller, arbiter []byte, scriptType multisig.ScriptType) (*Escrow, error) {
+	if bytes.Equal(buyer, seller) || bytes.Equal(buyer, arbiter) || bytes.Equal(seller, arbiter) {
+		return nil, fmt.Errorf("escrow parties must have different public keys")
+	}
+	script, err := multisig.NewScript(2, multisig.SortPubKeys([][]byte{buyer, seller, arbiter}), scriptType)
+	if err != nil {
+		return nil, err
+	}
+	return &Escrow{
+		Buyer:   buyer,
+		Seller:  seller,
+		Arbiter: arbiter,
+		Script:  script,
+		client:  client,
+	}, nil
+}
+
+// Address returns the address of the escrow, which the buyer funds.
+func (escrow *Escrow) Address() (btcutil.Address, error) {
+	return escrow.Script.Address(escrow.client.NetworkParams())
+}
+
+// Fund transfers the value from the account of the buyer to the escrow, and
+// returns the hash and the fee of the transaction.
+func (escrow *Escrow) Fund(ctx context.Context, account libbtc.Account, value int64, speed libbtc.TxExecutionSpeed) (string, int64, error) {
+	address, err := escrow.Address()
+	if err != nil {
+		return "", 0, err
+	}
+	return account.Transfer(ctx, address.EncodeAddress(), value, speed, false)
+}
+
+// Release builds the transaction paying the funds of the escrow, less the fee,
+// to the address of the seller. If utxos is nil, every unspent output of the
+// escrow is spent.
+func (escrow *Escrow) Release(ctx context.Context, fee int64, utxos []clients.UTXO) (libbtc.Tx, error) {
+	return escrow.spend(ctx, escrow.Seller, escrow.Buyer, -1, fee, utxos)
+}
+
+// Refund builds the transaction paying the funds of the escrow, less the fee,
+// back to the address of the buyer. If utxos is nil, every unspent output of
+// the escrow is spent.
+func (escrow *Escrow) Refund(ctx context.Context, fee int64, utxos []clients.UTXO) (libbtc.Tx, error) {
+	return escrow.spend(ctx, escrow.Buyer, escrow.Seller, -1, fee, utxos)
+}
+
+// Dispute builds the transaction splitting the funds of the escrow as decided
+// by the arbiter, who signs it with either party: the seller is paid
+// sellerValue, and the rest, less the fee, goes back to the buyer. If utxos is
+// nil, every unspent output of the escrow is spent.
+func (escrow *Escrow) Dispute(ctx context.Context, sellerValue, fee int64, utxos []clients.UTXO) (libbtc.Tx, error) {
+	if sellerValue < 0 {
+		return nil, fmt.Errorf("invalid seller value %d", sellerValue)
+	}
+	return escrow.spend(ctx, escrow.Seller, escrow.Buyer, sellerValue, fee, utxos)
+}
+
+// spend builds the transaction paying value to the address of the recipient,
+// and the rest, less the fee, to the address of the other party. Every fund of
+// the escrow goes to the recipient if value is negative.
+func (escrow *Escrow) spend(ctx context.Context, recipient, other []byte, value, fee int64, utxos []clients.UTXO) (libbtc.Tx, error) {
+	if utxos == nil {
+		address, err := escrow.Address()
+		if err != nil {
+			return nil, err
+		}
+		if utxos, err = escrow.client.GetUTXOs(ctx, address.EncodeAddress(), 999999, 0); err != nil {
+			return nil, err
+		}
+	}
+	if len(utxos) == 0 {
+		return nil, fmt.Errorf("escrow has no funds")
+	}
+
+	to, err := escrow.client.PublicKeyToAddress(recipient)
+	if err != nil {
+		return nil, err
+	}
+	change, err := escrow.client.PublicKeyToAddress(other)
+	if err != nil {
+		return nil, err
+	}
+	pubKey, err := btcec.ParsePubKey(recipient, btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+
+	// The fee of TxBuilders is paid from the payment, and the change goes to
+	// the other party.
+	if value < 0 {
+		value = 0
+		for _, utxo := range utxos {
+			value += utxo.Amount
+		}
+	} else {
+		value += fee
+	}
+	builder := libbtc.NewTxBuilder(escrow.client, libbtc.WithFee(fee), libbtc.WithChangeAddresser(libbtc.StaticChangeAddresser(change)))
+	return builder.BuildContracts(ctx, *pubKey.ToECDSA(), to.EncodeAddress(), value, nil, []libbtc.ContractSpend{{
+		Contract: escrow.Script.RedeemScript,
+		UTXOs:    utxos,
+	}})
+}
@@ -0,0 +1,240 @@
+package libbtc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// DefaultTxRate is the fee rate, in satoshis per byte, used by clients without
+// a fee source when the rate of the network cannot be fetched.
+const DefaultTxRate = 30
+
+// MaxFeeDeviation is the factor by which the rate of a source can differ from
+// the median of all sources before a FeeOracle discards it as an outlier.
+const MaxFeeDeviation = 2
+
+// DefaultMaxFeePercent is the percentage of the value transferred by a
+// transaction that its fee can reach, until another maximum is set. The value
+// transferred is the value received by the recipients, so sweeps spending more
+// than half of their value on fees are rejected.
+const DefaultMaxFeePercent = 100
+
+// FeeSource returns the fee rate, in satoshis per byte, for a transaction to
+// be mined at the given speed.
+type FeeSource interface {
+	TxRate(ctx context.Context, speed TxExecutionSpeed) (int64, error)
+}
+
+// FeeSourceFunc is a function implementing FeeSource.
+type FeeSourceFunc func(ctx context.Context, speed TxExecutionSpeed) (int64, error)
+
+func (f FeeSourceFunc) TxRate(ctx context.Context, speed TxExecutionSpeed) (int64, error) {
+	return f(ctx, speed)
+}
+
+// EarnFeeSource returns the rates suggested by bitcoinfees.earn.com, see
+// SuggestedTxRateWithClient.
+func EarnFeeSource(httpClient *http.Client) FeeSource {
+	return FeeSourceFunc(func(ctx context.Context, speed TxExecutionSpeed) (int64, error) {
+		return SuggestedTxRateWithClient(httpClient, speed)
+	})
+}
+
+// MempoolSpaceFeeSource returns the rates recommended by the mempool.space API
+// at the given URL, for example "https://mempool.space".
+func MempoolSpaceFeeSource(url string, httpClient *http.Client) FeeSource {
+	return FeeSourceFunc(func(ctx context.Context, speed TxExecutionSpeed) (int64, error) {
+		request, err := http.NewRequest("GET", strings.TrimSuffix(url, "/")+"/api/v1/fees/recommended", nil)
+		if err != nil {
+			return 0, err
+		}
+		res, err := httpClient.Do(request.WithContext(ctx))
+		if err != nil {
+			return 0, fmt.Errorf("cannot connect to %s = %v", url, err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return 0, fmt.Errorf("unexpected status code %v from %s", res.StatusCode, url)
+		}
+
+		data := struct {
+			Fastest  int64 `json:"fastestFee"`
+			HalfHour int64 `json:"halfHourFee"`
+			Hour     int64 `json:"hourFee"`
+		}{}
+		if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
+			return 0, err
+		}
+		switch speed {
+		case Slow:
+			return data.Hour, nil
+		case Standard:
+			return data.HalfHour, nil
+		case Fast:
+			return data.Fastest, nil
+		default:
+			return 0, fmt.Errorf("invalid speed tier: %v", speed)
+		}
+	})
+}
+
+// BackendFeeSource returns the rates estimated by the backend of the client,
+// such as the estimatesmartfee call of bitcoind. Fast transactions target the
+// next 2 blocks, standard ones 6 blocks and slow ones 24 blocks.
+func BackendFeeSource(client Client) FeeSource {
+	return FeeSourceFunc(func(ctx context.Context, speed TxExecutionSpeed) (int64, error) {
+		switch speed {
+		case Slow:
+			return client.EstimateFeeRate(ctx, 24)
+		case Standard:
+			return client.EstimateFeeRate(ctx, 6)
+		case Fast:
+			return client.EstimateFeeRate(ctx, 2)
+		default:
+			return 0, fmt.Errorf("invalid speed tier: %v", speed)
+		}
+	})
+}
+
+type cachedRate struct {
+	rate   int64
+	expiry time.Time
+}
+
+// feeOracle aggregates the rates of several sources.
+type feeOracle struct {
+	sources []FeeSource
+	floor   int64
+	window  time.Duration
+
+	mu    *sync.Mutex
+	rates map[TxExecutionSpeed]cachedRate
+}
+
+// NewFeeOracle returns a FeeSource querying every source concurrently. Rates
+// differing from their median by more than MaxFeeDeviation are discarded, and
+// the average of the others is cached for the window. The floor is returned
+// when every source fails, and is the lowest rate ever returned.
+func NewFeeOracle(floor int64, window time.Duration, sources ...FeeSource) FeeSource {
+	return &feeOracle{
+		sources: sources,
+		floor:   floor,
+		window:  window,
+		mu:      new(sync.Mutex),
+		rates:   map[TxExecutionSpeed]cachedRate{},
+	}
+}
+
+func (oracle *feeOracle) TxRate(ctx context.Context, speed TxExecutionSpeed) (int64, error) {
+	oracle.mu.Lock()
+	cached, ok := oracle.rates[speed]
+	oracle.mu.Unlock()
+	if ok && time.Now().Before(cached.expiry) {
+		return cached.rate, nil
+	}
+
+	results := make(chan int64, len(oracle.sources))
+	wg := new(sync.WaitGroup)
+	for _, source := range oracle.sources {
+		wg.Add(1)
+		go func(source FeeSource) {
+			defer wg.Done()
+			if rate, err := source.TxRate(ctx, speed); err == nil && rate > 0 {
+				results <- rate
+			}
+		}(source)
+	}
+	wg.Wait()
+	close(results)
+
+	rates := []int64{}
+	for rate := range results {
+		rates = append(rates, rate)
+	}
+	if len(rates) == 0 {
+		return oracle.floor, nil
+	}
+
+	rate := aggregateRates(rates)
+	if rate < oracle.floor {
+		rate = oracle.floor
+	}
+	oracle.mu.Lock()
+	oracle.rates[speed] = cachedRate{rate, time.Now().Add(oracle.window)}
+	oracle.mu.Unlock()
+	return rate, nil
+}
+
+// aggregateRates returns the average of the rates that are within
+// MaxFeeDeviation of their median, rounded up.
+func aggregateRates(rates []int64) int64 {
+	sort.Slice(rates, func(i, j int) bool { return rates[i] < rates[j] })
+	median := rates[len(rates)/2]
+	if len(rates)%2 == 0 {
+		median = (rates[len(rates)/2-1] + median + 1) / 2
+	}
+
+	var sum, count int64
+	for _, rate := range rates {
+		if rate*MaxFeeDeviation < median || rate > median*MaxFeeDeviation {
+			continue
+		}
+		sum += rate
+		count++
+	}
+	return (sum + count - 1) / count
+}
+
+// checkFee returns an AbsurdFeeError if the fee exceeds maxPercent percent of
+// the value. A maximum of zero disables the check.
+func checkFee(fee, value int64, maxPercent float64) error {
+	if maxPercent <= 0 || float64(fee)*100 <= maxPercent*float64(value) {
+		return nil
+	}
+	return &errors.AbsurdFeeError{Fee: fee, Value: value, MaxFeePercent: maxPercent}
+}
+
+// msgTxFee returns the fee of the transaction spending outputs of the given
+// total value.
+func msgTxFee(msgTx *wire.MsgTx, inputValue int64) int64 {
+	fee := inputValue
+	for _, txOut := range msgTx.TxOut {
+		fee -= txOut.Value
+	}
+	return fee
+}
+
+func (client *client) EstimateFeeRate(ctx context.Context, blocks int64) (int64, error) {
+	estimator, ok := client.ClientCore.(clients.FeeEstimator)
+	if !ok {
+		return 0, errors.NewErrUnsupportedOperation("EstimateFeeRate", "current")
+	}
+	return estimator.EstimateFeeRate(ctx, blocks)
+}
+
+// SetFeeSource sets the source of the fee rates of the transactions built
+// with the client.
+func (client *client) SetFeeSource(source FeeSource) {
+	client.fees = source
+}
+
+func (client *client) TxRate(ctx context.Context, speed TxExecutionSpeed) (int64, error) {
+	if client.fees != nil {
+		return client.fees.TxRate(ctx, speed)
+	}
+	rate, err := NetworkTxRate(client.NetworkParams(), speed)
+	if err != nil {
+		return DefaultTxRate, nil
+	}
+	return rate, nil
+}
@@ -0,0 +1,119 @@
+package libbtc
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/tyler-smith/go-bip32"
+)
+
+// BIP44 chains of an account.
+const (
+	ExternalChain = uint32(0)
+	InternalChain = uint32(1)
+)
+
+// HDAccount is an account backed by a BIP44 account-level extended key. It
+// spends from every address derived on its external (receiving) and internal
+// (change) chains, and sends change to a fresh internal address on every
+// transaction.
+type HDAccount interface {
+	WatchOnlyAccount
+
+	// Transfer bitcoins to the given address, spending from every address
+	// derived so far.
+	Transfer(ctx context.Context, to string, value int64, speed TxExecutionSpeed) (string, int64, error)
+
+	// BuildTransfer builds and signs a transfer without publishing it.
+	BuildTransfer(ctx context.Context, to string, value int64, speed TxExecutionSpeed) (string, []byte, error)
+}
+
+type hdAccount struct {
+	*watchOnlyAccount
+}
+
+// NewHDAccount returns an HDAccount for the BIP44 account-level key, for
+// example the key at m/44'/0'/0'. external and internal are the number of
+// addresses already used on each chain, which are spent from.
+func NewHDAccount(client Client, key *bip32.Key, external, internal uint32, logger Logger) HDAccount {
+	return &hdAccount{newWatchOnlyAccount(client, key, nil, external, internal, logger)}
+}
+
+func (account *hdAccount) Transfer(ctx context.Context, to string, value int64, speed TxExecutionSpeed) (string, int64, error) {
+	msgTx, fee, err := account.buildTransfer(ctx, to, value, speed)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := account.PublishTransaction(ctx, msgTx); err != nil {
+		return "", 0, err
+	}
+	account.logger.Infof("successfully submitted the tx %s", msgTx.TxHash())
+	return msgTx.TxHash().String(), fee, nil
+}
+
+func (account *hdAccount) BuildTransfer(ctx context.Context, to string, value int64, speed TxExecutionSpeed) (string, []byte, error) {
+	msgTx, _, err := account.buildTransfer(ctx, to, value, speed)
+	if err != nil {
+		return "", nil, err
+	}
+	var stxBuffer bytes.Buffer
+	stxBuffer.Grow(msgTx.SerializeSize())
+	if err := msgTx.Serialize(&stxBuffer); err != nil {
+		return "", nil, err
+	}
+	return msgTx.TxHash().String(), stxBuffer.Bytes(), nil
+}
+
+func (account *hdAccount) buildTransfer(ctx context.Context, to string, value int64, speed TxExecutionSpeed) (*wire.MsgTx, int64, error) {
+	transfer, err := account.BuildUnsignedTransfer(ctx, to, value, speed)
+	if err != nil {
+		return nil, 0, err
+	}
+	msgTx := transfer.Tx
+
+	sigHashes := txscript.NewTxSigHashes(msgTx)
+	for i, input := range transfer.Inputs {
+		key, err := account.childKey(input.Chain, input.Index)
+		if err != nil {
+			return nil, 0, err
+		}
+		privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), key.Key)
+		program := input.witnessProgram()
+		if program == nil {
+			sigScript, err := txscript.SignatureScript(msgTx, i, input.ScriptPubKey, txscript.SigHashAll, privKey, true)
+			if err != nil {
+				return nil, 0, err
+			}
+			msgTx.TxIn[i].SignatureScript = sigScript
+			continue
+		}
+		witness, err := txscript.WitnessSignature(msgTx, sigHashes, i, input.Amount, program, txscript.SigHashAll, privKey, true)
+		if err != nil {
+			return nil, 0, err
+		}
+		msgTx.TxIn[i].Witness = witness
+		if input.RedeemScript != nil {
+			// P2SH-P2WPKH inputs push the witness program.
+			sigScript, err := txscript.NewScriptBuilder().AddData(input.RedeemScript).Script()
+			if err != nil {
+				return nil, 0, err
+			}
+			msgTx.TxIn[i].SignatureScript = sigScript
+		}
+	}
+	for i, input := range transfer.Inputs {
+		engine, err := txscript.NewEngine(input.ScriptPubKey, msgTx, i,
+			txscript.StandardVerifyFlags, txscript.NewSigCache(10),
+			txscript.NewTxSigHashes(msgTx), input.Amount)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := engine.Execute(); err != nil {
+			return nil, 0, err
+		}
+	}
+	return msgTx, transfer.Fee, nil
+}
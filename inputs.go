@@ -0,0 +1,125 @@
+package libbtc
+
+import (
+	"context"
+	"encoding/hex"
+	"math"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/clients"
+)
+
+// ResolvedInput is a transaction input annotated with the output it spends.
+type ResolvedInput struct {
+	Index            int
+	PreviousOutPoint wire.OutPoint
+	Amount           int64
+	ScriptPubKey     []byte
+	ScriptClass      txscript.ScriptClass
+
+	// Address is the address owning the spent output, it is nil for coinbase
+	// inputs and non-standard scripts.
+	Address btcutil.Address
+}
+
+// ResolvedInputs are the resolved inputs of a transaction.
+type ResolvedInputs []ResolvedInput
+
+// Value returns the total value spent by the inputs.
+func (inputs ResolvedInputs) Value() int64 {
+	var value int64
+	for _, input := range inputs {
+		value += input.Amount
+	}
+	return value
+}
+
+// Fee returns the fee paid by the given transaction, assuming that the inputs
+// are its resolved inputs.
+func (inputs ResolvedInputs) Fee(msgTx *wire.MsgTx) int64 {
+	fee := inputs.Value()
+	for _, txOut := range msgTx.TxOut {
+		fee -= txOut.Value
+	}
+	return fee
+}
+
+func (client *client) ResolveInputs(ctx context.Context, msgTx *wire.MsgTx) (ResolvedInputs, error) {
+	utxos, err := client.batchUTXOs(ctx, msgTx)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := make(ResolvedInputs, len(msgTx.TxIn))
+	for i, txIn := range msgTx.TxIn {
+		inputs[i] = ResolvedInput{
+			Index:            i,
+			PreviousOutPoint: txIn.PreviousOutPoint,
+			ScriptClass:      txscript.NonStandardTy,
+		}
+		if isCoinbaseOutPoint(txIn.PreviousOutPoint) {
+			continue
+		}
+
+		utxo, ok := utxos[txIn.PreviousOutPoint]
+		if !ok {
+			var err error
+			utxo, err = client.GetUTXO(ctx, txIn.PreviousOutPoint.Hash.String(), txIn.PreviousOutPoint.Index)
+			if err != nil {
+				return nil, err
+			}
+		}
+		scriptPubKey, err := hex.DecodeString(utxo.ScriptPubKey)
+		if err != nil {
+			return nil, err
+		}
+		inputs[i].Amount = utxo.Amount
+		inputs[i].ScriptPubKey = scriptPubKey
+
+		class, addrs, _, err := txscript.ExtractPkScriptAddrs(scriptPubKey, client.NetworkParams())
+		if err != nil {
+			continue
+		}
+		inputs[i].ScriptClass = class
+		if len(addrs) == 1 {
+			inputs[i].Address = addrs[0]
+		}
+	}
+	return inputs, nil
+}
+
+// batchUTXOs fetches the outputs spent by the transaction in a single round
+// trip when the backend supports it. Otherwise, it returns no outputs and they
+// are fetched one at a time.
+func (client *client) batchUTXOs(ctx context.Context, msgTx *wire.MsgTx) (map[wire.OutPoint]clients.UTXO, error) {
+	utxos := map[wire.OutPoint]clients.UTXO{}
+	batcher, ok := client.ClientCore.(clients.UTXOBatcher)
+	if !ok {
+		return utxos, nil
+	}
+
+	outPoints := []wire.OutPoint{}
+	for _, txIn := range msgTx.TxIn {
+		if !isCoinbaseOutPoint(txIn.PreviousOutPoint) {
+			outPoints = append(outPoints, txIn.PreviousOutPoint)
+		}
+	}
+	if len(outPoints) == 0 {
+		return utxos, nil
+	}
+	batch, err := batcher.GetUTXOBatch(ctx, outPoints)
+	if err != nil {
+		return nil, err
+	}
+	for i, utxo := range batch {
+		utxos[outPoints[i]] = utxo
+	}
+	return utxos, nil
+}
+
+func isCoinbaseOutPoint(outPoint wire.OutPoint) bool {
+	return outPoint.Index == math.MaxUint32 && outPoint.Hash == (chainhash.Hash{})
+}
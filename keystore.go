@@ -0,0 +1,33 @@
+package libbtc
+
+import (
+	"fmt"
+
+	"github.com/renproject/libbtc-go/keystore"
+)
+
+// NewAccountFromKeystore returns an Account for the private key of the
+// encrypted keystore file at path, saved with keystore.SavePrivateKey.
+func NewAccountFromKeystore(client Client, path, passphrase string, logger Logger) (Account, error) {
+	key, err := keystore.Load(path, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if key.Kind != keystore.KindPrivateKey {
+		return nil, fmt.Errorf("keystore %s holds a %s, not a private key", path, key.Kind)
+	}
+	return NewAccount(client, NewPrivateKeySigner(key.PrivateKey), logger), nil
+}
+
+// NewWalletFromKeystore returns a Wallet for the mnemonic of the encrypted
+// keystore file at path, saved with keystore.SaveMnemonic.
+func NewWalletFromKeystore(client Client, path, passphrase string, logger Logger) (Wallet, error) {
+	key, err := keystore.Load(path, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if key.Kind != keystore.KindMnemonic {
+		return nil, fmt.Errorf("keystore %s holds a %s, not a mnemonic", path, key.Kind)
+	}
+	return NewWallet(key.Mnemonic, client, logger), nil
+}
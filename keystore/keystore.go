@@ -0,0 +1,228 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/tyler-smith/go-bip39"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Version is the version of the keystore file format.
+const Version = 1
+
+// The kinds of secrets a keystore file holds.
+const (
+	KindPrivateKey = "privateKey"
+	KindMnemonic   = "mnemonic"
+)
+
+// The scrypt parameters of new keystore files. ScryptN can be lowered, for
+// example in tests, at the cost of making passphrases easier to brute force.
+var (
+	ScryptN = 1 << 18
+	ScryptR = 8
+	ScryptP = 1
+)
+
+const (
+	keyLen  = 32
+	saltLen = 32
+)
+
+// ErrWrongPassphrase is returned when a keystore file cannot be decrypted with
+// the passphrase, or has been modified.
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted keystore")
+
+// Key is the secret of a keystore file, either a private key or a mnemonic.
+type Key struct {
+	Kind       string
+	PrivateKey *ecdsa.PrivateKey
+	Mnemonic   string
+}
+
+type file struct {
+	Version int        `json:"version"`
+	Kind    string     `json:"kind"`
+	Crypto  cryptoJSON `json:"crypto"`
+}
+
+type cryptoJSON struct {
+	Cipher     string     `json:"cipher"`
+	CipherText string     `json:"ciphertext"`
+	Nonce      string     `json:"nonce"`
+	KDF        string     `json:"kdf"`
+	KDFParams  scryptJSON `json:"kdfparams"`
+}
+
+type scryptJSON struct {
+	N      int    `json:"n"`
+	R      int    `json:"r"`
+	P      int    `json:"p"`
+	KeyLen int    `json:"dklen"`
+	Salt   string `json:"salt"`
+}
+
+// SavePrivateKey encrypts the private key with the passphrase and writes it to
+// a keystore file at path, readable only by its owner.
+func SavePrivateKey(path string, key *ecdsa.PrivateKey, passphrase string) error {
+	return save(path, KindPrivateKey, (*btcec.PrivateKey)(key).Serialize(), passphrase)
+}
+
+// SaveMnemonic encrypts the BIP39 mnemonic with the passphrase and writes it to
+// a keystore file at path, readable only by its owner.
+func SaveMnemonic(path, mnemonic, passphrase string) error {
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return fmt.Errorf("invalid mnemonic")
+	}
+	return save(path, KindMnemonic, []byte(mnemonic), passphrase)
+}
+
+// Load decrypts the keystore file at path with the passphrase.
+func Load(path, passphrase string) (Key, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Key{}, err
+	}
+	f := file{}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Key{}, fmt.Errorf("cannot decode keystore %s: %v", path, err)
+	}
+	if f.Version != Version {
+		return Key{}, fmt.Errorf("unsupported keystore version %d", f.Version)
+	}
+	secret, err := decrypt(f, passphrase)
+	if err != nil {
+		return Key{}, err
+	}
+
+	switch f.Kind {
+	case KindPrivateKey:
+		privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), secret)
+		return Key{Kind: f.Kind, PrivateKey: privKey.ToECDSA()}, nil
+	case KindMnemonic:
+		return Key{Kind: f.Kind, Mnemonic: string(secret)}, nil
+	default:
+		return Key{}, fmt.Errorf("unknown keystore kind %q", f.Kind)
+	}
+}
+
+func save(path, kind string, secret []byte, passphrase string) error {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	params := scryptJSON{
+		N:      ScryptN,
+		R:      ScryptR,
+		P:      ScryptP,
+		KeyLen: keyLen,
+		Salt:   hex.EncodeToString(salt),
+	}
+	aead, err := newAEAD(params, passphrase)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	// The kind is authenticated, so that a private key cannot be loaded as a
+	// mnemonic or the other way round.
+	cipherText := aead.Seal(nil, nonce, secret, []byte(kind))
+	data, err := json.MarshalIndent(file{
+		Version: Version,
+		Kind:    kind,
+		Crypto: cryptoJSON{
+			Cipher:     "aes-256-gcm",
+			CipherText: hex.EncodeToString(cipherText),
+			Nonce:      hex.EncodeToString(nonce),
+			KDF:        "scrypt",
+			KDFParams:  params,
+		},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, data)
+}
+
+func decrypt(f file, passphrase string) ([]byte, error) {
+	if f.Crypto.Cipher != "aes-256-gcm" {
+		return nil, fmt.Errorf("unsupported keystore cipher %q", f.Crypto.Cipher)
+	}
+	if f.Crypto.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported keystore kdf %q", f.Crypto.KDF)
+	}
+	aead, err := newAEAD(f.Crypto.KDFParams, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(f.Crypto.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode keystore nonce: %v", err)
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid keystore nonce length %d", len(nonce))
+	}
+	cipherText, err := hex.DecodeString(f.Crypto.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode keystore ciphertext: %v", err)
+	}
+	secret, err := aead.Open(nil, nonce, cipherText, []byte(f.Kind))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return secret, nil
+}
+
+// newAEAD derives the AES-256 key from the passphrase.
+func newAEAD(params scryptJSON, passphrase string) (cipher.AEAD, error) {
+	if params.KeyLen != keyLen {
+		return nil, fmt.Errorf("unsupported keystore key length %d", params.KeyLen)
+	}
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode keystore salt: %v", err)
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, params.KeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeFile writes the data to a temporary file in the directory of path and
+// renames it, so that an existing keystore is never left half written.
+func writeFile(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	// TempFile creates the file with the 0600 permissions.
+	return os.Rename(tmp.Name(), path)
+}
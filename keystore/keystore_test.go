@@ -0,0 +1,88 @@
+package keystore_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go/keystore"
+
+	"github.com/btcsuite/btcd/btcec"
+)
+
+var _ = Describe("Keystore", func() {
+	const (
+		mnemonic   = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+		passphrase = "correct horse battery staple"
+	)
+
+	var dir string
+
+	BeforeEach(func() {
+		// Keystores are encrypted with cheap scrypt parameters, so that the
+		// tests run quickly.
+		ScryptN = 1 << 10
+		var err error
+		dir, err = ioutil.TempDir("", "keystore")
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).Should(Succeed())
+	})
+
+	It("should round-trip private keys", func() {
+		key, err := btcec.NewPrivateKey(btcec.S256())
+		Expect(err).ShouldNot(HaveOccurred())
+		path := filepath.Join(dir, "key.json")
+		Expect(SavePrivateKey(path, key.ToECDSA(), passphrase)).Should(Succeed())
+
+		info, err := os.Stat(path)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(info.Mode().Perm()).Should(Equal(os.FileMode(0600)))
+
+		loaded, err := Load(path, passphrase)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(loaded.Kind).Should(Equal(KindPrivateKey))
+		Expect(loaded.PrivateKey.D).Should(Equal(key.D))
+	})
+
+	It("should round-trip mnemonics", func() {
+		path := filepath.Join(dir, "mnemonic.json")
+		Expect(SaveMnemonic(path, mnemonic, passphrase)).Should(Succeed())
+		loaded, err := Load(path, passphrase)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(loaded.Kind).Should(Equal(KindMnemonic))
+		Expect(loaded.Mnemonic).Should(Equal(mnemonic))
+	})
+
+	It("should reject invalid mnemonics", func() {
+		Expect(SaveMnemonic(filepath.Join(dir, "mnemonic.json"), "abandon abandon", passphrase)).ShouldNot(Succeed())
+	})
+
+	It("should reject the wrong passphrase", func() {
+		path := filepath.Join(dir, "mnemonic.json")
+		Expect(SaveMnemonic(path, mnemonic, passphrase)).Should(Succeed())
+		_, err := Load(path, "wrong passphrase")
+		Expect(err).Should(Equal(ErrWrongPassphrase))
+	})
+
+	It("should reject keystores whose kind was modified", func() {
+		path := filepath.Join(dir, "mnemonic.json")
+		Expect(SaveMnemonic(path, mnemonic, passphrase)).Should(Succeed())
+		data, err := ioutil.ReadFile(path)
+		Expect(err).ShouldNot(HaveOccurred())
+		f := map[string]interface{}{}
+		Expect(json.Unmarshal(data, &f)).Should(Succeed())
+		f["kind"] = KindPrivateKey
+		data, err = json.Marshal(f)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ioutil.WriteFile(path, data, 0600)).Should(Succeed())
+
+		_, err = Load(path, passphrase)
+		Expect(err).Should(Equal(ErrWrongPassphrase))
+	})
+})
@@ -5,9 +5,29 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/clients/mock"
 )
 
 func TestLibbtc(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Libbtc Suite")
 }
+
+// newMockAccount returns an account of a new key on a new mock chain.
+func newMockAccount() (*mock.Chain, Account) {
+	chain := mock.NewChain(&chaincfg.RegressionNetParams)
+	return chain, newAccount(chain)
+}
+
+// newAccount returns an account of a new key on the chain, usually a mock
+// chain wrapped to change some of its behaviour.
+func newAccount(core clients.ClientCore) Account {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	Expect(err).ShouldNot(HaveOccurred())
+	return NewAccount(NewClientFromCore(core), NewPrivateKeySigner(key.ToECDSA()), nil)
+}
@@ -70,12 +70,12 @@ var _ = Describe("LibBTC", func() {
 		if err != nil {
 			panic(err)
 		}
-		mainAccount := NewAccount(client, mainKey, nil)
+		mainAccount := NewAccount(client, NewPrivateKeySigner(mainKey), nil)
 		secKey, err := loadKey(44, 1, 1, 0, 0) // "m/44'/1'/1'/0/0"
 		if err != nil {
 			panic(err)
 		}
-		secondaryAccount := NewAccount(client, secKey, nil)
+		secondaryAccount := NewAccount(client, NewPrivateKeySigner(secKey), nil)
 		return mainAccount, secondaryAccount
 	}
 
@@ -0,0 +1,16 @@
+package libbtc
+
+import (
+	"github.com/renproject/libbtc-go/clients"
+)
+
+// Logger logs the progress of clients, accounts and builders. A
+// logrus.FieldLogger is a Logger, and clients.NopLogger discards everything.
+type Logger = clients.Logger
+
+// SetLogger sets the logger of the backend of the client, if it logs.
+func (client *client) SetLogger(logger Logger) {
+	if setter, ok := client.ClientCore.(clients.LoggerSetter); ok {
+		setter.SetLogger(logger)
+	}
+}
@@ -0,0 +1,126 @@
+package libbtc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/libbtc-go/clients"
+)
+
+// MerkleProof proves that a transaction is included in a block.
+type MerkleProof struct {
+	TxHash    chainhash.Hash
+	BlockHash chainhash.Hash
+	clients.MerkleBranch
+}
+
+// Verify checks that the proof links the transaction to the merkle root of the
+// given header, which should come from a trusted source of headers.
+func (proof MerkleProof) Verify(header *wire.BlockHeader) error {
+	if hash := header.BlockHash(); hash != proof.BlockHash {
+		return fmt.Errorf("proof is for block %s, got header of block %s", proof.BlockHash, hash)
+	}
+	if len(proof.Hashes) < 32 && proof.Index>>uint(len(proof.Hashes)) != 0 {
+		return fmt.Errorf("index %d is out of range of a merkle branch of %d hashes", proof.Index, len(proof.Hashes))
+	}
+
+	hash := proof.TxHash
+	index := proof.Index
+	for _, sibling := range proof.Hashes {
+		if index&1 == 0 {
+			hash = merkleParent(hash, sibling)
+		} else {
+			hash = merkleParent(sibling, hash)
+		}
+		index >>= 1
+	}
+	if hash != header.MerkleRoot {
+		return fmt.Errorf("proof of %s computes merkle root %s, expected %s", proof.TxHash, hash, header.MerkleRoot)
+	}
+	return nil
+}
+
+func (client *client) GetTxMerkleProof(ctx context.Context, txHash string) (MerkleProof, error) {
+	hash, err := chainhash.NewHashFromStr(txHash)
+	if err != nil {
+		return MerkleProof{}, err
+	}
+	conf, err := client.Confirmations(ctx, txHash)
+	if err != nil {
+		return MerkleProof{}, err
+	}
+	if conf <= 0 {
+		return MerkleProof{}, fmt.Errorf("transaction %s is not confirmed", txHash)
+	}
+	tip, err := client.ChainTip(ctx)
+	if err != nil {
+		return MerkleProof{}, err
+	}
+	height := tip.Height - conf + 1
+
+	proof := MerkleProof{TxHash: *hash}
+	if prover, ok := client.ClientCore.(clients.MerkleProver); ok {
+		if proof.MerkleBranch, err = prover.MerkleBranch(ctx, txHash, height); err != nil {
+			return MerkleProof{}, err
+		}
+		header, err := client.GetBlockHeader(ctx, strconv.FormatInt(proof.BlockHeight, 10))
+		if err != nil {
+			return MerkleProof{}, err
+		}
+		proof.BlockHash = header.BlockHash()
+		return proof, nil
+	}
+
+	// Otherwise, the branch is computed from the transactions of the block.
+	block, err := client.GetBlock(ctx, strconv.FormatInt(height, 10))
+	if err != nil {
+		return MerkleProof{}, err
+	}
+	txHashes := make([]chainhash.Hash, len(block.Transactions))
+	index := -1
+	for i, tx := range block.Transactions {
+		txHashes[i] = tx.TxHash()
+		if txHashes[i] == *hash {
+			index = i
+		}
+	}
+	if index < 0 {
+		return MerkleProof{}, fmt.Errorf("transaction %s is not in block %s at height %d", txHash, block.BlockHash(), height)
+	}
+	proof.BlockHash = block.BlockHash()
+	proof.MerkleBranch = clients.MerkleBranch{
+		BlockHeight: height,
+		Index:       uint32(index),
+		Hashes:      merkleBranch(txHashes, index),
+	}
+	return proof, nil
+}
+
+// merkleBranch returns the siblings of the hash at the given index and of its
+// ancestors in the merkle tree of the hashes. The last hash of a level with an
+// odd number of hashes is paired with itself.
+func merkleBranch(hashes []chainhash.Hash, index int) []chainhash.Hash {
+	branch := []chainhash.Hash{}
+	level := hashes
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		branch = append(branch, level[index^1])
+
+		parents := make([]chainhash.Hash, len(level)/2)
+		for i := range parents {
+			parents[i] = merkleParent(level[2*i], level[2*i+1])
+		}
+		level = parents
+		index /= 2
+	}
+	return branch
+}
+
+func merkleParent(left, right chainhash.Hash) chainhash.Hash {
+	return chainhash.DoubleHashH(append(left[:], right[:]...))
+}
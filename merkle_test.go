@@ -0,0 +1,160 @@
+package libbtc_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/clients/mock"
+)
+
+var _ = Describe("MerkleProof", func() {
+	// newBlock returns the header of a block of n transactions, with their
+	// proofs built from the merkle tree of btcd.
+	newBlock := func(n int) (*wire.BlockHeader, []MerkleProof) {
+		txs := make([]*btcutil.Tx, n)
+		for i := range txs {
+			msgTx := wire.NewMsgTx(2)
+			msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, uint32(i)), nil, nil))
+			msgTx.AddTxOut(wire.NewTxOut(int64(i), nil))
+			txs[i] = btcutil.NewTx(msgTx)
+		}
+		store := blockchain.BuildMerkleTreeStore(txs, false)
+		header := wire.NewBlockHeader(2, &chainhash.Hash{}, store[len(store)-1], 0, 0)
+
+		proofs := make([]MerkleProof, n)
+		for i := range proofs {
+			proofs[i] = MerkleProof{
+				TxHash:       *txs[i].Hash(),
+				BlockHash:    header.BlockHash(),
+				MerkleBranch: clients.MerkleBranch{Index: uint32(i), Hashes: []chainhash.Hash{}},
+			}
+			// The store holds each level of the tree after the previous one,
+			// without the hashes paired with themselves.
+			offset, index := 0, i
+			for width := (len(store) + 1) / 2; width > 1; width /= 2 {
+				sibling := store[offset+(index^1)]
+				if sibling == nil {
+					sibling = store[offset+index]
+				}
+				proofs[i].Hashes = append(proofs[i].Hashes, *sibling)
+				offset += width
+				index /= 2
+			}
+		}
+		return header, proofs
+	}
+
+	It("should verify the proofs of every transaction of blocks of any size", func() {
+		for n := 1; n <= 9; n++ {
+			header, proofs := newBlock(n)
+			for _, proof := range proofs {
+				Expect(proof.Verify(header)).Should(Succeed())
+			}
+		}
+	})
+
+	It("should reject proofs that do not match the header", func() {
+		header, proofs := newBlock(5)
+		proof := proofs[2]
+
+		wrongIndex := proof
+		wrongIndex.Index ^= 1
+		Expect(wrongIndex.Verify(header)).ShouldNot(Succeed())
+
+		outOfRange := proof
+		outOfRange.Index += 1 << uint(len(proof.Hashes))
+		Expect(outOfRange.Verify(header)).ShouldNot(Succeed())
+
+		wrongTx := proof
+		wrongTx.TxHash = proofs[3].TxHash
+		Expect(wrongTx.Verify(header)).ShouldNot(Succeed())
+
+		otherHeader, _ := newBlock(4)
+		Expect(proof.Verify(otherHeader)).ShouldNot(Succeed())
+	})
+})
+
+// provingChain is a mock chain returning the merkle branches of transactions,
+// and recording the heights it was given.
+type provingChain struct {
+	*mock.Chain
+	heights *[]int64
+}
+
+func (chain provingChain) MerkleBranch(ctx context.Context, txHash string, blockHeight int64) (clients.MerkleBranch, error) {
+	*chain.heights = append(*chain.heights, blockHeight)
+	proof, err := NewClientFromCore(chain.Chain).GetTxMerkleProof(ctx, txHash)
+	if err != nil {
+		return clients.MerkleBranch{}, err
+	}
+	return proof.MerkleBranch, nil
+}
+
+var _ = Describe("GetTxMerkleProof", func() {
+	// fund returns the hashes of n transactions confirmed in the same block,
+	// after a few empty blocks.
+	fund := func(chain *mock.Chain, n int) []string {
+		address, err := btcutil.NewAddressPubKeyHash(make([]byte, 20), chain.NetworkParams())
+		Expect(err).ShouldNot(HaveOccurred())
+		chain.Mine(3)
+		txHashes := make([]string, n)
+		for i := range txHashes {
+			txHashes[i], err = chain.Fund(address.EncodeAddress(), int64(1000*(i+1)))
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+		chain.Mine(2)
+		return txHashes
+	}
+
+	It("should prove the transactions of a block from its transactions", func() {
+		chain := mock.NewChain(&chaincfg.RegressionNetParams)
+		client := NewClientFromCore(chain)
+		txHashes := fund(chain, 5)
+		header, err := client.GetBlockHeader(context.Background(), "4")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		for _, txHash := range txHashes {
+			proof, err := client.GetTxMerkleProof(context.Background(), txHash)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(proof.TxHash.String()).Should(Equal(txHash))
+			Expect(proof.BlockHash).Should(Equal(header.BlockHash()))
+			Expect(proof.BlockHeight).Should(Equal(int64(4)))
+			Expect(proof.Verify(header)).Should(Succeed())
+		}
+	})
+
+	It("should prove transactions with the branches of the backend", func() {
+		chain := mock.NewChain(&chaincfg.RegressionNetParams)
+		heights := []int64{}
+		client := NewClientFromCore(provingChain{chain, &heights})
+		txHashes := fund(chain, 3)
+		header, err := client.GetBlockHeader(context.Background(), "4")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		proof, err := client.GetTxMerkleProof(context.Background(), txHashes[1])
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(heights).Should(Equal([]int64{4}))
+		Expect(proof.BlockHash).Should(Equal(header.BlockHash()))
+		Expect(proof.Verify(header)).Should(Succeed())
+	})
+
+	It("should not prove unconfirmed transactions", func() {
+		chain := mock.NewChain(&chaincfg.RegressionNetParams)
+		address, err := btcutil.NewAddressPubKeyHash(make([]byte, 20), chain.NetworkParams())
+		Expect(err).ShouldNot(HaveOccurred())
+		txHash, err := chain.Fund(address.EncodeAddress(), 1000)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		_, err = NewClientFromCore(chain).GetTxMerkleProof(context.Background(), txHash)
+		Expect(err).Should(HaveOccurred())
+	})
+})
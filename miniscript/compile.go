@@ -0,0 +1,354 @@
+package miniscript
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// fragment is the compiled script of a policy expression. Every fragment
+// script ends with an opcode, so that it can be turned into its verify form by
+// changing or appending its last opcode.
+type fragment struct {
+	script []byte
+
+	// unit is whether the fragment leaves exactly 1 on the stack when
+	// satisfied, rather than any non-zero value.
+	unit bool
+
+	// satisfy returns the stack satisfying the fragment, bottom first, or
+	// false if the satisfier lacks what it needs.
+	satisfy func(satisfier Satisfier) ([][]byte, bool)
+}
+
+func compile(n *node) (*fragment, error) {
+	switch n.name {
+	case "pk", "pkh":
+		if len(n.args) != 1 || len(n.subs) != 0 {
+			return nil, fmt.Errorf("%s expects a public key", n.name)
+		}
+		return compileKey(n.name, n.args[0])
+	case "after", "older":
+		if len(n.args) != 1 || len(n.subs) != 0 {
+			return nil, fmt.Errorf("%s expects a lock time", n.name)
+		}
+		return compileTimeLock(n.name, n.args[0])
+	case "sha256", "hash256", "ripemd160", "hash160":
+		if len(n.args) != 1 || len(n.subs) != 0 {
+			return nil, fmt.Errorf("%s expects a hash", n.name)
+		}
+		return compileHashLock(n.name, n.args[0])
+	case "and", "or":
+		if len(n.args) != 0 || len(n.subs) != 2 {
+			return nil, fmt.Errorf("%s expects two policies", n.name)
+		}
+		x, err := compile(n.subs[0])
+		if err != nil {
+			return nil, err
+		}
+		y, err := compile(n.subs[1])
+		if err != nil {
+			return nil, err
+		}
+		if n.name == "and" {
+			return compileAnd(x, y), nil
+		}
+		return compileOr(x, y), nil
+	case "thresh":
+		if len(n.args) != 1 || len(n.subs) == 0 {
+			return nil, fmt.Errorf("thresh expects a threshold and policies")
+		}
+		k, err := strconv.Atoi(n.args[0])
+		if err != nil || k < 1 || k > len(n.subs) {
+			return nil, fmt.Errorf("invalid threshold %q for %d policies", n.args[0], len(n.subs))
+		}
+		subs := make([]*fragment, len(n.subs))
+		for i := range n.subs {
+			if subs[i], err = compile(n.subs[i]); err != nil {
+				return nil, err
+			}
+		}
+		return compileThresh(k, subs)
+	default:
+		return nil, fmt.Errorf("unknown policy %q", n.name)
+	}
+}
+
+// compileKey compiles pk(K) to <K> OP_CHECKSIG, and pkh(K) to OP_DUP
+// OP_HASH160 <HASH160(K)> OP_EQUALVERIFY OP_CHECKSIG, which expects the public
+// key along with the signature.
+func compileKey(name, arg string) (*fragment, error) {
+	pubKey, err := hex.DecodeString(arg)
+	if err != nil || len(pubKey) != btcec.PubKeyBytesLenCompressed {
+		return nil, fmt.Errorf("invalid public key %q: expected a hex compressed public key", arg)
+	}
+	if _, err := btcec.ParsePubKey(pubKey, btcec.S256()); err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %v", arg, err)
+	}
+
+	builder := txscript.NewScriptBuilder()
+	if name == "pkh" {
+		builder.AddOp(txscript.OP_DUP).AddOp(txscript.OP_HASH160).
+			AddData(btcutil.Hash160(pubKey)).AddOp(txscript.OP_EQUALVERIFY)
+	} else {
+		builder.AddData(pubKey)
+	}
+	script, err := builder.AddOp(txscript.OP_CHECKSIG).Script()
+	if err != nil {
+		return nil, err
+	}
+	return &fragment{
+		script: script,
+		unit:   true,
+		satisfy: func(satisfier Satisfier) ([][]byte, bool) {
+			sig, ok := satisfier.Signatures[hex.EncodeToString(pubKey)]
+			if !ok {
+				return nil, false
+			}
+			if name == "pkh" {
+				return [][]byte{sig, pubKey}, true
+			}
+			return [][]byte{sig}, true
+		},
+	}, nil
+}
+
+// compileTimeLock compiles after(n) to <n> OP_CHECKLOCKTIMEVERIFY, and
+// older(n) to <n> OP_CHECKSEQUENCEVERIFY, where n is a sequence number as
+// returned by libbtc.RelativeLockTimeBlocks or RelativeLockTimeSeconds.
+func compileTimeLock(name, arg string) (*fragment, error) {
+	lockTime, err := strconv.ParseUint(arg, 10, 32)
+	if err != nil || lockTime < 1 || lockTime >= 1<<31 {
+		return nil, fmt.Errorf("invalid %s lock time %q", name, arg)
+	}
+
+	op := byte(txscript.OP_CHECKLOCKTIMEVERIFY)
+	satisfied := func(satisfier Satisfier) bool {
+		return lockTimeSatisfied(uint32(lockTime), satisfier.LockTime)
+	}
+	if name == "older" {
+		op = txscript.OP_CHECKSEQUENCEVERIFY
+		satisfied = func(satisfier Satisfier) bool {
+			return sequenceSatisfied(uint32(lockTime), satisfier.Sequence)
+		}
+	}
+	script, err := txscript.NewScriptBuilder().AddInt64(int64(lockTime)).AddOp(op).Script()
+	if err != nil {
+		return nil, err
+	}
+	return &fragment{
+		script: script,
+		satisfy: func(satisfier Satisfier) ([][]byte, bool) {
+			return [][]byte{}, satisfied(satisfier)
+		},
+	}, nil
+}
+
+// compileHashLock compiles hash locks to OP_SIZE 32 OP_EQUALVERIFY <op> <h>
+// OP_EQUAL, which expects a 32 byte preimage.
+func compileHashLock(name, arg string) (*fragment, error) {
+	var op byte
+	size := 32
+	switch name {
+	case "sha256":
+		op = txscript.OP_SHA256
+	case "hash256":
+		op = txscript.OP_HASH256
+	case "ripemd160":
+		op, size = txscript.OP_RIPEMD160, 20
+	case "hash160":
+		op, size = txscript.OP_HASH160, 20
+	}
+	hash, err := hex.DecodeString(arg)
+	if err != nil || len(hash) != size {
+		return nil, fmt.Errorf("invalid %s hash %q: expected %d hex bytes", name, arg, size)
+	}
+
+	script, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_SIZE).AddInt64(32).AddOp(txscript.OP_EQUALVERIFY).
+		AddOp(op).AddData(hash).AddOp(txscript.OP_EQUAL).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+	return &fragment{
+		script: script,
+		unit:   true,
+		satisfy: func(satisfier Satisfier) ([][]byte, bool) {
+			preimage, ok := satisfier.Preimages[hex.EncodeToString(hash)]
+			if !ok || len(preimage) != 32 {
+				return nil, false
+			}
+			return [][]byte{preimage}, true
+		},
+	}, nil
+}
+
+// compileAnd compiles and(X,Y) to the verify form of X followed by Y. X runs
+// first, so its satisfaction is on top of the one of Y.
+func compileAnd(x, y *fragment) *fragment {
+	return &fragment{
+		script: append(verify(x.script), y.script...),
+		unit:   y.unit,
+		satisfy: func(satisfier Satisfier) ([][]byte, bool) {
+			satX, ok := x.satisfy(satisfier)
+			if !ok {
+				return nil, false
+			}
+			satY, ok := y.satisfy(satisfier)
+			if !ok {
+				return nil, false
+			}
+			return append(append([][]byte{}, satY...), satX...), true
+		},
+	}
+}
+
+// compileOr compiles or(X,Y) to OP_IF X OP_ELSE Y OP_ENDIF, and is satisfied
+// by the smallest satisfaction of its branches.
+func compileOr(x, y *fragment) *fragment {
+	script := []byte{txscript.OP_IF}
+	script = append(script, x.script...)
+	script = append(script, txscript.OP_ELSE)
+	script = append(script, y.script...)
+	script = append(script, txscript.OP_ENDIF)
+	return &fragment{
+		script: script,
+		unit:   x.unit && y.unit,
+		satisfy: func(satisfier Satisfier) ([][]byte, bool) {
+			satX, okX := x.satisfy(satisfier)
+			satY, okY := y.satisfy(satisfier)
+			if okX {
+				satX = append(append([][]byte{}, satX...), []byte{1})
+			}
+			if okY {
+				satY = append(append([][]byte{}, satY...), []byte{})
+			}
+			switch {
+			case okX && (!okY || stackSize(satX) <= stackSize(satY)):
+				return satX, true
+			case okY:
+				return satY, true
+			default:
+				return nil, false
+			}
+		},
+	}
+}
+
+// compileThresh compiles thresh(k,X1,...,Xn) to
+//
+//	X1' OP_TOALTSTACK X2' OP_FROMALTSTACK OP_ADD ... <k> OP_EQUAL
+//
+// where Xi' is OP_IF Xi [OP_0NOTEQUAL] OP_ELSE OP_0 OP_ENDIF, so that every
+// policy counts as 1 when satisfied and can be skipped with an empty push.
+func compileThresh(k int, subs []*fragment) (*fragment, error) {
+	script := []byte{}
+	for i, sub := range subs {
+		if i > 0 {
+			script = append(script, txscript.OP_TOALTSTACK)
+		}
+		script = append(script, txscript.OP_IF)
+		script = append(script, sub.script...)
+		if !sub.unit {
+			script = append(script, txscript.OP_0NOTEQUAL)
+		}
+		script = append(script, txscript.OP_ELSE, txscript.OP_0, txscript.OP_ENDIF)
+		if i > 0 {
+			script = append(script, txscript.OP_FROMALTSTACK, txscript.OP_ADD)
+		}
+	}
+	threshold, err := txscript.NewScriptBuilder().AddInt64(int64(k)).AddOp(txscript.OP_EQUAL).Script()
+	if err != nil {
+		return nil, err
+	}
+	script = append(script, threshold...)
+
+	return &fragment{
+		script: script,
+		unit:   true,
+		satisfy: func(satisfier Satisfier) ([][]byte, bool) {
+			// Satisfy the k policies with the smallest satisfactions, and skip
+			// the others.
+			sats := make([][][]byte, len(subs))
+			satisfied := []int{}
+			for i, sub := range subs {
+				sat, ok := sub.satisfy(satisfier)
+				if ok {
+					sats[i] = append(append([][]byte{}, sat...), []byte{1})
+					satisfied = append(satisfied, i)
+				}
+			}
+			if len(satisfied) < k {
+				return nil, false
+			}
+			sort.SliceStable(satisfied, func(i, j int) bool {
+				return stackSize(sats[satisfied[i]]) < stackSize(sats[satisfied[j]])
+			})
+			use := map[int]bool{}
+			for _, i := range satisfied[:k] {
+				use[i] = true
+			}
+
+			// The first policy runs first, so its satisfaction is on top.
+			stack := [][]byte{}
+			for i := len(subs) - 1; i >= 0; i-- {
+				if use[i] {
+					stack = append(stack, sats[i]...)
+				} else {
+					stack = append(stack, []byte{})
+				}
+			}
+			return stack, true
+		},
+	}, nil
+}
+
+// verify returns the verify form of the script, which fails instead of
+// leaving false on the stack.
+func verify(script []byte) []byte {
+	verified := append([]byte{}, script...)
+	switch last := len(verified) - 1; verified[last] {
+	case txscript.OP_CHECKSIG:
+		verified[last] = txscript.OP_CHECKSIGVERIFY
+	case txscript.OP_EQUAL:
+		verified[last] = txscript.OP_EQUALVERIFY
+	default:
+		verified = append(verified, txscript.OP_VERIFY)
+	}
+	return verified
+}
+
+// lockTimeSatisfied returns whether a transaction with the given lock time
+// satisfies OP_CHECKLOCKTIMEVERIFY with the required one.
+func lockTimeSatisfied(required, lockTime uint32) bool {
+	const threshold = 500000000
+	return (required < threshold) == (lockTime < threshold) && lockTime >= required
+}
+
+// sequenceSatisfied returns whether an input with the given sequence number
+// satisfies OP_CHECKSEQUENCEVERIFY with the required one.
+func sequenceSatisfied(required, sequence uint32) bool {
+	if sequence&wire.SequenceLockTimeDisabled != 0 {
+		return false
+	}
+	if required&wire.SequenceLockTimeIsSeconds != sequence&wire.SequenceLockTimeIsSeconds {
+		return false
+	}
+	return sequence&wire.SequenceLockTimeMask >= required&wire.SequenceLockTimeMask
+}
+
+// stackSize returns the size of the pushes of the stack items.
+func stackSize(stack [][]byte) int {
+	size := 0
+	for _, item := range stack {
+		size += len(item) + 1
+	}
+	return size
+}
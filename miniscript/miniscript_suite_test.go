@@ -0,0 +1,13 @@
+package miniscript_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestMiniscript(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Miniscript Suite")
+}
@@ -0,0 +1,65 @@
+package miniscript
+
+import (
+	"fmt"
+	"strings"
+)
+
+// node is a parsed policy expression, like thresh(2,pk(A),pk(B),older(10)).
+// Arguments that are expressions themselves are in subs, the others in args.
+type node struct {
+	name string
+	args []string
+	subs []*node
+}
+
+func parse(policy string) (*node, error) {
+	policy = strings.Join(strings.Fields(policy), "")
+	n, rest, err := parseNode(policy)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("unexpected %q after policy", rest)
+	}
+	return n, nil
+}
+
+func parseNode(s string) (*node, string, error) {
+	open := strings.IndexByte(s, '(')
+	if open < 1 {
+		return nil, "", fmt.Errorf("expected expression at %q", s)
+	}
+	n := &node{name: s[:open]}
+	s = s[open+1:]
+	for {
+		end := strings.IndexAny(s, "(),")
+		if end < 0 {
+			return nil, "", fmt.Errorf("unexpected end of %s expression", n.name)
+		}
+		if s[end] == '(' {
+			sub, rest, err := parseNode(s)
+			if err != nil {
+				return nil, "", err
+			}
+			n.subs = append(n.subs, sub)
+			s = rest
+		} else {
+			n.args = append(n.args, s[:end])
+			s = s[end:]
+		}
+
+		if s == "" {
+			return nil, "", fmt.Errorf("unexpected end of %s expression", n.name)
+		}
+		sep := s[0]
+		s = s[1:]
+		switch sep {
+		case ')':
+			return n, s, nil
+		case ',':
+		default:
+			return nil, "", fmt.Errorf("unexpected %q in %s expression", sep, n.name)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Package miniscript compiles spending policies into Bitcoin scripts, and
+// builds the data satisfying them.
+//
+// Policies are expressions of:
+//
+//	pk(KEY)            a signature of KEY
+//	pkh(KEY)           a signature of KEY, along with KEY
+//	after(N)           a lock time of at least N, checked by OP_CHECKLOCKTIMEVERIFY
+//	older(N)           a sequence number of at least N, checked by OP_CHECKSEQUENCEVERIFY
+//	sha256(H)          the 32 byte preimage of H, also hash256, ripemd160 and hash160
+//	and(X,Y)           both X and Y
+//	or(X,Y)            either X or Y
+//	thresh(K,X,Y,...)  K of the policies
+//
+// where keys are hex compressed public keys, and hashes are hex.
+package miniscript
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// maxStandardWitnessScriptSize is the size above which P2WSH spends are not
+// relayed.
+const maxStandardWitnessScriptSize = 3600
+
+// ScriptType is the way a compiled script is committed to in the outputs it
+// owns.
+type ScriptType uint8
+
+// ScriptType values.
+const (
+	P2SH = ScriptType(iota)
+	P2WSH
+)
+
+// placeholderSigSize is the size of the signature standing in for the one of
+// the TxBuilder when building a template.
+const placeholderSigSize = 73
+
+// Satisfier holds what is available to satisfy a policy.
+type Satisfier struct {
+	// Signatures are the serialized signatures, with their sighash type, of
+	// the hex public keys.
+	Signatures map[string][]byte
+	// Preimages are the preimages of the hex hashes.
+	Preimages map[string][]byte
+	// LockTime and Sequence are the lock time of the spending transaction and
+	// the sequence number of the spending input.
+	LockTime uint32
+	Sequence uint32
+}
+
+// Script is a compiled policy.
+type Script struct {
+	Policy string
+	Script []byte
+	Type   ScriptType
+	root   *fragment
+}
+
+// Compile compiles the policy into a script spent from P2WSH outputs, which
+// is at most 3600 bytes to be standard.
+func Compile(policy string) (*Script, error) {
+	return compileScript(policy, P2WSH, maxStandardWitnessScriptSize)
+}
+
+// CompileP2SH compiles the policy into a script spent from P2SH outputs, to be
+// used with Template. The script is pushed by the spending input, so it is at
+// most 520 bytes.
+func CompileP2SH(policy string) (*Script, error) {
+	return compileScript(policy, P2SH, txscript.MaxScriptElementSize)
+}
+
+func compileScript(policy string, scriptType ScriptType, maxSize int) (*Script, error) {
+	n, err := parse(policy)
+	if err != nil {
+		return nil, err
+	}
+	root, err := compile(n)
+	if err != nil {
+		return nil, err
+	}
+	if len(root.script) > maxSize {
+		return nil, fmt.Errorf("script of %d bytes is larger than the maximum of %d bytes", len(root.script), maxSize)
+	}
+	return &Script{
+		Policy: policy,
+		Script: root.script,
+		Type:   scriptType,
+		root:   root,
+	}, nil
+}
+
+// Address returns the P2WSH or P2SH address of the script.
+func (script *Script) Address(params *chaincfg.Params) (btcutil.Address, error) {
+	if script.Type == P2SH {
+		return btcutil.NewAddressScriptHash(script.Script, params)
+	}
+	hash := sha256.Sum256(script.Script)
+	return btcutil.NewAddressWitnessScriptHash(hash[:], params)
+}
+
+// ScriptPubKey returns the public key script of the outputs of the script.
+func (script *Script) ScriptPubKey(params *chaincfg.Params) ([]byte, error) {
+	address, err := script.Address(params)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.PayToAddrScript(address)
+}
+
+// Satisfy returns the smallest stack satisfying the script with what the
+// satisfier holds, bottom first.
+func (script *Script) Satisfy(satisfier Satisfier) ([][]byte, error) {
+	stack, ok := script.root.satisfy(satisfier)
+	if !ok {
+		return nil, fmt.Errorf("cannot satisfy %s", script.Policy)
+	}
+	return stack, nil
+}
+
+// Witness returns the witness of an input spending a P2WSH output of the
+// script.
+func (script *Script) Witness(satisfier Satisfier) (wire.TxWitness, error) {
+	if script.Type != P2WSH {
+		return nil, fmt.Errorf("%s is not compiled for P2WSH", script.Policy)
+	}
+	stack, err := script.Satisfy(satisfier)
+	if err != nil {
+		return nil, err
+	}
+	return append(wire.TxWitness(stack), script.Script), nil
+}
+
+// Template returns the pushes to give to libbtc.WithScriptSigTemplate, to
+// spend P2SH outputs of a script compiled with CompileP2SH, used as contract,
+// with a TxBuilder signing with the given public key. The TxBuilder pushes its
+// signature and public key first, so the policy must be satisfied with
+// pkh(pubKey) checked last, like and(X,pkh(pubKey)).
+func (script *Script) Template(pubKey []byte, satisfier Satisfier) ([][]byte, error) {
+	if script.Type != P2SH {
+		return nil, fmt.Errorf("%s is not compiled for P2SH", script.Policy)
+	}
+	placeholder := make([]byte, placeholderSigSize)
+	signatures := map[string][]byte{}
+	for key, sig := range satisfier.Signatures {
+		signatures[key] = sig
+	}
+	signatures[hex.EncodeToString(pubKey)] = placeholder
+	satisfier.Signatures = signatures
+
+	stack, err := script.Satisfy(satisfier)
+	if err != nil {
+		return nil, err
+	}
+	if len(stack) < 2 || !bytes.Equal(stack[0], placeholder) || !bytes.Equal(stack[1], pubKey) {
+		return nil, fmt.Errorf("%s does not check pkh(%x) last", script.Policy, pubKey)
+	}
+	return stack[2:], nil
+}
@@ -0,0 +1,201 @@
+package miniscript_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go/miniscript"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var _ = Describe("Miniscript", func() {
+	newKey := func() (*btcec.PrivateKey, string) {
+		key, err := btcec.NewPrivateKey(btcec.S256())
+		Expect(err).ShouldNot(HaveOccurred())
+		return key, hex.EncodeToString(key.PubKey().SerializeCompressed())
+	}
+
+	// spend returns the error of the script engine executing a transaction
+	// spending a P2WSH output of the script with the witness built from the
+	// satisfier, signed by the given keys.
+	spend := func(script *Script, keys []*btcec.PrivateKey, satisfier Satisfier) error {
+		scriptPubKey, err := script.ScriptPubKey(&chaincfg.RegressionNetParams)
+		Expect(err).ShouldNot(HaveOccurred())
+		const amount = 100000
+
+		tx := wire.NewMsgTx(2)
+		tx.LockTime = satisfier.LockTime
+		txIn := wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil)
+		txIn.Sequence = satisfier.Sequence
+		tx.AddTxIn(txIn)
+		tx.AddTxOut(wire.NewTxOut(amount-1000, scriptPubKey))
+
+		signatures := map[string][]byte{}
+		for _, key := range keys {
+			sigHashes := txscript.NewTxSigHashes(tx)
+			sig, err := txscript.RawTxInWitnessSignature(tx, sigHashes, 0, amount, script.Script, txscript.SigHashAll, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			signatures[hex.EncodeToString(key.PubKey().SerializeCompressed())] = sig
+		}
+		satisfier.Signatures = signatures
+		witness, err := script.Witness(satisfier)
+		if err != nil {
+			return err
+		}
+		tx.TxIn[0].Witness = witness
+
+		engine, err := txscript.NewEngine(scriptPubKey, tx, 0, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(tx), amount)
+		Expect(err).ShouldNot(HaveOccurred())
+		return engine.Execute()
+	}
+
+	Context("when compiling a policy", func() {
+		It("should compile pk to a checksig", func() {
+			_, pubKey := newKey()
+			script, err := Compile(fmt.Sprintf("pk(%s)", pubKey))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(hex.EncodeToString(script.Script)).Should(Equal("21" + pubKey + "ac"))
+			Expect(script.Type).Should(Equal(P2WSH))
+		})
+
+		It("should compile and to the verify form of its first policy", func() {
+			_, pubKey := newKey()
+			script, err := Compile(fmt.Sprintf("and(pk(%s),older(10))", pubKey))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(hex.EncodeToString(script.Script)).Should(Equal("21" + pubKey + "ad5ab2"))
+		})
+
+		It("should ignore whitespace", func() {
+			_, pubKey := newKey()
+			script, err := Compile(fmt.Sprintf("or( pk(%s), after(100) )", pubKey))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(script.Script[0]).Should(Equal(byte(txscript.OP_IF)))
+		})
+
+		It("should reject invalid policies", func() {
+			_, pubKey := newKey()
+			for _, policy := range []string{
+				"",
+				"pk()",
+				"pk(00)",
+				"older(0)",
+				"sha256(00)",
+				"and(pk(" + pubKey + "))",
+				"thresh(3,pk(" + pubKey + "),older(1))",
+				"unknown(1)",
+				"pk(" + pubKey + ")pk(" + pubKey + ")",
+			} {
+				_, err := Compile(policy)
+				Expect(err).Should(HaveOccurred(), policy)
+			}
+		})
+
+		It("should enforce the size limit of the script type", func() {
+			// A 1-of-20 threshold of keys is about 800 bytes.
+			pubKeys := make([]string, 20)
+			for i := range pubKeys {
+				_, pubKey := newKey()
+				pubKeys[i] = fmt.Sprintf("pk(%s)", pubKey)
+			}
+			policy := fmt.Sprintf("thresh(1,%s)", strings.Join(pubKeys, ","))
+
+			script, err := Compile(policy)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(script.Script)).Should(BeNumerically(">", txscript.MaxScriptElementSize))
+			_, err = CompileP2SH(policy)
+			Expect(err).Should(HaveOccurred())
+
+			// A 1-of-120 threshold is above the standard P2WSH limit.
+			for len(pubKeys) < 120 {
+				pubKeys = append(pubKeys, pubKeys[0])
+			}
+			_, err = Compile(fmt.Sprintf("thresh(1,%s)", strings.Join(pubKeys, ",")))
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should compile P2SH scripts to P2SH addresses", func() {
+			_, pubKey := newKey()
+			script, err := CompileP2SH(fmt.Sprintf("pkh(%s)", pubKey))
+			Expect(err).ShouldNot(HaveOccurred())
+			scriptPubKey, err := script.ScriptPubKey(&chaincfg.RegressionNetParams)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(txscript.IsPayToScriptHash(scriptPubKey)).Should(BeTrue())
+			_, err = script.Witness(Satisfier{})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("when satisfying a script", func() {
+		It("should spend with a relative lock time once it has passed", func() {
+			key, pubKey := newKey()
+			script, err := Compile(fmt.Sprintf("and(pk(%s),older(10))", pubKey))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(spend(script, []*btcec.PrivateKey{key}, Satisfier{Sequence: 10})).Should(Succeed())
+			Expect(spend(script, []*btcec.PrivateKey{key}, Satisfier{Sequence: 9})).ShouldNot(Succeed())
+		})
+
+		It("should spend either branch of an or", func() {
+			key, pubKey := newKey()
+			other, otherPubKey := newKey()
+			script, err := Compile(fmt.Sprintf("or(pk(%s),pkh(%s))", pubKey, otherPubKey))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(spend(script, []*btcec.PrivateKey{key}, Satisfier{})).Should(Succeed())
+			Expect(spend(script, []*btcec.PrivateKey{other}, Satisfier{})).Should(Succeed())
+		})
+
+		It("should spend a threshold with k of its policies", func() {
+			keys := make([]*btcec.PrivateKey, 3)
+			policies := make([]string, 3)
+			for i := range keys {
+				var pubKey string
+				keys[i], pubKey = newKey()
+				policies[i] = fmt.Sprintf("pk(%s)", pubKey)
+			}
+			script, err := Compile(fmt.Sprintf("thresh(2,%s)", strings.Join(policies, ",")))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(spend(script, keys[1:], Satisfier{})).Should(Succeed())
+			Expect(spend(script, keys[:1], Satisfier{})).ShouldNot(Succeed())
+		})
+
+		It("should spend a hash lock with the preimage", func() {
+			key, pubKey := newKey()
+			preimage := make([]byte, 32)
+			preimage[0] = 1
+			hash := sha256.Sum256(preimage)
+			script, err := Compile(fmt.Sprintf("and(sha256(%x),pk(%s))", hash, pubKey))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			satisfier := Satisfier{Preimages: map[string][]byte{hex.EncodeToString(hash[:]): preimage}}
+			Expect(spend(script, []*btcec.PrivateKey{key}, satisfier)).Should(Succeed())
+			Expect(spend(script, []*btcec.PrivateKey{key}, Satisfier{})).ShouldNot(Succeed())
+		})
+
+		It("should build the template of a P2SH script checking pkh last", func() {
+			_, pubKey := newKey()
+			_, otherPubKey := newKey()
+			script, err := CompileP2SH(fmt.Sprintf("and(after(100),pkh(%s))", pubKey))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			pubKeyBytes, _ := hex.DecodeString(pubKey)
+			template, err := script.Template(pubKeyBytes, Satisfier{LockTime: 100})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(template).Should(BeEmpty())
+
+			otherPubKeyBytes, _ := hex.DecodeString(otherPubKey)
+			_, err = script.Template(otherPubKeyBytes, Satisfier{LockTime: 100})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
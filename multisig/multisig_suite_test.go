@@ -0,0 +1,23 @@
+package multisig_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/renproject/libbtc-go"
+	"github.com/renproject/libbtc-go/clients/mock"
+)
+
+func TestMultisig(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Multisig Suite")
+}
+
+// newMockClient returns a client of a new mock chain.
+func newMockClient() (*mock.Chain, libbtc.Client) {
+	chain := mock.NewChain(&chaincfg.RegressionNetParams)
+	return chain, libbtc.NewClientFromCore(chain)
+}
@@ -0,0 +1,119 @@
+package multisig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+)
+
+// ScriptType is the way the redeem script of a multisig is committed to in
+// the outputs it owns.
+type ScriptType uint8
+
+// ScriptType values.
+const (
+	P2SH = ScriptType(iota)
+	P2WSH
+)
+
+// maxPubKeys is the maximum number of public keys of a standard multisig.
+const maxPubKeys = 16
+
+// Script is an m-of-n multisig redeem script.
+type Script struct {
+	M       int
+	PubKeys [][]byte
+	Type    ScriptType
+
+	// RedeemScript is OP_m <pubKey>... OP_n OP_CHECKMULTISIG.
+	RedeemScript []byte
+}
+
+// NewScript returns the m-of-n multisig of the serialized public keys, in the
+// given order. Signatures must be given in the same order as the public keys,
+// which is taken care of by Tx. Use SortPubKeys to get the BIP67 order.
+func NewScript(m int, pubKeys [][]byte, scriptType ScriptType) (*Script, error) {
+	if len(pubKeys) == 0 || len(pubKeys) > maxPubKeys {
+		return nil, fmt.Errorf("invalid number of public keys %d: expected between 1 and %d", len(pubKeys), maxPubKeys)
+	}
+	if m < 1 || m > len(pubKeys) {
+		return nil, fmt.Errorf("invalid number of required signatures %d for %d public keys", m, len(pubKeys))
+	}
+	if scriptType != P2SH && scriptType != P2WSH {
+		return nil, fmt.Errorf("unsupported multisig script type %d", scriptType)
+	}
+
+	builder := txscript.NewScriptBuilder()
+	builder.AddInt64(int64(m))
+	for i, pubKey := range pubKeys {
+		if _, err := btcec.ParsePubKey(pubKey, btcec.S256()); err != nil {
+			return nil, fmt.Errorf("invalid public key %d: %v", i, err)
+		}
+		if scriptType == P2WSH && len(pubKey) != btcec.PubKeyBytesLenCompressed {
+			return nil, fmt.Errorf("invalid public key %d: segwit requires compressed public keys", i)
+		}
+		builder.AddData(pubKey)
+	}
+	builder.AddInt64(int64(len(pubKeys)))
+	builder.AddOp(txscript.OP_CHECKMULTISIG)
+	redeemScript, err := builder.Script()
+	if err != nil {
+		return nil, err
+	}
+	// P2SH redeem scripts are pushed by the spending input, so they can be no
+	// larger than a push, which rules out 16 compressed keys.
+	if scriptType == P2SH && len(redeemScript) > txscript.MaxScriptElementSize {
+		return nil, fmt.Errorf("redeem script of %d bytes exceeds the P2SH limit of %d bytes", len(redeemScript), txscript.MaxScriptElementSize)
+	}
+
+	return &Script{
+		M:            m,
+		PubKeys:      pubKeys,
+		Type:         scriptType,
+		RedeemScript: redeemScript,
+	}, nil
+}
+
+// SortPubKeys returns a copy of the public keys sorted lexicographically, as
+// defined in BIP67.
+func SortPubKeys(pubKeys [][]byte) [][]byte {
+	sorted := make([][]byte, len(pubKeys))
+	copy(sorted, pubKeys)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i], sorted[j]) < 0 })
+	return sorted
+}
+
+// Address returns the address of the multisig.
+func (script *Script) Address(params *chaincfg.Params) (btcutil.Address, error) {
+	if script.Type == P2WSH {
+		hash := sha256.Sum256(script.RedeemScript)
+		return btcutil.NewAddressWitnessScriptHash(hash[:], params)
+	}
+	return btcutil.NewAddressScriptHash(script.RedeemScript, params)
+}
+
+// ScriptPubKey returns the public key script of the outputs owned by the
+// multisig.
+func (script *Script) ScriptPubKey(params *chaincfg.Params) ([]byte, error) {
+	address, err := script.Address(params)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.PayToAddrScript(address)
+}
+
+// index returns the position of the public key in the redeem script.
+func (script *Script) index(pubKey []byte) (int, bool) {
+	for i, key := range script.PubKeys {
+		if bytes.Equal(key, pubKey) {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,133 @@
+package multisig_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go/multisig"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+var _ = Describe("Multisig", func() {
+	newKeys := func(n int) ([]*btcec.PrivateKey, [][]byte) {
+		keys := make([]*btcec.PrivateKey, n)
+		pubKeys := make([][]byte, n)
+		for i := range keys {
+			key, err := btcec.NewPrivateKey(btcec.S256())
+			Expect(err).ShouldNot(HaveOccurred())
+			keys[i] = key
+			pubKeys[i] = key.PubKey().SerializeCompressed()
+		}
+		return keys, pubKeys
+	}
+
+	Context("when creating a script", func() {
+		It("should accept 15 compressed keys for P2SH", func() {
+			_, pubKeys := newKeys(15)
+			script, err := NewScript(15, pubKeys, P2SH)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(script.RedeemScript)).Should(BeNumerically("<=", txscript.MaxScriptElementSize))
+		})
+
+		It("should reject P2SH redeem scripts larger than a push", func() {
+			_, pubKeys := newKeys(16)
+			_, err := NewScript(16, pubKeys, P2SH)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should accept 16 compressed keys for P2WSH", func() {
+			_, pubKeys := newKeys(16)
+			_, err := NewScript(16, pubKeys, P2WSH)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should reject more than 16 keys", func() {
+			_, pubKeys := newKeys(17)
+			_, err := NewScript(1, pubKeys, P2WSH)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should reject an invalid number of required signatures", func() {
+			_, pubKeys := newKeys(3)
+			_, err := NewScript(0, pubKeys, P2SH)
+			Expect(err).Should(HaveOccurred())
+			_, err = NewScript(4, pubKeys, P2SH)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should reject uncompressed keys for P2WSH", func() {
+			keys, pubKeys := newKeys(2)
+			pubKeys[1] = keys[1].PubKey().SerializeUncompressed()
+			_, err := NewScript(1, pubKeys, P2WSH)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should sort public keys as defined in BIP67", func() {
+			_, pubKeys := newKeys(5)
+			sorted := SortPubKeys(pubKeys)
+			Expect(sorted).Should(ConsistOf(pubKeys))
+			for i := 1; i < len(sorted); i++ {
+				Expect(string(sorted[i-1]) < string(sorted[i])).Should(BeTrue())
+			}
+		})
+	})
+
+	for _, scriptType := range []ScriptType{P2SH, P2WSH} {
+		scriptType := scriptType
+
+		Context("when spending from a 2-of-3 multisig", func() {
+			It("should publish the transaction once it has enough signatures", func() {
+				chain, client := newMockClient()
+				keys, pubKeys := newKeys(3)
+				script, err := NewScript(2, SortPubKeys(pubKeys), scriptType)
+				Expect(err).ShouldNot(HaveOccurred())
+				address, err := script.Address(client.NetworkParams())
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = chain.Fund(address.EncodeAddress(), 100000)
+				Expect(err).ShouldNot(HaveOccurred())
+				chain.Mine(1)
+
+				tx, err := Build(context.Background(), client, script, address.EncodeAddress(), 50000, 1000, nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tx.Missing()).Should(Equal([]int{2}))
+				_, err = tx.Finalize()
+				Expect(err).Should(HaveOccurred())
+
+				for _, key := range keys[:2] {
+					sigs := []*btcec.Signature{}
+					for _, hash := range tx.Hashes() {
+						sig, err := key.Sign(hash)
+						Expect(err).ShouldNot(HaveOccurred())
+						sigs = append(sigs, sig)
+					}
+					Expect(tx.AddSignatures(key.PubKey().SerializeCompressed(), sigs)).Should(Succeed())
+				}
+				Expect(tx.Missing()).Should(Equal([]int{0}))
+				txHash, err := tx.Submit(context.Background())
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(chain.Mempool()).Should(ContainElement(txHash))
+			})
+
+			It("should reject signatures of keys outside the multisig", func() {
+				chain, client := newMockClient()
+				_, pubKeys := newKeys(3)
+				outsiders, _ := newKeys(1)
+				script, err := NewScript(2, pubKeys, scriptType)
+				Expect(err).ShouldNot(HaveOccurred())
+				address, err := script.Address(client.NetworkParams())
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = chain.Fund(address.EncodeAddress(), 100000)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				tx, err := Build(context.Background(), client, script, address.EncodeAddress(), 50000, 1000, nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				sig, err := outsiders[0].Sign(tx.Hashes()[0])
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tx.AddSignature(outsiders[0].PubKey().SerializeCompressed(), 0, sig)).ShouldNot(Succeed())
+			})
+		})
+	}
+})
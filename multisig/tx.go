@@ -0,0 +1,261 @@
+package multisig
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go"
+	"github.com/renproject/libbtc-go/clients"
+)
+
+// Tx is a transaction spending outputs of a multisig, collecting the partial
+// signatures of its signers.
+type Tx interface {
+	// Hashes returns the SigHashAll hashes every signer needs to sign, one per
+	// input.
+	Hashes() [][]byte
+
+	// AddSignature adds the signature of the signer with the given public key
+	// for the input at the given index, after verifying it.
+	AddSignature(pubKey []byte, index int, sig *btcec.Signature) error
+
+	// AddSignatures adds the signatures of the signer for every input, in
+	// order.
+	AddSignatures(pubKey []byte, sigs []*btcec.Signature) error
+
+	// Missing returns the number of signatures still needed by each input.
+	Missing() []int
+
+	// Finalize returns the transaction with the signature scripts, or
+	// witnesses, of every input, once every input has enough signatures.
+	Finalize() (*wire.MsgTx, error)
+
+	// Submit finalizes and publishes the transaction, and returns its hash.
+	Submit(ctx context.Context) (string, error)
+}
+
+type transaction struct {
+	client  libbtc.Client
+	script  *Script
+	msgTx   *wire.MsgTx
+	amounts []int64
+	hashes  [][]byte
+
+	// sigs holds the signatures of every input, indexed by the position of
+	// the signer in the redeem script.
+	sigs [][][]byte
+}
+
+// Build returns a Tx spending the given outputs of the multisig to the given
+// address, with the change sent back to the multisig. If utxos is nil, every
+// unspent output of the multisig is spent. The fee is paid from the change,
+// and change below dust is added to the fee.
+func Build(ctx context.Context, client libbtc.Client, script *Script, to string, value, fee int64, utxos []clients.UTXO) (Tx, error) {
+	params := client.NetworkParams()
+	from, err := script.Address(params)
+	if err != nil {
+		return nil, err
+	}
+	scriptPubKey, err := txscript.PayToAddrScript(from)
+	if err != nil {
+		return nil, err
+	}
+	toAddr, err := btcutil.DecodeAddress(to, params)
+	if err != nil {
+		return nil, err
+	}
+	toScript, err := txscript.PayToAddrScript(toAddr)
+	if err != nil {
+		return nil, err
+	}
+	dust := libbtc.NetworkDust(params)
+	if value < dust {
+		return nil, fmt.Errorf("transfer value (%d) is less than the minimum value (%d)", value, dust)
+	}
+
+	if utxos == nil {
+		if utxos, err = client.GetUTXOs(ctx, from.EncodeAddress(), 999999, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	msgTx := wire.NewMsgTx(2)
+	amounts := []int64{}
+	var amount int64
+	for _, utxo := range utxos {
+		if utxo.ScriptPubKey != hex.EncodeToString(scriptPubKey) {
+			return nil, fmt.Errorf("utxo %s:%d is not owned by the multisig", utxo.TxHash, utxo.Vout)
+		}
+		hash, err := chainhash.NewHashFromStr(utxo.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, utxo.Vout), nil, nil))
+		amounts = append(amounts, utxo.Amount)
+		amount += utxo.Amount
+	}
+	if amount < value+fee {
+		return nil, libbtc.NewErrInsufficientBalance(from.EncodeAddress(), value+fee, amount)
+	}
+
+	msgTx.AddTxOut(wire.NewTxOut(value, toScript))
+	if change := amount - value - fee; change >= dust {
+		msgTx.AddTxOut(wire.NewTxOut(change, scriptPubKey))
+	}
+
+	hashes := make([][]byte, len(msgTx.TxIn))
+	sigHashes := txscript.NewTxSigHashes(msgTx)
+	for i := range msgTx.TxIn {
+		if script.Type == P2WSH {
+			hashes[i], err = txscript.CalcWitnessSigHash(script.RedeemScript, sigHashes, txscript.SigHashAll, msgTx, i, amounts[i])
+		} else {
+			hashes[i], err = txscript.CalcSignatureHash(script.RedeemScript, txscript.SigHashAll, msgTx, i)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sigs := make([][][]byte, len(msgTx.TxIn))
+	for i := range sigs {
+		sigs[i] = make([][]byte, len(script.PubKeys))
+	}
+	return &transaction{
+		client:  client,
+		script:  script,
+		msgTx:   msgTx,
+		amounts: amounts,
+		hashes:  hashes,
+		sigs:    sigs,
+	}, nil
+}
+
+func (tx *transaction) Hashes() [][]byte {
+	return tx.hashes
+}
+
+func (tx *transaction) AddSignature(pubKey []byte, index int, sig *btcec.Signature) error {
+	if index < 0 || index >= len(tx.hashes) {
+		return fmt.Errorf("invalid input index %d: transaction has %d inputs", index, len(tx.hashes))
+	}
+	position, ok := tx.script.index(pubKey)
+	if !ok {
+		return fmt.Errorf("%s is not a signer of the multisig", hex.EncodeToString(pubKey))
+	}
+	key, err := btcec.ParsePubKey(pubKey, btcec.S256())
+	if err != nil {
+		return err
+	}
+	if !sig.Verify(tx.hashes[index], key) {
+		return fmt.Errorf("invalid signature for input %d from %s", index, hex.EncodeToString(pubKey))
+	}
+	tx.sigs[index][position] = append(sig.Serialize(), byte(txscript.SigHashAll))
+	return nil
+}
+
+func (tx *transaction) AddSignatures(pubKey []byte, sigs []*btcec.Signature) error {
+	if len(sigs) != len(tx.hashes) {
+		return fmt.Errorf("expected %d signatures, got %d", len(tx.hashes), len(sigs))
+	}
+	for i, sig := range sigs {
+		if err := tx.AddSignature(pubKey, i, sig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tx *transaction) Missing() []int {
+	missing := make([]int, len(tx.sigs))
+	for i, inputSigs := range tx.sigs {
+		missing[i] = tx.script.M
+		for _, sig := range inputSigs {
+			if sig != nil && missing[i] > 0 {
+				missing[i]--
+			}
+		}
+	}
+	return missing
+}
+
+func (tx *transaction) Finalize() (*wire.MsgTx, error) {
+	for i, missing := range tx.Missing() {
+		if missing > 0 {
+			return nil, fmt.Errorf("input %d is missing %d signatures", i, missing)
+		}
+	}
+
+	msgTx := tx.msgTx.Copy()
+	for i, inputSigs := range tx.sigs {
+		// OP_CHECKMULTISIG expects the signatures in the order of the public
+		// keys.
+		sigs := [][]byte{}
+		for _, sig := range inputSigs {
+			if sig != nil && len(sigs) < tx.script.M {
+				sigs = append(sigs, sig)
+			}
+		}
+
+		// OP_CHECKMULTISIG pops an extra element, so an empty one comes
+		// first.
+		if tx.script.Type == P2WSH {
+			witness := wire.TxWitness{[]byte{}}
+			witness = append(witness, sigs...)
+			msgTx.TxIn[i].Witness = append(witness, tx.script.RedeemScript)
+			continue
+		}
+		builder := txscript.NewScriptBuilder()
+		builder.AddOp(txscript.OP_0)
+		for _, sig := range sigs {
+			builder.AddData(sig)
+		}
+		builder.AddData(tx.script.RedeemScript)
+		sigScript, err := builder.Script()
+		if err != nil {
+			return nil, err
+		}
+		msgTx.TxIn[i].SignatureScript = sigScript
+	}
+
+	if err := tx.verify(msgTx); err != nil {
+		return nil, err
+	}
+	return msgTx, nil
+}
+
+func (tx *transaction) Submit(ctx context.Context) (string, error) {
+	msgTx, err := tx.Finalize()
+	if err != nil {
+		return "", err
+	}
+	if err := tx.client.PublishTransaction(ctx, msgTx); err != nil {
+		return "", err
+	}
+	return msgTx.TxHash().String(), nil
+}
+
+func (tx *transaction) verify(msgTx *wire.MsgTx) error {
+	scriptPubKey, err := tx.script.ScriptPubKey(tx.client.NetworkParams())
+	if err != nil {
+		return err
+	}
+	sigHashes := txscript.NewTxSigHashes(msgTx)
+	for i := range msgTx.TxIn {
+		engine, err := txscript.NewEngine(scriptPubKey, msgTx, i,
+			txscript.StandardVerifyFlags, txscript.NewSigCache(10),
+			sigHashes, tx.amounts[i])
+		if err != nil {
+			return err
+		}
+		if err := engine.Execute(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
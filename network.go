@@ -0,0 +1,48 @@
+package libbtc
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/renproject/libbtc-go/networks"
+)
+
+// NetworkDust returns the value below which outputs are dust on the network,
+// which is BitcoinDust unless the network is registered in the networks
+// package.
+func NetworkDust(params *chaincfg.Params) int64 {
+	if network, ok := networks.Lookup(params); ok {
+		return network.Dust
+	}
+	return BitcoinDust
+}
+
+// NetworkMaxFee returns the fee above which fees are capped on the network,
+// which is MaxBitcoinFee unless the network is registered in the networks
+// package.
+func NetworkMaxFee(params *chaincfg.Params) int64 {
+	if network, ok := networks.Lookup(params); ok {
+		return network.MaxFee
+	}
+	return MaxBitcoinFee
+}
+
+// NetworkTxRate returns the fee rate for the speed on the network. Registered
+// networks use their default fee rates, and bitcoin networks the rate
+// suggested by bitcoinfees.earn.com.
+func NetworkTxRate(params *chaincfg.Params, txSpeed TxExecutionSpeed) (int64, error) {
+	network, ok := networks.Lookup(params)
+	if !ok {
+		return SuggestedTxRate(txSpeed)
+	}
+	switch txSpeed {
+	case Slow:
+		return network.SlowFeeRate, nil
+	case Standard:
+		return network.StandardFeeRate, nil
+	case Fast:
+		return network.FastFeeRate, nil
+	default:
+		return 0, fmt.Errorf("invalid speed tier: %v", txSpeed)
+	}
+}
@@ -0,0 +1,91 @@
+// Package networks registers chains sharing the transaction format of bitcoin,
+// such as Litecoin and Dogecoin, along with the policy defaults in which they
+// differ from bitcoin.
+package networks
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Network is a chain registered with its parameters and policy defaults.
+type Network struct {
+	Params *chaincfg.Params
+
+	// Dust is the value below which outputs are not relayed.
+	Dust int64
+
+	// MaxFee is the fee above which fees are capped.
+	MaxFee int64
+
+	// SlowFeeRate, StandardFeeRate and FastFeeRate are the fee rates, in the
+	// smallest unit of the chain per vbyte, used for each transaction speed.
+	SlowFeeRate     int64
+	StandardFeeRate int64
+	FastFeeRate     int64
+}
+
+var (
+	mu       = new(sync.RWMutex)
+	registry = map[string]Network{}
+)
+
+// Register registers the network, and its parameters with chaincfg so that
+// the addresses of the network can be decoded. Networks are identified by the
+// name of their parameters.
+func Register(network Network) error {
+	if network.Params == nil {
+		return fmt.Errorf("cannot register a network without parameters")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[network.Params.Name]; ok {
+		return fmt.Errorf("network %s is already registered", network.Params.Name)
+	}
+	// Networks sharing the magic of a registered network, for example forks
+	// used for testing, only need their network parameters to be registered
+	// once.
+	if err := chaincfg.Register(network.Params); err != nil && err != chaincfg.ErrDuplicateNet {
+		return err
+	}
+	registry[network.Params.Name] = network
+	return nil
+}
+
+// Lookup returns the registered network with the given parameters.
+func Lookup(params *chaincfg.Params) (Network, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	network, ok := registry[params.Name]
+	return network, ok
+}
+
+// LookupGenesis returns the registered network with the given genesis hash,
+// for clients that detect the network of the server they connect to.
+func LookupGenesis(hash string) (Network, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, network := range registry {
+		if network.Params.GenesisHash != nil && network.Params.GenesisHash.String() == hash {
+			return network, true
+		}
+	}
+	return Network{}, false
+}
+
+func mustRegister(network Network) {
+	if err := Register(network); err != nil {
+		panic(err)
+	}
+}
+
+func newHashFromStr(hash string) *chainhash.Hash {
+	h, err := chainhash.NewHashFromStr(hash)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
@@ -0,0 +1,135 @@
+package networks
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// The parameters of Litecoin and Dogecoin are copies of the ones of bitcoin
+// with the fields used to encode addresses and keys, identify the network and
+// estimate block times replaced. Consensus fields that are not used by this
+// library, such as checkpoints and deployments, are not meaningful.
+
+// LitecoinMainNetParams are the parameters of the Litecoin main network.
+var LitecoinMainNetParams = altParams(chaincfg.MainNetParams, altParamsConfig{
+	name:             "litecoin-mainnet",
+	net:              0xdbb6c0fb,
+	genesisHash:      "12a765e31ffd4059bada1e25190f6e98c99d9714d334efa41a195a7e7e04bfe2",
+	targetTime:       150 * time.Second,
+	coinbaseMaturity: 100,
+	bech32HRP:        "ltc",
+	pubKeyHashAddrID: 0x30,
+	scriptHashAddrID: 0x32,
+	privateKeyID:     0xb0,
+	hdPrivateKeyID:   [4]byte{0x04, 0x88, 0xad, 0xe4},
+	hdPublicKeyID:    [4]byte{0x04, 0x88, 0xb2, 0x1e},
+	hdCoinType:       2,
+})
+
+// LitecoinTestNetParams are the parameters of the Litecoin test network
+// (testnet4).
+var LitecoinTestNetParams = altParams(chaincfg.TestNet3Params, altParamsConfig{
+	name:             "litecoin-testnet4",
+	net:              0xf1c8d2fd,
+	genesisHash:      "4966625a4b2851d9fdee139e56211a0d88575f59ed816ff5e6a63deb4e3e29a0",
+	targetTime:       150 * time.Second,
+	coinbaseMaturity: 100,
+	bech32HRP:        "tltc",
+	pubKeyHashAddrID: 0x6f,
+	scriptHashAddrID: 0x3a,
+	privateKeyID:     0xef,
+	hdPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+	hdPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+	hdCoinType:       1,
+})
+
+// DogecoinMainNetParams are the parameters of the Dogecoin main network, which
+// does not support segwit.
+var DogecoinMainNetParams = altParams(chaincfg.MainNetParams, altParamsConfig{
+	name:             "dogecoin-mainnet",
+	net:              0xc0c0c0c0,
+	genesisHash:      "1a91e3dace36e2be3bf030a65679fe821aa1d6ef92e7c9902eb318182c355691",
+	targetTime:       time.Minute,
+	coinbaseMaturity: 240,
+	pubKeyHashAddrID: 0x1e,
+	scriptHashAddrID: 0x16,
+	privateKeyID:     0x9e,
+	hdPrivateKeyID:   [4]byte{0x02, 0xfa, 0xc3, 0x98},
+	hdPublicKeyID:    [4]byte{0x02, 0xfa, 0xca, 0xfd},
+	hdCoinType:       3,
+})
+
+// DogecoinTestNetParams are the parameters of the Dogecoin test network.
+var DogecoinTestNetParams = altParams(chaincfg.TestNet3Params, altParamsConfig{
+	name:             "dogecoin-testnet",
+	net:              0xdcb7c1fc,
+	genesisHash:      "bb0a78264637406b6360aad926284d544d7049f45189db5664f3c4d07350559e",
+	targetTime:       time.Minute,
+	coinbaseMaturity: 240,
+	pubKeyHashAddrID: 0x71,
+	scriptHashAddrID: 0xc4,
+	privateKeyID:     0xf1,
+	hdPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+	hdPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+	hdCoinType:       1,
+})
+
+func init() {
+	for _, params := range []*chaincfg.Params{&LitecoinMainNetParams, &LitecoinTestNetParams} {
+		mustRegister(Network{
+			Params:          params,
+			Dust:            5460,
+			MaxFee:          1000000,
+			SlowFeeRate:     10,
+			StandardFeeRate: 20,
+			FastFeeRate:     50,
+		})
+	}
+	for _, params := range []*chaincfg.Params{&DogecoinMainNetParams, &DogecoinTestNetParams} {
+		mustRegister(Network{
+			Params:          params,
+			Dust:            1000000,
+			MaxFee:          100000000,
+			SlowFeeRate:     1000,
+			StandardFeeRate: 1000,
+			FastFeeRate:     2000,
+		})
+	}
+}
+
+type altParamsConfig struct {
+	name             string
+	net              wire.BitcoinNet
+	genesisHash      string
+	targetTime       time.Duration
+	coinbaseMaturity uint16
+	bech32HRP        string
+	pubKeyHashAddrID byte
+	scriptHashAddrID byte
+	privateKeyID     byte
+	hdPrivateKeyID   [4]byte
+	hdPublicKeyID    [4]byte
+	hdCoinType       uint32
+}
+
+func altParams(params chaincfg.Params, config altParamsConfig) chaincfg.Params {
+	params.Name = config.name
+	params.Net = config.net
+	params.DefaultPort = ""
+	params.DNSSeeds = nil
+	params.Checkpoints = nil
+	params.GenesisBlock = nil
+	params.GenesisHash = newHashFromStr(config.genesisHash)
+	params.TargetTimePerBlock = config.targetTime
+	params.CoinbaseMaturity = config.coinbaseMaturity
+	params.Bech32HRPSegwit = config.bech32HRP
+	params.PubKeyHashAddrID = config.pubKeyHashAddrID
+	params.ScriptHashAddrID = config.scriptHashAddrID
+	params.PrivateKeyID = config.privateKeyID
+	params.HDPrivateKeyID = config.hdPrivateKeyID
+	params.HDPublicKeyID = config.hdPublicKeyID
+	params.HDCoinType = config.hdCoinType
+	return params
+}
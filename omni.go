@@ -0,0 +1,159 @@
+package libbtc
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// USDTPropertyID is the Omni property ID of USDT.
+const USDTPropertyID = 31
+
+// omniMarker prefixes the payload of Omni class C transactions.
+var omniMarker = []byte("omni")
+
+// OmniOption configures the layout of the transactions of TransferOmni, for
+// the Omni wallets that find the reference output by its position.
+type OmniOption func(*omniOptions)
+
+type omniOptions struct {
+	referenceIndex int
+	changeIndex    int
+	referenceValue int64
+}
+
+// OmniReferenceIndex sets the position of the reference output relative to
+// the Omni payload: 0, the default, puts it before the payload and 1 after.
+func OmniReferenceIndex(index int) OmniOption {
+	return func(opts *omniOptions) {
+		opts.referenceIndex = index
+	}
+}
+
+// OmniChangeIndex sets the index of the bitcoin change output in the
+// transaction, from 0 to 2. By default, the change is the last output.
+func OmniChangeIndex(index int) OmniOption {
+	return func(opts *omniOptions) {
+		opts.changeIndex = index
+	}
+}
+
+// OmniReferenceValue sets the value of the reference output, which is the
+// dust of the network by default. It cannot be lower than dust.
+func OmniReferenceValue(value int64) OmniOption {
+	return func(opts *omniOptions) {
+		opts.referenceValue = value
+	}
+}
+
+// OmniSimpleSendScript returns the OP_RETURN script of an Omni simple send of
+// the amount, in willets, of the property.
+func OmniSimpleSendScript(propertyID uint32, amount int64) ([]byte, error) {
+	payload := make([]byte, 16)
+	// The version and the type of simple sends are both 0.
+	binary.BigEndian.PutUint32(payload[4:8], propertyID)
+	binary.BigEndian.PutUint64(payload[8:], uint64(amount))
+	return txscript.NullDataScript(append(omniMarker, payload...))
+}
+
+// TransferOmni sends the amount, in willets, of the Omni property from the
+// account to the address in a simple send, and returns the hash of the
+// transaction and its fee. The recipient is the reference output of the
+// transaction, which is funded with dust of the network, and the bitcoin
+// change is sent back to the account after the Omni payload, unless the
+// options say otherwise. The Omni balance of the account is not checked: a
+// send exceeding it is mined but rejected by the Omni protocol.
+func (account *account) TransferOmni(ctx context.Context, to string, propertyID uint32, amount int64, speed TxExecutionSpeed, opts ...OmniOption) (string, int64, error) {
+	sending, txOuts, err := account.omniSend(to, propertyID, amount, opts)
+	if err != nil {
+		return "", 0, err
+	}
+	return sending.SendTransaction(ctx, nil, speed, nil, addTxOuts(txOuts), nil, nil, false)
+}
+
+// BuildOmni builds and signs the transaction of TransferOmni without
+// publishing it.
+func (account *account) BuildOmni(ctx context.Context, to string, propertyID uint32, amount int64, speed TxExecutionSpeed, opts ...OmniOption) (string, []byte, error) {
+	sending, txOuts, err := account.omniSend(to, propertyID, amount, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	return sending.BuildTransaction(ctx, nil, speed, nil, addTxOuts(txOuts), nil, nil, false)
+}
+
+// TransferUSDT sends the amount of USDT, in willets, from the account to the
+// address in an Omni simple send. See TransferOmni.
+func (account *account) TransferUSDT(ctx context.Context, to string, amount int64, speed TxExecutionSpeed, opts ...OmniOption) (string, int64, error) {
+	return account.TransferOmni(ctx, to, USDTPropertyID, amount, speed, opts...)
+}
+
+// BuildTransferUSDT builds and signs the transaction of TransferUSDT without
+// publishing it.
+func (account *account) BuildTransferUSDT(ctx context.Context, to string, amount int64, speed TxExecutionSpeed, opts ...OmniOption) (string, []byte, error) {
+	return account.BuildOmni(ctx, to, USDTPropertyID, amount, speed, opts...)
+}
+
+// omniSend returns the reference output and the Omni payload of a simple
+// send, in the order of the options, with the account to send them from,
+// which moves the change to its position.
+func (account *account) omniSend(to string, propertyID uint32, amount int64, opts []OmniOption) (*account, []*wire.TxOut, error) {
+	if amount <= 0 {
+		return nil, nil, fmt.Errorf("omni amount must be positive")
+	}
+	dust := NetworkDust(account.NetworkParams())
+	options := omniOptions{changeIndex: -1, referenceValue: dust}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.referenceIndex < 0 || options.referenceIndex > 1 {
+		return nil, nil, fmt.Errorf("invalid reference output index %d: expected 0 or 1", options.referenceIndex)
+	}
+	if options.changeIndex < -1 || options.changeIndex > 2 {
+		return nil, nil, fmt.Errorf("invalid change output index %d: expected between 0 and 2", options.changeIndex)
+	}
+	if options.referenceValue < dust {
+		return nil, nil, fmt.Errorf("reference output value (%d) is less than the minimum value (%d)", options.referenceValue, dust)
+	}
+
+	address, err := btcutil.DecodeAddress(to, account.NetworkParams())
+	if err != nil {
+		return nil, nil, err
+	}
+	reference, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return nil, nil, err
+	}
+	payload, err := OmniSimpleSendScript(propertyID, amount)
+	if err != nil {
+		return nil, nil, err
+	}
+	txOuts := []*wire.TxOut{
+		wire.NewTxOut(options.referenceValue, reference),
+		wire.NewTxOut(0, payload),
+	}
+	if options.referenceIndex == 1 {
+		txOuts[0], txOuts[1] = txOuts[1], txOuts[0]
+	}
+
+	// The copy shares the reservations of the account.
+	sending := *account
+	sending.dustSend = true
+	if options.changeIndex >= 0 {
+		sending.arrange = func(msgTx *wire.MsgTx) {
+			// The change is the last output, unless it was dropped as
+			// dust.
+			if len(msgTx.TxOut) <= len(txOuts) {
+				return
+			}
+			last := len(msgTx.TxOut) - 1
+			change := msgTx.TxOut[last]
+			copy(msgTx.TxOut[options.changeIndex+1:], msgTx.TxOut[options.changeIndex:last])
+			msgTx.TxOut[options.changeIndex] = change
+		}
+	}
+	return &sending, txOuts, nil
+}
@@ -0,0 +1,119 @@
+package libbtc_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/clients/mock"
+)
+
+var _ = Describe("Omni sends", func() {
+	const amount = 150000000
+
+	// newFundedAccount returns a funded account on a new mock chain, and the
+	// address of a recipient.
+	newFundedAccount := func() (*mock.Chain, Account, string) {
+		chain, account := newMockAccount()
+		address, err := account.Address()
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = chain.Fund(address.EncodeAddress(), 100000)
+		Expect(err).ShouldNot(HaveOccurred())
+		chain.Mine(1)
+
+		recipient, err := btcec.NewPrivateKey(btcec.S256())
+		Expect(err).ShouldNot(HaveOccurred())
+		to, err := account.PublicKeyToAddress(recipient.PubKey().SerializeCompressed())
+		Expect(err).ShouldNot(HaveOccurred())
+		return chain, account, to.EncodeAddress()
+	}
+
+	// layout builds an Omni send with the options and returns the kinds of
+	// its outputs, in order, along with the outputs.
+	layout := func(opts ...OmniOption) ([]string, []*wire.TxOut) {
+		_, account, to := newFundedAccount()
+		_, stx, err := account.BuildOmni(context.Background(), to, USDTPropertyID, amount, Fast, opts...)
+		Expect(err).ShouldNot(HaveOccurred())
+		tx, err := btcutil.NewTxFromBytes(stx)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		payload, err := OmniSimpleSendScript(USDTPropertyID, amount)
+		Expect(err).ShouldNot(HaveOccurred())
+		self, err := account.Address()
+		Expect(err).ShouldNot(HaveOccurred())
+		kinds := []string{}
+		for _, txOut := range tx.MsgTx().TxOut {
+			if string(txOut.PkScript) == string(payload) {
+				kinds = append(kinds, "payload")
+				continue
+			}
+			_, addresses, _, err := txscript.ExtractPkScriptAddrs(txOut.PkScript, account.NetworkParams())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addresses).Should(HaveLen(1))
+			switch addresses[0].EncodeAddress() {
+			case to:
+				kinds = append(kinds, "reference")
+			case self.EncodeAddress():
+				kinds = append(kinds, "change")
+			}
+		}
+		return kinds, tx.MsgTx().TxOut
+	}
+
+	It("should pay dust to the reference before the payload and the change last by default", func() {
+		kinds, txOuts := layout()
+		Expect(kinds).Should(Equal([]string{"reference", "payload", "change"}))
+		Expect(txOuts[0].Value).Should(Equal(NetworkDust(&chaincfg.RegressionNetParams)))
+		Expect(txOuts[1].Value).Should(BeZero())
+	})
+
+	It("should place the reference and the change at the given positions", func() {
+		kinds, _ := layout(OmniReferenceIndex(1))
+		Expect(kinds).Should(Equal([]string{"payload", "reference", "change"}))
+		kinds, _ = layout(OmniChangeIndex(0))
+		Expect(kinds).Should(Equal([]string{"change", "reference", "payload"}))
+		kinds, _ = layout(OmniChangeIndex(1))
+		Expect(kinds).Should(Equal([]string{"reference", "change", "payload"}))
+		kinds, _ = layout(OmniReferenceIndex(1), OmniChangeIndex(2))
+		Expect(kinds).Should(Equal([]string{"payload", "reference", "change"}))
+		kinds, _ = layout(OmniReferenceIndex(1), OmniChangeIndex(0))
+		Expect(kinds).Should(Equal([]string{"change", "payload", "reference"}))
+	})
+
+	It("should pay the given value to the reference", func() {
+		kinds, txOuts := layout(OmniReferenceValue(1000))
+		Expect(kinds).Should(Equal([]string{"reference", "payload", "change"}))
+		Expect(txOuts[0].Value).Should(Equal(int64(1000)))
+	})
+
+	It("should reject invalid layouts", func() {
+		_, account, to := newFundedAccount()
+		dust := NetworkDust(account.NetworkParams())
+		for _, opt := range []OmniOption{
+			OmniReferenceIndex(-1),
+			OmniReferenceIndex(2),
+			OmniChangeIndex(-2),
+			OmniChangeIndex(3),
+			OmniReferenceValue(dust - 1),
+		} {
+			_, _, err := account.BuildOmni(context.Background(), to, USDTPropertyID, amount, Fast, opt)
+			Expect(err).Should(HaveOccurred())
+		}
+		_, _, err := account.BuildOmni(context.Background(), to, USDTPropertyID, 0, Fast)
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should publish sends accepted by the chain", func() {
+		chain, account, to := newFundedAccount()
+		txHash, _, err := account.TransferOmni(context.Background(), to, USDTPropertyID, amount, Fast, OmniReferenceIndex(1), OmniChangeIndex(0))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(chain.Mempool()).Should(Equal([]string{txHash}))
+	})
+})
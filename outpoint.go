@@ -0,0 +1,58 @@
+package libbtc
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/renproject/libbtc-go/clients"
+)
+
+func (client *client) OutPointSpent(ctx context.Context, txHash string, vout uint32) (bool, string, error) {
+	var err error
+	if spender, ok := client.ClientCore.(clients.OutputSpender); ok {
+		var spend clients.Spend
+		var spent bool
+		if spend, spent, err = spender.SpentBy(ctx, txHash, vout); err == nil {
+			return spent, spend.TxHash, nil
+		}
+	}
+	if checker, ok := client.ClientCore.(clients.OutputChecker); ok {
+		var unspent bool
+		if unspent, err = checker.OutputUnspent(ctx, txHash, vout); err == nil {
+			return !unspent, "", nil
+		}
+	}
+
+	// Other backends are asked whether the output is among the unspent
+	// outputs of its address.
+	utxo, utxoErr := client.GetUTXO(ctx, txHash, vout)
+	if utxoErr != nil {
+		if err != nil {
+			return false, "", err
+		}
+		return false, "", utxoErr
+	}
+	script, err := hex.DecodeString(utxo.ScriptPubKey)
+	if err != nil {
+		return false, "", err
+	}
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(script, client.NetworkParams())
+	if err != nil {
+		return false, "", err
+	}
+	if len(addrs) != 1 {
+		return false, "", fmt.Errorf("output %s:%d does not pay to an address", txHash, vout)
+	}
+	utxos, err := client.GetUTXOs(ctx, addrs[0].EncodeAddress(), 999999, 0)
+	if err != nil {
+		return false, "", err
+	}
+	for _, utxo := range utxos {
+		if utxo.TxHash == txHash && utxo.Vout == vout {
+			return false, "", nil
+		}
+	}
+	return true, "", nil
+}
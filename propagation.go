@@ -0,0 +1,79 @@
+package libbtc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/renproject/libbtc-go/clients"
+)
+
+// BackendPropagation is whether a backend knows about a transaction.
+type BackendPropagation struct {
+	Backend       string `json:"backend"`
+	Seen          bool   `json:"seen"`
+	Confirmations int64  `json:"confirmations"`
+	Err           error  `json:"-"`
+}
+
+// PropagationStatus is how widely a transaction has propagated across the
+// backends of a client.
+type PropagationStatus struct {
+	TxHash   string               `json:"txHash"`
+	Seen     int                  `json:"seen"`
+	Backends []BackendPropagation `json:"backends"`
+}
+
+// Propagated returns true if at least the given number of backends know about
+// the transaction.
+func (status PropagationStatus) Propagated(minBackends int) bool {
+	return status.Seen >= minBackends
+}
+
+func (client *client) PropagationStatus(ctx context.Context, txHash string) (PropagationStatus, error) {
+	if _, err := chainhash.NewHashFromStr(txHash); err != nil {
+		return PropagationStatus{}, err
+	}
+
+	// Only the multi-backend client is backed by independent backends, other
+	// clients report on their only backend.
+	backends := []clients.Backend{{ClientCore: client.ClientCore}}
+	if multi, ok := client.ClientCore.(interface {
+		Backends() []clients.Backend
+	}); ok {
+		backends = multi.Backends()
+	}
+
+	results := make([]BackendPropagation, len(backends))
+	done := make(chan struct{}, len(backends))
+	for i, backend := range backends {
+		go func(i int, backend clients.Backend) {
+			defer func() { done <- struct{}{} }()
+			name := backend.Name
+			if name == "" {
+				name = fmt.Sprintf("backend %d", i)
+			}
+			conf, err := backend.Confirmations(ctx, txHash)
+			results[i] = BackendPropagation{
+				Backend:       name,
+				Seen:          err == nil,
+				Confirmations: conf,
+				Err:           err,
+			}
+		}(i, backend)
+	}
+	for range backends {
+		<-done
+	}
+
+	status := PropagationStatus{
+		TxHash:   txHash,
+		Backends: results,
+	}
+	for _, result := range results {
+		if result.Seen {
+			status.Seen++
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,80 @@
+package libbtc
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/clients"
+)
+
+// TransferQuote is the expected outcome of a transfer.
+type TransferQuote struct {
+	// Fee is the fee paid by the transfer, including change below dust
+	// dropped by the dust policy.
+	Fee int64
+	// Value is the value received by the destination, which includes change
+	// below dust with DustToPayment.
+	Value int64
+	// Change is the value sent back to the account, zero if the change output
+	// is dropped.
+	Change int64
+	// Inputs are the unspent outputs spent by the transfer.
+	Inputs []clients.UTXO
+}
+
+// EstimateTransfer selects the unspent outputs and estimates the fee of a
+// transfer the same way Transfer does, without signing or publishing it.
+func (account *account) EstimateTransfer(ctx context.Context, to string, value int64, speed TxExecutionSpeed) (TransferQuote, error) {
+	address, err := btcutil.DecodeAddress(to, account.NetworkParams())
+	if err != nil {
+		return TransferQuote{}, err
+	}
+	script, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return TransferQuote{}, err
+	}
+
+	tx := account.newTx(ctx, wire.NewMsgTx(2))
+	tx.msgTx.AddTxOut(wire.NewTxOut(value, script))
+	if err := tx.fund(nil); err != nil {
+		return TransferQuote{}, err
+	}
+	size, err := tx.estimateSTXVSize(nil, nil)
+	if err != nil {
+		return TransferQuote{}, err
+	}
+	rate, err := account.TxRate(ctx, speed)
+	if err != nil {
+		return TransferQuote{}, err
+	}
+	txFee := int64(size) * rate
+	if maxFee := NetworkMaxFee(account.NetworkParams()); txFee > maxFee-NetworkDust(account.NetworkParams()) {
+		txFee = maxFee
+	}
+	tx.msgTx.TxOut[len(tx.msgTx.TxOut)-1].Value -= txFee
+	if err := account.applyDustPolicy(tx.msgTx); err != nil {
+		return TransferQuote{}, err
+	}
+
+	quote := TransferQuote{
+		Value:  tx.msgTx.TxOut[0].Value,
+		Inputs: make([]clients.UTXO, len(tx.msgTx.TxIn)),
+	}
+	if len(tx.msgTx.TxOut) > 1 {
+		quote.Change = tx.msgTx.TxOut[len(tx.msgTx.TxOut)-1].Value
+	}
+	quote.Fee = -quote.Value - quote.Change
+	for i, txIn := range tx.msgTx.TxIn {
+		quote.Inputs[i] = clients.UTXO{
+			TxHash:       txIn.PreviousOutPoint.Hash.String(),
+			Amount:       tx.receiveValues[i],
+			ScriptPubKey: hex.EncodeToString(tx.scriptPublicKeys[i]),
+			Vout:         txIn.PreviousOutPoint.Index,
+		}
+		quote.Fee += tx.receiveValues[i]
+	}
+	return quote, nil
+}
@@ -0,0 +1,118 @@
+package libbtc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Rebroadcaster keeps a set of signed transactions and periodically publishes
+// them again until they confirm or expire, so that transactions evicted from
+// mempools are not lost.
+type Rebroadcaster interface {
+	// Add starts rebroadcasting the transaction until it confirms or the
+	// expiry is reached. A zero expiry never expires.
+	Add(stx *wire.MsgTx, expiry time.Time)
+
+	// Remove stops rebroadcasting the transaction.
+	Remove(txHash string)
+
+	// Pending returns the hashes of the transactions being rebroadcast.
+	Pending() []string
+}
+
+type pendingTx struct {
+	stx    *wire.MsgTx
+	expiry time.Time
+}
+
+type rebroadcaster struct {
+	client        Client
+	confirmations int64
+
+	mu      *sync.Mutex
+	pending map[string]pendingTx
+}
+
+// NewRebroadcaster returns a Rebroadcaster publishing the pending transactions
+// through the client at the given interval, until they have the given number
+// of confirmations or the context is done.
+func NewRebroadcaster(ctx context.Context, client Client, interval time.Duration, confirmations int64) Rebroadcaster {
+	rebroadcaster := &rebroadcaster{
+		client:        client,
+		confirmations: confirmations,
+		mu:            new(sync.Mutex),
+		pending:       map[string]pendingTx{},
+	}
+	go rebroadcaster.run(ctx, interval)
+	return rebroadcaster
+}
+
+func (rebroadcaster *rebroadcaster) Add(stx *wire.MsgTx, expiry time.Time) {
+	rebroadcaster.mu.Lock()
+	defer rebroadcaster.mu.Unlock()
+	rebroadcaster.pending[stx.TxHash().String()] = pendingTx{
+		stx:    stx.Copy(),
+		expiry: expiry,
+	}
+}
+
+func (rebroadcaster *rebroadcaster) Remove(txHash string) {
+	rebroadcaster.mu.Lock()
+	defer rebroadcaster.mu.Unlock()
+	delete(rebroadcaster.pending, txHash)
+}
+
+func (rebroadcaster *rebroadcaster) Pending() []string {
+	rebroadcaster.mu.Lock()
+	defer rebroadcaster.mu.Unlock()
+	txHashes := make([]string, 0, len(rebroadcaster.pending))
+	for txHash := range rebroadcaster.pending {
+		txHashes = append(txHashes, txHash)
+	}
+	return txHashes
+}
+
+func (rebroadcaster *rebroadcaster) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rebroadcaster.rebroadcast(ctx)
+		}
+	}
+}
+
+func (rebroadcaster *rebroadcaster) rebroadcast(ctx context.Context) {
+	rebroadcaster.mu.Lock()
+	pending := make(map[string]pendingTx, len(rebroadcaster.pending))
+	for txHash, tx := range rebroadcaster.pending {
+		pending[txHash] = tx
+	}
+	rebroadcaster.mu.Unlock()
+
+	for txHash, tx := range pending {
+		if !tx.expiry.IsZero() && time.Now().After(tx.expiry) {
+			rebroadcaster.Remove(txHash)
+			continue
+		}
+		confCtx, cancel := context.WithTimeout(ctx, knownTxTimeout)
+		conf, err := rebroadcaster.client.Confirmations(confCtx, txHash)
+		cancel()
+		if err == nil && conf > 0 && conf >= rebroadcaster.confirmations {
+			rebroadcaster.Remove(txHash)
+			continue
+		}
+
+		// Errors are expected when the transaction is still in the mempool
+		// of the backend, so they are ignored.
+		publishCtx, cancel := context.WithTimeout(ctx, knownTxTimeout)
+		rebroadcaster.client.PublishTransaction(publishCtx, tx.stx)
+		cancel()
+	}
+}
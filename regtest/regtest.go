@@ -0,0 +1,247 @@
+// Package regtest runs a throwaway bitcoind in regtest mode for integration
+// tests, so that they do not depend on testnet funds or public services.
+package regtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go"
+)
+
+// CoinbaseMaturity is the number of blocks after which a coinbase output can
+// be spent, so the node mines that many blocks on start to have funds.
+const CoinbaseMaturity = 100
+
+const (
+	rpcUser     = "libbtc"
+	rpcPassword = "libbtc"
+	walletName  = "libbtc"
+)
+
+// Node is a bitcoind running in regtest mode in a temporary data directory.
+// Its wallet holds the block rewards, which are used to fund accounts.
+type Node struct {
+	Host     string
+	User     string
+	Password string
+
+	cmd     *exec.Cmd
+	dataDir string
+	rpc     *rpcclient.Client
+	miner   string
+}
+
+// Start starts bitcoind, found at the path in the BITCOIND environment
+// variable or in the PATH, waits for it to accept RPC calls, and mines enough
+// blocks for its wallet to have spendable funds. The full-node client watches
+// addresses with importaddress in legacy wallets and with importdescriptors
+// in descriptor wallets, so any version of bitcoind can be used.
+func Start(ctx context.Context) (*Node, error) {
+	bin := os.Getenv("BITCOIND")
+	if bin == "" {
+		bin = "bitcoind"
+	}
+	dataDir, err := ioutil.TempDir("", "libbtc-regtest")
+	if err != nil {
+		return nil, err
+	}
+	rpcPort, err := freePort()
+	if err != nil {
+		os.RemoveAll(dataDir)
+		return nil, err
+	}
+
+	cmd := exec.Command(bin,
+		"-regtest",
+		"-server",
+		"-listen=0",
+		"-txindex",
+		"-printtoconsole=0",
+		"-fallbackfee=0.0002",
+		"-datadir="+dataDir,
+		fmt.Sprintf("-rpcport=%d", rpcPort),
+		"-rpcuser="+rpcUser,
+		"-rpcpassword="+rpcPassword,
+	)
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dataDir)
+		return nil, err
+	}
+
+	node := &Node{
+		Host:     fmt.Sprintf("127.0.0.1:%d", rpcPort),
+		User:     rpcUser,
+		Password: rpcPassword,
+		cmd:      cmd,
+		dataDir:  dataDir,
+	}
+	if err := node.init(ctx); err != nil {
+		node.Stop()
+		return nil, err
+	}
+	return node, nil
+}
+
+func (node *Node) init(ctx context.Context) error {
+	rpc, err := rpcclient.New(
+		&rpcclient.ConnConfig{
+			Host:         node.Host,
+			User:         node.User,
+			Pass:         node.Password,
+			HTTPPostMode: true,
+			DisableTLS:   true,
+		},
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+	node.rpc = rpc
+
+	// bitcoind answers with an error while it is warming up.
+	for {
+		if _, err := rpc.GetBlockChainInfo(); err == nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	if err := node.createWallet(); err != nil {
+		return err
+	}
+	resp, err := rpc.RawRequest("getnewaddress", nil)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(resp, &node.miner); err != nil {
+		return err
+	}
+	_, err = node.Mine(CoinbaseMaturity + 1)
+	return err
+}
+
+// createWallet creates the wallet of the node, unless bitcoind loaded a
+// default one as versions before 0.21 do.
+func (node *Node) createWallet() error {
+	resp, err := node.rpc.RawRequest("listwallets", nil)
+	if err != nil {
+		return err
+	}
+	wallets := []string{}
+	if err := json.Unmarshal(resp, &wallets); err != nil {
+		return err
+	}
+	if len(wallets) > 0 {
+		return nil
+	}
+
+	// Newer versions create descriptor wallets by default, which do not
+	// support importaddress, so a legacy wallet is requested when the
+	// descriptors parameter is known.
+	params := []json.RawMessage{json.RawMessage(fmt.Sprintf("%q", walletName))}
+	legacy := append(params,
+		json.RawMessage("false"), json.RawMessage("false"), json.RawMessage(`""`),
+		json.RawMessage("false"), json.RawMessage("false"),
+	)
+	if _, err := node.rpc.RawRequest("createwallet", legacy); err == nil {
+		return nil
+	}
+	_, err = node.rpc.RawRequest("createwallet", params)
+	return err
+}
+
+// Params returns the network parameters of the node.
+func (node *Node) Params() *chaincfg.Params {
+	return &chaincfg.RegressionNetParams
+}
+
+// Client returns a full-node client connected to the node.
+func (node *Node) Client() (libbtc.Client, error) {
+	return libbtc.NewBitcoinFNClient(node.Host, node.User, node.Password)
+}
+
+// Mine mines the given number of blocks, paying the rewards to the wallet of
+// the node, and returns their hashes.
+func (node *Node) Mine(blocks int) ([]string, error) {
+	params := []json.RawMessage{
+		json.RawMessage(fmt.Sprintf("%d", blocks)),
+		json.RawMessage(fmt.Sprintf("%q", node.miner)),
+	}
+	resp, err := node.rpc.RawRequest("generatetoaddress", params)
+	if err != nil {
+		return nil, err
+	}
+	hashes := []string{}
+	if err := json.Unmarshal(resp, &hashes); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// Fund sends the value, in satoshis, from the wallet of the node to the
+// address, and mines a block to confirm the transaction. It returns the hash
+// of the transaction.
+func (node *Node) Fund(address string, value int64) (string, error) {
+	addr, err := btcutil.DecodeAddress(address, node.Params())
+	if err != nil {
+		return "", err
+	}
+	txHash, err := node.rpc.SendToAddress(addr, btcutil.Amount(value))
+	if err != nil {
+		return "", err
+	}
+	if _, err := node.Mine(1); err != nil {
+		return "", err
+	}
+	return txHash.String(), nil
+}
+
+// FundAccount funds the address of the account, see Fund.
+func (node *Node) FundAccount(account libbtc.Account, value int64) (string, error) {
+	address, err := account.Address()
+	if err != nil {
+		return "", err
+	}
+	return node.Fund(address.EncodeAddress(), value)
+}
+
+// Stop stops bitcoind and removes its data directory.
+func (node *Node) Stop() error {
+	if node.rpc != nil {
+		node.rpc.RawRequest("stop", nil)
+		node.rpc.Shutdown()
+	}
+	done := make(chan error, 1)
+	go func() { done <- node.cmd.Wait() }()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		node.cmd.Process.Kill()
+		<-done
+	}
+	return os.RemoveAll(node.dataDir)
+}
+
+// freePort returns a TCP port that is free on the loopback interface.
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,54 @@
+package libbtc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// maxRelativeLockTimeSeconds is the longest relative lock time in seconds that
+// fits in a sequence number.
+const maxRelativeLockTimeSeconds = wire.SequenceLockTimeMask << wire.SequenceLockTimeGranularity
+
+// RelativeLockTimeBlocks returns the sequence number of an input that can only
+// be mined the given number of blocks after the output it spends, as checked
+// by OP_CHECKSEQUENCEVERIFY. Set it with WithSequence.
+func RelativeLockTimeBlocks(blocks uint16) uint32 {
+	return uint32(blocks)
+}
+
+// RelativeLockTimeSeconds returns the sequence number of an input that can
+// only be mined the given number of seconds after the output it spends, as
+// checked by OP_CHECKSEQUENCEVERIFY. Relative lock times are in units of 512
+// seconds, so the number of seconds is rounded up. Set it with WithSequence.
+func RelativeLockTimeSeconds(seconds uint32) (uint32, error) {
+	if seconds > maxRelativeLockTimeSeconds {
+		return 0, fmt.Errorf("relative lock time of %d seconds is longer than the maximum of %d seconds", seconds, maxRelativeLockTimeSeconds)
+	}
+	units := (seconds + 1<<wire.SequenceLockTimeGranularity - 1) >> wire.SequenceLockTimeGranularity
+	return wire.SequenceLockTimeIsSeconds | units, nil
+}
+
+// RelativeLockTimeReached returns whether an input with the given sequence
+// number can spend the output of the given transaction in the next block,
+// based on the confirmations of the transaction. Time based lock times are
+// estimated from the target time per block of the network, as the backends do
+// not return the time of the block of the transaction, so they may be reached
+// a little later than reported.
+func (client *client) RelativeLockTimeReached(ctx context.Context, txHash string, sequence uint32) (bool, error) {
+	if sequence&wire.SequenceLockTimeDisabled != 0 {
+		return true, nil
+	}
+	conf, err := client.Confirmations(ctx, txHash)
+	if err != nil {
+		return false, err
+	}
+
+	lockTime := int64(sequence & wire.SequenceLockTimeMask)
+	if sequence&wire.SequenceLockTimeIsSeconds == 0 {
+		return conf >= lockTime, nil
+	}
+	seconds := lockTime << wire.SequenceLockTimeGranularity
+	return conf*int64(client.NetworkParams().TargetTimePerBlock.Seconds()) >= seconds, nil
+}
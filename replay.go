@@ -0,0 +1,87 @@
+package libbtc
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// SigHashForkID is the sighash flag used by BCH-style fork networks. Signatures
+// carrying it commit to the fork id and to the amounts of the spent outputs
+// (using the BIP143 digest), so they are not valid on the original chain.
+const SigHashForkID = txscript.SigHashType(0x40)
+
+// ErrReplayableTx is returned when replay protection is enabled and an input
+// is not signed with the fork id sighash flag, which would make the
+// transaction valid on more than one chain.
+type ErrReplayableTx struct {
+	Index int
+}
+
+func (err ErrReplayableTx) Error() string {
+	return fmt.Sprintf("transaction can be replayed across chains: input %d is not signed with the fork id sighash flag", err.Index)
+}
+
+// WithReplayProtection builds the transaction for a BCH-style fork network
+// with the given fork id (0 for BCH). Inputs are signed with SigHashForkID,
+// and the transaction is refused if any input is not.
+func WithReplayProtection(forkID uint32) BuildOption {
+	return func(opts *buildOptions) {
+		opts.hashType = txscript.SigHashAll | SigHashForkID | txscript.SigHashType(forkID<<8)
+	}
+}
+
+// hasForkID returns true if the sighash type carries the fork id flag.
+func hasForkID(hashType txscript.SigHashType) bool {
+	return hashType&SigHashForkID == SigHashForkID
+}
+
+// calcSignatureHash returns the sighash of the input at the given index. The
+// BIP143 digest is used for segwit inputs and when the sighash type carries
+// the fork id flag, in which cases the amount of the spent output is committed
+// to.
+func calcSignatureHash(script []byte, sigHashes *txscript.TxSigHashes, hashType txscript.SigHashType, msgTx *wire.MsgTx, index int, amount int64, segwit bool) ([]byte, error) {
+	if segwit || hasForkID(hashType) {
+		return txscript.CalcWitnessSigHash(script, sigHashes, hashType, msgTx, index, amount)
+	}
+	return txscript.CalcSignatureHash(script, hashType, msgTx, index)
+}
+
+// checkReplayProtection checks that every signature of every input of the
+// transaction carries the fork id sighash flag. Signatures are found among the
+// data pushed by the signature script and the witness of the input, wherever
+// the spent script expects them, and every input must have at least one.
+func checkReplayProtection(msgTx *wire.MsgTx) error {
+	for i, txIn := range msgTx.TxIn {
+		stack, err := txscript.PushedData(txIn.SignatureScript)
+		if err != nil {
+			return ErrReplayableTx{Index: i}
+		}
+		signed := false
+		for _, data := range append(stack, txIn.Witness...) {
+			if !isSignature(data) {
+				continue
+			}
+			if !hasForkID(txscript.SigHashType(data[len(data)-1])) {
+				return ErrReplayableTx{Index: i}
+			}
+			signed = true
+		}
+		if !signed {
+			return ErrReplayableTx{Index: i}
+		}
+	}
+	return nil
+}
+
+// isSignature returns true if the data is a DER encoded signature followed by
+// its sighash type.
+func isSignature(data []byte) bool {
+	if len(data) < 2 {
+		return false
+	}
+	_, err := btcec.ParseDERSignature(data[:len(data)-1], btcec.S256())
+	return err == nil
+}
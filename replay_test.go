@@ -0,0 +1,220 @@
+package libbtc_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/clients/mock"
+)
+
+// forkChain is a mock chain of a BCH-style fork network. It accepts and
+// publishes transactions without running their scripts, as the script engine
+// of btcd does not know the fork id digest.
+type forkChain struct {
+	*mock.Chain
+	published []*wire.MsgTx
+}
+
+func (chain *forkChain) TestMempoolAccept(ctx context.Context, stx *wire.MsgTx) error {
+	return nil
+}
+
+func (chain *forkChain) PublishTransaction(ctx context.Context, stx *wire.MsgTx) error {
+	chain.published = append(chain.published, stx.Copy())
+	return nil
+}
+
+var _ = Describe("Replay protection", func() {
+	const forkHashType = txscript.SigHashAll | SigHashForkID
+	params := &chaincfg.RegressionNetParams
+
+	var chain *forkChain
+	var client Client
+	var key *btcec.PrivateKey
+	var to string
+
+	BeforeEach(func() {
+		chain = &forkChain{Chain: mock.NewChain(params)}
+		client = NewClientFromCore(chain)
+		var err error
+		key, err = btcec.NewPrivateKey(btcec.S256())
+		Expect(err).ShouldNot(HaveOccurred())
+		recipient, err := btcec.NewPrivateKey(btcec.S256())
+		Expect(err).ShouldNot(HaveOccurred())
+		address, err := client.PublicKeyToAddress(recipient.PubKey().SerializeCompressed())
+		Expect(err).ShouldNot(HaveOccurred())
+		to = address.EncodeAddress()
+	})
+
+	// fund pays to the address and returns its UTXOs.
+	fund := func(address btcutil.Address) []clients.UTXO {
+		_, err := chain.Fund(address.EncodeAddress(), 100000)
+		Expect(err).ShouldNot(HaveOccurred())
+		chain.Mine(1)
+		utxos, err := client.GetUTXOs(context.Background(), address.EncodeAddress(), 999999, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(utxos).Should(HaveLen(1))
+		return utxos
+	}
+
+	// newMultisig returns a 2-of-2 multisig contract between the key and a
+	// new key, and the new key.
+	newMultisig := func() ([]byte, *btcec.PrivateKey) {
+		remoteKey, err := btcec.NewPrivateKey(btcec.S256())
+		Expect(err).ShouldNot(HaveOccurred())
+		contract, err := txscript.NewScriptBuilder().
+			AddOp(txscript.OP_2).
+			AddData(key.PubKey().SerializeCompressed()).
+			AddData(remoteKey.PubKey().SerializeCompressed()).
+			AddOp(txscript.OP_2).AddOp(txscript.OP_CHECKMULTISIG).
+			Script()
+		Expect(err).ShouldNot(HaveOccurred())
+		return contract, remoteKey
+	}
+
+	// expectForkIDDigests checks that the hashes of the transaction are the
+	// fork id digests of its inputs, which commit to their amounts.
+	expectForkIDDigests := func(tx Tx) {
+		serialized, err := tx.Serialize()
+		Expect(err).ShouldNot(HaveOccurred())
+		msgTx, err := btcutil.NewTxFromBytes(serialized)
+		Expect(err).ShouldNot(HaveOccurred())
+		sigHashes := txscript.NewTxSigHashes(msgTx.MsgTx())
+		for i, request := range tx.SigningRequests() {
+			Expect(request.HashType).Should(Equal(forkHashType))
+			hash, err := txscript.CalcWitnessSigHash(request.Script, sigHashes, forkHashType, msgTx.MsgTx(), i, request.Amount)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(tx.Hashes()[i]).Should(Equal(hash))
+		}
+	}
+
+	// spendMultisig spends the multisig contract with the signatures of both
+	// keys, and returns the published transaction.
+	spendMultisig := func(contract []byte, remoteKey *btcec.PrivateKey, utxos []clients.UTXO) *wire.MsgTx {
+		tx, err := NewTxBuilder(client).Build(context.Background(), *key.PubKey().ToECDSA(), to, contract, 50000, nil, utxos, WithReplayProtection(0))
+		Expect(err).ShouldNot(HaveOccurred())
+		expectForkIDDigests(tx)
+		hash := tx.Hashes()[0]
+		sigs := []InputSig{}
+		for _, signer := range []*btcec.PrivateKey{key, remoteKey} {
+			sig, err := signer.Sign(hash)
+			Expect(err).ShouldNot(HaveOccurred())
+			sigs = append(sigs, InputSig{PubKey: signer.PubKey().SerializeCompressed(), Signature: sig})
+		}
+		Expect(tx.InjectSigsFor(0, sigs)).Should(Succeed())
+		_, err = tx.Submit(context.Background())
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(chain.published).Should(HaveLen(1))
+		return chain.published[0]
+	}
+
+	It("should sign P2PKH inputs with the fork id", func() {
+		address, err := client.PublicKeyToAddress(key.PubKey().SerializeCompressed())
+		Expect(err).ShouldNot(HaveOccurred())
+		tx, err := NewTxBuilder(client).Build(context.Background(), *key.PubKey().ToECDSA(), to, nil, 50000, fund(address), nil, WithReplayProtection(0))
+		Expect(err).ShouldNot(HaveOccurred())
+		expectForkIDDigests(tx)
+		sigs := []*btcec.Signature{}
+		for _, hash := range tx.Hashes() {
+			sig, err := key.Sign(hash)
+			Expect(err).ShouldNot(HaveOccurred())
+			sigs = append(sigs, sig)
+		}
+		Expect(tx.InjectSigs(sigs)).Should(Succeed())
+		_, err = tx.Submit(context.Background())
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(chain.published).Should(HaveLen(1))
+		pushes, err := txscript.PushedData(chain.published[0].TxIn[0].SignatureScript)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(pushes[0][len(pushes[0])-1]).Should(Equal(byte(forkHashType)))
+	})
+
+	It("should sign multisig inputs with the fork id", func() {
+		contract, remoteKey := newMultisig()
+		address, err := btcutil.NewAddressScriptHash(contract, params)
+		Expect(err).ShouldNot(HaveOccurred())
+		msgTx := spendMultisig(contract, remoteKey, fund(address))
+
+		pushes, err := txscript.PushedData(msgTx.TxIn[0].SignatureScript)
+		Expect(err).ShouldNot(HaveOccurred())
+		// The dummy element of OP_CHECKMULTISIG, both signatures and the
+		// contract.
+		Expect(pushes).Should(HaveLen(4))
+		Expect(pushes[0]).Should(BeEmpty())
+		for _, sig := range pushes[1:3] {
+			Expect(sig[len(sig)-1]).Should(Equal(byte(forkHashType)))
+		}
+	})
+
+	It("should sign segwit inputs with the fork id", func() {
+		contract, remoteKey := newMultisig()
+		scriptHash := sha256.Sum256(contract)
+		address, err := btcutil.NewAddressWitnessScriptHash(scriptHash[:], params)
+		Expect(err).ShouldNot(HaveOccurred())
+		msgTx := spendMultisig(contract, remoteKey, fund(address))
+
+		Expect(msgTx.TxIn[0].SignatureScript).Should(BeEmpty())
+		Expect(msgTx.TxIn[0].Witness).Should(HaveLen(4))
+		for _, sig := range msgTx.TxIn[0].Witness[1:3] {
+			Expect(sig[len(sig)-1]).Should(Equal(byte(forkHashType)))
+		}
+	})
+
+	It("should sign the transactions of accounts with the fork id", func() {
+		account := newAccount(chain)
+		account.SetSigHashType(forkHashType)
+		address, err := account.Address()
+		Expect(err).ShouldNot(HaveOccurred())
+		utxos := fund(address)
+
+		_, stx, err := account.BuildTransfer(context.Background(), to, 50000, Fast, false)
+		Expect(err).ShouldNot(HaveOccurred())
+		msgTx, err := btcutil.NewTxFromBytes(stx)
+		Expect(err).ShouldNot(HaveOccurred())
+		txIn := msgTx.MsgTx().TxIn[0]
+		pushes, err := txscript.PushedData(txIn.SignatureScript)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(pushes).Should(HaveLen(2))
+		Expect(pushes[0][len(pushes[0])-1]).Should(Equal(byte(forkHashType)))
+
+		scriptPubKey, err := txscript.PayToAddrScript(address)
+		Expect(err).ShouldNot(HaveOccurred())
+		hash, err := txscript.CalcWitnessSigHash(scriptPubKey, txscript.NewTxSigHashes(msgTx.MsgTx()), forkHashType, msgTx.MsgTx(), 0, utxos[0].Amount)
+		Expect(err).ShouldNot(HaveOccurred())
+		sig, err := btcec.ParseDERSignature(pushes[0][:len(pushes[0])-1], btcec.S256())
+		Expect(err).ShouldNot(HaveOccurred())
+		pubKey, err := btcec.ParsePubKey(pushes[1], btcec.S256())
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(sig.Verify(hash, pubKey)).Should(BeTrue())
+	})
+
+	It("should refuse to finalize transactions with an input signed without the fork id", func() {
+		address, err := client.PublicKeyToAddress(key.PubKey().SerializeCompressed())
+		Expect(err).ShouldNot(HaveOccurred())
+		tx, err := NewTxBuilder(client).Build(context.Background(), *key.PubKey().ToECDSA(), to, nil, 50000, fund(address), nil, WithReplayProtection(0))
+		Expect(err).ShouldNot(HaveOccurred())
+		session, err := tx.Session(time.Time{})
+		Expect(err).ShouldNot(HaveOccurred())
+		sig, err := key.Sign(session.Hashes[0])
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(session.AddSignature(0, sig)).Should(Succeed())
+		_, err = session.Finalize()
+		Expect(err).ShouldNot(HaveOccurred())
+
+		session.HashTypes = []txscript.SigHashType{txscript.SigHashAll}
+		_, err = session.Finalize()
+		Expect(err).Should(Equal(ErrReplayableTx{Index: 0}))
+	})
+})
@@ -0,0 +1,27 @@
+package libbtc
+
+import (
+	"context"
+
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+func (client *client) ImportAddressRescan(ctx context.Context, address string) (<-chan clients.RescanProgress, error) {
+	if err := client.Validate(address); err != nil {
+		return nil, err
+	}
+	rescanner, ok := client.ClientCore.(clients.AddressRescanner)
+	if !ok {
+		return nil, errors.NewErrUnsupportedOperation("ImportAddressRescan", "current")
+	}
+	return rescanner.ImportAddressRescan(ctx, address)
+}
+
+func (client *client) ImportDescriptors(ctx context.Context, descriptors []clients.WalletDescriptor) error {
+	importer, ok := client.ClientCore.(clients.DescriptorImporter)
+	if !ok {
+		return errors.NewErrUnsupportedOperation("ImportDescriptors", "current")
+	}
+	return importer.ImportDescriptors(ctx, descriptors)
+}
@@ -0,0 +1,83 @@
+package libbtc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// ReservationTTL is how long the outputs spent by a transaction sent by an
+// account stay reserved, which covers the time backends take to see the
+// transaction in their mempool.
+const ReservationTTL = 10 * time.Minute
+
+// MaxConflictRetries is the number of times an account sends a transaction
+// again with fresh outputs, when the outputs it selected were already spent.
+const MaxConflictRetries = 3
+
+// reservations are the outputs selected by the transactions of an account,
+// which are not selected by its other transactions until they expire.
+// Transactions select their outputs one at a time, holding the lock of the
+// reservations, so that concurrent transactions never select the same ones.
+type reservations struct {
+	mu       *sync.Mutex
+	selectMu *sync.Mutex
+	reserved map[wire.OutPoint]time.Time
+}
+
+func newReservations() *reservations {
+	return &reservations{
+		mu:       new(sync.Mutex),
+		selectMu: new(sync.Mutex),
+		reserved: map[wire.OutPoint]time.Time{},
+	}
+}
+
+// lock waits until no other transaction is selecting outputs.
+func (r *reservations) lock() {
+	r.selectMu.Lock()
+}
+
+func (r *reservations) unlock() {
+	r.selectMu.Unlock()
+}
+
+func (r *reservations) isReserved(outPoint wire.OutPoint) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	expiry, ok := r.reserved[outPoint]
+	if ok && time.Now().After(expiry) {
+		delete(r.reserved, outPoint)
+		return false
+	}
+	return ok
+}
+
+// reserve reserves the outputs spent by the transaction.
+func (r *reservations) reserve(msgTx *wire.MsgTx) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	expiry := time.Now().Add(ReservationTTL)
+	for _, txIn := range msgTx.TxIn {
+		r.reserved[txIn.PreviousOutPoint] = expiry
+	}
+}
+
+// release releases the outputs spent by the transaction, when it was not
+// sent.
+func (r *reservations) release(msgTx *wire.MsgTx) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, txIn := range msgTx.TxIn {
+		delete(r.reserved, txIn.PreviousOutPoint)
+	}
+}
+
+// isMempoolConflict returns whether the transaction was rejected because its
+// inputs were already spent.
+func isMempoolConflict(err error) bool {
+	submitErr, ok := err.(*errors.SubmitTxError)
+	return ok && submitErr.Reason == errors.ErrMempoolConflict
+}
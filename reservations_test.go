@@ -0,0 +1,159 @@
+package libbtc_test
+
+import (
+	"context"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/clients/mock"
+)
+
+// gatedChain is a mock chain that holds the transactions it publishes until
+// its gate opens, reporting each of them on held.
+type gatedChain struct {
+	*mock.Chain
+	held chan *wire.MsgTx
+	gate chan struct{}
+}
+
+func (chain *gatedChain) PublishTransaction(ctx context.Context, stx *wire.MsgTx) error {
+	chain.held <- stx
+	<-chain.gate
+	return chain.Chain.PublishTransaction(ctx, stx)
+}
+
+// laggingChain is a mock chain whose outputs lag behind its mempool, like a
+// backend that has not seen the transactions spending them yet, until it
+// rejects a transaction.
+type laggingChain struct {
+	*mock.Chain
+	mu    sync.Mutex
+	utxos []clients.UTXO
+}
+
+func (chain *laggingChain) GetUTXOs(ctx context.Context, address string, limit, confirmations int64) ([]clients.UTXO, error) {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	if chain.utxos != nil {
+		return chain.utxos, nil
+	}
+	return chain.Chain.GetUTXOs(ctx, address, limit, confirmations)
+}
+
+func (chain *laggingChain) TestMempoolAccept(ctx context.Context, stx *wire.MsgTx) error {
+	err := chain.Chain.TestMempoolAccept(ctx, stx)
+	if err != nil {
+		chain.mu.Lock()
+		chain.utxos = nil
+		chain.mu.Unlock()
+	}
+	return err
+}
+
+var _ = Describe("SendTransaction", func() {
+	// fund pays the values to the account and returns the address of a
+	// recipient.
+	fund := func(chain *mock.Chain, account Account, values ...int64) string {
+		address, err := account.Address()
+		Expect(err).ShouldNot(HaveOccurred())
+		for _, value := range values {
+			_, err := chain.Fund(address.EncodeAddress(), value)
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+		chain.Mine(1)
+		key, err := btcec.NewPrivateKey(btcec.S256())
+		Expect(err).ShouldNot(HaveOccurred())
+		to, err := account.PublicKeyToAddress(key.PubKey().SerializeCompressed())
+		Expect(err).ShouldNot(HaveOccurred())
+		return to.EncodeAddress()
+	}
+
+	It("should spend different outputs in concurrent transactions", func() {
+		chain, account := newMockAccount()
+		to := fund(chain, account, 100000, 100000, 100000)
+
+		var wg sync.WaitGroup
+		txHashes := make([]string, 3)
+		errs := make([]error, 3)
+		for i := range txHashes {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				txHashes[i], _, errs[i] = account.Transfer(context.Background(), to, 50000, Fast, false)
+			}(i)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+		Expect(chain.Mempool()).Should(ConsistOf(txHashes))
+
+		spent := map[wire.OutPoint]bool{}
+		for _, txHash := range txHashes {
+			tx, err := chain.RawTransaction(context.Background(), txHash)
+			Expect(err).ShouldNot(HaveOccurred())
+			for _, txIn := range tx.TxIn {
+				Expect(spent).ShouldNot(HaveKey(txIn.PreviousOutPoint))
+				spent[txIn.PreviousOutPoint] = true
+			}
+		}
+	})
+
+	It("should report the outputs reserved by another transaction as an insufficient balance", func() {
+		chain := &gatedChain{
+			Chain: mock.NewChain(&chaincfg.RegressionNetParams),
+			held:  make(chan *wire.MsgTx, 1),
+			gate:  make(chan struct{}),
+		}
+		account := newAccount(chain)
+		to := fund(chain.Chain, account, 100000)
+
+		errs := make(chan error, 1)
+		go func() {
+			_, _, err := account.Transfer(context.Background(), to, 50000, Fast, false)
+			errs <- err
+		}()
+		<-chain.held
+
+		_, _, err := account.Transfer(context.Background(), to, 50000, Fast, false)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("insufficient balance"))
+		Expect(err.Error()).Should(ContainSubstring("current:0"))
+
+		close(chain.gate)
+		Expect(<-errs).ShouldNot(HaveOccurred())
+	})
+
+	It("should send the transaction again with fresh outputs when its outputs are already spent", func() {
+		chain := &laggingChain{Chain: mock.NewChain(&chaincfg.RegressionNetParams)}
+		client := NewClientFromCore(chain)
+		key, err := btcec.NewPrivateKey(btcec.S256())
+		Expect(err).ShouldNot(HaveOccurred())
+		account := NewAccount(client, NewPrivateKeySigner(key.ToECDSA()), nil)
+		to := fund(chain.Chain, account, 100000, 100000)
+		address, err := account.Address()
+		Expect(err).ShouldNot(HaveOccurred())
+		utxos, err := chain.Chain.GetUTXOs(context.Background(), address.EncodeAddress(), 999999, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Another account of the same key, which does not share the
+		// reservations, spends the first output before the backend sees it.
+		other := NewAccount(client, NewPrivateKeySigner(key.ToECDSA()), nil)
+		_, _, err = other.Transfer(context.Background(), to, 50000, Fast, false)
+		Expect(err).ShouldNot(HaveOccurred())
+		chain.utxos = utxos
+
+		txHash, _, err := account.Transfer(context.Background(), to, 40000, Fast, false)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(chain.utxos).Should(BeNil())
+		Expect(chain.Mempool()).Should(HaveLen(2))
+		Expect(chain.Mempool()).Should(ContainElement(txHash))
+	})
+})
@@ -0,0 +1,91 @@
+package libbtc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ScannedOutput is an output paying to one of the scripts scanned by
+// ScanBlocks.
+type ScannedOutput struct {
+	TxHash       string
+	Vout         uint32
+	Amount       int64
+	ScriptPubKey []byte
+	BlockHeight  int64
+	BlockHash    string
+
+	// SpentBy is the hash of the transaction spending the output, if it is
+	// in the scanned blocks. Outputs spent after the scanned blocks are not
+	// detected.
+	SpentBy string
+}
+
+// ScanProgress is called by ScanBlocks once every block is scanned, with the
+// height of the block, the last height scanned, and the number of outputs
+// found so far.
+type ScanProgress func(height, toHeight int64, found int)
+
+func (client *client) ScanBlocks(ctx context.Context, fromHeight, toHeight int64, scripts [][]byte, progress ScanProgress) ([]ScannedOutput, error) {
+	tip, err := client.ChainTip(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if toHeight <= 0 || toHeight > tip.Height {
+		toHeight = tip.Height
+	}
+	if fromHeight < 0 || fromHeight > toHeight {
+		return nil, fmt.Errorf("invalid block range from %d to %d with the chain tip at %d", fromHeight, toHeight, tip.Height)
+	}
+
+	wanted := make(map[string]bool, len(scripts))
+	for _, script := range scripts {
+		wanted[string(script)] = true
+	}
+
+	outputs := []ScannedOutput{}
+	// found indexes the outputs by outpoint, to mark them spent.
+	found := map[wire.OutPoint]int{}
+	for height := fromHeight; height <= toHeight; height++ {
+		select {
+		case <-ctx.Done():
+			return outputs, ctx.Err()
+		default:
+		}
+
+		block, err := client.GetBlock(ctx, strconv.FormatInt(height, 10))
+		if err != nil {
+			return outputs, fmt.Errorf("cannot get block at height %d: %v", height, err)
+		}
+		blockHash := block.BlockHash().String()
+		for _, tx := range block.Transactions {
+			txHash := tx.TxHash()
+			for _, txIn := range tx.TxIn {
+				if i, ok := found[txIn.PreviousOutPoint]; ok {
+					outputs[i].SpentBy = txHash.String()
+				}
+			}
+			for vout, txOut := range tx.TxOut {
+				if !wanted[string(txOut.PkScript)] {
+					continue
+				}
+				found[*wire.NewOutPoint(&txHash, uint32(vout))] = len(outputs)
+				outputs = append(outputs, ScannedOutput{
+					TxHash:       txHash.String(),
+					Vout:         uint32(vout),
+					Amount:       txOut.Value,
+					ScriptPubKey: txOut.PkScript,
+					BlockHeight:  height,
+					BlockHash:    blockHash,
+				})
+			}
+		}
+		if progress != nil {
+			progress(height, toHeight, len(outputs))
+		}
+	}
+	return outputs, nil
+}
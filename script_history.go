@@ -0,0 +1,30 @@
+package libbtc
+
+import (
+	"context"
+	"sort"
+
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+func (client *client) ScriptHistory(ctx context.Context, address string) ([]clients.ScriptTx, error) {
+	if err := client.Validate(address); err != nil {
+		return nil, err
+	}
+	historian, ok := client.ClientCore.(clients.ScriptHistorian)
+	if !ok {
+		return nil, errors.NewErrUnsupportedOperation("ScriptHistory", "current")
+	}
+	history, err := historian.ScriptHistory(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	// Transactions with more confirmations are older, and unconfirmed
+	// transactions have none.
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Confirmations > history[j].Confirmations
+	})
+	return history, nil
+}
@@ -0,0 +1,67 @@
+package libbtc
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// sigHashMask masks the base sighash type, SigHashAll, SigHashNone or
+// SigHashSingle, out of the flags combined with it.
+const sigHashMask = 0x1f
+
+// WithSigHashType sets the sighash type of the input at the given index, which
+// is SigHashAll by default. Inputs are indexed like in WithSequence. Any of
+// SigHashAll, SigHashNone and SigHashSingle can be combined with
+// SigHashAnyOneCanPay, for example to let others add inputs to the
+// transaction. The fork id of WithReplayProtection is added to the type.
+func WithSigHashType(index int, hashType txscript.SigHashType) BuildOption {
+	return func(opts *buildOptions) {
+		opts.hashTypes[index] = hashType
+	}
+}
+
+// inputHashTypes returns the sighash type of every input of the transaction.
+func inputHashTypes(msgTx *wire.MsgTx, options buildOptions) ([]txscript.SigHashType, error) {
+	for index := range options.hashTypes {
+		if index < 0 || index >= len(msgTx.TxIn) {
+			return nil, fmt.Errorf("invalid input index %d for sighash type: transaction has %d inputs", index, len(msgTx.TxIn))
+		}
+	}
+
+	hashTypes := make([]txscript.SigHashType, len(msgTx.TxIn))
+	for i := range msgTx.TxIn {
+		hashType, ok := options.hashTypes[i]
+		if !ok {
+			hashTypes[i] = options.hashType
+			continue
+		}
+		if hasForkID(options.hashType) {
+			hashType |= options.hashType &^ (sigHashMask | txscript.SigHashAnyOneCanPay)
+		}
+		if err := checkSigHashType(msgTx, i, hashType); err != nil {
+			return nil, err
+		}
+		hashTypes[i] = hashType
+	}
+	return hashTypes, nil
+}
+
+// checkSigHashType returns an error if the input at the given index cannot be
+// signed with the sighash type. SigHashSingle signs the output at the index of
+// the input, which must exist, as the signature would otherwise commit to
+// nothing.
+func checkSigHashType(msgTx *wire.MsgTx, index int, hashType txscript.SigHashType) error {
+	switch hashType & sigHashMask {
+	case txscript.SigHashAll, txscript.SigHashNone:
+		return nil
+	case txscript.SigHashSingle:
+		if index >= len(msgTx.TxOut) {
+			return fmt.Errorf("cannot sign input %d with SigHashSingle: transaction has %d outputs", index, len(msgTx.TxOut))
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid sighash type %#x for input %d", uint32(hashType), index)
+	}
+}
@@ -0,0 +1,52 @@
+package libbtc
+
+import (
+	"context"
+	"crypto/ecdsa"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/libbtc-go/clients"
+)
+
+// Signer signs sighashes with a key that does not need to be held in process
+// memory, for example a key held by an HSM, a remote service or an MPC
+// cluster.
+type Signer interface {
+	// SignHash signs the 32 byte sighash.
+	SignHash(hash []byte) (*btcec.Signature, error)
+
+	// PublicKey returns the public key of the signer.
+	PublicKey() *btcec.PublicKey
+}
+
+type privKeySigner struct {
+	privKey *btcec.PrivateKey
+}
+
+// NewPrivateKeySigner returns a Signer for a private key held in memory.
+func NewPrivateKeySigner(privateKey *ecdsa.PrivateKey) Signer {
+	return &privKeySigner{(*btcec.PrivateKey)(privateKey)}
+}
+
+func (signer *privKeySigner) SignHash(hash []byte) (*btcec.Signature, error) {
+	return signer.privKey.Sign(hash)
+}
+
+func (signer *privKeySigner) PublicKey() *btcec.PublicKey {
+	return signer.privKey.PubKey()
+}
+
+// TxSigner signs transactions with keys that are held outside of libbtc, for
+// example by a wallet daemon.
+type TxSigner interface {
+	// SignTransaction returns the transaction with the signature scripts of
+	// every input spending an output owned by the signer.
+	SignTransaction(ctx context.Context, msgTx *wire.MsgTx) (*wire.MsgTx, error)
+}
+
+// NewBtcWalletSigner returns a TxSigner backed by a btcwallet instance. The
+// wallet must be unlocked for signing to succeed.
+func NewBtcWalletSigner(host, user, password string, cert []byte) (TxSigner, error) {
+	return clients.NewBtcWalletClientCore(host, user, password, cert)
+}
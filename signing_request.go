@@ -0,0 +1,37 @@
+package libbtc
+
+import (
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// SigningRequest describes what is being signed for an input of a
+// transaction, so that threshold signers can authorize a sighash before
+// signing it.
+type SigningRequest struct {
+	// Index is the index of the input in the transaction.
+	Index int `json:"index"`
+
+	// OutPoint and Amount describe the output spent by the input.
+	OutPoint wire.OutPoint `json:"outPoint"`
+	Amount   int64         `json:"amount"`
+
+	// Script is the script committed to by the sighash: the public key script
+	// of the master wallet, or the redeem script of the contract.
+	Script []byte `json:"script"`
+
+	HashType txscript.SigHashType `json:"hashType"`
+	Hash     []byte               `json:"hash"`
+
+	// PubKey is the serialized public key expected to sign the input, which
+	// identifies the key to derive.
+	PubKey []byte `json:"pubKey"`
+
+	// Contract is true if the input spends a contract output rather than a
+	// master wallet output.
+	Contract bool `json:"contract"`
+
+	// Segwit is true if the input spends a P2WSH output, whose sighash is
+	// computed as defined in BIP143.
+	Segwit bool `json:"segwit,omitempty"`
+}
@@ -0,0 +1,252 @@
+package libbtc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// SessionSignature is a signature collected by a SigningSession, along with
+// the time at which it was added.
+type SessionSignature struct {
+	Signature []byte    `json:"signature"`
+	AddedAt   time.Time `json:"addedAt"`
+}
+
+// SigningSession holds an unsigned transaction while the signatures for its
+// inputs are collected, for example from the participants of a distributed
+// signing protocol. It only contains exported fields so that it can be
+// persisted as JSON and audited.
+type SigningSession struct {
+	// UnsignedTx is the serialized transaction without signature scripts.
+	UnsignedTx []byte `json:"unsignedTx"`
+	// Hashes are the sighashes that need to be signed, one per input.
+	Hashes [][]byte `json:"hashes"`
+	// PubKeys are the serialized public keys that are expected to sign each
+	// input.
+	PubKeys [][]byte `json:"pubKeys"`
+	// Contract is the redeem script of the inputs at index MasterInputs and
+	// above, it is nil if the transaction only spends master wallet UTXOs.
+	Contract     []byte `json:"contract"`
+	MasterInputs int    `json:"masterInputs"`
+	// Signatures are the signatures collected so far, one per input, nil
+	// until the signature of the input has been added.
+	Signatures []*SessionSignature `json:"signatures"`
+	Expiry     time.Time           `json:"expiry"`
+	// HashType is the sighash type of the signatures, SigHashAll if unset.
+	HashType txscript.SigHashType `json:"hashType"`
+	// HashTypes are the sighash types of the signatures of every input, when
+	// they differ. HashType is used for the inputs it does not cover.
+	HashTypes []txscript.SigHashType `json:"hashTypes,omitempty"`
+	// ContractPushes are pushed before the contract in the signature scripts
+	// of the inputs spending the contract, to select the branch being spent.
+	ContractPushes [][]byte `json:"contractPushes"`
+	// Contracts are the redeem scripts of every input, nil for master wallet
+	// inputs, when the transaction spends several contracts. They are pushed
+	// after the InputContractPushes of the input, and Contract and
+	// ContractPushes are ignored.
+	Contracts           [][]byte   `json:"contracts,omitempty"`
+	InputContractPushes [][][]byte `json:"inputContractPushes,omitempty"`
+	// Segwit is true for the inputs spending P2WSH contract outputs, which
+	// are satisfied by their witness rather than their signature script.
+	Segwit []bool `json:"segwit,omitempty"`
+}
+
+// NewSigningSession returns a SigningSession for the unsigned transaction.
+// There must be exactly one hash and one public key per input.
+func NewSigningSession(msgTx *wire.MsgTx, hashes, pubKeys [][]byte, contract []byte, masterInputs int, expiry time.Time) (*SigningSession, error) {
+	if len(hashes) != len(msgTx.TxIn) || len(pubKeys) != len(msgTx.TxIn) {
+		return nil, fmt.Errorf("expected %d hashes and public keys, got %d hashes and %d public keys", len(msgTx.TxIn), len(hashes), len(pubKeys))
+	}
+	if masterInputs < 0 || masterInputs > len(msgTx.TxIn) {
+		return nil, fmt.Errorf("invalid number of master inputs %d: transaction has %d inputs", masterInputs, len(msgTx.TxIn))
+	}
+
+	var txBuffer bytes.Buffer
+	txBuffer.Grow(msgTx.SerializeSize())
+	if err := msgTx.Serialize(&txBuffer); err != nil {
+		return nil, err
+	}
+	return &SigningSession{
+		UnsignedTx:   txBuffer.Bytes(),
+		Hashes:       hashes,
+		PubKeys:      pubKeys,
+		Contract:     contract,
+		MasterInputs: masterInputs,
+		Signatures:   make([]*SessionSignature, len(msgTx.TxIn)),
+		Expiry:       expiry,
+		HashType:     txscript.SigHashAll,
+	}, nil
+}
+
+// TxHash returns the hash of the transaction being signed. The hash does not
+// change once the transaction is signed, as signature scripts are not part of
+// it.
+func (session *SigningSession) TxHash() (string, error) {
+	msgTx, err := session.msgTx()
+	if err != nil {
+		return "", err
+	}
+	return msgTx.TxHash().String(), nil
+}
+
+// Expired returns true if the session has expired.
+func (session *SigningSession) Expired() bool {
+	return !session.Expiry.IsZero() && time.Now().After(session.Expiry)
+}
+
+// Missing returns the indexes of the inputs that have not been signed yet.
+func (session *SigningSession) Missing() []int {
+	missing := []int{}
+	for i, sig := range session.Signatures {
+		if sig == nil {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+// AddSignature adds the signature of the input at the given index, after
+// checking that it was produced by the expected signer.
+func (session *SigningSession) AddSignature(index int, sig *btcec.Signature) error {
+	if session.Expired() {
+		return fmt.Errorf("signing session expired at %s", session.Expiry)
+	}
+	if index < 0 || index >= len(session.Signatures) {
+		return fmt.Errorf("invalid input index %d: transaction has %d inputs", index, len(session.Signatures))
+	}
+	if err := session.verify(index, sig); err != nil {
+		return err
+	}
+	session.Signatures[index] = &SessionSignature{
+		Signature: sig.Serialize(),
+		AddedAt:   time.Now(),
+	}
+	return nil
+}
+
+// AddSignatures adds the signatures of every input, in order.
+func (session *SigningSession) AddSignatures(sigs []*btcec.Signature) error {
+	if len(sigs) != len(session.Signatures) {
+		return fmt.Errorf("expected %d signatures, got %d", len(session.Signatures), len(sigs))
+	}
+	for i, sig := range sigs {
+		if err := session.AddSignature(i, sig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks that the session has not expired, and that every input has
+// a valid signature from its expected signer.
+func (session *SigningSession) Validate() error {
+	if session.Expired() {
+		return fmt.Errorf("signing session expired at %s", session.Expiry)
+	}
+	if len(session.Hashes) != len(session.Signatures) || len(session.PubKeys) != len(session.Signatures) {
+		return fmt.Errorf("malformed signing session: %d hashes, %d public keys and %d signatures", len(session.Hashes), len(session.PubKeys), len(session.Signatures))
+	}
+	if missing := session.Missing(); len(missing) > 0 {
+		return fmt.Errorf("missing signatures for inputs %v", missing)
+	}
+	for i, sessionSig := range session.Signatures {
+		sig, err := btcec.ParseDERSignature(sessionSig.Signature, btcec.S256())
+		if err != nil {
+			return err
+		}
+		if err := session.verify(i, sig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Finalize validates the session and returns the transaction with the
+// signature scripts of every input, ready to be published.
+func (session *SigningSession) Finalize() (*wire.MsgTx, error) {
+	if err := session.Validate(); err != nil {
+		return nil, err
+	}
+	msgTx, err := session.msgTx()
+	if err != nil {
+		return nil, err
+	}
+	for i, sessionSig := range session.Signatures {
+		stack := [][]byte{append(sessionSig.Signature, byte(session.hashType(i))), session.PubKeys[i]}
+		if contract, pushes := session.contract(i); contract != nil {
+			stack = append(stack, pushes...)
+			stack = append(stack, contract)
+		}
+		if i < len(session.Segwit) && session.Segwit[i] {
+			msgTx.TxIn[i].Witness = stack
+			continue
+		}
+		sigScript, err := pushScript(stack)
+		if err != nil {
+			return nil, err
+		}
+		msgTx.TxIn[i].SignatureScript = sigScript
+	}
+	if hasForkID(session.HashType) {
+		if err := checkReplayProtection(msgTx); err != nil {
+			return nil, err
+		}
+	}
+	return msgTx, nil
+}
+
+// contract returns the contract spent by the input at the given index and the
+// data pushed before it, or nil if the input spends a master wallet UTXO.
+func (session *SigningSession) contract(index int) ([]byte, [][]byte) {
+	if session.Contracts != nil {
+		var pushes [][]byte
+		if index < len(session.InputContractPushes) {
+			pushes = session.InputContractPushes[index]
+		}
+		if index < len(session.Contracts) {
+			return session.Contracts[index], pushes
+		}
+		return nil, nil
+	}
+	if index >= session.MasterInputs {
+		return session.Contract, session.ContractPushes
+	}
+	return nil, nil
+}
+
+// hashType returns the sighash type of the signature of the input at the given
+// index.
+func (session *SigningSession) hashType(index int) txscript.SigHashType {
+	if index < len(session.HashTypes) && session.HashTypes[index] != 0 {
+		return session.HashTypes[index]
+	}
+	if session.HashType == 0 {
+		return txscript.SigHashAll
+	}
+	return session.HashType
+}
+
+func (session *SigningSession) verify(index int, sig *btcec.Signature) error {
+	pubKey, err := btcec.ParsePubKey(session.PubKeys[index], btcec.S256())
+	if err != nil {
+		return err
+	}
+	if !sig.Verify(session.Hashes[index], pubKey) {
+		return fmt.Errorf("invalid signature for input %d: expected signer %s", index, hex.EncodeToString(session.PubKeys[index]))
+	}
+	return nil
+}
+
+func (session *SigningSession) msgTx() (*wire.MsgTx, error) {
+	msgTx := new(wire.MsgTx)
+	if err := msgTx.Deserialize(bytes.NewReader(session.UnsignedTx)); err != nil {
+		return nil, err
+	}
+	return msgTx, nil
+}
@@ -0,0 +1,121 @@
+package libbtc
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+)
+
+// SlaveVersion is the format of a slave script. Funds sent to the slave
+// addresses of a version are moved to the addresses of another one with
+// MigrateSlave.
+type SlaveVersion uint8
+
+// SlaveVersion values.
+const (
+	// SlaveV0 is the original format, <nonce> OP_DROP followed by the P2PKH
+	// script of the master public key hash, returned by SlaveScript.
+	SlaveV0 = SlaveVersion(iota)
+	// SlaveV1 pushes the version before the nonce, <1> <nonce> OP_2DROP
+	// followed by the P2PKH script, so that later formats never produce the
+	// same scripts.
+	SlaveV1
+)
+
+// LatestSlaveVersion is the format new slave scripts should use.
+const LatestSlaveVersion = SlaveV1
+
+func (client *client) SlaveScriptVersion(mpkh, nonce []byte, version SlaveVersion) ([]byte, error) {
+	b := txscript.NewScriptBuilder()
+	switch version {
+	case SlaveV0:
+		return client.SlaveScript(mpkh, nonce)
+	case SlaveV1:
+		b.AddData([]byte{byte(version)})
+		b.AddData(nonce)
+		b.AddOp(txscript.OP_2DROP)
+	default:
+		return nil, fmt.Errorf("unknown slave script version %d", version)
+	}
+	b.AddOp(txscript.OP_DUP)
+	b.AddOp(txscript.OP_HASH160)
+	b.AddData(mpkh)
+	b.AddOp(txscript.OP_EQUALVERIFY)
+	b.AddOp(txscript.OP_CHECKSIG)
+	return b.Script()
+}
+
+func (client *client) SlaveAddressVersion(mpkh, nonce []byte, version SlaveVersion) (btcutil.Address, error) {
+	script, err := client.SlaveScriptVersion(mpkh, nonce, version)
+	if err != nil {
+		return nil, err
+	}
+	return btcutil.NewAddressScriptHash(script, client.NetworkParams())
+}
+
+// MigrateSlave moves the funds of the P2SH and P2WSH slave addresses of every
+// other version, for the public key hash of the account and the nonce, to the
+// P2SH slave address of the new version, at the standard speed. It returns
+// the hashes of the transactions, one per version that had funds.
+func (account *account) MigrateSlave(ctx context.Context, oldNonce []byte, newVersion SlaveVersion) ([]string, error) {
+	pubKey, err := account.SerializedPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	mpkh := btcutil.Hash160(pubKey)
+	to, err := account.SlaveAddressVersion(mpkh, oldNonce, newVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	txHashes := []string{}
+	for version := SlaveV0; version <= LatestSlaveVersion; version++ {
+		if version == newVersion {
+			continue
+		}
+		script, err := account.SlaveScriptVersion(mpkh, oldNonce, version)
+		if err != nil {
+			return nil, err
+		}
+		balance, err := account.scriptBalance(ctx, script)
+		if err != nil {
+			return nil, err
+		}
+		if balance == 0 {
+			continue
+		}
+		account.Logger.Infof("migrating %d SAT from version %d slave to version %d", balance, version, newVersion)
+		txHash, _, err := account.SweepScript(ctx, script, to.EncodeAddress(), nil, Standard)
+		if err != nil {
+			return txHashes, err
+		}
+		txHashes = append(txHashes, txHash)
+	}
+	return txHashes, nil
+}
+
+// scriptBalance returns the balance of both the P2SH and the P2WSH addresses
+// of the redeem script, including unconfirmed outputs.
+func (account *account) scriptBalance(ctx context.Context, script []byte) (int64, error) {
+	p2sh, err := btcutil.NewAddressScriptHash(script, account.NetworkParams())
+	if err != nil {
+		return 0, err
+	}
+	scriptHash := sha256.Sum256(script)
+	p2wsh, err := btcutil.NewAddressWitnessScriptHash(scriptHash[:], account.NetworkParams())
+	if err != nil {
+		return 0, err
+	}
+	var balance int64
+	for _, address := range []btcutil.Address{p2sh, p2wsh} {
+		addressBalance, err := account.Balance(ctx, address.EncodeAddress(), 0)
+		if err != nil {
+			return 0, err
+		}
+		balance += addressBalance
+	}
+	return balance, nil
+}
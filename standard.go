@@ -0,0 +1,149 @@
+package libbtc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/errors"
+	"github.com/renproject/libbtc-go/networks"
+)
+
+// The standardness rules enforced by the relay policy of bitcoind. Nodes do
+// not relay transactions breaking them, even though they are valid in blocks.
+const (
+	// MinRelayTxRate is the minimum fee rate, in satoshis per virtual byte.
+	MinRelayTxRate = 1
+	// MaxStandardTxWeight is the maximum weight of a transaction.
+	MaxStandardTxWeight = 400000
+	// MaxStandardScriptSigSize is the maximum size of a signature script.
+	MaxStandardScriptSigSize = 1650
+	// MaxStandardDataCarrierSize is the maximum size of the script of an
+	// OP_RETURN output, of which there can only be one.
+	MaxStandardDataCarrierSize = 83
+	// MaxStandardTxVersion is the highest standard transaction version.
+	MaxStandardTxVersion = 2
+)
+
+// ErrNonStandardTx is returned, before a transaction is published, when it
+// breaks a standardness rule. It unwraps to ErrDustOutput, ErrInsufficientFee
+// or ErrNonStandard, as do the errors of backends rejecting it for the same
+// reason.
+type ErrNonStandardTx struct {
+	Reason error
+	Msg    string
+}
+
+func (err ErrNonStandardTx) Error() string {
+	return fmt.Sprintf("non-standard transaction: %s", err.Msg)
+}
+
+// Unwrap returns the reason the transaction is not standard.
+func (err ErrNonStandardTx) Unwrap() error {
+	return err.Reason
+}
+
+func nonStandard(reason error, format string, args ...interface{}) error {
+	return ErrNonStandardTx{Reason: reason, Msg: fmt.Sprintf(format, args...)}
+}
+
+// CheckStandard returns an ErrNonStandardTx if the transaction breaks a
+// standardness rule: its version, weight, signature scripts, output scripts
+// and dust outputs are checked. The values of the outputs spent by the inputs
+// are needed to check the fee rate against MinRelayTxRate, which is skipped
+// when they are nil.
+func CheckStandard(msgTx *wire.MsgTx, inputValues []int64, params *chaincfg.Params) error {
+	if msgTx.Version < 1 || msgTx.Version > MaxStandardTxVersion {
+		return nonStandard(ErrNonStandard, "version %d", msgTx.Version)
+	}
+	if weight := msgTx.SerializeSizeStripped()*(witnessScaleFactor-1) + msgTx.SerializeSize(); weight > MaxStandardTxWeight {
+		return nonStandard(ErrNonStandard, "weight %d is above %d", weight, MaxStandardTxWeight)
+	}
+
+	for i, txIn := range msgTx.TxIn {
+		if size := len(txIn.SignatureScript); size > MaxStandardScriptSigSize {
+			return nonStandard(ErrNonStandard, "signature script of input %d is %d bytes, above %d", i, size, MaxStandardScriptSigSize)
+		}
+		if !txscript.IsPushOnlyScript(txIn.SignatureScript) {
+			return nonStandard(ErrNonStandard, "signature script of input %d is not push only", i)
+		}
+	}
+
+	dataOutputs := 0
+	for i, txOut := range msgTx.TxOut {
+		switch txscript.GetScriptClass(txOut.PkScript) {
+		case txscript.NonStandardTy:
+			return nonStandard(ErrNonStandard, "script of output %d is not standard", i)
+		case txscript.NullDataTy:
+			if size := len(txOut.PkScript); size > MaxStandardDataCarrierSize {
+				return nonStandard(ErrNonStandard, "OP_RETURN script of output %d is %d bytes, above %d", i, size, MaxStandardDataCarrierSize)
+			}
+			if dataOutputs++; dataOutputs > 1 {
+				return nonStandard(ErrNonStandard, "more than one OP_RETURN output")
+			}
+			continue
+		}
+		if dust := outputDust(txOut, params); txOut.Value < dust {
+			return nonStandard(ErrDustOutput, "value %d of output %d is below dust (%d)", txOut.Value, i, dust)
+		}
+	}
+
+	if inputValues == nil {
+		return nil
+	}
+	var inputValue int64
+	for _, value := range inputValues {
+		inputValue += value
+	}
+	fee := msgTxFee(msgTx, inputValue)
+	if minFee := int64(txVSize(msgTx)) * MinRelayTxRate; fee < minFee {
+		return nonStandard(ErrInsufficientFee, "fee %d is below the minimum relay fee %d", fee, minFee)
+	}
+	return nil
+}
+
+// outputDust returns the value below which the output is dust, which is three
+// times the fee of spending it at MinRelayTxRate as computed by bitcoind, or
+// the dust of the network if it is registered in the networks package.
+func outputDust(txOut *wire.TxOut, params *chaincfg.Params) int64 {
+	if network, ok := networks.Lookup(params); ok {
+		return network.Dust
+	}
+	// The input spending the output is made of the outpoint, the sequence
+	// number and a signature script of 107 bytes, which is discounted when
+	// it is a witness.
+	size := txOut.SerializeSize() + 32 + 4 + 4 + 1
+	if txscript.IsWitnessProgram(txOut.PkScript) {
+		size += 107 / witnessScaleFactor
+	} else {
+		size += 107
+	}
+	return 3 * MinRelayTxRate * int64(size)
+}
+
+func (client *client) TestMempoolAccept(ctx context.Context, stx *wire.MsgTx) error {
+	tester, ok := client.ClientCore.(clients.MempoolAcceptTester)
+	if !ok {
+		return errors.NewErrUnsupportedOperation("TestMempoolAccept", "current")
+	}
+	return tester.TestMempoolAccept(ctx, stx)
+}
+
+// preflight checks that the transaction is standard before it is published,
+// and that it is accepted by the mempool of the backend if it can be tested.
+func (client *client) preflight(ctx context.Context, msgTx *wire.MsgTx) error {
+	if err := CheckStandard(msgTx, nil, client.NetworkParams()); err != nil {
+		return err
+	}
+	// Failing to test the transaction, for example because the node is too
+	// old to support it, leaves publishing it to decide.
+	if err := client.TestMempoolAccept(ctx, msgTx); err != nil {
+		if _, ok := err.(*errors.SubmitTxError); ok {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Names of the buckets nested in the bucket of each wallet.
+var (
+	utxosBucket     = []byte("utxos")
+	historyBucket   = []byte("history")
+	addressesBucket = []byte("addresses")
+)
+
+type boltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore returns a Store backed by the bbolt database at the given path,
+// creating it if it does not exist. Each wallet is stored in its own bucket.
+func NewBoltStore(path string) (Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &boltStore{db}, nil
+}
+
+func (store *boltStore) PutUTXOs(wallet, address string, set UTXOSet) error {
+	return store.put(wallet, utxosBucket, address, set)
+}
+
+func (store *boltStore) UTXOs(wallet, address string) (UTXOSet, bool, error) {
+	set := UTXOSet{}
+	ok := false
+	err := store.db.View(func(tx *bolt.Tx) error {
+		bucket := walletBucket(tx, wallet, utxosBucket)
+		if bucket == nil {
+			return nil
+		}
+		data := bucket.Get([]byte(address))
+		if data == nil {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(data, &set)
+	})
+	return set, ok, err
+}
+
+func (store *boltStore) PutTx(wallet string, tx TxRecord) error {
+	return store.put(wallet, historyBucket, tx.TxHash, tx)
+}
+
+func (store *boltStore) History(wallet string) ([]TxRecord, error) {
+	history := []TxRecord{}
+	err := store.db.View(func(tx *bolt.Tx) error {
+		bucket := walletBucket(tx, wallet, historyBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_, data []byte) error {
+			record := TxRecord{}
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+			history = append(history, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].Height < history[j].Height })
+	return history, nil
+}
+
+func (store *boltStore) PutAddress(wallet string, meta AddressMeta) error {
+	return store.put(wallet, addressesBucket, meta.Address, meta)
+}
+
+func (store *boltStore) Addresses(wallet string) ([]AddressMeta, error) {
+	addresses := []AddressMeta{}
+	err := store.db.View(func(tx *bolt.Tx) error {
+		bucket := walletBucket(tx, wallet, addressesBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_, data []byte) error {
+			meta := AddressMeta{}
+			if err := json.Unmarshal(data, &meta); err != nil {
+				return err
+			}
+			addresses = append(addresses, meta)
+			return nil
+		})
+	})
+	return addresses, err
+}
+
+func (store *boltStore) Invalidate(wallet string, height int64) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{utxosBucket, historyBucket, addressesBucket} {
+			bucket := walletBucket(tx, wallet, name)
+			if bucket == nil {
+				continue
+			}
+
+			// Keys are collected first, as deleting while iterating skips
+			// entries.
+			stale := [][]byte{}
+			err := bucket.ForEach(func(key, data []byte) error {
+				entry := struct {
+					Height int64 `json:"height"`
+				}{}
+				if err := json.Unmarshal(data, &entry); err != nil {
+					return err
+				}
+				if entry.Height >= height {
+					stale = append(stale, append([]byte{}, key...))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, key := range stale {
+				if err := bucket.Delete(key); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (store *boltStore) Close() error {
+	return store.db.Close()
+}
+
+func (store *boltStore) put(wallet string, name []byte, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return store.db.Update(func(tx *bolt.Tx) error {
+		walletBkt, err := tx.CreateBucketIfNotExists([]byte(wallet))
+		if err != nil {
+			return err
+		}
+		bucket, err := walletBkt.CreateBucketIfNotExists(name)
+		if err != nil {
+			return fmt.Errorf("cannot create bucket %s for wallet %s: %v", name, wallet, err)
+		}
+		return bucket.Put([]byte(key), data)
+	})
+}
+
+// walletBucket returns the named bucket of the wallet, or nil if nothing has
+// been stored in it yet.
+func walletBucket(tx *bolt.Tx, wallet string, name []byte) *bolt.Bucket {
+	walletBkt := tx.Bucket([]byte(wallet))
+	if walletBkt == nil {
+		return nil
+	}
+	return walletBkt.Bucket(name)
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"github.com/renproject/libbtc-go/clients"
+)
+
+// AddressMeta describes an address of a wallet that has been used on the
+// chain.
+type AddressMeta struct {
+	Address string `json:"address"`
+	Purpose uint32 `json:"purpose"`
+	Account uint32 `json:"account"`
+	Chain   uint32 `json:"chain"`
+	Index   uint32 `json:"index"`
+	Label   string `json:"label"`
+
+	// Height is the height of the chain tip when the address was first seen
+	// used.
+	Height int64 `json:"height"`
+}
+
+// TxRecord is a transaction in the history of a wallet.
+type TxRecord struct {
+	TxHash    string   `json:"txHash"`
+	Addresses []string `json:"addresses"`
+	// Amount is the net value received by the wallet, negative for spends.
+	Amount int64 `json:"amount"`
+
+	// Height is the height of the block including the transaction, or of the
+	// chain tip when it was seen if it is unconfirmed.
+	Height    int64 `json:"height"`
+	Confirmed bool  `json:"confirmed"`
+}
+
+// UTXOSet is the set of unspent outputs of an address at a height.
+type UTXOSet struct {
+	UTXOs []clients.UTXO `json:"utxos"`
+
+	// Height is the height of the chain tip when the outputs were fetched.
+	Height int64 `json:"height"`
+}
+
+// Store caches the UTXOs, transaction history and address metadata of
+// wallets, so that their addresses do not need to be rescanned on every
+// start. Every entry is recorded along with a block height, so that the
+// entries affected by a reorg can be invalidated.
+type Store interface {
+	// PutUTXOs replaces the unspent outputs of the address.
+	PutUTXOs(wallet, address string, set UTXOSet) error
+
+	// UTXOs returns the unspent outputs of the address, and false if they
+	// are not cached.
+	UTXOs(wallet, address string) (UTXOSet, bool, error)
+
+	// PutTx adds or replaces a transaction of the history of the wallet.
+	PutTx(wallet string, tx TxRecord) error
+
+	// History returns the transactions of the wallet, ordered by height.
+	History(wallet string) ([]TxRecord, error)
+
+	// PutAddress adds or replaces the metadata of an address of the wallet.
+	PutAddress(wallet string, meta AddressMeta) error
+
+	// Addresses returns the metadata of every address of the wallet.
+	Addresses(wallet string) ([]AddressMeta, error)
+
+	// Invalidate removes every entry of the wallet recorded at or above the
+	// given height, for example the first height orphaned by a reorg.
+	Invalidate(wallet string, height int64) error
+
+	// Close releases the resources of the store.
+	Close() error
+}
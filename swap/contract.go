@@ -0,0 +1,151 @@
+package swap
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+)
+
+// LockTimeThreshold is the value below which a lock time is a block height,
+// and above which it is a unix timestamp.
+const LockTimeThreshold = 500000000
+
+// Contract is a hashed timelock contract. It can be redeemed by the redeemer
+// with the preimage of the secret hash, or refunded to the refunder once the
+// lock time has passed.
+type Contract struct {
+	SecretHash  [32]byte
+	RedeemerPKH []byte
+	RefunderPKH []byte
+	LockTime    int64
+
+	// Script is the redeem script of the contract:
+	//
+	//	OP_IF
+	//		OP_SIZE 32 OP_EQUALVERIFY OP_SHA256 <secretHash> OP_EQUALVERIFY
+	//		OP_DUP OP_HASH160 <redeemerPKH>
+	//	OP_ELSE
+	//		<lockTime> OP_CHECKLOCKTIMEVERIFY OP_DROP
+	//		OP_DUP OP_HASH160 <refunderPKH>
+	//	OP_ENDIF
+	//	OP_EQUALVERIFY OP_CHECKSIG
+	Script []byte
+}
+
+// NewContract returns the contract paying to the redeemer on reveal of the
+// preimage of the SHA256 secret hash, or back to the refunder after the lock
+// time. The public key hashes are the Hash160 of the public keys serialized
+// as the accounts serialize them.
+func NewContract(secretHash [32]byte, redeemerPKH, refunderPKH []byte, lockTime int64) (*Contract, error) {
+	if len(redeemerPKH) != 20 || len(refunderPKH) != 20 {
+		return nil, fmt.Errorf("invalid public key hashes: expected 20 bytes, got %d and %d", len(redeemerPKH), len(refunderPKH))
+	}
+	if lockTime <= 0 || lockTime > 0xFFFFFFFF {
+		return nil, fmt.Errorf("invalid lock time %d", lockTime)
+	}
+
+	builder := txscript.NewScriptBuilder()
+	builder.AddOp(txscript.OP_IF)
+	builder.AddOp(txscript.OP_SIZE)
+	builder.AddInt64(32)
+	builder.AddOp(txscript.OP_EQUALVERIFY)
+	builder.AddOp(txscript.OP_SHA256)
+	builder.AddData(secretHash[:])
+	builder.AddOp(txscript.OP_EQUALVERIFY)
+	builder.AddOp(txscript.OP_DUP)
+	builder.AddOp(txscript.OP_HASH160)
+	builder.AddData(redeemerPKH)
+	builder.AddOp(txscript.OP_ELSE)
+	builder.AddInt64(lockTime)
+	builder.AddOp(txscript.OP_CHECKLOCKTIMEVERIFY)
+	builder.AddOp(txscript.OP_DROP)
+	builder.AddOp(txscript.OP_DUP)
+	builder.AddOp(txscript.OP_HASH160)
+	builder.AddData(refunderPKH)
+	builder.AddOp(txscript.OP_ENDIF)
+	builder.AddOp(txscript.OP_EQUALVERIFY)
+	builder.AddOp(txscript.OP_CHECKSIG)
+	script, err := builder.Script()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Contract{
+		SecretHash:  secretHash,
+		RedeemerPKH: redeemerPKH,
+		RefunderPKH: refunderPKH,
+		LockTime:    lockTime,
+		Script:      script,
+	}, nil
+}
+
+// ParseContract parses a contract script, for example one received from the
+// counterparty of a swap, and checks that it is a standard contract.
+func ParseContract(script []byte) (*Contract, error) {
+	pushes, err := txscript.PushedData(script)
+	if err != nil {
+		return nil, err
+	}
+	var secretHash [32]byte
+	pkhs := [][]byte{}
+	for _, push := range pushes {
+		switch len(push) {
+		case 32:
+			copy(secretHash[:], push)
+		case 20:
+			pkhs = append(pkhs, push)
+		}
+	}
+	if len(pkhs) != 2 {
+		return nil, fmt.Errorf("not a swap contract")
+	}
+	lockTime, err := parseLockTime(script)
+	if err != nil {
+		return nil, err
+	}
+
+	// The script is rebuilt from its parameters to check that nothing else
+	// is part of it.
+	contract, err := NewContract(secretHash, pkhs[0], pkhs[1], lockTime)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(contract.Script, script) {
+		return nil, fmt.Errorf("not a swap contract")
+	}
+	return contract, nil
+}
+
+// lockTimeOffset is the offset of the lock time push in a contract script.
+const lockTimeOffset = 64
+
+// parseLockTime returns the lock time pushed by the contract script, before
+// OP_CHECKLOCKTIMEVERIFY.
+func parseLockTime(script []byte) (int64, error) {
+	if len(script) <= lockTimeOffset {
+		return 0, fmt.Errorf("not a swap contract")
+	}
+	op := script[lockTimeOffset]
+	if op >= txscript.OP_1 && op <= txscript.OP_16 {
+		return int64(op - (txscript.OP_1 - 1)), nil
+	}
+
+	// Larger numbers are pushed as little endian data of up to 5 bytes.
+	size := int(op)
+	if size < 1 || size > 5 || len(script) <= lockTimeOffset+size {
+		return 0, fmt.Errorf("not a swap contract")
+	}
+	var lockTime int64
+	for i, b := range script[lockTimeOffset+1 : lockTimeOffset+1+size] {
+		lockTime |= int64(b) << uint(8*i)
+	}
+	return lockTime, nil
+}
+
+// Address returns the P2SH address of the contract.
+func (contract *Contract) Address(params *chaincfg.Params) (btcutil.Address, error) {
+	return btcutil.NewAddressScriptHash(contract.Script, params)
+}
@@ -0,0 +1,135 @@
+package swap
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go"
+)
+
+// ErrLockTimeNotReached indicates that a contract cannot be refunded yet.
+var ErrLockTimeNotReached = errors.New("lock time not reached")
+
+// ErrInvalidSecret indicates that a secret is not the preimage of the secret
+// hash of a contract.
+var ErrInvalidSecret = errors.New("invalid secret")
+
+// BuildInitiate builds and signs a transaction funding the contract with the
+// given value from the account. The returned transaction is not published.
+func BuildInitiate(ctx context.Context, account libbtc.Account, contract *Contract, value int64, speed libbtc.TxExecutionSpeed) (string, []byte, error) {
+	address, err := contract.Address(account.NetworkParams())
+	if err != nil {
+		return "", nil, err
+	}
+	return account.BuildTransfer(ctx, address.EncodeAddress(), value, speed, false)
+}
+
+// BuildRedeem builds and signs a transaction spending every output of the
+// contract to the account, revealing the secret. The account must be the
+// redeemer of the contract.
+func BuildRedeem(ctx context.Context, account libbtc.Account, contract *Contract, secret []byte, speed libbtc.TxExecutionSpeed) (string, []byte, error) {
+	if len(secret) != 32 || sha256.Sum256(secret) != contract.SecretHash {
+		return "", nil, ErrInvalidSecret
+	}
+	return buildSpend(ctx, account, contract, contract.RedeemerPKH, speed, nil, nil, func(builder *txscript.ScriptBuilder) {
+		builder.AddData(secret)
+		builder.AddOp(txscript.OP_TRUE)
+	})
+}
+
+// BuildRefund builds and signs a transaction spending every output of the
+// contract back to the account once its lock time has passed, which is
+// checked against the chain tip. The account must be the refunder of the
+// contract.
+func BuildRefund(ctx context.Context, account libbtc.Account, contract *Contract, speed libbtc.TxExecutionSpeed) (string, []byte, error) {
+	tip, err := account.ChainTip(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if !lockTimeReached(contract.LockTime, tip.Height, tip.MedianTime) {
+		return "", nil, ErrLockTimeNotReached
+	}
+
+	// OP_CHECKLOCKTIMEVERIFY requires the lock time of the transaction to be
+	// at least the one of the contract, and the inputs to be non-final.
+	return buildSpend(ctx, account, contract, contract.RefunderPKH, speed,
+		func(msgTx *wire.MsgTx) {
+			msgTx.LockTime = uint32(contract.LockTime)
+		},
+		func(txIn *wire.TxIn) {
+			txIn.Sequence = wire.MaxTxInSequenceNum - 1
+		},
+		func(builder *txscript.ScriptBuilder) {
+			builder.AddOp(txscript.OP_FALSE)
+		},
+	)
+}
+
+// lockTimeReached returns whether a transaction with the given lock time can
+// be included in the block following the chain tip.
+func lockTimeReached(lockTime, tipHeight, tipMedianTime int64) bool {
+	if lockTime < LockTimeThreshold {
+		return lockTime <= tipHeight
+	}
+	return lockTime < tipMedianTime
+}
+
+func buildSpend(
+	ctx context.Context,
+	account libbtc.Account,
+	contract *Contract,
+	spenderPKH []byte,
+	speed libbtc.TxExecutionSpeed,
+	updateMsgTx func(*wire.MsgTx),
+	updateTxIn func(*wire.TxIn),
+	f func(*txscript.ScriptBuilder),
+) (string, []byte, error) {
+	pubKey, err := account.SerializedPublicKey()
+	if err != nil {
+		return "", nil, err
+	}
+	if pkh := btcutil.Hash160(pubKey); string(pkh) != string(spenderPKH) {
+		return "", nil, fmt.Errorf("account cannot spend the contract: public key hash %x, expected %x", pkh, spenderPKH)
+	}
+	me, err := account.Address()
+	if err != nil {
+		return "", nil, err
+	}
+	address, err := contract.Address(account.NetworkParams())
+	if err != nil {
+		return "", nil, err
+	}
+	balance, err := account.Balance(ctx, address.EncodeAddress(), 0)
+	if err != nil {
+		return "", nil, err
+	}
+	if balance == 0 {
+		return "", nil, fmt.Errorf("contract %s is not funded", address.EncodeAddress())
+	}
+
+	return account.BuildTransaction(
+		ctx,
+		contract.Script,
+		speed,
+		updateTxIn,
+		func(msgTx *wire.MsgTx) bool {
+			script, err := txscript.PayToAddrScript(me)
+			if err != nil {
+				return false
+			}
+			msgTx.AddTxOut(wire.NewTxOut(balance, script))
+			if updateMsgTx != nil {
+				updateMsgTx(msgTx)
+			}
+			return true
+		},
+		f,
+		nil,
+		true,
+	)
+}
@@ -0,0 +1,237 @@
+package libbtc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/clients"
+)
+
+// SweepScript spends every unspent output of both the P2SH and the P2WSH
+// addresses of the redeem script to the given address, minus the fee, and
+// returns the transaction hash and the fee. Every input is signed with the
+// account, and satisfies the redeem script with the signature, the public key
+// and the template pushes, in that order, like TxBuilder spends contracts.
+func (account *account) SweepScript(ctx context.Context, redeemScript []byte, to string, template [][]byte, speed TxExecutionSpeed) (string, int64, error) {
+	witnessTemplate := func(sig, pubKey []byte) wire.TxWitness {
+		return append(wire.TxWitness{sig, pubKey}, template...)
+	}
+	return account.SpendContract(ctx, redeemScript, witnessTemplate, to, speed)
+}
+
+// SpendContract spends every unspent output of both the P2SH and the P2WSH
+// addresses of the redeem script to the given address, minus the fee, and
+// returns the transaction hash and the fee. The witness template returns the
+// stack satisfying the redeem script, given the SigHashAll signature of the
+// account and its serialized public key, and the redeem script is added after
+// it. The stack is the witness of P2WSH inputs, and is pushed in the signature
+// script of P2SH inputs. The template is also called with placeholder
+// signatures to estimate the fee, so the size of the stack must not depend on
+// the signature.
+func (account *account) SpendContract(ctx context.Context, redeemScript []byte, witnessTemplate func(sig, pubKey []byte) wire.TxWitness, to string, speed TxExecutionSpeed) (string, int64, error) {
+	spend, err := account.contractSpend(ctx, redeemScript, witnessTemplate)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(spend.utxos) == 0 {
+		p2sh, err := btcutil.NewAddressScriptHash(redeemScript, account.NetworkParams())
+		if err != nil {
+			return "", 0, err
+		}
+		return "", 0, NewErrInsufficientBalance(p2sh.EncodeAddress(), NetworkDust(account.NetworkParams()), 0)
+	}
+	return account.spendContracts(ctx, []contractSpend{spend}, to, speed)
+}
+
+// contractSpend is the unspent outputs of a redeem script, along with the
+// witness template satisfying it, spent by spendContracts.
+type contractSpend struct {
+	redeemScript    []byte
+	witnessTemplate func(sig, pubKey []byte) wire.TxWitness
+	utxos           []clients.UTXO
+	// segwit is whether each UTXO is owned by the P2WSH address of the
+	// redeem script, rather than the P2SH one.
+	segwit []bool
+}
+
+// balance returns the total value of the UTXOs of the spend.
+func (spend contractSpend) balance() int64 {
+	var balance int64
+	for _, utxo := range spend.utxos {
+		balance += utxo.Amount
+	}
+	return balance
+}
+
+// contractSpend returns every unspent output of both the P2SH and the P2WSH
+// addresses of the redeem script.
+func (account *account) contractSpend(ctx context.Context, redeemScript []byte, witnessTemplate func(sig, pubKey []byte) wire.TxWitness) (contractSpend, error) {
+	p2sh, err := btcutil.NewAddressScriptHash(redeemScript, account.NetworkParams())
+	if err != nil {
+		return contractSpend{}, err
+	}
+	scriptHash := sha256.Sum256(redeemScript)
+	p2wsh, err := btcutil.NewAddressWitnessScriptHash(scriptHash[:], account.NetworkParams())
+	if err != nil {
+		return contractSpend{}, err
+	}
+
+	spend := contractSpend{redeemScript: redeemScript, witnessTemplate: witnessTemplate}
+	for _, address := range []btcutil.Address{p2sh, p2wsh} {
+		utxos, err := account.GetUTXOs(ctx, address.EncodeAddress(), 999999, 0)
+		if err != nil {
+			return contractSpend{}, err
+		}
+		for _, utxo := range utxos {
+			spend.utxos = append(spend.utxos, utxo)
+			spend.segwit = append(spend.segwit, address == p2wsh)
+		}
+	}
+	return spend, nil
+}
+
+// spendContracts spends the UTXOs of every spend to the given address, minus
+// the fee, in a single transaction, as described by SpendContract.
+func (account *account) spendContracts(ctx context.Context, spends []contractSpend, to string, speed TxExecutionSpeed) (string, int64, error) {
+	toAddr, err := btcutil.DecodeAddress(to, account.NetworkParams())
+	if err != nil {
+		return "", 0, err
+	}
+	toScript, err := txscript.PayToAddrScript(toAddr)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// The inputs are described by the spend of their contract, and its
+	// UTXO at the same index.
+	msgTx := wire.NewMsgTx(2)
+	inputSpends := []contractSpend{}
+	utxos := []clients.UTXO{}
+	segwit := []bool{}
+	var balance int64
+	for _, spend := range spends {
+		for i, utxo := range spend.utxos {
+			hash, err := chainhash.NewHashFromStr(utxo.TxHash)
+			if err != nil {
+				return "", 0, err
+			}
+			msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, utxo.Vout), nil, nil))
+			inputSpends = append(inputSpends, spend)
+			utxos = append(utxos, utxo)
+			segwit = append(segwit, spend.segwit[i])
+			balance += utxo.Amount
+		}
+	}
+	msgTx.AddTxOut(wire.NewTxOut(balance, toScript))
+
+	serializedPublicKey, err := account.SerializedPublicKey()
+	if err != nil {
+		return "", 0, err
+	}
+	setInputScripts := func(msgTx *wire.MsgTx, sigs [][]byte) error {
+		for i, txIn := range msgTx.TxIn {
+			pushes := append([][]byte{}, inputSpends[i].witnessTemplate(sigs[i], serializedPublicKey)...)
+			pushes = append(pushes, inputSpends[i].redeemScript)
+			if segwit[i] {
+				txIn.Witness = pushes
+				continue
+			}
+			builder := txscript.NewScriptBuilder()
+			for _, push := range pushes {
+				builder.AddData(push)
+			}
+			sigScript, err := builder.Script()
+			if err != nil {
+				return err
+			}
+			txIn.SignatureScript = sigScript
+		}
+		return nil
+	}
+
+	// Estimate the fee with placeholder signatures, so that the signer is not
+	// asked to sign twice.
+	placeholders := make([][]byte, len(utxos))
+	for i := range placeholders {
+		placeholders[i] = make([]byte, maxSigSize)
+	}
+	txCopy := msgTx.Copy()
+	if err := setInputScripts(txCopy, placeholders); err != nil {
+		return "", 0, err
+	}
+	rate, err := account.TxRate(ctx, speed)
+	if err != nil {
+		return "", 0, err
+	}
+	txFee := int64(txVSize(txCopy)) * rate
+	if maxFee := NetworkMaxFee(account.NetworkParams()); txFee > maxFee {
+		txFee = maxFee
+	}
+	if dust := NetworkDust(account.NetworkParams()); balance-txFee < dust {
+		p2sh, err := btcutil.NewAddressScriptHash(spends[0].redeemScript, account.NetworkParams())
+		if err != nil {
+			return "", 0, err
+		}
+		return "", 0, NewErrInsufficientBalance(p2sh.EncodeAddress(), txFee+dust, balance)
+	}
+	msgTx.TxOut[0].Value = balance - txFee
+	if err := checkFee(txFee, msgTx.TxOut[0].Value, account.MaxFeePercent); err != nil {
+		return "", 0, err
+	}
+
+	sigs := make([][]byte, len(utxos))
+	sigHashes := txscript.NewTxSigHashes(msgTx)
+	for i := range msgTx.TxIn {
+		var hash []byte
+		redeemScript := inputSpends[i].redeemScript
+		if segwit[i] {
+			hash, err = txscript.CalcWitnessSigHash(redeemScript, sigHashes, txscript.SigHashAll, msgTx, i, utxos[i].Amount)
+		} else {
+			hash, err = txscript.CalcSignatureHash(redeemScript, txscript.SigHashAll, msgTx, i)
+		}
+		if err != nil {
+			return "", 0, err
+		}
+		sig, err := account.Signer.SignHash(hash)
+		if err != nil {
+			return "", 0, err
+		}
+		sigs[i] = append(sig.Serialize(), byte(txscript.SigHashAll))
+	}
+	if err := setInputScripts(msgTx, sigs); err != nil {
+		return "", 0, err
+	}
+
+	for i := range msgTx.TxIn {
+		scriptPubKey, err := hex.DecodeString(utxos[i].ScriptPubKey)
+		if err != nil {
+			return "", 0, err
+		}
+		engine, err := txscript.NewEngine(scriptPubKey, msgTx, i,
+			txscript.StandardVerifyFlags, txscript.NewSigCache(10),
+			sigHashes, utxos[i].Amount)
+		if err != nil {
+			return "", 0, err
+		}
+		if err := engine.Execute(); err != nil {
+			return "", 0, err
+		}
+	}
+
+	amounts := make([]int64, len(utxos))
+	for i, utxo := range utxos {
+		amounts[i] = utxo.Amount
+	}
+	if err := CheckStandard(msgTx, amounts, account.NetworkParams()); err != nil {
+		return "", 0, err
+	}
+	if err := account.PublishTransaction(ctx, msgTx); err != nil {
+		return "", 0, err
+	}
+	return msgTx.TxHash().String(), txFee, nil
+}
@@ -0,0 +1,148 @@
+package libbtc
+
+import (
+	"context"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/clients"
+)
+
+// MaxSweepInputs is the maximum number of inputs of the transactions sweeping
+// slave addresses, which keeps them well below the maximum standard size.
+const MaxSweepInputs = 200
+
+// SweepSlaves spends the funds of the slave addresses of the nonces, in every
+// slave script version and both P2SH and P2WSH, to the address of the account,
+// in as few transactions as possible. Slaves holding less than the threshold
+// are left alone, so that they are not swept at a loss. It returns the hashes
+// of the transactions, which are the ones sent before the error if sending
+// one fails.
+func (account *account) SweepSlaves(ctx context.Context, nonces [][]byte, threshold int64, speed TxExecutionSpeed) ([]string, error) {
+	pubKey, err := account.SerializedPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	mpkh := btcutil.Hash160(pubKey)
+	to, err := account.Address()
+	if err != nil {
+		return nil, err
+	}
+	// Every version of slave scripts is satisfied by a P2PKH signature.
+	witnessTemplate := func(sig, pubKey []byte) wire.TxWitness {
+		return wire.TxWitness{sig, pubKey}
+	}
+
+	txHashes := []string{}
+	batch := []contractSpend{}
+	inputs := 0
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		txHash, _, err := account.spendContracts(ctx, batch, to.EncodeAddress(), speed)
+		if err != nil {
+			return err
+		}
+		account.Logger.Infof("swept %d slave inputs in tx %s", inputs, txHash)
+		txHashes = append(txHashes, txHash)
+		batch, inputs = []contractSpend{}, 0
+		return nil
+	}
+
+	for _, nonce := range nonces {
+		for version := SlaveV0; version <= LatestSlaveVersion; version++ {
+			script, err := account.SlaveScriptVersion(mpkh, nonce, version)
+			if err != nil {
+				return txHashes, err
+			}
+			spend, err := account.contractSpend(ctx, script, witnessTemplate)
+			if err != nil {
+				return txHashes, err
+			}
+			if len(spend.utxos) == 0 || spend.balance() < threshold {
+				continue
+			}
+			// Slaves with more UTXOs than fit in a transaction are swept
+			// with the ones that fit.
+			if len(spend.utxos) > MaxSweepInputs {
+				spend.utxos, spend.segwit = spend.utxos[:MaxSweepInputs], spend.segwit[:MaxSweepInputs]
+			}
+			if inputs+len(spend.utxos) > MaxSweepInputs {
+				if err := send(); err != nil {
+					return txHashes, err
+				}
+			}
+			batch = append(batch, spend)
+			inputs += len(spend.utxos)
+		}
+	}
+	if err := send(); err != nil {
+		return txHashes, err
+	}
+	return txHashes, nil
+}
+
+// NonceSource returns the nonces of the slave addresses swept by a Sweeper,
+// for example the nonces of the deposits a gateway is waiting for.
+type NonceSource interface {
+	Nonces(ctx context.Context) ([][]byte, error)
+}
+
+// Sweeper periodically sweeps the slave addresses of the nonces of a
+// NonceSource to the address of an account.
+type Sweeper interface {
+	// Sweep sweeps the slave addresses now, and returns the hashes of the
+	// transactions.
+	Sweep(ctx context.Context) ([]string, error)
+}
+
+type sweeper struct {
+	account   Account
+	nonces    NonceSource
+	threshold int64
+	speed     TxExecutionSpeed
+	logger    Logger
+}
+
+// NewSweeper returns a Sweeper sweeping the slave addresses of the account,
+// with SweepSlaves, at the given interval until the context is done. Errors
+// are logged, and the slaves are swept again at the next interval.
+func NewSweeper(ctx context.Context, account Account, nonces NonceSource, interval time.Duration, threshold int64, speed TxExecutionSpeed, logger Logger) Sweeper {
+	if logger == nil {
+		logger = clients.NopLogger()
+	}
+	sweeper := &sweeper{
+		account:   account,
+		nonces:    nonces,
+		threshold: threshold,
+		speed:     speed,
+		logger:    logger,
+	}
+	go sweeper.run(ctx, interval)
+	return sweeper
+}
+
+func (sweeper *sweeper) Sweep(ctx context.Context) ([]string, error) {
+	nonces, err := sweeper.nonces.Nonces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sweeper.account.SweepSlaves(ctx, nonces, sweeper.threshold, sweeper.speed)
+}
+
+func (sweeper *sweeper) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := sweeper.Sweep(ctx); err != nil {
+				sweeper.logger.Errorf("cannot sweep slave addresses: %v", err)
+			}
+		}
+	}
+}
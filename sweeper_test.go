@@ -0,0 +1,63 @@
+package libbtc_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go"
+
+	"github.com/btcsuite/btcutil"
+)
+
+var _ = Describe("SweepSlaves", func() {
+	It("should sweep the slaves above the threshold in a single transaction", func() {
+		chain, account := newMockAccount()
+		pubKey, err := account.SerializedPublicKey()
+		Expect(err).ShouldNot(HaveOccurred())
+		mpkh := btcutil.Hash160(pubKey)
+
+		nonces := [][]byte{[]byte("nonce 1"), []byte("nonce 2"), []byte("nonce 3")}
+		slave, err := account.SlaveAddress(mpkh, nonces[0])
+		Expect(err).ShouldNot(HaveOccurred())
+		segwitSlave, err := account.SlaveAddressSegwit(mpkh, nonces[1])
+		Expect(err).ShouldNot(HaveOccurred())
+		smallSlave, err := account.SlaveAddress(mpkh, nonces[2])
+		Expect(err).ShouldNot(HaveOccurred())
+		for address, value := range map[string]int64{
+			slave.EncodeAddress():       100000,
+			segwitSlave.EncodeAddress(): 200000,
+			smallSlave.EncodeAddress():  1000,
+		} {
+			_, err := chain.Fund(address, value)
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+		chain.Mine(1)
+
+		txHashes, err := account.SweepSlaves(context.Background(), nonces, 10000, Fast)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(txHashes).Should(HaveLen(1))
+		Expect(chain.Mempool()).Should(ConsistOf(txHashes[0]))
+		tx, err := chain.RawTransaction(context.Background(), txHashes[0])
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(tx.TxIn).Should(HaveLen(2))
+
+		address, err := account.Address()
+		Expect(err).ShouldNot(HaveOccurred())
+		balance, err := account.Balance(context.Background(), address.EncodeAddress(), 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(balance).Should(BeNumerically(">", 290000))
+		Expect(balance).Should(BeNumerically("<", 300000))
+		utxos, err := account.GetUTXOs(context.Background(), smallSlave.EncodeAddress(), 999999, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(utxos).Should(HaveLen(1))
+	})
+
+	It("should not send anything when no slave is funded", func() {
+		chain, account := newMockAccount()
+		txHashes, err := account.SweepSlaves(context.Background(), [][]byte{[]byte("nonce")}, 0, Fast)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(txHashes).Should(BeEmpty())
+		Expect(chain.Published()).Should(BeEmpty())
+	})
+})
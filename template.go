@@ -0,0 +1,91 @@
+package libbtc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// TemplateOutput is an output of a TxTemplate.
+type TemplateOutput struct {
+	Address string `json:"address"`
+	Value   int64  `json:"value"`
+}
+
+// TxTemplate is a set of outputs that is defined once and paid repeatedly,
+// for example a recurring payout. Every time the template is sent, the
+// transaction is funded from the current UTXOs of the account, with fresh
+// change and fees.
+type TxTemplate struct {
+	Outputs []TemplateOutput `json:"outputs"`
+}
+
+// NewTxTemplate returns a TxTemplate paying the given outputs.
+func NewTxTemplate(outputs ...TemplateOutput) TxTemplate {
+	return TxTemplate{Outputs: outputs}
+}
+
+// Total returns the sum of the values of the outputs of the template.
+func (template TxTemplate) Total() int64 {
+	var total int64
+	for _, output := range template.Outputs {
+		total += output.Value
+	}
+	return total
+}
+
+// TxOuts returns a new set of transaction outputs for the template. It fails
+// if an address is not valid for the network or a value is below dust.
+func (template TxTemplate) TxOuts(params *chaincfg.Params) ([]*wire.TxOut, error) {
+	if len(template.Outputs) == 0 {
+		return nil, fmt.Errorf("transaction template has no outputs")
+	}
+	txOuts := make([]*wire.TxOut, 0, len(template.Outputs))
+	for i, output := range template.Outputs {
+		if dust := NetworkDust(params); output.Value < dust {
+			return nil, fmt.Errorf("template's %d output value (%d) is less than the minimum value (%d)", i, output.Value, dust)
+		}
+		address, err := btcutil.DecodeAddress(output.Address, params)
+		if err != nil {
+			return nil, err
+		}
+		script, err := txscript.PayToAddrScript(address)
+		if err != nil {
+			return nil, err
+		}
+		txOuts = append(txOuts, wire.NewTxOut(output.Value, script))
+	}
+	return txOuts, nil
+}
+
+// TransferTemplate pays the outputs of the template from the account.
+func (account *account) TransferTemplate(ctx context.Context, template TxTemplate, speed TxExecutionSpeed) (string, int64, error) {
+	txOuts, err := template.TxOuts(account.NetworkParams())
+	if err != nil {
+		return "", 0, err
+	}
+	return account.SendTransaction(ctx, nil, speed, nil, addTxOuts(txOuts), nil, nil, false)
+}
+
+// BuildTransferTemplate builds and signs a transaction paying the outputs of
+// the template from the account, without publishing it.
+func (account *account) BuildTransferTemplate(ctx context.Context, template TxTemplate, speed TxExecutionSpeed) (string, []byte, error) {
+	txOuts, err := template.TxOuts(account.NetworkParams())
+	if err != nil {
+		return "", nil, err
+	}
+	return account.BuildTransaction(ctx, nil, speed, nil, addTxOuts(txOuts), nil, nil, false)
+}
+
+func addTxOuts(txOuts []*wire.TxOut) func(*wire.MsgTx) bool {
+	return func(tx *wire.MsgTx) bool {
+		for _, txOut := range txOuts {
+			tx.AddTxOut(wire.NewTxOut(txOut.Value, txOut.PkScript))
+		}
+		return true
+	}
+}
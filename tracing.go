@@ -0,0 +1,186 @@
+package libbtc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// Span is an operation being traced.
+type Span interface {
+	// End ends the span, marking it as failed if err is not nil.
+	End(err error)
+}
+
+// Tracer starts the spans of the operations of clients and accounts. Adapters
+// to tracing libraries, such as OpenTelemetry, implement it and are installed
+// with SetTracer. No spans are recorded by default.
+type Tracer interface {
+	// Start starts a span as a child of the span of the context, if any, and
+	// returns the context holding the new span.
+	Start(ctx context.Context, name string, attributes map[string]string) (context.Context, Span)
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string, attributes map[string]string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(err error) {}
+
+var (
+	tracerMu = new(sync.RWMutex)
+	tracer   = Tracer(noopTracer{})
+)
+
+// SetTracer installs the tracer used by every client and account. A nil
+// tracer disables tracing.
+func SetTracer(t Tracer) {
+	tracerMu.Lock()
+	defer tracerMu.Unlock()
+	if t == nil {
+		t = noopTracer{}
+	}
+	tracer = t
+}
+
+func startSpan(ctx context.Context, name string, attributes map[string]string) (context.Context, Span) {
+	tracerMu.RLock()
+	defer tracerMu.RUnlock()
+	return tracer.Start(ctx, name, attributes)
+}
+
+// traceStep runs a step of building a transaction in its own span, with the
+// context of the transaction holding the span while the step runs.
+func traceStep(tx *tx, name string, f func() error) error {
+	ctx := tx.ctx
+	stepCtx, span := startSpan(ctx, name, nil)
+	tx.ctx = stepCtx
+	err := f()
+	tx.ctx = ctx
+	span.End(err)
+	return err
+}
+
+// The calls to the backend of a client are traced by the client, so that
+// every backend is traced without the optional interfaces of the backend
+// being hidden.
+
+func (client *client) startBackendSpan(ctx context.Context, method string) (context.Context, Span) {
+	return startSpan(ctx, "libbtc.Client."+method, map[string]string{
+		"libbtc.backend": fmt.Sprintf("%T", client.ClientCore),
+		"libbtc.network": client.NetworkParams().Name,
+	})
+}
+
+func (client *client) GetUTXOs(ctx context.Context, address string, limit, confirmations int64) (utxos []clients.UTXO, err error) {
+	ctx, span := client.startBackendSpan(ctx, "GetUTXOs")
+	defer func() { span.End(err) }()
+	return client.ClientCore.GetUTXOs(ctx, address, limit, confirmations)
+}
+
+func (client *client) GetUTXO(ctx context.Context, txHash string, vout uint32) (utxo clients.UTXO, err error) {
+	ctx, span := client.startBackendSpan(ctx, "GetUTXO")
+	defer func() { span.End(err) }()
+	return client.ClientCore.GetUTXO(ctx, txHash, vout)
+}
+
+func (client *client) Confirmations(ctx context.Context, txHash string) (confirmations int64, err error) {
+	ctx, span := client.startBackendSpan(ctx, "Confirmations")
+	defer func() { span.End(err) }()
+	return client.ClientCore.Confirmations(ctx, txHash)
+}
+
+func (client *client) ScriptFunded(ctx context.Context, address string, value int64) (funded bool, received int64, err error) {
+	ctx, span := client.startBackendSpan(ctx, "ScriptFunded")
+	defer func() { span.End(err) }()
+	return client.ClientCore.ScriptFunded(ctx, address, value)
+}
+
+func (client *client) ScriptRedeemed(ctx context.Context, address string, value int64) (redeemed bool, balance int64, err error) {
+	ctx, span := client.startBackendSpan(ctx, "ScriptRedeemed")
+	defer func() { span.End(err) }()
+	return client.ClientCore.ScriptRedeemed(ctx, address, value)
+}
+
+func (client *client) ScriptRedemption(ctx context.Context, address string, value int64) (redemption clients.Redemption, err error) {
+	ctx, span := client.startBackendSpan(ctx, "ScriptRedemption")
+	defer func() { span.End(err) }()
+	finder, ok := client.ClientCore.(clients.RedemptionFinder)
+	if !ok {
+		return clients.Redemption{}, errors.NewErrUnsupportedOperation("ScriptRedemption", "current")
+	}
+	return finder.ScriptRedemption(ctx, address, value)
+}
+
+func (client *client) ScriptSpent(ctx context.Context, script, spender string) (spent bool, txHash string, err error) {
+	ctx, span := client.startBackendSpan(ctx, "ScriptSpent")
+	defer func() { span.End(err) }()
+	return client.ClientCore.ScriptSpent(ctx, script, spender)
+}
+
+func (client *client) ScriptSpendDetails(ctx context.Context, script, spender string) (spend clients.ScriptSpend, spent bool, err error) {
+	ctx, span := client.startBackendSpan(ctx, "ScriptSpendDetails")
+	defer func() { span.End(err) }()
+	finder, ok := client.ClientCore.(clients.ScriptSpendFinder)
+	if !ok {
+		return clients.ScriptSpend{}, false, errors.NewErrUnsupportedOperation("ScriptSpendDetails", "current")
+	}
+	return finder.ScriptSpendDetails(ctx, script, spender)
+}
+
+func (client *client) ChainTip(ctx context.Context) (tip clients.ChainTip, err error) {
+	ctx, span := client.startBackendSpan(ctx, "ChainTip")
+	defer func() { span.End(err) }()
+	fetcher, ok := client.ClientCore.(clients.ChainTipFetcher)
+	if !ok {
+		return clients.ChainTip{}, errors.NewErrUnsupportedOperation("ChainTip", "current")
+	}
+	return fetcher.ChainTip(ctx)
+}
+
+func (client *client) RawTransaction(ctx context.Context, txHash string) (msgTx *wire.MsgTx, err error) {
+	ctx, span := client.startBackendSpan(ctx, "RawTransaction")
+	defer func() { span.End(err) }()
+	fetcher, ok := client.ClientCore.(clients.TxFetcher)
+	if !ok {
+		return nil, errors.NewErrUnsupportedOperation("RawTransaction", "current")
+	}
+	return fetcher.RawTransaction(ctx, txHash)
+}
+
+func (client *client) GetBlockHeader(ctx context.Context, hashOrHeight string) (header *wire.BlockHeader, err error) {
+	ctx, span := client.startBackendSpan(ctx, "GetBlockHeader")
+	defer func() { span.End(err) }()
+	fetcher, ok := client.ClientCore.(clients.HeaderFetcher)
+	if !ok {
+		return nil, errors.NewErrUnsupportedOperation("GetBlockHeader", "current")
+	}
+	return fetcher.GetBlockHeader(ctx, hashOrHeight)
+}
+
+func (client *client) GetBlock(ctx context.Context, hashOrHeight string) (block *wire.MsgBlock, err error) {
+	ctx, span := client.startBackendSpan(ctx, "GetBlock")
+	defer func() { span.End(err) }()
+	fetcher, ok := client.ClientCore.(clients.BlockFetcher)
+	if !ok {
+		return nil, errors.NewErrUnsupportedOperation("GetBlock", "current")
+	}
+	return fetcher.GetBlock(ctx, hashOrHeight)
+}
+
+func (client *client) PublishTransaction(ctx context.Context, signedTransaction *wire.MsgTx) (err error) {
+	ctx, span := client.startBackendSpan(ctx, "PublishTransaction")
+	defer func() { span.End(err) }()
+	if err := client.preflight(ctx, signedTransaction); err != nil {
+		return err
+	}
+	return client.ClientCore.PublishTransaction(ctx, signedTransaction)
+}
@@ -6,21 +6,31 @@ import (
 	"encoding/hex"
 	"fmt"
 
+	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/clients"
 )
 
 const BitcoinDust = 600
 const MaxBitcoinFee = int64(10000)
 
+// maxSigSize is the size of a DER encoded signature with a high R value,
+// followed by the sighash type.
+const maxSigSize = 73
+
 type tx struct {
-	receiveValues   []int64
-	scriptPublicKey []byte
-	account         *account
-	msgTx           *wire.MsgTx
-	ctx             context.Context
+	receiveValues []int64
+
+	// scriptPublicKeys are the public key scripts of the outputs spent by
+	// every input, which can differ when the account was paid to both its
+	// compressed and uncompressed public key.
+	scriptPublicKeys [][]byte
+	account          *account
+	msgTx            *wire.MsgTx
+	ctx              context.Context
 }
 
 func (account *account) newTx(ctx context.Context, msgtx *wire.MsgTx) *tx {
@@ -31,6 +41,31 @@ func (account *account) newTx(ctx context.Context, msgtx *wire.MsgTx) *tx {
 	}
 }
 
+// transferredValue returns the value received by the recipients of the
+// transaction. The change output is the last one, unless the transaction
+// sends everything.
+func (tx *tx) transferredValue(sendAll bool) int64 {
+	txOuts := tx.msgTx.TxOut
+	if !sendAll {
+		txOuts = txOuts[:len(txOuts)-1]
+	}
+	var value int64
+	for _, txOut := range txOuts {
+		value += txOut.Value
+	}
+	return value
+}
+
+// fee returns the value of the inputs of the transaction that is not spent by
+// its outputs.
+func (tx *tx) fee() int64 {
+	var inputValue int64
+	for _, receiveValue := range tx.receiveValues {
+		inputValue += receiveValue
+	}
+	return msgTxFee(tx.msgTx, inputValue)
+}
+
 func (tx *tx) fund(addr btcutil.Address) error {
 	if addr == nil {
 		var err error
@@ -40,10 +75,16 @@ func (tx *tx) fund(addr btcutil.Address) error {
 		}
 	}
 
+	params := tx.account.NetworkParams()
+	dust, maxFee := NetworkDust(params), NetworkMaxFee(params)
 	var value int64
 	for i, j := range tx.msgTx.TxOut {
-		if j.Value < 600 {
-			return fmt.Errorf("transaction's %d output value (%d) is less than bitcoin's minimum value (%d)", i, j.Value, BitcoinDust)
+		// Null data outputs carry no value and are exempt from dust.
+		if txscript.GetScriptClass(j.PkScript) == txscript.NullDataTy {
+			continue
+		}
+		if j.Value < dust {
+			return fmt.Errorf("transaction's %d output value (%d) is less than the minimum value (%d)", i, j.Value, dust)
 		}
 		value = value + j.Value
 	}
@@ -53,8 +94,9 @@ func (tx *tx) fund(addr btcutil.Address) error {
 		return err
 	}
 
-	if value+MaxBitcoinFee > balance {
-		return NewErrInsufficientBalance(addr.EncodeAddress(), value+MaxBitcoinFee, balance)
+	required := value + maxFee
+	if required > balance {
+		return NewErrInsufficientBalance(addr.EncodeAddress(), required, balance)
 	}
 
 	utxos, err := tx.account.GetUTXOs(tx.ctx, addr.EncodeAddress(), 999999, 0)
@@ -62,36 +104,44 @@ func (tx *tx) fund(addr btcutil.Address) error {
 		return err
 	}
 
+	// The balance counts the outputs reserved by the other transactions of
+	// the account, which this one cannot spend.
+	var reserved int64
 	for _, j := range utxos {
-		ScriptPubKey, err := hex.DecodeString(j.ScriptPubKey)
+		hash, err := chainhash.NewHashFromStr(j.TxHash)
 		if err != nil {
 			return err
 		}
-		if len(tx.scriptPublicKey) == 0 {
-			tx.scriptPublicKey = ScriptPubKey
-		} else {
-			if bytes.Compare(tx.scriptPublicKey, ScriptPubKey) != 0 {
-				continue
-			}
+		outPoint := wire.NewOutPoint(hash, j.Vout)
+		if tx.account.reservations.isReserved(*outPoint) {
+			reserved += j.Amount
+			continue
 		}
-		tx.receiveValues = append(tx.receiveValues, j.Amount)
-		hash, err := chainhash.NewHashFromStr(j.TxHash)
+		added, err := tx.addInput(addr, outPoint, j)
 		if err != nil {
 			return err
 		}
-		tx.msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, j.Vout), []byte{}, [][]byte{}))
+		if !added {
+			continue
+		}
 		value = value - j.Amount
-		if value <= -MaxBitcoinFee {
+		if value <= -maxFee {
 			break
 		}
 	}
 
-	if value <= -MaxBitcoinFee {
-		P2PKHScript, err := txscript.PayToAddrScript(addr)
+	if value <= -maxFee {
+		changeAddr, err := changeAddress(tx.ctx, tx.account.Change, addr)
+		if err != nil {
+			return err
+		}
+		changeScript, err := txscript.PayToAddrScript(changeAddr)
 		if err != nil {
 			return err
 		}
-		tx.msgTx.AddTxOut(wire.NewTxOut(-value, P2PKHScript))
+		tx.msgTx.AddTxOut(wire.NewTxOut(-value, changeScript))
+	} else if reserved > 0 {
+		return NewErrInsufficientBalance(addr.EncodeAddress(), required, balance-reserved)
 	} else {
 		return ErrMismatchedPubKeys
 	}
@@ -104,49 +154,127 @@ func (tx *tx) fundAll(addr btcutil.Address) error {
 		return err
 	}
 	for _, j := range utxos {
-		ScriptPubKey, err := hex.DecodeString(j.ScriptPubKey)
+		hash, err := chainhash.NewHashFromStr(j.TxHash)
 		if err != nil {
 			return err
 		}
-		if len(tx.scriptPublicKey) == 0 {
-			tx.scriptPublicKey = ScriptPubKey
-		} else {
-			if bytes.Compare(tx.scriptPublicKey, ScriptPubKey) != 0 {
-				continue
-			}
+		outPoint := wire.NewOutPoint(hash, j.Vout)
+		if tx.account.reservations.isReserved(*outPoint) {
+			continue
 		}
-		tx.receiveValues = append(tx.receiveValues, j.Amount)
-		hash, err := chainhash.NewHashFromStr(j.TxHash)
+		added, err := tx.addInput(addr, outPoint, j)
 		if err != nil {
 			return err
 		}
-		tx.msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, j.Vout), []byte{}, [][]byte{}))
+		if !added {
+			continue
+		}
 	}
 	return nil
 }
 
-func (tx *tx) sign(f func(*txscript.ScriptBuilder), updateTxIn func(*wire.TxIn), contract []byte) error {
-	var subScript []byte
-	if contract == nil {
-		subScript = tx.scriptPublicKey
-	} else {
-		subScript = contract
+// addInput adds an input spending the output, if the account can sign it: it
+// pays to the address being funded, or to the public key of the account.
+func (tx *tx) addInput(addr btcutil.Address, outPoint *wire.OutPoint, utxo clients.UTXO) (bool, error) {
+	scriptPubKey, err := hex.DecodeString(utxo.ScriptPubKey)
+	if err != nil {
+		return false, err
 	}
-	serializedPublicKey, err := tx.account.SerializedPublicKey()
+	addrScript, err := txscript.PayToAddrScript(addr)
 	if err != nil {
-		return err
+		return false, err
+	}
+	if _, ok := ownerPubKey(scriptPubKey, tx.account.Signer.PublicKey()); !ok && !bytes.Equal(scriptPubKey, addrScript) {
+		return false, nil
+	}
+	tx.receiveValues = append(tx.receiveValues, utxo.Amount)
+	tx.scriptPublicKeys = append(tx.scriptPublicKeys, scriptPubKey)
+	tx.msgTx.AddTxIn(wire.NewTxIn(outPoint, []byte{}, [][]byte{}))
+	return true, nil
+}
+
+// ownerPubKey returns the serialization of the public key that the
+// pay-to-pubkey-hash or pay-to-pubkey script pays to, compressed or
+// uncompressed. It returns false if the script does not pay to the public key.
+func ownerPubKey(scriptPubKey []byte, pubKey *btcec.PublicKey) ([]byte, bool) {
+	for _, serialized := range [][]byte{pubKey.SerializeCompressed(), pubKey.SerializeUncompressed()} {
+		p2pkh, err := txscript.NewScriptBuilder().
+			AddOp(txscript.OP_DUP).AddOp(txscript.OP_HASH160).
+			AddData(btcutil.Hash160(serialized)).
+			AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG).
+			Script()
+		if err != nil {
+			return nil, false
+		}
+		p2pk, err := txscript.NewScriptBuilder().AddData(serialized).AddOp(txscript.OP_CHECKSIG).Script()
+		if err != nil {
+			return nil, false
+		}
+		if bytes.Equal(scriptPubKey, p2pkh) || bytes.Equal(scriptPubKey, p2pk) {
+			return serialized, true
+		}
+	}
+	return nil, false
+}
+
+// pushPubKey returns the public key pushed after the signature in the
+// signature script of the input, which pay-to-pubkey outputs do without.
+func (tx *tx) pushPubKey(index int, contract []byte) ([]byte, error) {
+	if contract == nil {
+		scriptPubKey := tx.scriptPublicKeys[index]
+		if txscript.GetScriptClass(scriptPubKey) == txscript.PubKeyTy {
+			return nil, nil
+		}
+		if pubKey, ok := ownerPubKey(scriptPubKey, tx.account.Signer.PublicKey()); ok {
+			return pubKey, nil
+		}
+	}
+	return tx.account.SerializedPublicKey()
+}
+
+func (tx *tx) sign(f func(*txscript.ScriptBuilder), updateTxIn func(*wire.TxIn), contract []byte) error {
+	hashType := tx.account.SigHashType
+	if hashType == 0 {
+		hashType = txscript.SigHashAll
 	}
 	for i, txin := range tx.msgTx.TxIn {
 		if updateTxIn != nil {
 			updateTxIn(txin)
 		}
-		sig, err := txscript.RawTxInSignature(tx.msgTx, i, subScript, txscript.SigHashAll, tx.account.PrivKey)
+		if err := checkSigHashType(tx.msgTx, i, hashType); err != nil {
+			return err
+		}
+	}
+	// The fork id digest commits to the sequences of every input, so it is
+	// only computed once they are all updated.
+	sigHashes := txscript.NewTxSigHashes(tx.msgTx)
+	for i, txin := range tx.msgTx.TxIn {
+		subScript := contract
+		if contract == nil {
+			subScript = tx.scriptPublicKeys[i]
+		}
+		hash, err := calcSignatureHash(subScript, sigHashes, hashType, tx.msgTx, i, tx.receiveValues[i], false)
+		if err != nil {
+			return err
+		}
+		sig, err := tx.account.Signer.SignHash(hash)
+		if err != nil {
+			return err
+		}
+		// The script engine cannot verify signatures of the fork id digest,
+		// so they are checked here.
+		if hasForkID(hashType) && !sig.Verify(hash, tx.account.Signer.PublicKey()) {
+			return fmt.Errorf("invalid signature for input %d", i)
+		}
+		pubKey, err := tx.pushPubKey(i, contract)
 		if err != nil {
 			return err
 		}
 		builder := txscript.NewScriptBuilder()
-		builder.AddData(sig)
-		builder.AddData(serializedPublicKey)
+		builder.AddData(append(sig.Serialize(), byte(hashType)))
+		if pubKey != nil {
+			builder.AddData(pubKey)
+		}
 		if f != nil {
 			f(builder)
 		}
@@ -162,47 +290,63 @@ func (tx *tx) sign(f func(*txscript.ScriptBuilder), updateTxIn func(*wire.TxIn),
 	return nil
 }
 
-func (tx *tx) estimateSTXSize(f func(*txscript.ScriptBuilder), updateTxIn func(*wire.TxIn), contract []byte) (int, error) {
-	var subScript []byte
-	if contract == nil {
-		subScript = tx.scriptPublicKey
-	} else {
-		subScript = contract
-	}
-	serializedPublicKey, err := tx.account.SerializedPublicKey()
+// estimateSTXVSize returns the virtual size of the signed transaction, from
+// the types of its inputs, so that the signer is not asked to sign twice.
+func (tx *tx) estimateSTXVSize(f func(*txscript.ScriptBuilder), contract []byte) (int, error) {
+	inputTypes, err := tx.inputTypes(f, contract)
 	if err != nil {
 		return 0, err
 	}
-	txCopy := tx.msgTx.Copy()
-	for i, txin := range txCopy.TxIn {
-		if updateTxIn != nil {
-			updateTxIn(txin)
-		}
-		sig, err := txscript.RawTxInSignature(txCopy, i, subScript, txscript.SigHashAll, tx.account.PrivKey)
+	return estimateTxVSize(tx.msgTx, inputTypes), nil
+}
+
+// inputTypes returns the types of the inputs of the transaction, which spend
+// the contract with the data pushed by f if it is not nil.
+func (tx *tx) inputTypes(f func(*txscript.ScriptBuilder), contract []byte) ([]InputType, error) {
+	inputTypes := make([]InputType, len(tx.msgTx.TxIn))
+	if contract != nil {
+		serializedPublicKey, err := tx.account.SerializedPublicKey()
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
 		builder := txscript.NewScriptBuilder()
-		builder.AddData(sig)
-		builder.AddData(serializedPublicKey)
 		if f != nil {
 			f(builder)
 		}
-		if contract != nil {
-			builder.AddData(contract)
+		template, err := builder.Script()
+		if err != nil {
+			return nil, err
 		}
-		sigScript, err := builder.Script()
+		inputType := contractInputType(contract, len(serializedPublicKey), len(template))
+		for i := range inputTypes {
+			inputTypes[i] = inputType
+		}
+		return inputTypes, nil
+	}
+
+	for i := range inputTypes {
+		pubKey, err := tx.pushPubKey(i, nil)
 		if err != nil {
-			return 0, err
+			return nil, err
+		}
+		switch {
+		case pubKey == nil:
+			inputTypes[i] = InputP2PK
+		case len(pubKey) == btcec.PubKeyBytesLenUncompressed:
+			inputTypes[i] = InputP2PKHUncompressed
+		default:
+			inputTypes[i] = InputP2PKH
 		}
-		txin.SignatureScript = sigScript
 	}
-	return txCopy.SerializeSize(), nil
+	return inputTypes, nil
 }
 
 func (tx *tx) verify() error {
+	if hasForkID(tx.account.SigHashType) {
+		return checkReplayProtection(tx.msgTx)
+	}
 	for i, receiveValue := range tx.receiveValues {
-		engine, err := txscript.NewEngine(tx.scriptPublicKey, tx.msgTx, i,
+		engine, err := txscript.NewEngine(tx.scriptPublicKeys[i], tx.msgTx, i,
 			txscript.StandardVerifyFlags, txscript.NewSigCache(10),
 			txscript.NewTxSigHashes(tx.msgTx), receiveValue)
 		if err != nil {
@@ -216,5 +360,8 @@ func (tx *tx) verify() error {
 }
 
 func (tx *tx) submit() error {
+	if err := CheckStandard(tx.msgTx, tx.receiveValues, tx.account.NetworkParams()); err != nil {
+		return err
+	}
 	return tx.account.PublishTransaction(tx.ctx, tx.msgTx)
 }
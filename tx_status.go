@@ -0,0 +1,138 @@
+package libbtc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// TxState is the state of a transaction, see TxStatus.
+type TxState uint8
+
+// TxState values.
+const (
+	// TxNotFound is the state of transactions unknown to the backend, which
+	// were never published or were dropped from its mempool.
+	TxNotFound = TxState(iota)
+	// TxMempool is the state of unconfirmed transactions.
+	TxMempool
+	// TxConfirmed is the state of transactions included in the active chain.
+	TxConfirmed
+	// TxConflicted is the state of unconfirmed transactions spending an
+	// output that is spent by another transaction, which cannot be confirmed
+	// unless the other transaction is dropped.
+	TxConflicted
+)
+
+func (state TxState) String() string {
+	switch state {
+	case TxNotFound:
+		return "not found"
+	case TxMempool:
+		return "mempool"
+	case TxConfirmed:
+		return "confirmed"
+	case TxConflicted:
+		return "conflicted"
+	default:
+		return "unknown"
+	}
+}
+
+// TxStatus is the state of a transaction along with the fee it pays.
+type TxStatus struct {
+	State TxState
+
+	// BlockHeight and Confirmations are only set for confirmed transactions.
+	BlockHeight   int64
+	Confirmations int64
+
+	// ConflictTxHash is the hash of the transaction conflicting with a
+	// conflicted transaction.
+	ConflictTxHash string
+
+	// Fee is the fee paid by the transaction, zero if it is not found.
+	Fee int64
+}
+
+func (client *client) TxStatus(ctx context.Context, txHash string) (TxStatus, error) {
+	// Backends retry requests for unknown transactions until the context is
+	// done, so they are only given a bounded time to find the transaction.
+	lookupCtx, cancel := context.WithTimeout(ctx, knownTxTimeout)
+	defer cancel()
+	msgTx, err := client.RawTransaction(lookupCtx, txHash)
+	if err != nil {
+		if lookupCtx.Err() != nil || errors.Is(err, errors.ErrTimedOut) {
+			return TxStatus{}, errors.ErrTimedOut
+		}
+		if isTxNotFound(err) {
+			return TxStatus{State: TxNotFound}, nil
+		}
+		return TxStatus{}, err
+	}
+
+	decoded, err := client.decode(ctx, msgTx)
+	if err != nil {
+		return TxStatus{}, err
+	}
+	status := TxStatus{
+		State: TxMempool,
+		Fee:   decoded.Fee,
+	}
+	conf, err := client.Confirmations(ctx, txHash)
+	if err != nil {
+		return TxStatus{}, err
+	}
+	if conf > 0 {
+		tip, err := client.ChainTip(ctx)
+		if err != nil {
+			return TxStatus{}, err
+		}
+		status.State = TxConfirmed
+		status.Confirmations = conf
+		status.BlockHeight = tip.Height - conf + 1
+		return status, nil
+	}
+
+	for _, txIn := range msgTx.TxIn {
+		if isCoinbaseOutPoint(txIn.PreviousOutPoint) {
+			continue
+		}
+		spent, spendingTxHash, err := client.OutPointSpent(ctx, txIn.PreviousOutPoint.Hash.String(), txIn.PreviousOutPoint.Index)
+		if err != nil {
+			return TxStatus{}, err
+		}
+		// Backends that cannot tell which transaction spent the output may
+		// report the output of a transaction in their mempool as spent, so
+		// only known spenders are conflicts.
+		if spent && spendingTxHash != "" && spendingTxHash != txHash {
+			status.State = TxConflicted
+			status.ConflictTxHash = spendingTxHash
+			return status, nil
+		}
+	}
+	return status, nil
+}
+
+// isTxNotFound returns whether the error of a transaction lookup is a definite
+// answer that the backend does not know the transaction, as opposed to a
+// failure to answer: ErrTxNotFound, a 404 response of an HTTP backend, or the
+// error of a node or Electrum server without the transaction.
+func isTxNotFound(err error) bool {
+	if errors.Is(err, errors.ErrTxNotFound) {
+		return true
+	}
+	var httpErr clients.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusNotFound
+	}
+	var rpcErr *btcjson.RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code == btcjson.ErrRPCNoTxInfo
+	}
+	return strings.Contains(err.Error(), "No such mempool or blockchain transaction")
+}
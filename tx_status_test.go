@@ -0,0 +1,81 @@
+package libbtc_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/clients/mock"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// failingChain is a mock chain whose transaction lookups fail with err.
+type failingChain struct {
+	*mock.Chain
+	err error
+}
+
+func (chain failingChain) RawTransaction(ctx context.Context, txHash string) (*wire.MsgTx, error) {
+	return nil, chain.err
+}
+
+var _ = Describe("TxStatus", func() {
+	const unknownTxHash = "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"
+
+	It("should follow a transfer from the mempool to the chain", func() {
+		chain, account := newMockAccount()
+		address, err := account.Address()
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = chain.Fund(address.EncodeAddress(), 1000000)
+		Expect(err).ShouldNot(HaveOccurred())
+		chain.Mine(1)
+
+		txHash, _, err := account.Transfer(context.Background(), address.EncodeAddress(), 500000, Fast, false)
+		Expect(err).ShouldNot(HaveOccurred())
+		status, err := account.TxStatus(context.Background(), txHash)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(status.State).Should(Equal(TxMempool))
+		Expect(status.Fee).Should(BeNumerically(">", 0))
+
+		chain.Mine(2)
+		status, err = account.TxStatus(context.Background(), txHash)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(status.State).Should(Equal(TxConfirmed))
+		Expect(status.Confirmations).Should(Equal(int64(2)))
+	})
+
+	It("should report transactions unknown to the backend as not found", func() {
+		chain := mock.NewChain(&chaincfg.RegressionNetParams)
+		status, err := NewClientFromCore(chain).TxStatus(context.Background(), unknownTxHash)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(status.State).Should(Equal(TxNotFound))
+
+		notFound := failingChain{chain, fmt.Errorf("cannot get the transaction: %w", clients.HTTPError{StatusCode: http.StatusNotFound})}
+		status, err = NewClientFromCore(notFound).TxStatus(context.Background(), unknownTxHash)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(status.State).Should(Equal(TxNotFound))
+	})
+
+	It("should return the errors of backends failing to answer", func() {
+		chain := mock.NewChain(&chaincfg.RegressionNetParams)
+		for _, lookupErr := range []error{
+			fmt.Errorf("cannot get the transaction: %w", clients.HTTPError{StatusCode: http.StatusServiceUnavailable}),
+			clients.HTTPError{StatusCode: http.StatusTooManyRequests},
+			errors.NewErrBackendUnavailable("mock", fmt.Errorf("connection refused")),
+			fmt.Errorf("unexpected response"),
+		} {
+			_, err := NewClientFromCore(failingChain{chain, lookupErr}).TxStatus(context.Background(), unknownTxHash)
+			Expect(err).Should(HaveOccurred(), lookupErr.Error())
+		}
+
+		_, err := NewClientFromCore(failingChain{chain, errors.ErrTimedOut}).TxStatus(context.Background(), unknownTxHash)
+		Expect(err).Should(Equal(errors.ErrTimedOut))
+	})
+})
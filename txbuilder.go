@@ -4,45 +4,270 @@ import (
 	"bytes"
 	"context"
 	"crypto/ecdsa"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"time"
 
-	"github.com/renproject/libbtc-go/clients"
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/clients"
 )
 
 type txBuilder struct {
-	version   int32
-	fee, dust int64
-	client    Client
+	version       int32
+	fee, dust     int64
+	maxFeePercent float64
+	rbf           bool
+	change        ChangeAddresser
+	client        Client
+	logger        Logger
+}
+
+// TxBuilderOption configures a TxBuilder.
+type TxBuilderOption func(*txBuilder)
+
+// WithDust sets the value below which outputs are dust. The default is the
+// dust of the network of the client, 600 satoshis on bitcoin.
+func WithDust(dust int64) TxBuilderOption {
+	return func(builder *txBuilder) {
+		builder.dust = dust
+	}
+}
+
+// WithFee sets the fee paid by the transactions. The default is the maximum
+// fee of the network of the client, 10000 satoshis on bitcoin.
+func WithFee(fee int64) TxBuilderOption {
+	return func(builder *txBuilder) {
+		builder.fee = fee
+	}
+}
+
+// WithVersion sets the version of the transactions. The default is 2, which
+// relative lock times require.
+func WithVersion(version int32) TxBuilderOption {
+	return func(builder *txBuilder) {
+		builder.version = version
+	}
+}
+
+// WithRBF makes the transactions signal replaceability, as defined in BIP125,
+// by giving the inputs without an explicit sequence number the sequence
+// number MaxTxInSequenceNum - 2.
+func WithRBF() TxBuilderOption {
+	return func(builder *txBuilder) {
+		builder.rbf = true
+	}
+}
+
+// WithLogger sets the logger of the builder, which logs the inputs of the
+// transactions it builds. The builder logs nothing by default.
+func WithLogger(logger Logger) TxBuilderOption {
+	return func(builder *txBuilder) {
+		builder.logger = logger
+	}
 }
 
-func NewTxBuilder(client Client) TxBuilder {
-	return &txBuilder{2, 10000, 600, client}
+// WithChangeAddresser sets where the change of the transactions goes. By
+// default, change goes back to the address of the public key the
+// transactions are built for.
+func WithChangeAddresser(addresser ChangeAddresser) TxBuilderOption {
+	return func(builder *txBuilder) {
+		builder.change = addresser
+	}
+}
+
+// WithMaxFeePercent sets the percentage of the value transferred by the
+// transactions that their fee can reach, which is DefaultMaxFeePercent by
+// default. Zero disables the check.
+func WithMaxFeePercent(percent float64) TxBuilderOption {
+	return func(builder *txBuilder) {
+		builder.maxFeePercent = percent
+	}
+}
+
+func NewTxBuilder(client Client, opts ...TxBuilderOption) TxBuilder {
+	builder := &txBuilder{
+		version:       2,
+		fee:           NetworkMaxFee(client.NetworkParams()),
+		dust:          NetworkDust(client.NetworkParams()),
+		maxFeePercent: DefaultMaxFeePercent,
+		client:        client,
+		logger:        clients.NopLogger(),
+	}
+	for _, opt := range opts {
+		opt(builder)
+	}
+	if builder.logger == nil {
+		builder.logger = clients.NopLogger()
+	}
+	return builder
 }
 
 type TxBuilder interface {
-	Build(ctx context.Context, pubKey ecdsa.PublicKey, to string, contract []byte, value int64, mwUTXOs, scriptUTXOs []clients.UTXO) (Tx, error)
+	Build(ctx context.Context, pubKey ecdsa.PublicKey, to string, contract []byte, value int64, mwUTXOs, scriptUTXOs []clients.UTXO, opts ...BuildOption) (Tx, error)
+
+	// BuildContracts is Build spending the UTXOs of several contracts, so
+	// that they can be swept along with the master wallet in a single
+	// transaction.
+	BuildContracts(ctx context.Context, pubKey ecdsa.PublicKey, to string, value int64, mwUTXOs []clients.UTXO, spends []ContractSpend, opts ...BuildOption) (Tx, error)
+
+	// BuildOutputs builds a transaction paying arbitrary outputs, such as
+	// several payments, an OP_RETURN output or the funding of contracts, from
+	// the UTXOs of the public key.
+	BuildOutputs(ctx context.Context, pubKey ecdsa.PublicKey, outputs []wire.TxOut, utxos []clients.UTXO, opts ...BuildOption) (Tx, error)
+
+	// BuildChannelFunding builds the funding transaction of a 2-of-2
+	// multisig Channel between the public key and a remote public key.
+	BuildChannelFunding(ctx context.Context, pubKey ecdsa.PublicKey, remotePubKey []byte, capacity int64, utxos []clients.UTXO, opts ...BuildOption) (*Channel, error)
+
+	// BuildChannelRefund builds the refund of the Channel to its funder,
+	// which can be mined delay blocks after the funding transaction.
+	BuildChannelRefund(ctx context.Context, channel *Channel, delay uint16, opts ...BuildOption) (Tx, error)
+}
+
+// ContractSpend is a contract and the UTXOs of it that a transaction spends.
+type ContractSpend struct {
+	// Contract is the redeem script of the P2SH outputs spent.
+	Contract []byte
+	UTXOs    []clients.UTXO
+	// Pushes are pushed before the contract in the signature scripts of the
+	// inputs, to select the branch being spent. The pushes set with
+	// WithScriptSigTemplate are used if they are nil.
+	Pushes [][]byte
+}
+
+// BuildOption configures optional fields of the transactions built by a
+// TxBuilder.
+type BuildOption func(*buildOptions)
+
+type buildOptions struct {
+	lockTime   uint32
+	sequences  map[int]uint32
+	dustPolicy DustPolicy
+	hashType   txscript.SigHashType
+	hashTypes  map[int]txscript.SigHashType
+	template   [][]byte
+	rbf        bool
+	absurdFee  bool
+}
+
+// AllowAbsurdFee builds the transaction even if its fee exceeds the maximum
+// percentage of the value it transfers.
+func AllowAbsurdFee() BuildOption {
+	return func(opts *buildOptions) {
+		opts.absurdFee = true
+	}
+}
+
+// WithLockTime sets the nLockTime of the transaction. Inputs without an
+// explicit sequence number are given a non-final sequence number, so that the
+// lock time is enforced.
+func WithLockTime(lockTime uint32) BuildOption {
+	return func(opts *buildOptions) {
+		opts.lockTime = lockTime
+	}
+}
+
+// WithSequence sets the sequence number of the input at the given index.
+// Inputs spending the master wallet UTXOs come first, followed by the inputs
+// spending the script UTXOs, in the order they were given. Relative lock times
+// checked by OP_CHECKSEQUENCEVERIFY are set with the sequence numbers returned
+// by RelativeLockTimeBlocks and RelativeLockTimeSeconds.
+func WithSequence(index int, sequence uint32) BuildOption {
+	return func(opts *buildOptions) {
+		opts.sequences[index] = sequence
+	}
+}
+
+// WithScriptSigTemplate sets the data pushed between the public key and the
+// contract in the signature scripts of the inputs spending the script UTXOs,
+// which selects the branch of the contract being spent. Pushes are minimally
+// encoded, so the refund branch of a contract using OP_IF and
+// OP_CHECKLOCKTIMEVERIFY is selected with a single empty push (OP_FALSE),
+// along with WithLockTime.
+func WithScriptSigTemplate(pushes ...[]byte) BuildOption {
+	return func(opts *buildOptions) {
+		opts.template = pushes
+	}
+}
+
+// WithDustPolicy sets what happens to the change output when its value falls
+// below dust. The default is DustToFee.
+func WithDustPolicy(policy DustPolicy) BuildOption {
+	return func(opts *buildOptions) {
+		opts.dustPolicy = policy
+	}
 }
 
 type Tx interface {
 	Hashes() [][]byte
+
+	// SigningRequests returns the hashes to sign along with the inputs they
+	// sign, in the same order as Hashes.
+	SigningRequests() []SigningRequest
 	InjectSigs(sigs []*btcec.Signature) error
+
+	// InjectSigsFor adds signatures to the input at the given index, which
+	// can be signed by another key than the one the transaction was built
+	// for. The signatures of an input accumulate across calls, so the m
+	// signatures of a multisig contract can be injected as they arrive.
+	InjectSigsFor(inputIndex int, sigs []InputSig) error
+	Session(expiry time.Time) (*SigningSession, error)
 	Submit(ctx context.Context) ([]byte, error)
+
+	// Serialize returns the transaction in the wire format, with the
+	// signatures injected so far, so that it can be stored or published
+	// through another channel.
+	Serialize() ([]byte, error)
+
+	// TxHash returns the hash of the transaction, which only changes with
+	// the signatures of inputs that are not segwit.
+	TxHash() chainhash.Hash
+
+	// VSize returns the virtual size of the transaction, which is only final
+	// once every input is signed.
+	VSize() int
+
+	// Export returns the unsigned transaction and its signing requests, to be
+	// signed on another machine once imported with ImportTx.
+	Export() (*UnsignedTx, error)
+}
+
+// InputSig is the signature of an input by the serialized public key. A nil
+// PubKey is the public key the transaction was built for.
+type InputSig struct {
+	PubKey    []byte
+	Signature *btcec.Signature
 }
 
 type transaction struct {
 	sent      int64
 	msgTx     *wire.MsgTx
 	hashes    [][]byte
+	requests  []SigningRequest
 	client    Client
-	contract  []byte
 	publicKey ecdsa.PublicKey
 	mwIns     int
+	scripts   [][]byte
+	hashType  txscript.SigHashType
+	hashTypes []txscript.SigHashType
+
+	// contracts are the contracts spent by every input, nil for the inputs
+	// spending master wallet UTXOs, and pushes are pushed before them in the
+	// signature scripts.
+	contracts [][]byte
+	pushes    [][][]byte
+
+	// segwit is true for the inputs spending P2WSH outputs, which are
+	// satisfied by their witness rather than their signature script.
+	segwit []bool
+
+	// sigs are the signatures injected so far, per input.
+	sigs [][]InputSig
 }
 
 func (builder *txBuilder) Build(
@@ -52,7 +277,29 @@ func (builder *txBuilder) Build(
 	contract []byte,
 	value int64,
 	mwUTXOs, scriptUTXOs []clients.UTXO,
+	opts ...BuildOption,
+) (Tx, error) {
+	var spends []ContractSpend
+	if contract != nil {
+		spends = []ContractSpend{{Contract: contract, UTXOs: scriptUTXOs}}
+	}
+	return builder.BuildContracts(ctx, pubKey, to, value, mwUTXOs, spends, opts...)
+}
+
+// BuildContracts builds a transaction spending the master wallet UTXOs
+// followed by the UTXOs of every contract, in order, the hash of every input
+// being computed with the contract it spends.
+func (builder *txBuilder) BuildContracts(
+	ctx context.Context,
+	pubKey ecdsa.PublicKey,
+	to string,
+	value int64,
+	mwUTXOs []clients.UTXO,
+	spends []ContractSpend,
+	opts ...BuildOption,
 ) (Tx, error) {
+	options := builder.buildOptions(opts)
+
 	if value < builder.fee+builder.dust {
 		return nil, fmt.Errorf("minimum transfer amount is : %d", builder.dust+builder.fee+1)
 	}
@@ -76,22 +323,43 @@ func (builder *txBuilder) Build(
 	msgTx := wire.NewMsgTx(builder.version)
 
 	var sent int64
-	amt, pubKeyScript, err := fundBtcTx(ctx, from, nil, builder.client, msgTx, mwUTXOs)
+	amt, scripts, segwit, err := fundBtcTx(ctx, (*btcec.PublicKey)(&pubKey), nil, builder.client, msgTx, mwUTXOs)
 	if err != nil {
 		return nil, err
 	}
-	if contract != nil {
-		amt2, _, err := fundBtcTx(ctx, from, contract, builder.client, msgTx, scriptUTXOs)
-		if err != nil {
-			return nil, err
+	contracts := make([][]byte, len(mwUTXOs))
+	pushes := make([][][]byte, len(mwUTXOs))
+	utxoSets := [][]clients.UTXO{mwUTXOs}
+	if len(spends) > 0 {
+		var contractAmt int64
+		for i, spend := range spends {
+			if spend.Contract == nil {
+				return nil, fmt.Errorf("contract %d is nil", i)
+			}
+			amt2, contractScripts, contractSegwit, err := fundBtcTx(ctx, (*btcec.PublicKey)(&pubKey), spend.Contract, builder.client, msgTx, spend.UTXOs)
+			if err != nil {
+				return nil, err
+			}
+			scripts = append(scripts, contractScripts...)
+			segwit = append(segwit, contractSegwit...)
+			contractAmt += amt2
+			utxoSets = append(utxoSets, spend.UTXOs)
+
+			contractPushes := spend.Pushes
+			if contractPushes == nil {
+				contractPushes = options.template
+			}
+			for range spend.UTXOs {
+				contracts = append(contracts, spend.Contract)
+				pushes = append(pushes, contractPushes)
+			}
 		}
-		amt += amt2
-		sent = amt2 - builder.fee
+		amt += contractAmt
+		sent = contractAmt - builder.fee
 	}
 
-	fmt.Println("utxos being used: ")
 	for i, txIn := range msgTx.TxIn {
-		fmt.Printf("[%d]: %s:%d\n", i, txIn.PreviousOutPoint.Hash.String(), txIn.PreviousOutPoint.Index)
+		builder.logger.Debugf("using utxo [%d]: %s:%d", i, txIn.PreviousOutPoint.Hash.String(), txIn.PreviousOutPoint.Index)
 	}
 
 	if amt < value+builder.fee {
@@ -108,40 +376,207 @@ func (builder *txBuilder) Build(
 		msgTx.AddTxOut(wire.NewTxOut(value, script))
 	}
 
-	if amt-value > builder.fee+builder.dust {
-		P2PKHScript, err := txscript.PayToAddrScript(from)
+	if change := amt - value - builder.fee; change > 0 {
+		changeAddr, err := changeAddress(ctx, builder.change, from)
+		if err != nil {
+			return nil, err
+		}
+		changeScript, err := txscript.PayToAddrScript(changeAddr)
+		if err != nil {
+			return nil, err
+		}
+		msgTx.AddTxOut(wire.NewTxOut(change, changeScript))
+
+		paymentIndex := -1
+		if value > 0 {
+			paymentIndex = len(msgTx.TxOut) - 2
+		}
+		rolled, err := applyDustPolicy(msgTx, len(msgTx.TxOut)-1, paymentIndex, builder.dust, options.dustPolicy)
 		if err != nil {
 			return nil, err
 		}
-		msgTx.AddTxOut(wire.NewTxOut(amt-value-builder.fee, P2PKHScript))
+		sent += rolled
 	}
 
-	var hashes [][]byte
+	if !options.absurdFee {
+		if err := checkFee(msgTxFee(msgTx, amt), sent, builder.maxFeePercent); err != nil {
+			return nil, err
+		}
+	}
+
+	return builder.newTransaction(msgTx, pubKey, pubKeyBytes, scripts, contracts, pushes, segwit, sent, options, len(mwUTXOs), utxoSets...)
+}
+
+// BuildOutputs builds a transaction paying the outputs, in order, from the
+// UTXOs of the public key, with the change in a last output. Outputs other
+// than OP_RETURN ones must not be dust. The value transferred is the total
+// value of the outputs, so transactions with only OP_RETURN outputs need
+// AllowAbsurdFee. The DustToPayment policy adds the change to the first output
+// with a value.
+func (builder *txBuilder) BuildOutputs(
+	ctx context.Context,
+	pubKey ecdsa.PublicKey,
+	outputs []wire.TxOut,
+	utxos []clients.UTXO,
+	opts ...BuildOption,
+) (Tx, error) {
+	options := builder.buildOptions(opts)
+
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("transaction has no outputs")
+	}
+
+	pubKeyBytes, err := builder.client.SerializePublicKey((*btcec.PublicKey)(&pubKey))
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := builder.client.PublicKeyToAddress(pubKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	msgTx := wire.NewMsgTx(builder.version)
+
+	var value int64
+	paymentIndex := -1
+	for i, output := range outputs {
+		if txscript.GetScriptClass(output.PkScript) != txscript.NullDataTy {
+			if output.Value < builder.dust {
+				return nil, fmt.Errorf("value %d of output %d is below dust (%d)", output.Value, i, builder.dust)
+			}
+			if paymentIndex < 0 {
+				paymentIndex = i
+			}
+		}
+		value += output.Value
+		msgTx.AddTxOut(wire.NewTxOut(output.Value, output.PkScript))
+	}
+
+	amt, scripts, segwit, err := fundBtcTx(ctx, (*btcec.PublicKey)(&pubKey), nil, builder.client, msgTx, utxos)
+	if err != nil {
+		return nil, err
+	}
 
-	for i := 0; i < len(mwUTXOs); i++ {
-		hash, err := txscript.CalcSignatureHash(pubKeyScript, txscript.SigHashAll, msgTx, i)
+	for i, txIn := range msgTx.TxIn {
+		builder.logger.Debugf("using utxo [%d]: %s:%d", i, txIn.PreviousOutPoint.Hash.String(), txIn.PreviousOutPoint.Index)
+	}
+
+	if amt < value+builder.fee {
+		return nil, fmt.Errorf("insufficient balance to do the transfer:"+
+			"got: %d required: %d", amt, value+builder.fee)
+	}
+
+	sent := value
+	if change := amt - value - builder.fee; change > 0 {
+		changeAddr, err := changeAddress(ctx, builder.change, from)
 		if err != nil {
 			return nil, err
 		}
-		hashes = append(hashes, hash)
+		changeScript, err := txscript.PayToAddrScript(changeAddr)
+		if err != nil {
+			return nil, err
+		}
+		msgTx.AddTxOut(wire.NewTxOut(change, changeScript))
+
+		rolled, err := applyDustPolicy(msgTx, len(msgTx.TxOut)-1, paymentIndex, builder.dust, options.dustPolicy)
+		if err != nil {
+			return nil, err
+		}
+		sent += rolled
+	}
+
+	if !options.absurdFee {
+		if err := checkFee(msgTxFee(msgTx, amt), sent, builder.maxFeePercent); err != nil {
+			return nil, err
+		}
+	}
+
+	contracts := make([][]byte, len(msgTx.TxIn))
+	pushes := make([][][]byte, len(msgTx.TxIn))
+	return builder.newTransaction(msgTx, pubKey, pubKeyBytes, scripts, contracts, pushes, segwit, sent, options, len(utxos), utxos)
+}
+
+func (builder *txBuilder) buildOptions(opts []BuildOption) buildOptions {
+	options := buildOptions{
+		sequences: map[int]uint32{},
+		hashType:  txscript.SigHashAll,
+		hashTypes: map[int]txscript.SigHashType{},
+		rbf:       builder.rbf,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// newTransaction applies the build options to the funded transaction and
+// computes the hashes its inputs sign. The mwIns inputs spending the master
+// wallet UTXOs come first, followed by the ones spending contracts, and every
+// input spends one of the UTXOs.
+func (builder *txBuilder) newTransaction(
+	msgTx *wire.MsgTx,
+	pubKey ecdsa.PublicKey,
+	pubKeyBytes []byte,
+	scripts, contracts [][]byte,
+	pushes [][][]byte,
+	segwit []bool,
+	sent int64,
+	options buildOptions,
+	mwIns int,
+	utxoSets ...[]clients.UTXO,
+) (Tx, error) {
+	if err := applyBuildOptions(msgTx, options); err != nil {
+		return nil, err
 	}
 
-	for i := len(mwUTXOs); i < len(mwUTXOs)+len(scriptUTXOs); i++ {
-		hash, err := txscript.CalcSignatureHash(contract, txscript.SigHashAll, msgTx, i)
+	amounts, err := inputAmounts(msgTx, utxoSets...)
+	if err != nil {
+		return nil, err
+	}
+	hashTypes, err := inputHashTypes(msgTx, options)
+	if err != nil {
+		return nil, err
+	}
+	sigHashes := txscript.NewTxSigHashes(msgTx)
+
+	var hashes [][]byte
+	var requests []SigningRequest
+
+	for i, script := range scripts {
+		hash, err := calcSignatureHash(script, sigHashes, hashTypes[i], msgTx, i, amounts[i], segwit[i])
 		if err != nil {
 			return nil, err
 		}
 		hashes = append(hashes, hash)
+		requests = append(requests, SigningRequest{
+			Index:    i,
+			OutPoint: msgTx.TxIn[i].PreviousOutPoint,
+			Amount:   amounts[i],
+			Script:   script,
+			HashType: hashTypes[i],
+			Hash:     hash,
+			PubKey:   signingPubKey(script, (*btcec.PublicKey)(&pubKey), pubKeyBytes),
+			Contract: contracts[i] != nil,
+			Segwit:   segwit[i],
+		})
 	}
 
 	return &transaction{
 		sent:      sent,
 		hashes:    hashes,
+		requests:  requests,
 		msgTx:     msgTx,
 		client:    builder.client,
 		publicKey: pubKey,
-		contract:  contract,
-		mwIns:     len(mwUTXOs),
+		mwIns:     mwIns,
+		scripts:   scripts,
+		hashType:  options.hashType,
+		hashTypes: hashTypes,
+		contracts: contracts,
+		pushes:    pushes,
+		segwit:    segwit,
+		sigs:      make([][]InputSig, len(msgTx.TxIn)),
 	}, nil
 }
 
@@ -149,69 +584,359 @@ func (tx *transaction) Hashes() [][]byte {
 	return tx.hashes
 }
 
+func (tx *transaction) SigningRequests() []SigningRequest {
+	return tx.requests
+}
+
 func (tx *transaction) InjectSigs(sigs []*btcec.Signature) error {
-	pubKey := (*btcec.PublicKey)(&tx.publicKey)
-	serializedPublicKey, err := tx.client.SerializePublicKey(pubKey)
+	for i, sig := range sigs {
+		if err := tx.InjectSigsFor(i, []InputSig{{Signature: sig}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tx *transaction) InjectSigsFor(inputIndex int, sigs []InputSig) error {
+	if inputIndex < 0 || inputIndex >= len(tx.msgTx.TxIn) {
+		return fmt.Errorf("invalid input index %d: transaction has %d inputs", inputIndex, len(tx.msgTx.TxIn))
+	}
+	signers, required, err := tx.multisigSigners(inputIndex)
 	if err != nil {
 		return err
 	}
-	for i, sig := range sigs {
-		builder := txscript.NewScriptBuilder()
-		builder.AddData(append(sig.Serialize(), byte(txscript.SigHashAll)))
-		builder.AddData(serializedPublicKey)
-		if i >= tx.mwIns && tx.contract != nil {
-			builder.AddData(tx.contract)
+	for _, sig := range sigs {
+		if sig.PubKey == nil {
+			serializedPublicKey, err := tx.client.SerializePublicKey((*btcec.PublicKey)(&tx.publicKey))
+			if err != nil {
+				return err
+			}
+			sig.PubKey = signingPubKey(tx.scripts[inputIndex], (*btcec.PublicKey)(&tx.publicKey), serializedPublicKey)
 		}
-		sigScript, err := builder.Script()
+		pubKey, err := btcec.ParsePubKey(sig.PubKey, btcec.S256())
 		if err != nil {
 			return err
 		}
-		tx.msgTx.TxIn[i].SignatureScript = sigScript
+		if !sig.Signature.Verify(tx.hashes[inputIndex], pubKey) {
+			return fmt.Errorf("invalid signature for input %d from %s", inputIndex, hex.EncodeToString(sig.PubKey))
+		}
+		if signers != nil && !containsPubKey(signers, sig.PubKey) {
+			return fmt.Errorf("%s is not a signer of the multisig contract of input %d", hex.EncodeToString(sig.PubKey), inputIndex)
+		}
+		tx.addSig(inputIndex, sig)
+	}
+	if len(tx.sigs[inputIndex]) == 0 {
+		return nil
+	}
+
+	stack := tx.inputStack(inputIndex, signers, required)
+	if tx.segwit[inputIndex] {
+		tx.msgTx.TxIn[inputIndex].Witness = stack
+		return nil
 	}
+	sigScript, err := pushScript(stack)
+	if err != nil {
+		return err
+	}
+	tx.msgTx.TxIn[inputIndex].SignatureScript = sigScript
 	return nil
 }
 
+// addSig adds the signature to the input, replacing the previous signature of
+// the same public key.
+func (tx *transaction) addSig(index int, sig InputSig) {
+	for i, inputSig := range tx.sigs[index] {
+		if bytes.Equal(inputSig.PubKey, sig.PubKey) {
+			tx.sigs[index][i] = sig
+			return
+		}
+	}
+	tx.sigs[index] = append(tx.sigs[index], sig)
+}
+
+// multisigSigners returns the public keys of the multisig contract spent by
+// the input, in order, and the number of signatures it requires. The public
+// keys are nil if the input does not spend a multisig contract.
+func (tx *transaction) multisigSigners(index int) ([][]byte, int, error) {
+	contract := tx.contracts[index]
+	if contract == nil || txscript.GetScriptClass(contract) != txscript.MultiSigTy {
+		return nil, 0, nil
+	}
+	_, addrs, required, err := txscript.ExtractPkScriptAddrs(contract, tx.client.NetworkParams())
+	if err != nil {
+		return nil, 0, err
+	}
+	signers := make([][]byte, len(addrs))
+	for i, addr := range addrs {
+		signers[i] = addr.ScriptAddress()
+	}
+	return signers, required, nil
+}
+
+func containsPubKey(pubKeys [][]byte, pubKey []byte) bool {
+	for _, key := range pubKeys {
+		if bytes.Equal(key, pubKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// inputStack returns the data satisfying the input with the signatures
+// injected so far, which is pushed by its signature script or is its witness.
+// Inputs spending a multisig contract push up to the required number of
+// signatures in the order of the public keys in the contract, as
+// OP_CHECKMULTISIG expects, and other inputs push the last signature along
+// with its public key.
+func (tx *transaction) inputStack(index int, signers [][]byte, required int) [][]byte {
+	sigs := tx.sigs[index]
+	hashType := byte(tx.hashTypes[index])
+
+	var stack [][]byte
+	if signers != nil {
+		// OP_CHECKMULTISIG pops an extra element, so an empty one comes
+		// first.
+		stack = append(stack, []byte{})
+		pushed := 0
+		for _, signer := range signers {
+			for _, sig := range sigs {
+				if pushed < required && bytes.Equal(signer, sig.PubKey) {
+					stack = append(stack, append(sig.Signature.Serialize(), hashType))
+					pushed++
+				}
+			}
+		}
+	} else {
+		// Pay-to-pubkey outputs are spent with the signature alone.
+		sig := sigs[len(sigs)-1]
+		stack = append(stack, append(sig.Signature.Serialize(), hashType))
+		if tx.contracts[index] != nil || txscript.GetScriptClass(tx.scripts[index]) != txscript.PubKeyTy {
+			stack = append(stack, sig.PubKey)
+		}
+	}
+	if contract := tx.contracts[index]; contract != nil {
+		stack = append(stack, tx.pushes[index]...)
+		stack = append(stack, contract)
+	}
+	return stack
+}
+
+// pushScript returns the script pushing the data, minimally encoded, so that
+// an empty item is pushed with OP_0.
+func pushScript(stack [][]byte) ([]byte, error) {
+	builder := txscript.NewScriptBuilder()
+	for _, data := range stack {
+		builder.AddData(data)
+	}
+	return builder.Script()
+}
+
+// Session returns a SigningSession for the transaction, expecting every input
+// to be signed by the public key the transaction was built for.
+func (tx *transaction) Session(expiry time.Time) (*SigningSession, error) {
+	serializedPublicKey, err := tx.client.SerializePublicKey((*btcec.PublicKey)(&tx.publicKey))
+	if err != nil {
+		return nil, err
+	}
+	pubKeys := make([][]byte, len(tx.msgTx.TxIn))
+	for i := range pubKeys {
+		pubKeys[i] = signingPubKey(tx.scripts[i], (*btcec.PublicKey)(&tx.publicKey), serializedPublicKey)
+	}
+	contract, pushes, single := tx.singleContract()
+	session, err := NewSigningSession(tx.msgTx, tx.hashes, pubKeys, contract, tx.mwIns, expiry)
+	if err != nil {
+		return nil, err
+	}
+	session.HashType = tx.hashType
+	session.HashTypes = tx.hashTypes
+	session.ContractPushes = pushes
+	for _, segwit := range tx.segwit {
+		if segwit {
+			session.Segwit = tx.segwit
+			break
+		}
+	}
+	if !single {
+		session.Contracts = tx.contracts
+		session.InputContractPushes = tx.pushes
+	}
+	return session, nil
+}
+
+// singleContract returns the contract spent by the inputs after the master
+// inputs, and its pushes, if they all spend the same one with the same pushes.
+// The contract is nil if the transaction spends no contract.
+func (tx *transaction) singleContract() ([]byte, [][]byte, bool) {
+	if tx.mwIns == len(tx.contracts) {
+		return nil, nil, true
+	}
+	contract, pushes := tx.contracts[tx.mwIns], tx.pushes[tx.mwIns]
+	for i := tx.mwIns + 1; i < len(tx.contracts); i++ {
+		if !bytes.Equal(tx.contracts[i], contract) || !equalPushes(tx.pushes[i], pushes) {
+			return nil, nil, false
+		}
+	}
+	return contract, pushes, true
+}
+
+func equalPushes(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (tx *transaction) Serialize() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.Grow(tx.msgTx.SerializeSize())
+	if err := tx.msgTx.Serialize(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (tx *transaction) TxHash() chainhash.Hash {
+	return tx.msgTx.TxHash()
+}
+
+func (tx *transaction) VSize() int {
+	return txVSize(tx.msgTx)
+}
+
 func (tx *transaction) Submit(ctx context.Context) ([]byte, error) {
+	if hasForkID(tx.hashType) {
+		if err := checkReplayProtection(tx.msgTx); err != nil {
+			return nil, err
+		}
+	}
+	amounts := make([]int64, len(tx.requests))
+	for i, request := range tx.requests {
+		amounts[i] = request.Amount
+	}
+	if err := CheckStandard(tx.msgTx, amounts, tx.client.NetworkParams()); err != nil {
+		return nil, err
+	}
 	if err := tx.client.PublishTransaction(ctx, tx.msgTx); err != nil {
 		return nil, err
 	}
-	return hex.DecodeString(tx.msgTx.TxHash().String())
+	return hex.DecodeString(tx.TxHash().String())
+}
+
+func applyBuildOptions(msgTx *wire.MsgTx, options buildOptions) error {
+	for index := range options.sequences {
+		if index < 0 || index >= len(msgTx.TxIn) {
+			return fmt.Errorf("invalid input index %d for sequence number: transaction has %d inputs", index, len(msgTx.TxIn))
+		}
+	}
+
+	msgTx.LockTime = options.lockTime
+	for i, txIn := range msgTx.TxIn {
+		if sequence, ok := options.sequences[i]; ok {
+			txIn.Sequence = sequence
+			continue
+		}
+		switch {
+		case options.rbf:
+			txIn.Sequence = wire.MaxTxInSequenceNum - 2
+		case options.lockTime != 0:
+			txIn.Sequence = wire.MaxTxInSequenceNum - 1
+		}
+	}
+	return nil
+}
+
+// inputAmounts returns the amount of the output spent by every input of the
+// transaction.
+func inputAmounts(msgTx *wire.MsgTx, utxoSets ...[]clients.UTXO) ([]int64, error) {
+	amounts := map[wire.OutPoint]int64{}
+	for _, utxos := range utxoSets {
+		for _, utxo := range utxos {
+			hash, err := chainhash.NewHashFromStr(utxo.TxHash)
+			if err != nil {
+				return nil, err
+			}
+			amounts[*wire.NewOutPoint(hash, utxo.Vout)] = utxo.Amount
+		}
+	}
+	inputs := make([]int64, len(msgTx.TxIn))
+	for i, txIn := range msgTx.TxIn {
+		amount, ok := amounts[txIn.PreviousOutPoint]
+		if !ok {
+			return nil, fmt.Errorf("unknown amount for input %d spending %s", i, txIn.PreviousOutPoint)
+		}
+		inputs[i] = amount
+	}
+	return inputs, nil
+}
+
+// signingPubKey returns the serialized public key signing the input with the
+// script, in the serialization the output pays to, or the default
+// serialization for inputs spending contracts.
+func signingPubKey(script []byte, pubKey *btcec.PublicKey, serialized []byte) []byte {
+	if owner, ok := ownerPubKey(script, pubKey); ok {
+		return owner
+	}
+	return serialized
 }
 
-func fundBtcTx(ctx context.Context, from btcutil.Address, script []byte, client Client, msgTx *wire.MsgTx, utxos []clients.UTXO) (int64, []byte, error) {
+// fundBtcTx adds inputs spending the UTXOs, which must be owned by the public
+// key, or by the P2SH or P2WSH address of the contract if it is not nil, and
+// returns their amount, the script that every input signs and whether it
+// spends a P2WSH output.
+func fundBtcTx(ctx context.Context, pubKey *btcec.PublicKey, script []byte, client Client, msgTx *wire.MsgTx, utxos []clients.UTXO) (int64, [][]byte, []bool, error) {
+	var p2shScript, p2wshScript []byte
 	if script != nil {
-		scriptAddr, err := btcutil.NewAddressScriptHash(script, client.NetworkParams())
+		p2shAddr, err := btcutil.NewAddressScriptHash(script, client.NetworkParams())
 		if err != nil {
-			return 0, nil, err
+			return 0, nil, nil, err
+		}
+		if p2shScript, err = txscript.PayToAddrScript(p2shAddr); err != nil {
+			return 0, nil, nil, err
+		}
+		scriptHash := sha256.Sum256(script)
+		p2wshAddr, err := btcutil.NewAddressWitnessScriptHash(scriptHash[:], client.NetworkParams())
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		if p2wshScript, err = txscript.PayToAddrScript(p2wshAddr); err != nil {
+			return 0, nil, nil, err
 		}
-		from = scriptAddr
 	}
 
 	var amount int64
-	var scriptPubKey []byte
+	var scripts [][]byte
+	var segwit []bool
 	for _, utxo := range utxos {
-		ScriptPubKey, err := hex.DecodeString(utxo.ScriptPubKey)
+		scriptPubKey, err := hex.DecodeString(utxo.ScriptPubKey)
 		if err != nil {
-			return 0, nil, err
+			return 0, nil, nil, err
 		}
-		if len(scriptPubKey) == 0 {
-			scriptPubKey = ScriptPubKey
+		if script != nil {
+			isP2WSH := bytes.Equal(scriptPubKey, p2wshScript)
+			if !isP2WSH && !bytes.Equal(scriptPubKey, p2shScript) {
+				return 0, nil, nil, fmt.Errorf("utxo %s:%d is not owned by the contract", utxo.TxHash, utxo.Vout)
+			}
+			scripts = append(scripts, script)
+			segwit = append(segwit, isP2WSH)
 		} else {
-			if bytes.Compare(scriptPubKey, ScriptPubKey) != 0 {
-				continue
+			if _, ok := ownerPubKey(scriptPubKey, pubKey); !ok {
+				return 0, nil, nil, fmt.Errorf("utxo %s:%d is not owned by the public key", utxo.TxHash, utxo.Vout)
 			}
+			scripts = append(scripts, scriptPubKey)
+			segwit = append(segwit, false)
 		}
 
 		hash, err := chainhash.NewHashFromStr(utxo.TxHash)
 		if err != nil {
-			return 0, nil, err
+			return 0, nil, nil, err
 		}
 		msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, utxo.Vout), []byte{}, [][]byte{}))
 		amount += utxo.Amount
 	}
-
-	if script != nil {
-		return amount, script, nil
-	}
-	return amount, scriptPubKey, nil
+	return amount, scripts, segwit, nil
 }
@@ -0,0 +1,144 @@
+package libbtc
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// UnsignedTx is a transaction built by a TxBuilder along with what is needed to
+// sign it, so that it can be exported as JSON to an offline signer and
+// imported back with ImportTx. Signatures injected before the export are not
+// part of it.
+type UnsignedTx struct {
+	// Tx is the serialized transaction without signature scripts.
+	Tx []byte `json:"tx"`
+	// PubKey is the serialized public key the transaction was built for.
+	PubKey []byte `json:"pubKey"`
+	// Contracts are the redeem scripts spent by every input, nil for the
+	// MasterInputs first inputs, and ContractPushes are pushed before them in
+	// the signature scripts.
+	Contracts      [][]byte   `json:"contracts"`
+	ContractPushes [][][]byte `json:"contractPushes"`
+	MasterInputs   int        `json:"masterInputs"`
+	// Sent is the value transferred by the transaction.
+	Sent int64 `json:"sent"`
+	// HashType is the sighash type the transaction was built with, the
+	// sighash type of every input is in its signing request.
+	HashType txscript.SigHashType `json:"hashType"`
+	// Inputs are the signing requests of the inputs, in order.
+	Inputs []SigningRequest `json:"inputs"`
+}
+
+func (tx *transaction) Export() (*UnsignedTx, error) {
+	msgTx := tx.msgTx.Copy()
+	for _, txIn := range msgTx.TxIn {
+		txIn.SignatureScript = nil
+		txIn.Witness = nil
+	}
+	var txBuffer bytes.Buffer
+	txBuffer.Grow(msgTx.SerializeSize())
+	if err := msgTx.Serialize(&txBuffer); err != nil {
+		return nil, err
+	}
+	pubKey, err := tx.client.SerializePublicKey((*btcec.PublicKey)(&tx.publicKey))
+	if err != nil {
+		return nil, err
+	}
+	return &UnsignedTx{
+		Tx:             txBuffer.Bytes(),
+		PubKey:         pubKey,
+		Contracts:      tx.contracts,
+		ContractPushes: tx.pushes,
+		MasterInputs:   tx.mwIns,
+		Sent:           tx.sent,
+		HashType:       tx.hashType,
+		Inputs:         tx.requests,
+	}, nil
+}
+
+// ImportTx returns the Tx exported as the UnsignedTx, ready for its signatures
+// to be injected. The sighashes are computed again rather than trusted, and
+// every input must spend an output of the public key or of the contract.
+func ImportTx(client Client, unsigned *UnsignedTx) (Tx, error) {
+	msgTx := new(wire.MsgTx)
+	if err := msgTx.Deserialize(bytes.NewReader(unsigned.Tx)); err != nil {
+		return nil, err
+	}
+	if len(unsigned.Inputs) != len(msgTx.TxIn) || len(unsigned.Contracts) != len(msgTx.TxIn) || len(unsigned.ContractPushes) != len(msgTx.TxIn) {
+		return nil, fmt.Errorf("expected %d signing requests, contracts and contract pushes, got %d, %d and %d", len(msgTx.TxIn), len(unsigned.Inputs), len(unsigned.Contracts), len(unsigned.ContractPushes))
+	}
+	if unsigned.MasterInputs < 0 || unsigned.MasterInputs > len(msgTx.TxIn) {
+		return nil, fmt.Errorf("invalid number of master inputs %d: transaction has %d inputs", unsigned.MasterInputs, len(msgTx.TxIn))
+	}
+	pubKey, err := btcec.ParsePubKey(unsigned.PubKey, btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+
+	sigHashes := txscript.NewTxSigHashes(msgTx)
+	hashes := make([][]byte, len(msgTx.TxIn))
+	requests := make([]SigningRequest, len(msgTx.TxIn))
+	scripts := make([][]byte, len(msgTx.TxIn))
+	hashTypes := make([]txscript.SigHashType, len(msgTx.TxIn))
+	segwit := make([]bool, len(msgTx.TxIn))
+	for i, input := range unsigned.Inputs {
+		if input.Index != i || input.OutPoint != msgTx.TxIn[i].PreviousOutPoint {
+			return nil, fmt.Errorf("signing request %d does not match input %d", input.Index, i)
+		}
+		contract := unsigned.Contracts[i]
+		if (contract != nil) != (i >= unsigned.MasterInputs) {
+			return nil, fmt.Errorf("contract of input %d does not match the %d master inputs", i, unsigned.MasterInputs)
+		}
+		if contract != nil {
+			if !bytes.Equal(input.Script, contract) {
+				return nil, fmt.Errorf("input %d does not spend its contract", i)
+			}
+		} else if _, ok := ownerPubKey(input.Script, pubKey); !ok {
+			return nil, fmt.Errorf("input %d is not owned by the public key", i)
+		} else if input.Segwit {
+			return nil, fmt.Errorf("input %d spends a segwit master wallet output", i)
+		}
+		if err := checkSigHashType(msgTx, i, input.HashType); err != nil {
+			return nil, err
+		}
+
+		hash, err := calcSignatureHash(input.Script, sigHashes, input.HashType, msgTx, i, input.Amount, input.Segwit)
+		if err != nil {
+			return nil, err
+		}
+		if input.Hash != nil && !bytes.Equal(input.Hash, hash) {
+			return nil, fmt.Errorf("invalid sighash for input %d", i)
+		}
+
+		hashes[i] = hash
+		scripts[i] = input.Script
+		hashTypes[i] = input.HashType
+		segwit[i] = input.Segwit
+		requests[i] = input
+		requests[i].Hash = hash
+		requests[i].PubKey = signingPubKey(input.Script, pubKey, unsigned.PubKey)
+		requests[i].Contract = contract != nil
+	}
+
+	return &transaction{
+		sent:      unsigned.Sent,
+		hashes:    hashes,
+		requests:  requests,
+		msgTx:     msgTx,
+		client:    client,
+		publicKey: ecdsa.PublicKey(*pubKey),
+		mwIns:     unsigned.MasterInputs,
+		scripts:   scripts,
+		hashType:  unsigned.HashType,
+		hashTypes: hashTypes,
+		contracts: unsigned.Contracts,
+		pushes:    unsigned.ContractPushes,
+		segwit:    segwit,
+		sigs:      make([][]InputSig, len(msgTx.TxIn)),
+	}, nil
+}
@@ -0,0 +1,79 @@
+package ur
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// bytewords are the 256 words of the Bytewords encoding, as defined in
+// BCR-2020-012. The minimal encoding of a byte is the first and last letter of
+// its word, which are unique.
+var bytewords = strings.Fields(`
+	able acid also apex aqua arch atom aunt away axis back bald barn belt beta bias
+	blue body brag brew bulb buzz calm cash cats chef city claw code cola cook cost
+	crux curl cusp cyan dark data days deli dice diet door down draw drop drum dull
+	duty each easy echo edge epic even exam exit eyes fact fair fern figs film fish
+	fizz flap flew flux foxy free frog fuel fund gala game gear gems gift girl glow
+	good gray grim guru gush gyro half hang hard hawk heat help high hill holy hope
+	horn huts iced idea idle inch inky into iris iron item jade jazz join jolt jowl
+	judo jugs jump junk jury keep keno kept keys kick kiln king kite kiwi knob lamb
+	lava lazy leaf legs liar limp lion list logo loud love luau luck lung main many
+	math maze memo menu meow mild mint miss monk nail navy need news next noon note
+	numb obey oboe omit onyx open oval owls paid part peck play plus poem pool pose
+	puff puma purr quad quiz race ramp real redo rich road rock roof ruby ruin runs
+	rust safe saga scar sets silk skew slot soap solo song stub surf swan taco task
+	taxi tent tied time tiny toil tomb toys trip tuna twin ugly undo unit urge user
+	vast very veto vial vibe view visa void vows wall wand warm wasp wave waxy webs
+	what when whiz wolf work yank yawn yell yoga yurt zaps zero zest zinc zone zoom
+`)
+
+var minimalBytewords = func() map[string]byte {
+	minimal := make(map[string]byte, len(bytewords))
+	for i, word := range bytewords {
+		minimal[word[:1]+word[3:]] = byte(i)
+	}
+	return minimal
+}()
+
+// encodeBytewords returns the minimal Bytewords encoding of the data followed
+// by its CRC32 checksum.
+func encodeBytewords(data []byte) string {
+	checksum := make([]byte, 4)
+	binary.BigEndian.PutUint32(checksum, crc32.ChecksumIEEE(data))
+
+	var builder strings.Builder
+	builder.Grow(2 * (len(data) + 4))
+	for _, b := range append(append([]byte{}, data...), checksum...) {
+		word := bytewords[b]
+		builder.WriteByte(word[0])
+		builder.WriteByte(word[3])
+	}
+	return builder.String()
+}
+
+// decodeBytewords decodes a minimal Bytewords encoding, in either case, and
+// checks its checksum.
+func decodeBytewords(encoded string) ([]byte, error) {
+	encoded = strings.ToLower(encoded)
+	if len(encoded)%2 != 0 {
+		return nil, fmt.Errorf("invalid bytewords length %d", len(encoded))
+	}
+	data := make([]byte, len(encoded)/2)
+	for i := range data {
+		b, ok := minimalBytewords[encoded[2*i:2*i+2]]
+		if !ok {
+			return nil, fmt.Errorf("invalid byteword %q", encoded[2*i:2*i+2])
+		}
+		data[i] = b
+	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("bytewords are too short to have a checksum")
+	}
+	data, checksum := data[:len(data)-4], data[len(data)-4:]
+	if binary.BigEndian.Uint32(checksum) != crc32.ChecksumIEEE(data) {
+		return nil, fmt.Errorf("invalid bytewords checksum")
+	}
+	return data, nil
+}
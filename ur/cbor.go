@@ -0,0 +1,178 @@
+package ur
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// The CBOR major types used by URs, as defined in RFC 7049.
+const (
+	cborUint   = 0
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+// The simple values of booleans.
+const (
+	cborFalse = 20
+	cborTrue  = 21
+)
+
+// cborHead appends the head of a data item, in its shortest form as required
+// by the deterministic encoding of URs.
+func cborHead(buf []byte, major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return append(buf, major|byte(arg))
+	case arg <= 0xFF:
+		return append(buf, major|24, byte(arg))
+	case arg <= 0xFFFF:
+		buf = append(buf, major|25, 0, 0)
+		binary.BigEndian.PutUint16(buf[len(buf)-2:], uint16(arg))
+		return buf
+	case arg <= 0xFFFFFFFF:
+		buf = append(buf, major|26, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(buf[len(buf)-4:], uint32(arg))
+		return buf
+	default:
+		buf = append(buf, major|27, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(buf[len(buf)-8:], arg)
+		return buf
+	}
+}
+
+func cborAppendBytes(buf []byte, data []byte) []byte {
+	return append(cborHead(buf, cborBytes, uint64(len(data))), data...)
+}
+
+func cborAppendBool(buf []byte, value bool) []byte {
+	if value {
+		return cborHead(buf, cborSimple, cborTrue)
+	}
+	return cborHead(buf, cborSimple, cborFalse)
+}
+
+// cborReader decodes the data items of a CBOR encoding in order.
+type cborReader struct {
+	data []byte
+	pos  int
+}
+
+func (reader *cborReader) done() bool {
+	return reader.pos == len(reader.data)
+}
+
+func (reader *cborReader) head() (byte, uint64, error) {
+	if reader.pos >= len(reader.data) {
+		return 0, 0, fmt.Errorf("unexpected end of cbor")
+	}
+	initial := reader.data[reader.pos]
+	reader.pos++
+	major, info := initial>>5, initial&0x1F
+	if info < 24 {
+		return major, uint64(info), nil
+	}
+	if info > 27 {
+		return 0, 0, fmt.Errorf("unsupported cbor additional info %d", info)
+	}
+	size := 1 << (info - 24)
+	if reader.pos+size > len(reader.data) {
+		return 0, 0, fmt.Errorf("unexpected end of cbor")
+	}
+	var arg uint64
+	for _, b := range reader.data[reader.pos : reader.pos+size] {
+		arg = arg<<8 | uint64(b)
+	}
+	reader.pos += size
+	return major, arg, nil
+}
+
+func (reader *cborReader) expect(major byte) (uint64, error) {
+	actual, arg, err := reader.head()
+	if err != nil {
+		return 0, err
+	}
+	if actual != major {
+		return 0, fmt.Errorf("expected cbor major type %d, got %d", major, actual)
+	}
+	return arg, nil
+}
+
+func (reader *cborReader) readUint() (uint64, error) {
+	return reader.expect(cborUint)
+}
+
+func (reader *cborReader) readBytes() ([]byte, error) {
+	n, err := reader.expect(cborBytes)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(reader.data)-reader.pos) {
+		return nil, fmt.Errorf("unexpected end of cbor")
+	}
+	// The data is copied, so that decoded values do not alias the encoding.
+	data := append([]byte{}, reader.data[reader.pos:reader.pos+int(n)]...)
+	reader.pos += int(n)
+	return data, nil
+}
+
+func (reader *cborReader) readBool() (bool, error) {
+	value, err := reader.expect(cborSimple)
+	if err != nil {
+		return false, err
+	}
+	switch value {
+	case cborFalse:
+		return false, nil
+	case cborTrue:
+		return true, nil
+	default:
+		return false, fmt.Errorf("expected cbor boolean, got simple value %d", value)
+	}
+}
+
+func (reader *cborReader) readTag(tag uint64) error {
+	actual, err := reader.expect(cborTag)
+	if err != nil {
+		return err
+	}
+	if actual != tag {
+		return fmt.Errorf("expected cbor tag %d, got %d", tag, actual)
+	}
+	return nil
+}
+
+// skip skips a data item of any type, including the items it contains.
+func (reader *cborReader) skip() error {
+	major, arg, err := reader.head()
+	if err != nil {
+		return err
+	}
+	switch major {
+	case cborBytes, cborText:
+		if arg > uint64(len(reader.data)-reader.pos) {
+			return fmt.Errorf("unexpected end of cbor")
+		}
+		reader.pos += int(arg)
+	case cborArray:
+		for i := uint64(0); i < arg; i++ {
+			if err := reader.skip(); err != nil {
+				return err
+			}
+		}
+	case cborMap:
+		for i := uint64(0); i < 2*arg; i++ {
+			if err := reader.skip(); err != nil {
+				return err
+			}
+		}
+	case cborTag:
+		return reader.skip()
+	}
+	return nil
+}
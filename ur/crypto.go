@@ -0,0 +1,404 @@
+package ur
+
+import (
+	"fmt"
+	"math"
+)
+
+// The UR types of the registry of BCR-2020-006 that are supported.
+const (
+	TypePSBT    = "crypto-psbt"
+	TypeAccount = "crypto-account"
+)
+
+// The CBOR tags of the types nested in a crypto-account.
+const (
+	tagHDKey    = 303
+	tagKeyPath  = 304
+	tagCoinInfo = 305
+)
+
+// hardened is the offset of hardened child indices in derivation paths.
+const hardened = 0x80000000
+
+// ScriptExpression is the CBOR tag of a script expression of an output
+// descriptor, as defined in BCR-2020-010.
+type ScriptExpression uint64
+
+// ScriptExpression values.
+const (
+	ScriptSH   = ScriptExpression(400)
+	ScriptWSH  = ScriptExpression(401)
+	ScriptPK   = ScriptExpression(402)
+	ScriptPKH  = ScriptExpression(403)
+	ScriptWPKH = ScriptExpression(404)
+	ScriptTR   = ScriptExpression(409)
+)
+
+// HDKey is the extended public key of a crypto-hdkey.
+type HDKey struct {
+	// Key is the compressed public key.
+	Key       []byte
+	ChainCode []byte
+	Testnet   bool
+
+	// Path is the derivation path of the key from the master key, hardened
+	// indices being offset by 2^31 as in BIP32.
+	Path []uint32
+	// SourceFingerprint is the fingerprint of the master key, or zero if it is
+	// unknown.
+	SourceFingerprint uint32
+	// ParentFingerprint is the fingerprint of the parent of the key.
+	ParentFingerprint uint32
+}
+
+// OutputDescriptor is an output descriptor of a crypto-account: the key, in
+// the script expressions it is wrapped in, outermost first. For example, the
+// descriptor sh(wpkh(key)) has the scripts [ScriptSH, ScriptWPKH].
+type OutputDescriptor struct {
+	Scripts []ScriptExpression
+	Key     HDKey
+}
+
+// Account is a crypto-account: the keys of an account of a wallet, for
+// example the keys at m/44'/0'/0' and m/84'/0'/0', with the outputs they are
+// used in.
+type Account struct {
+	MasterFingerprint uint32
+	Descriptors       []OutputDescriptor
+}
+
+// NewPSBT returns the crypto-psbt UR of the serialized BIP174 partially signed
+// transaction.
+func NewPSBT(psbt []byte) UR {
+	return UR{Type: TypePSBT, CBOR: cborAppendBytes(nil, psbt)}
+}
+
+// PSBT returns the serialized partially signed transaction of a crypto-psbt
+// UR.
+func (ur UR) PSBT() ([]byte, error) {
+	if ur.Type != TypePSBT {
+		return nil, fmt.Errorf("expected %s UR, got %s", TypePSBT, ur.Type)
+	}
+	reader := &cborReader{data: ur.CBOR}
+	psbt, err := reader.readBytes()
+	if err != nil {
+		return nil, err
+	}
+	if !reader.done() {
+		return nil, fmt.Errorf("unexpected data after psbt")
+	}
+	return psbt, nil
+}
+
+// NewAccount returns the crypto-account UR of the account.
+func NewAccount(account Account) (UR, error) {
+	buf := cborHead(nil, cborMap, 2)
+	buf = cborHead(buf, cborUint, 1)
+	buf = cborHead(buf, cborUint, uint64(account.MasterFingerprint))
+	buf = cborHead(buf, cborUint, 2)
+	buf = cborHead(buf, cborArray, uint64(len(account.Descriptors)))
+	for _, descriptor := range account.Descriptors {
+		for _, script := range descriptor.Scripts {
+			buf = cborHead(buf, cborTag, uint64(script))
+		}
+		key, err := descriptor.Key.encode(buf)
+		if err != nil {
+			return UR{}, err
+		}
+		buf = key
+	}
+	return UR{Type: TypeAccount, CBOR: buf}, nil
+}
+
+// Account returns the account of a crypto-account UR. Output descriptors
+// whose key is not a crypto-hdkey, such as multisig ones, are not supported.
+func (ur UR) Account() (Account, error) {
+	if ur.Type != TypeAccount {
+		return Account{}, fmt.Errorf("expected %s UR, got %s", TypeAccount, ur.Type)
+	}
+	reader := &cborReader{data: ur.CBOR}
+	n, err := reader.expect(cborMap)
+	if err != nil {
+		return Account{}, err
+	}
+	account := Account{}
+	for i := uint64(0); i < n; i++ {
+		key, err := reader.readUint()
+		if err != nil {
+			return Account{}, err
+		}
+		switch key {
+		case 1:
+			fingerprint, err := reader.readUint()
+			if err != nil {
+				return Account{}, err
+			}
+			if fingerprint > math.MaxUint32 {
+				return Account{}, fmt.Errorf("invalid master fingerprint %d", fingerprint)
+			}
+			account.MasterFingerprint = uint32(fingerprint)
+		case 2:
+			count, err := reader.expect(cborArray)
+			if err != nil {
+				return Account{}, err
+			}
+			for j := uint64(0); j < count; j++ {
+				descriptor, err := decodeOutputDescriptor(reader)
+				if err != nil {
+					return Account{}, fmt.Errorf("invalid output descriptor %d: %v", j, err)
+				}
+				account.Descriptors = append(account.Descriptors, descriptor)
+			}
+		default:
+			if err := reader.skip(); err != nil {
+				return Account{}, err
+			}
+		}
+	}
+	if !reader.done() {
+		return Account{}, fmt.Errorf("unexpected data after account")
+	}
+	return account, nil
+}
+
+func decodeOutputDescriptor(reader *cborReader) (OutputDescriptor, error) {
+	descriptor := OutputDescriptor{}
+	for {
+		tag, err := reader.expect(cborTag)
+		if err != nil {
+			return OutputDescriptor{}, err
+		}
+		if tag == tagHDKey {
+			break
+		}
+		descriptor.Scripts = append(descriptor.Scripts, ScriptExpression(tag))
+	}
+	if len(descriptor.Scripts) == 0 {
+		return OutputDescriptor{}, fmt.Errorf("missing script expression")
+	}
+	key, err := decodeHDKey(reader)
+	if err != nil {
+		return OutputDescriptor{}, err
+	}
+	descriptor.Key = key
+	return descriptor, nil
+}
+
+// encode appends the tagged crypto-hdkey, with its keys in ascending order as
+// required by the deterministic encoding.
+func (key HDKey) encode(buf []byte) ([]byte, error) {
+	if len(key.Key) != 33 {
+		return nil, fmt.Errorf("expected a 33 byte compressed public key, got %d bytes", len(key.Key))
+	}
+	if len(key.ChainCode) != 32 {
+		return nil, fmt.Errorf("expected a 32 byte chain code, got %d bytes", len(key.ChainCode))
+	}
+
+	fields := uint64(5)
+	if key.ParentFingerprint != 0 {
+		fields++
+	}
+	buf = cborHead(buf, cborTag, tagHDKey)
+	buf = cborHead(buf, cborMap, fields)
+	buf = cborHead(buf, cborUint, 2)
+	buf = cborAppendBool(buf, false)
+	buf = cborHead(buf, cborUint, 3)
+	buf = cborAppendBytes(buf, key.Key)
+	buf = cborHead(buf, cborUint, 4)
+	buf = cborAppendBytes(buf, key.ChainCode)
+
+	buf = cborHead(buf, cborUint, 5)
+	buf = cborHead(buf, cborTag, tagCoinInfo)
+	buf = cborHead(buf, cborMap, 2)
+	buf = cborHead(buf, cborUint, 1)
+	buf = cborHead(buf, cborUint, 0)
+	buf = cborHead(buf, cborUint, 2)
+	if key.Testnet {
+		buf = cborHead(buf, cborUint, 1)
+	} else {
+		buf = cborHead(buf, cborUint, 0)
+	}
+
+	buf = cborHead(buf, cborUint, 6)
+	buf = cborHead(buf, cborTag, tagKeyPath)
+	// The source fingerprint is omitted when it is unknown, as zero is not a
+	// valid fingerprint.
+	if key.SourceFingerprint != 0 {
+		buf = cborHead(buf, cborMap, 3)
+	} else {
+		buf = cborHead(buf, cborMap, 2)
+	}
+	buf = cborHead(buf, cborUint, 1)
+	buf = cborHead(buf, cborArray, uint64(2*len(key.Path)))
+	for _, index := range key.Path {
+		buf = cborHead(buf, cborUint, uint64(index&^hardened))
+		buf = cborAppendBool(buf, index&hardened != 0)
+	}
+	if key.SourceFingerprint != 0 {
+		buf = cborHead(buf, cborUint, 2)
+		buf = cborHead(buf, cborUint, uint64(key.SourceFingerprint))
+	}
+	buf = cborHead(buf, cborUint, 3)
+	buf = cborHead(buf, cborUint, uint64(len(key.Path)))
+
+	if key.ParentFingerprint != 0 {
+		buf = cborHead(buf, cborUint, 8)
+		buf = cborHead(buf, cborUint, uint64(key.ParentFingerprint))
+	}
+	return buf, nil
+}
+
+// decodeHDKey decodes the map of a crypto-hdkey, following its tag. Private
+// keys are rejected, and the keys of the map that are not used by HDKey are
+// skipped.
+func decodeHDKey(reader *cborReader) (HDKey, error) {
+	n, err := reader.expect(cborMap)
+	if err != nil {
+		return HDKey{}, err
+	}
+	key := HDKey{}
+	for i := uint64(0); i < n; i++ {
+		field, err := reader.readUint()
+		if err != nil {
+			return HDKey{}, err
+		}
+		switch field {
+		case 2:
+			private, err := reader.readBool()
+			if err != nil {
+				return HDKey{}, err
+			}
+			if private {
+				return HDKey{}, fmt.Errorf("expected an extended public key")
+			}
+		case 3:
+			if key.Key, err = reader.readBytes(); err != nil {
+				return HDKey{}, err
+			}
+		case 4:
+			if key.ChainCode, err = reader.readBytes(); err != nil {
+				return HDKey{}, err
+			}
+		case 5:
+			if key.Testnet, err = decodeCoinInfo(reader); err != nil {
+				return HDKey{}, err
+			}
+		case 6:
+			if key.Path, key.SourceFingerprint, err = decodeKeyPath(reader); err != nil {
+				return HDKey{}, err
+			}
+		case 8:
+			fingerprint, err := reader.readUint()
+			if err != nil {
+				return HDKey{}, err
+			}
+			if fingerprint > math.MaxUint32 {
+				return HDKey{}, fmt.Errorf("invalid parent fingerprint %d", fingerprint)
+			}
+			key.ParentFingerprint = uint32(fingerprint)
+		default:
+			if err := reader.skip(); err != nil {
+				return HDKey{}, err
+			}
+		}
+	}
+	if len(key.Key) != 33 {
+		return HDKey{}, fmt.Errorf("expected a 33 byte compressed public key, got %d bytes", len(key.Key))
+	}
+	if len(key.ChainCode) != 32 {
+		return HDKey{}, fmt.Errorf("expected a 32 byte chain code, got %d bytes", len(key.ChainCode))
+	}
+	return key, nil
+}
+
+// decodeCoinInfo decodes a tagged crypto-coininfo, and returns whether it is
+// for the Bitcoin testnet.
+func decodeCoinInfo(reader *cborReader) (bool, error) {
+	if err := reader.readTag(tagCoinInfo); err != nil {
+		return false, err
+	}
+	n, err := reader.expect(cborMap)
+	if err != nil {
+		return false, err
+	}
+	testnet := false
+	for i := uint64(0); i < n; i++ {
+		field, err := reader.readUint()
+		if err != nil {
+			return false, err
+		}
+		value, err := reader.readUint()
+		if err != nil {
+			return false, err
+		}
+		switch field {
+		case 1:
+			if value != 0 {
+				return false, fmt.Errorf("unsupported coin type %d", value)
+			}
+		case 2:
+			testnet = value == 1
+		}
+	}
+	return testnet, nil
+}
+
+// decodeKeyPath decodes a tagged crypto-keypath, and returns its path and
+// source fingerprint. Wildcard components are not supported.
+func decodeKeyPath(reader *cborReader) ([]uint32, uint32, error) {
+	if err := reader.readTag(tagKeyPath); err != nil {
+		return nil, 0, err
+	}
+	n, err := reader.expect(cborMap)
+	if err != nil {
+		return nil, 0, err
+	}
+	path := []uint32{}
+	var fingerprint uint64
+	for i := uint64(0); i < n; i++ {
+		field, err := reader.readUint()
+		if err != nil {
+			return nil, 0, err
+		}
+		switch field {
+		case 1:
+			count, err := reader.expect(cborArray)
+			if err != nil {
+				return nil, 0, err
+			}
+			if count%2 != 0 {
+				return nil, 0, fmt.Errorf("expected pairs of path components")
+			}
+			for j := uint64(0); j < count; j += 2 {
+				index, err := reader.readUint()
+				if err != nil {
+					return nil, 0, err
+				}
+				if index >= hardened {
+					return nil, 0, fmt.Errorf("invalid child index %d", index)
+				}
+				isHardened, err := reader.readBool()
+				if err != nil {
+					return nil, 0, err
+				}
+				if isHardened {
+					index += hardened
+				}
+				path = append(path, uint32(index))
+			}
+		case 2:
+			if fingerprint, err = reader.readUint(); err != nil {
+				return nil, 0, err
+			}
+			if fingerprint > math.MaxUint32 {
+				return nil, 0, fmt.Errorf("invalid source fingerprint %d", fingerprint)
+			}
+		default:
+			if err := reader.skip(); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+	return path, uint32(fingerprint), nil
+}
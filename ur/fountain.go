@@ -0,0 +1,415 @@
+package ur
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// minFragmentLen is the length under which messages are not fragmented
+// further, as in the reference implementation.
+const minFragmentLen = 10
+
+// xoshiro is the xoshiro256** generator choosing the fragments mixed in the
+// parts of a fountain code, seeded with the SHA-256 hash of a seed so that the
+// encoder and decoder agree on them.
+type xoshiro [4]uint64
+
+func newXoshiro(seed []byte) *xoshiro {
+	hash := sha256.Sum256(seed)
+	rng := new(xoshiro)
+	for i := range rng {
+		rng[i] = binary.BigEndian.Uint64(hash[8*i:])
+	}
+	return rng
+}
+
+func (rng *xoshiro) next() uint64 {
+	result := bits.RotateLeft64(rng[1]*5, 7) * 9
+	t := rng[1] << 17
+	rng[2] ^= rng[0]
+	rng[3] ^= rng[1]
+	rng[1] ^= rng[2]
+	rng[0] ^= rng[3]
+	rng[2] ^= t
+	rng[3] = bits.RotateLeft64(rng[3], 45)
+	return result
+}
+
+func (rng *xoshiro) nextDouble() float64 {
+	return float64(rng.next()) / (float64(math.MaxUint64) + 1)
+}
+
+// nextInt returns an integer between low and high, both included.
+func (rng *xoshiro) nextInt(low, high int) int {
+	return int(rng.nextDouble()*float64(high-low+1)) + low
+}
+
+// sampler draws indices with the given weights, using the alias method exactly
+// as the reference implementation does.
+type sampler struct {
+	probs   []float64
+	aliases []int
+}
+
+func newSampler(weights []float64) *sampler {
+	n := len(weights)
+	var sum float64
+	for _, weight := range weights {
+		sum += weight
+	}
+	scaled := make([]float64, n)
+	for i, weight := range weights {
+		scaled[i] = weight * float64(n) / sum
+	}
+
+	small, large := []int{}, []int{}
+	for i := n - 1; i >= 0; i-- {
+		if scaled[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+
+	s := &sampler{probs: make([]float64, n), aliases: make([]int, n)}
+	for len(small) > 0 && len(large) > 0 {
+		a, g := small[len(small)-1], large[len(large)-1]
+		small, large = small[:len(small)-1], large[:len(large)-1]
+		s.probs[a] = scaled[a]
+		s.aliases[a] = g
+		scaled[g] += scaled[a] - 1
+		if scaled[g] < 1 {
+			small = append(small, g)
+		} else {
+			large = append(large, g)
+		}
+	}
+	for _, i := range large {
+		s.probs[i] = 1
+	}
+	for _, i := range small {
+		s.probs[i] = 1
+	}
+	return s
+}
+
+func (s *sampler) next(rng *xoshiro) int {
+	r1, r2 := rng.nextDouble(), rng.nextDouble()
+	i := int(float64(len(s.probs)) * r1)
+	if r2 < s.probs[i] {
+		return i
+	}
+	return s.aliases[i]
+}
+
+// chooseFragments returns the sorted indices of the fragments mixed in the
+// part with the sequence number. The first seqLen parts are the fragments
+// themselves, the following ones mix a random number of them, smaller numbers
+// being more likely.
+func chooseFragments(seqNum uint32, seqLen int, checksum uint32) []int {
+	if int(seqNum) <= seqLen {
+		return []int{int(seqNum) - 1}
+	}
+
+	seed := make([]byte, 8)
+	binary.BigEndian.PutUint32(seed, seqNum)
+	binary.BigEndian.PutUint32(seed[4:], checksum)
+	rng := newXoshiro(seed)
+
+	weights := make([]float64, seqLen)
+	for i := range weights {
+		weights[i] = 1 / float64(i+1)
+	}
+	degree := newSampler(weights).next(rng) + 1
+
+	remaining := make([]int, seqLen)
+	for i := range remaining {
+		remaining[i] = i
+	}
+	indices := make([]int, 0, degree)
+	for len(indices) < degree {
+		i := rng.nextInt(0, len(remaining)-1)
+		indices = append(indices, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+	sort.Ints(indices)
+	return indices
+}
+
+// fragmentLen returns the length of the fragments of a message, the largest
+// that divides it in the fewest fragments no longer than maxFragmentLen.
+func fragmentLen(messageLen, maxFragmentLen int) int {
+	maxCount := messageLen / minFragmentLen
+	if maxCount < 1 {
+		maxCount = 1
+	}
+	var length int
+	for count := 1; count <= maxCount; count++ {
+		length = (messageLen + count - 1) / count
+		if length <= maxFragmentLen {
+			break
+		}
+	}
+	return length
+}
+
+// part is a part of a fountain code, which is encoded in CBOR as the array
+// [seqNum, seqLen, messageLen, checksum, data].
+type part struct {
+	seqNum     uint32
+	seqLen     int
+	messageLen int
+	checksum   uint32
+	data       []byte
+}
+
+func (p part) encode() []byte {
+	buf := cborHead(nil, cborArray, 5)
+	buf = cborHead(buf, cborUint, uint64(p.seqNum))
+	buf = cborHead(buf, cborUint, uint64(p.seqLen))
+	buf = cborHead(buf, cborUint, uint64(p.messageLen))
+	buf = cborHead(buf, cborUint, uint64(p.checksum))
+	return cborAppendBytes(buf, p.data)
+}
+
+func decodePart(data []byte) (part, error) {
+	reader := &cborReader{data: data}
+	if n, err := reader.expect(cborArray); err != nil || n != 5 {
+		return part{}, fmt.Errorf("invalid part: expected an array of 5 items")
+	}
+	fields := make([]uint64, 4)
+	for i := range fields {
+		field, err := reader.readUint()
+		if err != nil {
+			return part{}, fmt.Errorf("invalid part: %v", err)
+		}
+		fields[i] = field
+	}
+	fragment, err := reader.readBytes()
+	if err != nil {
+		return part{}, fmt.Errorf("invalid part: %v", err)
+	}
+	if !reader.done() {
+		return part{}, fmt.Errorf("invalid part: unexpected data after fragment")
+	}
+	if fields[0] == 0 || fields[0] > math.MaxUint32 || fields[1] == 0 || fields[1] > math.MaxUint32 ||
+		fields[2] == 0 || fields[2] > math.MaxUint32 || fields[3] > math.MaxUint32 || len(fragment) == 0 {
+		return part{}, fmt.Errorf("invalid part: field out of range")
+	}
+	return part{
+		seqNum:     uint32(fields[0]),
+		seqLen:     int(fields[1]),
+		messageLen: int(fields[2]),
+		checksum:   uint32(fields[3]),
+		data:       fragment,
+	}, nil
+}
+
+// fountainEncoder splits a message into fragments and emits an endless
+// sequence of parts, from which the message can be recovered once enough of
+// them have been received, in any order.
+type fountainEncoder struct {
+	messageLen int
+	checksum   uint32
+	fragments  [][]byte
+	seqNum     uint32
+}
+
+func newFountainEncoder(message []byte, maxFragmentLen int) *fountainEncoder {
+	length, count := fragmentLen(len(message), maxFragmentLen), 1
+	if length > 0 {
+		count = (len(message) + length - 1) / length
+	}
+	padded := make([]byte, length*count)
+	copy(padded, message)
+
+	fragments := make([][]byte, count)
+	for i := range fragments {
+		fragments[i] = padded[i*length : (i+1)*length]
+	}
+	return &fountainEncoder{
+		messageLen: len(message),
+		checksum:   crc32.ChecksumIEEE(message),
+		fragments:  fragments,
+	}
+}
+
+func (encoder *fountainEncoder) nextPart() part {
+	encoder.seqNum++
+	data := make([]byte, len(encoder.fragments[0]))
+	for _, i := range chooseFragments(encoder.seqNum, len(encoder.fragments), encoder.checksum) {
+		xor(data, encoder.fragments[i])
+	}
+	return part{
+		seqNum:     encoder.seqNum,
+		seqLen:     len(encoder.fragments),
+		messageLen: encoder.messageLen,
+		checksum:   encoder.checksum,
+		data:       data,
+	}
+}
+
+func xor(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// mixedPart is a received part mixing several fragments that are not known
+// yet.
+type mixedPart struct {
+	indices []int
+	data    []byte
+}
+
+// fountainDecoder recovers a message from the parts of a fountainEncoder. Each
+// part reduces the mixed parts received before it, until every fragment is
+// known.
+type fountainDecoder struct {
+	seqLen     int
+	messageLen int
+	checksum   uint32
+	fragLen    int
+
+	fragments map[int][]byte
+	mixed     []mixedPart
+}
+
+func (decoder *fountainDecoder) receive(p part) error {
+	if decoder.fragments == nil {
+		if p.seqLen != (p.messageLen+len(p.data)-1)/len(p.data) {
+			return fmt.Errorf("invalid part: inconsistent lengths")
+		}
+		decoder.seqLen = p.seqLen
+		decoder.messageLen = p.messageLen
+		decoder.checksum = p.checksum
+		decoder.fragLen = len(p.data)
+		decoder.fragments = map[int][]byte{}
+	} else if p.seqLen != decoder.seqLen || p.messageLen != decoder.messageLen ||
+		p.checksum != decoder.checksum || len(p.data) != decoder.fragLen {
+		return fmt.Errorf("part %d does not belong to the message being decoded", p.seqNum)
+	}
+	if decoder.complete() {
+		return nil
+	}
+
+	queue := []mixedPart{{chooseFragments(p.seqNum, p.seqLen, p.checksum), append([]byte{}, p.data...)}}
+	for len(queue) > 0 {
+		mixed := decoder.reduce(queue[0])
+		queue = queue[1:]
+		switch len(mixed.indices) {
+		case 0:
+			// Every fragment of the part is already known.
+		case 1:
+			decoder.fragments[mixed.indices[0]] = mixed.data
+			// The mixed parts including the fragment are reduced again.
+			remaining := decoder.mixed[:0]
+			for _, other := range decoder.mixed {
+				if contains(other.indices, mixed.indices[0]) {
+					queue = append(queue, other)
+				} else {
+					remaining = append(remaining, other)
+				}
+			}
+			decoder.mixed = remaining
+		default:
+			// The mixed parts are reduced by the ones mixing a subset of
+			// their fragments.
+			for _, other := range decoder.mixed {
+				if subset(other.indices, mixed.indices) {
+					mixed = subtract(mixed, other)
+				}
+			}
+			if len(mixed.indices) < 2 {
+				queue = append(queue, mixed)
+				continue
+			}
+			remaining := decoder.mixed[:0]
+			for _, other := range decoder.mixed {
+				if subset(mixed.indices, other.indices) {
+					queue = append(queue, subtract(other, mixed))
+				} else {
+					remaining = append(remaining, other)
+				}
+			}
+			decoder.mixed = append(remaining, mixed)
+		}
+	}
+	return nil
+}
+
+// subset returns whether the sorted indices a are all in the sorted indices b.
+func subset(a, b []int) bool {
+	j := 0
+	for _, i := range a {
+		for j < len(b) && b[j] < i {
+			j++
+		}
+		if j == len(b) || b[j] != i {
+			return false
+		}
+	}
+	return true
+}
+
+// subtract removes the fragments of b, which are a subset of those of a, from
+// a.
+func subtract(a, b mixedPart) mixedPart {
+	data := append([]byte{}, a.data...)
+	xor(data, b.data)
+	indices := []int{}
+	for _, i := range a.indices {
+		if !contains(b.indices, i) {
+			indices = append(indices, i)
+		}
+	}
+	return mixedPart{indices, data}
+}
+
+// reduce removes the known fragments from the mixed part.
+func (decoder *fountainDecoder) reduce(mixed mixedPart) mixedPart {
+	indices := []int{}
+	for _, i := range mixed.indices {
+		if fragment, ok := decoder.fragments[i]; ok {
+			xor(mixed.data, fragment)
+		} else {
+			indices = append(indices, i)
+		}
+	}
+	mixed.indices = indices
+	return mixed
+}
+
+func contains(indices []int, index int) bool {
+	for _, i := range indices {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+func (decoder *fountainDecoder) complete() bool {
+	return decoder.fragments != nil && len(decoder.fragments) == decoder.seqLen
+}
+
+// message returns the recovered message, once every fragment is known.
+func (decoder *fountainDecoder) message() ([]byte, error) {
+	if !decoder.complete() {
+		return nil, fmt.Errorf("message is incomplete")
+	}
+	message := make([]byte, 0, decoder.fragLen*decoder.seqLen)
+	for i := 0; i < decoder.seqLen; i++ {
+		message = append(message, decoder.fragments[i]...)
+	}
+	message = message[:decoder.messageLen]
+	if crc32.ChecksumIEEE(message) != decoder.checksum {
+		return nil, fmt.Errorf("invalid message checksum")
+	}
+	return message, nil
+}
@@ -0,0 +1,188 @@
+package ur
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxFragmentLen is the maximum length of the fragments of a multipart
+// UR that fits in QR codes most cameras scan reliably.
+const DefaultMaxFragmentLen = 200
+
+// UR is a Uniform Resource, as defined in BCR-2020-005: CBOR data along with
+// its registered type, such as crypto-psbt.
+type UR struct {
+	Type string
+	CBOR []byte
+}
+
+// Encode returns the UR as a single part, such as "ur:crypto-psbt/...".
+func (ur UR) Encode() string {
+	return "ur:" + ur.Type + "/" + encodeBytewords(ur.CBOR)
+}
+
+// Decode decodes a single part UR, in either case.
+func Decode(encoded string) (UR, error) {
+	urType, components, err := parse(encoded)
+	if err != nil {
+		return UR{}, err
+	}
+	if len(components) != 1 {
+		return UR{}, fmt.Errorf("expected a single part UR")
+	}
+	data, err := decodeBytewords(components[0])
+	if err != nil {
+		return UR{}, err
+	}
+	return UR{Type: urType, CBOR: data}, nil
+}
+
+func parse(encoded string) (string, []string, error) {
+	encoded = strings.ToLower(encoded)
+	if !strings.HasPrefix(encoded, "ur:") {
+		return "", nil, fmt.Errorf("invalid UR %q: missing ur: scheme", encoded)
+	}
+	components := strings.Split(strings.TrimPrefix(encoded, "ur:"), "/")
+	if len(components) < 2 || len(components) > 3 {
+		return "", nil, fmt.Errorf("invalid UR %q", encoded)
+	}
+	urType := components[0]
+	if urType == "" || strings.Trim(urType, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+		return "", nil, fmt.Errorf("invalid UR type %q", urType)
+	}
+	return urType, components[1:], nil
+}
+
+// Encoder encodes a UR in the parts of an animated QR code. If the UR fits in
+// one fragment, every part is the single part UR. Otherwise the first parts
+// are its fragments, and the following ones mix them in a fountain code so
+// that a decoder can start scanning at any time.
+type Encoder struct {
+	ur       UR
+	fountain *fountainEncoder
+}
+
+// NewEncoder returns an Encoder of the UR with fragments no longer than
+// maxFragmentLen bytes.
+func NewEncoder(ur UR, maxFragmentLen int) *Encoder {
+	return &Encoder{
+		ur:       ur,
+		fountain: newFountainEncoder(ur.CBOR, maxFragmentLen),
+	}
+}
+
+// SeqLen returns the number of fragments of the UR, which is the minimum
+// number of parts a decoder needs.
+func (encoder *Encoder) SeqLen() int {
+	return len(encoder.fountain.fragments)
+}
+
+// NextPart returns the next part of the UR, such as "ur:crypto-psbt/1-3/...".
+// The parts are uppercase, so that they are encoded in the alphanumeric mode of
+// QR codes.
+func (encoder *Encoder) NextPart() string {
+	if encoder.SeqLen() == 1 {
+		return strings.ToUpper(encoder.ur.Encode())
+	}
+	p := encoder.fountain.nextPart()
+	encoded := fmt.Sprintf("ur:%s/%d-%d/%s", encoder.ur.Type, p.seqNum, p.seqLen, encodeBytewords(p.encode()))
+	return strings.ToUpper(encoded)
+}
+
+// Decoder decodes a UR from the parts of an Encoder, received in any order.
+type Decoder struct {
+	urType   string
+	fountain fountainDecoder
+	result   *UR
+}
+
+// NewDecoder returns an empty Decoder.
+func NewDecoder() *Decoder {
+	return &Decoder{}
+}
+
+// Receive decodes a part. Parts already received, or received once the UR is
+// complete, are ignored.
+func (decoder *Decoder) Receive(encoded string) error {
+	urType, components, err := parse(encoded)
+	if err != nil {
+		return err
+	}
+	if decoder.urType != "" && urType != decoder.urType {
+		return fmt.Errorf("expected a %s part, got %s", decoder.urType, urType)
+	}
+	if decoder.result != nil {
+		return nil
+	}
+
+	if len(components) == 1 {
+		data, err := decodeBytewords(components[0])
+		if err != nil {
+			return err
+		}
+		decoder.urType = urType
+		decoder.result = &UR{Type: urType, CBOR: data}
+		return nil
+	}
+
+	seq := strings.Split(components[0], "-")
+	if len(seq) != 2 {
+		return fmt.Errorf("invalid sequence %q", components[0])
+	}
+	seqNum, err := strconv.ParseUint(seq[0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid sequence %q: %v", components[0], err)
+	}
+	seqLen, err := strconv.ParseUint(seq[1], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid sequence %q: %v", components[0], err)
+	}
+	data, err := decodeBytewords(components[1])
+	if err != nil {
+		return err
+	}
+	p, err := decodePart(data)
+	if err != nil {
+		return err
+	}
+	if uint64(p.seqNum) != seqNum || uint64(p.seqLen) != seqLen {
+		return fmt.Errorf("sequence %q does not match part %d-%d", components[0], p.seqNum, p.seqLen)
+	}
+	if err := decoder.fountain.receive(p); err != nil {
+		return err
+	}
+	decoder.urType = urType
+	if decoder.fountain.complete() {
+		message, err := decoder.fountain.message()
+		if err != nil {
+			return err
+		}
+		decoder.result = &UR{Type: urType, CBOR: message}
+	}
+	return nil
+}
+
+// Complete returns whether enough parts have been received to decode the UR.
+func (decoder *Decoder) Complete() bool {
+	return decoder.result != nil
+}
+
+// Progress returns the fraction of the fragments of the UR that are known.
+func (decoder *Decoder) Progress() float64 {
+	if decoder.result != nil {
+		return 1
+	}
+	if decoder.fountain.seqLen == 0 {
+		return 0
+	}
+	return float64(len(decoder.fountain.fragments)) / float64(decoder.fountain.seqLen)
+}
+
+// Result returns the decoded UR, once it is complete.
+func (decoder *Decoder) Result() (UR, error) {
+	if decoder.result == nil {
+		return UR{}, fmt.Errorf("UR is incomplete")
+	}
+	return *decoder.result, nil
+}
@@ -0,0 +1,13 @@
+package ur_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestUR(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "UR Suite")
+}
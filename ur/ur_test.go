@@ -0,0 +1,134 @@
+package ur_test
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go/ur"
+)
+
+// xoshiro is the xoshiro256** generator of the reference implementation, which
+// its test vectors use to make messages.
+type xoshiro [4]uint64
+
+func newXoshiro(seed string) *xoshiro {
+	hash := sha256.Sum256([]byte(seed))
+	rng := new(xoshiro)
+	for i := range rng {
+		rng[i] = binary.BigEndian.Uint64(hash[8*i:])
+	}
+	return rng
+}
+
+func (rng *xoshiro) next() uint64 {
+	result := bits.RotateLeft64(rng[1]*5, 7) * 9
+	t := rng[1] << 17
+	rng[2] ^= rng[0]
+	rng[3] ^= rng[1]
+	rng[1] ^= rng[2]
+	rng[0] ^= rng[3]
+	rng[2] ^= t
+	rng[3] = bits.RotateLeft64(rng[3], 45)
+	return result
+}
+
+// makeMessage returns the message of the given length made from the seed, as
+// make_message in the reference implementation.
+func makeMessage(length int, seed string) []byte {
+	rng := newXoshiro(seed)
+	message := make([]byte, length)
+	for i := range message {
+		message[i] = byte(float64(rng.next()) / (float64(math.MaxUint64) + 1) * 256)
+	}
+	return message
+}
+
+// makeMessageUR returns a bytes UR of the message of the given length made
+// from the seed "Wolf", as make_message_ur in the reference implementation.
+func makeMessageUR(length int) UR {
+	message := makeMessage(length, "Wolf")
+	var head []byte
+	switch {
+	case length < 24:
+		head = []byte{0x40 | byte(length)}
+	case length < 1<<8:
+		head = []byte{0x58, byte(length)}
+	default:
+		head = []byte{0x59, byte(length >> 8), byte(length)}
+	}
+	return UR{Type: "bytes", CBOR: append(head, message...)}
+}
+
+var _ = Describe("UR", func() {
+	Context("when generating the messages of the reference test vectors", func() {
+		It("should match the random numbers of the reference implementation", func() {
+			rng := newXoshiro("Wolf")
+			numbers := make([]uint64, 100)
+			for i := range numbers {
+				numbers[i] = rng.next() % 100
+			}
+			Expect(numbers).Should(Equal([]uint64{
+				42, 81, 85, 8, 82, 84, 76, 73, 70, 88, 2, 74, 40, 48, 77, 54, 88, 7, 5, 88,
+				37, 25, 82, 13, 69, 59, 30, 39, 11, 82, 19, 99, 45, 87, 30, 15, 32, 22, 89, 44,
+				92, 77, 29, 78, 4, 92, 44, 68, 92, 69, 1, 42, 89, 50, 37, 84, 63, 34, 32, 3,
+				17, 62, 40, 98, 82, 89, 24, 43, 85, 39, 15, 3, 99, 29, 20, 42, 27, 10, 85, 66,
+				50, 35, 69, 70, 70, 74, 30, 13, 72, 54, 11, 5, 70, 55, 91, 52, 10, 43, 43, 52,
+			}))
+		})
+	})
+
+	Context("when encoding single part URs", func() {
+		It("should encode bytewords with their checksum", func() {
+			Expect(UR{Type: "bytes", CBOR: []byte{0, 1, 2, 128, 255}}.Encode()).Should(Equal("ur:bytes/aeadaolazmjendeoti"))
+		})
+
+		It("should match the reference test vector", func() {
+			ur := makeMessageUR(50)
+			encoded := ur.Encode()
+			Expect(encoded).Should(Equal("ur:bytes/hdeymejtswhhylkepmykhhtsytsnoyoyaxaedsuttydmmhhpktpmsrjtgwdpfnsboxgwlbaawzuefywkdplrsrjynbvygabwjldapfcsdwkbrkch"))
+			decoded, err := Decode(encoded)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(decoded).Should(Equal(ur))
+			decoded, err = Decode(strings.ToUpper(encoded))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(decoded).Should(Equal(ur))
+		})
+
+		It("should reject corrupted checksums", func() {
+			_, err := Decode("ur:bytes/aeadaolazmjendeota")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("when encoding multipart URs", func() {
+		It("should match the fountain code parts of the reference test vector", func() {
+			encoder := NewEncoder(makeMessageUR(256), 30)
+			Expect(encoder.SeqLen()).Should(Equal(9))
+			Expect(encoder.NextPart()).Should(Equal(strings.ToUpper("ur:bytes/1-9/lpadascfadaxcywenbpljkhdcahkadaemejtswhhylkepmykhhtsytsnoyoyaxaedsuttydmmhhpktpmsrjtdkgslpgh")))
+			Expect(encoder.NextPart()).Should(Equal(strings.ToUpper("ur:bytes/2-9/lpaoascfadaxcywenbpljkhdcagwdpfnsboxgwlbaawzuefywkdplrsrjynbvygabwjldapfcsgmghhkhstlrdcxaefz")))
+		})
+
+		It("should decode from the mixed parts past the fragments", func() {
+			ur := makeMessageUR(32767)
+			encoder := NewEncoder(ur, 1000)
+			// The fragments themselves are skipped, so that the message can
+			// only be recovered from the parts mixing several of them.
+			for i := 0; i < encoder.SeqLen(); i++ {
+				encoder.NextPart()
+			}
+			decoder := NewDecoder()
+			for parts := 0; !decoder.Complete(); parts++ {
+				Expect(parts).Should(BeNumerically("<", 10*encoder.SeqLen()))
+				Expect(decoder.Receive(encoder.NextPart())).Should(Succeed())
+			}
+			decoded, err := decoder.Result()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(decoded).Should(Equal(ur))
+		})
+	})
+})
@@ -0,0 +1,87 @@
+// Package usdt sends Tether (USDT) on the Omni Layer from an account, without
+// callers having to know the Omni encoding.
+package usdt
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/renproject/libbtc-go"
+)
+
+// PropertyID is the Omni property ID of USDT.
+const PropertyID = libbtc.USDTPropertyID
+
+// Decimals is the number of decimals of USDT amounts.
+const Decimals = 8
+
+// ParseAmount converts an amount of USDT, such as "12.5", to willets, the
+// smallest unit of Omni properties.
+func ParseAmount(amount string) (int64, error) {
+	parts := strings.Split(amount, ".")
+	if len(parts) > 2 || len(parts) == 2 && (len(parts[1]) == 0 || len(parts[1]) > Decimals) {
+		return 0, fmt.Errorf("invalid usdt amount %s", amount)
+	}
+	whole, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || whole < 0 {
+		return 0, fmt.Errorf("invalid usdt amount %s", amount)
+	}
+	var fraction int64
+	if len(parts) == 2 {
+		if fraction, err = strconv.ParseInt(parts[1]+strings.Repeat("0", Decimals-len(parts[1])), 10, 64); err != nil || fraction < 0 {
+			return 0, fmt.Errorf("invalid usdt amount %s", amount)
+		}
+	}
+	if whole > (1<<63-1-fraction)/1e8 {
+		return 0, fmt.Errorf("usdt amount %s is too large", amount)
+	}
+	value := whole*1e8 + fraction
+	if value == 0 {
+		return 0, fmt.Errorf("usdt amount must be positive")
+	}
+	return value, nil
+}
+
+// SimpleSendScript returns the OP_RETURN script of an Omni simple send of the
+// amount, in willets, of the property.
+func SimpleSendScript(propertyID uint32, amount int64) ([]byte, error) {
+	return libbtc.OmniSimpleSendScript(propertyID, amount)
+}
+
+// Option configures the layout of the transactions of transfers, for the Omni
+// wallets that find the reference output by its position.
+type Option = libbtc.OmniOption
+
+// WithReferenceIndex sets the position of the reference output, which pays
+// the recipient, relative to the Omni payload: 0, the default, puts it before
+// the payload and 1 after.
+func WithReferenceIndex(index int) Option {
+	return libbtc.OmniReferenceIndex(index)
+}
+
+// WithChangeIndex sets the index of the bitcoin change output in the
+// transaction, from 0 to 2. By default, the change is the last output.
+func WithChangeIndex(index int) Option {
+	return libbtc.OmniChangeIndex(index)
+}
+
+// WithReferenceValue sets the value of the reference output, which is the
+// dust of the network by default.
+func WithReferenceValue(value int64) Option {
+	return libbtc.OmniReferenceValue(value)
+}
+
+// Transfer sends the amount of USDT, in willets, from the account to the
+// address, and returns the hash of the transaction and its fee. See
+// Account.TransferUSDT.
+func Transfer(ctx context.Context, account libbtc.Account, to string, amount int64, speed libbtc.TxExecutionSpeed, opts ...Option) (string, int64, error) {
+	return account.TransferUSDT(ctx, to, amount, speed, opts...)
+}
+
+// BuildTransfer builds and signs a transaction sending the amount of USDT, in
+// willets, from the account to the address. See Account.BuildTransferUSDT.
+func BuildTransfer(ctx context.Context, account libbtc.Account, to string, amount int64, speed libbtc.TxExecutionSpeed, opts ...Option) (string, []byte, error) {
+	return account.BuildTransferUSDT(ctx, to, amount, speed, opts...)
+}
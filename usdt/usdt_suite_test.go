@@ -0,0 +1,26 @@
+package usdt_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/renproject/libbtc-go"
+	"github.com/renproject/libbtc-go/clients/mock"
+)
+
+func TestUSDT(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "USDT Suite")
+}
+
+// newMockAccount returns an account of a new key on a new mock chain.
+func newMockAccount() (*mock.Chain, libbtc.Account) {
+	chain := mock.NewChain(&chaincfg.RegressionNetParams)
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	Expect(err).ShouldNot(HaveOccurred())
+	return chain, libbtc.NewAccount(libbtc.NewClientFromCore(chain), libbtc.NewPrivateKeySigner(key.ToECDSA()), nil)
+}
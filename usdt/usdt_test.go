@@ -0,0 +1,93 @@
+package usdt_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go/usdt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+var _ = Describe("USDT", func() {
+	Context("when parsing amounts", func() {
+		It("should convert amounts to willets", func() {
+			for amount, willets := range map[string]int64{
+				"1":          1e8,
+				"12.5":       1250000000,
+				"0.00000001": 1,
+			} {
+				value, err := ParseAmount(amount)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(value).Should(Equal(willets))
+			}
+		})
+
+		It("should reject invalid amounts", func() {
+			for _, amount := range []string{"", "0", "-1", "1.", "1.123456789", "1.2.3", "abc", "100000000000000"} {
+				_, err := ParseAmount(amount)
+				Expect(err).Should(HaveOccurred(), amount)
+			}
+		})
+	})
+
+	Context("when building a transfer", func() {
+		It("should encode a simple send of USDT", func() {
+			script, err := SimpleSendScript(PropertyID, 150000000)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(script).Should(Equal([]byte{
+				txscript.OP_RETURN, 20, 'o', 'm', 'n', 'i',
+				0, 0, 0, 0, 0, 0, 0, 31,
+				0, 0, 0, 0, 0x08, 0xf0, 0xd1, 0x80,
+			}))
+		})
+
+		// newFundedAccount returns an account funded on a new mock chain,
+		// and the address of a recipient.
+		newFundedAccount := func() (libbtc.Account, string) {
+			chain, account := newMockAccount()
+			address, err := account.Address()
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = chain.Fund(address.EncodeAddress(), 100000)
+			Expect(err).ShouldNot(HaveOccurred())
+			chain.Mine(1)
+
+			recipient, err := btcec.NewPrivateKey(btcec.S256())
+			Expect(err).ShouldNot(HaveOccurred())
+			to, err := account.PublicKeyToAddress(recipient.PubKey().SerializeCompressed())
+			Expect(err).ShouldNot(HaveOccurred())
+			return account, to.EncodeAddress()
+		}
+
+		It("should pay the reference before the payload and the change last", func() {
+			account, to := newFundedAccount()
+			_, stx, err := BuildTransfer(context.Background(), account, to, 150000000, libbtc.Fast)
+			Expect(err).ShouldNot(HaveOccurred())
+			tx, err := btcutil.NewTxFromBytes(stx)
+			Expect(err).ShouldNot(HaveOccurred())
+			txOuts := tx.MsgTx().TxOut
+			Expect(txOuts).Should(HaveLen(3))
+
+			_, addresses, _, err := txscript.ExtractPkScriptAddrs(txOuts[0].PkScript, account.NetworkParams())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addresses[0].EncodeAddress()).Should(Equal(to))
+			Expect(txOuts[0].Value).Should(Equal(libbtc.NetworkDust(account.NetworkParams())))
+			script, err := SimpleSendScript(PropertyID, 150000000)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(txOuts[1].PkScript).Should(Equal(script))
+			Expect(txOuts[2].Value).Should(BeNumerically(">", 90000))
+		})
+
+		It("should check the fee against the value of every output", func() {
+			account, to := newFundedAccount()
+			account.SetMaxFeePercent(0.1)
+			_, _, err := BuildTransfer(context.Background(), account, to, 150000000, libbtc.Fast)
+			Expect(errors.Is(err, errors.ErrAbsurdFee)).Should(BeTrue())
+		})
+	})
+})
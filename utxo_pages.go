@@ -0,0 +1,78 @@
+package libbtc
+
+import (
+	"context"
+
+	"github.com/renproject/libbtc-go/clients"
+)
+
+// DefaultUTXOPageSize is the page size used by GetUTXOsPaged when the given
+// one is not positive.
+const DefaultUTXOPageSize = 250
+
+// UTXOPage is a page of the outputs of an address sent by GetUTXOsPaged. Err
+// is set when fetching the page failed, in which case it is the last page.
+type UTXOPage struct {
+	UTXOs []clients.UTXO
+	Err   error
+}
+
+func (client *client) GetUTXOsPaged(ctx context.Context, address string, pageSize int64) (<-chan UTXOPage, error) {
+	if err := client.Validate(address); err != nil {
+		return nil, err
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultUTXOPageSize
+	}
+
+	pages := make(chan UTXOPage)
+	go func() {
+		defer close(pages)
+		send := func(page UTXOPage) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case pages <- page:
+				return page.Err == nil
+			}
+		}
+
+		pager, ok := client.ClientCore.(clients.UTXOPager)
+		if !ok {
+			// The backend returns every output at once, so they are only
+			// split into pages.
+			utxos, err := client.GetUTXOs(ctx, address, 999999, 0)
+			if err != nil {
+				send(UTXOPage{Err: err})
+				return
+			}
+			for len(utxos) > 0 {
+				n := int64(len(utxos))
+				if n > pageSize {
+					n = pageSize
+				}
+				if !send(UTXOPage{UTXOs: utxos[:n]}) {
+					return
+				}
+				utxos = utxos[n:]
+			}
+			return
+		}
+
+		for offset := int64(0); ; {
+			utxos, err := pager.GetUTXOPage(ctx, address, offset, pageSize, 0)
+			if err != nil {
+				send(UTXOPage{Err: err})
+				return
+			}
+			if len(utxos) == 0 {
+				return
+			}
+			if !send(UTXOPage{UTXOs: utxos}) {
+				return
+			}
+			offset += int64(len(utxos))
+		}
+	}()
+	return pages, nil
+}
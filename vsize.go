@@ -0,0 +1,212 @@
+package libbtc
+
+import (
+	"sync"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// InputType is the kind of output an input spends, which determines its size.
+// Other types than the ones below are registered with RegisterInputType,
+// P2SHMultisigInputType and ContractInputType.
+type InputType uint16
+
+// InputType values. The inputs are assumed to use compressed public keys,
+// unless stated otherwise.
+const (
+	InputP2PKH = InputType(iota)
+	InputP2SHP2WPKH
+	InputP2WPKH
+	InputP2PKHUncompressed
+	InputP2PK
+)
+
+// OutputType is the kind of script an output pays to.
+type OutputType uint8
+
+// OutputType values.
+const (
+	OutputP2PKH = OutputType(iota)
+	OutputP2SH
+	OutputP2WPKH
+	OutputP2WSH
+)
+
+// witnessScaleFactor is the weight of a non-witness byte, witness bytes
+// weighing 1, as defined in BIP141.
+const witnessScaleFactor = 4
+
+// inputWeights are the non-witness and witness sizes of the input types, with
+// signatures of the maximum size. The witness size includes the number of
+// witness items. Registered input types are added to it, under inputWeightsMu.
+var inputWeights = map[InputType][2]int{
+	// outpoint, script length, <sig> <pubKey>, sequence
+	InputP2PKH: {32 + 4 + 1 + (1 + maxSigSize + 1 + 33) + 4, 0},
+	// outpoint, script length, <0 <hash160>>, sequence; <sig> <pubKey>
+	InputP2SHP2WPKH: {32 + 4 + 1 + 23 + 4, 1 + (1 + maxSigSize) + (1 + 33)},
+	// outpoint, script length, sequence; <sig> <pubKey>
+	InputP2WPKH: {32 + 4 + 1 + 4, 1 + (1 + maxSigSize) + (1 + 33)},
+	// outpoint, script length, <sig> <uncompressed pubKey>, sequence
+	InputP2PKHUncompressed: {32 + 4 + 1 + (1 + maxSigSize + 1 + 65) + 4, 0},
+	// outpoint, script length, <sig>, sequence
+	InputP2PK: {32 + 4 + 1 + (1 + maxSigSize) + 4, 0},
+}
+
+var inputWeightsMu = new(sync.RWMutex)
+
+// RegisterInputType registers the input type of a signed input with the given
+// non-witness and witness sizes, which EstimateVSize then accounts for. The
+// witness size includes the number of witness items, and is zero for inputs
+// without a witness. Registering the sizes of an existing input type returns
+// that type.
+func RegisterInputType(size, witnessSize int) InputType {
+	inputWeightsMu.Lock()
+	defer inputWeightsMu.Unlock()
+	weight := [2]int{size, witnessSize}
+	for inputType, inputWeight := range inputWeights {
+		if inputWeight == weight {
+			return inputType
+		}
+	}
+	inputType := InputType(len(inputWeights))
+	inputWeights[inputType] = weight
+	return inputType
+}
+
+// P2SHMultisigInputType returns the input type of inputs spending an m-of-n
+// multisig redeem script of compressed public keys through P2SH.
+func P2SHMultisigInputType(m, n int) InputType {
+	// OP_m <pubKey>... OP_n OP_CHECKMULTISIG
+	redeemScriptSize := 1 + n*(1+33) + 1 + 1
+	// OP_0 <sig>... <redeemScript>
+	sigScriptSize := 1 + m*(1+maxSigSize) + pushSize(redeemScriptSize)
+	return RegisterInputType(sigScriptInputSize(sigScriptSize), 0)
+}
+
+// ContractInputType returns the input type of inputs spending the contract
+// through P2SH, with the signature script of TxBuilder and accounts: the
+// signature, the compressed public key, the template and the contract.
+func ContractInputType(contract []byte, template [][]byte) InputType {
+	templateSize := 0
+	for _, push := range template {
+		templateSize += dataPushSize(push)
+	}
+	return contractInputType(contract, 33, templateSize)
+}
+
+// contractInputType returns the input type of inputs spending the contract
+// with a public key and a template of the given sizes.
+func contractInputType(contract []byte, pubKeySize, templateSize int) InputType {
+	sigScriptSize := pushSize(maxSigSize) + pushSize(pubKeySize) + templateSize + pushSize(len(contract))
+	return RegisterInputType(sigScriptInputSize(sigScriptSize), 0)
+}
+
+// scriptInputType returns the input type of inputs spending the public key
+// script, assuming P2PKH for the scripts it does not recognise.
+func scriptInputType(scriptPubKey []byte) InputType {
+	switch txscript.GetScriptClass(scriptPubKey) {
+	case txscript.WitnessV0PubKeyHashTy:
+		return InputP2WPKH
+	case txscript.PubKeyTy:
+		return InputP2PK
+	}
+	return InputP2PKH
+}
+
+// sigScriptInputSize returns the size of a non-witness input with a
+// signature script of the given size.
+func sigScriptInputSize(sigScriptSize int) int {
+	// outpoint, script length, script, sequence
+	return 32 + 4 + wire.VarIntSerializeSize(uint64(sigScriptSize)) + sigScriptSize + 4
+}
+
+// dataPushSize returns the size of the minimally encoded push of the data,
+// which small integers are pushed with a single opcode for.
+func dataPushSize(data []byte) int {
+	if len(data) == 0 || len(data) == 1 && (data[0] <= 16 || data[0] == 0x81) {
+		return 1
+	}
+	return pushSize(len(data))
+}
+
+// pushSize returns the size of the push of data of the given size.
+func pushSize(size int) int {
+	switch {
+	case size < txscript.OP_PUSHDATA1:
+		return 1 + size
+	case size <= 0xff:
+		return 2 + size
+	case size <= 0xffff:
+		return 3 + size
+	}
+	return 5 + size
+}
+
+// outputSizes are the sizes of the output types: value, script length and
+// script.
+var outputSizes = map[OutputType]int{
+	OutputP2PKH:  8 + 1 + 25,
+	OutputP2SH:   8 + 1 + 23,
+	OutputP2WPKH: 8 + 1 + 22,
+	OutputP2WSH:  8 + 1 + 34,
+}
+
+// EstimateVSize returns the virtual size of a signed transaction with the
+// given inputs and outputs, which is what fee rates apply to. Witness bytes
+// count for a quarter of other bytes.
+func EstimateVSize(inputs []InputType, outputs []OutputType) int {
+	// version, input count, output count, lock time
+	base := 4 + wire.VarIntSerializeSize(uint64(len(inputs))) + wire.VarIntSerializeSize(uint64(len(outputs))) + 4
+	for _, output := range outputs {
+		base += outputSizes[output]
+	}
+	size, witness := inputsWeight(inputs)
+	return vsize((base+size)*witnessScaleFactor + witness)
+}
+
+// estimateTxVSize returns the virtual size of the transaction once its inputs,
+// of the given types, are signed.
+func estimateTxVSize(msgTx *wire.MsgTx, inputs []InputType) int {
+	// The unsigned inputs hold an outpoint, an empty script and a sequence.
+	base := msgTx.SerializeSizeStripped()
+	for _, txIn := range msgTx.TxIn {
+		base -= wire.VarIntSerializeSize(uint64(len(txIn.SignatureScript))) + len(txIn.SignatureScript)
+	}
+	base -= len(msgTx.TxIn) * (32 + 4 + 4)
+	size, witness := inputsWeight(inputs)
+	return vsize((base+size)*witnessScaleFactor + witness)
+}
+
+// inputsWeight returns the non-witness size of the inputs, and the size they
+// add to the witness of the transaction.
+func inputsWeight(inputs []InputType) (int, int) {
+	inputWeightsMu.RLock()
+	defer inputWeightsMu.RUnlock()
+	size, witness := 0, 0
+	for _, input := range inputs {
+		size += inputWeights[input][0]
+		witness += inputWeights[input][1]
+	}
+	if witness > 0 {
+		// The segwit marker and flag, and the empty witnesses of the
+		// non-segwit inputs.
+		witness += 2
+		for _, input := range inputs {
+			if inputWeights[input][1] == 0 {
+				witness++
+			}
+		}
+	}
+	return size, witness
+}
+
+// vsize returns the virtual size of the given weight, rounded up.
+func vsize(weight int) int {
+	return (weight + witnessScaleFactor - 1) / witnessScaleFactor
+}
+
+// txVSize returns the virtual size of the transaction.
+func txVSize(msgTx *wire.MsgTx) int {
+	return vsize(msgTx.SerializeSizeStripped()*(witnessScaleFactor-1) + msgTx.SerializeSize())
+}
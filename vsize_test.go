@@ -0,0 +1,156 @@
+package libbtc_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+var _ = Describe("EstimateVSize", func() {
+	params := &chaincfg.RegressionNetParams
+
+	// signedTx returns a transaction spending two outputs of the input type
+	// to a P2WPKH and a P2PKH output, signed with a new key. Signatures do not
+	// have to be valid to have the right size.
+	signedTx := func(input InputType) *wire.MsgTx {
+		key, err := btcec.NewPrivateKey(btcec.S256())
+		Expect(err).ShouldNot(HaveOccurred())
+		pubKeyHash := btcutil.Hash160(key.PubKey().SerializeCompressed())
+		p2pkh, err := btcutil.NewAddressPubKeyHash(pubKeyHash, params)
+		Expect(err).ShouldNot(HaveOccurred())
+		p2pkhScript, err := txscript.PayToAddrScript(p2pkh)
+		Expect(err).ShouldNot(HaveOccurred())
+		p2wpkh, err := btcutil.NewAddressWitnessPubKeyHash(pubKeyHash, params)
+		Expect(err).ShouldNot(HaveOccurred())
+		p2wpkhScript, err := txscript.PayToAddrScript(p2wpkh)
+		Expect(err).ShouldNot(HaveOccurred())
+		uncompressed, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(key.PubKey().SerializeUncompressed()), params)
+		Expect(err).ShouldNot(HaveOccurred())
+		uncompressedScript, err := txscript.PayToAddrScript(uncompressed)
+		Expect(err).ShouldNot(HaveOccurred())
+		p2pkScript, err := txscript.NewScriptBuilder().AddData(key.PubKey().SerializeCompressed()).AddOp(txscript.OP_CHECKSIG).Script()
+		Expect(err).ShouldNot(HaveOccurred())
+
+		msgTx := wire.NewMsgTx(2)
+		for i := 0; i < 2; i++ {
+			msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{byte(i + 1)}, uint32(i)), nil, nil))
+		}
+		msgTx.AddTxOut(wire.NewTxOut(50000, p2wpkhScript))
+		msgTx.AddTxOut(wire.NewTxOut(40000, p2pkhScript))
+
+		sigHashes := txscript.NewTxSigHashes(msgTx)
+		for i, txIn := range msgTx.TxIn {
+			switch input {
+			case InputP2PKH:
+				txIn.SignatureScript, err = txscript.SignatureScript(msgTx, i, p2pkhScript, txscript.SigHashAll, key, true)
+			case InputP2PKHUncompressed:
+				txIn.SignatureScript, err = txscript.SignatureScript(msgTx, i, uncompressedScript, txscript.SigHashAll, key, false)
+			case InputP2PK:
+				var sig []byte
+				if sig, err = txscript.RawTxInSignature(msgTx, i, p2pkScript, txscript.SigHashAll, key); err == nil {
+					txIn.SignatureScript, err = txscript.NewScriptBuilder().AddData(sig).Script()
+				}
+			case InputP2SHP2WPKH:
+				txIn.Witness, err = txscript.WitnessSignature(msgTx, sigHashes, i, 50000, p2wpkhScript, txscript.SigHashAll, key, true)
+				Expect(err).ShouldNot(HaveOccurred())
+				txIn.SignatureScript, err = txscript.NewScriptBuilder().AddData(p2wpkhScript).Script()
+			case InputP2WPKH:
+				txIn.Witness, err = txscript.WitnessSignature(msgTx, sigHashes, i, 50000, p2wpkhScript, txscript.SigHashAll, key, true)
+			}
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+		return msgTx
+	}
+
+	It("should match the sizes of common transactions with signatures of the maximum size", func() {
+		Expect(EstimateVSize([]InputType{InputP2PKH}, []OutputType{OutputP2PKH, OutputP2PKH})).Should(Equal(227))
+		Expect(EstimateVSize([]InputType{InputP2WPKH}, []OutputType{OutputP2WPKH})).Should(Equal(110))
+		Expect(EstimateVSize([]InputType{InputP2SHP2WPKH}, []OutputType{OutputP2SH})).Should(Equal(134))
+	})
+
+	It("should count witnesses for a quarter of other bytes", func() {
+		legacy := EstimateVSize([]InputType{InputP2PKH, InputP2PKH}, []OutputType{OutputP2WPKH})
+		mixed := EstimateVSize([]InputType{InputP2PKH, InputP2WPKH}, []OutputType{OutputP2WPKH})
+		segwit := EstimateVSize([]InputType{InputP2WPKH, InputP2WPKH}, []OutputType{OutputP2WPKH})
+		Expect(segwit).Should(BeNumerically("<", mixed))
+		Expect(mixed).Should(BeNumerically("<", legacy))
+	})
+
+	for _, spent := range []struct {
+		name  string
+		input InputType
+	}{
+		{"P2PKH", InputP2PKH},
+		{"P2SH-P2WPKH", InputP2SHP2WPKH},
+		{"P2WPKH", InputP2WPKH},
+		{"uncompressed P2PKH", InputP2PKHUncompressed},
+		{"P2PK", InputP2PK},
+	} {
+		input := spent.input
+
+		It("should not underestimate signed transactions spending "+spent.name+" outputs", func() {
+			msgTx := signedTx(input)
+			vsize := (msgTx.SerializeSizeStripped()*3 + msgTx.SerializeSize() + 3) / 4
+			estimate := EstimateVSize([]InputType{input, input}, []OutputType{OutputP2WPKH, OutputP2PKH})
+			// Signatures are up to 2 bytes shorter than the maximum size.
+			Expect(estimate).Should(BeNumerically(">=", vsize))
+			Expect(estimate).Should(BeNumerically("<=", vsize+2*len(msgTx.TxIn)))
+		})
+	}
+
+	Context("when registering input types", func() {
+		It("should return the same type for the same sizes", func() {
+			inputType := RegisterInputType(100, 50)
+			Expect(RegisterInputType(100, 50)).Should(Equal(inputType))
+			Expect(RegisterInputType(100, 51)).ShouldNot(Equal(inputType))
+			// outpoint, script length, sequence; <sig> <pubKey>
+			Expect(RegisterInputType(32+4+1+4, 1+(1+73)+(1+33))).Should(Equal(InputP2WPKH))
+		})
+
+		It("should estimate the registered sizes", func() {
+			legacy := RegisterInputType(200, 0)
+			Expect(EstimateVSize([]InputType{legacy}, []OutputType{OutputP2WPKH})).Should(Equal(10 + 200 + 31))
+			segwit := RegisterInputType(50, 101)
+			// The witness adds the segwit marker and flag.
+			Expect(EstimateVSize([]InputType{segwit}, []OutputType{OutputP2WPKH})).Should(Equal(10 + 50 + 31 + (101+2+3)/4))
+		})
+
+		It("should not underestimate signed multisig inputs", func() {
+			keys := make([]*btcec.PrivateKey, 3)
+			builder := txscript.NewScriptBuilder().AddOp(txscript.OP_2)
+			for i := range keys {
+				var err error
+				keys[i], err = btcec.NewPrivateKey(btcec.S256())
+				Expect(err).ShouldNot(HaveOccurred())
+				builder.AddData(keys[i].PubKey().SerializeCompressed())
+			}
+			redeemScript, err := builder.AddOp(txscript.OP_3).AddOp(txscript.OP_CHECKMULTISIG).Script()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			msgTx := wire.NewMsgTx(2)
+			msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+			msgTx.AddTxOut(wire.NewTxOut(50000, make([]byte, 22)))
+			sigScript := txscript.NewScriptBuilder().AddOp(txscript.OP_0)
+			for _, key := range keys[:2] {
+				sig, err := txscript.RawTxInSignature(msgTx, 0, redeemScript, txscript.SigHashAll, key)
+				Expect(err).ShouldNot(HaveOccurred())
+				sigScript.AddData(sig)
+			}
+			msgTx.TxIn[0].SignatureScript, err = sigScript.AddData(redeemScript).Script()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			estimate := EstimateVSize([]InputType{P2SHMultisigInputType(2, 3)}, []OutputType{OutputP2WPKH})
+			Expect(estimate).Should(BeNumerically(">=", msgTx.SerializeSize()))
+			// Shorter signatures can also shorten the length of the signature
+			// script, from 3 bytes to 1, as it is close to 253 bytes.
+			Expect(estimate).Should(BeNumerically("<=", msgTx.SerializeSize()+2*2+2))
+		})
+	})
+})
@@ -1,27 +1,174 @@
 package libbtc
 
 import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcutil"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/sirupsen/logrus"
+	"github.com/renproject/libbtc-go/storage"
 	"github.com/tyler-smith/go-bip32"
 	"github.com/tyler-smith/go-bip39"
 )
 
 type wallet struct {
-	mnemonic string
-	client   Client
-	logger   logrus.FieldLogger
+	mnemonic   string
+	passphrase string
+	client     Client
+	store      storage.Store
+	logger     Logger
 }
 
+// Wallet derives the accounts of a BIP39 mnemonic. The password of the methods
+// taking one is the BIP39 passphrase extending the mnemonic, which derives
+// different keys for every passphrase rather than encrypting anything. The
+// other methods use the passphrase the wallet was created with.
 type Wallet interface {
 	NewAccount(derivationPath []uint32, password string) (Account, error)
+	NewHDAccount(accountPath []uint32, password string, external, internal uint32) (HDAccount, error)
+	Discover(ctx context.Context, client Client, gapLimit uint32, password string) (Discovery, error)
+
+	// NewAccountAt returns an Account for the key at the path.
+	NewAccountAt(path DerivationPath) (Account, error)
+
+	// NewBIP44Account returns an HDAccount with P2PKH addresses, for the
+	// key at m/44'/coin'/index'.
+	NewBIP44Account(index uint32) (HDAccount, error)
+
+	// NewBIP49Account returns an HDAccount with P2SH-P2WPKH addresses, for
+	// the key at m/49'/coin'/index'.
+	NewBIP49Account(index uint32) (HDAccount, error)
+
+	// NewBIP84Account returns an HDAccount with P2WPKH addresses, for the
+	// key at m/84'/coin'/index'.
+	NewBIP84Account(index uint32) (HDAccount, error)
+}
+
+func NewWallet(mnemonic string, client Client, logger Logger) Wallet {
+	return &wallet{mnemonic, "", client, nil, logger}
+}
+
+// NewWalletWithStore returns a Wallet that caches the addresses it discovers
+// and their UTXOs in the store.
+func NewWalletWithStore(mnemonic string, client Client, store storage.Store, logger Logger) Wallet {
+	return &wallet{mnemonic, "", client, store, logger}
+}
+
+// NewWalletWithPassphrase returns a Wallet for the mnemonic extended with the
+// BIP39 passphrase, after checking the mnemonic is valid.
+func NewWalletWithPassphrase(mnemonic, passphrase string, client Client, logger Logger) (Wallet, error) {
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	return &wallet{mnemonic, passphrase, client, nil, logger}, nil
+}
+
+// NewMnemonic generates a BIP39 mnemonic from entropyBits bits of random
+// entropy, a multiple of 32 from 128 to 256. 128 bits give 12 words and 256
+// bits 24 words.
+func NewMnemonic(entropyBits int) (string, error) {
+	entropy, err := bip39.NewEntropy(entropyBits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
 }
 
-func NewWallet(mnemonic string, client Client, logger logrus.FieldLogger) Wallet {
-	return &wallet{mnemonic, client, logger}
+// ValidateMnemonic returns an error if the mnemonic is not made of words of
+// the BIP39 English word list with a valid checksum.
+func ValidateMnemonic(mnemonic string) error {
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return fmt.Errorf("invalid mnemonic")
+	}
+	return nil
 }
 
 func (wallet *wallet) NewAccount(derivationPath []uint32, password string) (Account, error) {
+	key, err := wallet.deriveKey(derivationPath, password)
+	if err != nil {
+		return nil, err
+	}
+	privKey, err := crypto.ToECDSA(key.Key)
+	if err != nil {
+		return nil, err
+	}
+	return NewAccount(wallet.client, NewPrivateKeySigner(privKey), wallet.logger), nil
+}
+
+// NewHDAccount returns an HDAccount for the account-level key at the given
+// path, for example m/44'/0'/0'. external and internal are the number of
+// addresses already used on the receiving and change chains.
+func (wallet *wallet) NewHDAccount(accountPath []uint32, password string, external, internal uint32) (HDAccount, error) {
+	key, err := wallet.deriveKey(accountPath, password)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := wallet.masterFingerprint(password)
+	if err != nil {
+		return nil, err
+	}
+	account := newWatchOnlyAccount(wallet.client, key, accountPath, external, internal, wallet.logger)
+	account.masterFingerprint = fingerprint
+	return &hdAccount{account}, nil
+}
+
+func (wallet *wallet) NewAccountAt(path DerivationPath) (Account, error) {
+	return wallet.NewAccount(path.Uint32s(), wallet.passphrase)
+}
+
+func (wallet *wallet) NewBIP44Account(index uint32) (HDAccount, error) {
+	return wallet.newPurposeAccount(PurposeP2PKH, index)
+}
+
+func (wallet *wallet) NewBIP49Account(index uint32) (HDAccount, error) {
+	return wallet.newPurposeAccount(PurposeP2SHP2WPKH, index)
+}
+
+func (wallet *wallet) NewBIP84Account(index uint32) (HDAccount, error) {
+	return wallet.newPurposeAccount(PurposeP2WPKH, index)
+}
+
+// newPurposeAccount returns the HDAccount at the given index for the
+// derivation scheme of the purpose, with no address used yet.
+func (wallet *wallet) newPurposeAccount(purpose, index uint32) (HDAccount, error) {
+	path := AccountPath(purpose, wallet.client.NetworkParams().HDCoinType, index).Uint32s()
+	key, err := wallet.deriveKey(path, wallet.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := wallet.masterFingerprint(wallet.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	account := newWatchOnlyAccount(wallet.client, key, path, 0, 0, wallet.logger)
+	account.purpose = purpose
+	account.masterFingerprint = fingerprint
+	return &hdAccount{account}, nil
+}
+
+// masterFingerprint returns the BIP32 fingerprint of the master key derived
+// with the password, the first 4 bytes of the hash of its public key.
+func (wallet *wallet) masterFingerprint(password string) (uint32, error) {
+	key, err := wallet.deriveKey(nil, password)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(btcutil.Hash160(key.PublicKey().Key)[:4]), nil
+}
+
+// id returns the identifier of the wallet in its store, the hash of the
+// public master key derived with the password.
+func (wallet *wallet) id(password string) (string, error) {
+	key, err := wallet.deriveKey(nil, password)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(btcutil.Hash160(key.PublicKey().Key)), nil
+}
+
+func (wallet *wallet) deriveKey(derivationPath []uint32, password string) (*bip32.Key, error) {
 	seed := bip39.NewSeed(wallet.mnemonic, password)
 	key, err := bip32.NewMasterKey(seed)
 	if err != nil {
@@ -33,9 +180,5 @@ func (wallet *wallet) NewAccount(derivationPath []uint32, password string) (Acco
 			return nil, err
 		}
 	}
-	privKey, err := crypto.ToECDSA(key.Key)
-	if err != nil {
-		return nil, err
-	}
-	return NewAccount(wallet.client, privKey, wallet.logger), nil
+	return key, nil
 }
@@ -0,0 +1,718 @@
+package libbtc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/ur"
+	"github.com/tyler-smith/go-bip32"
+)
+
+// WatchOnlyAccount is an account backed by a BIP44 account-level extended
+// public key. It derives the P2PKH addresses of the account, or the segwit
+// addresses of BIP49 and BIP84 accounts, computes their balance and builds
+// unsigned transactions spending from them, but never holds a private key.
+type WatchOnlyAccount interface {
+	Client
+
+	// Address returns the address at the given index of the given chain.
+	Address(chain, index uint32) (btcutil.Address, error)
+
+	// NextReceiveAddress derives a fresh address on the external chain.
+	NextReceiveAddress() (btcutil.Address, error)
+
+	// Addresses returns every address derived so far, on both chains.
+	Addresses() ([]btcutil.Address, error)
+
+	// TotalBalance returns the balance of every address derived so far.
+	TotalBalance(ctx context.Context, confirmations int64) (int64, error)
+
+	// AddressBalances returns every address derived so far, on both chains,
+	// with its balance and whether it has ever received funds.
+	AddressBalances(ctx context.Context) ([]AddressBalance, error)
+
+	// BuildUnsignedTransfer builds a transfer to the given address spending
+	// from every address derived so far, with change to a fresh internal
+	// address.
+	BuildUnsignedTransfer(ctx context.Context, to string, value int64, speed TxExecutionSpeed) (*UnsignedTransfer, error)
+
+	// ChangeAddress derives a fresh address on the internal chain, so that
+	// the account can be the ChangeAddresser of other accounts and
+	// TxBuilders.
+	ChangeAddress(ctx context.Context) (btcutil.Address, error)
+
+	// AccountUR returns the extended public key of the account as a
+	// crypto-account UR with the output descriptor of its addresses, pkh
+	// unless the account was derived for BIP49 or BIP84, for air-gapped
+	// signers to check the transfers they sign.
+	AccountUR() (ur.UR, error)
+
+	// OutputDescriptors returns the output descriptors of the external and
+	// internal chains of the account, such as wpkh(<xpub>/0/*) for BIP84
+	// accounts.
+	OutputDescriptors() (external, internal string)
+
+	// RegisterDescriptors imports the output descriptors of the account into
+	// the descriptor wallet of a node once, up to the gap limit past the
+	// addresses derived so far, so that the node tracks the outputs and
+	// transactions of the account. The chain is rescanned for its past
+	// transactions if rescan is true.
+	RegisterDescriptors(ctx context.Context, gapLimit uint32, rescan bool) error
+}
+
+// AddressBalance is an address derived by a WatchOnlyAccount, along with its
+// derivation and balance.
+type AddressBalance struct {
+	Address btcutil.Address
+	Chain   uint32
+	Index   uint32
+
+	// Balance includes unconfirmed outputs.
+	Balance int64
+	// Used is whether the address has ever received funds, even if they have
+	// been spent since.
+	Used bool
+}
+
+// UnsignedInput is an input of an UnsignedTransfer, along with the output it
+// spends and the derivation of the key that can sign it.
+type UnsignedInput struct {
+	OutPoint     wire.OutPoint
+	Amount       int64
+	ScriptPubKey []byte
+	Chain        uint32
+	Index        uint32
+	PubKey       []byte
+
+	// RedeemScript is the P2WPKH witness program of the public key for
+	// P2SH-P2WPKH inputs, and nil otherwise.
+	RedeemScript []byte
+
+	// PrevTx is the transaction of the spent output for inputs that are not
+	// segwit, whose amount is only committed to through the transaction, and
+	// nil otherwise.
+	PrevTx *wire.MsgTx
+}
+
+// witnessProgram returns the script the input commits to in BIP143 sighashes,
+// or nil for inputs that are not segwit.
+func (input UnsignedInput) witnessProgram() []byte {
+	if input.RedeemScript != nil {
+		return input.RedeemScript
+	}
+	if txscript.IsPayToWitnessPubKeyHash(input.ScriptPubKey) {
+		return input.ScriptPubKey
+	}
+	return nil
+}
+
+// UnsignedTransfer is a transfer built by a WatchOnlyAccount, to be signed
+// offline.
+type UnsignedTransfer struct {
+	Tx     *wire.MsgTx
+	Inputs []UnsignedInput
+	Fee    int64
+
+	// DerivationPath is the path of the account-level key from the master
+	// key, used to describe the signing keys in the PSBT.
+	DerivationPath []uint32
+	// MasterFingerprint is the fingerprint of the master key, or zero if it
+	// is unknown.
+	MasterFingerprint uint32
+}
+
+// Sighashes returns the SigHashAll hashes that need to be signed, one per
+// input. Segwit inputs are hashed as defined in BIP143.
+func (transfer *UnsignedTransfer) Sighashes() ([][]byte, error) {
+	hashes := make([][]byte, len(transfer.Inputs))
+	sigHashes := txscript.NewTxSigHashes(transfer.Tx)
+	for i, input := range transfer.Inputs {
+		var hash []byte
+		var err error
+		if program := input.witnessProgram(); program != nil {
+			hash, err = txscript.CalcWitnessSigHash(program, sigHashes, txscript.SigHashAll, transfer.Tx, i, input.Amount)
+		} else {
+			hash, err = txscript.CalcSignatureHash(input.ScriptPubKey, txscript.SigHashAll, transfer.Tx, i)
+		}
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = hash
+	}
+	return hashes, nil
+}
+
+// PSBT types used when serializing an UnsignedTransfer, as defined in BIP174.
+const (
+	psbtGlobalUnsignedTx  = 0x00
+	psbtInNonWitnessUTXO  = 0x00
+	psbtInWitnessUTXO     = 0x01
+	psbtInSighashType     = 0x03
+	psbtInRedeemScript    = 0x04
+	psbtInBIP32Derivation = 0x06
+)
+
+// PSBT returns the transfer as a BIP174 partially signed transaction. Segwit
+// inputs describe the spent output with a witness UTXO record, other inputs
+// with the full previous transaction. The signing keys are described with the
+// derivation path of the account only if the fingerprint of the master key is
+// known, as signers match on it and a wrong fingerprint prevents signing.
+func (transfer *UnsignedTransfer) PSBT() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write([]byte{'p', 's', 'b', 't', 0xFF})
+
+	var txBuf bytes.Buffer
+	if err := transfer.Tx.SerializeNoWitness(&txBuf); err != nil {
+		return nil, err
+	}
+	if err := psbtWritePair(&buf, []byte{psbtGlobalUnsignedTx}, txBuf.Bytes()); err != nil {
+		return nil, err
+	}
+	buf.WriteByte(0x00)
+
+	for _, input := range transfer.Inputs {
+		var utxoBuf bytes.Buffer
+		utxoType := byte(psbtInWitnessUTXO)
+		if input.witnessProgram() != nil {
+			if err := wire.WriteTxOut(&utxoBuf, 0, 0, wire.NewTxOut(input.Amount, input.ScriptPubKey)); err != nil {
+				return nil, err
+			}
+		} else {
+			if input.PrevTx == nil || input.PrevTx.TxHash() != input.OutPoint.Hash {
+				return nil, fmt.Errorf("missing the previous transaction %s of a non-segwit input", input.OutPoint.Hash)
+			}
+			utxoType = psbtInNonWitnessUTXO
+			if err := input.PrevTx.Serialize(&utxoBuf); err != nil {
+				return nil, err
+			}
+		}
+		if err := psbtWritePair(&buf, []byte{utxoType}, utxoBuf.Bytes()); err != nil {
+			return nil, err
+		}
+
+		sighashType := make([]byte, 4)
+		binary.LittleEndian.PutUint32(sighashType, uint32(txscript.SigHashAll))
+		if err := psbtWritePair(&buf, []byte{psbtInSighashType}, sighashType); err != nil {
+			return nil, err
+		}
+		if input.RedeemScript != nil {
+			if err := psbtWritePair(&buf, []byte{psbtInRedeemScript}, input.RedeemScript); err != nil {
+				return nil, err
+			}
+		}
+
+		if transfer.MasterFingerprint != 0 {
+			path := append(append([]uint32{}, transfer.DerivationPath...), input.Chain, input.Index)
+			derivation := make([]byte, 4+4*len(path))
+			binary.BigEndian.PutUint32(derivation, transfer.MasterFingerprint)
+			for i, child := range path {
+				binary.LittleEndian.PutUint32(derivation[4+4*i:], child)
+			}
+			if err := psbtWritePair(&buf, append([]byte{psbtInBIP32Derivation}, input.PubKey...), derivation); err != nil {
+				return nil, err
+			}
+		}
+		buf.WriteByte(0x00)
+	}
+
+	for range transfer.Tx.TxOut {
+		buf.WriteByte(0x00)
+	}
+	return buf.Bytes(), nil
+}
+
+// UR returns the PSBT of the transfer as a crypto-psbt UR, to be sent to an
+// air-gapped signer through a ur.Encoder.
+func (transfer *UnsignedTransfer) UR() (ur.UR, error) {
+	psbt, err := transfer.PSBT()
+	if err != nil {
+		return ur.UR{}, err
+	}
+	return ur.NewPSBT(psbt), nil
+}
+
+func psbtWritePair(buf *bytes.Buffer, key, value []byte) error {
+	if err := wire.WriteVarBytes(buf, 0, key); err != nil {
+		return err
+	}
+	return wire.WriteVarBytes(buf, 0, value)
+}
+
+type watchOnlyAccount struct {
+	Client
+	key    *bip32.Key
+	path   []uint32
+	logger Logger
+
+	// purpose is the BIP43 purpose of the derivation scheme, which
+	// determines the type of the addresses.
+	purpose uint32
+	// masterFingerprint is the fingerprint of the master key, or zero if it
+	// is unknown.
+	masterFingerprint uint32
+
+	mu       *sync.Mutex
+	external uint32
+	internal uint32
+}
+
+// NewWatchOnlyAccount returns a WatchOnlyAccount for the serialized
+// account-level extended public key, for example the xpub of m/44'/0'/0'.
+// derivationPath is the path of the key from the master key, its purpose
+// determines the type of the addresses: P2SH-P2WPKH for 49', P2WPKH for 84'
+// and P2PKH otherwise. The fingerprint
+// of the master key is unknown, so the PSBTs of the account do not describe
+// the signing keys.
+func NewWatchOnlyAccount(client Client, xpub string, derivationPath []uint32) (WatchOnlyAccount, error) {
+	return NewWatchOnlyAccountWithFingerprint(client, xpub, derivationPath, 0)
+}
+
+// NewWatchOnlyAccountWithFingerprint returns a WatchOnlyAccount as in
+// NewWatchOnlyAccount, with the fingerprint of the master key, so that signers
+// can find the signing keys of its PSBTs.
+func NewWatchOnlyAccountWithFingerprint(client Client, xpub string, derivationPath []uint32, masterFingerprint uint32) (WatchOnlyAccount, error) {
+	key, err := bip32.B58Deserialize(xpub)
+	if err != nil {
+		return nil, err
+	}
+	if key.IsPrivate {
+		return nil, fmt.Errorf("watch-only accounts expect an extended public key")
+	}
+	account := newWatchOnlyAccount(client, key, derivationPath, 0, 0, nil)
+	account.masterFingerprint = masterFingerprint
+	return account, nil
+}
+
+// NewWatchOnlyAccountFromUR returns a WatchOnlyAccount for the key of the
+// first pkh, sh(wpkh) or wpkh output descriptor of a crypto-account UR, as
+// exported by air-gapped signers. The type of the addresses of the account
+// follows the descriptor.
+func NewWatchOnlyAccountFromUR(client Client, account ur.UR) (WatchOnlyAccount, error) {
+	decoded, err := account.Account()
+	if err != nil {
+		return nil, err
+	}
+	for _, descriptor := range decoded.Descriptors {
+		purpose, ok := scriptsPurpose(descriptor.Scripts)
+		if !ok {
+			continue
+		}
+		hdKey := descriptor.Key
+		if len(hdKey.Path) == 0 || len(hdKey.Path) > 255 {
+			return nil, fmt.Errorf("invalid derivation path of length %d", len(hdKey.Path))
+		}
+		key := &bip32.Key{
+			Key:         hdKey.Key,
+			Version:     bip32.PublicWalletVersion,
+			ChildNumber: make([]byte, 4),
+			FingerPrint: make([]byte, 4),
+			ChainCode:   hdKey.ChainCode,
+			Depth:       byte(len(hdKey.Path)),
+		}
+		binary.BigEndian.PutUint32(key.ChildNumber, hdKey.Path[len(hdKey.Path)-1])
+		binary.BigEndian.PutUint32(key.FingerPrint, hdKey.ParentFingerprint)
+		watchOnly := newWatchOnlyAccount(client, key, hdKey.Path, 0, 0, nil)
+		watchOnly.purpose = purpose
+		watchOnly.masterFingerprint = hdKey.SourceFingerprint
+		if watchOnly.masterFingerprint == 0 {
+			watchOnly.masterFingerprint = decoded.MasterFingerprint
+		}
+		return watchOnly, nil
+	}
+	return nil, fmt.Errorf("crypto-account has no pkh, sh(wpkh) or wpkh output descriptor")
+}
+
+// scriptsPurpose returns the BIP43 purpose of the addresses of an output
+// descriptor with the script expressions, and false if they are not supported.
+func scriptsPurpose(scripts []ur.ScriptExpression) (uint32, bool) {
+	switch {
+	case len(scripts) == 1 && scripts[0] == ur.ScriptPKH:
+		return PurposeP2PKH, true
+	case len(scripts) == 2 && scripts[0] == ur.ScriptSH && scripts[1] == ur.ScriptWPKH:
+		return PurposeP2SHP2WPKH, true
+	case len(scripts) == 1 && scripts[0] == ur.ScriptWPKH:
+		return PurposeP2WPKH, true
+	default:
+		return 0, false
+	}
+}
+
+// pathPurpose returns the BIP43 purpose of the derivation path, defaulting to
+// P2PKH for paths without a known purpose.
+func pathPurpose(path []uint32) uint32 {
+	if len(path) > 0 {
+		switch path[0] {
+		case bip32.FirstHardenedChild + PurposeP2SHP2WPKH:
+			return PurposeP2SHP2WPKH
+		case bip32.FirstHardenedChild + PurposeP2WPKH:
+			return PurposeP2WPKH
+		}
+	}
+	return PurposeP2PKH
+}
+
+func newWatchOnlyAccount(client Client, key *bip32.Key, path []uint32, external, internal uint32, logger Logger) *watchOnlyAccount {
+	if logger == nil {
+		logger = clients.NopLogger()
+	}
+	return &watchOnlyAccount{
+		Client:   client,
+		key:      key,
+		path:     path,
+		logger:   logger,
+		purpose:  pathPurpose(path),
+		mu:       new(sync.Mutex),
+		external: external,
+		internal: internal,
+	}
+}
+
+func (account *watchOnlyAccount) AccountUR() (ur.UR, error) {
+	// The accounts of HDAccounts hold the private key, which is never
+	// exported.
+	pubKey := account.key.PublicKey()
+	key := ur.HDKey{
+		Key:               pubKey.Key,
+		ChainCode:         pubKey.ChainCode,
+		Testnet:           account.NetworkParams().Net != wire.MainNet,
+		Path:              account.path,
+		SourceFingerprint: account.masterFingerprint,
+		ParentFingerprint: binary.BigEndian.Uint32(account.key.FingerPrint),
+	}
+	var scripts []ur.ScriptExpression
+	switch account.purpose {
+	case PurposeP2SHP2WPKH:
+		scripts = []ur.ScriptExpression{ur.ScriptSH, ur.ScriptWPKH}
+	case PurposeP2WPKH:
+		scripts = []ur.ScriptExpression{ur.ScriptWPKH}
+	default:
+		scripts = []ur.ScriptExpression{ur.ScriptPKH}
+	}
+	return ur.NewAccount(ur.Account{
+		MasterFingerprint: account.masterFingerprint,
+		Descriptors:       []ur.OutputDescriptor{{Scripts: scripts, Key: key}},
+	})
+}
+
+func (account *watchOnlyAccount) OutputDescriptors() (string, string) {
+	// Nodes expect extended keys with the version of their network.
+	pubKey := account.key.PublicKey()
+	pubKey.Version = account.NetworkParams().HDPublicKeyID[:]
+	xpub := pubKey.B58Serialize()
+
+	var format string
+	switch account.purpose {
+	case PurposeP2SHP2WPKH:
+		format = "sh(wpkh(%s/%d/*))"
+	case PurposeP2WPKH:
+		format = "wpkh(%s/%d/*)"
+	default:
+		format = "pkh(%s/%d/*)"
+	}
+	return fmt.Sprintf(format, xpub, ExternalChain), fmt.Sprintf(format, xpub, InternalChain)
+}
+
+func (account *watchOnlyAccount) RegisterDescriptors(ctx context.Context, gapLimit uint32, rescan bool) error {
+	if gapLimit == 0 {
+		gapLimit = DefaultGapLimit
+	}
+	external, internal := account.OutputDescriptors()
+	account.mu.Lock()
+	externalEnd, internalEnd := account.external+gapLimit, account.internal+gapLimit
+	account.mu.Unlock()
+
+	return account.ImportDescriptors(ctx, []clients.WalletDescriptor{
+		{Desc: external, RangeEnd: externalEnd, Rescan: rescan},
+		{Desc: internal, RangeEnd: internalEnd, Internal: true, Rescan: rescan},
+	})
+}
+
+func (account *watchOnlyAccount) Address(chain, index uint32) (btcutil.Address, error) {
+	key, err := account.childKey(chain, index)
+	if err != nil {
+		return nil, err
+	}
+	return purposeAddress(account.purpose, key.PublicKey().Key, account.NetworkParams())
+}
+
+func (account *watchOnlyAccount) NextReceiveAddress() (btcutil.Address, error) {
+	return account.Address(ExternalChain, account.next(ExternalChain))
+}
+
+func (account *watchOnlyAccount) ChangeAddress(ctx context.Context) (btcutil.Address, error) {
+	return account.Address(InternalChain, account.next(InternalChain))
+}
+
+func (account *watchOnlyAccount) Addresses() ([]btcutil.Address, error) {
+	addresses := []btcutil.Address{}
+	for _, chain := range []uint32{ExternalChain, InternalChain} {
+		for index := uint32(0); index < account.count(chain); index++ {
+			address, err := account.Address(chain, index)
+			if err != nil {
+				return nil, err
+			}
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses, nil
+}
+
+func (account *watchOnlyAccount) TotalBalance(ctx context.Context, confirmations int64) (int64, error) {
+	addresses, err := account.Addresses()
+	if err != nil {
+		return 0, err
+	}
+	var balance int64
+	for _, address := range addresses {
+		addrBalance, err := account.Balance(ctx, address.EncodeAddress(), confirmations)
+		if err != nil {
+			return 0, err
+		}
+		balance += addrBalance
+	}
+	return balance, nil
+}
+
+func (account *watchOnlyAccount) AddressBalances(ctx context.Context) ([]AddressBalance, error) {
+	balances := []AddressBalance{}
+	addresses := []string{}
+	for _, chain := range []uint32{ExternalChain, InternalChain} {
+		for index := uint32(0); index < account.count(chain); index++ {
+			address, err := account.Address(chain, index)
+			if err != nil {
+				return nil, err
+			}
+			balances = append(balances, AddressBalance{
+				Address: address,
+				Chain:   chain,
+				Index:   index,
+			})
+			addresses = append(addresses, address.EncodeAddress())
+		}
+	}
+	if len(addresses) == 0 {
+		return balances, nil
+	}
+
+	amounts, err := account.BalanceMulti(ctx, addresses, 0)
+	if err != nil {
+		return nil, err
+	}
+	// Addresses with a balance are used without querying their history, the
+	// history of the others is fetched in a single round trip when the
+	// backend reports script statuses.
+	unfunded := []string{}
+	for i := range balances {
+		balances[i].Balance = amounts[addresses[i]]
+		balances[i].Used = balances[i].Balance > 0
+		if !balances[i].Used {
+			unfunded = append(unfunded, addresses[i])
+		}
+	}
+	if len(unfunded) == 0 {
+		return balances, nil
+	}
+	statuses, statusErr := account.ScriptStatuses(ctx, unfunded)
+	for i := range balances {
+		if balances[i].Used {
+			continue
+		}
+		if statusErr == nil {
+			balances[i].Used = statuses[addresses[i]] != ""
+			continue
+		}
+		balances[i].Used, _, err = account.ScriptFunded(ctx, addresses[i], 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return balances, nil
+}
+
+func (account *watchOnlyAccount) BuildUnsignedTransfer(ctx context.Context, to string, value int64, speed TxExecutionSpeed) (*UnsignedTransfer, error) {
+	dust := NetworkDust(account.NetworkParams())
+	if value < dust {
+		return nil, fmt.Errorf("transfer value (%d) is less than the minimum value (%d)", value, dust)
+	}
+	toAddr, err := btcutil.DecodeAddress(to, account.NetworkParams())
+	if err != nil {
+		return nil, err
+	}
+	toScript, err := txscript.PayToAddrScript(toAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	rate, err := account.TxRate(ctx, speed)
+	if err != nil {
+		return nil, err
+	}
+
+	// Inputs are added until they cover the value and the fee of a
+	// transaction with a payment and a change output.
+	inputs, err := account.spendableInputs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var fee, amount int64
+	selected := []UnsignedInput{}
+	inputTypes := []InputType{}
+	for _, input := range inputs {
+		selected = append(selected, input)
+		if input.RedeemScript != nil {
+			inputTypes = append(inputTypes, InputP2SHP2WPKH)
+		} else {
+			inputTypes = append(inputTypes, scriptInputType(input.ScriptPubKey))
+		}
+		amount += input.Amount
+		fee = estimateFee(inputTypes, 2, rate, NetworkMaxFee(account.NetworkParams()))
+		if amount >= value+fee {
+			break
+		}
+	}
+	if amount < value+fee {
+		return nil, NewErrInsufficientBalance("hd account", value+fee, amount)
+	}
+
+	// The amounts of inputs that are not segwit are only committed to by
+	// their previous transaction, which signers need to check the fee.
+	for i := range selected {
+		if selected[i].witnessProgram() != nil {
+			continue
+		}
+		prevTx, err := account.RawTransaction(ctx, selected[i].OutPoint.Hash.String())
+		if err != nil {
+			return nil, err
+		}
+		selected[i].PrevTx = prevTx
+	}
+
+	msgTx := wire.NewMsgTx(2)
+	for i := range selected {
+		msgTx.AddTxIn(wire.NewTxIn(&selected[i].OutPoint, []byte{}, [][]byte{}))
+	}
+	msgTx.AddTxOut(wire.NewTxOut(value, toScript))
+
+	// Change below dust is left to the fee.
+	if change := amount - value - fee; change >= dust {
+		changeAddr, err := account.ChangeAddress(ctx)
+		if err != nil {
+			return nil, err
+		}
+		changeScript, err := txscript.PayToAddrScript(changeAddr)
+		if err != nil {
+			return nil, err
+		}
+		msgTx.AddTxOut(wire.NewTxOut(change, changeScript))
+	} else {
+		fee = amount - value
+	}
+
+	return &UnsignedTransfer{
+		Tx:                msgTx,
+		Inputs:            selected,
+		Fee:               fee,
+		DerivationPath:    account.path,
+		MasterFingerprint: account.masterFingerprint,
+	}, nil
+}
+
+// spendableInputs returns the unspent outputs of every address derived so far,
+// along with the derivation of the keys that can spend them.
+func (account *watchOnlyAccount) spendableInputs(ctx context.Context) ([]UnsignedInput, error) {
+	inputs := []UnsignedInput{}
+	for _, chain := range []uint32{ExternalChain, InternalChain} {
+		for index := uint32(0); index < account.count(chain); index++ {
+			key, err := account.childKey(chain, index)
+			if err != nil {
+				return nil, err
+			}
+			pubKey := key.PublicKey().Key
+			address, err := purposeAddress(account.purpose, pubKey, account.NetworkParams())
+			if err != nil {
+				return nil, err
+			}
+			var redeemScript []byte
+			if account.purpose == PurposeP2SHP2WPKH {
+				redeemScript, err = p2wpkhScript(pubKey, account.NetworkParams())
+				if err != nil {
+					return nil, err
+				}
+			}
+			utxos, err := account.GetUTXOs(ctx, address.EncodeAddress(), 999999, 0)
+			if err != nil {
+				return nil, err
+			}
+			for _, utxo := range utxos {
+				hash, err := chainhash.NewHashFromStr(utxo.TxHash)
+				if err != nil {
+					return nil, err
+				}
+				scriptPubKey, err := hex.DecodeString(utxo.ScriptPubKey)
+				if err != nil {
+					return nil, err
+				}
+				inputs = append(inputs, UnsignedInput{
+					OutPoint:     *wire.NewOutPoint(hash, utxo.Vout),
+					Amount:       utxo.Amount,
+					ScriptPubKey: scriptPubKey,
+					Chain:        chain,
+					Index:        index,
+					PubKey:       pubKey,
+					RedeemScript: redeemScript,
+				})
+			}
+		}
+	}
+	return inputs, nil
+}
+
+// next reserves the next address index of the chain.
+func (account *watchOnlyAccount) next(chain uint32) uint32 {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	if chain == ExternalChain {
+		account.external++
+		return account.external - 1
+	}
+	account.internal++
+	return account.internal - 1
+}
+
+// count returns the number of addresses derived so far on the chain.
+func (account *watchOnlyAccount) count(chain uint32) uint32 {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	if chain == ExternalChain {
+		return account.external
+	}
+	return account.internal
+}
+
+func (account *watchOnlyAccount) childKey(chain, index uint32) (*bip32.Key, error) {
+	chainKey, err := account.key.NewChildKey(chain)
+	if err != nil {
+		return nil, err
+	}
+	return chainKey.NewChildKey(index)
+}
+
+// estimateFee returns the fee of a transaction with the given inputs and
+// number of P2PKH outputs, capped to maxFee.
+func estimateFee(inputTypes []InputType, outputs int, rate, maxFee int64) int64 {
+	outputTypes := make([]OutputType, outputs)
+	fee := int64(EstimateVSize(inputTypes, outputTypes)) * rate
+	if fee > maxFee {
+		return maxFee
+	}
+	return fee
+}
@@ -0,0 +1,153 @@
+package libbtc_test
+
+import (
+	"context"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/libbtc-go/clients/mock"
+	"github.com/tyler-smith/go-bip32"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// countingChain is a mock chain counting the per-address history lookups.
+type countingChain struct {
+	*mock.Chain
+	scriptFunded int32
+}
+
+func (chain *countingChain) ScriptFunded(ctx context.Context, address string, value int64) (bool, int64, error) {
+	atomic.AddInt32(&chain.scriptFunded, 1)
+	return chain.Chain.ScriptFunded(ctx, address, value)
+}
+
+var _ = Describe("WatchOnlyAccount", func() {
+	const mnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	newClient := func() Client {
+		return NewClientFromCore(mock.NewChain(&chaincfg.RegressionNetParams))
+	}
+
+	// expectSameAddresses checks that both accounts derive the same first
+	// addresses on both chains.
+	expectSameAddresses := func(account, watchOnly WatchOnlyAccount) {
+		for _, chain := range []uint32{ExternalChain, InternalChain} {
+			for index := uint32(0); index < 3; index++ {
+				expected, err := account.Address(chain, index)
+				Expect(err).ShouldNot(HaveOccurred())
+				address, err := watchOnly.Address(chain, index)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(address.EncodeAddress()).Should(Equal(expected.EncodeAddress()))
+			}
+		}
+	}
+
+	for _, purpose := range []uint32{PurposeP2PKH, PurposeP2SHP2WPKH, PurposeP2WPKH} {
+		purpose := purpose
+
+		newAccount := func(client Client) HDAccount {
+			wallet := NewWallet(mnemonic, client, nil)
+			var account HDAccount
+			var err error
+			switch purpose {
+			case PurposeP2SHP2WPKH:
+				account, err = wallet.NewBIP49Account(0)
+			case PurposeP2WPKH:
+				account, err = wallet.NewBIP84Account(0)
+			default:
+				account, err = wallet.NewBIP44Account(0)
+			}
+			Expect(err).ShouldNot(HaveOccurred())
+			return account
+		}
+
+		Context("when the purpose is "+DerivationPath{Hardened(purpose)}.String(), func() {
+			It("should round-trip through a crypto-account UR", func() {
+				client := newClient()
+				account := newAccount(client)
+				accountUR, err := account.AccountUR()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				watchOnly, err := NewWatchOnlyAccountFromUR(client, accountUR)
+				Expect(err).ShouldNot(HaveOccurred())
+				expectSameAddresses(account, watchOnly)
+				external, internal := account.OutputDescriptors()
+				watchOnlyExternal, watchOnlyInternal := watchOnly.OutputDescriptors()
+				Expect(watchOnlyExternal).Should(Equal(external))
+				Expect(watchOnlyInternal).Should(Equal(internal))
+			})
+
+			It("should infer the address type from the derivation path", func() {
+				client := newClient()
+				account := newAccount(client)
+				path := []uint32{
+					bip32.FirstHardenedChild + purpose,
+					bip32.FirstHardenedChild + client.NetworkParams().HDCoinType,
+					bip32.FirstHardenedChild,
+				}
+				key, err := bip32.NewMasterKey(bip39.NewSeed(mnemonic, ""))
+				Expect(err).ShouldNot(HaveOccurred())
+				for _, index := range path {
+					key, err = key.NewChildKey(index)
+					Expect(err).ShouldNot(HaveOccurred())
+				}
+
+				watchOnly, err := NewWatchOnlyAccount(client, key.PublicKey().B58Serialize(), path)
+				Expect(err).ShouldNot(HaveOccurred())
+				expectSameAddresses(account, watchOnly)
+			})
+		})
+	}
+
+	Context("when listing the balances of the addresses", func() {
+		It("should report spent addresses as used without per-address lookups", func() {
+			chain := &countingChain{Chain: mock.NewChain(&chaincfg.RegressionNetParams)}
+			client := NewClientFromCore(chain)
+			account, err := NewWallet(mnemonic, client, nil).NewBIP84Account(0)
+			Expect(err).ShouldNot(HaveOccurred())
+			spent := []btcutil.Address{}
+			for i := 0; i < 2; i++ {
+				address, err := account.NextReceiveAddress()
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = chain.Fund(address.EncodeAddress(), 100000)
+				Expect(err).ShouldNot(HaveOccurred())
+				spent = append(spent, address)
+			}
+			chain.Mine(1)
+			key, err := btcec.NewPrivateKey(btcec.S256())
+			Expect(err).ShouldNot(HaveOccurred())
+			to, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(key.PubKey().SerializeCompressed()), client.NetworkParams())
+			Expect(err).ShouldNot(HaveOccurred())
+			_, _, err = account.Transfer(context.Background(), to.EncodeAddress(), 150000, Fast)
+			Expect(err).ShouldNot(HaveOccurred())
+			chain.Mine(1)
+			unused, err := account.NextReceiveAddress()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			balances, err := account.AddressBalances(context.Background())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(chain.scriptFunded).Should(BeZero())
+			byAddress := map[string]AddressBalance{}
+			var change int64
+			for _, balance := range balances {
+				byAddress[balance.Address.EncodeAddress()] = balance
+				if balance.Chain == InternalChain {
+					Expect(balance.Used).Should(BeTrue())
+					change += balance.Balance
+				}
+			}
+			for _, address := range spent {
+				Expect(byAddress[address.EncodeAddress()].Used).Should(BeTrue())
+				Expect(byAddress[address.EncodeAddress()].Balance).Should(BeZero())
+			}
+			Expect(byAddress[unused.EncodeAddress()].Used).Should(BeFalse())
+			Expect(change).Should(BeNumerically(">", 0))
+		})
+	})
+})
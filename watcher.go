@@ -0,0 +1,238 @@
+package libbtc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/renproject/libbtc-go/clients"
+	"github.com/renproject/libbtc-go/errors"
+)
+
+// WatchedAddress is the state of an address watched by a Watcher.
+type WatchedAddress struct {
+	Address string
+
+	// Funded is true once the address has received an output, and Spent once
+	// it has spent one. Outputs received and spent before the address was
+	// watched are only seen by backends reporting script statuses.
+	Funded bool
+	Spent  bool
+
+	// Balance is the value of the unspent outputs of the address, including
+	// unconfirmed ones.
+	Balance int64
+	UTXOs   []clients.UTXO
+}
+
+// Watcher tracks whether many addresses are funded and spent, polling them
+// with batched backend queries rather than one query per address. Backends
+// implementing clients.ScriptStatuser, such as Electrum servers, are first
+// asked which addresses changed, so that only the outputs of those are
+// fetched, with a single GetUTXOsMulti.
+type Watcher interface {
+	// Watch starts watching the addresses.
+	Watch(addresses ...string) error
+
+	// Unwatch stops watching the addresses.
+	Unwatch(addresses ...string)
+
+	// Status returns the last known state of the address, and false if it is
+	// not watched.
+	Status(address string) (WatchedAddress, bool)
+
+	// Changes returns the channel of the addresses whose state changed. It is
+	// closed once the context of the watcher is done.
+	Changes() <-chan WatchedAddress
+}
+
+type watchedState struct {
+	address WatchedAddress
+	utxos   map[string]clients.UTXO
+	status  string
+}
+
+type watcher struct {
+	client  Client
+	changes chan WatchedAddress
+
+	mu      *sync.Mutex
+	watched map[string]*watchedState
+}
+
+// NewWatcher returns a Watcher polling the addresses at the given interval
+// until the context is done.
+func NewWatcher(ctx context.Context, client Client, interval time.Duration) Watcher {
+	watcher := &watcher{
+		client:  client,
+		changes: make(chan WatchedAddress, 64),
+		mu:      new(sync.Mutex),
+		watched: map[string]*watchedState{},
+	}
+	go watcher.run(ctx, interval)
+	return watcher
+}
+
+func (watcher *watcher) Watch(addresses ...string) error {
+	for _, address := range addresses {
+		if err := watcher.client.Validate(address); err != nil {
+			return err
+		}
+	}
+
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	for _, address := range addresses {
+		if _, ok := watcher.watched[address]; !ok {
+			watcher.watched[address] = &watchedState{
+				address: WatchedAddress{Address: address, UTXOs: []clients.UTXO{}},
+				utxos:   map[string]clients.UTXO{},
+			}
+		}
+	}
+	return nil
+}
+
+func (watcher *watcher) Unwatch(addresses ...string) {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	for _, address := range addresses {
+		delete(watcher.watched, address)
+	}
+}
+
+func (watcher *watcher) Status(address string) (WatchedAddress, bool) {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	state, ok := watcher.watched[address]
+	if !ok {
+		return WatchedAddress{}, false
+	}
+	return state.address.copy(), true
+}
+
+func (watcher *watcher) Changes() <-chan WatchedAddress {
+	return watcher.changes
+}
+
+func (watcher *watcher) run(ctx context.Context, interval time.Duration) {
+	defer close(watcher.changes)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, change := range watcher.poll(ctx) {
+			select {
+			case <-ctx.Done():
+				return
+			case watcher.changes <- change:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll fetches the outputs of the watched addresses that changed since the
+// last poll, or of every watched address when the backend does not report
+// script statuses, and returns the addresses whose state changed. Errors are
+// ignored, and the addresses are polled again at the next interval.
+func (watcher *watcher) poll(ctx context.Context) []WatchedAddress {
+	watcher.mu.Lock()
+	addresses := make([]string, 0, len(watcher.watched))
+	for address := range watcher.watched {
+		addresses = append(addresses, address)
+	}
+	lastStatuses := make(map[string]string, len(watcher.watched))
+	for address, state := range watcher.watched {
+		lastStatuses[address] = state.status
+	}
+	watcher.mu.Unlock()
+	if len(addresses) == 0 {
+		return nil
+	}
+
+	statuses, err := watcher.client.ScriptStatuses(ctx, addresses)
+	if err == nil {
+		// Addresses that never had a history are skipped until they do.
+		changed := []string{}
+		for _, address := range addresses {
+			if statuses[address] != lastStatuses[address] {
+				changed = append(changed, address)
+			}
+		}
+		addresses = changed
+		if len(addresses) == 0 {
+			return nil
+		}
+	}
+	utxos, err := watcher.client.GetUTXOsMulti(ctx, addresses, 0)
+	if err != nil {
+		return nil
+	}
+
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	changes := []WatchedAddress{}
+	for _, address := range addresses {
+		state, ok := watcher.watched[address]
+		if !ok {
+			continue
+		}
+		if state.update(utxos[address], statuses[address]) {
+			changes = append(changes, state.address.copy())
+		}
+	}
+	return changes
+}
+
+// update replaces the unspent outputs of the address with the latest ones,
+// along with its script status if the backend reports them, and returns
+// whether its state changed.
+func (state *watchedState) update(utxos []clients.UTXO, status string) bool {
+	latest := make(map[string]clients.UTXO, len(utxos))
+	var balance int64
+	for _, utxo := range utxos {
+		latest[utxoKey(utxo)] = utxo
+		balance += utxo.Amount
+	}
+
+	changed := len(latest) != len(state.utxos)
+	spent := state.address.Spent
+	for key := range state.utxos {
+		if _, ok := latest[key]; !ok {
+			changed, spent = true, true
+		}
+	}
+	// A script with a history but no unspent outputs has spent them all.
+	if status != "" && len(latest) == 0 {
+		spent = true
+	}
+	funded := state.address.Funded || len(latest) > 0 || status != ""
+
+	changed = changed || funded != state.address.Funded || spent != state.address.Spent
+	state.status = status
+	state.utxos = latest
+	state.address.Funded = funded
+	state.address.Spent = spent
+	state.address.Balance = balance
+	state.address.UTXOs = append([]clients.UTXO{}, utxos...)
+	return changed
+}
+
+func (address WatchedAddress) copy() WatchedAddress {
+	address.UTXOs = append([]clients.UTXO{}, address.UTXOs...)
+	return address
+}
+
+func (client *client) ScriptStatuses(ctx context.Context, addresses []string) (map[string]string, error) {
+	statuser, ok := client.ClientCore.(clients.ScriptStatuser)
+	if !ok {
+		return nil, errors.NewErrUnsupportedOperation("ScriptStatuses", "current")
+	}
+	return statuser.ScriptStatuses(ctx, addresses)
+}
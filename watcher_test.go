@@ -0,0 +1,69 @@
+package libbtc_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/libbtc-go"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/renproject/libbtc-go/clients/mock"
+)
+
+var _ = Describe("Watcher", func() {
+	It("should report the addresses once funded and once spent", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		chain, account := newMockAccount()
+		address, err := account.Address()
+		Expect(err).ShouldNot(HaveOccurred())
+		otherKey, err := btcec.NewPrivateKey(btcec.S256())
+		Expect(err).ShouldNot(HaveOccurred())
+		other, err := account.PublicKeyToAddress(otherKey.PubKey().SerializeCompressed())
+		Expect(err).ShouldNot(HaveOccurred())
+
+		watcher := NewWatcher(ctx, account.BTCClient(), 10*time.Millisecond)
+		Expect(watcher.Watch(address.EncodeAddress(), other.EncodeAddress())).Should(Succeed())
+		Expect(watcher.Watch("not an address")).ShouldNot(Succeed())
+		status, ok := watcher.Status(address.EncodeAddress())
+		Expect(ok).Should(BeTrue())
+		Expect(status.Funded).Should(BeFalse())
+
+		_, err = chain.Fund(address.EncodeAddress(), 100000)
+		Expect(err).ShouldNot(HaveOccurred())
+		var change WatchedAddress
+		Eventually(watcher.Changes(), 5*time.Second).Should(Receive(&change))
+		Expect(change.Address).Should(Equal(address.EncodeAddress()))
+		Expect(change.Funded).Should(BeTrue())
+		Expect(change.Spent).Should(BeFalse())
+		Expect(change.Balance).Should(Equal(int64(100000)))
+		Expect(change.UTXOs).Should(HaveLen(1))
+
+		chain.Mine(1)
+		_, _, err = account.Transfer(ctx, other.EncodeAddress(), 0, Fast, true)
+		Expect(err).ShouldNot(HaveOccurred())
+		changes := map[string]WatchedAddress{}
+		for len(changes) < 2 {
+			Eventually(watcher.Changes(), 5*time.Second).Should(Receive(&change))
+			changes[change.Address] = change
+		}
+		Expect(changes[address.EncodeAddress()].Spent).Should(BeTrue())
+		Expect(changes[address.EncodeAddress()].Balance).Should(BeZero())
+		Expect(changes[other.EncodeAddress()].Funded).Should(BeTrue())
+		Expect(changes[other.EncodeAddress()].Balance).Should(BeNumerically(">", 0))
+
+		watcher.Unwatch(address.EncodeAddress())
+		_, ok = watcher.Status(address.EncodeAddress())
+		Expect(ok).Should(BeFalse())
+	})
+
+	It("should close the changes once the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		watcher := NewWatcher(ctx, NewClientFromCore(mock.NewChain(&chaincfg.RegressionNetParams)), 10*time.Millisecond)
+		cancel()
+		Eventually(watcher.Changes(), 5*time.Second).Should(BeClosed())
+	})
+})